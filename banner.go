@@ -0,0 +1,78 @@
+package goservice
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// startupInfo returns the service info and the config of every component
+// by name, with the values of sensitive keys redacted like log fields
+func (s *service) startupInfo() logger.Fields {
+	info := s.BuildInfo()
+	hostname, _ := os.Hostname()
+
+	return logger.Fields{
+		"service":    info.Name,
+		"version":    info.Version,
+		"commit":     info.Commit,
+		"go_version": info.GoVersion,
+		"pid":        os.Getpid(),
+		"hostname":   hostname,
+		"config":     s.componentConfigs(),
+	}
+}
+
+// componentConfigs returns DumpConfig of the ConfigDumpers,
+// the values of the flags they registered for the other components
+func (s *service) componentConfigs() map[string]interface{} {
+	flags := map[string]logger.Fields{}
+	for _, e := range s.EffectiveConfig() {
+		if flags[e.Plugin] == nil {
+			flags[e.Plugin] = logger.Fields{}
+		}
+		flags[e.Plugin][e.Name] = e.Value
+	}
+
+	configs := map[string]interface{}{}
+	for _, r := range s.runnables() {
+		if dumper, ok := r.(ConfigDumper); ok {
+			configs[r.Name()] = logger.Redact(dumper.DumpConfig())
+			continue
+		}
+		if config, ok := flags[r.Name()]; ok {
+			configs[r.Name()] = logger.Redact(config)
+		}
+	}
+
+	// the service, the logger and the application
+	for owner, config := range flags {
+		if _, ok := configs[owner]; !ok {
+			configs[owner] = logger.Redact(config)
+		}
+	}
+	return configs
+}
+
+// logStartupInfo logs the startup info in a single entry,
+// pretty printed too in debug mode
+func (s *service) logStartupInfo() {
+	info := s.startupInfo()
+	s.logger.Withs(info).Info("starting service")
+
+	if level := s.logger.GetLevel(); level == "debug" || level == "trace" {
+		b, err := json.MarshalIndent(info, "", "  ")
+		if err == nil {
+			s.logger.Debugf("startup info:\n%s", b)
+		}
+	}
+}
+
+// writeStartupInfo writes the startup info as indented json, see the flag print-config
+func (s *service) writeStartupInfo(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s.startupInfo())
+}
@@ -0,0 +1,67 @@
+package goservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// dumperPlugin describes its config with DumpConfig
+type dumperPlugin struct {
+	legacyPlugin
+}
+
+func (p *dumperPlugin) DumpConfig() map[string]interface{} {
+	return map[string]interface{}{"addr": "redis:6379", "password": "p1"}
+}
+
+func TestStartupInfo(t *testing.T) {
+	s := New(
+		WithName("demo"),
+		WithVersion("1.0.0"),
+		WithArgs([]string{"-db-dsn=mysql://u:p@db", "-log-redact-keys=dsn"}),
+		WithInitRunnable(&legacyPlugin{name: "mysql", prefix: "db"}),
+		WithInitRunnable(&dumperPlugin{legacyPlugin: legacyPlugin{name: "redis", prefix: "redis"}}),
+	)
+
+	info := s.(*service).startupInfo()
+	assert.Equal(t, "demo", info["service"], "should be equal")
+	assert.Equal(t, "1.0.0", info["version"], "should be equal")
+	assert.Equal(t, os.Getpid(), info["pid"], "should be equal")
+	assert.NotEmpty(t, info["go_version"], "should be set")
+
+	config := info["config"].(map[string]interface{})
+	assert.Equal(t, logger.Fields{"db-dsn": "[REDACTED]"}, config["mysql"], "log-redact-keys should be redacted")
+	assert.Equal(t, logger.Fields{"addr": "redis:6379", "password": "[REDACTED]"}, config["redis"], "should be equal")
+	assert.Equal(t, float64(3000), config[s.HTTPServer().Name()].(logger.Fields)["http_port"], "should be the config of the server")
+	assert.Equal(t, "dev", config[serviceFlagOwner].(logger.Fields)["app-env"], "should be equal")
+}
+
+func TestStartupInfoConnectionStrings(t *testing.T) {
+	var uri string
+	s := New(WithName("demo"), WithArgs([]string{}))
+	s.FlagSet().Var((*Secret)(&uri), "gorm-db-uri", "")
+	s.FlagSet().String("cache-url", "redis://:pass@cache:6379/0", "")
+	assert.Nil(t, s.FlagSet().Set("gorm-db-uri", "postgres://app:pass@db/app"), "must be nil")
+
+	b, err := json.Marshal(s.(*service).startupInfo())
+	assert.Nil(t, err, "must be nil")
+	assert.NotContains(t, string(b), "pass@", "passwords must not be logged")
+	assert.Contains(t, string(b), `"cache-url":"redis://:[REDACTED]@cache:6379/0"`, "should be equal")
+}
+
+func TestWriteStartupInfo(t *testing.T) {
+	s := New(WithName("demo"), WithArgs([]string{}))
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, s.(*service).writeStartupInfo(buf), "must be nil")
+
+	var out map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &out), "must be nil")
+	assert.Equal(t, "demo", out["service"], "should be equal")
+	assert.Contains(t, out["config"], serviceFlagOwner, "should have the service flags")
+}
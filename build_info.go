@@ -0,0 +1,55 @@
+package goservice
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/taimaifika/go-sdk/httpserver"
+)
+
+// WithBuildInfo sets the commit and build time served by /version.
+// By default they are read from the vcs info embedded by go build.
+func WithBuildInfo(commit, buildTime string) Option {
+	return func(s *service) {
+		s.commit = commit
+		s.buildTime = buildTime
+	}
+}
+
+// resolveBuildInfo fills the version, commit and build time
+// which are not set with the info embedded in the binary
+func (s *service) resolveBuildInfo() {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+
+	if s.version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		s.version = bi.Main.Version
+	}
+
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if s.commit == "" {
+				s.commit = setting.Value
+			}
+		case "vcs.time":
+			if s.buildTime == "" {
+				s.buildTime = setting.Value
+			}
+		}
+	}
+}
+
+// BuildInfo returns name, version and build metadata of the service
+func (s *service) BuildInfo() httpserver.BuildInfo {
+	return httpserver.BuildInfo{
+		Name:      s.name,
+		Version:   s.version,
+		Commit:    s.commit,
+		BuildTime: s.buildTime,
+		GoVersion: runtime.Version(),
+		StartTime: s.startTime,
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+const authKeyPrefix = "auth:"
+
+type authEntry[U sdkcm.User] struct {
+	OAuthID string `json:"oauth_id" msgpack:"oauth_id"`
+	User    U      `json:"user" msgpack:"user"`
+}
+
+type oauthID string
+
+func (id oauthID) OAuthID() string { return string(id) }
+
+// AuthCaching caches the requesters of the auth middleware by token signature,
+// it implements middleware.Caching and middleware.CachingWithTTL. GetUser of the
+// requesters must return their U, which is stored with the codec of the cache.
+type AuthCaching[U sdkcm.User] struct {
+	cache Cache
+	ttl   time.Duration
+}
+
+// NewAuthCaching stores the requesters in c, ttl is used by WriteCurrentUser
+func NewAuthCaching[U sdkcm.User](c Cache, ttl time.Duration) *AuthCaching[U] {
+	return &AuthCaching[U]{cache: c, ttl: ttl}
+}
+
+func (a *AuthCaching[U]) GetCurrentUser(ctx context.Context, sig string) (sdkcm.Requester, error) {
+	var e authEntry[U]
+	if err := a.cache.Get(ctx, authKeyPrefix+sig, &e); err != nil {
+		return nil, err
+	}
+	return sdkcm.CurrentUser(oauthID(e.OAuthID), e.User), nil
+}
+
+func (a *AuthCaching[U]) WriteCurrentUser(ctx context.Context, sig string, r sdkcm.Requester) error {
+	return a.WriteCurrentUserWithTTL(ctx, sig, r, a.ttl)
+}
+
+func (a *AuthCaching[U]) WriteCurrentUserWithTTL(ctx context.Context, sig string, r sdkcm.Requester, ttl time.Duration) error {
+	u, ok := r.GetUser().(U)
+	if !ok {
+		var zero U
+		return fmt.Errorf("cache: user %T of the requester is not %T", r.GetUser(), zero)
+	}
+	return a.cache.Set(ctx, authKeyPrefix+sig, authEntry[U]{OAuthID: r.OAuthID(), User: u}, ttl)
+}
+
+// DeleteCurrentUser drops the requester of sig, e.g. once its token is revoked
+func (a *AuthCaching[U]) DeleteCurrentUser(ctx context.Context, sig string) error {
+	return a.cache.Delete(ctx, authKeyPrefix+sig)
+}
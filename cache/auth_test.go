@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+type testUser struct {
+	ID   uint32 `json:"id" msgpack:"id"`
+	Role string `json:"role" msgpack:"role"`
+}
+
+func (u testUser) UserID() uint32        { return u.ID }
+func (u testUser) GetSystemRole() string { return u.Role }
+func (u testUser) GetUser() interface{}  { return u }
+
+type otherUser struct{ testUser }
+
+func (u otherUser) GetUser() interface{} { return u }
+
+func TestAuthCaching(t *testing.T) {
+	for _, codec := range []Codec{JSON, Msgpack} {
+		c, _ := NewLRU("auth", LRUConfig{Codec: codec})
+		a := NewAuthCaching[testUser](c, time.Minute)
+		ctx := context.Background()
+
+		_, err := a.GetCurrentUser(ctx, "sig")
+		assert.ErrorIs(t, err, ErrNotFound, "should be a miss")
+
+		requester := sdkcm.CurrentUser(oauthID("oauth-1"), testUser{ID: 1, Role: "admin"})
+		assert.Nil(t, a.WriteCurrentUser(ctx, "sig", requester), "must be nil")
+
+		r, err := a.GetCurrentUser(ctx, "sig")
+		assert.Nil(t, err, "must be nil")
+		assert.Equal(t, "oauth-1", r.OAuthID(), "should be equal")
+		assert.Equal(t, uint32(1), r.UserID(), "should be equal")
+		assert.Equal(t, "admin", r.GetSystemRole(), "should be equal")
+
+		assert.Nil(t, a.DeleteCurrentUser(ctx, "sig"), "must be nil")
+		_, err = a.GetCurrentUser(ctx, "sig")
+		assert.ErrorIs(t, err, ErrNotFound, "should be deleted")
+
+		other := sdkcm.CurrentUser(oauthID("oauth-2"), otherUser{})
+		assert.NotNil(t, a.WriteCurrentUser(ctx, "sig", other), "should be an error")
+	}
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound is returned by Get for missing and expired keys
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache stores encoded values by key, the backends are NewLRU, NewLayered
+// and the redis one of sdkredis
+type Cache interface {
+	Name() string
+	// Get decodes the value of key into dst, a pointer
+	Get(ctx context.Context, key string, dst interface{}) error
+	// Set stores value for ttl, 0 ttl never expires
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// PrefixDeleter is implemented by the caches which can delete all the keys
+// starting with a prefix, the backends of this package and sdkredis do
+type PrefixDeleter interface {
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// DeletePrefix deletes the keys of c starting with prefix
+func DeletePrefix(ctx context.Context, c Cache, prefix string) error {
+	d, ok := c.(PrefixDeleter)
+	if !ok {
+		return fmt.Errorf("cache %s can't delete keys by prefix", c.Name())
+	}
+	return d.DeletePrefix(ctx, prefix)
+}
+
+// Codec encodes the values of a cache
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+
+var (
+	JSON    Codec = jsonCodec{}
+	Msgpack Codec = msgpackCodec{}
+)
+
+// CodecByName returns the codec of name: json or msgpack
+func CodecByName(name string) (Codec, error) {
+	switch name {
+	case "", "json":
+		return JSON, nil
+	case "msgpack":
+		return Msgpack, nil
+	}
+	return nil, fmt.Errorf("unknown cache codec %q, must be json or msgpack", name)
+}
+
+var loads singleflight.Group
+
+// GetOrLoad returns the value of key, on a miss it's loaded then stored for ttl.
+// Concurrent misses of a key share one load. Cache errors are logged and the
+// value is loaded anyway.
+func GetOrLoad[T any](ctx context.Context, c Cache, key string, ttl time.Duration, load func(ctx context.Context) (T, error)) (T, error) {
+	var value T
+	err := c.Get(ctx, key, &value)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		logWarn(c.Name(), "cannot get %s: %s", key, err.Error())
+	}
+
+	v, err, shared := loads.Do(c.Name()+"\x00"+key, func() (interface{}, error) {
+		value, err := load(ctx)
+		if err != nil {
+			return value, err
+		}
+		if err := c.Set(ctx, key, value, ttl); err != nil {
+			logWarn(c.Name(), "cannot set %s: %s", key, err.Error())
+		}
+		return value, nil
+	})
+
+	trace.SpanFromContext(ctx).AddEvent("cache.load", trace.WithAttributes(
+		attribute.String("cache.name", c.Name()),
+		attribute.Bool("cache.shared", shared),
+		attribute.Bool("cache.error", err != nil),
+	))
+
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+func logWarn(name, format string, args ...interface{}) {
+	current := logger.GetCurrent()
+	if current == nil {
+		return
+	}
+	current.GetLogger("cache").Withs(logger.Fields{"cache": name}).Warnf(format, args...)
+}
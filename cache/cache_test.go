@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	c, _ := NewLRU("items", LRUConfig{})
+	ctx := context.Background()
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(context.Context) (item, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return item{ID: 1}, nil
+	}
+
+	// concurrent misses share the load
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := GetOrLoad(ctx, c, "1", time.Minute, load)
+			assert.Nil(t, err, "must be nil")
+			assert.Equal(t, item{ID: 1}, got, "should be equal")
+		}()
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&loads) == 1 }, time.Second, time.Millisecond, "should load")
+	time.Sleep(20 * time.Millisecond) // lets the other gets join the load
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads), "should be loaded once")
+
+	// stored
+	got, err := GetOrLoad(ctx, c, "1", time.Minute, load)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, item{ID: 1}, got, "should be equal")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads), "should be a hit")
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	c, _ := NewLRU("items", LRUConfig{})
+	errDown := errors.New("database is down")
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, span := provider.Tracer("test").Start(context.Background(), "get")
+
+	_, err := GetOrLoad(ctx, c, "1", 0, func(context.Context) (item, error) { return item{}, errDown })
+	span.End()
+	assert.ErrorIs(t, err, errDown, "should be equal")
+	assert.Equal(t, 0, c.Len(), "errors should not be stored")
+
+	events := exporter.GetSpans()[0].Events
+	assert.Len(t, events, 1, "should be equal")
+	assert.Equal(t, "cache.load", events[0].Name, "should be equal")
+	assert.Contains(t, events[0].Attributes, attribute.String("cache.name", "items"), "should contain the name")
+	assert.Contains(t, events[0].Attributes, attribute.Bool("cache.error", true), "should contain the error")
+}
+
+func TestMetrics(t *testing.T) {
+	reader := metric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(metric.NewMeterProvider(metric.WithReader(reader)))
+	defer otel.SetMeterProvider(prev)
+
+	c, _ := NewLRU("items", LRUConfig{})
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, _ = GetOrLoad(ctx, c, "1", 0, func(context.Context) (item, error) { return item{ID: 1}, nil })
+	}
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(ctx, &rm), "must be nil")
+
+	results := map[string]int64{}
+	for _, dp := range rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints {
+		name, _ := dp.Attributes.Value("name")
+		assert.Equal(t, "items", name.AsString(), "should be equal")
+		result, _ := dp.Attributes.Value("result")
+		results[result.AsString()] = dp.Value
+	}
+	assert.Equal(t, map[string]int64{"hit": 2, "miss": 1}, results, "should be equal")
+}
+
+func TestCodecByName(t *testing.T) {
+	for _, name := range []string{"json", "msgpack"} {
+		codec, err := CodecByName(name)
+		assert.Nil(t, err, "must be nil")
+
+		data, err := codec.Marshal(item{ID: 1, Name: "one"})
+		assert.Nil(t, err, "must be nil")
+		var got item
+		assert.Nil(t, codec.Unmarshal(data, &got), "must be nil")
+		assert.Equal(t, item{ID: 1, Name: "one"}, got, "should be equal")
+	}
+
+	_, err := CodecByName("xml")
+	assert.NotNil(t, err, "should be an error")
+}
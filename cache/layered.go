@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invalidation tells the layered caches named Cache to drop the local entries of Keys
+// and of the keys starting with Prefixes, Origin is the id of the sender which has
+// already dropped them
+type Invalidation struct {
+	Origin   string   `json:"origin"`
+	Cache    string   `json:"cache"`
+	Keys     []string `json:"keys,omitempty"`
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// Invalidator broadcasts the invalidations of the layered caches to all replicas,
+// sdkredis has one on redis pub/sub
+type Invalidator interface {
+	Publish(ctx context.Context, inv Invalidation) error
+	// Subscribe calls fn with every invalidation until the returned closer is closed
+	Subscribe(fn func(Invalidation)) (io.Closer, error)
+}
+
+// Layered is a local cache, usually an LRU, in front of a remote one shared by
+// the replicas. Sets and deletes are broadcast so the other replicas drop their
+// local entries, lost invalidations leave them stale for at most the local ttl.
+type Layered struct {
+	name     string
+	local    Cache
+	remote   Cache
+	inv      Invalidator
+	localTTL time.Duration
+	id       string
+	metrics  *Metrics
+	sub      io.Closer
+}
+
+func NewLayered(name string, local, remote Cache, inv Invalidator, localTTL time.Duration) (*Layered, error) {
+	if localTTL <= 0 {
+		return nil, errors.New("layered cache: local ttl must be positive")
+	}
+
+	c := &Layered{
+		name:     name,
+		local:    local,
+		remote:   remote,
+		inv:      inv,
+		localTTL: localTTL,
+		id:       uuid.NewString(),
+		metrics:  NewMetrics(name),
+	}
+
+	sub, err := inv.Subscribe(c.invalidate)
+	if err != nil {
+		return nil, err
+	}
+	c.sub = sub
+	return c, nil
+}
+
+func (c *Layered) Name() string {
+	return c.name
+}
+
+func (c *Layered) Get(ctx context.Context, key string, dst interface{}) error {
+	err := c.get(ctx, key, dst)
+	c.metrics.RecordGet(ctx, err)
+	return err
+}
+
+func (c *Layered) get(ctx context.Context, key string, dst interface{}) error {
+	if err := c.local.Get(ctx, key, dst); err == nil {
+		return nil
+	}
+
+	if err := c.remote.Get(ctx, key, dst); err != nil {
+		return err
+	}
+	if err := c.local.Set(ctx, key, dst, c.localTTL); err != nil {
+		logWarn(c.name, "cannot set %s locally: %s", key, err.Error())
+	}
+	return nil
+}
+
+func (c *Layered) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := c.remote.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	localTTL := c.localTTL
+	if ttl > 0 && ttl < localTTL {
+		localTTL = ttl
+	}
+	if err := c.local.Set(ctx, key, value, localTTL); err != nil {
+		logWarn(c.name, "cannot set %s locally: %s", key, err.Error())
+	}
+	return c.publish(ctx, key)
+}
+
+func (c *Layered) Delete(ctx context.Context, keys ...string) error {
+	if err := c.remote.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	if err := c.local.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return c.publish(ctx, keys...)
+}
+
+// DeletePrefix deletes the keys starting with prefix, both caches must be PrefixDeleters
+func (c *Layered) DeletePrefix(ctx context.Context, prefix string) error {
+	if err := DeletePrefix(ctx, c.remote, prefix); err != nil {
+		return err
+	}
+	if err := DeletePrefix(ctx, c.local, prefix); err != nil {
+		return err
+	}
+	return c.inv.Publish(ctx, Invalidation{Origin: c.id, Cache: c.name, Prefixes: []string{prefix}})
+}
+
+func (c *Layered) publish(ctx context.Context, keys ...string) error {
+	return c.inv.Publish(ctx, Invalidation{Origin: c.id, Cache: c.name, Keys: keys})
+}
+
+func (c *Layered) invalidate(inv Invalidation) {
+	if inv.Origin == c.id || inv.Cache != c.name {
+		return
+	}
+	ctx := context.Background()
+	if err := c.local.Delete(ctx, inv.Keys...); err != nil {
+		logWarn(c.name, "cannot invalidate local keys: %s", err.Error())
+	}
+	for _, prefix := range inv.Prefixes {
+		if err := DeletePrefix(ctx, c.local, prefix); err != nil {
+			logWarn(c.name, "cannot invalidate local keys of %s: %s", prefix, err.Error())
+		}
+	}
+}
+
+// Close stops receiving invalidations, the caches it layers stay open
+func (c *Layered) Close() error {
+	return c.sub.Close()
+}
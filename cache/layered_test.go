@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memInvalidator broadcasts the invalidations to the subscribers of the process
+type memInvalidator struct {
+	mu   sync.Mutex
+	subs map[int]func(Invalidation)
+	next int
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func (i *memInvalidator) Publish(_ context.Context, inv Invalidation) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, fn := range i.subs {
+		fn(inv)
+	}
+	return nil
+}
+
+func (i *memInvalidator) Subscribe(fn func(Invalidation)) (io.Closer, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.subs == nil {
+		i.subs = map[int]func(Invalidation){}
+	}
+	id := i.next
+	i.next++
+	i.subs[id] = fn
+	return closerFunc(func() error {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		delete(i.subs, id)
+		return nil
+	}), nil
+}
+
+func newReplica(t *testing.T, remote Cache, inv Invalidator) (*Layered, *LRU) {
+	local, _ := NewLRU("items.local", LRUConfig{})
+	c, err := NewLayered("items", local, remote, inv, time.Minute)
+	assert.Nil(t, err, "must be nil")
+	return c, local
+}
+
+func TestLayered(t *testing.T) {
+	remote, _ := NewLRU("items.remote", LRUConfig{})
+	inv := &memInvalidator{}
+	a, localA := newReplica(t, remote, inv)
+	b, localB := newReplica(t, remote, inv)
+	ctx := context.Background()
+
+	// b reads the value set by a from the remote cache, then keeps it
+	assert.Nil(t, a.Set(ctx, "1", item{ID: 1, Name: "one"}, time.Hour), "must be nil")
+	assert.Equal(t, 1, localA.Len(), "should be set locally")
+	var got item
+	assert.Nil(t, b.Get(ctx, "1", &got), "must be nil")
+	assert.Equal(t, item{ID: 1, Name: "one"}, got, "should be equal")
+	assert.Equal(t, 1, localB.Len(), "should be set locally")
+
+	// sets of a drop the stale entry of b but not its own
+	assert.Nil(t, a.Set(ctx, "1", item{ID: 1, Name: "uno"}, time.Hour), "must be nil")
+	assert.Equal(t, 1, localA.Len(), "should be kept")
+	assert.Equal(t, 0, localB.Len(), "should be invalidated")
+	assert.Nil(t, b.Get(ctx, "1", &got), "must be nil")
+	assert.Equal(t, "uno", got.Name, "should be equal")
+
+	assert.Nil(t, b.Delete(ctx, "1"), "must be nil")
+	assert.ErrorIs(t, a.Get(ctx, "1", &got), ErrNotFound, "should be deleted")
+
+	// prefixes
+	assert.Nil(t, a.Set(ctx, "items:1", item{ID: 1}, 0), "must be nil")
+	assert.Nil(t, a.Set(ctx, "items:2", item{ID: 2}, 0), "must be nil")
+	assert.Nil(t, b.Get(ctx, "items:1", &got), "must be nil")
+	assert.Nil(t, a.DeletePrefix(ctx, "items:"), "must be nil")
+	assert.Equal(t, 0, localA.Len(), "should be deleted")
+	assert.Equal(t, 0, localB.Len(), "should be invalidated")
+	assert.ErrorIs(t, b.Get(ctx, "items:2", &got), ErrNotFound, "should be deleted")
+
+	// closed caches aren't invalidated anymore
+	assert.Nil(t, a.Set(ctx, "1", item{ID: 1}, 0), "must be nil")
+	assert.Nil(t, b.Get(ctx, "1", &got), "must be nil")
+	assert.Nil(t, b.Close(), "must be nil")
+	assert.Nil(t, a.Delete(ctx, "1"), "must be nil")
+	assert.Equal(t, 1, localB.Len(), "should not be invalidated")
+}
+
+func TestLayeredLocalTTL(t *testing.T) {
+	remote, _ := NewLRU("items.remote", LRUConfig{})
+	a, localA := newReplica(t, remote, &memInvalidator{})
+	now := time.Now()
+	localA.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	assert.Nil(t, a.Set(ctx, "1", item{ID: 1}, time.Hour), "must be nil")
+	assert.Nil(t, remote.Set(ctx, "1", item{ID: 2}, time.Hour), "must be nil")
+
+	var got item
+	assert.Nil(t, a.Get(ctx, "1", &got), "must be nil")
+	assert.Equal(t, 1, got.ID, "should be the local value")
+
+	now = now.Add(time.Minute)
+	assert.Nil(t, a.Get(ctx, "1", &got), "must be nil")
+	assert.Equal(t, 2, got.ID, "should be the remote value after the local ttl")
+
+	_, err := NewLayered("items", localA, remote, &memInvalidator{}, 0)
+	assert.NotNil(t, err, "should be an error")
+}
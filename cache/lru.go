@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LRUConfig struct {
+	// entries kept before evicting the least recently used, 0 => unlimited
+	MaxEntries int
+	// bytes of the keys and encoded values kept, 0 => unlimited
+	MaxBytes int64
+	// nil => JSON
+	Codec Codec
+}
+
+func (cfg LRUConfig) Validate() error {
+	if cfg.MaxEntries < 0 || cfg.MaxBytes < 0 {
+		return errors.New("lru: max entries and max bytes must not be negative")
+	}
+	return nil
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e *lruEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// LRU is an in-process cache, the values are stored encoded so callers
+// never share them
+type LRU struct {
+	name    string
+	cfg     LRUConfig
+	codec   Codec
+	metrics *Metrics
+	now     func() time.Time
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+}
+
+func NewLRU(name string, cfg LRUConfig) (*LRU, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	codec := cfg.Codec
+	if codec == nil {
+		codec = JSON
+	}
+	return &LRU{
+		name:    name,
+		cfg:     cfg,
+		codec:   codec,
+		metrics: NewMetrics(name),
+		now:     time.Now,
+		ll:      list.New(),
+		items:   map[string]*list.Element{},
+	}, nil
+}
+
+func (c *LRU) Name() string {
+	return c.name
+}
+
+// Len returns the number of entries, expired ones included until they are evicted
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU) Get(ctx context.Context, key string, dst interface{}) error {
+	value, err := c.get(key)
+	c.metrics.RecordGet(ctx, err)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(value, dst)
+}
+
+func (c *LRU) get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	e := el.Value.(*lruEntry)
+	if !e.expiresAt.IsZero() && !c.now().Before(e.expiresAt) {
+		c.remove(el)
+		return nil, ErrNotFound
+	}
+
+	c.ll.MoveToFront(el)
+	return e.value, nil
+}
+
+func (c *LRU) Set(_ context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	e := &lruEntry{key: key, value: data}
+	if ttl > 0 {
+		e.expiresAt = c.now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	if c.cfg.MaxBytes > 0 && e.size() > c.cfg.MaxBytes {
+		return fmt.Errorf("cache %s: value of %s is larger than max bytes %d", c.name, key, c.cfg.MaxBytes)
+	}
+
+	c.items[key] = c.ll.PushFront(e)
+	c.bytes += e.size()
+	for (c.cfg.MaxEntries > 0 && c.ll.Len() > c.cfg.MaxEntries) || (c.cfg.MaxBytes > 0 && c.bytes > c.cfg.MaxBytes) {
+		c.remove(c.ll.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+func (c *LRU) DeletePrefix(_ context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+// remove drops the entry of el, c.mu is held
+func (c *LRU) remove(el *list.Element) {
+	e := c.ll.Remove(el).(*lruEntry)
+	delete(c.items, e.key)
+	c.bytes -= e.size()
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	ID   int    `json:"id" msgpack:"id"`
+	Name string `json:"name" msgpack:"name"`
+}
+
+func TestLRU(t *testing.T) {
+	c, err := NewLRU("items", LRUConfig{MaxEntries: 2})
+	assert.Nil(t, err, "must be nil")
+	ctx := context.Background()
+
+	var got item
+	assert.ErrorIs(t, c.Get(ctx, "1", &got), ErrNotFound, "should be a miss")
+
+	assert.Nil(t, c.Set(ctx, "1", item{ID: 1, Name: "one"}, 0), "must be nil")
+	assert.Nil(t, c.Get(ctx, "1", &got), "must be nil")
+	assert.Equal(t, item{ID: 1, Name: "one"}, got, "should be equal")
+
+	// 1 is used more recently than 2
+	assert.Nil(t, c.Set(ctx, "2", item{ID: 2}, 0), "must be nil")
+	assert.Nil(t, c.Get(ctx, "1", &got), "must be nil")
+	assert.Nil(t, c.Set(ctx, "3", item{ID: 3}, 0), "must be nil")
+	assert.Equal(t, 2, c.Len(), "should be equal")
+	assert.ErrorIs(t, c.Get(ctx, "2", &got), ErrNotFound, "should be evicted")
+	assert.Nil(t, c.Get(ctx, "1", &got), "must be nil")
+
+	assert.Nil(t, c.Delete(ctx, "1", "3", "4"), "must be nil")
+	assert.Equal(t, 0, c.Len(), "should be equal")
+}
+
+func TestLRUDeletePrefix(t *testing.T) {
+	c, _ := NewLRU("items", LRUConfig{})
+	ctx := context.Background()
+	for _, key := range []string{"/items?a=1", "/items/1?", "/orders?"} {
+		assert.Nil(t, c.Set(ctx, key, "", 0), "must be nil")
+	}
+
+	assert.Nil(t, DeletePrefix(ctx, c, "/items"), "must be nil")
+	assert.Equal(t, 1, c.Len(), "should be equal")
+	assert.Equal(t, int64(len("/orders?")+2), c.bytes, "should be equal")
+
+	var got string
+	assert.Nil(t, c.Get(ctx, "/orders?", &got), "must be nil")
+}
+
+func TestLRUExpiry(t *testing.T) {
+	c, _ := NewLRU("items", LRUConfig{})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	assert.Nil(t, c.Set(ctx, "1", item{ID: 1}, time.Minute), "must be nil")
+	now = now.Add(59 * time.Second)
+	var got item
+	assert.Nil(t, c.Get(ctx, "1", &got), "must be nil")
+
+	now = now.Add(time.Second)
+	assert.ErrorIs(t, c.Get(ctx, "1", &got), ErrNotFound, "should be expired")
+	assert.Equal(t, 0, c.Len(), "should be removed")
+}
+
+func TestLRUMaxBytes(t *testing.T) {
+	c, _ := NewLRU("items", LRUConfig{MaxBytes: 20})
+	ctx := context.Background()
+
+	// key and value are 1 + 8 bytes
+	assert.Nil(t, c.Set(ctx, "1", "123456", 0), "must be nil")
+	assert.Nil(t, c.Set(ctx, "2", "123456", 0), "must be nil")
+	assert.Nil(t, c.Set(ctx, "3", "123456", 0), "must be nil")
+	assert.Equal(t, 2, c.Len(), "should be equal")
+
+	var got string
+	assert.ErrorIs(t, c.Get(ctx, "1", &got), ErrNotFound, "should be evicted")
+
+	assert.NotNil(t, c.Set(ctx, "2", "12345678901234567890", 0), "should be an error")
+	assert.ErrorIs(t, c.Get(ctx, "2", &got), ErrNotFound, "should be removed")
+	assert.Equal(t, int64(9), c.bytes, "should be equal")
+}
+
+func TestLRUCopies(t *testing.T) {
+	c, _ := NewLRU("items", LRUConfig{Codec: Msgpack})
+	ctx := context.Background()
+
+	value := []int{1, 2}
+	assert.Nil(t, c.Set(ctx, "k", value, 0), "must be nil")
+	value[0] = 3
+
+	var got []int
+	assert.Nil(t, c.Get(ctx, "k", &got), "must be nil")
+	assert.Equal(t, []int{1, 2}, got, "should not share the value")
+}
+
+func TestLRUConfigValidate(t *testing.T) {
+	_, err := NewLRU("items", LRUConfig{MaxEntries: -1})
+	assert.NotNil(t, err, "should be an error")
+}
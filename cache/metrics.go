@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/taimaifika/go-sdk/cache"
+
+// Metrics counts the gets of a cache by result, the hit ratio is
+// hit / (hit + miss). The backends record their gets with it.
+type Metrics struct {
+	hit      metric.MeasurementOption
+	miss     metric.MeasurementOption
+	requests metric.Int64Counter
+}
+
+func NewMetrics(name string) *Metrics {
+	requests, err := otel.Meter(meterName).Int64Counter("cache.requests",
+		metric.WithDescription("Number of cache gets by result: hit or miss"),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return &Metrics{
+		hit:      metric.WithAttributes(attribute.String("name", name), attribute.String("result", "hit")),
+		miss:     metric.WithAttributes(attribute.String("name", name), attribute.String("result", "miss")),
+		requests: requests,
+	}
+}
+
+// RecordGet counts a nil err as a hit and ErrNotFound as a miss, other errors aren't counted
+func (m *Metrics) RecordGet(ctx context.Context, err error) {
+	switch {
+	case err == nil:
+		m.requests.Add(ctx, 1, m.hit)
+	case errors.Is(err, ErrNotFound):
+		m.requests.Add(ctx, 1, m.miss)
+	}
+}
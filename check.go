@@ -0,0 +1,158 @@
+package goservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// Formats of WriteCheckReport
+const (
+	CheckFormatText = "text"
+	CheckFormatJSON = "json"
+)
+
+// Statuses of the components in a CheckReport
+const (
+	CheckStatusOK     = "ok"
+	CheckStatusFailed = "failed"
+	// configured, the component has no connectivity check
+	CheckStatusConfigured = "configured"
+)
+
+const defaultCheckTimeout = 5 * time.Second
+
+// ComponentCheck is the result of Configure and of the connectivity check of a component
+type ComponentCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CheckReport is the result of Check, secret values of Config and the
+// passwords of the connection strings in the errors are masked
+type CheckReport struct {
+	Service string `json:"service"`
+	Version string `json:"version"`
+	OK      bool   `json:"ok"`
+	// errors of the flags, the env, the config file and the dependencies
+	Errors     []string         `json:"errors,omitempty"`
+	Components []ComponentCheck `json:"components"`
+	Config     []ConfigEntry    `json:"config"`
+}
+
+// Check validates the config, configures every component and checks the
+// connectivity of the ConnectivityCheckers, without running any of them:
+// no port is bound. The components aren't stopped, the process should exit.
+// Init runs it and exits with the flag -check.
+func (s *service) Check(ctx context.Context) CheckReport {
+	report := CheckReport{Service: s.name, Version: s.version, OK: true}
+
+	for _, err := range []error{s.initErr, s.resolveFlagValues(), s.checkFlagMeta(), validateWaitForReady(s.waitForReady)} {
+		if err != nil {
+			report.OK = false
+			report.Errors = append(report.Errors, logger.RedactCredentials(err.Error()))
+		}
+	}
+
+	levels, err := s.initLevels()
+	if err != nil {
+		report.OK = false
+		report.Errors = append(report.Errors, logger.RedactCredentials(err.Error()))
+	}
+	s.initOrder = nil
+	for _, level := range levels {
+		s.initOrder = append(s.initOrder, level...)
+	}
+
+	for _, r := range s.runnables() {
+		c := s.checkComponent(ctx, r)
+		if c.Status == CheckStatusFailed {
+			report.OK = false
+		}
+		report.Components = append(report.Components, c)
+	}
+
+	// URI and DSN flags are secrets, the other values have their passwords redacted
+	report.Config = s.EffectiveConfig()
+	return report
+}
+
+func (s *service) checkComponent(ctx context.Context, r Runnable) ComponentCheck {
+	c := ComponentCheck{Name: r.Name(), Status: CheckStatusOK}
+
+	err := r.Configure()
+	if err == nil {
+		checker, ok := r.(ConnectivityChecker)
+		if !ok {
+			c.Status = CheckStatusConfigured
+			return c
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, s.checkTimeout)
+		defer cancel()
+		err = checker.CheckConnectivity(ctx)
+	}
+
+	if err != nil {
+		c.Status = CheckStatusFailed
+		// drivers quote the connection string in their errors
+		c.Error = logger.RedactCredentials(err.Error())
+	}
+	return c
+}
+
+// runCheck writes the report of Check to stdout and exits, 1 if anything failed
+func (s *service) runCheck() {
+	report := s.Check(context.Background())
+	if err := WriteCheckReport(os.Stdout, report, s.checkFormat); err != nil {
+		s.logger.Fatal(err.Error())
+	}
+
+	if !report.OK {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// WriteCheckReport writes report as text tables or as json
+func WriteCheckReport(w io.Writer, report CheckReport, format string) error {
+	switch format {
+	case CheckFormatText, "":
+	case CheckFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	default:
+		return fmt.Errorf("unknown check format %q, must be %s or %s", format, CheckFormatText, CheckFormatJSON)
+	}
+
+	status := "OK"
+	if !report.OK {
+		status = "FAILED"
+	}
+	_, _ = fmt.Fprintf(w, "%s %s: %s\n", report.Service, report.Version, status)
+
+	for _, e := range report.Errors {
+		_, _ = fmt.Fprintf(w, "error: %s\n", e)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "COMPONENT\tSTATUS\tERROR")
+	for _, c := range report.Components {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, c.Status, c.Error)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(w)
+	return writeConfigTable(w, report.Config)
+}
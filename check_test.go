@@ -0,0 +1,105 @@
+package goservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checkedPlugin fails its connectivity check with err
+type checkedPlugin struct {
+	legacyPlugin
+	err error
+}
+
+func (p *checkedPlugin) CheckConnectivity(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("no timeout")
+	}
+	return p.err
+}
+
+func findComponentCheck(report CheckReport, name string) ComponentCheck {
+	for _, c := range report.Components {
+		if c.Name == name {
+			return c
+		}
+	}
+	return ComponentCheck{}
+}
+
+func TestCheck(t *testing.T) {
+	s := New(
+		WithName("demo"),
+		WithArgs([]string{"-check-timeout=1s"}),
+		WithInitRunnable(&checkedPlugin{legacyPlugin: legacyPlugin{name: "mysql", prefix: "db"}, err: errors.New("connection refused")}),
+		WithInitRunnable(&checkedPlugin{legacyPlugin: legacyPlugin{name: "redis", prefix: "redis"}}),
+		WithInitRunnable(&legacyPlugin{name: "warmer", prefix: "warmer"}),
+	)
+
+	report := s.Check(context.Background())
+	assert.False(t, report.OK, "should fail")
+	assert.Empty(t, report.Errors, "should be empty")
+	assert.Equal(t, ComponentCheck{Name: "mysql", Status: CheckStatusFailed, Error: "connection refused"}, findComponentCheck(report, "mysql"), "should be equal")
+	assert.Equal(t, CheckStatusOK, findComponentCheck(report, "redis").Status, "should be equal")
+	assert.Equal(t, CheckStatusConfigured, findComponentCheck(report, "warmer").Status, "should be equal")
+	assert.Equal(t, CheckStatusConfigured, findComponentCheck(report, s.HTTPServer().Name()).Status, "the server must not run")
+	assert.NotEmpty(t, report.Config, "should have the config")
+}
+
+func TestCheckMasksConnectionStrings(t *testing.T) {
+	s := New(
+		WithName("demo"),
+		WithArgs([]string{"-db-dsn=mysql://app:pass@db/app", "-check-format=json"}),
+		WithInitRunnable(&checkedPlugin{legacyPlugin: legacyPlugin{name: "mysql", prefix: "db"}, err: errors.New(`dial "mysql://app:pass@db/app": connection refused`)}),
+	)
+
+	report := s.Check(context.Background())
+	assert.Equal(t, maskedValue, findConfigEntry(report.Config, "db-dsn").Value, "should be masked")
+	assert.Equal(t, `dial "mysql://app:[REDACTED]@db/app": connection refused`, findComponentCheck(report, "mysql").Error, "should be equal")
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, WriteCheckReport(buf, report, CheckFormatJSON), "must be nil")
+	assert.NotContains(t, buf.String(), "pass@", "the report must not contain passwords")
+}
+
+func TestCheckConfigErrors(t *testing.T) {
+	s := New(WithName("demo"), WithArgs([]string{"-service-wait-for-ready=never"}))
+
+	report := s.Check(context.Background())
+	assert.False(t, report.OK, "should fail")
+	if assert.Len(t, report.Errors, 1, "should have an error") {
+		assert.Contains(t, report.Errors[0], "never")
+	}
+}
+
+func TestWriteCheckReport(t *testing.T) {
+	report := CheckReport{
+		Service: "demo",
+		Version: "1.0.0",
+		Errors:  []string{"flag \"db-dsn\" is required"},
+		Components: []ComponentCheck{
+			{Name: "mysql", Status: CheckStatusFailed, Error: "connection refused"},
+			{Name: "gin", Status: CheckStatusConfigured},
+		},
+		Config: []ConfigEntry{{Name: "db-password", Value: maskedValue, Source: SourceEnv, Plugin: "mysql"}},
+	}
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, WriteCheckReport(buf, report, CheckFormatText), "must be nil")
+	assert.Contains(t, buf.String(), "demo 1.0.0: FAILED\nerror: flag \"db-dsn\" is required\n")
+	assert.Contains(t, buf.String(), "mysql      failed      connection refused\n")
+	assert.Contains(t, buf.String(), "db-password  ******  env     mysql\n")
+
+	buf.Reset()
+	assert.Nil(t, WriteCheckReport(buf, report, CheckFormatJSON), "must be nil")
+	var decoded CheckReport
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &decoded), "must be nil")
+	assert.Equal(t, report, decoded, "should be equal")
+
+	assert.NotNil(t, WriteCheckReport(buf, report, "xml"), "should be an error")
+}
@@ -0,0 +1,147 @@
+package goservice
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats of ExportConfig
+const (
+	ExportFormatEnv  = "env"
+	ExportFormatYAML = "yaml"
+	ExportFormatK8s  = "k8s"
+)
+
+// ExportConfig writes the current value of every flag, so values from env
+// or the config file are kept:
+//   - env: dotenv with the descriptions as comments
+//   - yaml: the config file schema, sections are flag prefixes
+//   - k8s: a ConfigMap and a Secret named after the service
+//
+// Secret values are never written, they are commented out in env and yaml
+// and placeholders in the k8s Secret.
+func (s *service) ExportConfig(w io.Writer, format string) error {
+	switch format {
+	case ExportFormatEnv, "":
+		return s.exportEnv(w)
+	case ExportFormatYAML:
+		return s.exportYAML(w)
+	case ExportFormatK8s:
+		return s.GenerateK8sManifests(w, ManifestOptions{})
+	default:
+		return fmt.Errorf("unknown export format %q, must be %s, %s or %s", format, ExportFormatEnv, ExportFormatYAML, ExportFormatK8s)
+	}
+}
+
+// exportedFlags returns the flags to export, secrets are separated
+func (s *service) exportedFlags() (configs, secrets []*flag.Flag) {
+	s.cmdLine.VisitAll(func(f *flag.Flag) {
+		if f.Name == "outenv" || s.isDeprecatedFlag(f.Name) {
+			return
+		}
+
+		if s.isSecretFlag(f) {
+			secrets = append(secrets, f)
+		} else {
+			configs = append(configs, f)
+		}
+	})
+	return configs, secrets
+}
+
+func (s *service) exportEnv(w io.Writer) error {
+	configs, secrets := s.exportedFlags()
+
+	b := &strings.Builder{}
+	for _, f := range configs {
+		fmt.Fprintf(b, "## %s (-%s)\n", f.Usage, f.Name)
+
+		// defaults are left commented out
+		value := f.Value.String()
+		if s.flagSource(f.Name) == SourceDefault {
+			b.WriteString("#")
+		}
+		fmt.Fprintf(b, "%s=%s\n\n", s.cmdLine.envName(f.Name), envValue(f, value))
+	}
+
+	if len(secrets) > 0 {
+		b.WriteString("## Secrets, values are not exported\n\n")
+		for _, f := range secrets {
+			fmt.Fprintf(b, "## %s (-%s)\n#%s=\n\n", f.Usage, f.Name, s.cmdLine.envName(f.Name))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func envValue(f *flag.Flag, value string) string {
+	if fmt.Sprintf("%T", f.Value) == "*flag.stringValue" && value != "" {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func (s *service) exportYAML(w io.Writer) error {
+	configs, secrets := s.exportedFlags()
+
+	isSecret := map[string]bool{}
+	for _, f := range secrets {
+		isSecret[f.Name] = true
+	}
+
+	names := map[string]bool{}
+	all := append(configs, secrets...)
+	for _, f := range all {
+		names[f.Name] = true
+	}
+
+	// flags are grouped by their first segment, unless it's a flag itself
+	sections := map[string][]*flag.Flag{}
+	for _, f := range all {
+		section, _, found := strings.Cut(f.Name, "-")
+		if !found || names[section] {
+			section = ""
+		}
+		sections[section] = append(sections[section], f)
+	}
+
+	keys := make([]string, 0, len(sections))
+	for k := range sections {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b := &strings.Builder{}
+	for _, section := range keys {
+		indent := ""
+		if section != "" {
+			fmt.Fprintf(b, "%s:\n", section)
+			indent = "  "
+		}
+
+		flags := sections[section]
+		sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+
+		for _, f := range flags {
+			key := f.Name
+			if section != "" {
+				key = strings.TrimPrefix(f.Name, section+"-")
+			}
+
+			fmt.Fprintf(b, "%s# %s\n", indent, f.Usage)
+			if isSecret[f.Name] {
+				fmt.Fprintf(b, "%s# %s: \"\" # secret, not exported\n", indent, key)
+				continue
+			}
+			fmt.Fprintf(b, "%s%s: %s\n", indent, key, strconv.Quote(f.Value.String()))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
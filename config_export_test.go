@@ -0,0 +1,72 @@
+package goservice
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newExportTestService(t *testing.T) *service {
+	s := newFlagTestService()
+	s.name = "demo"
+	s.cmdLine.Int("gin-port", 3000, "gin server port")
+	s.cmdLine.String("gin-mode", "", "gin mode")
+	s.cmdLine.String("log-level", "info", "log level")
+	s.cmdLine.String("db-password", "", "database password")
+	s.cmdLine.String("db-dsn", "", "database dsn")
+	s.cmdLine.String("db", "", "not a section")
+
+	t.Setenv("GIN_PORT", "8080")
+	t.Setenv("DB_PASSWORD", "hunter2")
+	assert.Nil(t, s.applyEnv(), "must be nil")
+	return s
+}
+
+func TestExportConfigEnv(t *testing.T) {
+	s := newExportTestService(t)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, s.ExportConfig(buf, ExportFormatEnv), "must be nil")
+	out := buf.String()
+
+	assert.Contains(t, out, "## gin server port (-gin-port)\nGIN_PORT=8080\n", "env values should be exported")
+	assert.Contains(t, out, "#LOG_LEVEL=\"info\"\n", "defaults should be commented out")
+	assert.Contains(t, out, "## database password (-db-password)\n#DB_PASSWORD=\n", "should be equal")
+	assert.NotContains(t, out, "hunter2", "secrets should not be exported")
+}
+
+func TestExportConfigYAML(t *testing.T) {
+	s := newExportTestService(t)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, s.ExportConfig(buf, ExportFormatYAML), "must be nil")
+	out := buf.String()
+
+	assert.Contains(t, out, "gin:\n  # gin mode\n  mode: \"\"\n  # gin server port\n  port: \"8080\"\n", "should be equal")
+	assert.Contains(t, out, "db-dsn: \"\"", "a flag named like the section should keep flags flat")
+	assert.Contains(t, out, "# db-password: \"\" # secret, not exported", "should be equal")
+	assert.NotContains(t, out, "hunter2", "secrets should not be exported")
+
+	// the output is a valid config file
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, os.WriteFile(path, buf.Bytes(), 0o600), "must be nil")
+	values, err := readConfigFile(path)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "8080", values["gin-port"], "should be equal")
+	assert.Equal(t, "info", values["log-level"], "should be equal")
+}
+
+func TestExportConfigK8s(t *testing.T) {
+	s := newExportTestService(t)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, s.ExportConfig(buf, ExportFormatK8s), "must be nil")
+	assert.Contains(t, buf.String(), "name: demo-config", "should be equal")
+	assert.Contains(t, buf.String(), "GIN_PORT: \"8080\"", "should be equal")
+	assert.NotContains(t, buf.String(), "hunter2", "secrets should not be exported")
+
+	assert.NotNil(t, s.ExportConfig(buf, "toml"), "should be an error")
+}
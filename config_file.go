@@ -0,0 +1,205 @@
+package goservice
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// max suggestions of an unknown config key
+const maxFlagSuggestions = 3
+
+// applyConfigFile sets flags from the YAML or JSON file of the config flag.
+// Nested sections are joined with "-", e.g. gin: {port: 8080} => gin-port.
+// Values from env and command line win. Unknown keys are logged, or are an
+// error with config-strict.
+func (s *service) applyConfigFile() error {
+	if s.configFile == "" {
+		return nil
+	}
+
+	values, err := readConfigFile(s.configFile)
+	if err != nil {
+		return err
+	}
+
+	var errs, unknown []error
+	for _, name := range sortedKeys(values) {
+		if s.cmdLine.Lookup(name) == nil {
+			unknown = append(unknown, s.unknownConfigKey(name))
+			continue
+		}
+
+		if _, err := s.setFlag(name, values[name], SourceConfigFile); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q of %s in config file %s: %v", s.maskFlagValue(s.cmdLine.Lookup(name), values[name]), name, s.configFile, err))
+		}
+	}
+
+	if s.configStrict {
+		errs = append(errs, unknown...)
+	} else {
+		for _, err := range unknown {
+			s.logger.Warn(err.Error())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *service) unknownConfigKey(name string) error {
+	var names []string
+	s.cmdLine.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+
+	if similar := similarNames(name, names); len(similar) > 0 {
+		return fmt.Errorf("unknown key %s in config file %s, did you mean %s?", name, s.configFile, strings.Join(similar, ", "))
+	}
+	return fmt.Errorf("unknown key %s in config file %s", name, s.configFile)
+}
+
+// readConfigFile returns the flag values of a config file,
+// .json files are JSON, others are YAML
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flattenConfig("", doc, values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flattenConfig joins nested keys with "-", lists become comma separated values
+func flattenConfig(prefix string, doc map[string]interface{}, values map[string]string) error {
+	for key, value := range doc {
+		name := key
+		if prefix != "" {
+			name = prefix + "-" + key
+		}
+
+		if section, ok := value.(map[string]interface{}); ok {
+			if err := flattenConfig(name, section, values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, err := configValue(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		values[name] = s
+	}
+	return nil
+}
+
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// similarNames returns the names closest to name by edit distance
+func similarNames(name string, names []string) []string {
+	maxDistance := max(2, len(name)/3)
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, n := range names {
+		if d := editDistance(name, n); d <= maxDistance {
+			candidates = append(candidates, candidate{name: n, distance: d})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var result []string
+	for i := 0; i < len(candidates) && i < maxFlagSuggestions; i++ {
+		result = append(result, candidates[i].name)
+	}
+	return result
+}
+
+// editDistance is the Levenshtein distance of a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
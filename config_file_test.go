@@ -0,0 +1,97 @@
+package goservice
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o600), "must be nil")
+	return path
+}
+
+func newConfigTestService(path string, args ...string) *service {
+	logger.InitServLogger(false)
+
+	s := newFlagTestService()
+	s.logger = logger.GetCurrent().GetLogger("service")
+	s.cmdLine.StringVar(&s.configFile, "config", "", "")
+	s.cmdLine.BoolVar(&s.configStrict, "config-strict", false, "")
+	s.cmdLine.Int("gin-port", 3000, "")
+	s.cmdLine.Duration("gin-shutdown-timeout", 15*time.Second, "")
+	s.cmdLine.String("gin-cors-allow-origins", "*", "")
+	s.cmdLine.Bool("gin-cors-enabled", false, "")
+	s.cmdLine.String("log-level", "info", "")
+
+	_ = s.cmdLine.FlagSet.Parse(append([]string{"-config=" + path}, args...))
+	s.recordCLIFlags()
+	return s
+}
+
+func flagValue(s *service, name string) string {
+	return s.cmdLine.Lookup(name).Value.String()
+}
+
+func TestApplyConfigFileYAML(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+gin:
+  port: 8080
+  shutdown-timeout: 30s
+  cors:
+    enabled: true
+    allow-origins: [https://a.com, https://b.com]
+log-level: debug
+`)
+
+	s := newConfigTestService(path)
+	assert.Nil(t, s.applyConfigFile(), "must be nil")
+
+	assert.Equal(t, "8080", flagValue(s, "gin-port"), "should be equal")
+	assert.Equal(t, "30s", flagValue(s, "gin-shutdown-timeout"), "should be equal")
+	assert.Equal(t, "true", flagValue(s, "gin-cors-enabled"), "should be equal")
+	assert.Equal(t, "https://a.com,https://b.com", flagValue(s, "gin-cors-allow-origins"), "lists should be comma separated")
+	assert.Equal(t, SourceConfigFile, s.flagSource("gin-port"), "should be equal")
+}
+
+func TestApplyConfigFilePrecedence(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{"gin": {"port": 8080}, "log-level": "debug", "gin-shutdown-timeout": "30s"}`)
+
+	t.Setenv("LOG_LEVEL", "warn")
+	s := newConfigTestService(path, "-gin-port=9090")
+	assert.Nil(t, s.applyEnv(), "must be nil")
+	assert.Nil(t, s.applyConfigFile(), "must be nil")
+
+	assert.Equal(t, "9090", flagValue(s, "gin-port"), "command line should win")
+	assert.Equal(t, "warn", flagValue(s, "log-level"), "env should win")
+	assert.Equal(t, "30s", flagValue(s, "gin-shutdown-timeout"), "flat keys should be allowed")
+}
+
+func TestApplyConfigFileUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "gin:\n  prot: 8080\n")
+
+	s := newConfigTestService(path)
+	assert.Nil(t, s.applyConfigFile(), "unknown keys should only be logged")
+
+	s = newConfigTestService(path, "-config-strict")
+	err := s.applyConfigFile()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "unknown key gin-prot", "should be equal")
+	assert.Contains(t, err.Error(), "did you mean gin-port?", "should suggest the flag")
+}
+
+func TestApplyConfigFileInvalid(t *testing.T) {
+	s := newConfigTestService(writeConfigFile(t, "config.yaml", "gin:\n  port: abc\n"))
+	assert.NotNil(t, s.applyConfigFile(), "should be an error")
+
+	s = newConfigTestService(writeConfigFile(t, "config.json", "{gin"))
+	assert.NotNil(t, s.applyConfigFile(), "should be an error")
+
+	s = newConfigTestService(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.NotNil(t, s.applyConfigFile(), "should be an error")
+}
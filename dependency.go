@@ -0,0 +1,160 @@
+package goservice
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// prefix of the built-in otel plugin, the other init components depend on it
+// so they can install instrumentation when they run
+const otelPrefix = "otel"
+
+// initLevels sorts the init components by their dependencies.
+// Components of a level only depend on components of the previous levels,
+// so they can run concurrently. Each level keeps the registration order.
+func (s *service) initLevels() ([][]string, error) {
+	deps := make(map[string][]string, len(s.initPrefixes))
+	for _, prefix := range s.initPrefixes {
+		deps[prefix] = s.dependencies(prefix)
+
+		for _, dep := range deps[prefix] {
+			if _, ok := s.initServices[dep]; !ok {
+				return nil, fmt.Errorf("%s depends on unknown component %s", prefix, dep)
+			}
+		}
+	}
+
+	var levels [][]string
+	done := make(map[string]bool, len(s.initPrefixes))
+
+	for len(done) < len(s.initPrefixes) {
+		var level []string
+		for _, prefix := range s.initPrefixes {
+			if done[prefix] {
+				continue
+			}
+
+			ready := true
+			for _, dep := range deps[prefix] {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				level = append(level, prefix)
+			}
+		}
+
+		if len(level) == 0 {
+			return nil, fmt.Errorf("dependency cycle: %s", strings.Join(findCycle(s.initPrefixes, deps, done), " -> "))
+		}
+
+		for _, prefix := range level {
+			done[prefix] = true
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, nil
+}
+
+// dependencies returns the declared dependencies of an init component
+// plus the otel plugin
+func (s *service) dependencies(prefix string) []string {
+	var deps []string
+	if _, ok := s.initServices[otelPrefix]; ok && prefix != otelPrefix {
+		deps = append(deps, otelPrefix)
+	}
+
+	if d, ok := s.initServices[prefix].(Dependent); ok {
+		for _, dep := range d.DependsOn() {
+			if dep != otelPrefix {
+				deps = append(deps, dep)
+			}
+		}
+	}
+
+	return deps
+}
+
+// findCycle returns a dependency cycle among the components which are not done,
+// starting and ending with the same prefix
+func findCycle(prefixes []string, deps map[string][]string, done map[string]bool) []string {
+	visiting := map[string]bool{}
+	visited := map[string]bool{}
+	var path []string
+
+	var visit func(prefix string) []string
+	visit = func(prefix string) []string {
+		if visiting[prefix] {
+			for i, p := range path {
+				if p == prefix {
+					return append(append([]string{}, path[i:]...), prefix)
+				}
+			}
+		}
+		if visited[prefix] || done[prefix] {
+			return nil
+		}
+
+		visiting[prefix] = true
+		path = append(path, prefix)
+		for _, dep := range deps[prefix] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		visiting[prefix] = false
+		visited[prefix] = true
+
+		return nil
+	}
+
+	for _, prefix := range prefixes {
+		if cycle := visit(prefix); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// runInitLevels runs the init components level by level,
+// a level runs concurrently and all of it must succeed before the next one
+func (s *service) runInitLevels(levels [][]string) error {
+	for _, level := range levels {
+		errs := make([]error, len(level))
+		wg := sync.WaitGroup{}
+
+		for i, prefix := range level {
+			wg.Add(1)
+			go func(i int, r Runnable) {
+				defer wg.Done()
+				errs[i] = r.Run()
+			}(i, s.initServices[prefix])
+		}
+		wg.Wait()
+
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatLevels formats the init order, ex: otel -> [mysql redis] -> warmer
+func formatLevels(levels [][]string) string {
+	parts := make([]string, 0, len(levels))
+	for _, level := range levels {
+		if len(level) == 1 {
+			parts = append(parts, level[0])
+		} else {
+			parts = append(parts, "["+strings.Join(level, " ")+"]")
+		}
+	}
+	return strings.Join(parts, " -> ")
+}
@@ -0,0 +1,94 @@
+package goservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type dependentRunnable struct {
+	fakeRunnable
+	deps     []string
+	runDelay time.Duration
+	started  *stopRecorder
+}
+
+func (d *dependentRunnable) DependsOn() []string { return d.deps }
+
+func (d *dependentRunnable) Run() error {
+	time.Sleep(d.runDelay)
+	d.started.add(d.name)
+	return nil
+}
+
+func newDependent(prefix string, started, stopped *stopRecorder, deps ...string) *dependentRunnable {
+	return &dependentRunnable{
+		fakeRunnable: fakeRunnable{name: prefix, prefix: prefix, recorder: stopped},
+		deps:         deps,
+		started:      started,
+	}
+}
+
+func TestInitLevels(t *testing.T) {
+	started, stopped := &stopRecorder{}, &stopRecorder{}
+
+	s := newTestService(
+		WithInitRunnable(newDependent("warmer", started, stopped, "db", "cache")),
+		WithInitRunnable(newDependent(otelPrefix, started, stopped)),
+		WithInitRunnable(newDependent("db", started, stopped)),
+		WithInitRunnable(newDependent("cache", started, stopped)),
+	)
+
+	levels, err := s.initLevels()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, [][]string{{otelPrefix}, {"db", "cache"}, {"warmer"}}, levels, "should be equal")
+	assert.Equal(t, "otel -> [db cache] -> warmer", formatLevels(levels), "should be equal")
+
+	assert.Nil(t, s.runInitLevels(levels), "must be nil")
+	assert.Equal(t, otelPrefix, started.names[0], "should be equal")
+	assert.Equal(t, "warmer", started.names[3], "should be equal")
+
+	for _, level := range levels {
+		s.initOrder = append(s.initOrder, level...)
+	}
+	assert.Nil(t, s.Shutdown(context.Background()), "must be nil")
+	assert.Equal(t, []string{"warmer", "cache", "db", otelPrefix}, stopped.names, "should stop in reverse start order")
+}
+
+func TestInitLevelsConcurrent(t *testing.T) {
+	started, stopped := &stopRecorder{}, &stopRecorder{}
+
+	a := newDependent("a", started, stopped)
+	a.runDelay = 200 * time.Millisecond
+	b := newDependent("b", started, stopped)
+	b.runDelay = 200 * time.Millisecond
+
+	s := newTestService(WithInitRunnable(a), WithInitRunnable(b))
+	levels, err := s.initLevels()
+	assert.Nil(t, err, "must be nil")
+
+	start := time.Now()
+	assert.Nil(t, s.runInitLevels(levels), "must be nil")
+	assert.Less(t, time.Since(start), 350*time.Millisecond, "independent components should run concurrently")
+}
+
+func TestInitLevelsErrors(t *testing.T) {
+	started, stopped := &stopRecorder{}, &stopRecorder{}
+
+	s := newTestService(
+		WithInitRunnable(newDependent("a", started, stopped, "b")),
+		WithInitRunnable(newDependent("b", started, stopped, "c")),
+		WithInitRunnable(newDependent("c", started, stopped, "a")),
+		WithInitRunnable(newDependent("d", started, stopped)),
+	)
+	_, err := s.initLevels()
+	assert.NotNil(t, err, "should be an error")
+	assert.Equal(t, "dependency cycle: a -> b -> c -> a", err.Error(), "should be equal")
+
+	s = newTestService(WithInitRunnable(newDependent("a", started, stopped, "missing")))
+	_, err = s.initLevels()
+	assert.NotNil(t, err, "should be an error")
+	assert.Equal(t, "a depends on unknown component missing", err.Error(), "should be equal")
+}
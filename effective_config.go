@@ -0,0 +1,145 @@
+package goservice
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// ConfigSource tells where the final value of a flag came from
+type ConfigSource string
+
+// Sources in precedence order, a value from a later source overrides an earlier one
+const (
+	SourceDefault    ConfigSource = "default"
+	SourceProfile    ConfigSource = "profile"
+	SourceConfigFile ConfigSource = "config-file"
+	SourceRemote     ConfigSource = "remote"
+	SourceEnv        ConfigSource = "env"
+	SourceCLI        ConfigSource = "cli"
+)
+
+const maskedValue = "******"
+
+var sourcePrecedence = map[ConfigSource]int{
+	SourceDefault:    0,
+	SourceProfile:    1,
+	SourceConfigFile: 2,
+	SourceRemote:     3,
+	SourceEnv:        4,
+	SourceCLI:        5,
+}
+
+// ConfigEntry is the effective value of a flag with its provenance
+type ConfigEntry struct {
+	Name   string       `json:"name"`
+	Value  string       `json:"value"`
+	Source ConfigSource `json:"source"`
+	Plugin string       `json:"plugin"`
+}
+
+// recordCLIFlags marks flags set while parsing the command line,
+// an alias marks the flag it stands for as well
+func (s *service) recordCLIFlags() {
+	s.cmdLine.Visit(func(f *flag.Flag) {
+		s.flagSources[f.Name] = SourceCLI
+		s.flagSources[s.canonicalFlagName(f.Name)] = SourceCLI
+	})
+}
+
+// setFlag sets value to a flag unless its current value came from a source
+// with higher precedence. It returns whether the value has been applied.
+// An alias is set through the flag it stands for and keeps its own source
+// only for the deprecation warning.
+func (s *service) setFlag(name, value string, source ConfigSource) (bool, error) {
+	canonical := s.canonicalFlagName(name)
+	if current, ok := s.flagSources[canonical]; ok && sourcePrecedence[current] > sourcePrecedence[source] {
+		return false, nil
+	}
+
+	if err := s.cmdLine.Set(canonical, value); err != nil {
+		return false, err
+	}
+
+	s.flagSources[canonical] = source
+	s.flagSources[name] = source
+	return true, nil
+}
+
+func (s *service) flagSource(name string) ConfigSource {
+	if source, ok := s.flagSources[name]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// isSecretFlag tells whether a flag holds a credential: registered
+// with the Secret type or named like the redacted log fields
+func isSecretFlag(f *flag.Flag) bool {
+	return isSecretValue(f) || logger.IsSensitiveKey(f.Name)
+}
+
+// isSecretFlag also tells whether a flag value was resolved from a secret reference
+func (s *service) isSecretFlag(f *flag.Flag) bool {
+	return isSecretFlag(f) || s.resolvedFlags[f.Name]
+}
+
+// maskFlagValue masks a value of a secret flag and the passwords of connection strings
+func (s *service) maskFlagValue(f *flag.Flag, value string) string {
+	if value != "" && s.isSecretFlag(f) {
+		return maskedValue
+	}
+	return logger.RedactCredentials(value)
+}
+
+// EffectiveConfig returns the final value of every flag, where it came from
+// and which plugin registered it. Secret values and the passwords of
+// connection strings are masked, aliases are reported by their flag.
+func (s *service) EffectiveConfig() []ConfigEntry {
+	var entries []ConfigEntry
+
+	s.cmdLine.VisitAll(func(f *flag.Flag) {
+		if _, ok := s.flagAliases[f.Name]; ok {
+			return
+		}
+
+		entries = append(entries, ConfigEntry{
+			Name:   f.Name,
+			Value:  s.maskFlagValue(f, f.Value.String()),
+			Source: s.flagSource(f.Name),
+			Plugin: s.flagOwners[f.Name],
+		})
+	})
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	return entries
+}
+
+// dumpEffectiveConfig writes effective config as a table
+func (s *service) dumpEffectiveConfig(w io.Writer) {
+	_ = writeConfigTable(w, s.EffectiveConfig())
+}
+
+func writeConfigTable(w io.Writer, entries []ConfigEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tVALUE\tSOURCE\tPLUGIN")
+
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Name, e.Value, e.Source, e.Plugin)
+	}
+
+	return tw.Flush()
+}
+
+func (s *service) debugConfigHandler(engine *gin.Engine) {
+	engine.GET("/debug/config", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.EffectiveConfig())
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	goservice "github.com/taimaifika/go-sdk"
+	"github.com/taimaifika/go-sdk/plugin/storage/sdkmongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Run with MONGO_URI=mongodb://localhost:27017/demo
+func main() {
+	service := goservice.New(
+		goservice.WithName("mongo-demo"),
+		goservice.WithVersion("1.0.0"),
+		goservice.WithInitRunnable(sdkmongo.NewMongoDB("mongo", "")),
+	)
+
+	if err := service.Init(); err != nil {
+		panic(err)
+	}
+
+	service.HTTPServer().AddHandler(func(engine *gin.Engine) {
+		engine.GET("/visits", func(c *gin.Context) {
+			db := goservice.MustGet[*mongo.Database](service, "mongo")
+
+			var counter struct {
+				Visits int64 `bson:"visits"`
+			}
+			err := db.Collection("counters").FindOneAndUpdate(c.Request.Context(),
+				bson.M{"_id": "visits"},
+				bson.M{"$inc": bson.M{"visits": 1}},
+				options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+			).Decode(&counter)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"visits": counter.Visits})
+		})
+	})
+
+	_ = service.Start()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v7"
+	goservice "github.com/taimaifika/go-sdk"
+	"github.com/taimaifika/go-sdk/plugin/storage/sdkredis"
+)
+
+// Run with -redis-go-redis-uri=redis://localhost:6379/0
+// or -redis-go-redis-mode=cluster -redis-go-redis-addrs=node1:6379,node2:6379
+func main() {
+	service := goservice.New(
+		goservice.WithName("redis-demo"),
+		goservice.WithVersion("1.0.0"),
+		goservice.WithInitRunnable(sdkredis.NewRedisDB("redis", "redis")),
+	)
+
+	if err := service.Init(); err != nil {
+		panic(err)
+	}
+
+	service.HTTPServer().AddHandler(func(engine *gin.Engine) {
+		engine.GET("/visits", func(c *gin.Context) {
+			client := goservice.MustGet[redis.UniversalClient](service, "redis")
+
+			visits, err := client.Incr("visits").Result()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"visits": visits})
+		})
+	})
+
+	_ = service.Start()
+}
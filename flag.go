@@ -1,13 +1,33 @@
 package goservice
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 )
 
+const (
+	serviceFlagOwner = "service"
+	appFlagOwner     = "application"
+)
+
+// Service-level flag names, plugins must not register them
+var reservedFlagNames = []string{"config", "config-strict", "config-file", "profile", "env-file"}
+
+func isReservedFlagName(name string) bool {
+	for _, n := range reservedFlagNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 // isZeroValue guesses whether the string represents the zero
 // value for a flag. It is not accurate but in practice works OK.
 func isZeroValue(f *flag.Flag, value string) bool {
@@ -44,22 +64,32 @@ func getEnvName(name string) string {
 
 type AppFlagSet struct {
 	*flag.FlagSet
+	// prefix of env var names, ex: MYAPP => MYAPP_GIN_PORT
+	envPrefix string
+}
+
+// envName returns the env var bound to a flag
+func (f *AppFlagSet) envName(name string) string {
+	if f.envPrefix == "" {
+		return getEnvName(name)
+	}
+	return getEnvName(f.envPrefix) + "_" + getEnvName(name)
 }
 
 func newFlagSet(name string, fs *flag.FlagSet) *AppFlagSet {
-	fSet := &AppFlagSet{fs}
+	fSet := &AppFlagSet{FlagSet: fs}
 	fSet.Usage = flagCustomUsage(name, fSet)
 	return fSet
 }
 
-func (f *AppFlagSet) GetSampleEnvs() {
-	f.VisitAll(func(f *flag.Flag) {
+func (fs *AppFlagSet) GetSampleEnvs() {
+	fs.VisitAll(func(f *flag.Flag) {
 		if f.Name == "outenv" {
 			return
 		}
 
 		s := fmt.Sprintf("## %s (-%s)\n", f.Usage, f.Name)
-		s += fmt.Sprintf("#%s=", getEnvName(f.Name))
+		s += fmt.Sprintf("#%s=", fs.envName(f.Name))
 
 		if !isZeroValue(f, f.DefValue) {
 			t := fmt.Sprintf("%T", f.Value)
@@ -74,10 +104,220 @@ func (f *AppFlagSet) GetSampleEnvs() {
 	})
 }
 
+// CommandLineMu guards flag.CommandLine: third-party components without InitFlagSet
+// register into it, it's swapped with the flag set of the service meanwhile.
+// Every in-repo plugin implements FlagSetInitializer and never goes through the swap.
+// Code reading or swapping flag.CommandLine while services are created must hold it.
+var CommandLineMu sync.Mutex
+
+// recordAppFlags adds the flags registered into flag.CommandLine, or the flag set
+// of WithAppFlagSet, before the service was created (by the application itself,
+// cobra, etc.), owned by the application
+func (s *service) recordAppFlags() {
+	appFlags := s.appFlags
+	if appFlags == nil {
+		CommandLineMu.Lock()
+		defer CommandLineMu.Unlock()
+		appFlags = flag.CommandLine
+	}
+
+	if appFlags != s.cmdLine.FlagSet {
+		appFlags.VisitAll(func(f *flag.Flag) {
+			if s.cmdLine.Lookup(f.Name) == nil {
+				s.cmdLine.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+	}
+
+	s.cmdLine.VisitAll(func(f *flag.Flag) { s.flagOwners[f.Name] = appFlagOwner })
+}
+
+// registerFlags records the flags of r, registered by InitFlagSet
+// or, for third-party runnables only, by InitFlags into the swapped flag.CommandLine
+func (s *service) registerFlags(r Runnable) {
+	if aliaser, ok := r.(FlagAliaser); ok {
+		for alias, name := range aliaser.FlagAliases() {
+			s.flagAliases[alias] = name
+			s.deprecatedFlags[alias] = "use " + name
+		}
+	}
+
+	if initializer, ok := r.(FlagSetInitializer); ok {
+		s.recordFlagOwner(r.Name(), func() { initializer.InitFlagSet(s.cmdLine.FlagSet) })
+		return
+	}
+
+	s.recordFlagOwner(r.Name(), func() {
+		CommandLineMu.Lock()
+		defer CommandLineMu.Unlock()
+
+		commandLine := flag.CommandLine
+		flag.CommandLine = s.cmdLine.FlagSet
+		defer func() { flag.CommandLine = commandLine }()
+
+		r.InitFlags()
+	})
+}
+
+// recordFlagOwner runs a flag registration function and marks every newly
+// created flag as owned by owner. A duplicated or reserved flag name becomes
+// an Init error naming both owners instead of the flag package panic.
+func (s *service) recordFlagOwner(owner string, initFlags func()) {
+	before := map[string]bool{}
+	s.cmdLine.VisitAll(func(f *flag.Flag) { before[f.Name] = true })
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				s.initErr = errors.Join(s.initErr, s.flagRedefinedError(owner, r))
+			}
+		}()
+
+		initFlags()
+	}()
+
+	var names []string
+	s.cmdLine.VisitAll(func(f *flag.Flag) {
+		if !before[f.Name] {
+			s.flagOwners[f.Name] = owner
+			names = append(names, f.Name)
+		}
+	})
+
+	if owner == serviceFlagOwner {
+		return
+	}
+
+	for _, name := range names {
+		if isReservedFlagName(name) {
+			s.initErr = errors.Join(s.initErr, fmt.Errorf("flag %q registered by %s is reserved by the service", name, owner))
+		}
+	}
+}
+
+func (s *service) flagRedefinedError(owner string, r interface{}) error {
+	msg := fmt.Sprintf("%v", r)
+
+	idx := strings.Index(msg, "flag redefined: ")
+	if idx < 0 {
+		panic(r)
+	}
+
+	name := msg[idx+len("flag redefined: "):]
+	if isReservedFlagName(name) {
+		return fmt.Errorf("flag %q registered by %s is reserved by the service", name, owner)
+	}
+
+	return fmt.Errorf("flag %q registered by %s is already registered by %s", name, owner, s.flagOwners[name])
+}
+
+// FlagSet returns the flags of the service, e.g. for cobra
+func (s *service) FlagSet() *flag.FlagSet {
+	return s.cmdLine.FlagSet
+}
+
+// FlagOwners returns which plugin registered each flag
+func (s *service) FlagOwners() map[string]string {
+	owners := make(map[string]string, len(s.flagOwners))
+	for name, owner := range s.flagOwners {
+		owners[name] = owner
+	}
+	return owners
+}
+
 func (f *AppFlagSet) Parse(args []string) {
 	_ = f.FlagSet.Parse(args)
 }
 
+// knownArgs returns the args of the flags defined in fs with their values,
+// dropping unknown flags and positional args. Parsing stops at "--".
+func knownArgs(fs *flag.FlagSet, args []string) []string {
+	var known []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		name, _, hasValue := strings.Cut(name, "=")
+		f := fs.Lookup(name)
+		if f == nil {
+			continue
+		}
+
+		known = append(known, arg)
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); hasValue || (ok && bf.IsBoolFlag()) {
+			continue
+		}
+		if i+1 < len(args) {
+			i++
+			known = append(known, args[i])
+		}
+	}
+	return known
+}
+
+// applyFlagValues sets the flags of WithFlagValues with the cli source
+func (s *service) applyFlagValues() error {
+	names := make([]string, 0, len(s.flagValues))
+	for name := range s.flagValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if s.cmdLine.Lookup(name) == nil {
+			errs = append(errs, fmt.Errorf("unknown flag -%s", name))
+			continue
+		}
+		if _, err := s.setFlag(name, s.flagValues[name], SourceCLI); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for flag -%s: %v", s.maskFlagValue(s.cmdLine.Lookup(name), s.flagValues[name]), name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// canonicalFlagName returns the flag an alias stands for, name otherwise
+func (s *service) canonicalFlagName(name string) string {
+	if canonical, ok := s.flagAliases[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+// applyEnv sets flags which are not set on the command line from their env vars.
+// The env var of an alias is ignored when the one of its flag is set.
+func (s *service) applyEnv() error {
+	var errs []error
+
+	s.cmdLine.VisitAll(func(f *flag.Flag) {
+		envName := s.cmdLine.envName(f.Name)
+
+		value, ok := os.LookupEnv(envName)
+		if !ok {
+			return
+		}
+
+		if canonical := s.canonicalFlagName(f.Name); canonical != f.Name {
+			if _, ok := os.LookupEnv(s.cmdLine.envName(canonical)); ok {
+				return
+			}
+		}
+
+		if _, err := s.setFlag(f.Name, value, SourceEnv); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q of env %s for flag -%s: %v", s.maskFlagValue(f, value), envName, f.Name, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
 // inspect from PrintDefaults
 func flagCustomUsage(appname string, fSet *AppFlagSet) func() {
 	return func() {
@@ -108,7 +348,7 @@ func flagCustomUsage(appname string, fSet *AppFlagSet) func() {
 					s += fmt.Sprintf(" (default %v)", f.DefValue)
 				}
 			}
-			s += fmt.Sprintf(" [$%s]", getEnvName(f.Name))
+			s += fmt.Sprintf(" [$%s]", fSet.envName(f.Name))
 			_, _ = fmt.Fprint(os.Stderr, s, "\n")
 		})
 	}
@@ -0,0 +1,122 @@
+package goservice
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// registered for every service created afterwards, copied by goservice.New()
+	// flag name => deprecation message
+	globalDeprecatedFlags = map[string]string{}
+	globalRequiredFlags   = map[string]bool{}
+)
+
+// Secret is a flag value holding a credential. Its value is masked in
+// config reports and rendered into Secret manifests instead of ConfigMaps.
+type Secret string
+
+func (s *Secret) String() string {
+	if s == nil {
+		return ""
+	}
+	return string(*s)
+}
+
+func (s *Secret) Set(value string) error {
+	*s = Secret(value)
+	return nil
+}
+
+// SecretVar defines a secret string flag in the default flag set
+func SecretVar(p *string, name, value, usage string) {
+	SecretFlagVar(flag.CommandLine, p, name, value, usage)
+}
+
+// SecretFlagVar defines a secret string flag in fs, see FlagSetInitializer
+func SecretFlagVar(fs *flag.FlagSet, p *string, name, value, usage string) {
+	*p = value
+	fs.Var((*Secret)(p), name, usage)
+}
+
+// MarkFlagRequired makes Init fail when the flag has an empty value,
+// for every service created afterwards. See WithRequiredFlag for one service.
+func MarkFlagRequired(name string) {
+	globalRequiredFlags[name] = true
+}
+
+// MarkFlagDeprecated marks a flag as deprecated for every service created afterwards,
+// it is omitted from generated manifests and a warning is logged when it is set.
+// See WithDeprecatedFlag for one service.
+func MarkFlagDeprecated(name, message string) {
+	globalDeprecatedFlags[name] = message
+}
+
+// WithRequiredFlag makes Init fail when the flag has an empty value
+func WithRequiredFlag(name string) Option {
+	return func(s *service) { s.requiredFlags[name] = true }
+}
+
+// WithDeprecatedFlag marks a flag as deprecated, it is omitted from
+// generated manifests and a warning is logged when it is set
+func WithDeprecatedFlag(name, message string) Option {
+	return func(s *service) { s.deprecatedFlags[name] = message }
+}
+
+// initFlagMeta copies the flag metadata registered for every service,
+// the options of the service add to it
+func (s *service) initFlagMeta() {
+	s.requiredFlags = make(map[string]bool, len(globalRequiredFlags))
+	for name := range globalRequiredFlags {
+		s.requiredFlags[name] = true
+	}
+
+	s.deprecatedFlags = make(map[string]string, len(globalDeprecatedFlags))
+	for name, message := range globalDeprecatedFlags {
+		s.deprecatedFlags[name] = message
+	}
+
+	s.profileDefaults = map[string]map[string]string{}
+	for name, values := range globalProfileDefaults {
+		addProfileDefault(s.profileDefaults, name, values)
+	}
+}
+
+func isSecretValue(f *flag.Flag) bool {
+	_, ok := f.Value.(*Secret)
+	return ok
+}
+
+func (s *service) isRequiredFlag(name string) bool {
+	return s.requiredFlags[name]
+}
+
+func (s *service) isDeprecatedFlag(name string) bool {
+	_, ok := s.deprecatedFlags[name]
+	return ok
+}
+
+// checkFlagMeta returns an error listing required flags without value
+// and warns about deprecated flags which are used
+func (s *service) checkFlagMeta() error {
+	var missing []string
+
+	s.cmdLine.VisitAll(func(f *flag.Flag) {
+		if s.isRequiredFlag(f.Name) && f.Value.String() == "" {
+			missing = append(missing, f.Name)
+		}
+
+		if msg, ok := s.deprecatedFlags[f.Name]; ok && s.flagSource(f.Name) != SourceDefault {
+			s.logger.Warnf("flag %s is deprecated: %s", f.Name, msg)
+		}
+	})
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("required flags are not set: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
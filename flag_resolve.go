@@ -0,0 +1,51 @@
+package goservice
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+// resolveFlagValues substitutes the flag values referencing secrets with the init
+// components implementing FlagValueResolver. Errors name the flag, never its value.
+func (s *service) resolveFlagValues() error {
+	var resolvers []FlagValueResolver
+	for _, prefix := range s.initPrefixes {
+		if r, ok := s.initServices[prefix].(FlagValueResolver); ok {
+			resolvers = append(resolvers, r)
+		}
+	}
+
+	if len(resolvers) == 0 {
+		return nil
+	}
+
+	var errs []error
+	s.cmdLine.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if value == "" {
+			return
+		}
+
+		for _, r := range resolvers {
+			resolved, ok, err := r.ResolveFlagValue(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("cannot resolve flag -%s: %w", f.Name, err))
+				return
+			}
+			if !ok {
+				continue
+			}
+
+			// the error of Set may contain the value
+			if err := s.cmdLine.Set(f.Name, resolved); err != nil {
+				errs = append(errs, fmt.Errorf("invalid resolved value of flag -%s", f.Name))
+				return
+			}
+			s.resolvedFlags[f.Name] = true
+			return
+		}
+	})
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,57 @@
+package goservice
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeResolver struct {
+	PrefixRunnable
+	secrets map[string]string
+}
+
+func (r *fakeResolver) ResolveFlagValue(value string) (string, bool, error) {
+	if !strings.HasPrefix(value, "vault://") {
+		return "", false, nil
+	}
+	if secret, ok := r.secrets[value]; ok {
+		return secret, true, nil
+	}
+	return "", true, errors.New("secret not found")
+}
+
+func newResolveTestService(r *fakeResolver, args ...string) *service {
+	s, _ := newProfileTestService(args...)
+	s.resolvedFlags = map[string]bool{}
+	s.initServices = map[string]PrefixRunnable{"secrets": r}
+	s.initPrefixes = []string{"secrets"}
+	return s
+}
+
+func TestResolveFlagValues(t *testing.T) {
+	r := &fakeResolver{secrets: map[string]string{"vault://secret/data/app#db_password": "p@ss"}}
+	s := newResolveTestService(r)
+	password := s.cmdLine.String("db-dsn-password", "", "")
+	_, _ = s.setFlag("db-dsn-password", "vault://secret/data/app#db_password", SourceEnv)
+
+	assert.Nil(t, s.resolveFlagValues(), "must be nil")
+	assert.Equal(t, "p@ss", *password, "should be equal")
+
+	entry := findConfigEntry(s.EffectiveConfig(), "db-dsn-password")
+	assert.Equal(t, maskedValue, entry.Value, "resolved values must be masked")
+	assert.Equal(t, SourceEnv, entry.Source, "source should be kept")
+
+	// plain values are kept
+	assert.Equal(t, "trace", findConfigEntry(s.EffectiveConfig(), "log-level").Value, "should be equal")
+}
+
+func TestResolveFlagValuesError(t *testing.T) {
+	s := newResolveTestService(&fakeResolver{}, "-log-level=vault://secret/data/app#level")
+
+	err := s.resolveFlagValues()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "-log-level", "should name the flag")
+}
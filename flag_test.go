@@ -0,0 +1,296 @@
+package goservice
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFlagTestService() *service {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	s := &service{
+		cmdLine:     newFlagSet("test", fs),
+		flagSources: map[string]ConfigSource{},
+		flagOwners:  map[string]string{},
+		flagAliases: map[string]string{},
+	}
+	s.initFlagMeta()
+	return s
+}
+
+func TestFlagCollision(t *testing.T) {
+	s := newFlagTestService()
+
+	s.recordFlagOwner("mysql", func() { s.cmdLine.String("db-dsn", "", "") })
+	assert.Nil(t, s.initErr, "must be nil")
+
+	s.recordFlagOwner("postgres", func() {
+		s.cmdLine.String("pg-schema", "", "")
+		s.cmdLine.String("db-dsn", "", "")
+	})
+	assert.NotNil(t, s.initErr, "should be an error")
+	assert.Contains(t, s.initErr.Error(), `flag "db-dsn" registered by postgres is already registered by mysql`)
+
+	owners := s.FlagOwners()
+	assert.Equal(t, "mysql", owners["db-dsn"], "should be equal")
+	assert.Equal(t, "postgres", owners["pg-schema"], "should be equal")
+}
+
+func TestFlagCollisionWithApplication(t *testing.T) {
+	s := newFlagTestService()
+	s.cmdLine.Int("port", 0, "")
+	s.recordAppFlags()
+
+	s.recordFlagOwner("grpc", func() { s.cmdLine.Int("port", 0, "") })
+	assert.NotNil(t, s.initErr, "should be an error")
+	assert.Contains(t, s.initErr.Error(), "registered by grpc is already registered by application")
+}
+
+func TestReservedFlagName(t *testing.T) {
+	s := newFlagTestService()
+
+	s.recordFlagOwner(serviceFlagOwner, func() { s.cmdLine.String("profile", "", "") })
+	assert.Nil(t, s.initErr, "must be nil")
+
+	s.recordFlagOwner("fake", func() { s.cmdLine.String("env-file", "", "") })
+	assert.NotNil(t, s.initErr, "should be an error")
+	assert.Contains(t, s.initErr.Error(), `flag "env-file" registered by fake is reserved by the service`)
+
+	s.initErr = nil
+	s.recordFlagOwner("fake", func() { s.cmdLine.String("profile", "", "") })
+	assert.NotNil(t, s.initErr, "should be an error")
+	assert.Contains(t, s.initErr.Error(), `flag "profile" registered by fake is reserved by the service`)
+}
+
+func TestApplyEnv(t *testing.T) {
+	s := newFlagTestService()
+	port := s.cmdLine.Int("gin-port", 3000, "")
+	endpoint := s.cmdLine.String("otel-exporter-otlp-endpoint", "", "")
+	mode := s.cmdLine.String("gin-mode", "", "")
+	_ = s.cmdLine.FlagSet.Parse([]string{"-gin-mode=release"})
+	s.recordCLIFlags()
+
+	t.Setenv("GIN_PORT", "8080")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "collector:4317")
+	t.Setenv("GIN_MODE", "debug")
+
+	assert.Nil(t, s.applyEnv(), "must be nil")
+	assert.Equal(t, 8080, *port, "should be equal")
+	assert.Equal(t, "collector:4317", *endpoint, "should be equal")
+	assert.Equal(t, "release", *mode, "command line should win")
+	assert.Equal(t, SourceEnv, s.flagSource("gin-port"), "should be equal")
+	assert.Equal(t, SourceCLI, s.flagSource("gin-mode"), "should be equal")
+}
+
+func TestApplyEnvWithPrefix(t *testing.T) {
+	s := newFlagTestService()
+	s.cmdLine.envPrefix = "myapp"
+	port := s.cmdLine.Int("gin-port", 3000, "")
+
+	t.Setenv("GIN_PORT", "8080")
+	t.Setenv("MYAPP_GIN_PORT", "9090")
+
+	assert.Nil(t, s.applyEnv(), "must be nil")
+	assert.Equal(t, 9090, *port, "should be equal")
+}
+
+func TestApplyEnvInvalidValue(t *testing.T) {
+	s := newFlagTestService()
+	s.cmdLine.Int("gin-port", 3000, "")
+	s.cmdLine.Bool("gin-no-logger", false, "")
+
+	t.Setenv("GIN_PORT", "80a")
+	t.Setenv("GIN_NO_LOGGER", "yes")
+
+	err := s.applyEnv()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), `invalid value "80a" of env GIN_PORT for flag -gin-port`)
+	assert.Contains(t, err.Error(), `invalid value "yes" of env GIN_NO_LOGGER for flag -gin-no-logger`)
+}
+
+func TestApplyEnvInvalidSecretValue(t *testing.T) {
+	s := newFlagTestService()
+	s.cmdLine.Func("db-password", "", func(string) error { return errors.New("too short") })
+	s.cmdLine.Func("db-uri", "", func(string) error { return errors.New("unknown scheme") })
+
+	t.Setenv("DB_PASSWORD", "hunter2")
+	t.Setenv("DB_URI", "foo://app:hunter3@db:3306/app")
+
+	err := s.applyEnv()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), `invalid value "******" of env DB_PASSWORD for flag -db-password: too short`)
+	assert.Contains(t, err.Error(), `of env DB_URI for flag -db-uri: unknown scheme`)
+	assert.NotContains(t, err.Error(), "hunter2")
+	assert.NotContains(t, err.Error(), "hunter3")
+}
+
+// legacyPlugin registers its flags into flag.CommandLine
+type legacyPlugin struct {
+	name   string
+	prefix string
+	dsn    string
+}
+
+func (p *legacyPlugin) Name() string      { return p.name }
+func (p *legacyPlugin) GetPrefix() string { return p.prefix }
+func (p *legacyPlugin) Get() interface{}  { return p.dsn }
+func (p *legacyPlugin) InitFlags()        { flag.StringVar(&p.dsn, p.prefix+"-dsn", "", "") }
+func (p *legacyPlugin) Configure() error  { return nil }
+func (p *legacyPlugin) Run() error        { return nil }
+func (p *legacyPlugin) Stop() <-chan bool {
+	c := make(chan bool, 1)
+	c <- true
+	return c
+}
+
+func TestServicesInOneProcess(t *testing.T) {
+	first := New(
+		WithName("first"),
+		WithArgs([]string{"-gin-port=1234", "-db-dsn=first"}),
+		WithInitRunnable(&legacyPlugin{name: "mysql", prefix: "db"}),
+	)
+	second := New(
+		WithName("second"),
+		WithArgs([]string{}),
+		WithInitRunnable(&legacyPlugin{name: "mysql", prefix: "db"}),
+	)
+
+	assert.Nil(t, first.(*service).initErr, "must be nil")
+	assert.Nil(t, second.(*service).initErr, "must be nil")
+	assert.Equal(t, 1234, first.HTTPServer().GetConfig().Port, "should be equal")
+	assert.NotEqual(t, 1234, second.HTTPServer().GetConfig().Port, "should be the default")
+	assert.Equal(t, "first", first.MustGet("db"), "should be equal")
+	assert.Equal(t, "", second.MustGet("db"), "should be equal")
+
+	assert.Equal(t, "mysql", first.FlagOwners()["db-dsn"], "should be equal")
+	assert.NotNil(t, first.FlagSet().Lookup("db-dsn"), "should be registered into the flag set of the service")
+	assert.Nil(t, flag.CommandLine.Lookup("db-dsn"), "must be nil")
+}
+
+func TestFlagMetaPerService(t *testing.T) {
+	first := New(
+		WithName("first"),
+		WithArgs([]string{"-profile=dev"}),
+		WithProfileDefault("gin-port", map[string]string{"dev": "4001"}),
+		WithDeprecatedFlag("gin-cors-enabled", "use the gateway"),
+		WithRequiredFlag("gin-ops-token"),
+	)
+	second := New(WithName("second"), WithArgs([]string{"-profile=dev"}))
+
+	assert.Nil(t, first.(*service).initErr, "must be nil")
+	assert.Nil(t, second.(*service).initErr, "must be nil")
+	assert.Equal(t, 4001, first.HTTPServer().GetConfig().Port, "should be equal")
+	assert.NotEqual(t, 4001, second.HTTPServer().GetConfig().Port, "should be the default")
+
+	assert.True(t, first.(*service).isDeprecatedFlag("gin-cors-enabled"), "should be deprecated")
+	assert.False(t, second.(*service).isDeprecatedFlag("gin-cors-enabled"), "should not be deprecated")
+	assert.NotNil(t, first.(*service).checkFlagMeta(), "should be an error")
+	assert.Nil(t, second.(*service).checkFlagMeta(), "must be nil")
+}
+
+func TestAppFlagSet(t *testing.T) {
+	app := flag.NewFlagSet("app", flag.ContinueOnError)
+	verbose := app.Bool("verbose", false, "")
+
+	s := New(WithName("app"), WithArgs([]string{"-verbose"}), WithAppFlagSet(app))
+	assert.Nil(t, s.(*service).initErr, "must be nil")
+	assert.True(t, *verbose, "should be set")
+	assert.Equal(t, appFlagOwner, s.FlagOwners()["verbose"], "should be equal")
+}
+
+func TestParseArgs(t *testing.T) {
+	s := New(WithName("strict"), WithArgs([]string{"-no-such-flag"}))
+	assert.NotNil(t, s.Init(), "should be an error")
+
+	s = New(
+		WithName("embedded"),
+		WithArgs([]string{"serve", "--verbose", "--gin-port", "4321", "-db-dsn=embedded"}),
+		WithUnknownFlagsIgnored(),
+		WithInitRunnable(&legacyPlugin{name: "mysql", prefix: "db"}),
+	)
+	assert.Nil(t, s.(*service).initErr, "must be nil")
+	assert.Equal(t, 4321, s.HTTPServer().GetConfig().Port, "should be equal")
+	assert.Equal(t, "embedded", s.MustGet("db"), "should be equal")
+}
+
+func TestDeprecatedFlagAliases(t *testing.T) {
+	s := New(WithName("legacy"), WithArgs([]string{"-ginPort=4322", "-ginaddr=127.0.0.1"}))
+	assert.Nil(t, s.(*service).initErr, "must be nil")
+	assert.Equal(t, 4322, s.HTTPServer().GetConfig().Port, "should be equal")
+	assert.Equal(t, "127.0.0.1", s.HTTPServer().GetConfig().BindAddr, "should be equal")
+	assert.True(t, s.(*service).isDeprecatedFlag("ginPort"), "should be deprecated")
+	assert.True(t, s.(*service).isDeprecatedFlag("ginaddr"), "should be deprecated")
+
+	t.Setenv("GINPORT", "4323")
+	s = New(WithName("legacy"), WithArgs([]string{}))
+	assert.Nil(t, s.(*service).initErr, "must be nil")
+	assert.Equal(t, 4323, s.HTTPServer().GetConfig().Port, "the old env var should be applied")
+}
+
+func TestFlagAliasPrecedence(t *testing.T) {
+	t.Setenv("GINPORT", "4323")
+	s := New(WithName("legacy"), WithArgs([]string{"-gin-port=5000"}))
+	assert.Nil(t, s.(*service).initErr, "must be nil")
+	assert.Equal(t, 5000, s.HTTPServer().GetConfig().Port, "the cli flag should win over the old env var")
+	assert.Equal(t, SourceCLI, s.(*service).flagSource("gin-port"), "should be equal")
+
+	os.Unsetenv("GINPORT")
+	t.Setenv("GIN_PORT", "4324")
+	s = New(WithName("legacy"), WithArgs([]string{"-ginPort=5001"}))
+	assert.Nil(t, s.(*service).initErr, "must be nil")
+	assert.Equal(t, 5001, s.HTTPServer().GetConfig().Port, "the old cli flag should win over the env var")
+	assert.Equal(t, SourceCLI, s.(*service).flagSource("gin-port"), "should be equal")
+
+	t.Setenv("GINPORT", "4325")
+	s = New(WithName("legacy"), WithArgs([]string{}))
+	assert.Nil(t, s.(*service).initErr, "must be nil")
+	assert.Equal(t, 4324, s.HTTPServer().GetConfig().Port, "the env var should win over the old one")
+
+	for _, e := range s.(*service).EffectiveConfig() {
+		assert.NotEqual(t, "ginPort", e.Name, "aliases are reported by their flag")
+		if e.Name == "gin-port" {
+			assert.Equal(t, "4324", e.Value, "should be equal")
+			assert.Equal(t, SourceEnv, e.Source, "should be equal")
+		}
+	}
+}
+
+func TestKnownArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("gin-port", 0, "")
+	fs.Bool("gin-cors-enabled", false, "")
+
+	args := []string{"serve", "-v", "--gin-port", "8080", "--gin-cors-enabled", "--config", "x.yaml", "-gin-port=9090", "--", "-gin-port=1"}
+	assert.Equal(t, []string{"--gin-port", "8080", "--gin-cors-enabled", "-gin-port=9090"}, knownArgs(fs, args), "should be equal")
+}
+
+func TestDuplicatePrefix(t *testing.T) {
+	s := New(
+		WithArgs([]string{}),
+		WithInitRunnable(&legacyPlugin{name: "mysql", prefix: "db"}),
+		WithInitRunnable(&legacyPlugin{name: "postgres", prefix: "db"}),
+	)
+
+	err := s.Init()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), `prefix "db" of postgres is already registered by mysql`)
+}
+
+func TestOwnOtelPlugin(t *testing.T) {
+	own := &legacyPlugin{name: "own-otel", prefix: "otel"}
+	s := New(WithArgs([]string{}), WithInitRunnable(own))
+
+	assert.Nil(t, s.(*service).initErr, "must be nil")
+	assert.Equal(t, own, s.(*service).initServices["otel"], "should replace the built-in otel plugin")
+	assert.Equal(t, []string{"otel"}, s.(*service).initPrefixes, "should be equal")
+
+	s = New(WithArgs([]string{}), WithInitRunnable(&legacyPlugin{name: "mysql", prefix: "db"}))
+	assert.Nil(t, s.(*service).initErr, "must be nil")
+	assert.Equal(t, []string{"otel", "db"}, s.(*service).initPrefixes, "the built-in otel plugin should come first")
+}
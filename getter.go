@@ -0,0 +1,34 @@
+package goservice
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Get returns the component of the prefix as T, T can be a concrete type or an interface:
+//
+//	client, err := goservice.Get[redis.UniversalClient](service, "redis")
+func Get[T any](sc ServiceContext, prefix string) (T, error) {
+	var zero T
+
+	component, ok := sc.Get(prefix)
+	if !ok {
+		return zero, fmt.Errorf("no component with prefix %q, registered prefixes: [%s]", prefix, strings.Join(sc.ListPrefixes(), ", "))
+	}
+
+	value, ok := component.(T)
+	if !ok {
+		return zero, fmt.Errorf("component %q is %T, not %s", prefix, component, reflect.TypeOf((*T)(nil)).Elem())
+	}
+	return value, nil
+}
+
+// MustGet is Get panicking on errors
+func MustGet[T any](sc ServiceContext, prefix string) T {
+	value, err := Get[T](sc, prefix)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
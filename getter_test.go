@@ -0,0 +1,36 @@
+package goservice
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedGet(t *testing.T) {
+	db := &fakeRunnable{name: "db", prefix: "db"}
+	s := newTestService(
+		WithInitRunnable(db),
+		WithInitRunnable(&fakeRunnable{name: "cache", prefix: "cache"}),
+	)
+	assert.Equal(t, []string{"cache", "db"}, s.ListPrefixes(), "should be equal")
+
+	value, err := Get[*fakeRunnable](s, "db")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, db, value, "should be equal")
+
+	runnable, err := Get[Runnable](s, "db")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "db", runnable.Name(), "should be equal")
+
+	_, err = Get[string](s, "db")
+	assert.EqualError(t, err, `component "db" is *goservice.fakeRunnable, not string`, "should be equal")
+
+	_, err = Get[GrpcServer](s, "db")
+	assert.EqualError(t, err, `component "db" is *goservice.fakeRunnable, not goservice.GrpcServer`, "should be equal")
+
+	_, err = Get[Runnable](s, "mongo")
+	assert.EqualError(t, err, `no component with prefix "mongo", registered prefixes: [cache, db]`, "should be equal")
+
+	assert.Equal(t, db, MustGet[*fakeRunnable](s, "db"), "should be equal")
+	assert.Panics(t, func() { MustGet[string](s, "db") }, "should panic")
+}
@@ -0,0 +1,159 @@
+package httpserver
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// adminService is an internal http server for operational endpoints
+// (health, metrics, pprof, log level...), so they are not exposed with
+// the public routes. It's disabled when admin-port is 0.
+type adminService struct {
+	name     string
+	port     int
+	bindAddr string
+	// max time to wait for in-flight requests when stopping
+	shutdownTimeout time.Duration
+
+	logger   logger.Logger
+	svr      *myHttpServer
+	mu       *sync.Mutex
+	handlers []func(*gin.Engine)
+	// closed when Run returns
+	runDone chan struct{}
+}
+
+func NewAdmin(name string) *adminService {
+	return &adminService{
+		name: name,
+		mu:   &sync.Mutex{},
+	}
+}
+
+func (as *adminService) Name() string {
+	return as.name + "-admin"
+}
+
+func (as *adminService) InitFlags() {
+	as.InitFlagSet(flag.CommandLine)
+}
+
+func (as *adminService) InitFlagSet(fs *flag.FlagSet) {
+	prefix := "admin"
+	fs.IntVar(&as.port, prefix+"-port", 0, "admin server port serving health, metrics, pprof and log level endpoints instead of the gin server. 0 => disabled")
+	fs.StringVar(&as.bindAddr, prefix+"-addr", "", "admin server bind address")
+	fs.DurationVar(&as.shutdownTimeout, prefix+"-shutdown-timeout", defaultShutdownTimeout, "max time to wait for in-flight admin requests when stopping")
+}
+
+func (as *adminService) isEnabled() bool {
+	return as != nil && as.port != 0
+}
+
+func (as *adminService) Configure() error {
+	as.logger = logger.GetCurrent().GetLogger("admin")
+	return nil
+}
+
+func (as *adminService) Run() error {
+	if !as.isEnabled() {
+		return nil
+	}
+
+	done := make(chan struct{})
+	as.mu.Lock()
+	as.runDone = done
+	as.mu.Unlock()
+	defer close(done)
+
+	if err := as.Configure(); err != nil {
+		return err
+	}
+
+	router := gin.New()
+	router.Use(middleware.PanicLogger())
+	registerPprofHandlers(router)
+
+	as.mu.Lock()
+	handlers := append([]func(*gin.Engine){}, as.handlers...)
+	as.mu.Unlock()
+
+	for _, hdl := range handlers {
+		hdl(router)
+	}
+
+	addr := formatBindAddr(as.bindAddr, as.port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("admin server failed to listen on %s: %w", addr, err)
+	}
+
+	svr := newHttpServer(router)
+	svr.ReadHeaderTimeout = defaultReadHeaderTimeout
+	as.mu.Lock()
+	as.svr = svr
+	as.mu.Unlock()
+
+	as.logger.Infof("listen on %s...", lis.Addr().String())
+
+	if err := svr.Serve(lis); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Stop drains the admin server, it sends false if connections are closed
+func (as *adminService) Stop() <-chan bool {
+	c := make(chan bool)
+
+	go func() {
+		as.mu.Lock()
+		svr, done := as.svr, as.runDone
+		as.mu.Unlock()
+
+		drained := true
+		if svr != nil {
+			drained = shutdownServer(svr, as.shutdownTimeout, as.logger)
+		}
+
+		// wait for Run to return
+		if done != nil {
+			<-done
+		}
+		c <- drained
+	}()
+	return c
+}
+
+// AddHandler registers routes on the admin server, they are
+// not served when it's disabled
+func (as *adminService) AddHandler(hdl func(*gin.Engine)) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.handlers = append(as.handlers, hdl)
+}
+
+func (as *adminService) URI() string {
+	return formatBindAddr(as.bindAddr, as.port)
+}
+
+func registerPprofHandlers(engine *gin.Engine) {
+	group := engine.Group("/debug/pprof")
+	group.GET("/", gin.WrapF(pprof.Index))
+	group.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	group.GET("/profile", gin.WrapF(pprof.Profile))
+	group.GET("/symbol", gin.WrapF(pprof.Symbol))
+	group.POST("/symbol", gin.WrapF(pprof.Symbol))
+	group.GET("/trace", gin.WrapF(pprof.Trace))
+	group.GET("/:profile", func(c *gin.Context) {
+		pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+	})
+}
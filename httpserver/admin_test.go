@@ -0,0 +1,177 @@
+package httpserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+const testOpsToken = "ops-token"
+
+func freePort(t *testing.T) int {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.Nil(t, err, "must be nil")
+	defer lis.Close()
+	return getPort(lis)
+}
+
+func startWithAdmin(t *testing.T) (*ginService, *adminService) {
+	logger.InitServLogger(false)
+
+	admin := NewAdmin("test")
+	admin.port = freePort(t)
+
+	gs := New("test")
+	gs.SetAdminServer(admin)
+	gs.AddOpsHandler(func(engine *gin.Engine) {
+		engine.GET("/admin/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	})
+	gs.AddHandler(func(engine *gin.Engine) {
+		engine.GET("/hello", func(c *gin.Context) { c.String(http.StatusOK, "hello") })
+	})
+
+	go func() { _ = admin.Run() }()
+	go func() { _ = gs.Run() }()
+
+	assert.Eventually(t, func() bool { return gs.Port() != 0 }, time.Second, 10*time.Millisecond, "server should listen")
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/healthz", admin.port))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, time.Second, 10*time.Millisecond, "admin server should listen")
+
+	return gs, admin
+}
+
+func statusOf(t *testing.T, port int, path string) int {
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", port, path))
+	assert.Nil(t, err, "must be nil")
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAdminServer(t *testing.T) {
+	gs, admin := startWithAdmin(t)
+
+	for _, path := range []string{"/healthz", "/readyz", "/version", "/admin/ping", "/debug/pprof/", "/debug/pprof/heap"} {
+		assert.Equal(t, http.StatusOK, statusOf(t, admin.port, path), "admin should serve "+path)
+	}
+
+	for _, path := range []string{"/healthz", "/readyz", "/version", "/admin/ping", "/debug/pprof/"} {
+		assert.Equal(t, http.StatusNotFound, statusOf(t, gs.Port(), path), "public should not serve "+path)
+	}
+	assert.Equal(t, http.StatusOK, statusOf(t, gs.Port(), "/hello"), "should be equal")
+	assert.Equal(t, http.StatusNotFound, statusOf(t, admin.port, "/hello"), "should be equal")
+
+	// readiness follows the public server
+	gs.draining.Store(true)
+	assert.Equal(t, http.StatusServiceUnavailable, statusOf(t, admin.port, "/readyz"), "should be equal")
+
+	assert.True(t, <-gs.Stop(), "should stop cleanly")
+	assert.True(t, <-admin.Stop(), "should stop cleanly")
+}
+
+// serveOps serves a GET of path on the public router with the bearer token
+func serveOps(gs *ginService, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	gs.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOpsEndpointsWithoutAdmin(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.AddOpsHandler(func(engine *gin.Engine) {
+		engine.GET("/admin/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	})
+	assert.Nil(t, gs.Configure(), "must be nil")
+	assert.Nil(t, gs.registerRoutes(), "must be nil")
+
+	// only health and version without the ops token
+	for _, path := range []string{"/healthz", "/readyz", "/version"} {
+		assert.Equal(t, http.StatusOK, serveOps(gs, path, "").Code, "public should serve "+path)
+	}
+	for _, path := range []string{"/admin/ping", "/admin/routes", "/admin/maintenance"} {
+		assert.Equal(t, http.StatusNotFound, serveOps(gs, path, testOpsToken).Code, "public should not serve "+path)
+	}
+
+	gs.OpsToken = testOpsToken
+	assert.Nil(t, gs.Configure(), "must be nil")
+	assert.Nil(t, gs.registerRoutes(), "must be nil")
+
+	for _, path := range []string{"/admin/ping", "/admin/routes", "/admin/maintenance"} {
+		assert.Equal(t, http.StatusUnauthorized, serveOps(gs, path, "").Code, "should require the token for "+path)
+		assert.Equal(t, http.StatusUnauthorized, serveOps(gs, path, "wrong").Code, "should reject a wrong token for "+path)
+		assert.Equal(t, http.StatusOK, serveOps(gs, path, testOpsToken).Code, "should serve "+path+" with the token")
+	}
+	assert.Equal(t, http.StatusOK, serveOps(gs, "/healthz", "").Code, "health should stay public")
+}
+
+func TestAdminServerStopsFirst(t *testing.T) {
+	gs, admin := startWithAdmin(t)
+
+	assert.True(t, <-admin.Stop(), "should stop cleanly")
+	assert.Equal(t, http.StatusOK, statusOf(t, gs.Port(), "/hello"), "public server should keep serving")
+	assert.True(t, <-gs.Stop(), "should stop cleanly")
+}
+
+func TestAdminServerDisabled(t *testing.T) {
+	admin := NewAdmin("test")
+	assert.Nil(t, admin.Run(), "must be nil")
+	assert.True(t, <-admin.Stop(), "should stop cleanly")
+}
+
+func TestServerWithoutHandlers(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.Config.Port = freePort(t)
+
+	// returns right away without listening
+	assert.Nil(t, gs.Run(), "must be nil")
+	_, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", gs.Config.Port))
+	assert.NotNil(t, err, "port should not be reserved")
+	assert.True(t, <-gs.Stop(), "should stop cleanly")
+}
+
+func TestDisabledServerHealthOnAdmin(t *testing.T) {
+	logger.InitServLogger(false)
+
+	admin := NewAdmin("test")
+	admin.port = freePort(t)
+
+	gs := New("test")
+	gs.SetAdminServer(admin)
+	gs.Disable()
+	gs.AddHandler(func(engine *gin.Engine) {
+		t.Error("handler of a disabled server should not be called")
+	})
+	assert.Nil(t, gs.Run(), "must be nil")
+
+	go func() { _ = admin.Run() }()
+	defer func() { <-admin.Stop() }()
+
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/readyz", admin.port))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond, "admin server should serve health checks")
+}
@@ -0,0 +1,53 @@
+package httpserver
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+// configureAPIKeys loads the keys of gin-api-keys-file unless a store is set
+func (gs *ginService) configureAPIKeys() error {
+	if gs.apiKeyStore != nil || gs.APIKeysFile == "" {
+		return nil
+	}
+
+	store, err := middleware.LoadStaticKeyStore(gs.APIKeysFile)
+	if err != nil {
+		return fmt.Errorf("invalid gin api keys: %w", err)
+	}
+	gs.apiKeyStore = store
+	return nil
+}
+
+// SetAPIKeyStore replaces the keys of gin-api-keys-file,
+// e.g. with apikeys.NewStore to keep them in the database
+func (gs *ginService) SetAPIKeyStore(s middleware.KeyStore) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.apiKeyStore = s
+}
+
+// RequiredAPIKey authenticates the requests with the keys of gin-api-keys-file
+// or of SetAPIKeyStore, see middleware.RequiredAPIKey:
+//
+//	engine.Group("/internal", gs.RequiredAPIKey(), middleware.RequiredScopes("reports:read"))
+func (gs *ginService) RequiredAPIKey(opts ...middleware.APIKeyOption) gin.HandlerFunc {
+	var once sync.Once
+	var auth gin.HandlerFunc
+
+	return func(c *gin.Context) {
+		// the store is known once configured
+		once.Do(func() {
+			store := gs.apiKeyStore
+			if store == nil {
+				store = middleware.NewStaticKeyStore()
+			}
+			opts = append([]middleware.APIKeyOption{middleware.WithAPIKeyCacheTTL(gs.APIKeyCacheTTL)}, opts...)
+			auth = middleware.RequiredAPIKey(store, opts...)
+		})
+		auth(c)
+	}
+}
@@ -0,0 +1,34 @@
+package httpserver
+
+import (
+	"fmt"
+
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/plugin/worker"
+)
+
+// configureAudit builds the audit config from the flags, the sink logs
+// unless SetAuditSink is called
+func (gs *ginService) configureAudit() error {
+	if gs.listFlagChanged(&gs.auditRedactFields) {
+		gs.Audit.RedactFields = splitList(gs.auditRedactFields)
+	}
+	if gs.Audit.Sink == nil {
+		gs.Audit.Sink = middleware.NewLogAuditSink(nil)
+	}
+
+	if err := gs.Audit.Validate(); err != nil {
+		return fmt.Errorf("invalid gin audit config: %w", err)
+	}
+	return nil
+}
+
+// SetAuditSink writes the audit entries of gin-audit-enabled to sink with the jobs
+// of queue, e.g. audit.NewSink of the gorm plugin and the worker plugin.
+// nil queue writes them in goroutines.
+func (gs *ginService) SetAuditSink(sink middleware.AuditSink, queue worker.Queue) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.Audit.Sink = sink
+	gs.Audit.Queue = queue
+}
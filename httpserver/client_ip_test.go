@@ -0,0 +1,72 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func newClientIPTestService(t *testing.T, trustedProxies, headers string, forwarded bool) *ginService {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.trustedProxies = trustedProxies
+	gs.remoteIPHeaders = headers
+	gs.ForwardedByClientIP = forwarded
+	assert.Nil(t, gs.Configure(), "must be nil")
+
+	gs.router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, middleware.ClientIP(c)) })
+	return gs
+}
+
+func clientIPOf(gs *ginService, remoteAddr string, headers map[string]string) string {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	gs.router.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func TestClientIP(t *testing.T) {
+	chain := map[string]string{"X-Forwarded-For": "203.0.113.7, 10.0.0.5, 10.0.0.9"}
+
+	// no trusted proxy, the headers are ignored
+	gs := newClientIPTestService(t, "", "X-Forwarded-For", true)
+	assert.Equal(t, "10.0.0.1", clientIPOf(gs, "10.0.0.1:4000", chain), "should be the remote address")
+
+	// the chain is walked from the right, skipping trusted proxies
+	gs = newClientIPTestService(t, "10.0.0.0/8", "X-Forwarded-For", true)
+	assert.Equal(t, "203.0.113.7", clientIPOf(gs, "10.0.0.1:4000", chain), "should be the first untrusted hop")
+	assert.Equal(t, "198.51.100.1", clientIPOf(gs, "198.51.100.1:4000", chain), "untrusted peer should not be believed")
+
+	spoofed := map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7, 10.0.0.5"}
+	assert.Equal(t, "203.0.113.7", clientIPOf(gs, "10.0.0.1:4000", spoofed), "client set entries should be ignored")
+
+	// single ips and header priority
+	gs = newClientIPTestService(t, "10.0.0.1", "CF-Connecting-IP,X-Forwarded-For", true)
+	assert.Equal(t, "192.0.2.44", clientIPOf(gs, "10.0.0.1:4000", map[string]string{
+		"CF-Connecting-IP": "192.0.2.44",
+		"X-Forwarded-For":  "203.0.113.7",
+	}), "should use the first header")
+
+	gs = newClientIPTestService(t, "10.0.0.0/8", "X-Forwarded-For", false)
+	assert.Equal(t, "10.0.0.1", clientIPOf(gs, "10.0.0.1:4000", chain), "headers should be ignored")
+}
+
+func TestInvalidTrustedProxies(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.trustedProxies = "10.0.0.0/8,10.0.0.300/24"
+	err := gs.Configure()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "10.0.0.300/24", "should name the invalid entry")
+}
@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func newCORSTestService(origins string, credentials bool) *ginService {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.CORSEnabled = true
+	gs.corsOrigins = origins
+	gs.corsMethods = "GET,POST"
+	gs.CORS.AllowCredentials = credentials
+	gs.CORS.MaxAge = 12 * time.Hour
+	return gs
+}
+
+func performCORSRequest(gs *ginService, method, path, origin string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	gs.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSWildcardWithCredentials(t *testing.T) {
+	gs := newCORSTestService("*", true)
+
+	err := gs.Configure()
+	assert.ErrorIs(t, err, middleware.ErrCORSWildcardWithCredentials)
+}
+
+func TestCORSPreflight(t *testing.T) {
+	gs := newCORSTestService("https://a.example.com", true)
+	assert.Nil(t, gs.Configure(), "must be nil")
+
+	called := false
+	gs.router.POST("/items", func(c *gin.Context) { called = true })
+
+	w := performCORSRequest(gs, http.MethodOptions, "/items", "https://a.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code, "should be equal")
+	assert.False(t, called, "preflight must not reach the handler")
+	assert.Equal(t, "https://a.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "43200", w.Header().Get("Access-Control-Max-Age"))
+
+	w = performCORSRequest(gs, http.MethodOptions, "/items", "https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, w.Code, "should be equal")
+
+	w = performCORSRequest(gs, http.MethodPost, "/items", "https://a.example.com")
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.True(t, called, "should reach the handler")
+	assert.Equal(t, "https://a.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSOverride(t *testing.T) {
+	gs := newCORSTestService("https://a.example.com", false)
+	gs.AddCORSOverride("/public", middleware.CORSConfig{AllowOrigins: []string{"*"}, AllowMethods: []string{"GET"}})
+	assert.Nil(t, gs.Configure(), "must be nil")
+
+	w := performCORSRequest(gs, http.MethodOptions, "/public/items", "https://b.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code, "should be equal")
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET", w.Header().Get("Access-Control-Allow-Methods"))
+
+	w = performCORSRequest(gs, http.MethodOptions, "/private/items", "https://b.example.com")
+	assert.Equal(t, http.StatusForbidden, w.Code, "should be equal")
+}
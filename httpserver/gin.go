@@ -2,33 +2,152 @@ package httpserver
 
 import (
 	"context"
-	"errors"
+	"crypto/subtle"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/cache"
 	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/httpserver/openapi"
+	"github.com/taimaifika/go-sdk/httpserver/websocket"
+	"github.com/taimaifika/go-sdk/i18n"
 	"github.com/taimaifika/go-sdk/logger"
-	"github.com/taimaifika/go-sdk/plugin/otel"
+	"github.com/taimaifika/go-sdk/sdkcm"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// DefaultPrefix is the flag prefix of the default server
+const DefaultPrefix = "gin"
+
 var (
 	ginMode     string
 	ginNoLogger bool
 	defaultPort = 3000
+
+	defaultShutdownTimeout = 15 * time.Second
+
+	defaultReadTimeout       = 30 * time.Second
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultWriteTimeout      = 60 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+
+	defaultMaxBodyBytes    int64 = 10 << 20
+	defaultMultipartMemory int64 = 32 << 20
+
+	defaultETagMaxBody = 256 << 10
+
+	defaultAPIKeyCacheTTL = 30 * time.Second
+
+	defaultIdempotencyTTL     = 24 * time.Hour
+	defaultIdempotencyLockTTL = time.Minute
+	defaultIdempotencyMaxBody = 64 << 10
+
+	defaultResponseCacheTTL            = time.Minute
+	defaultResponseCacheMaxBody        = 1 << 20
+	defaultResponseCacheSize           = 1000
+	defaultResponseCacheMaxBytes int64 = 64 << 20
+
+	defaultOpenAPIPath   = "/openapi.json"
+	defaultOpenAPIUIPath = "/docs"
 )
 
 type Config struct {
-	Port         int    `json:"http_port"`
-	BindAddr     string `json:"http_bind_addr"`
-	GinNoDefault bool   `json:"http_no_default"`
+	Port               int                        `json:"http_port"`
+	BindAddr           string                     `json:"http_bind_addr"`
+	GinNoDefault       bool                       `json:"http_no_default"`
+	HealthDisabled     bool                       `json:"http_health_disabled"`
+	HealthCheckTimeout time.Duration              `json:"http_health_check_timeout"`
+	VersionDisabled    bool                       `json:"http_version_disabled"`
+	CORSEnabled        bool                       `json:"http_cors_enabled"`
+	RequestTimeout     time.Duration              `json:"http_request_timeout"`
+	MetricsDisabled    bool                       `json:"http_metrics_disabled"`
+	MetricsBuckets     []float64                  `json:"http_metrics_buckets"`
+	ShutdownTimeout    time.Duration              `json:"http_shutdown_timeout"`
+	ShutdownDelay      time.Duration              `json:"http_shutdown_delay"`
+	ReadTimeout        time.Duration              `json:"http_read_timeout"`
+	ReadHeaderTimeout  time.Duration              `json:"http_read_header_timeout"`
+	WriteTimeout       time.Duration              `json:"http_write_timeout"`
+	IdleTimeout        time.Duration              `json:"http_idle_timeout"`
+	MaxHeaderBytes     int                        `json:"http_max_header_bytes"`
+	KeepAlivePeriod    time.Duration              `json:"http_keep_alive_period"`
+	RateLimitRPS       float64                    `json:"http_rate_limit_rps"`
+	RateLimitBurst     int                        `json:"http_rate_limit_burst"`
+	RateLimitKey       string                     `json:"http_rate_limit_key"`
+	CORS               middleware.CORSConfig      `json:"http_cors"`
+	AccessLog          middleware.AccessLogConfig `json:"http_access_log"`
+	// CIDRs or ips of proxies whose forwarded headers are trusted
+	TrustedProxies      []string `json:"http_trusted_proxies"`
+	ForwardedByClientIP bool     `json:"http_forwarded_by_client_ip"`
+	// headers read in order for the client ip of trusted proxies
+	RemoteIPHeaders []string         `json:"http_remote_ip_headers"`
+	Websocket       websocket.Config `json:"http_websocket"`
+	// directory of the static files, SetStaticFS takes precedence
+	StaticDir string                  `json:"http_static_dir"`
+	Static    middleware.StaticConfig `json:"http_static"`
+	// compresses responses with brotli or gzip
+	CompressionEnabled bool                         `json:"http_compression_enabled"`
+	Compression        middleware.CompressionConfig `json:"http_compression"`
+	// sets ETags of GET responses and answers If-None-Match with 304
+	ETagEnabled bool                  `json:"http_etag_enabled"`
+	ETag        middleware.ETagConfig `json:"http_etag"`
+	// larger request bodies are rejected with 413, 0 => no limit
+	MaxBodyBytes int64 `json:"http_max_body_bytes"`
+	// multipart files above this are stored in temporary files
+	MultipartMemory int64 `json:"http_multipart_memory"`
+	// replays the responses of the routes using IdempotentRoutes
+	Idempotency middleware.IdempotencyConfig `json:"http_idempotency"`
+	// JSON file of the keys of RequiredAPIKey, with their hashes
+	APIKeysFile    string        `json:"http_api_keys_file"`
+	APIKeyCacheTTL time.Duration `json:"http_api_key_cache_ttl"`
+	// records the mutating requests, see SetAuditSink
+	AuditEnabled bool                   `json:"http_audit_enabled"`
+	Audit        middleware.AuditConfig `json:"http_audit"`
+	// resolves the locale of the requests and translates their error messages
+	LocaleEnabled bool                    `json:"http_locale_enabled"`
+	Locale        middleware.LocaleConfig `json:"http_locale"`
+	// directory of the catalogs, e.g. en.toml and vi.json, see SetI18nBundle
+	I18nDir           string `json:"http_i18n_dir"`
+	I18nDefaultLocale string `json:"http_i18n_default_locale"`
+	// caches the responses of the routes using CachedRoutes
+	ResponseCaching middleware.ResponseCacheConfig `json:"http_response_cache"`
+	// entries and bytes of the in-memory cache, see SetResponseCacheStore
+	ResponseCacheSize     int   `json:"http_response_cache_size"`
+	ResponseCacheMaxBytes int64 `json:"http_response_cache_max_bytes"`
+	// serves the OpenAPI document of the routes, see OpenAPI
+	OpenAPIEnabled bool   `json:"http_openapi_enabled"`
+	OpenAPIPath    string `json:"http_openapi_path"`
+	OpenAPIUI      bool   `json:"http_openapi_ui"`
+	OpenAPIUIPath  string `json:"http_openapi_ui_path"`
+	// fail | warn on the routes registered twice, or conflicting with a wildcard
+	DuplicateRoutes string `json:"http_duplicate_routes"`
+	RoutesDisabled  bool   `json:"http_routes_disabled"`
+	// responds 503 to the requests, it's also turned on and off with SetMaintenance
+	MaintenanceEnabled bool                         `json:"http_maintenance_enabled"`
+	MaintenanceMessage string                       `json:"http_maintenance_message"`
+	Maintenance        middleware.MaintenanceConfig `json:"http_maintenance"`
+	// tcp | unix, a socket passed by systemd socket activation is used instead
+	ListenNetwork  string      `json:"http_listen_network"`
+	UnixSocketPath string      `json:"http_unix_socket_path"`
+	UnixSocketMode fs.FileMode `json:"http_unix_socket_mode"`
+	// serves HTTP/2 without TLS, for proxies and grpc-gateway style clients
+	H2CEnabled bool        `json:"http_h2c_enabled"`
+	HTTP2      HTTP2Config `json:"http_http2"`
+	// HMAC secret shared by the services, the requesters they sign in X-Requester are trusted
+	RequesterSecret string `json:"-"`
+	// bearer token of the ops endpoints served by this server when the admin
+	// server is disabled, they are not served without it
+	OpsToken string `json:"-"`
 }
 
 type GinService interface {
@@ -40,26 +159,126 @@ type GinService interface {
 type ginService struct {
 	Config
 	isEnabled bool
-	name      string
-	version   string
+	// headless services never start the server
+	disabled bool
+	name     string
+	version  string
+	// of the flags and the logger, gin for the default server
+	prefix string
 
-	logger   logger.Logger
-	svr      *myHttpServer
-	router   *gin.Engine
-	mu       *sync.Mutex
-	handlers []func(*gin.Engine)
+	logger       logger.Logger
+	svr          *myHttpServer
+	router       *gin.Engine
+	mu           *sync.Mutex
+	handlers     []func(*gin.Engine)
+	opsHandlers  []func(*gin.Engine)
+	healthChecks []healthCheck
+	buildInfo    BuildInfo
+	// comma separated cors flags, parsed into Config.CORS by Configure
+	corsOrigins    string
+	corsMethods    string
+	corsHeaders    string
+	corsOverrides  map[string]middleware.CORSConfig
+	panicNotifiers []middleware.PanicNotifier
+	// comma separated lists, parsed into Config by Configure
+	trustedProxies  string
+	remoteIPHeaders string
+	// comma separated paths, parsed into Config.AccessLog by Configure
+	accessLogSkipPaths string
+	// comma separated lists, parsed into Config.Compression by Configure
+	compressionExcludedTypes string
+	compressionExcludedPaths string
+	// comma separated fields, parsed into Config.Audit by Configure
+	auditRedactFields string
+	// comma separated sources, parsed into Config.Locale by Configure
+	localeSources string
+	// comma separated seconds, parsed into Config.MetricsBuckets by Configure
+	metricsBuckets string
+	// comma separated networks not rate limited
+	rateLimitExemptCIDRs string
+	// nil uses an in-memory limiter
+	rateLimiter middleware.Limiter
+	// comma separated origins, parsed into Config.Websocket by Configure
+	wsOrigins string
+	// tracks the connections of AddWebsocketHandler
+	ws *websocket.Server
+	// static files of SetStaticFS
+	staticFS fs.FS
+	// nil uses an in-memory store
+	idempotencyStore middleware.IdempotencyStore
+	// built by Configure for IdempotentRoutes
+	idempotency gin.HandlerFunc
+	// nil uses the keys of APIKeysFile
+	apiKeyStore middleware.KeyStore
+	// comma separated headers, parsed into Config.ResponseCaching by Configure
+	responseCacheVary string
+	// nil uses an in-memory cache
+	responseCacheStore cache.Cache
+	// built by Configure for CachedRoutes
+	responseCache *middleware.ResponseCache
+	// routes documented with OpenAPI
+	docs *openapi.Docs
+	// switch of the maintenance mode
+	maintenance *middleware.Maintenance
+	// nil keeps the maintenance state local
+	maintenanceSync middleware.MaintenanceSync
+	maintenanceSub  io.Closer
+	// comma separated lists, parsed into Config.Maintenance by Configure
+	maintenanceExemptPaths string
+	maintenanceExemptCIDRs string
+	// octal, parsed into Config.UnixSocketMode by Configure
+	unixSocketMode string
+	// serves the ops handlers instead of the router when enabled
+	admin *adminService
+	// serves the router, Reload swaps it
+	handler *routerHandler
+	// the listener being served and its address, Reload replaces them
+	// when the address changes, unless the listener is passed by systemd
+	lis          net.Listener
+	lisAddr      string
+	lisInherited bool
+	// errors of the listeners being served
+	serveErrs chan error
+	// serializes the reloads
+	reloadMu sync.Mutex
+	// last values of the comma separated flags parsed by Configure
+	parsedFlags map[*string]string
+	// set while stopping, /readyz responds 503
+	draining atomic.Bool
+	// closed when the server stops serving, ends the streams
+	stopping     chan struct{}
+	stoppingOnce *sync.Once
+	// closed when Run returns
+	runDone chan struct{}
+	// closed once listening, or when Run returns for a disabled server
+	listening     chan struct{}
+	listeningOnce sync.Once
 }
 
 func New(name string) *ginService {
+	return NewNamed(name, DefaultPrefix)
+}
+
+// NewNamed creates another server of the service, its flags start with
+// prefix, e.g. partner-port. gin-mode and gin-no-logger are shared.
+func NewNamed(name, prefix string) *ginService {
 	return &ginService{
-		name:     name,
-		mu:       &sync.Mutex{},
-		handlers: []func(*gin.Engine){},
+		name:        name,
+		prefix:      prefix,
+		mu:          &sync.Mutex{},
+		handlers:    []func(*gin.Engine){},
+		docs:        openapi.New(openapi.Info{Title: name}),
+		maintenance: middleware.NewMaintenance(),
+		listening:   make(chan struct{}),
 	}
 }
 
 func (gs *ginService) Name() string {
-	return gs.name + "-gin"
+	return gs.name + "-" + gs.prefix
+}
+
+func (gs *ginService) Prefix() string {
+	return gs.prefix
 }
 
 func (gs *ginService) Version() string {
@@ -67,15 +286,146 @@ func (gs *ginService) Version() string {
 }
 
 func (gs *ginService) InitFlags() {
-	prefix := "gin"
-	flag.IntVar(&gs.Config.Port, prefix+"Port", defaultPort, "gin server Port. If 0 => get a random Port")
-	flag.StringVar(&gs.BindAddr, prefix+"addr", "", "gin server bind address")
-	flag.StringVar(&ginMode, "gin-mode", "", "gin mode")
-	flag.BoolVar(&ginNoLogger, "gin-no-logger", false, "disable default gin logger middleware")
+	gs.InitFlagSet(flag.CommandLine)
+}
+
+// FlagAliases returns the old names of the port and address flags,
+// they are still applied, env GINPORT and GINADDR included
+func (gs *ginService) FlagAliases() map[string]string {
+	return map[string]string{
+		gs.prefix + "Port": gs.prefix + "-port",
+		gs.prefix + "addr": gs.prefix + "-addr",
+	}
+}
+
+// InitFlagSet registers the flags into fs, InitFlags into flag.CommandLine
+func (gs *ginService) InitFlagSet(fs *flag.FlagSet) {
+	prefix := gs.prefix
+	fs.IntVar(&gs.Config.Port, prefix+"-port", defaultPort, "gin server Port. If 0 => get a random Port")
+	fs.StringVar(&gs.BindAddr, prefix+"-addr", "", "gin server bind address")
+	// names before the rename, see FlagAliases
+	fs.IntVar(&gs.Config.Port, prefix+"Port", defaultPort, "deprecated, use "+prefix+"-port")
+	fs.StringVar(&gs.BindAddr, prefix+"addr", "", "deprecated, use "+prefix+"-addr")
+	fs.StringVar(&gs.ListenNetwork, prefix+"-listen-network", ListenNetworkTCP, "network to listen on: tcp | unix. A socket passed by systemd socket activation is used instead, the one named "+prefix+" or the only one")
+	fs.StringVar(&gs.UnixSocketPath, prefix+"-unix-socket-path", "", "path of the unix socket, a stale socket is removed on start and the socket is removed on stop")
+	fs.StringVar(&gs.unixSocketMode, prefix+"-unix-socket-mode", defaultUnixSocketMode, "octal file mode of the unix socket, e.g. 0666 for a proxy running as another user")
+	if prefix == DefaultPrefix {
+		fs.StringVar(&ginMode, "gin-mode", "", "gin mode")
+		fs.BoolVar(&ginNoLogger, "gin-no-logger", false, "disable the access log middleware")
+	}
+	fs.StringVar(&gs.accessLogSkipPaths, prefix+"-access-log-skip-paths", "", "comma separated paths which are not logged. Ex: /healthz,/metrics")
+	fs.IntVar(&gs.AccessLog.MaxBodySize, prefix+"-access-log-body-size", 0, "log request and response bodies up to this size in bytes, for debugging. 0 => disabled")
+	fs.StringVar(&gs.AccessLog.ClientErrorLevel, prefix+"-access-log-level-4xx", "warn", "log level of 4xx responses: debug | info | warn | error")
+	fs.StringVar(&gs.AccessLog.ServerErrorLevel, prefix+"-access-log-level-5xx", "error", "log level of 5xx responses: debug | info | warn | error")
+	fs.StringVar(&gs.trustedProxies, prefix+"-trusted-proxies", "", "comma separated CIDRs or ips of proxies whose forwarded headers give the client ip. Empty => no proxy is trusted")
+	fs.BoolVar(&gs.ForwardedByClientIP, prefix+"-forwarded-by-client-ip", true, "read the client ip from the remote ip headers of trusted proxies")
+	fs.StringVar(&gs.remoteIPHeaders, prefix+"-remote-ip-headers", "X-Forwarded-For,X-Real-IP", "comma separated headers giving the client ip, in priority order. Ex: CF-Connecting-IP,X-Forwarded-For")
+	fs.BoolVar(&gs.HealthDisabled, prefix+"-health-disabled", false, "disable /healthz and /readyz endpoints")
+	fs.DurationVar(&gs.HealthCheckTimeout, prefix+"-health-check-timeout", defaultHealthCheckTimeout, "timeout of each plugin health check in /readyz")
+	fs.BoolVar(&gs.VersionDisabled, prefix+"-version-disabled", false, "disable /version endpoint")
+	fs.DurationVar(&gs.RequestTimeout, prefix+"-request-timeout", 0, "timeout of each request, responds 503 when exceeded. 0 => disabled")
+	fs.DurationVar(&gs.ShutdownTimeout, prefix+"-shutdown-timeout", defaultShutdownTimeout, "max time to wait for in-flight requests when stopping, then connections are closed")
+	fs.DurationVar(&gs.ShutdownDelay, prefix+"-shutdown-delay", 0, "time to keep serving with /readyz responding 503 before stopping, so load balancers take the instance out")
+	fs.DurationVar(&gs.ReadTimeout, prefix+"-read-timeout", defaultReadTimeout, "max time to read a request including its body. 0 => no limit")
+	fs.DurationVar(&gs.ReadHeaderTimeout, prefix+"-read-header-timeout", defaultReadHeaderTimeout, "max time to read request headers, against slow clients. 0 => read timeout")
+	fs.DurationVar(&gs.WriteTimeout, prefix+"-write-timeout", defaultWriteTimeout, "max time from the end of the request headers to the end of the response. 0 => no limit")
+	fs.DurationVar(&gs.IdleTimeout, prefix+"-idle-timeout", defaultIdleTimeout, "max time to wait for the next request on a keep-alive connection. 0 => read timeout")
+	fs.IntVar(&gs.MaxHeaderBytes, prefix+"-max-header-bytes", http.DefaultMaxHeaderBytes, "max size of request headers in bytes")
+	fs.BoolVar(&gs.H2CEnabled, prefix+"-h2c-enabled", false, "serve HTTP/2 without TLS (h2c) to clients with prior knowledge or upgrading, HTTP/1.1 is still served")
+	fs.UintVar(&gs.HTTP2.MaxConcurrentStreams, prefix+"-http2-max-concurrent-streams", 0, "max concurrent streams of each HTTP/2 connection. 0 => 250")
+	fs.UintVar(&gs.HTTP2.MaxReadFrameSize, prefix+"-http2-max-frame-size", 0, "max size of the HTTP/2 frames read, from 16384 to 16777215. 0 => 1MB")
+	fs.DurationVar(&gs.HTTP2.IdleTimeout, prefix+"-http2-idle-timeout", 0, "idle HTTP/2 connections are closed after it. 0 => "+prefix+"-idle-timeout")
+	fs.DurationVar(&gs.KeepAlivePeriod, prefix+"-tcp-keep-alive", defaultKeepAlivePeriod, "period of TCP keep-alive probes, so dead connections go away. 0 => disabled")
+	fs.BoolVar(&gs.MetricsDisabled, prefix+"-metrics-disabled", false, "disable http server metrics")
+	fs.StringVar(&gs.metricsBuckets, prefix+"-metrics-buckets", "", "comma separated request duration histogram buckets in seconds. Default is 0.005,0.01,0.025,0.05,0.075,0.1,0.25,0.5,0.75,1,2.5,5,7.5,10")
+	fs.Float64Var(&gs.RateLimitRPS, prefix+"-rate-limit-rps", 0, "requests per second allowed for each client, responds 429 when exceeded. 0 => disabled")
+	fs.IntVar(&gs.RateLimitBurst, prefix+"-rate-limit-burst", 10, "max requests a client can send at once")
+	fs.StringVar(&gs.RateLimitKey, prefix+"-rate-limit-key", middleware.RateLimitByIP, "what a client is: ip | api-key | requester. Requests are by ip until authenticated, use middleware.RateLimit after RequiredAuth for per user limits")
+	fs.StringVar(&gs.rateLimitExemptCIDRs, prefix+"-rate-limit-exempt-cidrs", "", "comma separated networks which are not rate limited, e.g. 10.0.0.0/8")
+	fs.BoolVar(&gs.CORSEnabled, prefix+"-cors-enabled", false, "enable CORS middleware")
+	fs.StringVar(&gs.corsOrigins, prefix+"-cors-allow-origins", "*", "comma separated CORS allowed origins")
+	fs.StringVar(&gs.corsMethods, prefix+"-cors-allow-methods", "GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS", "comma separated CORS allowed methods")
+	fs.StringVar(&gs.corsHeaders, prefix+"-cors-allow-headers", "Origin,Content-Type,Content-Length,Accept,Accept-Encoding,Authorization,X-Request-ID", "comma separated CORS allowed headers")
+	fs.BoolVar(&gs.CORS.AllowCredentials, prefix+"-cors-allow-credentials", false, "allow credentials in CORS requests, can't be used with origin *")
+	fs.DurationVar(&gs.CORS.MaxAge, prefix+"-cors-max-age", 12*time.Hour, "how long CORS preflight results can be cached")
+	fs.BoolVar(&gs.CompressionEnabled, prefix+"-compression-enabled", false, "compress responses with brotli or gzip as clients accept")
+	fs.IntVar(&gs.Compression.Level, prefix+"-compression-level", -1, "compression level from 1 (fastest) to 9 (smallest). -1 => default")
+	fs.IntVar(&gs.Compression.MinSize, prefix+"-compression-min-size", 1024, "smaller responses are not compressed, in bytes")
+	fs.StringVar(&gs.compressionExcludedTypes, prefix+"-compression-excluded-types", "", "comma separated content type prefixes not compressed, in addition to images, archives and event streams")
+	fs.StringVar(&gs.compressionExcludedPaths, prefix+"-compression-excluded-paths", "", "comma separated path prefixes not compressed. Ex: /downloads")
+	fs.BoolVar(&gs.ETagEnabled, prefix+"-etag-enabled", false, "set ETags of successful GET responses from their body and answer If-None-Match with 304")
+	fs.IntVar(&gs.ETag.MaxBodySize, prefix+"-etag-max-body", defaultETagMaxBody, "larger responses are not buffered to get an ETag, in bytes")
+	fs.Int64Var(&gs.MaxBodyBytes, prefix+"-max-body-bytes", defaultMaxBodyBytes, "max size of request bodies in bytes, responds 413 when exceeded. Routes raise it with middleware.BodyLimit. 0 => no limit")
+	fs.Int64Var(&gs.MultipartMemory, prefix+"-multipart-memory", defaultMultipartMemory, "max memory of parsed multipart forms in bytes, larger files are stored in temporary files")
+	fs.DurationVar(&gs.Idempotency.TTL, prefix+"-idempotency-ttl", defaultIdempotencyTTL, "how long responses of idempotent routes are replayed for the same Idempotency-Key")
+	fs.DurationVar(&gs.Idempotency.LockTTL, prefix+"-idempotency-lock-ttl", defaultIdempotencyLockTTL, "how long an Idempotency-Key stays in progress if the instance dies while handling it. 0 => idempotency ttl")
+	fs.IntVar(&gs.Idempotency.MaxBodySize, prefix+"-idempotency-max-body", defaultIdempotencyMaxBody, "larger response bodies of idempotent routes are not kept, only their status and headers are replayed")
+	fs.DurationVar(&gs.ResponseCaching.TTL, prefix+"-response-cache-ttl", defaultResponseCacheTTL, "how long responses of the cached routes are fresh, routes may use a different one")
+	fs.DurationVar(&gs.ResponseCaching.StaleTTL, prefix+"-response-cache-stale-ttl", 0, "how long expired responses of the cached routes are served while they are refreshed in the background. 0 => not served")
+	fs.StringVar(&gs.responseCacheVary, prefix+"-response-cache-vary", "", "comma separated request headers the cached responses differ by. Ex: Accept,X-Tenant")
+	fs.IntVar(&gs.ResponseCaching.MaxBodySize, prefix+"-response-cache-max-body", defaultResponseCacheMaxBody, "larger responses of the cached routes are not cached, in bytes")
+	fs.BoolVar(&gs.ResponseCaching.AllowAuthenticated, prefix+"-response-cache-allow-auth", false, "cache the responses of authenticated requests too, they are shared by every user")
+	fs.BoolVar(&gs.ResponseCaching.AllowSetCookie, prefix+"-response-cache-allow-set-cookie", false, "cache the responses setting cookies too, without their Set-Cookie header")
+	fs.IntVar(&gs.ResponseCacheSize, prefix+"-response-cache-size", defaultResponseCacheSize, "max responses kept by the in-memory response cache. 0 => unlimited")
+	fs.Int64Var(&gs.ResponseCacheMaxBytes, prefix+"-response-cache-max-bytes", defaultResponseCacheMaxBytes, "max bytes kept by the in-memory response cache. 0 => unlimited")
+	fs.BoolVar(&gs.OpenAPIEnabled, prefix+"-openapi-enabled", false, "serve the OpenAPI document of the routes, the ones registered without gs.OpenAPI() only have their path params")
+	fs.StringVar(&gs.OpenAPIPath, prefix+"-openapi-path", defaultOpenAPIPath, "path of the OpenAPI document")
+	fs.BoolVar(&gs.OpenAPIUI, prefix+"-openapi-ui", false, "serve a Swagger UI of the OpenAPI document, its assets are loaded from unpkg.com")
+	fs.StringVar(&gs.OpenAPIUIPath, prefix+"-openapi-ui-path", defaultOpenAPIUIPath, "path of the Swagger UI")
+	fs.StringVar(&gs.DuplicateRoutes, prefix+"-duplicate-routes", DuplicateRoutesFail, "on routes registered twice or conflicting with a wildcard: fail | warn (log them and skip the next routes of their handler)")
+	fs.BoolVar(&gs.RoutesDisabled, prefix+"-routes-disabled", false, "disable /admin/routes endpoint")
+	fs.BoolVar(&gs.MaintenanceEnabled, prefix+"-maintenance-enabled", false, "start in maintenance mode, requests are responded 503 except the health endpoints. It's turned off with PUT /admin/maintenance")
+	fs.StringVar(&gs.MaintenanceMessage, prefix+"-maintenance-message", "", "message of the maintenance responses")
+	fs.DurationVar(&gs.Maintenance.RetryAfter, prefix+"-maintenance-retry-after", defaultMaintenanceRetryAfter, "Retry-After of the maintenance responses without eta. 0 => not set")
+	fs.StringVar(&gs.maintenanceExemptPaths, prefix+"-maintenance-exempt-paths", "", "comma separated path prefixes served during maintenance, in addition to /healthz and /readyz. Ex: /webhooks")
+	fs.StringVar(&gs.maintenanceExemptCIDRs, prefix+"-maintenance-exempt-cidrs", "", "comma separated networks served during maintenance, e.g. the office to check the migration")
+	fs.StringVar(&gs.APIKeysFile, prefix+"-api-keys-file", "", "JSON file of the api keys of RequiredAPIKey: [{\"id\": \"ci\", \"hash\": \"<sha256 hex>\", \"scopes\": [\"deploy\"]}]")
+	fs.DurationVar(&gs.APIKeyCacheTTL, prefix+"-api-key-cache-ttl", defaultAPIKeyCacheTTL, "how long api keys are cached, revoked keys are accepted until then. 0 => disabled")
+	fs.BoolVar(&gs.AuditEnabled, prefix+"-audit-enabled", false, "record who sent the POST, PUT, PATCH and DELETE requests, to the logs unless SetAuditSink is called. Routes opt out with middleware.SkipAudit")
+	fs.IntVar(&gs.Audit.MaxBodySize, prefix+"-audit-body-size", 0, "keep the redacted JSON request bodies up to this size in bytes in the audit entries. 0 => not kept")
+	fs.StringVar(&gs.auditRedactFields, prefix+"-audit-redact-fields", "", "comma separated request body fields redacted in the audit entries, in addition to password, token, secret, authorization, api_key, card_number and cvv")
+	fs.BoolVar(&gs.LocaleEnabled, prefix+"-locale-enabled", false, "resolve the locale of the requests for i18n.T and translate the errors having a message key")
+	fs.StringVar(&gs.localeSources, prefix+"-locale-sources", "query,cookie,header", "comma separated sources of the locale in priority order: query | cookie | header (Accept-Language)")
+	fs.StringVar(&gs.Locale.QueryParam, prefix+"-locale-query-param", "lang", "query param of the locale")
+	fs.StringVar(&gs.Locale.CookieName, prefix+"-locale-cookie", "lang", "cookie of the locale")
+	fs.StringVar(&gs.I18nDir, prefix+"-i18n-dir", "", "directory of the message catalogs, one JSON or TOML file per locale. Ex: locales/vi.toml")
+	fs.StringVar(&gs.I18nDefaultLocale, prefix+"-i18n-default-locale", i18n.DefaultLocale, "locale of the requests without supported locale, and of the messages missing in others")
+	fs.StringVar(&gs.StaticDir, prefix+"-static-dir", "", "directory of static files served for the paths without route. Empty => disabled")
+	fs.StringVar(&gs.Static.Prefix, prefix+"-static-prefix", "/", "path the static files are served under")
+	fs.BoolVar(&gs.Static.SPA, prefix+"-spa-mode", false, "serve index.html for unknown paths which don't look like files, for single page apps")
+	wsDefault := websocket.DefaultConfig()
+	fs.StringVar(&gs.RequesterSecret, prefix+"-requester-secret", "", "HMAC secret shared with the other services, the requesters they send in the X-Requester header are trusted. Empty => the header is ignored")
+	fs.StringVar(&gs.OpsToken, prefix+"-ops-token", "", "bearer token of the ops endpoints (routes, maintenance, metrics, log level, reload, config) when the admin server is disabled. Empty => only the health and version endpoints are served on the public port")
+	fs.StringVar(&gs.wsOrigins, prefix+"-ws-allow-origins", "", "comma separated origins allowed to open websockets, * or https://*.example.com for subdomains. Empty => same origin only")
+	fs.DurationVar(&gs.Websocket.HandshakeTimeout, prefix+"-ws-handshake-timeout", wsDefault.HandshakeTimeout, "max time of the websocket upgrade")
+	fs.DurationVar(&gs.Websocket.WriteTimeout, prefix+"-ws-write-timeout", wsDefault.WriteTimeout, "max time to write a websocket message")
+	fs.DurationVar(&gs.Websocket.PingInterval, prefix+"-ws-ping-interval", wsDefault.PingInterval, "interval of websocket pings. 0 => disabled")
+	fs.DurationVar(&gs.Websocket.PongTimeout, prefix+"-ws-pong-timeout", wsDefault.PongTimeout, "websockets receiving nothing, not even a pong, for this long are closed. 0 => disabled")
+	fs.Int64Var(&gs.Websocket.MaxMessageSize, prefix+"-ws-max-message-size", wsDefault.MaxMessageSize, "max size of received websocket messages in bytes. 0 => no limit")
 }
 
 func (gs *ginService) Configure() error {
-	gs.logger = logger.GetCurrent().GetLogger("gin")
+	if err := gs.configureRouter(); err != nil {
+		return err
+	}
+
+	gs.draining.Store(false)
+	gs.handler = newRouterHandler(gs.router)
+	gs.svr = newHttpServer(gs.handler)
+	gs.svr.ReadTimeout = gs.ReadTimeout
+	gs.svr.ReadHeaderTimeout = gs.ReadHeaderTimeout
+	gs.svr.WriteTimeout = gs.WriteTimeout
+	gs.svr.IdleTimeout = gs.IdleTimeout
+	gs.svr.MaxHeaderBytes = gs.MaxHeaderBytes
+	gs.svr.keepAlivePeriod = gs.KeepAlivePeriod
+
+	return gs.configureHTTP2()
+}
+
+// configureRouter builds a new router with the middlewares of the config,
+// the routes are registered by registerRoutes
+func (gs *ginService) configureRouter() error {
+	gs.logger = logger.GetCurrent().GetLogger(gs.prefix)
 
 	if ginMode == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -83,29 +433,277 @@ func (gs *ginService) Configure() error {
 
 	gs.logger.Debug("init gin engine...")
 	gs.router = gin.New()
+
+	gs.stopping = make(chan struct{})
+	gs.stoppingOnce = &sync.Once{}
+	// long-lived responses like sse streams end when stopping
+	gs.router.Use(middleware.ShutdownSignal(gs.stopping))
+
+	if err := gs.configureWebsocket(); err != nil {
+		return err
+	}
+	// before the other middlewares, they still run on the upgraded connections
+	// which the http server doesn't track
+	gs.router.Use(gs.ws.Track())
+
+	if _, err := validate(); err != nil {
+		gs.logger.Warnf("validation errors use struct field names: %s", err.Error())
+	}
+
+	switch gs.DuplicateRoutes {
+	case "", DuplicateRoutesFail, DuplicateRoutesWarn:
+	default:
+		return fmt.Errorf("invalid gin duplicate routes: %s", gs.DuplicateRoutes)
+	}
+
+	if err := gs.configureListen(); err != nil {
+		return err
+	}
+
+	if err := gs.configureClientIP(); err != nil {
+		return err
+	}
+
+	if gs.CORSEnabled {
+		if err := gs.configureCORS(); err != nil {
+			return err
+		}
+		// before everything else so preflight requests are answered right away
+		gs.router.Use(middleware.CORS(gs.CORS, gs.corsOverrides))
+	}
+
 	if !gs.GinNoDefault {
+		// otel middleware
+		gs.router.Use(otelgin.Middleware(gs.name))
+		// request id middleware, after otel to tag the span
+		gs.router.Use(middleware.RequestID())
+		// panic logger middleware, after otel and request id to record them
+		gs.router.Use(middleware.PanicLogger(gs.panicNotifiers...))
+
 		if !ginNoLogger {
-			gs.router.Use(gin.Logger())
+			if err := gs.configureAccessLog(); err != nil {
+				return err
+			}
+			// after request id and otel to log their ids
+			gs.router.Use(middleware.AccessLog(gs.AccessLog))
 		}
+	}
 
-		// recovery middleware
-		// gs.router.Use(gin.Recovery())
+	if !gs.MetricsDisabled {
+		if err := gs.configureMetrics(); err != nil {
+			return err
+		}
+		// before the timeout so timed out requests are recorded
+		gs.router.Use(middleware.Metrics(gs.MetricsBuckets))
+	}
 
-		// panic logger middleware
-		gs.router.Use(middleware.PanicLogger())
-		// otel middleware
-		gs.router.Use(otelgin.Middleware(gs.name))
+	if signer := sdkcm.NewRequesterSigner(gs.RequesterSecret); signer != nil {
+		// before the rate limit so the propagated requesters are limited by requester
+		gs.router.Use(middleware.PropagatedRequester(signer))
 	}
 
-	gs.svr = &myHttpServer{
-		Server: http.Server{
-			Handler: gs.router,
-		},
+	if gs.LocaleEnabled {
+		if err := gs.configureLocale(); err != nil {
+			return err
+		}
+		// before the rate limit and the body limit so their errors are translated
+		gs.router.Use(middleware.Locale(gs.Locale))
+	}
+
+	// after the metrics and the locale so the 503 responses are recorded and translated
+	if err := gs.configureMaintenance(); err != nil {
+		return err
+	}
+
+	rateLimit, err := gs.configureRateLimit()
+	if err != nil {
+		return err
+	}
+	if rateLimit != nil {
+		// after the metrics so rejected requests are recorded
+		gs.router.Use(middleware.RateLimit(*rateLimit))
+	}
+
+	if gs.AuditEnabled {
+		if err := gs.configureAudit(); err != nil {
+			return err
+		}
+		// before the body limit so rejected bodies are audited too
+		gs.router.Use(middleware.Audit(gs.Audit))
 	}
 
+	if gs.MaxBodyBytes < 0 || gs.MultipartMemory < 0 {
+		return fmt.Errorf("invalid gin body limits: max body bytes %d and multipart memory %d must not be negative", gs.MaxBodyBytes, gs.MultipartMemory)
+	}
+	gs.router.MaxMultipartMemory = gs.MultipartMemory
+	if gs.MaxBodyBytes > 0 {
+		// before the handlers bind, so bodies are never read past the limit
+		gs.router.Use(middleware.MaxBodySize(gs.MaxBodyBytes))
+	}
+
+	if gs.CompressionEnabled {
+		if err := gs.configureCompression(); err != nil {
+			return err
+		}
+		// before the timeout so timeout responses are compressed too
+		gs.router.Use(middleware.Compression(gs.Compression))
+	}
+
+	if gs.ETagEnabled {
+		if err := gs.ETag.Validate(); err != nil {
+			return fmt.Errorf("invalid gin etag config: %w", err)
+		}
+		// after the compression so the etag is of the uncompressed body
+		gs.router.Use(middleware.ETag(gs.ETag))
+	}
+
+	if gs.RequestTimeout > 0 {
+		// routes opt out with middleware.SkipTimeout()
+		gs.router.Use(middleware.Timeout(gs.RequestTimeout))
+	}
+
+	if !gs.admin.isEnabled() {
+		gs.excludeFromOpenAPI(func() { gs.registerPublicEndpoints(gs.router) })
+	}
+
+	if err := gs.configureStatic(); err != nil {
+		return err
+	}
+
+	if err := gs.configureIdempotency(); err != nil {
+		return err
+	}
+
+	if err := gs.configureAPIKeys(); err != nil {
+		return err
+	}
+
+	if err := gs.configureResponseCache(); err != nil {
+		return err
+	}
+
+	return gs.configureOpenAPI()
+}
+
+func (gs *ginService) configureCORS() error {
+	if gs.listFlagChanged(&gs.corsOrigins) {
+		gs.CORS.AllowOrigins = splitList(gs.corsOrigins)
+	}
+	if gs.listFlagChanged(&gs.corsMethods) {
+		gs.CORS.AllowMethods = splitList(gs.corsMethods)
+	}
+	if gs.listFlagChanged(&gs.corsHeaders) {
+		gs.CORS.AllowHeaders = splitList(gs.corsHeaders)
+	}
+
+	if err := gs.CORS.Validate(); err != nil {
+		return fmt.Errorf("invalid gin cors config: %w", err)
+	}
+
+	for prefix, cfg := range gs.corsOverrides {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid gin cors config of %s: %w", prefix, err)
+		}
+	}
+
+	return nil
+}
+
+// configureClientIP applies the trusted proxies and remote ip headers,
+// which middleware.ClientIP relies on
+func (gs *ginService) configureClientIP() error {
+	if gs.listFlagChanged(&gs.trustedProxies) {
+		gs.TrustedProxies = splitList(gs.trustedProxies)
+	}
+	if gs.listFlagChanged(&gs.remoteIPHeaders) {
+		gs.RemoteIPHeaders = splitList(gs.remoteIPHeaders)
+	}
+
+	// gin errors don't tell which entry is invalid
+	if _, err := middleware.ParseCIDRs(strings.Join(gs.TrustedProxies, ",")); err != nil {
+		return fmt.Errorf("invalid gin trusted proxies: %w", err)
+	}
+
+	if err := gs.router.SetTrustedProxies(gs.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid gin trusted proxies: %w", err)
+	}
+
+	gs.router.ForwardedByClientIP = gs.ForwardedByClientIP
+	if len(gs.RemoteIPHeaders) > 0 {
+		gs.router.RemoteIPHeaders = gs.RemoteIPHeaders
+	}
+
+	return nil
+}
+
+func (gs *ginService) configureWebsocket() error {
+	if gs.listFlagChanged(&gs.wsOrigins) {
+		gs.Websocket.AllowedOrigins = splitList(gs.wsOrigins)
+	}
+
+	if err := gs.Websocket.Validate(); err != nil {
+		return fmt.Errorf("invalid gin websocket config: %w", err)
+	}
+
+	gs.ws = websocket.NewServer(gs.Websocket)
+	return nil
+}
+
+// configureStatic serves the static files for the paths without route
+func (gs *ginService) configureStatic() error {
+	fsys := gs.staticFS
+	if fsys == nil && gs.StaticDir != "" {
+		info, err := os.Stat(gs.StaticDir)
+		if err != nil {
+			return fmt.Errorf("invalid gin static dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("invalid gin static dir: %s is not a directory", gs.StaticDir)
+		}
+		fsys = os.DirFS(gs.StaticDir)
+	}
+
+	if fsys != nil {
+		gs.router.NoRoute(middleware.Static(fsys, gs.Static))
+	}
+	return nil
+}
+
+func (gs *ginService) configureCompression() error {
+	if gs.listFlagChanged(&gs.compressionExcludedTypes) {
+		gs.Compression.ExcludedContentTypes = splitList(gs.compressionExcludedTypes)
+	}
+	if gs.listFlagChanged(&gs.compressionExcludedPaths) {
+		gs.Compression.ExcludedPaths = splitList(gs.compressionExcludedPaths)
+	}
+
+	if err := gs.Compression.Validate(); err != nil {
+		return fmt.Errorf("invalid gin compression config: %w", err)
+	}
 	return nil
 }
 
+func (gs *ginService) configureAccessLog() error {
+	if gs.listFlagChanged(&gs.accessLogSkipPaths) {
+		gs.AccessLog.SkipPaths = splitList(gs.accessLogSkipPaths)
+	}
+
+	if err := gs.AccessLog.Validate(); err != nil {
+		return fmt.Errorf("invalid gin access log config: %w", err)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func formatBindAddr(s string, p int) string {
 	if strings.Contains(s, ":") && !strings.Contains(s, "[") {
 		s = "[" + s + "]"
@@ -113,56 +711,126 @@ func formatBindAddr(s string, p int) string {
 	return fmt.Sprintf("%s:%d", s, p)
 }
 
+// Enabled returns whether Run listens: handlers or static files are added
+func (gs *ginService) Enabled() bool {
+	return !gs.disabled && (gs.isEnabled || gs.StaticDir != "")
+}
+
 func (gs *ginService) Run() error {
-	if !gs.isEnabled {
+	if !gs.Enabled() {
+		gs.listeningOnce.Do(func() { close(gs.listening) })
 		return nil
 	}
 
+	done := make(chan struct{})
+	gs.mu.Lock()
+	gs.runDone = done
+	gs.mu.Unlock()
+	defer close(done)
+
 	if err := gs.Configure(); err != nil {
 		return err
 	}
 
-	for _, hdl := range gs.handlers {
-		hdl(gs.router)
+	if err := gs.registerRoutes(); err != nil {
+		return err
 	}
 
-	addr := formatBindAddr(gs.BindAddr, gs.Config.Port)
-	gs.logger.Debugf("start listen tcp %s...", addr)
-	lis, err := net.Listen("tcp", addr)
+	gs.logger.Debugf("start listen %s...", gs.URI())
+	lis, inherited, err := gs.listen(gs.Config)
 	if err != nil {
-		gs.logger.Fatalf("failed to listen: %v", err)
+		// Start stops the started components and returns it
+		return fmt.Errorf("http server failed to listen on %s: %w", gs.URI(), err)
 	}
 
+	errs := make(chan error, 1)
+	gs.mu.Lock()
 	gs.Config.Port = getPort(lis)
+	gs.lis, gs.lisAddr, gs.lisInherited, gs.serveErrs = lis, listenAddr(gs.Config), inherited, errs
+	gs.mu.Unlock()
 
-	gs.logger.Infof("listen on %s...", lis.Addr().String())
+	if inherited {
+		gs.logger.Infof("listen on %s passed by systemd...", addrURI(lis.Addr()))
+	} else {
+		gs.logger.Infof("listen on %s...", addrURI(lis.Addr()))
+	}
+	gs.listeningOnce.Do(func() { close(gs.listening) })
 
-	// Handle SIGINT (CTRL+C) gracefully.
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
-	// Set up OpenTelemetry.
-	otelShutdown, err := otel.SetupOTelSDK(ctx, gs.Name(), gs.Version())
-	if err != nil {
+	// Start the server
+	gs.serve(lis)
+
+	if err := <-errs; err != http.ErrServerClosed {
 		return err
 	}
-	// Handle shutdown properly so nothing leaks.
-	defer func() {
-		err = errors.Join(err, otelShutdown(context.Background()))
-	}()
+	return nil
+}
 
-	// Start the server
-	err = gs.svr.Serve(lis)
+// Handler configures the server and registers the routes like Run but doesn't
+// listen, the handler is served in memory, e.g. with httptest. Call it instead of Run.
+func (gs *ginService) Handler() (http.Handler, error) {
+	if err := gs.Configure(); err != nil {
+		return nil, err
+	}
+	if err := gs.registerRoutes(); err != nil {
+		return nil, err
+	}
+	return gs.svr.Handler, nil
+}
 
-	if err != nil && err == http.ErrServerClosed {
-		return nil
+// registerRoutes registers the ops endpoints behind the ops token, unless
+// the admin server serves them, and the handlers on the router
+func (gs *ginService) registerRoutes() error {
+	if !gs.admin.isEnabled() && gs.OpsToken != "" {
+		var err error
+		gs.excludeFromOpenAPI(func() { err = gs.registerProtectedOps() })
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := gs.registerHandlers(gs.handlers); err != nil {
+		return err
 	}
-	return err
+	gs.logRoutes()
+	return nil
 }
 
+// serve serves lis in the background, its error ends Run unless Reload
+// replaced it by another listener
+func (gs *ginService) serve(lis net.Listener) {
+	gs.mu.Lock()
+	svr, errs := gs.svr, gs.serveErrs
+	gs.mu.Unlock()
+
+	go func() {
+		err := svr.Serve(lis)
+
+		gs.mu.Lock()
+		replaced := gs.lis != lis
+		gs.mu.Unlock()
+		if replaced && err != http.ErrServerClosed {
+			return
+		}
+
+		select {
+		case errs <- err:
+		default:
+		}
+	}()
+}
+
+// getPort returns 0 for listeners which are not tcp
 func getPort(lis net.Listener) int {
-	addr := lis.Addr()
-	tcp, _ := net.ResolveTCPAddr(addr.Network(), addr.String())
-	return tcp.Port
+	if tcp, ok := lis.Addr().(*net.TCPAddr); ok {
+		return tcp.Port
+	}
+	return 0
+}
+
+// Listening is closed once the server is listening and Port is final,
+// or right away when the server is disabled
+func (gs *ginService) Listening() <-chan struct{} {
+	return gs.listening
 }
 
 func (gs *ginService) Port() int {
@@ -171,37 +839,278 @@ func (gs *ginService) Port() int {
 	return gs.Config.Port
 }
 
+// Stop drains in-flight requests at most the shutdown timeout, then closes
+// the remaining connections. It sends false if connections are closed.
 func (gs *ginService) Stop() <-chan bool {
 	c := make(chan bool)
 
 	go func() {
+		drained := true
 		if gs.svr != nil {
-			_ = gs.svr.Shutdown(context.Background())
+			drained = gs.shutdown()
+		}
+		gs.closeMaintenanceSync()
+		gs.removeSocket()
+
+		gs.mu.Lock()
+		done := gs.runDone
+		gs.mu.Unlock()
+
+		// wait for Run to return
+		if done != nil {
+			<-done
 		}
-		c <- true
+		c <- drained
 	}()
 	return c
 }
 
+func (gs *ginService) shutdown() bool {
+	gs.draining.Store(true)
+
+	if gs.ShutdownDelay > 0 {
+		gs.logger.Infof("draining, stop serving in %s...", gs.ShutdownDelay)
+		time.Sleep(gs.ShutdownDelay)
+	}
+
+	// streams must end for the http server to drain
+	gs.stoppingOnce.Do(func() { close(gs.stopping) })
+
+	// upgraded connections are not tracked by the http server
+	wsDone := make(chan bool, 1)
+	go func() { wsDone <- shutdownWebsockets(gs.ws, gs.ShutdownTimeout) }()
+
+	drained := shutdownServer(gs.svr, gs.ShutdownTimeout, gs.logger)
+	return <-wsDone && drained
+}
+
+// shutdownWebsockets sends close frames and waits at most timeout for the
+// websocket handlers, then closes the connections and returns false
+func shutdownWebsockets(ws *websocket.Server, timeout time.Duration) bool {
+	if ws == nil {
+		return true
+	}
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return ws.Shutdown(ctx) == nil
+}
+
+// shutdownServer waits at most timeout for in-flight requests,
+// then closes the remaining connections and returns false
+func shutdownServer(svr *myHttpServer, timeout time.Duration, log logger.Logger) bool {
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := svr.Shutdown(ctx); err == nil {
+		log.Info("http server drained")
+		return true
+	}
+
+	dropped := svr.openConns()
+	_ = svr.Close()
+	log.Warnf("http server didn't drain in %s, dropped %d connections", timeout, dropped)
+
+	return false
+}
+
 func (gs *ginService) URI() string {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	if gs.lis != nil && (gs.lisInherited || gs.ListenNetwork == ListenNetworkUnix) {
+		return addrURI(gs.lis.Addr())
+	}
+	if gs.ListenNetwork == ListenNetworkUnix {
+		return "unix:" + gs.UnixSocketPath
+	}
 	return formatBindAddr(gs.BindAddr, gs.Config.Port)
 }
 
+// AddHandler adds routes and enables the server, it only starts
+// when at least one handler is added
 func (gs *ginService) AddHandler(hdl func(*gin.Engine)) {
+	if gs.disabled {
+		logger.GetCurrent().GetLogger(gs.prefix).Warn("http server is disabled, handler is ignored")
+		return
+	}
+
 	gs.isEnabled = true
 	gs.handlers = append(gs.handlers, hdl)
 }
 
-func (gs *ginService) Reload(config Config) error {
-	gs.Config = config
-	<-gs.Stop()
-	return gs.Run()
+// AddWebsocketHandler upgrades the GET requests of path to websockets served by
+// handler. The request timeout doesn't apply, and Stop sends close frames and
+// waits for the handlers, which must return once ctx is cancelled.
+func (gs *ginService) AddWebsocketHandler(path string, handler websocket.Handler) {
+	gs.AddHandler(func(engine *gin.Engine) {
+		engine.GET(path, gs.ws.Handler(handler))
+	})
+}
+
+// SetStaticFS serves fsys under prefix for the paths without route and enables
+// the server, e.g. an embed.FS for single binary deploys:
+//
+//	//go:embed dist
+//	var dist embed.FS
+//	web, _ := fs.Sub(dist, "dist")
+//	gs.SetStaticFS(web, "/")
+//
+// The gin-spa-mode flag serves index.html for the client side routes.
+func (gs *ginService) SetStaticFS(fsys fs.FS, prefix string) {
+	if gs.disabled {
+		logger.GetCurrent().GetLogger(gs.prefix).Warn("http server is disabled, static files are ignored")
+		return
+	}
+
+	gs.isEnabled = true
+	gs.staticFS = fsys
+	gs.Static.Prefix = prefix
+}
+
+// Disable makes the server headless: it never listens and handlers are ignored.
+// The ops endpoints are still served by the admin server when it's enabled.
+func (gs *ginService) Disable() {
+	gs.disabled = true
+	gs.isEnabled = false
+	gs.handlers = nil
+}
+
+// AddOpsHandler adds handlers for operational endpoints (config, health...).
+// Unlike AddHandler, it doesn't enable the server.
+func (gs *ginService) AddOpsHandler(hdl func(*gin.Engine)) {
+	gs.opsHandlers = append(gs.opsHandlers, hdl)
+}
+
+// SetAdminServer moves the ops handlers and the health, version and metrics
+// endpoints to admin when it's enabled
+func (gs *ginService) SetAdminServer(admin *adminService) {
+	gs.admin = admin
+	admin.AddHandler(gs.registerOpsHandlers)
+}
+
+func (gs *ginService) registerOpsHandlers(engine *gin.Engine) {
+	gs.registerPublicEndpoints(engine)
+	gs.registerOpsEndpoints(engine)
+}
+
+// registerProtectedOps serves the ops endpoints on the router, requests
+// without the ops token are rejected with 401
+func (gs *ginService) registerProtectedOps() error {
+	ops := gin.New()
+	ops.Use(opsAuth(gs.OpsToken))
+	gs.registerOpsEndpoints(ops)
+
+	handler := gin.WrapH(ops)
+	return addRoutes(gs.router, func(engine *gin.Engine) {
+		for _, r := range ops.Routes() {
+			engine.Handle(r.Method, r.Path, handler)
+		}
+	})
+}
+
+// registerPublicEndpoints registers the health and version endpoints,
+// the only ones the public router serves without the ops token
+func (gs *ginService) registerPublicEndpoints(engine *gin.Engine) {
+	if !gs.HealthDisabled {
+		gs.registerHealthHandlers(engine)
+	}
+
+	if !gs.VersionDisabled {
+		gs.registerVersionHandler(engine)
+	}
+}
+
+// registerOpsEndpoints registers the routes, maintenance and metrics
+// endpoints and the ops handlers
+func (gs *ginService) registerOpsEndpoints(engine *gin.Engine) {
+	if !gs.RoutesDisabled {
+		gs.registerRoutesHandler(engine)
+	}
+
+	gs.registerMaintenanceHandlers(engine)
+
+	gs.registerMetricsHandler(engine)
+
+	for _, hdl := range gs.opsHandlers {
+		hdl(engine)
+	}
+}
+
+// opsAuth rejects the requests without the bearer token
+func opsAuth(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid ops token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AddCORSOverride uses cfg instead of the flag config for requests
+// whose path starts with pathPrefix, e.g. the prefix of a route group.
+// It only takes effect when CORS is enabled.
+func (gs *ginService) AddCORSOverride(pathPrefix string, cfg middleware.CORSConfig) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if gs.corsOverrides == nil {
+		gs.corsOverrides = map[string]middleware.CORSConfig{}
+	}
+	gs.corsOverrides[pathPrefix] = cfg
+}
+
+// AddPanicNotifier tells n about panics of handlers, call it before Run
+func (gs *ginService) AddPanicNotifier(n middleware.PanicNotifier) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.panicNotifiers = append(gs.panicNotifiers, n)
 }
 
 func (gs *ginService) GetConfig() Config {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
 	return gs.Config
 }
 
+// DumpConfig returns the config by its json names for the startup banner,
+// the service redacts the secret values
+func (gs *ginService) DumpConfig() map[string]interface{} {
+	b, err := json.Marshal(gs.GetConfig())
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	var config map[string]interface{}
+	_ = json.Unmarshal(b, &config)
+	return config
+}
+
 func (gs *ginService) IsRunning() bool {
 	return gs.svr != nil
 }
+
+// listFlagChanged reports whether the comma separated flag s is set and
+// changed since the last Configure, so the lists of a reloaded Config are kept
+func (gs *ginService) listFlagChanged(s *string) bool {
+	if *s == "" {
+		return false
+	}
+	if gs.parsedFlags == nil {
+		gs.parsedFlags = map[*string]string{}
+	}
+	if last, ok := gs.parsedFlags[s]; ok && last == *s {
+		return false
+	}
+	gs.parsedFlags[s] = *s
+	return true
+}
@@ -0,0 +1,105 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultHealthCheckTimeout = 3 * time.Second
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type healthFailure struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// AddHealthCheck adds a check to the readiness endpoint, usually
+// the HealthCheck method of a plugin
+func (gs *ginService) AddHealthCheck(name string, check func(ctx context.Context) error) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.healthChecks = append(gs.healthChecks, healthCheck{name: name, check: check})
+}
+
+func (gs *ginService) registerHealthHandlers(engine *gin.Engine) {
+	engine.GET("/healthz", gs.livenessHandler)
+	engine.GET("/readyz", gs.readinessHandler)
+}
+
+// livenessHandler only confirms the server is serving
+func (gs *ginService) livenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readinessHandler runs all health checks at the same time,
+// each of them is bounded by the health check timeout.
+// It fails while the server is draining.
+func (gs *ginService) readinessHandler(c *gin.Context) {
+	if gs.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+
+	gs.mu.Lock()
+	checks := append([]healthCheck{}, gs.healthChecks...)
+	gs.mu.Unlock()
+
+	timeout := gs.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []healthFailure
+	)
+
+	for _, hc := range checks {
+		wg.Add(1)
+		go func(hc healthCheck) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			defer cancel()
+
+			if err := runHealthCheck(ctx, hc.check); err != nil {
+				mu.Lock()
+				failures = append(failures, healthFailure{Name: hc.name, Error: err.Error()})
+				mu.Unlock()
+			}
+		}(hc)
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Name < failures[j].Name })
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "failures": failures})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// runHealthCheck returns when the check is done or ctx is expired,
+// so a check ignoring its context can't hang the probe
+func runHealthCheck(ctx context.Context, check func(ctx context.Context) error) error {
+	errChan := make(chan error, 1)
+	go func() { errChan <- check(ctx) }()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
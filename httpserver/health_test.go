@@ -0,0 +1,29 @@
+package httpserver
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func newTestGinService(t *testing.T, config Config, setups ...func(gs *ginService)) *ginService {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.Config = config
+	for _, setup := range setups {
+		setup(gs)
+	}
+	assert.Nil(t, gs.Configure(), "must be nil")
+
+	return gs
+}
+
+func performRequest(gs *ginService, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	gs.router.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,54 @@
+package httpserver
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// bounds of the HTTP/2 frame size, RFC 9113 section 4.2
+const (
+	minHTTP2FrameSize = 1 << 14
+	maxHTTP2FrameSize = 1<<24 - 1
+)
+
+// HTTP2Config tunes the HTTP/2 connections, h2c and TLS ones, 0 => the defaults of x/net/http2
+type HTTP2Config struct {
+	MaxConcurrentStreams uint `json:"max_concurrent_streams"`
+	MaxReadFrameSize     uint `json:"max_read_frame_size"`
+	// idle connections are closed after it, 0 => gin-idle-timeout
+	IdleTimeout time.Duration `json:"idle_timeout"`
+}
+
+// configureHTTP2 sets up HTTP/2 of the http server, and serves HTTP/2 without
+// TLS with h2c, prior knowledge and upgraded requests
+func (gs *ginService) configureHTTP2() error {
+	cfg := gs.HTTP2
+	if cfg.MaxReadFrameSize != 0 && (cfg.MaxReadFrameSize < minHTTP2FrameSize || cfg.MaxReadFrameSize > maxHTTP2FrameSize) {
+		return fmt.Errorf("invalid gin http2 max frame size: %d, it must be between %d and %d", cfg.MaxReadFrameSize, minHTTP2FrameSize, maxHTTP2FrameSize)
+	}
+	if cfg.MaxConcurrentStreams > math.MaxUint32 {
+		return fmt.Errorf("invalid gin http2 max concurrent streams: %d", cfg.MaxConcurrentStreams)
+	}
+	if cfg.IdleTimeout < 0 {
+		return fmt.Errorf("invalid gin http2 idle timeout: %s must not be negative", cfg.IdleTimeout)
+	}
+
+	h2s := &http2.Server{
+		MaxConcurrentStreams: uint32(cfg.MaxConcurrentStreams),
+		MaxReadFrameSize:     uint32(cfg.MaxReadFrameSize),
+		IdleTimeout:          cfg.IdleTimeout,
+	}
+	// also makes Shutdown send GOAWAY to the HTTP/2 connections
+	if err := http2.ConfigureServer(&gs.svr.Server, h2s); err != nil {
+		return fmt.Errorf("invalid gin http2 config: %w", err)
+	}
+
+	if gs.H2CEnabled {
+		gs.svr.Handler = h2c.NewHandler(gs.handler, h2s)
+	}
+	return nil
+}
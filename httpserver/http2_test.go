@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"golang.org/x/net/http2"
+)
+
+func h2cClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+func TestH2C(t *testing.T) {
+	logger.InitServLogger(false)
+
+	next := make(chan struct{}, 1)
+	gs := New("test")
+	gs.H2CEnabled = true
+	gs.HTTP2.MaxConcurrentStreams = 10
+	gs.AddHandler(func(engine *gin.Engine) {
+		engine.GET("/proto", func(c *gin.Context) { c.String(http.StatusOK, c.Request.Proto) })
+		engine.GET("/stream", func(c *gin.Context) {
+			for i := 0; i < 2; i++ {
+				<-next
+				_, _ = fmt.Fprintf(c.Writer, "chunk %d\n", i)
+				c.Writer.Flush()
+			}
+		})
+	})
+	runErr := make(chan error, 1)
+	go func() { runErr <- gs.Run() }()
+	<-gs.Listening()
+
+	client := h2cClient()
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/proto", gs.Port()))
+	if assert.Nil(t, err, "must be nil") {
+		body, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		resp.Body.Close()
+		assert.Equal(t, "HTTP/2.0", resp.Proto, "should be equal")
+		assert.Equal(t, "HTTP/2.0", body, "the handlers should see HTTP/2")
+	}
+
+	// HTTP/1.1 is still served
+	status, body := getBody(t, gs.Port(), "/proto")
+	assert.Equal(t, http.StatusOK, status, "should be equal")
+	assert.Equal(t, "HTTP/1.1", body, "should be equal")
+
+	// each chunk is received before the next one is written
+	next <- struct{}{}
+	resp, err = client.Get(fmt.Sprintf("http://localhost:%d/stream", gs.Port()))
+	if assert.Nil(t, err, "must be nil") {
+		reader := bufio.NewReader(resp.Body)
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err, "must be nil")
+		assert.Equal(t, "chunk 0\n", line, "should be equal")
+
+		next <- struct{}{}
+		line, err = reader.ReadString('\n')
+		assert.Nil(t, err, "must be nil")
+		assert.Equal(t, "chunk 1\n", line, "should be equal")
+		resp.Body.Close()
+	}
+
+	assert.True(t, <-gs.Stop(), "should be drained")
+	assert.Nil(t, <-runErr, "must be nil")
+}
+
+func TestHTTP2Config(t *testing.T) {
+	gs := New("test")
+	gs.HTTP2.MaxReadFrameSize = 1024
+	assert.NotNil(t, gs.Configure(), "should be an error")
+
+	gs.HTTP2.MaxReadFrameSize = 1 << 20
+	assert.Nil(t, gs.Configure(), "must be nil")
+	_, ok := gs.svr.Handler.(*routerHandler)
+	assert.True(t, ok, "h2c should be disabled by default")
+}
@@ -3,15 +3,19 @@ package httpserver
 import (
 	"net"
 	"net/http"
+	"sync"
 	"time"
 )
 
+const defaultKeepAlivePeriod = 3 * time.Minute
+
 // tcpKeepAliveListener sets TCP keep-alive timeouts on accepted
 // connections. It's used by ListenAndServe and ListenAndServeTLS so
 // dead TCP connections (e.g. closing laptop mid-download) eventually
 // go away.
 type tcpKeepAliveListener struct {
 	*net.TCPListener
+	period time.Duration
 }
 
 func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
@@ -20,18 +24,58 @@ func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
 		return
 	}
 	_ = tc.SetKeepAlive(true)
-	_ = tc.SetKeepAlivePeriod(3 * time.Minute)
+	_ = tc.SetKeepAlivePeriod(ln.period)
 	return tc, nil
 }
 
 type myHttpServer struct {
 	http.Server
+	// 0 disables TCP keep-alive
+	keepAlivePeriod time.Duration
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newHttpServer(handler http.Handler) *myHttpServer {
+	srv := &myHttpServer{conns: map[net.Conn]struct{}{}, keepAlivePeriod: defaultKeepAlivePeriod}
+	srv.Handler = handler
+	srv.ConnState = srv.trackConn
+	return srv
+}
+
+// trackConn keeps the open connections, so the ones cut by Close can be counted
+func (srv *myHttpServer) trackConn(conn net.Conn, state http.ConnState) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	switch state {
+	case http.StateNew:
+		srv.conns[conn] = struct{}{}
+	case http.StateHijacked, http.StateClosed:
+		delete(srv.conns, conn)
+	}
+}
+
+func (srv *myHttpServer) openConns() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.conns)
+}
+
+// listener sets up keep-alive on accepted tcp connections, unless it's disabled
+func (srv *myHttpServer) listener(lis net.Listener) net.Listener {
+	tcp, ok := lis.(*net.TCPListener)
+	if !ok || srv.keepAlivePeriod <= 0 {
+		return lis
+	}
+	return tcpKeepAliveListener{TCPListener: tcp, period: srv.keepAlivePeriod}
 }
 
 func (srv *myHttpServer) Serve(lis net.Listener) error {
-	return srv.Server.Serve(tcpKeepAliveListener{lis.(*net.TCPListener)})
+	return srv.Server.Serve(srv.listener(lis))
 }
 
 func (srv *myHttpServer) ServeTLS(lis net.Listener, certFile, keyFile string) error {
-	return srv.Server.ServeTLS(tcpKeepAliveListener{lis.(*net.TCPListener)}, certFile, keyFile)
+	return srv.Server.ServeTLS(srv.listener(lis), certFile, keyFile)
 }
@@ -0,0 +1,41 @@
+package httpserver
+
+import (
+	"fmt"
+
+	"github.com/taimaifika/go-sdk/i18n"
+)
+
+// configureLocale loads the catalogs of gin-i18n-dir unless SetI18nBundle is
+// called, the bundle becomes the default one of i18n.T
+func (gs *ginService) configureLocale() error {
+	if gs.listFlagChanged(&gs.localeSources) {
+		gs.Locale.Sources = splitList(gs.localeSources)
+	}
+	if err := gs.Locale.Validate(); err != nil {
+		return fmt.Errorf("invalid gin locale config: %w", err)
+	}
+
+	if gs.Locale.Bundle == nil {
+		if gs.I18nDefaultLocale == "" {
+			gs.I18nDefaultLocale = i18n.DefaultLocale
+		}
+		bundle := i18n.NewBundle(gs.I18nDefaultLocale)
+		if gs.I18nDir != "" {
+			if err := bundle.LoadDir(gs.I18nDir); err != nil {
+				return fmt.Errorf("invalid gin i18n catalogs: %w", err)
+			}
+		}
+		gs.Locale.Bundle = bundle
+	}
+	i18n.SetDefault(gs.Locale.Bundle)
+	return nil
+}
+
+// SetI18nBundle replaces the catalogs of gin-i18n-dir, e.g. with ones embedded
+// in the binary by Bundle.LoadFS
+func (gs *ginService) SetI18nBundle(b *i18n.Bundle) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.Locale.Bundle = b
+}
@@ -0,0 +1,51 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/i18n"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+func TestLocaleI18nDir(t *testing.T) {
+	logger.InitServLogger(false)
+	defer i18n.SetDefault(i18n.Default())
+
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "en.toml"), []byte(`[errors]
+order_not_found = "order not found"`), 0o600), "must be nil")
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "vi.json"), []byte(`{"errors": {"order_not_found": "không tìm thấy đơn hàng"}}`), 0o600), "must be nil")
+
+	gs := New("test")
+	gs.LocaleEnabled = true
+	gs.localeSources = "header"
+	gs.I18nDir = dir
+	assert.Nil(t, gs.Configure(), "must be nil")
+	assert.Equal(t, []string{"en", "vi"}, i18n.Default().Locales(), "should be the default bundle")
+
+	gs.router.Use(middleware.ErrorHandler())
+	gs.router.GET("/orders/:id", func(c *gin.Context) {
+		panic(sdkcm.ErrEntityNotFound("Order", nil).WithMessageKey("errors.order_not_found"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set("Accept-Language", "vi-VN,vi;q=0.9")
+	w := httptest.NewRecorder()
+	gs.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "should be equal")
+	assert.Contains(t, w.Body.String(), "không tìm thấy đơn hàng", "should be translated")
+
+	gs = New("test")
+	gs.LocaleEnabled = true
+	gs.localeSources = "header"
+	gs.I18nDir = filepath.Join(dir, "missing")
+	assert.NotNil(t, gs.Configure(), "should be an error")
+}
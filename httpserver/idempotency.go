@@ -0,0 +1,47 @@
+package httpserver
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+// configureIdempotency builds the middleware returned by Idempotency from the flags
+func (gs *ginService) configureIdempotency() error {
+	if gs.Idempotency.TTL < 0 || gs.Idempotency.LockTTL < 0 || gs.Idempotency.MaxBodySize < 0 {
+		return fmt.Errorf("invalid gin idempotency config: ttl, lock ttl and max body must not be negative")
+	}
+
+	cfg := gs.Idempotency
+	if cfg.TTL == 0 {
+		cfg.TTL = defaultIdempotencyTTL
+	}
+	cfg.Store = gs.idempotencyStore
+	if cfg.Store == nil {
+		cfg.Store = middleware.NewMemoryIdempotencyStore()
+	}
+
+	gs.idempotency = middleware.Idempotency(cfg)
+	return nil
+}
+
+// SetIdempotencyStore replaces the in-memory store of Idempotency,
+// e.g. with sdkredis.NewRedisIdempotencyStore to share keys across replicas
+func (gs *ginService) SetIdempotencyStore(s middleware.IdempotencyStore) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.idempotencyStore = s
+}
+
+// IdempotentRoutes replays the responses of requests with an Idempotency-Key
+// header, for the route groups which need it:
+//
+//	payments := engine.Group("/payments", middleware.RequiredAuth(...), gs.IdempotentRoutes())
+//
+// It uses the gin-idempotency-* flags and the store of SetIdempotencyStore.
+func (gs *ginService) IdempotentRoutes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		gs.idempotency(c)
+	}
+}
@@ -0,0 +1,165 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ListenNetworkTCP  = "tcp"
+	ListenNetworkUnix = "unix"
+
+	defaultUnixSocketMode = "0660"
+	// first file descriptor passed by systemd socket activation
+	listenFdsStart = 3
+	// max time to check whether a server still listens on a unix socket
+	staleSocketTimeout = time.Second
+)
+
+// configureListen validates the listen flags, the mode is parsed from octal
+func (gs *ginService) configureListen() error {
+	switch gs.ListenNetwork {
+	case "", ListenNetworkTCP:
+	case ListenNetworkUnix:
+		if gs.UnixSocketPath == "" {
+			return fmt.Errorf("invalid gin listen config: %s-unix-socket-path is required to listen on a unix socket", gs.prefix)
+		}
+	default:
+		return fmt.Errorf("invalid gin listen network: %s", gs.ListenNetwork)
+	}
+
+	if gs.unixSocketMode != "" {
+		mode, err := strconv.ParseUint(gs.unixSocketMode, 8, 32)
+		if err != nil || mode > 0o777 {
+			return fmt.Errorf("invalid gin unix socket mode: %s", gs.unixSocketMode)
+		}
+		gs.UnixSocketMode = fs.FileMode(mode)
+	}
+	return nil
+}
+
+// listen returns the socket passed by systemd for the server if any,
+// else a new listener of the config
+func (gs *ginService) listen(config Config) (lis net.Listener, inherited bool, err error) {
+	if fd, name, ok := systemdFd(gs.prefix); ok {
+		f := os.NewFile(uintptr(fd), name)
+		lis, err := net.FileListener(f)
+		// the listener has its own copy
+		_ = f.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to use the socket %s passed by systemd: %w", name, err)
+		}
+		return lis, true, nil
+	}
+
+	lis, err = newListener(config)
+	return lis, false, err
+}
+
+// newListener listens on the network of config, a stale unix socket left by
+// a crashed instance is removed first
+func newListener(config Config) (net.Listener, error) {
+	if config.ListenNetwork != ListenNetworkUnix {
+		return net.Listen("tcp", formatBindAddr(config.BindAddr, config.Port))
+	}
+
+	path := config.UnixSocketPath
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if config.UnixSocketMode != 0 {
+		if err := os.Chmod(path, config.UnixSocketMode); err != nil {
+			_ = lis.Close()
+			return nil, fmt.Errorf("failed to set the mode of %s: %w", path, err)
+		}
+	}
+	return lis, nil
+}
+
+// removeStaleSocket removes the socket at path unless a server listens on it,
+// other files are never removed
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, staleSocketTimeout); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s is in use by another server", path)
+	}
+	return os.Remove(path)
+}
+
+// systemdFd returns the socket passed by systemd socket activation for the
+// server: the one named like its prefix with FileDescriptorName=, or the only
+// one for the default server
+func systemdFd(prefix string) (fd int, name string, ok bool) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return 0, "", false
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return 0, "", false
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < n && i < len(names); i++ {
+		if names[i] == prefix {
+			return listenFdsStart + i, names[i], true
+		}
+	}
+	if prefix == DefaultPrefix && n == 1 {
+		return listenFdsStart, "LISTEN_FDS", true
+	}
+	return 0, "", false
+}
+
+// listenAddr identifies where config listens, except the tcp port
+func listenAddr(config Config) string {
+	if config.ListenNetwork == ListenNetworkUnix {
+		return "unix:" + config.UnixSocketPath
+	}
+	return config.BindAddr
+}
+
+// addrURI returns host:port for tcp addresses, unix:path for unix sockets
+func addrURI(addr net.Addr) string {
+	if unix, ok := addr.(*net.UnixAddr); ok {
+		return "unix:" + unix.Name
+	}
+	return addr.String()
+}
+
+// removeSocket removes the unix socket created by the server, not the ones
+// passed by systemd
+func (gs *ginService) removeSocket() {
+	gs.mu.Lock()
+	lis, inherited := gs.lis, gs.lisInherited
+	gs.mu.Unlock()
+
+	if lis == nil || inherited {
+		return
+	}
+	if unix, ok := lis.Addr().(*net.UnixAddr); ok {
+		if err := os.Remove(unix.Name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			gs.logger.Warnf("failed to remove the unix socket %s: %s", unix.Name, err.Error())
+		}
+	}
+}
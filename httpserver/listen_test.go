@@ -0,0 +1,147 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestUnixSocket(t *testing.T) {
+	logger.InitServLogger(false)
+
+	path := filepath.Join(t.TempDir(), "gin.sock")
+	// a stale socket left by a crashed instance
+	stale, err := net.Listen("unix", path)
+	assert.Nil(t, err, "must be nil")
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	assert.Nil(t, stale.Close(), "must be nil")
+
+	gs := New("test")
+	gs.ListenNetwork = ListenNetworkUnix
+	gs.UnixSocketPath = path
+	gs.unixSocketMode = "0600"
+	gs.AddHandler(func(engine *gin.Engine) {
+		engine.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	})
+	runErr := make(chan error, 1)
+	go func() { runErr <- gs.Run() }()
+	<-gs.Listening()
+
+	assert.Equal(t, 0, gs.Port(), "should be equal")
+	assert.Equal(t, "unix:"+path, gs.URI(), "should be equal")
+	info, err := os.Stat(path)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, fs.FileMode(0o600), info.Mode().Perm(), "should be equal")
+
+	resp, err := unixClient(path).Get("http://unix/ping")
+	if assert.Nil(t, err, "must be nil") {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "pong", string(body), "should be equal")
+	}
+
+	// the socket is in use
+	_, err = newListener(gs.GetConfig())
+	assert.NotNil(t, err, "should be an error")
+
+	assert.True(t, <-gs.Stop(), "should be drained")
+	assert.Nil(t, <-runErr, "must be nil")
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "should be removed")
+}
+
+func TestConfigureListen(t *testing.T) {
+	gs := New("test")
+	assert.Nil(t, gs.configureListen(), "must be nil")
+
+	gs.ListenNetwork = ListenNetworkUnix
+	assert.NotNil(t, gs.configureListen(), "should require the socket path")
+
+	gs.UnixSocketPath = "/run/app.sock"
+	gs.unixSocketMode = "0666"
+	assert.Nil(t, gs.configureListen(), "must be nil")
+	assert.Equal(t, fs.FileMode(0o666), gs.UnixSocketMode, "should be equal")
+
+	gs.unixSocketMode = "rw"
+	assert.NotNil(t, gs.configureListen(), "should be an error")
+
+	gs.unixSocketMode = ""
+	gs.ListenNetwork = "udp"
+	assert.NotNil(t, gs.configureListen(), "should be an error")
+
+	// a regular file is never removed
+	file := filepath.Join(t.TempDir(), "app.sock")
+	assert.Nil(t, os.WriteFile(file, nil, 0o600), "must be nil")
+	assert.NotNil(t, removeStaleSocket(file), "should be an error")
+}
+
+func TestSystemdFd(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "app.socket")
+
+	fd, _, ok := systemdFd(DefaultPrefix)
+	assert.True(t, ok, "the only socket is for the default server")
+	assert.Equal(t, listenFdsStart, fd, "should be equal")
+	_, _, ok = systemdFd("partner")
+	assert.False(t, ok, "should need a name")
+
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv("LISTEN_FDNAMES", "gin:partner")
+	fd, name, ok := systemdFd("partner")
+	assert.True(t, ok, "should be found by name")
+	assert.Equal(t, listenFdsStart+1, fd, "should be equal")
+	assert.Equal(t, "partner", name, "should be equal")
+
+	t.Setenv("LISTEN_PID", "1")
+	_, _, ok = systemdFd(DefaultPrefix)
+	assert.False(t, ok, "should be passed to another process")
+}
+
+func TestKeepAliveListener(t *testing.T) {
+	lis, err := net.Listen("unix", filepath.Join(t.TempDir(), "gin.sock"))
+	assert.Nil(t, err, "must be nil")
+	defer lis.Close()
+
+	srv := newHttpServer(http.NotFoundHandler())
+	assert.Equal(t, lis, srv.listener(lis), "unix listeners should not be wrapped")
+	assert.Equal(t, 0, getPort(lis), "should be equal")
+}
+
+func TestListenError(t *testing.T) {
+	logger.InitServLogger(false)
+
+	busy, err := net.Listen("tcp", "localhost:0")
+	assert.Nil(t, err, "must be nil")
+	defer busy.Close()
+
+	gs := New("test")
+	gs.Config.Port = getPort(busy)
+	gs.AddHandler(registerItems)
+	err = gs.Run()
+	assert.NotNil(t, err, "should be an error")
+	assert.True(t, errors.Is(err, syscall.EADDRINUSE), "should be address in use")
+	assert.Contains(t, err.Error(), fmt.Sprintf(":%d", gs.Config.Port), "should name the address")
+	assert.True(t, <-gs.Stop(), "should stop")
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
 	"github.com/taimaifika/go-sdk/logger"
 )
 
@@ -21,7 +22,7 @@ func Logger(log logger.Logger) gin.HandlerFunc {
 		stop := time.Since(start)
 		latency := int(math.Ceil(float64(stop.Nanoseconds()) / 1000.0))
 		statusCode := c.Writer.Status()
-		clientIP := c.ClientIP()
+		clientIP := middleware.ClientIP(c)
 		clientUserAgent := c.Request.UserAgent()
 		referer := c.Request.Referer()
 		hostname, err := os.Hostname()
@@ -0,0 +1,160 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+const (
+	defaultMaintenanceRetryAfter = 5 * time.Minute
+	maintenancePath              = "/admin/maintenance"
+	// max time to load the state shared by the replicas when starting
+	maintenanceLoadTimeout = 5 * time.Second
+)
+
+type maintenanceRequest struct {
+	Enabled *bool      `json:"enabled" binding:"required"`
+	Message string     `json:"message"`
+	ETA     *time.Time `json:"eta"`
+}
+
+// configureMaintenance builds the maintenance middleware, the state is the
+// one of gin-maintenance-enabled, or the one shared by the replicas, unless
+// it's already set
+func (gs *ginService) configureMaintenance() error {
+	exempt, err := middleware.ParseCIDRs(gs.maintenanceExemptCIDRs)
+	if err != nil {
+		return fmt.Errorf("invalid gin maintenance config: %w", err)
+	}
+	gs.Maintenance.ExemptCIDRs = exempt
+	if gs.listFlagChanged(&gs.maintenanceExemptPaths) {
+		gs.Maintenance.ExemptPaths = splitList(gs.maintenanceExemptPaths)
+	}
+	if gs.Maintenance.RetryAfter < 0 {
+		return fmt.Errorf("invalid gin maintenance config: retry after %s must not be negative", gs.Maintenance.RetryAfter)
+	}
+
+	gs.mu.Lock()
+	shared := gs.maintenanceSync
+	subscribed := gs.maintenanceSub != nil
+	gs.mu.Unlock()
+
+	switch {
+	case !gs.maintenance.State().UpdatedAt.IsZero():
+		// set by SetMaintenance, or before a reload
+	case gs.MaintenanceEnabled:
+		gs.applyMaintenance(middleware.MaintenanceState{Enabled: true, Message: gs.MaintenanceMessage, UpdatedAt: time.Now()}, gs.prefix+"-maintenance-enabled")
+	case shared != nil:
+		ctx, cancel := context.WithTimeout(context.Background(), maintenanceLoadTimeout)
+		state, ok, err := shared.Load(ctx)
+		cancel()
+		if err != nil {
+			gs.logger.Warnf("failed to load the maintenance state of the replicas: %s", err.Error())
+		} else if ok {
+			gs.applyMaintenance(state, "the replicas")
+		}
+	}
+
+	if shared != nil && !subscribed {
+		sub, err := shared.Subscribe(func(state middleware.MaintenanceState) {
+			gs.applyMaintenance(state, "another replica")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to the maintenance state: %w", err)
+		}
+		gs.mu.Lock()
+		gs.maintenanceSub = sub
+		gs.mu.Unlock()
+	}
+
+	cfg := gs.Maintenance
+	// so it can be turned off when the ops endpoints are served by the router
+	cfg.ExemptPaths = append(append([]string{}, cfg.ExemptPaths...), maintenancePath)
+	gs.router.Use(middleware.MaintenanceMode(gs.maintenance, cfg))
+	return nil
+}
+
+// SetMaintenanceSync shares the maintenance state with the other replicas,
+// e.g. with sdkredis.NewMaintenanceSync. Call it before Run.
+func (gs *ginService) SetMaintenanceSync(s middleware.MaintenanceSync) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.maintenanceSync = s
+}
+
+// SetMaintenance turns the maintenance mode on or off, for every replica
+// when SetMaintenanceSync is called. eta gives the Retry-After, nil => gin-maintenance-retry-after.
+func (gs *ginService) SetMaintenance(ctx context.Context, enabled bool, message string, eta *time.Time) error {
+	state := middleware.MaintenanceState{Enabled: enabled, Message: message, ETA: eta, UpdatedAt: time.Now()}
+	gs.applyMaintenance(state, "this replica")
+
+	gs.mu.Lock()
+	shared := gs.maintenanceSync
+	gs.mu.Unlock()
+	if shared == nil {
+		return nil
+	}
+	if err := shared.Publish(ctx, state); err != nil {
+		return fmt.Errorf("failed to share the maintenance state: %w", err)
+	}
+	return nil
+}
+
+func (gs *ginService) MaintenanceState() middleware.MaintenanceState {
+	return gs.maintenance.State()
+}
+
+func (gs *ginService) applyMaintenance(state middleware.MaintenanceState, source string) {
+	if !gs.maintenance.Set(state) {
+		return
+	}
+
+	log := logger.GetCurrent().GetLogger(gs.prefix)
+	if !state.Enabled {
+		log.Infof("maintenance mode is off, set by %s", source)
+		return
+	}
+	eta := "unknown"
+	if state.ETA != nil {
+		eta = state.ETA.Format(time.RFC3339)
+	}
+	log.Warnf("maintenance mode is on, set by %s: %q, eta %s", source, state.Message, eta)
+}
+
+func (gs *ginService) closeMaintenanceSync() {
+	gs.mu.Lock()
+	sub := gs.maintenanceSub
+	gs.maintenanceSub = nil
+	gs.mu.Unlock()
+
+	if sub != nil {
+		_ = sub.Close()
+	}
+}
+
+// registerMaintenanceHandlers serves the maintenance state and changes it
+func (gs *ginService) registerMaintenanceHandlers(engine *gin.Engine) {
+	engine.GET(maintenancePath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gs.MaintenanceState())
+	})
+
+	engine.PUT(maintenancePath, func(c *gin.Context) {
+		var req maintenanceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := gs.SetMaintenance(c.Request.Context(), *req.Enabled, req.Message, req.ETA); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gs.MaintenanceState())
+	})
+}
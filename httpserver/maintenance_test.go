@@ -0,0 +1,116 @@
+package httpserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// memorySync is a pub/sub shared by the replicas of a test
+type memorySync struct {
+	mu   sync.Mutex
+	last *middleware.MaintenanceState
+	subs []func(middleware.MaintenanceState)
+}
+
+func (s *memorySync) Load(context.Context) (middleware.MaintenanceState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil {
+		return middleware.MaintenanceState{}, false, nil
+	}
+	return *s.last, true, nil
+}
+
+func (s *memorySync) Publish(_ context.Context, state middleware.MaintenanceState) error {
+	s.mu.Lock()
+	s.last = &state
+	subs := s.subs
+	s.mu.Unlock()
+	for _, fn := range subs {
+		fn(state)
+	}
+	return nil
+}
+
+func (s *memorySync) Subscribe(fn func(middleware.MaintenanceState)) (io.Closer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs = append(s.subs, fn)
+	return io.NopCloser(nil), nil
+}
+
+func newMaintenanceReplica(t *testing.T, shared middleware.MaintenanceSync) *ginService {
+	gs := New("test")
+	gs.SetMaintenanceSync(shared)
+	gs.OpsToken = testOpsToken
+	assert.Nil(t, gs.Configure(), "must be nil")
+	assert.Nil(t, gs.registerRoutes(), "must be nil")
+	gs.router.GET("/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return gs
+}
+
+func serveMaintenance(gs *ginService, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testOpsToken)
+	w := httptest.NewRecorder()
+	gs.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMaintenance(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.MaintenanceEnabled = true
+	gs.MaintenanceMessage = "upgrading"
+	gs.OpsToken = testOpsToken
+	assert.Nil(t, gs.Configure(), "must be nil")
+	assert.Nil(t, gs.registerRoutes(), "must be nil")
+	gs.router.GET("/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	assert.Equal(t, http.StatusServiceUnavailable, serveMaintenance(gs, http.MethodGet, "/items", "").Code, "should be equal")
+	assert.Equal(t, http.StatusOK, serveMaintenance(gs, http.MethodGet, "/healthz", "").Code, "should be equal")
+	assert.Contains(t, serveMaintenance(gs, http.MethodGet, "/admin/maintenance", "").Body.String(), `"message":"upgrading"`, "should contain the message")
+
+	assert.Equal(t, http.StatusBadRequest, serveMaintenance(gs, http.MethodPut, "/admin/maintenance", `{}`).Code, "enabled should be required")
+	w := serveMaintenance(gs, http.MethodPut, "/admin/maintenance", `{"enabled": false}`)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Contains(t, w.Body.String(), `"enabled":false`, "should be equal")
+	assert.Equal(t, http.StatusOK, serveMaintenance(gs, http.MethodGet, "/items", "").Code, "should be equal")
+
+	gs = New("test")
+	gs.maintenanceExemptCIDRs = "10.0.0.0/33"
+	assert.NotNil(t, gs.Configure(), "should be an error")
+}
+
+func TestMaintenanceSync(t *testing.T) {
+	logger.InitServLogger(false)
+
+	shared := &memorySync{}
+	a := newMaintenanceReplica(t, shared)
+	b := newMaintenanceReplica(t, shared)
+
+	w := serveMaintenance(a, http.MethodPut, "/admin/maintenance", `{"enabled": true, "message": "migrating", "eta": "2030-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, http.StatusServiceUnavailable, serveMaintenance(b, http.MethodGet, "/items", "").Code, "every replica should be in maintenance")
+	assert.Equal(t, "migrating", b.MaintenanceState().Message, "should be equal")
+
+	// replicas starting during the maintenance
+	c := newMaintenanceReplica(t, shared)
+	assert.True(t, c.MaintenanceState().Enabled, "should be loaded")
+
+	assert.Nil(t, b.SetMaintenance(context.Background(), false, "", nil), "must be nil")
+	for _, gs := range []*ginService{a, b, c} {
+		assert.Equal(t, http.StatusOK, serveMaintenance(gs, http.MethodGet, "/items", "").Code, "should be equal")
+	}
+}
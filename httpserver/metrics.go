@@ -0,0 +1,37 @@
+package httpserver
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/plugin/otel"
+)
+
+// configureMetrics parses the histogram buckets flag
+func (gs *ginService) configureMetrics() error {
+	if !gs.listFlagChanged(&gs.metricsBuckets) {
+		return nil
+	}
+
+	buckets := make([]float64, 0)
+	for _, item := range splitList(gs.metricsBuckets) {
+		b, err := strconv.ParseFloat(item, 64)
+		if err != nil || b < 0 {
+			return fmt.Errorf("invalid gin metrics bucket %q", item)
+		}
+		buckets = append(buckets, b)
+	}
+	sort.Float64s(buckets)
+
+	gs.MetricsBuckets = buckets
+	return nil
+}
+
+// registerMetricsHandler serves /metrics when the otel plugin exports metrics to prometheus
+func (gs *ginService) registerMetricsHandler(engine *gin.Engine) {
+	if h := otel.MetricsHandler(); h != nil {
+		engine.GET("/metrics", gin.WrapH(h))
+	}
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// AccessLogConfig is the config of AccessLog
+type AccessLogConfig struct {
+	// paths which are not logged, e.g. /healthz
+	SkipPaths []string `json:"skip_paths"`
+	// request and response bodies are logged up to this size in bytes, 0 => not logged
+	MaxBodySize int `json:"max_body_size"`
+	// levels of 4xx and 5xx responses, others are logged at info. Empty uses warn and error.
+	ClientErrorLevel string `json:"client_error_level"`
+	ServerErrorLevel string `json:"server_error_level"`
+	// nil uses the "http" logger of the current service logger
+	Logger logger.Logger `json:"-"`
+}
+
+// Validate checks the levels
+func (cfg AccessLogConfig) Validate() error {
+	for _, level := range []string{cfg.ClientErrorLevel, cfg.ServerErrorLevel} {
+		switch level {
+		case "", "debug", "info", "warn", "error":
+		default:
+			return fmt.Errorf("invalid access log level %q, must be debug, info, warn or error", level)
+		}
+	}
+	if cfg.MaxBodySize < 0 {
+		return fmt.Errorf("invalid access log body size %d", cfg.MaxBodySize)
+	}
+	return nil
+}
+
+// AccessLog logs every request with the "http" logger: method, route, status, latency,
+// sizes, client ip, user agent, request id, trace id and span id. It must run after RequestID
+// and the otel middleware to log their ids. Panics are logged as 500 and re-raised.
+func AccessLog(cfg AccessLogConfig) gin.HandlerFunc {
+	skip := make(map[string]struct{}, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		skip[path] = struct{}{}
+	}
+
+	clientErrorLevel, serverErrorLevel := cfg.ClientErrorLevel, cfg.ServerErrorLevel
+	if clientErrorLevel == "" {
+		clientErrorLevel = "warn"
+	}
+	if serverErrorLevel == "" {
+		serverErrorLevel = "error"
+	}
+
+	return func(c *gin.Context) {
+		if _, ok := skip[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		// read before c.Next, the otel middleware restores the request context after it
+		ctx := c.Request.Context()
+		path := c.Request.URL.Path
+
+		body := &bodyRecorder{limit: cfg.MaxBodySize}
+		if c.Request.Body != nil {
+			body.ReadCloser = c.Request.Body
+			c.Request.Body = body
+		}
+
+		var resp *responseRecorder
+		if cfg.MaxBodySize > 0 {
+			resp = &responseRecorder{ResponseWriter: c.Writer, limit: cfg.MaxBodySize}
+			c.Writer = resp
+		}
+
+		panicked := true
+		defer func() {
+			status := c.Writer.Status()
+			if panicked {
+				status = 500
+			}
+
+			bytesOut := c.Writer.Size()
+			if bytesOut < 0 {
+				bytesOut = 0
+			}
+
+			route := c.FullPath()
+			if route == "" {
+				route = unmatchedRoute
+			}
+
+			fields := logger.Fields{
+				"method":     c.Request.Method,
+				"proto":      c.Request.Proto,
+				"route":      route,
+				"path":       path,
+				"status":     status,
+				"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+				"bytes_in":   max(body.n, c.Request.ContentLength),
+				"bytes_out":  bytesOut,
+				"client_ip":  ClientIP(c),
+				"user_agent": c.Request.UserAgent(),
+			}
+
+			if id := RequestIDFromContext(ctx); id != "" {
+				fields[RequestIDKey] = id
+			}
+			if cfg.MaxBodySize > 0 {
+				fields["request_body"] = body.buf.String()
+				fields["response_body"] = resp.buf.String()
+			}
+			if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+				fields["error"] = errs
+			}
+
+			level := "info"
+			switch {
+			case status >= 500:
+				level = serverErrorLevel
+			case status >= 400:
+				level = clientErrorLevel
+			}
+
+			log := cfg.Logger
+			if log == nil {
+				log = logger.GetCurrent().GetLogger("http")
+			}
+			logAt(log.Withs(fields).WithContext(ctx), level,
+				fmt.Sprintf("%s %s %d", c.Request.Method, path, status))
+		}()
+
+		c.Next()
+		panicked = false
+	}
+}
+
+func logAt(l logger.Logger, level, msg string) {
+	switch level {
+	case "debug":
+		l.Debug(msg)
+	case "warn":
+		l.Warn(msg)
+	case "error":
+		l.Error(msg)
+	default:
+		l.Info(msg)
+	}
+}
+
+// bodyRecorder counts the request body read by handlers and keeps its first limit bytes
+type bodyRecorder struct {
+	io.ReadCloser
+	limit int
+	n     int64
+	buf   bytes.Buffer
+}
+
+func (r *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	if room := r.limit - r.buf.Len(); room > 0 {
+		r.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+// responseRecorder keeps the first limit bytes of the response body
+type responseRecorder struct {
+	gin.ResponseWriter
+	limit int
+	buf   bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if room := w.limit - w.buf.Len(); room > 0 {
+		w.buf.Write(b[:min(len(b), room)])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap is for http.ResponseController
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	if room := w.limit - w.buf.Len(); room > 0 {
+		w.buf.WriteString(s[:min(len(s), room)])
+	}
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type accessLogEntry struct {
+	level  string
+	msg    string
+	fields logger.Fields
+}
+
+// recordingLogger keeps the entries, the Logger methods
+// not used by AccessLog are left nil
+type recordingLogger struct {
+	logger.Logger
+	mu      *sync.Mutex
+	fields  logger.Fields
+	entries *[]accessLogEntry
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{mu: &sync.Mutex{}, entries: &[]accessLogEntry{}}
+}
+
+func (l *recordingLogger) With(key string, value interface{}) logger.Logger {
+	return l.Withs(logger.Fields{key: value})
+}
+
+func (l *recordingLogger) Withs(fields logger.Fields) logger.Logger {
+	merged := logger.Fields{}
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &recordingLogger{mu: l.mu, fields: merged, entries: l.entries}
+}
+
+func (l *recordingLogger) WithContext(ctx context.Context) logger.Logger {
+	fields := logger.Fields{}
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+		fields["span_id"] = sc.SpanID().String()
+	}
+	return &recordingLogger{mu: l.mu, fields: fields, entries: l.entries}
+}
+
+func (l *recordingLogger) record(level string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.entries = append(*l.entries, accessLogEntry{level: level, msg: args[0].(string), fields: l.fields})
+}
+
+func (l *recordingLogger) Debug(args ...interface{}) { l.record("debug", args...) }
+func (l *recordingLogger) Info(args ...interface{})  { l.record("info", args...) }
+func (l *recordingLogger) Warn(args ...interface{})  { l.record("warn", args...) }
+func (l *recordingLogger) Error(args ...interface{}) { l.record("error", args...) }
+
+func (l *recordingLogger) Warnf(format string, args ...interface{}) {
+	l.record("warn", fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.record("error", fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) last() accessLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return (*l.entries)[len(*l.entries)-1]
+}
+
+func (l *recordingLogger) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(*l.entries)
+}
+
+func newAccessLogRouter(cfg AccessLogConfig) *gin.Engine {
+	logger.InitServLogger(false)
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(otelgin.Middleware("test", otelgin.WithTracerProvider(sdktrace.NewTracerProvider())))
+	router.Use(RequestID())
+	router.Use(PanicLogger())
+	router.Use(AccessLog(cfg))
+	router.POST("/users/:id", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusCreated, "created "+string(body))
+	})
+	router.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+	return router
+}
+
+func TestAccessLog(t *testing.T) {
+	log := newRecordingLogger()
+	router := newAccessLogRouter(AccessLogConfig{SkipPaths: []string{"/healthz"}, Logger: log})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader("alice"))
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set(RequestIDHeader, "req-1")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code, "should be equal")
+
+	entry := log.last()
+	assert.Equal(t, "info", entry.level, "should be equal")
+	assert.Equal(t, "POST /users/1 201", entry.msg, "should be equal")
+	assert.Equal(t, "/users/:id", entry.fields["route"], "should log the route template")
+	assert.Equal(t, 201, entry.fields["status"], "should be equal")
+	assert.Equal(t, int64(5), entry.fields["bytes_in"], "should be equal")
+	assert.Equal(t, len("created alice"), entry.fields["bytes_out"], "should be equal")
+	assert.Equal(t, "test-agent", entry.fields["user_agent"], "should be equal")
+	assert.Equal(t, "HTTP/1.1", entry.fields["proto"], "should be equal")
+	assert.Equal(t, "req-1", entry.fields[RequestIDKey], "should be equal")
+	assert.Len(t, entry.fields["trace_id"], 32, "should log the trace id")
+	assert.Len(t, entry.fields["span_id"], 16, "should log the span id")
+	assert.NotContains(t, entry.fields, "request_body", "bodies should not be logged by default")
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, 1, log.count(), "skipped paths should not be logged")
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, "warn", log.last().level, "should be equal")
+	assert.Equal(t, unmatchedRoute, log.last().fields["route"], "should be equal")
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, "error", log.last().level, "should be equal")
+	assert.Equal(t, 500, log.last().fields["status"], "should be equal")
+}
+
+func TestAccessLogBodiesAndLevels(t *testing.T) {
+	log := newRecordingLogger()
+	router := newAccessLogRouter(AccessLogConfig{MaxBodySize: 4, ClientErrorLevel: "info", Logger: log})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader("alice")))
+	assert.Equal(t, "alic", log.last().fields["request_body"], "should be truncated")
+	assert.Equal(t, "crea", log.last().fields["response_body"], "should be truncated")
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, "info", log.last().level, "should be equal")
+
+	assert.NotNil(t, AccessLogConfig{ServerErrorLevel: "loud"}.Validate(), "should be an error")
+	assert.Nil(t, AccessLogConfig{ServerErrorLevel: "warn"}.Validate(), "must be nil")
+}
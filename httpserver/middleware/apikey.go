@@ -0,0 +1,284 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/worker"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+const (
+	apiKeyAuthScheme = "ApiKey "
+	// the prefix of generated keys, so leaked keys are easy to find
+	apiKeyPrefix = "sk_"
+
+	defaultAPIKeyCacheTTL = 30 * time.Second
+	// stale entries of the cache are removed at most this often
+	apiKeyCacheCleanupInterval = time.Minute
+)
+
+var (
+	// ErrAPIKeyNotFound is returned by KeyStore for unknown keys
+	ErrAPIKeyNotFound = errors.New("api key not found")
+
+	errMissingAPIKey = sdkcm.AppError{StatusCode: http.StatusUnauthorized, Code: "missing_api_key", Message: "missing api key"}
+	errInvalidAPIKey = sdkcm.AppError{StatusCode: http.StatusUnauthorized, Code: "invalid_api_key", Message: "invalid api key"}
+)
+
+// APIKey is the principal of requests authenticated by RequiredAPIKey, it's
+// stored as their requester
+type APIKey struct {
+	ID string `json:"id"`
+	// SHA-256 hex of the key, the key itself is never stored
+	Hash string `json:"hash"`
+	// the user the key acts as, 0 for service accounts
+	OwnerID   uint32     `json:"owner_id"`
+	Role      string     `json:"role"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k *APIKey) OAuthID() string       { return "apikey:" + k.ID }
+func (k *APIKey) UserID() uint32        { return k.OwnerID }
+func (k *APIKey) GetSystemRole() string { return k.Role }
+func (k *APIKey) GetUser() interface{}  { return k }
+func (k *APIKey) GetScopes() []string   { return k.Scopes }
+
+// isActive reports whether the key is neither revoked nor expired at now
+func (k *APIKey) isActive(now time.Time) bool {
+	return (k.RevokedAt == nil || now.Before(*k.RevokedAt)) &&
+		(k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// KeyStore finds the api keys by hash
+type KeyStore interface {
+	// FindAPIKey returns the key of hash, ErrAPIKeyNotFound when there is none
+	FindAPIKey(ctx context.Context, hash string) (*APIKey, error)
+}
+
+// KeyUsageRecorder is optionally implemented by KeyStore to record when keys are used
+type KeyUsageRecorder interface {
+	TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// HashAPIKey returns the hash of key stored in a KeyStore
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a new random key and its hash, the key is only
+// shown to its owner and the hash is stored
+func GenerateAPIKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+type apiKeyOptions struct {
+	cacheTTL time.Duration
+	queue    worker.Queue
+	now      func() time.Time
+}
+
+type APIKeyOption func(*apiKeyOptions)
+
+// WithAPIKeyCacheTTL sets how long keys are cached, revoked keys are accepted
+// until their cache entry expires. 0 disables the cache.
+func WithAPIKeyCacheTTL(ttl time.Duration) APIKeyOption {
+	return func(o *apiKeyOptions) { o.cacheTTL = ttl }
+}
+
+// WithUsageQueue records the last use of keys with a job of q, when the store
+// is a KeyUsageRecorder
+func WithUsageQueue(q worker.Queue) APIKeyOption {
+	return func(o *apiKeyOptions) { o.queue = q }
+}
+
+// RequiredAPIKey authenticates machine to machine callers with the key of the
+// X-API-Key header or of "Authorization: ApiKey <key>". The *APIKey is stored as
+// the requester, so RequiredScopes and RequiredRoles compose with it. Unknown,
+// revoked and expired keys get 401.
+func RequiredAPIKey(store KeyStore, opts ...APIKeyOption) gin.HandlerFunc {
+	o := apiKeyOptions{cacheTTL: defaultAPIKeyCacheTTL, now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cache := newAPIKeyCache(o.cacheTTL)
+	recorder, _ := store.(KeyUsageRecorder)
+
+	return func(c *gin.Context) {
+		presented := apiKeyOf(c.Request)
+		if presented == "" {
+			writeAppError(c, errMissingAPIKey)
+			return
+		}
+
+		ctx := c.Request.Context()
+		hash := HashAPIKey(presented)
+		now := o.now()
+
+		key, ok := cache.get(hash, now)
+		if !ok {
+			var err error
+			key, err = store.FindAPIKey(ctx, hash)
+			if errors.Is(err, ErrAPIKeyNotFound) {
+				writeAppError(c, errInvalidAPIKey)
+				return
+			}
+			if err != nil {
+				writeAppError(c, sdkcm.ErrInternal(err))
+				return
+			}
+			cache.set(hash, key, now)
+		}
+
+		// the store found it by hash, this doesn't leak the stored hash by timing
+		if subtle.ConstantTimeCompare([]byte(strings.ToLower(key.Hash)), []byte(hash)) != 1 || !key.isActive(now) {
+			writeAppError(c, errInvalidAPIKey)
+			return
+		}
+
+		if recorder != nil && o.queue != nil {
+			recordUsage(ctx, o.queue, recorder, key.ID, now)
+		}
+
+		c.Set(CurrentRequesterKey, key)
+		c.Request = c.Request.WithContext(sdkcm.ContextWithRequester(ctx, key))
+		c.Next()
+	}
+}
+
+func apiKeyOf(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return strings.TrimSpace(key)
+	}
+
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(apiKeyAuthScheme) || !strings.EqualFold(header[:len(apiKeyAuthScheme)], apiKeyAuthScheme) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(apiKeyAuthScheme):])
+}
+
+func recordUsage(ctx context.Context, q worker.Queue, recorder KeyUsageRecorder, id string, usedAt time.Time) {
+	err := q.Enqueue(ctx, "api-key-last-used", func(ctx context.Context) error {
+		return recorder.TouchAPIKey(ctx, id, usedAt)
+	})
+	if err != nil {
+		logger.FromContext(ctx, "auth").Debugf("last use of api key %s is not recorded: %s", id, err.Error())
+	}
+}
+
+type apiKeyCacheEntry struct {
+	key     *APIKey
+	expires time.Time
+}
+
+// apiKeyCache keeps the found keys for ttl, unknown keys are not cached
+// so random keys can't fill it
+type apiKeyCache struct {
+	ttl         time.Duration
+	mu          sync.Mutex
+	entries     map[string]apiKeyCacheEntry
+	lastCleanup time.Time
+}
+
+func newAPIKeyCache(ttl time.Duration) *apiKeyCache {
+	return &apiKeyCache{ttl: ttl, entries: map[string]apiKeyCacheEntry{}}
+}
+
+func (c *apiKeyCache) get(hash string, now time.Time) (*APIKey, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[hash]
+	if !ok || !now.Before(e.expires) {
+		return nil, false
+	}
+	return e.key, true
+}
+
+func (c *apiKeyCache) set(hash string, key *APIKey, now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastCleanup) > apiKeyCacheCleanupInterval {
+		for h, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, h)
+			}
+		}
+		c.lastCleanup = now
+	}
+	c.entries[hash] = apiKeyCacheEntry{key: key, expires: now.Add(c.ttl)}
+}
+
+type staticKeyStore struct {
+	keys map[string]*APIKey
+}
+
+// NewStaticKeyStore is a KeyStore of fixed keys, e.g. from LoadStaticKeyStore
+func NewStaticKeyStore(keys ...*APIKey) *staticKeyStore {
+	s := &staticKeyStore{keys: map[string]*APIKey{}}
+	for _, k := range keys {
+		s.keys[strings.ToLower(k.Hash)] = k
+	}
+	return s
+}
+
+// LoadStaticKeyStore reads the keys of a JSON file:
+//
+//	[{"id": "billing", "hash": "<sha256 hex of the key>", "role": "user", "scopes": ["orders:read"]}]
+func LoadStaticKeyStore(path string) (*staticKeyStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid api keys file %s: %w", path, err)
+	}
+	for i, k := range keys {
+		if k.ID == "" || len(k.Hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid api keys file %s: key %d must have an id and a sha256 hex hash", path, i)
+		}
+	}
+	return NewStaticKeyStore(keys...), nil
+}
+
+func (s *staticKeyStore) FindAPIKey(_ context.Context, hash string) (*APIKey, error) {
+	if k, ok := s.keys[hash]; ok {
+		return k, nil
+	}
+	return nil, ErrAPIKeyNotFound
+}
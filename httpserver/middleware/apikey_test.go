@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/plugin/worker"
+)
+
+// countingKeyStore counts the lookups and records the uses
+type countingKeyStore struct {
+	KeyStore
+	mu      sync.Mutex
+	lookups int
+	used    map[string]time.Time
+}
+
+func (s *countingKeyStore) FindAPIKey(ctx context.Context, hash string) (*APIKey, error) {
+	s.mu.Lock()
+	s.lookups++
+	s.mu.Unlock()
+	return s.KeyStore.FindAPIKey(ctx, hash)
+}
+
+func (s *countingKeyStore) TouchAPIKey(_ context.Context, id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used[id] = usedAt
+	return nil
+}
+
+// syncQueue runs the jobs right away
+type syncQueue struct{}
+
+func (syncQueue) Enqueue(ctx context.Context, _ string, fn worker.JobFunc, _ ...worker.JobOption) error {
+	return fn(ctx)
+}
+
+func newAPIKeyRouter(store KeyStore, opts ...APIKeyOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	api := router.Group("/api", RequiredAPIKey(store, opts...))
+	api.GET("/orders", RequiredScopes("orders:read"), func(c *gin.Context) {
+		r, _ := RequesterFromContext(c.Request.Context())
+		c.String(http.StatusOK, r.OAuthID())
+	})
+	api.POST("/orders", RequiredScopes("orders:write"), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	return router
+}
+
+func callWithKey(router *gin.Engine, method, header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/orders", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequiredAPIKey(t *testing.T) {
+	key, hash, err := GenerateAPIKey()
+	assert.Nil(t, err, "must be nil")
+	revoked := time.Now().Add(-time.Hour)
+
+	store := &countingKeyStore{
+		KeyStore: NewStaticKeyStore(
+			&APIKey{ID: "reader", Hash: hash, Role: "user", Scopes: []string{"orders:read"}},
+			&APIKey{ID: "old", Hash: HashAPIKey("sk_old"), Scopes: []string{"*"}, RevokedAt: &revoked},
+		),
+		used: map[string]time.Time{},
+	}
+	router := newAPIKeyRouter(store, WithUsageQueue(syncQueue{}))
+
+	w := callWithKey(router, http.MethodGet, APIKeyHeader, key)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "apikey:reader", w.Body.String(), "the key should be the requester")
+	assert.Contains(t, store.used, "reader", "the use should be recorded")
+
+	w = callWithKey(router, http.MethodGet, "Authorization", "ApiKey "+key)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, 1, store.lookups, "keys should be cached")
+
+	assert.Equal(t, http.StatusForbidden, callWithKey(router, http.MethodPost, APIKeyHeader, key).Code, "missing scopes should be forbidden")
+	assert.Equal(t, http.StatusUnauthorized, callWithKey(router, http.MethodGet, "", "").Code, "should be equal")
+	assert.Equal(t, http.StatusUnauthorized, callWithKey(router, http.MethodGet, APIKeyHeader, "sk_unknown").Code, "should be equal")
+	assert.Equal(t, http.StatusUnauthorized, callWithKey(router, http.MethodGet, APIKeyHeader, "sk_old").Code, "revoked keys should be rejected")
+	assert.Equal(t, http.StatusUnauthorized, callWithKey(router, http.MethodGet, "Authorization", "Bearer "+key).Code, "should be equal")
+}
+
+func TestRequiredAPIKeyCacheTTL(t *testing.T) {
+	key, hash, _ := GenerateAPIKey()
+	expires := time.Now().Add(time.Hour)
+	store := &countingKeyStore{KeyStore: NewStaticKeyStore(&APIKey{ID: "k", Hash: hash, Scopes: []string{"orders:*"}, ExpiresAt: &expires})}
+
+	now := time.Now()
+	router := newAPIKeyRouter(store, WithAPIKeyCacheTTL(time.Minute), func(o *apiKeyOptions) {
+		o.now = func() time.Time { return now }
+	})
+
+	assert.Equal(t, http.StatusCreated, callWithKey(router, http.MethodPost, APIKeyHeader, key).Code, "wildcard scopes should be granted")
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, http.StatusOK, callWithKey(router, http.MethodGet, APIKeyHeader, key).Code, "should be equal")
+	assert.Equal(t, 2, store.lookups, "expired cache entries should be looked up again")
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, http.StatusUnauthorized, callWithKey(router, http.MethodGet, APIKeyHeader, key).Code, "expired keys should be rejected")
+}
+
+func TestLoadStaticKeyStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys.json")
+	hash := HashAPIKey("sk_test")
+
+	assert.Nil(t, os.WriteFile(path, []byte(`[{"id":"ci","hash":"`+hash+`","scopes":["deploy"]}]`), 0o600), "must be nil")
+	store, err := LoadStaticKeyStore(path)
+	assert.Nil(t, err, "must be nil")
+	k, err := store.FindAPIKey(context.Background(), hash)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, []string{"deploy"}, k.GetScopes(), "should be equal")
+
+	assert.Nil(t, os.WriteFile(path, []byte(`[{"id":"ci","hash":"sk_test"}]`), 0o600), "must be nil")
+	_, err = LoadStaticKeyStore(path)
+	assert.NotNil(t, err, "plain keys should be an error")
+}
+
+func TestHasScope(t *testing.T) {
+	assert.True(t, hasScope([]string{"orders:read"}, "orders:read"), "should be true")
+	assert.True(t, hasScope([]string{"orders:*"}, "orders:write"), "should be true")
+	assert.True(t, hasScope([]string{"*"}, "users:delete"), "should be true")
+	assert.False(t, hasScope([]string{"orders:*"}, "ordersx:write"), "should be false")
+	assert.False(t, hasScope(nil, "orders:read"), "should be false")
+}
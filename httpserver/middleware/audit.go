@@ -0,0 +1,280 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/worker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	skipAuditKey  = "skip_audit"
+	redactedValue = "[REDACTED]"
+	// nested values deeper than this are redacted whole
+	maxAuditBodyDepth = 16
+)
+
+// request body fields always redacted, matched like the log-redact-keys of the logger
+var defaultAuditRedactFields = []string{"password", "token", "secret", "authorization", "api_key", "card_number", "cvv"}
+
+// AuditEntry is what a mutating request did, and who did it
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// the requester of RequiredAuth or RequiredAPIKey, empty for anonymous requests
+	UserID    uint32            `json:"user_id,omitempty"`
+	Principal string            `json:"principal,omitempty"`
+	Method    string            `json:"method"`
+	Route     string            `json:"route"`
+	Path      string            `json:"path"`
+	Params    map[string]string `json:"params,omitempty"`
+	Status    int               `json:"status"`
+	ClientIP  string            `json:"client_ip"`
+	RequestID string            `json:"request_id,omitempty"`
+	// redacted JSON request body, nil when it's not JSON or larger than the snapshot size
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// AuditSink stores the audit entries, it's called outside of the requests
+type AuditSink interface {
+	WriteAudit(ctx context.Context, entry *AuditEntry) error
+}
+
+type AuditConfig struct {
+	Sink AuditSink `json:"-"`
+	// writes the entries with jobs, nil writes them in goroutines
+	Queue worker.Queue `json:"-"`
+	// JSON request bodies are kept up to this size in bytes, 0 => not kept
+	MaxBodySize int `json:"max_body_size"`
+	// body fields replaced by [REDACTED], in addition to password, token, secret,
+	// authorization, api_key, card_number and cvv. Fields containing them are too.
+	RedactFields []string `json:"redact_fields"`
+}
+
+func (cfg AuditConfig) Validate() error {
+	if cfg.Sink == nil {
+		return errors.New("audit: sink is required")
+	}
+	if cfg.MaxBodySize < 0 {
+		return errors.New("audit: max body size must not be negative")
+	}
+	return nil
+}
+
+// Audit records the POST, PUT, PATCH and DELETE requests to cfg.Sink once they
+// are handled, without delaying them. Sink failures are logged and counted by the
+// http.server.audit.failures metric. Routes opt out with SkipAudit.
+func Audit(cfg AuditConfig) gin.HandlerFunc {
+	redact := newBodyRedactor(cfg.RedactFields)
+
+	failures, err := otel.Meter(meterName).Int64Counter("http.server.audit.failures",
+		metric.WithDescription("Number of audit entries which couldn't be written"),
+		metric.WithUnit("{entry}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	write := func(ctx context.Context, entry *AuditEntry) error {
+		err := cfg.Sink.WriteAudit(ctx, entry)
+		if err != nil {
+			failures.Add(ctx, 1)
+			logger.FromContext(ctx, "audit").Warnf("failed to write audit entry of %s %s: %s", entry.Method, entry.Path, err.Error())
+		}
+		return err
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		// read before c.Next, the otel middleware restores the request context after it
+		ctx := c.Request.Context()
+		path := c.Request.URL.Path
+
+		var body *bodyRecorder
+		if cfg.MaxBodySize > 0 && c.Request.Body != nil && isJSON(c.ContentType()) {
+			// one more byte tells truncated bodies
+			body = &bodyRecorder{ReadCloser: c.Request.Body, limit: cfg.MaxBodySize + 1}
+			c.Request.Body = body
+		}
+
+		c.Next()
+
+		if c.GetBool(skipAuditKey) {
+			return
+		}
+
+		entry := &AuditEntry{
+			Time:      start.UTC(),
+			Method:    c.Request.Method,
+			Route:     c.FullPath(),
+			Path:      path,
+			Status:    c.Writer.Status(),
+			ClientIP:  ClientIP(c),
+			RequestID: RequestIDFromContext(ctx),
+		}
+		if r, ok := RequesterFromContext(c); ok {
+			entry.UserID, entry.Principal = r.UserID(), r.OAuthID()
+		}
+		if len(c.Params) > 0 {
+			entry.Params = make(map[string]string, len(c.Params))
+			for _, p := range c.Params {
+				entry.Params[p.Key] = p.Value
+			}
+		}
+		if body != nil && body.buf.Len() <= cfg.MaxBodySize {
+			entry.Body = redact.json(body.buf.Bytes())
+		}
+
+		if cfg.Queue == nil {
+			go func() { _ = write(context.WithoutCancel(ctx), entry) }()
+			return
+		}
+		if err := cfg.Queue.Enqueue(ctx, "audit", func(ctx context.Context) error {
+			return write(ctx, entry)
+		}); err != nil {
+			failures.Add(ctx, 1)
+			logger.FromContext(ctx, "audit").Warnf("failed to enqueue audit entry of %s %s: %s", entry.Method, path, err.Error())
+		}
+	}
+}
+
+// SkipAudit opts a route out of Audit, e.g. a login whose body is all secrets:
+//
+//	engine.POST("/login", middleware.SkipAudit(), login)
+func SkipAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(skipAuditKey, true)
+		c.Next()
+	}
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+type bodyRedactor struct {
+	fields []string
+}
+
+func newBodyRedactor(extra []string) *bodyRedactor {
+	r := &bodyRedactor{}
+	for _, f := range append(append([]string(nil), defaultAuditRedactFields...), extra...) {
+		if f = normalizeField(f); f != "" {
+			r.fields = append(r.fields, f)
+		}
+	}
+	return r
+}
+
+// normalizeField makes apiKey, api_key and API-Key the same
+func normalizeField(f string) string {
+	f = strings.ToLower(strings.TrimSpace(f))
+	return strings.NewReplacer("_", "", "-", "").Replace(f)
+}
+
+func (r *bodyRedactor) sensitive(field string) bool {
+	field = normalizeField(field)
+	for _, f := range r.fields {
+		if strings.Contains(field, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// json returns the redacted body, nil when it's not valid JSON
+func (r *bodyRedactor) json(body []byte) json.RawMessage {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil
+	}
+
+	out, err := json.Marshal(r.value(v, 0))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func (r *bodyRedactor) value(v interface{}, depth int) interface{} {
+	if depth > maxAuditBodyDepth {
+		return redactedValue
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, nested := range v {
+			if r.sensitive(k) {
+				v[k] = redactedValue
+			} else {
+				v[k] = r.value(nested, depth+1)
+			}
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = r.value(nested, depth+1)
+		}
+	}
+	return v
+}
+
+type logAuditSink struct {
+	log logger.Logger
+}
+
+// NewLogAuditSink writes the audit entries as info logs of log,
+// nil uses the "audit" logger of the current service logger
+func NewLogAuditSink(log logger.Logger) *logAuditSink {
+	return &logAuditSink{log: log}
+}
+
+func (s *logAuditSink) WriteAudit(ctx context.Context, entry *AuditEntry) error {
+	log := s.log
+	if log == nil {
+		log = logger.GetCurrent().GetLogger("audit")
+	}
+
+	fields := logger.Fields{
+		"user_id":      entry.UserID,
+		"principal":    entry.Principal,
+		"method":       entry.Method,
+		"route":        entry.Route,
+		"path":         entry.Path,
+		"status":       entry.Status,
+		"client_ip":    entry.ClientIP,
+		"requested_at": entry.Time.Format(time.RFC3339Nano),
+	}
+	if entry.RequestID != "" {
+		fields[RequestIDKey] = entry.RequestID
+	}
+	if len(entry.Params) > 0 {
+		fields["params"] = entry.Params
+	}
+	if entry.Body != nil {
+		fields["body"] = string(entry.Body)
+	}
+
+	log.Withs(fields).WithContext(ctx).Info("audit " + entry.Method + " " + entry.Path)
+	return nil
+}
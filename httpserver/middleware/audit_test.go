@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/worker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type chanAuditSink struct {
+	entries chan *AuditEntry
+	err     error
+}
+
+func (s *chanAuditSink) WriteAudit(_ context.Context, entry *AuditEntry) error {
+	s.entries <- entry
+	return s.err
+}
+
+type fullQueue struct{}
+
+func (fullQueue) Enqueue(context.Context, string, worker.JobFunc, ...worker.JobOption) error {
+	return worker.ErrQueueFull
+}
+
+func newAuditRouter(cfg AuditConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestID(), Audit(cfg))
+	router.PUT("/orders/:id", func(c *gin.Context) {
+		c.Set(CurrentRequesterKey, &APIKey{ID: "billing", OwnerID: 7})
+		var req map[string]interface{}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(err)
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/login", SkipAudit(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func sendAudited(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func receiveAudit(t *testing.T, entries chan *AuditEntry) *AuditEntry {
+	select {
+	case e := <-entries:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("audit entry should be written")
+		return nil
+	}
+}
+
+func TestAudit(t *testing.T) {
+	sink := &chanAuditSink{entries: make(chan *AuditEntry, 10)}
+	router := newAuditRouter(AuditConfig{Sink: sink, Queue: syncQueue{}, MaxBodySize: 1 << 10, RedactFields: []string{"iban"}})
+
+	w := sendAudited(router, http.MethodPut, "/orders/42",
+		`{"note":"gift","payment":{"IBAN":"DE89370400440532013000","cardNumber":"4111111111111111"},"items":[{"user_password":"x"}]}`)
+	assert.Equal(t, http.StatusNoContent, w.Code, "should be equal")
+
+	e := receiveAudit(t, sink.entries)
+	assert.Equal(t, http.MethodPut, e.Method, "should be equal")
+	assert.Equal(t, "/orders/:id", e.Route, "should be equal")
+	assert.Equal(t, "/orders/42", e.Path, "should be equal")
+	assert.Equal(t, map[string]string{"id": "42"}, e.Params, "should be equal")
+	assert.Equal(t, http.StatusNoContent, e.Status, "should be equal")
+	assert.Equal(t, uint32(7), e.UserID, "should be equal")
+	assert.Equal(t, "apikey:billing", e.Principal, "should be equal")
+	assert.Equal(t, w.Header().Get(RequestIDHeader), e.RequestID, "should be equal")
+	assert.NotEmpty(t, e.ClientIP, "should not be empty")
+	assert.False(t, e.Time.IsZero(), "should have a time")
+	assert.JSONEq(t, `{"note":"gift","payment":{"IBAN":"[REDACTED]","cardNumber":"[REDACTED]"},"items":[{"user_password":"[REDACTED]"}]}`,
+		string(e.Body), "sensitive fields should be redacted")
+
+	// bodies larger than the snapshot are not kept
+	sendAudited(router, http.MethodPut, "/orders/42", `{"note":"`+strings.Repeat("a", 2<<10)+`"}`)
+	assert.Nil(t, receiveAudit(t, sink.entries).Body, "must be nil")
+
+	sendAudited(router, http.MethodGet, "/orders/42", "")
+	sendAudited(router, http.MethodPost, "/login", `{"password":"x"}`)
+	assert.Len(t, sink.entries, 0, "reads and opted out routes should not be audited")
+}
+
+func TestAuditFailures(t *testing.T) {
+	logger.InitServLogger(false)
+
+	reader := metric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(metric.NewMeterProvider(metric.WithReader(reader)))
+	defer otel.SetMeterProvider(prev)
+
+	sink := &chanAuditSink{entries: make(chan *AuditEntry, 10), err: errors.New("database is down")}
+
+	// written in a goroutine without queue
+	router := newAuditRouter(AuditConfig{Sink: sink})
+	assert.Equal(t, http.StatusNoContent, sendAudited(router, http.MethodPut, "/orders/1", `{}`).Code, "sink failures should not fail requests")
+	assert.Nil(t, receiveAudit(t, sink.entries).Body, "bodies should not be kept by default")
+
+	router = newAuditRouter(AuditConfig{Sink: sink, Queue: fullQueue{}})
+	assert.Equal(t, http.StatusNoContent, sendAudited(router, http.MethodPut, "/orders/1", `{}`).Code, "should be equal")
+
+	assert.Eventually(t, func() bool {
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil || len(rm.ScopeMetrics) == 0 {
+			return false
+		}
+		var total int64
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if m.Name == "http.server.audit.failures" {
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					total += dp.Value
+				}
+			}
+		}
+		return total == 2
+	}, time.Second, 10*time.Millisecond, "failures should be counted")
+}
+
+func TestAuditConfigValidate(t *testing.T) {
+	assert.Nil(t, AuditConfig{Sink: NewLogAuditSink(nil)}.Validate(), "must be nil")
+	assert.NotNil(t, AuditConfig{}.Validate(), "should be an error")
+	assert.NotNil(t, AuditConfig{Sink: NewLogAuditSink(nil), MaxBodySize: -1}.Validate(), "should be an error")
+}
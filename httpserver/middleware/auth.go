@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+// CurrentRequesterKey is the gin context key of the authenticated sdkcm.Requester
+const CurrentRequesterKey = "current_requester"
+
+// TokenValidatorPrefix is the prefix RequiredAuth gets its TokenValidator with,
+// the jwtauth plugin uses it by default
+const TokenValidatorPrefix = "jwt"
+
+var (
+	errMissingToken = sdkcm.AppError{StatusCode: http.StatusUnauthorized, Code: "missing_token", Message: "missing access token"}
+	errExpiredToken = sdkcm.AppError{StatusCode: http.StatusUnauthorized, Code: "token_expired", Message: "access token is expired"}
+	errInvalidToken = sdkcm.AppError{StatusCode: http.StatusUnauthorized, Code: "invalid_token", Message: "invalid access token"}
+)
+
+// CachingWithTTL is optionally implemented by Caching to expire the cached users
+type CachingWithTTL interface {
+	WriteCurrentUserWithTTL(ctx context.Context, sig string, u sdkcm.Requester, ttl time.Duration) error
+}
+
+type authOptions struct {
+	validator TokenValidator
+	cacheTTL  time.Duration
+}
+
+type AuthOption func(*authOptions)
+
+// WithTokenValidator validates tokens with v instead of the
+// component registered with TokenValidatorPrefix
+func WithTokenValidator(v TokenValidator) AuthOption {
+	return func(o *authOptions) { o.validator = v }
+}
+
+// WithCacheTTL sets how long requesters are cached, it's capped by the token expiry
+func WithCacheTTL(ttl time.Duration) AuthOption {
+	return func(o *authOptions) { o.cacheTTL = ttl }
+}
+
+type oauthID string
+
+func (id oauthID) OAuthID() string { return string(id) }
+
+// RequiredAuth validates the bearer token, then stores the requester under
+// CurrentRequesterKey. The requester is read from cache first (nil cache is allowed),
+// then from provider. It aborts with 401 on failure, expired tokens
+// have the code token_expired.
+func RequiredAuth(sc ServiceContext, provider CurrentUserProvider, cache Caching, opts ...AuthOption) gin.HandlerFunc {
+	o := authOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.validator == nil {
+		o.validator = sc.MustGet(TokenValidatorPrefix).(TokenValidator)
+	}
+	if o.cacheTTL == 0 {
+		if c, ok := o.validator.(interface{ CacheTTL() time.Duration }); ok {
+			o.cacheTTL = c.CacheTTL()
+		}
+	}
+
+	log := sc.Logger("auth")
+
+	return func(c *gin.Context) {
+		token := bearerToken(c.GetHeader("Authorization"))
+		if token == "" {
+			writeAppError(c, errMissingToken)
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		claims, err := o.validator.ValidateToken(ctx, token)
+		if err != nil {
+			appErr := errInvalidToken
+			if errors.Is(err, ErrTokenExpired) {
+				appErr = errExpiredToken
+			}
+			appErr.RootCause = err
+			writeAppError(c, appErr)
+			return
+		}
+
+		// the signature identifies the token
+		sig := token[strings.LastIndex(token, ".")+1:]
+
+		var requester sdkcm.Requester
+		if cache != nil {
+			if r, err := cache.GetCurrentUser(ctx, sig); err == nil && r != nil {
+				requester = r
+			}
+		}
+
+		if requester == nil {
+			user, err := provider.GetCurrentUser(ctx, claims.Subject)
+			if err != nil {
+				appErr := errInvalidToken
+				appErr.RootCause = err
+				writeAppError(c, appErr)
+				return
+			}
+
+			requester = sdkcm.CurrentUser(oauthID(claims.Subject), user)
+
+			if cache != nil {
+				if err := writeCache(ctx, cache, sig, requester, cacheTTL(o.cacheTTL, claims.ExpiresAt)); err != nil {
+					log.Warnf("cannot cache current user: %s", err.Error())
+				}
+			}
+		}
+
+		c.Set(CurrentRequesterKey, requester)
+		c.Request = c.Request.WithContext(sdkcm.ContextWithRequester(ctx, requester))
+		c.Next()
+	}
+}
+
+// RequesterFromContext returns the requester stored by RequiredAuth,
+// ctx is the gin context or the request context
+func RequesterFromContext(ctx context.Context) (sdkcm.Requester, bool) {
+	if r, ok := sdkcm.RequesterFromContext(ctx); ok {
+		return r, true
+	}
+
+	r, ok := ctx.Value(CurrentRequesterKey).(sdkcm.Requester)
+	return r, ok
+}
+
+func bearerToken(header string) string {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+// cacheTTL caps ttl by the token expiry, 0 ttl means until the token expires
+func cacheTTL(ttl time.Duration, expiresAt time.Time) time.Duration {
+	remaining := time.Until(expiresAt)
+	if ttl <= 0 || ttl > remaining {
+		return remaining
+	}
+	return ttl
+}
+
+func writeCache(ctx context.Context, cache Caching, sig string, r sdkcm.Requester, ttl time.Duration) error {
+	if c, ok := cache.(CachingWithTTL); ok {
+		return c.WriteCurrentUserWithTTL(ctx, sig, r, ttl)
+	}
+	return cache.WriteCurrentUser(ctx, sig, r)
+}
@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/cache"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+const testSecret = "test-secret"
+
+type testServiceContext struct {
+	components map[string]interface{}
+}
+
+func (sc testServiceContext) Logger(prefix string) logger.Logger {
+	return logger.GetCurrent().GetLogger(prefix)
+}
+
+func (sc testServiceContext) Get(prefix string) (interface{}, bool) {
+	c, ok := sc.components[prefix]
+	return c, ok
+}
+
+func (sc testServiceContext) MustGet(prefix string) interface{} {
+	return sc.components[prefix]
+}
+
+type testUser struct {
+	id uint32
+}
+
+func (u testUser) UserID() uint32        { return u.id }
+func (u testUser) GetSystemRole() string { return "user" }
+func (u testUser) GetUser() interface{}  { return u }
+
+var _ CachingWithTTL = (*cache.AuthCaching[testUser])(nil)
+
+type testUserProvider struct {
+	testServiceContext
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *testUserProvider) GetCurrentUser(ctx context.Context, oauthID string) (sdkcm.User, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+
+	if oauthID != "user-1" {
+		return nil, errors.New("user not found")
+	}
+	return testUser{id: 1}, nil
+}
+
+type testCache struct {
+	mu    sync.Mutex
+	users map[string]sdkcm.Requester
+	ttl   time.Duration
+}
+
+func (c *testCache) GetCurrentUser(ctx context.Context, sig string) (sdkcm.Requester, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u, ok := c.users[sig]; ok {
+		return u, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (c *testCache) WriteCurrentUser(ctx context.Context, sig string, u sdkcm.Requester) error {
+	return c.WriteCurrentUserWithTTL(ctx, sig, u, 0)
+}
+
+func (c *testCache) WriteCurrentUserWithTTL(ctx context.Context, sig string, u sdkcm.Requester, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.users[sig] = u
+	c.ttl = ttl
+	return nil
+}
+
+func signHS256(t *testing.T, secret, subject string, expiresIn time.Duration) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+	}).SignedString([]byte(secret))
+	assert.Nil(t, err, "must be nil")
+	return token
+}
+
+func newAuthRouter(t *testing.T, validator TokenValidator, cache Caching) (*gin.Engine, *testUserProvider) {
+	logger.InitServLogger(false)
+	gin.SetMode(gin.TestMode)
+
+	sc := testServiceContext{components: map[string]interface{}{TokenValidatorPrefix: validator}}
+	provider := &testUserProvider{testServiceContext: sc}
+
+	router := gin.New()
+	router.GET("/me", RequiredAuth(sc, provider, cache, WithCacheTTL(time.Minute)), func(c *gin.Context) {
+		r, ok := RequesterFromContext(c)
+		assert.True(t, ok, "requester should be set")
+
+		fromRequest, ok := RequesterFromContext(c.Request.Context())
+		assert.True(t, ok, "requester should be in the request context")
+		assert.Equal(t, r, fromRequest, "should be equal")
+
+		c.JSON(http.StatusOK, gin.H{"id": r.UserID(), "oauth_id": r.OAuthID()})
+	})
+
+	return router, provider
+}
+
+func requestWithToken(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequiredAuthHS256(t *testing.T) {
+	validator, err := NewJWTValidator(JWTConfig{Secret: testSecret})
+	assert.Nil(t, err, "must be nil")
+
+	cache := &testCache{users: map[string]sdkcm.Requester{}}
+	router, provider := newAuthRouter(t, validator, cache)
+
+	valid := signHS256(t, testSecret, "user-1", time.Hour)
+	for i := 0; i < 2; i++ {
+		w := requestWithToken(router, valid)
+		assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+		assert.JSONEq(t, `{"id":1,"oauth_id":"user-1"}`, w.Body.String(), "should be equal")
+	}
+	assert.Equal(t, 1, provider.calls, "second request should hit the cache")
+	assert.Equal(t, time.Minute, cache.ttl, "should be equal")
+
+	for _, c := range []struct {
+		token string
+		code  string
+	}{
+		{token: "", code: "missing_token"},
+		{token: signHS256(t, testSecret, "user-1", -time.Minute), code: "token_expired"},
+		{token: signHS256(t, "forged-secret", "user-1", time.Hour), code: "invalid_token"},
+		{token: "not.a.jwt", code: "invalid_token"},
+		{token: signHS256(t, testSecret, "user-2", time.Hour), code: "invalid_token"},
+	} {
+		w := requestWithToken(router, c.token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "should be equal")
+		assert.Contains(t, w.Body.String(), `"code":"`+c.code+`"`, "should be equal")
+	}
+
+	// alg none must be rejected
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		Subject:   "user-1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, http.StatusUnauthorized, requestWithToken(router, unsigned).Code, "should be equal")
+}
+
+func TestRequiredAuthJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err, "must be nil")
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "key-1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwksServer.Close()
+
+	validator, err := NewJWTValidator(JWTConfig{JWKSURL: jwksServer.URL, Issuer: "auth-service"})
+	assert.Nil(t, err, "must be nil")
+	router, _ := newAuthRouter(t, validator, nil)
+
+	sign := func(kid, issuer string, key *rsa.PrivateKey) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+			Subject:   "user-1",
+			Issuer:    issuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		})
+		token.Header["kid"] = kid
+		s, err := token.SignedString(key)
+		assert.Nil(t, err, "must be nil")
+		return s
+	}
+
+	assert.Equal(t, http.StatusOK, requestWithToken(router, sign("key-1", "auth-service", key)).Code, "should be equal")
+	assert.Equal(t, http.StatusUnauthorized, requestWithToken(router, sign("key-1", "other", key)).Code, "should be equal")
+	assert.Equal(t, http.StatusUnauthorized, requestWithToken(router, sign("key-2", "auth-service", key)).Code, "should be equal")
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, http.StatusUnauthorized, requestWithToken(router, sign("key-1", "auth-service", otherKey)).Code, "should be equal")
+
+	// HS256 tokens are not accepted without a secret
+	assert.Equal(t, http.StatusUnauthorized, requestWithToken(router, signHS256(t, "", "user-1", time.Hour)).Code, "should be equal")
+}
+
+func TestNewJWTValidatorRequiresKey(t *testing.T) {
+	_, err := NewJWTValidator(JWTConfig{})
+	assert.NotNil(t, err, "should be an error")
+}
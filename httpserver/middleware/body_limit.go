@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+// the body before any limit, so routes can raise the global limit
+const originalBodyKey = "original_body"
+
+// ErrBodyTooLarge is responded as 413 with the limit in its message
+func ErrBodyTooLarge(limit int64) sdkcm.AppError {
+	return sdkcm.AppError{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Code:       "body_too_large",
+		Message:    fmt.Sprintf("request body is larger than the limit of %d bytes", limit),
+	}
+}
+
+// MaxBodySize limits request bodies to limit bytes. Larger bodies are rejected
+// with 413 from their Content-Length, or when handlers read past the limit,
+// so binding never buffers more than limit. Handlers add the binding error
+// with c.Error(err) for the 413 response:
+//
+//	if err := c.ShouldBindJSON(&req); err != nil {
+//		_ = c.Error(err)
+//		return
+//	}
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limitBody(c, limit) {
+			return
+		}
+		c.Next()
+		respondBodyTooLarge(c)
+	}
+}
+
+// BodyLimit replaces the limit of MaxBodySize for a route, e.g. uploads:
+//
+//	engine.POST("/videos", middleware.BodyLimit(500<<20), uploadHandler)
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return MaxBodySize(limit)
+}
+
+// limitBody reports false when the body is rejected right away
+func limitBody(c *gin.Context, limit int64) bool {
+	if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return true
+	}
+
+	original := c.Request.Body
+	if v, ok := c.Get(originalBodyKey); ok {
+		original = v.(io.ReadCloser)
+	} else {
+		c.Set(originalBodyKey, original)
+	}
+
+	if c.Request.ContentLength > limit {
+		writeAppError(c, ErrBodyTooLarge(limit))
+		return false
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, original, limit)
+	return true
+}
+
+// respondBodyTooLarge responds 413 when a handler added the error of reading
+// past the limit and didn't respond
+func respondBodyTooLarge(c *gin.Context) {
+	if c.Writer.Written() {
+		return
+	}
+	for _, e := range c.Errors {
+		var tooLarge *http.MaxBytesError
+		if errors.As(e.Err, &tooLarge) {
+			writeAppError(c, e.Err)
+			return
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBodyLimitRouter(limit int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.MaxMultipartMemory = 1 << 10
+	router.Use(MaxBodySize(limit))
+
+	bindJSON := func(c *gin.Context) {
+		var req struct {
+			Data string `json:"data"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"size": len(req.Data)})
+	}
+	router.POST("/json", bindJSON)
+	router.POST("/large", BodyLimit(limit*10), bindJSON)
+	router.POST("/upload", func(c *gin.Context) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"size": file.Size})
+	})
+	return router
+}
+
+// endlessJSON is a json string of n bytes, generated without holding it in memory
+type endlessJSON struct {
+	n, read int
+}
+
+func (r *endlessJSON) Read(p []byte) (int, error) {
+	if r.read >= r.n {
+		return 0, io.EOF
+	}
+	for i := range p {
+		switch {
+		case r.read == 0:
+			p[i] = '"'
+		case r.read == r.n-1:
+			p[i] = '"'
+			r.read++
+			return i + 1, nil
+		default:
+			p[i] = 'a'
+		}
+		r.read++
+	}
+	return len(p), nil
+}
+
+func postBody(router *gin.Engine, path, contentType string, body io.Reader, contentLength int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, body)
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = contentLength
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func jsonBody(size int) string {
+	return `{"data":"` + strings.Repeat("a", size) + `"}`
+}
+
+func assertBodyTooLarge(t *testing.T, w *httptest.ResponseRecorder, limit int64) {
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "should be equal")
+
+	var resp map[string]interface{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp), "must be nil")
+	assert.Equal(t, "body_too_large", resp["code"], "should be equal")
+	assert.Equal(t, ErrBodyTooLarge(limit).Message, resp["message"], "should include the limit")
+}
+
+func TestMaxBodySize(t *testing.T) {
+	router := newBodyLimitRouter(1 << 10)
+
+	body := jsonBody(100)
+	w := postBody(router, "/json", "application/json", strings.NewReader(body), int64(len(body)))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.JSONEq(t, `{"size":100}`, w.Body.String(), "should be equal")
+
+	// rejected from the content length, before reading
+	body = jsonBody(2 << 10)
+	w = postBody(router, "/json", "application/json", strings.NewReader(body), int64(len(body)))
+	assertBodyTooLarge(t, w, 1<<10)
+
+	// chunked, rejected while binding
+	w = postBody(router, "/json", "application/json", strings.NewReader(body), -1)
+	assertBodyTooLarge(t, w, 1<<10)
+
+	// the route raises the limit
+	w = postBody(router, "/large", "application/json", strings.NewReader(body), -1)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	body = jsonBody(20 << 10)
+	w = postBody(router, "/large", "application/json", strings.NewReader(body), -1)
+	assertBodyTooLarge(t, w, 10<<10)
+}
+
+func TestMaxBodySizeMultipart(t *testing.T) {
+	router := newBodyLimitRouter(4 << 10)
+
+	upload := func(size int) (*bytes.Buffer, string) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		part, err := mw.CreateFormFile("file", "avatar.png")
+		assert.Nil(t, err, "must be nil")
+		_, err = part.Write(bytes.Repeat([]byte{1}, size))
+		assert.Nil(t, err, "must be nil")
+		assert.Nil(t, mw.Close(), "must be nil")
+		return &buf, mw.FormDataContentType()
+	}
+
+	body, contentType := upload(2 << 10)
+	w := postBody(router, "/upload", contentType, body, -1)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.JSONEq(t, `{"size":2048}`, w.Body.String(), "should be equal")
+
+	body, contentType = upload(8 << 10)
+	w = postBody(router, "/upload", contentType, body, -1)
+	assertBodyTooLarge(t, w, 4<<10)
+}
+
+func TestMaxBodySizeMemory(t *testing.T) {
+	router := newBodyLimitRouter(1 << 20)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	// 64 MiB would be buffered whole without the limit
+	w := postBody(router, "/json", "application/json", &endlessJSON{n: 64 << 20}, -1)
+
+	runtime.ReadMemStats(&after)
+	assertBodyTooLarge(t, w, 1<<20)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(16<<20), "memory should be bounded by the limit")
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// ClientIP returns the ip of the client, read from the remote ip headers
+// only when the request comes from a trusted proxy (gin-trusted-proxies).
+// It falls back to the remote address when it isn't host:port, e.g. unix sockets.
+func ClientIP(c *gin.Context) string {
+	if ip := c.ClientIP(); ip != "" {
+		return ip
+	}
+	return c.Request.RemoteAddr
+}
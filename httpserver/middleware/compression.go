@@ -0,0 +1,372 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	encodingGzip   = "gzip"
+	encodingBrotli = "br"
+	// larger buffers are not kept in the pool
+	maxPooledBufferSize = 64 << 10
+)
+
+// compressed already or streamed, compressing them is a waste or breaks them
+var defaultExcludedContentTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-brotli",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+	"application/octet-stream", "text/event-stream",
+}
+
+type CompressionConfig struct {
+	// 1 (fastest) to 9 (smallest), 0 or -1 => default of each encoding
+	Level int `json:"level"`
+	// smaller responses are sent as is
+	MinSize int `json:"min_size"`
+	// content type prefixes not compressed, in addition to images, archives and event streams
+	ExcludedContentTypes []string `json:"excluded_content_types"`
+	// path prefixes not compressed
+	ExcludedPaths []string `json:"excluded_paths"`
+}
+
+func (cfg CompressionConfig) Validate() error {
+	if cfg.Level < -1 || cfg.Level > 9 {
+		return errors.New("compression: level must be -1 or between 1 and 9")
+	}
+	if cfg.MinSize < 0 {
+		return errors.New("compression: min size must not be negative")
+	}
+	return nil
+}
+
+// Compression compresses responses with brotli or gzip as the client accepts.
+// Responses are buffered up to MinSize to decide, flushing decides right away
+// for streams. Websocket upgrades and event streams are not compressed.
+func Compression(cfg CompressionConfig) gin.HandlerFunc {
+	c := newCompressor(cfg)
+
+	return func(ctx *gin.Context) {
+		if !c.isEligible(ctx.Request) {
+			ctx.Next()
+			return
+		}
+
+		vary(ctx.Writer.Header())
+
+		encoding := negotiateEncoding(ctx.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			ctx.Next()
+			return
+		}
+
+		w := c.writers.Get().(*compressWriter)
+		w.reset(ctx.Writer, encoding, ctx.Request.Method == http.MethodHead)
+		ctx.Writer = w
+
+		panicked := true
+		defer func() {
+			// a panic response is written by the recovery middleware instead
+			w.finish(panicked)
+			ctx.Writer = w.ResponseWriter
+			w.reset(nil, "", false)
+			c.writers.Put(w)
+		}()
+
+		ctx.Next()
+		panicked = false
+	}
+}
+
+type compressor struct {
+	cfg           CompressionConfig
+	excludedTypes []string
+	gzipWriters   sync.Pool
+	brotliWriters sync.Pool
+	writers       sync.Pool
+}
+
+func newCompressor(cfg CompressionConfig) *compressor {
+	c := &compressor{
+		cfg:           cfg,
+		excludedTypes: append(append([]string(nil), defaultExcludedContentTypes...), cfg.ExcludedContentTypes...),
+	}
+
+	gzipLevel, brotliLevel := gzip.DefaultCompression, brotli.DefaultCompression
+	if cfg.Level > 0 {
+		gzipLevel, brotliLevel = cfg.Level, cfg.Level
+	}
+
+	c.gzipWriters.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzipLevel)
+		return w
+	}
+	c.brotliWriters.New = func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, brotliLevel)
+	}
+	c.writers.New = func() interface{} {
+		return &compressWriter{compressor: c}
+	}
+	return c
+}
+
+func (c *compressor) isEligible(r *http.Request) bool {
+	// the connection is hijacked
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, p := range c.cfg.ExcludedPaths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *compressor) isExcludedType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	// svg is text
+	if strings.HasPrefix(contentType, "image/svg+xml") {
+		return false
+	}
+	for _, t := range c.excludedTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func (c *compressor) getEncoder(encoding string, w io.Writer) encoder {
+	var enc encoder
+	if encoding == encodingBrotli {
+		enc = c.brotliWriters.Get().(*brotli.Writer)
+	} else {
+		enc = c.gzipWriters.Get().(*gzip.Writer)
+	}
+	enc.Reset(w)
+	return enc
+}
+
+func (c *compressor) putEncoder(enc encoder) {
+	enc.Reset(io.Discard)
+	switch e := enc.(type) {
+	case *brotli.Writer:
+		c.brotliWriters.Put(e)
+	case *gzip.Writer:
+		c.gzipWriters.Put(e)
+	}
+}
+
+// negotiateEncoding prefers brotli, then gzip, "" => identity
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := map[string]bool{}
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+
+		if name == "*" {
+			wildcard = q > 0
+			continue
+		}
+		accepted[name] = q > 0
+	}
+
+	for _, encoding := range []string{encodingBrotli, encodingGzip} {
+		if ok, listed := accepted[encoding]; ok || (!listed && wildcard) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+func vary(h http.Header) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), "Accept-Encoding") {
+				return
+			}
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+}
+
+type compressState int
+
+const (
+	stateUndecided compressState = iota
+	statePlain
+	stateCompressed
+)
+
+// compressWriter buffers the beginning of the response until it's
+// known whether it's compressed
+type compressWriter struct {
+	gin.ResponseWriter
+	compressor *compressor
+
+	encoding string
+	head     bool
+	state    compressState
+	buf      []byte
+	enc      encoder
+}
+
+func (w *compressWriter) reset(rw gin.ResponseWriter, encoding string, head bool) {
+	w.ResponseWriter = rw
+	w.encoding = encoding
+	w.head = head
+	w.state = stateUndecided
+	w.buf = w.buf[:0]
+	if cap(w.buf) > maxPooledBufferSize {
+		w.buf = nil
+	}
+	w.enc = nil
+}
+
+// decide compresses the response unless it's small, has no body,
+// is encoded or partial already, or its content type is excluded
+func (w *compressWriter) decide(final bool) {
+	header := w.ResponseWriter.Header()
+	status := w.ResponseWriter.Status()
+
+	w.state = statePlain
+	switch {
+	case w.head,
+		status < http.StatusOK, status == http.StatusNoContent, status == http.StatusNotModified,
+		status == http.StatusPartialContent, header.Get("Content-Range") != "",
+		header.Get("Content-Encoding") != "",
+		final && len(w.buf) < w.compressor.cfg.MinSize,
+		len(w.buf) == 0 && final:
+		return
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" && len(w.buf) > 0 {
+		// sniffing the compressed body would give the wrong type
+		contentType = http.DetectContentType(w.buf)
+		header.Set("Content-Type", contentType)
+	}
+	if w.compressor.isExcludedType(contentType) {
+		return
+	}
+
+	w.state = stateCompressed
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	// the compressed representation is not byte for byte the same
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+	w.enc = w.compressor.getEncoder(w.encoding, w.ResponseWriter)
+}
+
+// writeBuffered sends the buffered beginning once decided
+func (w *compressWriter) writeBuffered() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.state == stateCompressed {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	switch w.state {
+	case statePlain:
+		return w.ResponseWriter.Write(b)
+	case stateCompressed:
+		return w.enc.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.compressor.cfg.MinSize {
+		w.decide(false)
+		if err := w.writeBuffered(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers, the response can't be compressed anymore
+func (w *compressWriter) WriteHeaderNow() {
+	if w.state == stateUndecided {
+		w.state = statePlain
+		_ = w.writeBuffered()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush sends what is written so far, streams are decided on the first flush
+func (w *compressWriter) Flush() {
+	if w.state == stateUndecided {
+		w.decide(false)
+		_ = w.writeBuffered()
+	}
+	if w.state == stateCompressed {
+		_ = w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap is for http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish sends the buffered response or the end of the compressed one,
+// the buffer is dropped when the handlers panicked
+func (w *compressWriter) finish(panicked bool) {
+	if w.state == stateUndecided {
+		if panicked {
+			return
+		}
+		w.decide(true)
+		_ = w.writeBuffered()
+	}
+	if w.state == stateCompressed {
+		_ = w.enc.Close()
+		w.compressor.putEncoder(w.enc)
+	}
+}
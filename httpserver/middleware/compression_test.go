@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var largeBody = strings.Repeat(`{"id":1,"name":"item"},`, 200)
+
+func newCompressionRouter(cfg CompressionConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Compression(cfg))
+	router.GET("/large", func(c *gin.Context) {
+		c.Header("ETag", `"v1"`)
+		c.Data(http.StatusOK, "application/json", []byte(largeBody))
+	})
+	router.GET("/small", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	router.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", []byte(largeBody))
+	})
+	router.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.String(http.StatusOK, largeBody)
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		c.String(http.StatusOK, "part 1\n")
+		c.Writer.Flush()
+		c.String(http.StatusOK, "part 2\n")
+	})
+	router.GET("/empty", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+func getCompressed(router *gin.Engine, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompression(t *testing.T) {
+	router := newCompressionRouter(CompressionConfig{Level: -1, MinSize: 1024})
+
+	w := getCompressed(router, "/large", "gzip, deflate")
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "should be equal")
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), "should be equal")
+	assert.Equal(t, `W/"v1"`, w.Header().Get("ETag"), "etag should be weak")
+	assert.Less(t, w.Body.Len(), len(largeBody), "should be smaller")
+
+	zr, err := gzip.NewReader(w.Body)
+	assert.Nil(t, err, "must be nil")
+	body, err := io.ReadAll(zr)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, largeBody, string(body), "should be equal")
+
+	w = getCompressed(router, "/large", "gzip;q=0.8, br")
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"), "should prefer brotli")
+	body, err = io.ReadAll(brotli.NewReader(w.Body))
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, largeBody, string(body), "should be equal")
+
+	w = getCompressed(router, "/large", "br;q=0, *")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "should be equal")
+
+	w = getCompressed(router, "/large", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "should not be compressed")
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), "should be equal")
+	assert.Equal(t, largeBody, w.Body.String(), "should be equal")
+}
+
+func TestCompressionSkips(t *testing.T) {
+	router := newCompressionRouter(CompressionConfig{Level: 5, MinSize: 1024, ExcludedPaths: []string{"/stream"}})
+
+	w := getCompressed(router, "/small", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "responses below min size should not be compressed")
+	assert.JSONEq(t, `{"ok":true}`, w.Body.String(), "should be equal")
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), "should be equal")
+
+	w = getCompressed(router, "/image", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "images should not be compressed")
+	assert.Equal(t, largeBody, w.Body.String(), "should be equal")
+
+	w = getCompressed(router, "/events", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "event streams should not be compressed")
+
+	w = getCompressed(router, "/stream", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "excluded paths should not be compressed")
+	assert.Empty(t, w.Header().Get("Vary"), "should be empty")
+
+	w = getCompressed(router, "/empty", "gzip")
+	assert.Equal(t, http.StatusNoContent, w.Code, "should be equal")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "should be empty")
+}
+
+func TestCompressionFlush(t *testing.T) {
+	router := newCompressionRouter(CompressionConfig{Level: -1, MinSize: 1024})
+
+	// flushed responses are compressed whatever their size
+	w := getCompressed(router, "/stream", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "should be equal")
+	assert.True(t, w.Flushed, "should be flushed")
+
+	zr, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+	assert.Nil(t, err, "must be nil")
+	body, err := io.ReadAll(zr)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "part 1\npart 2\n", string(body), "should be equal")
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "br", negotiateEncoding("gzip, deflate, br"), "should be equal")
+	assert.Equal(t, "gzip", negotiateEncoding("GZIP"), "should be equal")
+	assert.Equal(t, "", negotiateEncoding("identity"), "should be equal")
+	assert.Equal(t, "", negotiateEncoding("gzip;q=0"), "should be equal")
+	assert.Equal(t, "br", negotiateEncoding("*"), "should be equal")
+}
+
+func TestCompressionConfigValidate(t *testing.T) {
+	assert.Nil(t, CompressionConfig{Level: -1}.Validate(), "must be nil")
+	assert.Nil(t, CompressionConfig{Level: 9, MinSize: 1024}.Validate(), "must be nil")
+	assert.Nil(t, CompressionConfig{}.Validate(), "must be nil")
+	assert.NotNil(t, CompressionConfig{Level: -2}.Validate(), "should be an error")
+	assert.NotNil(t, CompressionConfig{Level: 10}.Validate(), "should be an error")
+	assert.NotNil(t, CompressionConfig{Level: -1, MinSize: -1}.Validate(), "should be an error")
+}
+
+// BenchmarkCompression shows the allocations per request, the writers are pooled
+func BenchmarkCompression(b *testing.B) {
+	for _, encoding := range []string{"gzip", "br"} {
+		b.Run(encoding, func(b *testing.B) {
+			router := newCompressionRouter(CompressionConfig{Level: -1, MinSize: 1024})
+			req := httptest.NewRequest(http.MethodGet, "/large", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+			}
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package middleware
 
 import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,3 +25,110 @@ func AllowCORS() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+var ErrCORSWildcardWithCredentials = errors.New("cors: wildcard origin \"*\" cannot be used with allow credentials")
+
+type CORSConfig struct {
+	AllowOrigins     []string      `json:"allow_origins"`
+	AllowMethods     []string      `json:"allow_methods"`
+	AllowHeaders     []string      `json:"allow_headers"`
+	AllowCredentials bool          `json:"allow_credentials"`
+	MaxAge           time.Duration `json:"max_age"`
+}
+
+// Validate rejects configs which browsers would refuse
+func (cfg CORSConfig) Validate() error {
+	if len(cfg.AllowOrigins) == 0 {
+		return errors.New("cors: allow origins must not be empty")
+	}
+
+	if cfg.AllowCredentials && cfg.allowAllOrigins() {
+		return ErrCORSWildcardWithCredentials
+	}
+
+	return nil
+}
+
+func (cfg CORSConfig) allowAllOrigins() bool {
+	for _, o := range cfg.AllowOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg CORSConfig) isAllowedOrigin(origin string) bool {
+	for _, o := range cfg.AllowOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS returns a middleware handling CORS with cfg, overrides are configs
+// for route groups keyed by path prefix, the longest matching prefix wins.
+// Preflight requests are answered here without reaching the routes.
+func CORS(cfg CORSConfig, overrides map[string]CORSConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		rule := corsRuleFor(c.Request.URL.Path, cfg, overrides)
+		isPreflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+
+		if !rule.isAllowedOrigin(origin) {
+			if isPreflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if rule.allowAllOrigins() && !rule.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if rule.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !isPreflight {
+			c.Next()
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", strings.Join(rule.AllowMethods, ", "))
+		if len(rule.AllowHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(rule.AllowHeaders, ", "))
+		} else if reqHeaders := c.GetHeader("Access-Control-Request-Headers"); reqHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", reqHeaders)
+		}
+
+		if rule.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(rule.MaxAge.Seconds())))
+		}
+
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func corsRuleFor(path string, cfg CORSConfig, overrides map[string]CORSConfig) CORSConfig {
+	matched := ""
+	for prefix, override := range overrides {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+			cfg = override
+		}
+	}
+	return cfg
+}
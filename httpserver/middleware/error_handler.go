@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorHandler responds errors as sdkcm.AppError JSON bodies.
+// Handlers either add the error with c.Error(err) and return, or panic with it.
+// Validation errors of binding are responded as 400 with their invalid fields,
+// other errors which are not AppError are responded as 500 internal errors.
+// The root cause (log field) is only responded in gin debug mode.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if p := recover(); p != nil {
+				err, ok := p.(error)
+				if !ok {
+					err = fmt.Errorf("%v", p)
+				}
+				writeAppError(c, err)
+			}
+		}()
+
+		c.Next()
+
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			writeAppError(c, c.Errors.Last().Err)
+		}
+	}
+}
+
+func writeAppError(c *gin.Context, err error) {
+	var appErr sdkcm.AppError
+	var validationErrs validator.ValidationErrors
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		// binding read past the body limit
+		appErr = ErrBodyTooLarge(tooLarge.Limit)
+	case errors.As(err, &appErr):
+		if len(appErr.Fields) == 0 {
+			appErr = appErr.WithFields(sdkcm.ValidationFieldErrors(appErr)...)
+		}
+	case errors.As(err, &validationErrs):
+		// binding errors added as is
+		appErr = sdkcm.ErrValidation(err)
+	case errors.Is(err, sdkcm.ErrInvalidUID):
+		// a malformed uid in a bound body
+		appErr = sdkcm.ErrInvalidUIDParam(err, "id")
+	default:
+		appErr = sdkcm.ErrInternal(err)
+	}
+
+	root := appErr.RootError()
+	if root == nil {
+		root = appErr
+	}
+
+	span := trace.SpanFromContext(c.Request.Context())
+	span.RecordError(root)
+	if appErr.StatusCode >= 500 {
+		span.SetStatus(codes.Error, appErr.Message)
+	}
+
+	appErr.RequestID = RequestIDFromContext(c)
+	translateMessage(c, &appErr)
+
+	if gin.IsDebugging() {
+		appErr.Log = root.Error()
+	} else {
+		appErr.Log = ""
+	}
+
+	c.AbortWithStatusJSON(appErr.StatusCode, appErr)
+}
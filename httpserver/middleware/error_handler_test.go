@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestErrorHandler(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx, span := tracer.Start(c.Request.Context(), c.FullPath())
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	})
+	router.Use(ErrorHandler())
+	router.GET("/not-found", func(c *gin.Context) {
+		_ = c.Error(sdkcm.ErrEntityNotFound("user", sdkcm.ErrDataNotFound))
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic(sdkcm.ErrInvalidRequest(errors.New("bad json")).WithFields(sdkcm.FieldError{Field: "name", Message: "is required"}))
+	})
+	router.GET("/internal", func(c *gin.Context) {
+		_ = c.Error(errors.New("db is down"))
+	})
+	router.GET("/ok", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	for _, c := range []struct {
+		mode   string
+		path   string
+		status int
+		expect string
+	}{
+		{mode: gin.ReleaseMode, path: "/not-found", status: http.StatusNotFound,
+			expect: `{"code":"user_not_found","status_code":404,"message":"user not found"}`},
+		{mode: gin.DebugMode, path: "/not-found", status: http.StatusNotFound,
+			expect: `{"code":"user_not_found","log":"data not found","status_code":404,"message":"user not found"}`},
+		{mode: gin.ReleaseMode, path: "/panic", status: http.StatusBadRequest,
+			expect: `{"code":"invalid_request","status_code":400,"message":"invalid request","fields":[{"field":"name","message":"is required"}]}`},
+		{mode: gin.ReleaseMode, path: "/internal", status: http.StatusInternalServerError,
+			expect: `{"code":"internal_error","status_code":500,"message":"internal server error"}`},
+	} {
+		gin.SetMode(c.mode)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		assert.Equal(t, c.status, w.Code, "should be equal")
+		assert.JSONEq(t, c.expect, w.Body.String(), "should be equal")
+	}
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Equal(t, "ok", w.Body.String(), "should be equal")
+
+	spans := recorder.Ended()
+	assert.Equal(t, 5, len(spans), "should be equal")
+	assert.Equal(t, "exception", spans[0].Events()[0].Name, "error should be recorded")
+	assert.Equal(t, codes.Unset, spans[0].Status().Code, "client errors should not fail the span")
+	assert.Equal(t, codes.Error, spans[3].Status().Code, "server errors should fail the span")
+	assert.Empty(t, spans[4].Events(), "should be empty")
+}
+
+func TestErrorHandlerValidation(t *testing.T) {
+	type signup struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.POST("/raw", func(c *gin.Context) {
+		var req signup
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(err)
+		}
+	})
+	router.POST("/wrapped", func(c *gin.Context) {
+		var req signup
+		if err := c.ShouldBindJSON(&req); err != nil {
+			panic(sdkcm.ErrInvalidRequest(err))
+		}
+	})
+
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.TestMode)
+	for _, path := range []string{"/raw", "/wrapped"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"email":"john"}`)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, "should be equal")
+		assert.JSONEq(t, `{"code":"invalid_request","status_code":400,"message":"invalid request",
+			"fields":[{"field":"Email","rule":"email","message":"must be a valid email address"}]}`, w.Body.String(), "should be equal")
+	}
+}
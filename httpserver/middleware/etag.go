@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ETagConfig struct {
+	// larger responses are sent as is, without ETag
+	MaxBodySize int `json:"max_body_size"`
+}
+
+func (cfg ETagConfig) Validate() error {
+	if cfg.MaxBodySize <= 0 {
+		return errors.New("etag: max body size must be positive")
+	}
+	return nil
+}
+
+// ETag buffers the successful GET responses up to cfg.MaxBodySize to set a
+// strong ETag from their body, and answers If-None-Match with 304. ETags set
+// by handlers are kept, streams and Cache-Control: no-store are not buffered.
+// It must run after Compression, so the ETag is of the uncompressed body.
+func ETag(cfg ETagConfig) gin.HandlerFunc {
+	writers := sync.Pool{New: func() interface{} { return &etagWriter{} }}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := writers.Get().(*etagWriter)
+		w.reset(c.Writer, cfg.MaxBodySize)
+		c.Writer = w
+
+		panicked := true
+		defer func() {
+			// a panic response is written by the recovery middleware instead
+			if !panicked {
+				w.finish(c.Request)
+			}
+			c.Writer = w.ResponseWriter
+			w.reset(nil, 0)
+			if cap(w.buf) <= maxPooledBufferSize {
+				writers.Put(w)
+			}
+		}()
+
+		c.Next()
+		panicked = false
+	}
+}
+
+// etagWriter buffers the response until it ends or is larger than limit
+type etagWriter struct {
+	gin.ResponseWriter
+	limit int
+	plain bool
+	buf   []byte
+}
+
+func (w *etagWriter) reset(rw gin.ResponseWriter, limit int) {
+	w.ResponseWriter = rw
+	w.limit = limit
+	w.plain = false
+	w.buf = w.buf[:0]
+}
+
+// passThrough sends the buffered body and the rest of the response as is
+func (w *etagWriter) passThrough() error {
+	w.plain = true
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+// buffers reports whether the response can still get an ETag
+func (w *etagWriter) buffers(n int) bool {
+	if w.plain {
+		return false
+	}
+	header := w.ResponseWriter.Header()
+	if w.ResponseWriter.Status() != http.StatusOK || len(w.buf)+n > w.limit ||
+		isEventStream(header) || hasNoStore(header) {
+		_ = w.passThrough()
+		return false
+	}
+	return true
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.buffers(len(b)) {
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	return len(b), nil
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	if !w.buffers(len(s)) {
+		return w.ResponseWriter.WriteString(s)
+	}
+	w.buf = append(w.buf, s...)
+	return len(s), nil
+}
+
+// WriteHeaderNow sends the headers, the response can't get an ETag anymore
+func (w *etagWriter) WriteHeaderNow() {
+	_ = w.passThrough()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *etagWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush streams the response, it's sent as is
+func (w *etagWriter) Flush() {
+	_ = w.passThrough()
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap is for http.ResponseController
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish sets the ETag of the buffered response, then answers 304 when
+// it matches If-None-Match or sends the body
+func (w *etagWriter) finish(r *http.Request) {
+	if w.plain {
+		return
+	}
+
+	header := w.ResponseWriter.Header()
+	if w.ResponseWriter.Status() != http.StatusOK || hasNoStore(header) {
+		_ = w.passThrough()
+		return
+	}
+
+	etag := header.Get("ETag")
+	if etag == "" {
+		sum := sha1.Sum(w.buf)
+		etag = `"` + hex.EncodeToString(sum[:]) + `"`
+		header.Set("ETag", etag)
+	}
+
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		w.ResponseWriter.WriteHeaderNow()
+		w.buf = w.buf[:0]
+		return
+	}
+
+	_ = w.passThrough()
+}
+
+func hasNoStore(h http.Header) bool {
+	return strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-store")
+}
+
+// etagMatch compares the If-None-Match list with etag weakly, as RFC 9110 requires
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newETagRouter(cfg ETagConfig, middlewares ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middlewares...)
+	router.Use(ETag(cfg))
+	router.GET("/users", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"users": []string{"alice", "bob"}})
+	})
+	router.GET("/large", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", []byte(largeBody))
+	})
+	router.GET("/versioned", func(c *gin.Context) {
+		c.Header("ETag", `"v2"`)
+		c.JSON(http.StatusOK, gin.H{"version": 2})
+	})
+	router.GET("/private", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{"secret": true})
+	})
+	router.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.String(http.StatusOK, "data: 1\n\n")
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	})
+	router.POST("/users", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"created": true})
+	})
+	return router
+}
+
+func getETag(router *gin.Engine, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestETag(t *testing.T) {
+	router := newETagRouter(ETagConfig{MaxBodySize: 1 << 10})
+
+	w := getETag(router, http.MethodGet, "/users", nil)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.JSONEq(t, `{"users":["alice","bob"]}`, w.Body.String(), "should be equal")
+	etag := w.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{40}"$`, etag, "should be a strong etag")
+	assert.Equal(t, etag, getETag(router, http.MethodGet, "/users", nil).Header().Get("ETag"), "should be stable")
+
+	w = getETag(router, http.MethodGet, "/users", http.Header{"If-None-Match": {`"other", ` + etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code, "should be equal")
+	assert.Empty(t, w.Body.String(), "should be empty")
+	assert.Empty(t, w.Header().Get("Content-Type"), "should be empty")
+	assert.Equal(t, etag, w.Header().Get("ETag"), "should be equal")
+
+	// weak comparison
+	assert.Equal(t, http.StatusNotModified, getETag(router, http.MethodGet, "/users", http.Header{"If-None-Match": {"W/" + etag}}).Code, "should be equal")
+	assert.Equal(t, http.StatusNotModified, getETag(router, http.MethodGet, "/users", http.Header{"If-None-Match": {"*"}}).Code, "should be equal")
+	assert.Equal(t, http.StatusOK, getETag(router, http.MethodGet, "/users", http.Header{"If-None-Match": {`"other"`}}).Code, "should be equal")
+
+	// handler etags are kept
+	w = getETag(router, http.MethodGet, "/versioned", nil)
+	assert.Equal(t, `"v2"`, w.Header().Get("ETag"), "should be equal")
+	assert.Equal(t, http.StatusNotModified, getETag(router, http.MethodGet, "/versioned", http.Header{"If-None-Match": {`"v2"`}}).Code, "should be equal")
+}
+
+func TestETagSkips(t *testing.T) {
+	router := newETagRouter(ETagConfig{MaxBodySize: 1 << 10})
+
+	for _, path := range []string{"/large", "/private", "/events", "/missing"} {
+		w := getETag(router, http.MethodGet, path, http.Header{"If-None-Match": {"*"}})
+		assert.NotEqual(t, http.StatusNotModified, w.Code, path+" should not be 304")
+		assert.Empty(t, w.Header().Get("ETag"), path+" should not have an etag")
+		assert.NotEmpty(t, w.Body.String(), path+" should be sent")
+	}
+	assert.Equal(t, largeBody, getETag(router, http.MethodGet, "/large", nil).Body.String(), "should be equal")
+
+	w := getETag(router, http.MethodPost, "/users", nil)
+	assert.Empty(t, w.Header().Get("ETag"), "should not have an etag")
+}
+
+func TestETagCompression(t *testing.T) {
+	router := newETagRouter(ETagConfig{MaxBodySize: 64 << 10}, Compression(CompressionConfig{Level: -1, MinSize: 1024}))
+	gzip := http.Header{"Accept-Encoding": {"gzip"}}
+
+	plain := getETag(router, http.MethodGet, "/large", nil)
+	compressed := getETag(router, http.MethodGet, "/large", gzip)
+	assert.Equal(t, "gzip", compressed.Header().Get("Content-Encoding"), "should be equal")
+	assert.Equal(t, "W/"+plain.Header().Get("ETag"), compressed.Header().Get("ETag"), "etag should be of the uncompressed body")
+
+	w := getETag(router, http.MethodGet, "/large", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {compressed.Header().Get("ETag")}})
+	assert.Equal(t, http.StatusNotModified, w.Code, "should be equal")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "should be empty")
+	assert.Empty(t, w.Body.String(), "should be empty")
+}
+
+func TestETagMatch(t *testing.T) {
+	assert.True(t, etagMatch(`"a"`, `"a"`), "should be true")
+	assert.True(t, etagMatch(`W/"a"`, `"a"`), "should be true")
+	assert.True(t, etagMatch(`"b", "a"`, `W/"a"`), "should be true")
+	assert.True(t, etagMatch(`*`, `"a"`), "should be true")
+	assert.False(t, etagMatch(``, `"a"`), "should be false")
+	assert.False(t, etagMatch(`"ab"`, `"a"`), "should be false")
+}
+
+func TestETagConfigValidate(t *testing.T) {
+	assert.Nil(t, ETagConfig{MaxBodySize: 1}.Validate(), "must be nil")
+	assert.NotNil(t, ETagConfig{}.Validate(), "should be an error")
+}
+
+// BenchmarkETag shows the overhead of buffering the responses
+func BenchmarkETag(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(strings.Repeat(`{"id":1,"name":"item"},`, 200))
+
+	for _, bench := range []struct {
+		name string
+		etag bool
+	}{{"none", false}, {"etag", true}} {
+		b.Run(bench.name, func(b *testing.B) {
+			router := gin.New()
+			if bench.etag {
+				router.Use(ETag(ETagConfig{MaxBodySize: 64 << 10}))
+			}
+			router.GET("/", func(c *gin.Context) {
+				c.Data(http.StatusOK, "application/json", body)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+const (
+	// IdempotencyKeyHeader is the header of the client chosen key
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayHeader is set on replayed responses
+	IdempotentReplayHeader = "Idempotent-Replay"
+
+	maxIdempotencyKeyLen = 255
+	// stale records of the memory store are removed at most this often
+	memoryIdempotencyCleanupInterval = time.Minute
+)
+
+var (
+	errIdempotencyInProgress = sdkcm.AppError{StatusCode: http.StatusConflict, Code: "idempotency_in_progress", Message: "a request with the same idempotency key is in progress"}
+	errIdempotencyKeyInvalid = sdkcm.AppError{StatusCode: http.StatusBadRequest, Code: "invalid_idempotency_key", Message: "idempotency key must be 1 to 255 characters"}
+	errIdempotencyKeyMissing = sdkcm.AppError{StatusCode: http.StatusBadRequest, Code: "idempotency_key_required", Message: "Idempotency-Key header is required"}
+)
+
+// these are not replayed, they belong to the original response only
+var unreplayedHeaders = []string{"Content-Length", "Date", "Set-Cookie", "X-Request-Id"}
+
+// IdempotentResponse is the response replayed for the requests with a completed key
+type IdempotentResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	// the body was streamed or too large to be kept, only the status and headers are replayed
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// IdempotencyStore records the keys of idempotent requests, it must be atomic
+// across the replicas sharing it
+type IdempotencyStore interface {
+	// Start records key as in progress for lockTTL unless it's recorded already.
+	// It returns the response of a completed key, or started false while it's in progress.
+	Start(ctx context.Context, key string, lockTTL time.Duration) (resp *IdempotentResponse, started bool, err error)
+	// Complete replaces the in progress record of key with its response for ttl
+	Complete(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error
+	// Release removes key, so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+type IdempotencyConfig struct {
+	Store IdempotencyStore `json:"-"`
+	// how long responses are replayed
+	TTL time.Duration `json:"ttl"`
+	// how long a key stays in progress, in case the instance dies while handling it
+	LockTTL time.Duration `json:"lock_ttl"`
+	// larger response bodies are not kept, 0 => only the status and headers are kept
+	MaxBodySize int `json:"max_body_size"`
+	// requests without the header are rejected with 400 instead of handled as usual
+	Required bool `json:"required"`
+}
+
+// Idempotency replays the response of requests with the same Idempotency-Key
+// header, route and requester within cfg.TTL, with the Idempotent-Replay header.
+// Duplicates sent while the first request is handled get 409. Responses of 5xx
+// and panics release the key so clients can retry. It's meant for the routes
+// which need it, after RequiredAuth to tell users apart:
+//
+//	payments := engine.Group("/payments", middleware.RequiredAuth(...), middleware.Idempotency(cfg))
+func Idempotency(cfg IdempotencyConfig) gin.HandlerFunc {
+	lockTTL := cfg.LockTTL
+	if lockTTL <= 0 {
+		lockTTL = cfg.TTL
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		header := c.GetHeader(IdempotencyKeyHeader)
+		if header == "" {
+			if cfg.Required {
+				writeAppError(c, errIdempotencyKeyMissing)
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(header) > maxIdempotencyKeyLen {
+			writeAppError(c, errIdempotencyKeyInvalid)
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := idempotencyKey(c, header)
+
+		replay, started, err := cfg.Store.Start(ctx, key, lockTTL)
+		if err != nil {
+			// handling it without the key could run it twice
+			writeAppError(c, sdkcm.ErrInternal(err))
+			return
+		}
+		if replay != nil {
+			replayResponse(c, replay)
+			return
+		}
+		if !started {
+			writeAppError(c, errIdempotencyInProgress)
+			return
+		}
+
+		w := &replayRecorder{ResponseWriter: c.Writer, limit: cfg.MaxBodySize}
+		c.Writer = w
+
+		panicked := true
+		defer func() {
+			c.Writer = w.ResponseWriter
+
+			// a new context, the request may be canceled already
+			ctx := context.WithoutCancel(ctx)
+
+			status := w.Status()
+			if panicked || status >= http.StatusInternalServerError {
+				if err := cfg.Store.Release(ctx, key); err != nil {
+					logger.FromContext(ctx, "idempotency").Warnf("failed to release idempotency key: %s", err.Error())
+				}
+				return
+			}
+
+			if err := cfg.Store.Complete(ctx, key, w.response(), cfg.TTL); err != nil {
+				logger.FromContext(ctx, "idempotency").Warnf("failed to record idempotent response: %s", err.Error())
+			}
+		}()
+
+		c.Next()
+		panicked = false
+	}
+}
+
+// idempotencyKey scopes the header to the route and the requester
+func idempotencyKey(c *gin.Context, header string) string {
+	user := ""
+	if r, ok := RequesterFromContext(c); ok {
+		user = strconv.FormatUint(uint64(r.UserID()), 10)
+	}
+
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+
+	h := sha256.New()
+	for _, part := range []string{c.Request.Method, route, user, header} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replayResponse(c *gin.Context, resp *IdempotentResponse) {
+	header := c.Writer.Header()
+	for k, v := range resp.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set(IdempotentReplayHeader, "true")
+
+	c.Status(resp.StatusCode)
+	if len(resp.Body) > 0 {
+		_, _ = c.Writer.Write(resp.Body)
+	} else {
+		c.Writer.WriteHeaderNow()
+	}
+	c.Abort()
+}
+
+// replayRecorder keeps the response body up to limit, streamed
+// responses are not kept. It's used by Idempotency and ResponseCache.
+type replayRecorder struct {
+	gin.ResponseWriter
+	limit     int
+	buf       []byte
+	truncated bool
+}
+
+func (w *replayRecorder) keep(n int) bool {
+	if w.truncated || len(w.buf)+n > w.limit || isEventStream(w.Header()) {
+		w.truncated, w.buf = true, nil
+		return false
+	}
+	return true
+}
+
+func (w *replayRecorder) Write(b []byte) (int, error) {
+	if w.keep(len(b)) {
+		w.buf = append(w.buf, b...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *replayRecorder) WriteString(s string) (int, error) {
+	if w.keep(len(s)) {
+		w.buf = append(w.buf, s...)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *replayRecorder) Flush() {
+	w.truncated, w.buf = true, nil
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap is for http.ResponseController
+func (w *replayRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *replayRecorder) response() *IdempotentResponse {
+	header := w.Header().Clone()
+	for _, h := range unreplayedHeaders {
+		header.Del(h)
+	}
+	return &IdempotentResponse{StatusCode: w.Status(), Header: header, Body: w.buf, Truncated: w.truncated}
+}
+
+type idempotencyRecord struct {
+	resp    *IdempotentResponse
+	expires time.Time
+}
+
+type memoryIdempotencyStore struct {
+	mu          sync.Mutex
+	records     map[string]*idempotencyRecord
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+// NewMemoryIdempotencyStore keeps the keys in memory, it only works
+// with a single replica
+func NewMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: map[string]*idempotencyRecord{}, now: time.Now}
+}
+
+func (s *memoryIdempotencyStore) Start(_ context.Context, key string, lockTTL time.Duration) (*IdempotentResponse, bool, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastCleanup) > memoryIdempotencyCleanupInterval {
+		s.cleanup(now)
+	}
+
+	if r, ok := s.records[key]; ok && now.Before(r.expires) {
+		return r.resp, false, nil
+	}
+
+	s.records[key] = &idempotencyRecord{expires: now.Add(lockTTL)}
+	return nil, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = &idempotencyRecord{resp: resp, expires: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// cleanup removes the expired records, it must be called with the lock held
+func (s *memoryIdempotencyStore) cleanup(now time.Time) {
+	for key, r := range s.records {
+		if !now.Before(r.expires) {
+			delete(s.records, key)
+		}
+	}
+	s.lastCleanup = now
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIdempotencyRouter(cfg IdempotencyConfig, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	payments := router.Group("/payments", Idempotency(cfg))
+	payments.POST("", handler)
+	payments.POST("/:id/refund", handler)
+	return router
+}
+
+func postIdempotent(router *gin.Engine, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"amount":100}`))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var calls atomic.Int32
+	router := newIdempotencyRouter(IdempotencyConfig{Store: NewMemoryIdempotencyStore(), TTL: time.Hour, MaxBodySize: 1 << 10},
+		func(c *gin.Context) {
+			n := calls.Add(1)
+			c.Header("Location", "/payments/1")
+			c.JSON(http.StatusCreated, gin.H{"call": n})
+		})
+
+	w := postIdempotent(router, "/payments", "key-1")
+	assert.Equal(t, http.StatusCreated, w.Code, "should be equal")
+	assert.Empty(t, w.Header().Get(IdempotentReplayHeader), "should not be a replay")
+
+	w = postIdempotent(router, "/payments", "key-1")
+	assert.Equal(t, http.StatusCreated, w.Code, "should be equal")
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayHeader), "should be a replay")
+	assert.Equal(t, "/payments/1", w.Header().Get("Location"), "should be equal")
+	assert.JSONEq(t, `{"call":1}`, w.Body.String(), "should replay the first response")
+	assert.Equal(t, int32(1), calls.Load(), "handler should run once")
+
+	// keys are scoped to the route
+	w = postIdempotent(router, "/payments/1/refund", "key-1")
+	assert.JSONEq(t, `{"call":2}`, w.Body.String(), "should be equal")
+
+	// requests without key are handled as usual
+	postIdempotent(router, "/payments", "")
+	postIdempotent(router, "/payments", "")
+	assert.Equal(t, int32(4), calls.Load(), "should be equal")
+}
+
+func TestIdempotencyConcurrentDuplicates(t *testing.T) {
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	router := newIdempotencyRouter(IdempotencyConfig{Store: NewMemoryIdempotencyStore(), TTL: time.Hour, MaxBodySize: 1 << 10},
+		func(c *gin.Context) {
+			calls.Add(1)
+			close(started)
+			<-release
+			c.JSON(http.StatusOK, gin.H{"charged": true})
+		})
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- postIdempotent(router, "/payments", "pay-42") }()
+	<-started
+
+	var wg sync.WaitGroup
+	codes := make([]int, 10)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = postIdempotent(router, "/payments", "pay-42").Code
+		}(i)
+	}
+	wg.Wait()
+
+	for _, code := range codes {
+		assert.Equal(t, http.StatusConflict, code, "duplicates in progress should conflict")
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-first).Code, "should be equal")
+
+	w := postIdempotent(router, "/payments", "pay-42")
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayHeader), "should be a replay")
+	assert.Equal(t, int32(1), calls.Load(), "handler should run once")
+}
+
+func TestIdempotencyRelease(t *testing.T) {
+	var calls atomic.Int32
+	router := newIdempotencyRouter(IdempotencyConfig{Store: NewMemoryIdempotencyStore(), TTL: time.Hour},
+		func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"retry": true})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		})
+
+	assert.Equal(t, http.StatusServiceUnavailable, postIdempotent(router, "/payments", "k").Code, "should be equal")
+
+	// server errors can be retried
+	w := postIdempotent(router, "/payments", "k")
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Empty(t, w.Header().Get(IdempotentReplayHeader), "should not be a replay")
+
+	// the body is larger than MaxBodySize, only the status is replayed
+	w = postIdempotent(router, "/payments", "k")
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayHeader), "should be a replay")
+	assert.Empty(t, w.Body.String(), "should be empty")
+	assert.Equal(t, int32(2), calls.Load(), "should be equal")
+}
+
+func TestIdempotencyKeyValidation(t *testing.T) {
+	router := newIdempotencyRouter(IdempotencyConfig{Store: NewMemoryIdempotencyStore(), TTL: time.Hour, Required: true},
+		func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	assert.Equal(t, http.StatusBadRequest, postIdempotent(router, "/payments", "").Code, "should be equal")
+	assert.Equal(t, http.StatusBadRequest, postIdempotent(router, "/payments", strings.Repeat("k", 256)).Code, "should be equal")
+	assert.Equal(t, http.StatusNoContent, postIdempotent(router, "/payments", "k").Code, "should be equal")
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewMemoryIdempotencyStore()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, started, err := s.Start(ctx, "k", time.Minute)
+	assert.Nil(t, err, "must be nil")
+	assert.True(t, started, "should be started")
+
+	resp, started, _ := s.Start(ctx, "k", time.Minute)
+	assert.Nil(t, resp, "must be nil")
+	assert.False(t, started, "should be in progress")
+
+	// the lock of a dead instance expires
+	now = now.Add(2 * time.Minute)
+	_, started, _ = s.Start(ctx, "k", time.Minute)
+	assert.True(t, started, "should be started again")
+
+	assert.Nil(t, s.Complete(ctx, "k", &IdempotentResponse{StatusCode: http.StatusCreated}, time.Hour), "must be nil")
+	resp, started, _ = s.Start(ctx, "k", time.Minute)
+	assert.False(t, started, "should be completed")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, "should be equal")
+
+	now = now.Add(2 * time.Hour)
+	_, started, _ = s.Start(ctx, "other", time.Minute)
+	assert.True(t, started, "should be started")
+	assert.Len(t, s.records, 1, "expired records should be removed")
+}
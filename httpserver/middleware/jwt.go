@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrTokenExpired = errors.New("token is expired")
+	ErrTokenInvalid = errors.New("token is invalid")
+)
+
+const defaultJWKSRefreshInterval = 15 * time.Minute
+
+// TokenClaims are the claims RequiredAuth needs from a validated token
+type TokenClaims struct {
+	// oauth id of the user
+	Subject   string
+	ExpiresAt time.Time
+}
+
+// TokenValidator validates access tokens. Errors must wrap ErrTokenExpired
+// for expired tokens so clients know to refresh.
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (*TokenClaims, error)
+}
+
+type JWTConfig struct {
+	// HS256 secret
+	Secret string
+	// URL of the JSON Web Key Set verifying RS256 tokens
+	JWKSURL string
+	// checked when not empty
+	Issuer   string
+	Audience string
+	// max time the key set is cached, unknown key ids refresh it sooner
+	JWKSRefreshInterval time.Duration
+	// allowed clock skew
+	Leeway time.Duration
+}
+
+type jwtValidator struct {
+	cfg    JWTConfig
+	parser *jwt.Parser
+	jwks   *jwks
+}
+
+// NewJWTValidator validates HS256 tokens with the secret and RS256 tokens
+// with the key set, at least one of them must be configured
+func NewJWTValidator(cfg JWTConfig) (*jwtValidator, error) {
+	var methods []string
+	if cfg.Secret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.JWKSURL != "" {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("jwt secret or jwks url is required")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	v := &jwtValidator{cfg: cfg, parser: jwt.NewParser(opts...)}
+	if cfg.JWKSURL != "" {
+		refresh := cfg.JWKSRefreshInterval
+		if refresh <= 0 {
+			refresh = defaultJWKSRefreshInterval
+		}
+		v.jwks = &jwks{url: cfg.JWKSURL, refreshInterval: refresh, client: &http.Client{Timeout: 10 * time.Second}}
+	}
+
+	return v, nil
+}
+
+func (v *jwtValidator) ValidateToken(ctx context.Context, token string) (*TokenClaims, error) {
+	claims := jwt.RegisteredClaims{}
+
+	_, err := v.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			return []byte(v.cfg.Secret), nil
+		case *jwt.SigningMethodRSA:
+			kid, _ := t.Header["kid"].(string)
+			return v.jwks.key(ctx, kid)
+		default:
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+	})
+
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, fmt.Errorf("%w: %w", ErrTokenExpired, err)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", ErrTokenInvalid, err)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: subject is missing", ErrTokenInvalid)
+	}
+
+	return &TokenClaims{Subject: claims.Subject, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// jwks caches the RSA keys of a JSON Web Key Set by key id
+type jwks struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func (ks *jwks) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	// unknown key ids refresh the set at most once a minute, so forged
+	// key ids can't flood the key server
+	expired := time.Since(ks.fetchedAt) > ks.refreshInterval
+	if key, ok := ks.keys[kid]; ok && !expired {
+		return key, nil
+	}
+	if expired || time.Since(ks.fetchedAt) > time.Minute {
+		if err := ks.fetch(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// fetch must be called with mu held
+func (ks *jwks) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("cannot decode jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	ks.keys = keys
+	ks.fetchedAt = time.Now()
+	return nil
+}
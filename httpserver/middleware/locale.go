@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/i18n"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+const (
+	// Key of the locale in gin context
+	LocaleKey = "locale"
+
+	LocaleFromQuery  = "query"
+	LocaleFromCookie = "cookie"
+	LocaleFromHeader = "header"
+
+	localeBundleKey = "locale_bundle"
+	// languages of Accept-Language after these are ignored
+	maxAcceptLanguages = 16
+)
+
+type LocaleConfig struct {
+	// nil uses i18n.Default()
+	Bundle *i18n.Bundle `json:"-"`
+	// where the locale is read, in priority order: query, cookie and header (Accept-Language)
+	Sources    []string `json:"sources"`
+	QueryParam string   `json:"query_param"`
+	CookieName string   `json:"cookie_name"`
+}
+
+func (cfg LocaleConfig) Validate() error {
+	if len(cfg.Sources) == 0 {
+		return errors.New("locale: sources are required")
+	}
+	for _, s := range cfg.Sources {
+		switch s {
+		case LocaleFromQuery:
+			if cfg.QueryParam == "" {
+				return errors.New("locale: query param is required")
+			}
+		case LocaleFromCookie:
+			if cfg.CookieName == "" {
+				return errors.New("locale: cookie name is required")
+			}
+		case LocaleFromHeader:
+		default:
+			return fmt.Errorf("locale: unknown source %q, must be query, cookie or header", s)
+		}
+	}
+	return nil
+}
+
+// Locale resolves the locale of the requests from cfg.Sources, the first one
+// having a catalog wins, then the default locale of the bundle. It's stored in
+// gin context and in the request context for i18n.T, and errors responded with
+// a message key are translated in it:
+//
+//	i18n.T(c.Request.Context(), "cart.items", len(items))
+func Locale(cfg LocaleConfig) gin.HandlerFunc {
+	byHeader := false
+	for _, s := range cfg.Sources {
+		byHeader = byHeader || s == LocaleFromHeader
+	}
+
+	return func(c *gin.Context) {
+		bundle := cfg.Bundle
+		if bundle == nil {
+			bundle = i18n.Default()
+		}
+
+		locale := bundle.DefaultLocale()
+		for _, s := range cfg.Sources {
+			if l, ok := bundle.Match(localeCandidates(c, cfg, s)...); ok {
+				locale = l
+				break
+			}
+		}
+
+		if byHeader {
+			c.Writer.Header().Add("Vary", "Accept-Language")
+		}
+		c.Header("Content-Language", locale)
+		c.Set(LocaleKey, locale)
+		c.Set(localeBundleKey, bundle)
+		c.Request = c.Request.WithContext(i18n.WithLocale(c.Request.Context(), locale))
+
+		c.Next()
+	}
+}
+
+func localeCandidates(c *gin.Context, cfg LocaleConfig, source string) []string {
+	switch source {
+	case LocaleFromQuery:
+		if l := c.Query(cfg.QueryParam); l != "" {
+			return []string{l}
+		}
+	case LocaleFromCookie:
+		if l, err := c.Cookie(cfg.CookieName); err == nil && l != "" {
+			return []string{l}
+		}
+	case LocaleFromHeader:
+		return parseAcceptLanguage(c.GetHeader("Accept-Language"))
+	}
+	return nil
+}
+
+// parseAcceptLanguage returns the languages of the header by descending quality,
+// e.g. "en;q=0.8, vi-VN" => vi-VN, en
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+
+	var langs []weighted
+	for i, part := range strings.Split(header, ",") {
+		if i == maxAcceptLanguages {
+			break
+		}
+
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang = strings.TrimSpace(lang)
+		if lang == "" || lang == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			langs = append(langs, weighted{lang, q})
+		}
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	out := make([]string, len(langs))
+	for i, l := range langs {
+		out[i] = l.lang
+	}
+	return out
+}
+
+// translateMessage translates the message key of err in the locale of the request,
+// the message is kept when the key is missing
+func translateMessage(c *gin.Context, err *sdkcm.AppError) {
+	if err.MessageKey == "" {
+		return
+	}
+
+	v, _ := c.Get(localeBundleKey)
+	bundle, _ := v.(*i18n.Bundle)
+	if bundle == nil {
+		bundle = i18n.Default()
+	}
+	locale := c.GetString(LocaleKey)
+	if locale == "" {
+		locale = i18n.LocaleFromContext(c.Request.Context())
+	}
+
+	if msg, ok := bundle.Translate(locale, err.MessageKey, err.MessageArgs...); ok {
+		err.Message = msg
+	}
+}
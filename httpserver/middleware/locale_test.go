@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/i18n"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+func newLocaleBundle(t *testing.T) *i18n.Bundle {
+	b := i18n.NewBundle("en")
+	assert.Nil(t, b.AddMessages("en", map[string]interface{}{
+		"hello":  "hello",
+		"errors": map[string]interface{}{"order_not_found": "order %d not found"},
+	}), "must be nil")
+	assert.Nil(t, b.AddMessages("vi", map[string]interface{}{
+		"hello":  "xin chào",
+		"errors": map[string]interface{}{"order_not_found": "không tìm thấy đơn hàng %d"},
+	}), "must be nil")
+	return b
+}
+
+func newLocaleRouter(cfg LocaleConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(ErrorHandler(), Locale(cfg))
+	router.GET("/hello", func(c *gin.Context) {
+		c.String(http.StatusOK, cfg.Bundle.T(c.Request.Context(), "hello"))
+	})
+	router.GET("/orders/:id", func(c *gin.Context) {
+		_ = c.Error(sdkcm.ErrEntityNotFound("Order", errors.New("record not found")).
+			WithMessageKey("errors.order_not_found", 42))
+	})
+	router.GET("/unknown", func(c *gin.Context) {
+		_ = c.Error(sdkcm.ErrEntityNotFound("Order", nil).WithMessageKey("errors.unknown"))
+	})
+	return router
+}
+
+func TestLocale(t *testing.T) {
+	cfg := LocaleConfig{
+		Bundle:     newLocaleBundle(t),
+		Sources:    []string{LocaleFromQuery, LocaleFromCookie, LocaleFromHeader},
+		QueryParam: "lang",
+		CookieName: "lang",
+	}
+	router := newLocaleRouter(cfg)
+
+	for _, c := range []struct {
+		name   string
+		query  string
+		cookie string
+		header string
+		expect string
+	}{
+		{name: "default", expect: "en"},
+		{name: "header", header: "fr;q=0.9, vi-VN, en;q=0.8", expect: "vi"},
+		{name: "cookie over header", cookie: "en", header: "vi", expect: "en"},
+		{name: "query over cookie", query: "vi", cookie: "en", expect: "vi"},
+		{name: "unsupported query", query: "fr", header: "vi", expect: "vi"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/hello?lang="+c.query, nil)
+		if c.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "lang", Value: c.cookie})
+		}
+		if c.header != "" {
+			req.Header.Set("Accept-Language", c.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, c.expect, w.Header().Get("Content-Language"), c.name+" should be equal")
+		assert.Equal(t, "Accept-Language", w.Header().Get("Vary"), c.name+" should be equal")
+	}
+
+	// header only
+	cfg.Sources = []string{LocaleFromHeader}
+	router = newLocaleRouter(cfg)
+	req := httptest.NewRequest(http.MethodGet, "/hello?lang=en", nil)
+	req.Header.Set("Accept-Language", "vi")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "xin chào", w.Body.String(), "should be equal")
+}
+
+func TestLocaleErrors(t *testing.T) {
+	router := newLocaleRouter(LocaleConfig{Bundle: newLocaleBundle(t), Sources: []string{LocaleFromHeader}})
+
+	for _, c := range []struct {
+		path   string
+		header string
+		expect string
+	}{
+		{"/orders/42", "vi", `{"code":"order_not_found","status_code":404,"message":"không tìm thấy đơn hàng 42"}`},
+		{"/orders/42", "en-US", `{"code":"order_not_found","status_code":404,"message":"order 42 not found"}`},
+		// missing keys keep the message
+		{"/unknown", "vi", `{"code":"order_not_found","status_code":404,"message":"order not found"}`},
+	} {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set("Accept-Language", c.header)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code, "should be equal")
+		assert.JSONEq(t, c.expect, w.Body.String(), "should be equal")
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"vi-VN", "vi", "en"}, parseAcceptLanguage("en;q=0.5, vi-VN, vi;q=0.9, *;q=0.1, fr;q=0"), "should be equal")
+	assert.Empty(t, parseAcceptLanguage(""), "should be empty")
+	assert.Empty(t, parseAcceptLanguage("en;q=abc"), "should be empty")
+}
+
+func TestLocaleConfigValidate(t *testing.T) {
+	assert.Nil(t, LocaleConfig{Sources: []string{LocaleFromHeader}}.Validate(), "must be nil")
+	assert.NotNil(t, LocaleConfig{}.Validate(), "should be an error")
+	assert.NotNil(t, LocaleConfig{Sources: []string{LocaleFromQuery}}.Validate(), "should be an error")
+	assert.NotNil(t, LocaleConfig{Sources: []string{LocaleFromCookie}}.Validate(), "should be an error")
+	assert.NotNil(t, LocaleConfig{Sources: []string{"path"}}.Validate(), "should be an error")
+}
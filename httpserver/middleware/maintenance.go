@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+const defaultMaintenanceMessage = "the service is under maintenance, please retry later"
+
+// MaintenanceState is whether the service is under maintenance
+type MaintenanceState struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	// when the maintenance should end, it gives the Retry-After of the responses
+	ETA       *time.Time `json:"eta,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (s MaintenanceState) equal(o MaintenanceState) bool {
+	sameETA := (s.ETA == nil && o.ETA == nil) || (s.ETA != nil && o.ETA != nil && s.ETA.Equal(*o.ETA))
+	return s.Enabled == o.Enabled && s.Message == o.Message && sameETA && s.UpdatedAt.Equal(o.UpdatedAt)
+}
+
+// MaintenanceSync shares the maintenance state across replicas, e.g. sdkredis.NewMaintenanceSync
+type MaintenanceSync interface {
+	// Load returns the last published state, ok is false when there is none
+	Load(ctx context.Context) (state MaintenanceState, ok bool, err error)
+	Publish(ctx context.Context, state MaintenanceState) error
+	// Subscribe calls fn with every published state until the returned closer is closed
+	Subscribe(fn func(MaintenanceState)) (io.Closer, error)
+}
+
+// Maintenance is the switch of MaintenanceMode, safe for concurrent use
+type Maintenance struct {
+	state atomic.Pointer[MaintenanceState]
+}
+
+func NewMaintenance() *Maintenance {
+	m := &Maintenance{}
+	m.state.Store(&MaintenanceState{})
+	return m
+}
+
+func (m *Maintenance) State() MaintenanceState {
+	return *m.state.Load()
+}
+
+// Set replaces the state, it returns false when the state is unchanged or
+// older than the current one, e.g. delivered late by a MaintenanceSync
+func (m *Maintenance) Set(state MaintenanceState) bool {
+	for {
+		old := m.state.Load()
+		if old.equal(state) || state.UpdatedAt.Before(old.UpdatedAt) {
+			return false
+		}
+		if m.state.CompareAndSwap(old, &state) {
+			return true
+		}
+	}
+}
+
+type MaintenanceConfig struct {
+	// path prefixes served during maintenance, in addition to the health endpoints
+	ExemptPaths []string `json:"exempt_paths"`
+	// requests from these networks are served during maintenance
+	ExemptCIDRs []*net.IPNet `json:"-"`
+	// Retry-After of the responses when the state has no ETA
+	RetryAfter time.Duration `json:"retry_after"`
+}
+
+// MaintenanceMode responds 503 with Retry-After and the message of the state
+// while m is enabled, except to the exempt paths and networks
+func MaintenanceMode(m *Maintenance, cfg MaintenanceConfig) gin.HandlerFunc {
+	exemptPaths := append([]string{"/healthz", "/readyz"}, cfg.ExemptPaths...)
+
+	return func(c *gin.Context) {
+		state := m.State()
+		if !state.Enabled || isExemptPath(c.Request.URL.Path, exemptPaths) || isExempt(ClientIP(c), cfg.ExemptCIDRs) {
+			c.Next()
+			return
+		}
+
+		retryAfter := cfg.RetryAfter
+		if state.ETA != nil {
+			retryAfter = time.Until(*state.ETA)
+		}
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+
+		message := state.Message
+		if message == "" {
+			message = defaultMaintenanceMessage
+		}
+		writeAppError(c, sdkcm.AppError{StatusCode: http.StatusServiceUnavailable, Code: "maintenance", Message: message})
+	}
+}
+
+func isExemptPath(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
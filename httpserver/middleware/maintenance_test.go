@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := NewMaintenance()
+	exempt, _ := ParseCIDRs("10.0.0.0/8")
+	router := gin.New()
+	router.Use(MaintenanceMode(m, MaintenanceConfig{ExemptPaths: []string{"/webhooks"}, ExemptCIDRs: exempt, RetryAfter: time.Minute}))
+	for _, path := range []string{"/items", "/healthz", "/webhooks/stripe"} {
+		router.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	serve := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/items", "192.0.2.1:1234").Code, "should be served")
+
+	now := time.Now()
+	assert.True(t, m.Set(MaintenanceState{Enabled: true, UpdatedAt: now}), "should be changed")
+	assert.False(t, m.Set(MaintenanceState{Enabled: true, UpdatedAt: now}), "should be unchanged")
+	assert.False(t, m.Set(MaintenanceState{UpdatedAt: now.Add(-time.Second)}), "older states should be ignored")
+
+	w := serve("/items", "192.0.2.1:1234")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "should be equal")
+	assert.Equal(t, "60", w.Header().Get("Retry-After"), "should be equal")
+	var appErr sdkcm.AppError
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &appErr), "must be nil")
+	assert.Equal(t, "maintenance", appErr.Code, "should be equal")
+	assert.Equal(t, defaultMaintenanceMessage, appErr.Message, "should be equal")
+
+	assert.Equal(t, http.StatusOK, serve("/healthz", "192.0.2.1:1234").Code, "health should be served")
+	assert.Equal(t, http.StatusOK, serve("/webhooks/stripe", "192.0.2.1:1234").Code, "exempt paths should be served")
+	assert.Equal(t, http.StatusOK, serve("/items", "10.1.2.3:1234").Code, "exempt networks should be served")
+
+	eta := time.Now().Add(90 * time.Second)
+	m.Set(MaintenanceState{Enabled: true, Message: "migrating the database", ETA: &eta, UpdatedAt: time.Now()})
+	w = serve("/items", "192.0.2.1:1234")
+	assert.Equal(t, "90", w.Header().Get("Retry-After"), "should be the eta")
+	assert.Contains(t, w.Body.String(), "migrating the database", "should contain the message")
+
+	m.Set(MaintenanceState{UpdatedAt: time.Now()})
+	assert.Equal(t, http.StatusOK, serve("/items", "192.0.2.1:1234").Code, "should be served")
+}
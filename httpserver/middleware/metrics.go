@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/taimaifika/go-sdk/httpserver"
+
+// DefaultDurationBuckets are the request duration buckets in seconds
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// route of requests not matching any route, so scanners can't blow up the cardinality
+const unmatchedRoute = "unmatched"
+
+type httpMetrics struct {
+	requests     metric.Int64Counter
+	duration     metric.Float64Histogram
+	inFlight     metric.Int64UpDownCounter
+	responseSize metric.Int64Histogram
+}
+
+// Metrics records the request count, duration, in-flight requests and response size
+// with the global meter provider. They are labelled by method, route template and status class.
+// nil buckets uses DefaultDurationBuckets.
+func Metrics(buckets []float64) gin.HandlerFunc {
+	if buckets == nil {
+		buckets = DefaultDurationBuckets
+	}
+
+	m := newHTTPMetrics(otel.Meter(meterName), buckets)
+
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		ctx := c.Request.Context()
+		inFlightAttrs := metric.WithAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+		)
+
+		m.inFlight.Add(ctx, 1, inFlightAttrs)
+		defer m.inFlight.Add(ctx, -1, inFlightAttrs)
+
+		start := time.Now()
+		c.Next()
+
+		attrs := metric.WithAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("http.response.status_class", statusClass(c.Writer.Status())),
+		)
+
+		m.requests.Add(ctx, 1, attrs)
+		m.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+		if size := c.Writer.Size(); size > 0 {
+			m.responseSize.Record(ctx, int64(size), attrs)
+		}
+	}
+}
+
+// newHTTPMetrics creates the instruments, failures are reported
+// to the otel error handler and leave no-op instruments
+func newHTTPMetrics(meter metric.Meter, buckets []float64) *httpMetrics {
+	var (
+		m   httpMetrics
+		err error
+	)
+
+	if m.requests, err = meter.Int64Counter("http.server.request.count",
+		metric.WithDescription("Number of HTTP requests"),
+		metric.WithUnit("{request}")); err != nil {
+		otel.Handle(err)
+	}
+
+	if m.duration, err = meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of HTTP requests"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(buckets...)); err != nil {
+		otel.Handle(err)
+	}
+
+	if m.inFlight, err = meter.Int64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of in-flight HTTP requests"),
+		metric.WithUnit("{request}")); err != nil {
+		otel.Handle(err)
+	}
+
+	if m.responseSize, err = meter.Int64Histogram("http.server.response.body.size",
+		metric.WithDescription("Size of HTTP response bodies"),
+		metric.WithUnit("By")); err != nil {
+		otel.Handle(err)
+	}
+
+	return &m
+}
+
+// statusClass returns 2xx, 4xx... of a status code
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
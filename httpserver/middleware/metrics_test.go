@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry := prom.NewRegistry()
+	exporter, err := prometheus.New(prometheus.WithRegisterer(registry))
+	assert.Nil(t, err, "must be nil")
+
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(metric.NewMeterProvider(metric.WithReader(exporter)))
+	defer otel.SetMeterProvider(prev)
+
+	router := gin.New()
+	router.Use(Metrics([]float64{0.1, 1}))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "user")
+	})
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	assert.Contains(t, body, `http_server_request_count_total{http_request_method="GET",http_response_status_class="2xx",http_route="/users/:id"`, "should count by route template")
+	assert.Contains(t, body, `http_route="unmatched"`, "should label unmatched routes")
+	assert.Contains(t, body, `http_server_request_duration_seconds_bucket{http_request_method="GET",http_response_status_class="2xx",http_route="/users/:id",otel_scope_name="`+meterName+`",otel_scope_version="",le="0.1"}`, "should use the buckets")
+	assert.Contains(t, body, "http_server_active_requests", "should contain in-flight requests")
+	assert.Contains(t, body, "http_server_response_body_size_bytes", "should contain response sizes")
+	assert.NotContains(t, body, `http_route="/users/1"`, "should not label raw paths")
+}
@@ -2,15 +2,25 @@ package middleware
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"github.com/gin-gonic/gin"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"net/http/httputil"
-	"os"
 	"runtime"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -20,8 +30,99 @@ var (
 	slash     = []byte("/")
 )
 
-func PanicLogger() gin.HandlerFunc {
-	return RecoveryWithWriter(os.Stderr)
+// PanicNotifier is told about recovered panics, e.g. to forward them to Sentry or Slack
+type PanicNotifier interface {
+	NotifyPanic(c *gin.Context, err interface{}, stack []byte)
+}
+
+// PanicNotifierFunc is a function PanicNotifier
+type PanicNotifierFunc func(c *gin.Context, err interface{}, stack []byte)
+
+func (f PanicNotifierFunc) NotifyPanic(c *gin.Context, err interface{}, stack []byte) {
+	f(c, err, stack)
+}
+
+// PanicLogger recovers panics, responds them as a 500 sdkcm.AppError with the request id,
+// logs them with the stack by the "http" logger, records them on the active span,
+// counts them and tells the notifiers. It must run after the otel middleware to record the span.
+// Panics of a client gone away (broken pipe, connection reset) are only logged as warnings.
+func PanicLogger(notifiers ...PanicNotifier) gin.HandlerFunc {
+	panics, err := otel.Meter(meterName).Int64Counter("http.server.panic.count",
+		metric.WithDescription("Number of recovered panics of HTTP handlers"),
+		metric.WithUnit("{panic}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return func(c *gin.Context) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			err, ok := p.(error)
+			if !ok {
+				err = fmt.Errorf("%v", p)
+			}
+
+			if isClientGone(err) {
+				Logger(c, "http").Warnf("client is gone: %s", err.Error())
+				c.Abort()
+				return
+			}
+
+			stack := stack(3)
+			ctx := c.Request.Context()
+
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err, trace.WithAttributes(attribute.String("exception.stacktrace", string(stack))))
+			span.SetStatus(codes.Error, "panic")
+
+			route := c.FullPath()
+			if route == "" {
+				route = unmatchedRoute
+			}
+			if panics != nil {
+				panics.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", route)))
+			}
+
+			httprequest, _ := httputil.DumpRequest(c.Request, false)
+			Logger(c, "http").
+				With("stack", string(stack)).
+				Errorf("[Recovery] panic recovered: %v\n%s", p, maskAuthorization(httprequest))
+
+			for _, notifier := range notifiers {
+				notifier.NotifyPanic(c, p, stack)
+			}
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			writeAppError(c, sdkcm.ErrInternal(err))
+		}()
+		c.Next()
+	}
+}
+
+// isClientGone reports whether the connection of the client was closed,
+// the response can't be written anyway
+func isClientGone(err error) bool {
+	return errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, http.ErrAbortHandler)
+}
+
+// maskAuthorization hides the credentials of a dumped request
+func maskAuthorization(dump []byte) []byte {
+	lines := bytes.Split(dump, []byte("\r\n"))
+	for i, line := range lines {
+		if key, _, ok := bytes.Cut(line, []byte(":")); ok && strings.EqualFold(string(key), "Authorization") {
+			lines[i] = []byte("Authorization: *")
+		}
+	}
+	return bytes.Join(lines, []byte("\r\n"))
 }
 
 // RecoveryWithWriter returns a middleware for a given writer that recovers from any panics and writes a 500 if there was one.
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPanicLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reader := metric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(metric.NewMeterProvider(metric.WithReader(reader)))
+	defer otel.SetMeterProvider(prev)
+
+	recorder := tracetest.NewSpanRecorder()
+	log := newRecordingLogger()
+	var notified []interface{}
+
+	router := gin.New()
+	router.Use(otelgin.Middleware("test", otelgin.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))))
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logger.ContextWithLogger(c.Request.Context(), log))
+	})
+	router.Use(RequestID())
+	router.Use(PanicLogger(PanicNotifierFunc(func(c *gin.Context, err interface{}, stack []byte) {
+		notified = append(notified, err)
+	})))
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+	router.GET("/gone", func(c *gin.Context) {
+		panic(fmt.Errorf("write: %w", syscall.EPIPE))
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	req.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "should be equal")
+	assert.JSONEq(t, `{"code":"internal_error","status_code":500,"message":"internal server error","request_id":"req-1"}`,
+		w.Body.String(), "should be equal")
+
+	entry := log.last()
+	assert.Equal(t, "error", entry.level, "should be equal")
+	assert.Contains(t, entry.msg, "panic recovered: boom", "should be logged")
+	assert.NotContains(t, entry.msg, "secret", "credentials should be masked")
+	assert.Contains(t, entry.fields["stack"], "panic_logger_test.go", "should log the stack")
+	assert.Len(t, entry.fields["trace_id"], 32, "should log the trace id")
+	assert.Equal(t, []interface{}{"boom"}, notified, "should be notified")
+
+	span := recorder.Ended()[0]
+	assert.Equal(t, codes.Error, span.Status().Code, "should fail the span")
+	assert.Equal(t, "exception", span.Events()[0].Name, "should record the panic")
+
+	// the client is gone, nothing to respond
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gone", nil))
+	assert.Equal(t, "warn", log.last().level, "should be equal")
+	assert.Equal(t, 1, len(notified), "should not be notified")
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm), "must be nil")
+	sum := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	assert.Equal(t, int64(1), sum.DataPoints[0].Value, "should count panics")
+}
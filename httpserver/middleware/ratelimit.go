@@ -0,0 +1,267 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Rate limit key strategies
+const (
+	RateLimitByIP        = "ip"
+	RateLimitByAPIKey    = "api-key"
+	RateLimitByRequester = "requester"
+)
+
+// APIKeyHeader is the header RateLimitByAPIKey reads
+const APIKeyHeader = "X-API-Key"
+
+var errRateLimited = sdkcm.AppError{StatusCode: http.StatusTooManyRequests, Code: "rate_limited", Message: "too many requests"}
+
+// Limiter is a token bucket limiter shared by the requests with the same key
+type Limiter interface {
+	// Allow takes a token of key, retryAfter is when the next token is available if it's not allowed
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// KeyFunc returns the rate limit key of a request
+type KeyFunc func(c *gin.Context) string
+
+type RateLimitConfig struct {
+	Limiter Limiter
+	// nil uses KeyByIP
+	Key KeyFunc
+	// requests from these networks are not limited
+	ExemptCIDRs []*net.IPNet
+}
+
+// KeyByIP limits each client ip
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + ClientIP(c)
+}
+
+// KeyByAPIKey limits each api key, requests without one are limited by ip
+func KeyByAPIKey(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return "key:" + key
+	}
+	return KeyByIP(c)
+}
+
+// KeyByRequester limits each user authenticated by RequiredAuth,
+// so it must run after it. Anonymous requests are limited by ip.
+func KeyByRequester(c *gin.Context) string {
+	if r, ok := RequesterFromContext(c); ok {
+		return "user:" + strconv.FormatUint(uint64(r.UserID()), 10)
+	}
+	return KeyByIP(c)
+}
+
+// KeyFuncOf returns the KeyFunc of a strategy: ip, api-key or requester
+func KeyFuncOf(strategy string) (KeyFunc, error) {
+	switch strategy {
+	case RateLimitByIP, "":
+		return KeyByIP, nil
+	case RateLimitByAPIKey:
+		return KeyByAPIKey, nil
+	case RateLimitByRequester:
+		return KeyByRequester, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q, must be %s, %s or %s", strategy, RateLimitByIP, RateLimitByAPIKey, RateLimitByRequester)
+	}
+}
+
+// ParseCIDRs parses comma separated networks, single ips are allowed
+func ParseCIDRs(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range splitComma(s) {
+		if ip := net.ParseIP(item); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q: %w", item, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// RateLimit responds 429 with Retry-After when the limiter rejects the request.
+// Limiter errors let the request through.
+func RateLimit(cfg RateLimitConfig) gin.HandlerFunc {
+	key := cfg.Key
+	if key == nil {
+		key = KeyByIP
+	}
+
+	rejected, err := otel.Meter(meterName).Int64Counter("http.server.rate_limited",
+		metric.WithDescription("Number of HTTP requests rejected by the rate limiter"),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return func(c *gin.Context) {
+		if isExempt(ClientIP(c), cfg.ExemptCIDRs) {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		allowed, retryAfter, err := cfg.Limiter.Allow(ctx, key(c))
+		if err != nil {
+			logger.FromContext(ctx, "ratelimit").Warnf("rate limiter failed, request is allowed: %s", err.Error())
+			c.Next()
+			return
+		}
+
+		if !allowed {
+			rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", c.FullPath())))
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeAppError(c, errRateLimited)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func isExempt(clientIP string, nets []*net.IPNet) bool {
+	if len(nets) == 0 {
+		return false
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	memoryLimiterShards = 32
+	// stale buckets of a shard are removed at most this often
+	memoryLimiterCleanupInterval = time.Minute
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type limiterShard struct {
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+}
+
+type memoryLimiter struct {
+	rate   float64
+	burst  float64
+	shards [memoryLimiterShards]limiterShard
+	now    func() time.Time
+}
+
+// NewMemoryLimiter limits each key to rps requests per second with bursts of burst requests.
+// Keys are spread over sharded maps, idle buckets are removed while serving.
+func NewMemoryLimiter(rps float64, burst int) *memoryLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := &memoryLimiter{rate: rps, burst: float64(burst), now: time.Now}
+	for i := range l.shards {
+		l.shards[i].buckets = map[string]*bucket{}
+	}
+	return l
+}
+
+func (l *memoryLimiter) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	shard := &l.shards[h.Sum32()%memoryLimiterShards]
+
+	now := l.now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if now.Sub(shard.lastCleanup) > memoryLimiterCleanupInterval {
+		l.cleanup(shard, now)
+	}
+
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		shard.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), nil
+}
+
+// cleanup removes the buckets which are full again, they are the same as new ones.
+// It must be called with the shard lock held.
+func (l *memoryLimiter) cleanup(shard *limiterShard, now time.Time) {
+	for key, b := range shard.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(shard.buckets, key)
+		}
+	}
+	shard.lastCleanup = now
+}
+
+// size returns the number of buckets
+func (l *memoryLimiter) size() int {
+	n := 0
+	for i := range l.shards {
+		l.shards[i].mu.Lock()
+		n += len(l.shards[i].buckets)
+		l.shards[i].mu.Unlock()
+	}
+	return n
+}
+
+func splitComma(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
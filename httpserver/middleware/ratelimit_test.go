@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string) (bool, time.Duration, error) {
+	return false, 0, errors.New("redis is down")
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewMemoryLimiter(2, 3)
+	l.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		allowed, _, err := l.Allow(ctx, "a")
+		assert.Nil(t, err, "must be nil")
+		assert.True(t, allowed, "burst should be allowed")
+	}
+
+	allowed, retryAfter, _ := l.Allow(ctx, "a")
+	assert.False(t, allowed, "bucket should be empty")
+	assert.Equal(t, 500*time.Millisecond, retryAfter, "should be equal")
+
+	allowed, _, _ = l.Allow(ctx, "b")
+	assert.True(t, allowed, "keys should have their own bucket")
+
+	now = now.Add(500 * time.Millisecond)
+	allowed, _, _ = l.Allow(ctx, "a")
+	assert.True(t, allowed, "a token should be refilled")
+	assert.Equal(t, 2, l.size(), "should be equal")
+
+	// full buckets are removed by the next cleanup of their shard
+	now = now.Add(2 * time.Second)
+	for i := range l.shards {
+		l.cleanup(&l.shards[i], now)
+	}
+	assert.Equal(t, 0, l.size(), "stale buckets should be removed")
+}
+
+func TestRateLimit(t *testing.T) {
+	logger.InitServLogger(false)
+	gin.SetMode(gin.TestMode)
+
+	reader := metric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(metric.NewMeterProvider(metric.WithReader(reader)))
+	defer otel.SetMeterProvider(prev)
+
+	exempt, err := ParseCIDRs("10.0.0.0/8, 192.168.1.1")
+	assert.Nil(t, err, "must be nil")
+
+	router := gin.New()
+	router.Use(RateLimit(RateLimitConfig{Limiter: NewMemoryLimiter(0.5, 1), Key: KeyByAPIKey, ExemptCIDRs: exempt}))
+	router.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	request := func(ip, apiKey string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = ip + ":1234"
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request("1.1.1.1", "").Code, "should be equal")
+	w := request("1.1.1.1", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "should be equal")
+	assert.Equal(t, "2", w.Header().Get("Retry-After"), "should be equal")
+	assert.Contains(t, w.Body.String(), `"code":"rate_limited"`, "should be equal")
+
+	// the api key is the client, not the ip
+	assert.Equal(t, http.StatusOK, request("1.1.1.1", "key-1").Code, "should be equal")
+	assert.Equal(t, http.StatusTooManyRequests, request("2.2.2.2", "key-1").Code, "should be equal")
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, request("10.1.2.3", "").Code, "exempt network should not be limited")
+		assert.Equal(t, http.StatusOK, request("192.168.1.1", "").Code, "exempt ip should not be limited")
+	}
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm), "must be nil")
+	assert.Len(t, rm.ScopeMetrics, 1, "should be recorded")
+	sum := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	assert.Equal(t, int64(2), sum.DataPoints[0].Value, "should count rejections")
+
+	// limiter errors let requests through
+	router = gin.New()
+	router.Use(RateLimit(RateLimitConfig{Limiter: failingLimiter{}}))
+	router.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	assert.Equal(t, http.StatusOK, request("1.1.1.1", "").Code, "should be equal")
+}
+
+func TestRateLimitConfigParsing(t *testing.T) {
+	_, err := KeyFuncOf("session")
+	assert.NotNil(t, err, "should be an error")
+
+	_, err = ParseCIDRs("10.0.0.0/33")
+	assert.NotNil(t, err, "should be an error")
+
+	nets, err := ParseCIDRs("::1")
+	assert.Nil(t, err, "must be nil")
+	assert.True(t, isExempt("::1", nets), "single ip should be exempt")
+	assert.False(t, isExempt("::2", nets), "other ips should not be exempt")
+}
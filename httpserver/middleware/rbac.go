@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+// MultiRoleRequester is optionally implemented by requesters having several roles
+type MultiRoleRequester interface {
+	GetRoles() []string
+}
+
+// ScopedRequester is optionally implemented by requesters limited to scopes,
+// e.g. *APIKey
+type ScopedRequester interface {
+	GetScopes() []string
+}
+
+// errMissingRequester means an authorization middleware runs before RequiredAuth
+var errMissingRequester = sdkcm.ErrInternal(errors.New("no requester in context, authorization must run after RequiredAuth"))
+
+var errForbidden = sdkcm.AppError{StatusCode: http.StatusForbidden, Code: "no_permission", Message: "you don't have permission to access"}
+
+// RequiredRoles aborts with 403 unless a role of the requester is one of roles
+func RequiredRoles(roles ...string) gin.HandlerFunc {
+	return authorize(roles, func(c *gin.Context, r sdkcm.Requester) bool {
+		return hasRole(r, roles, false)
+	})
+}
+
+// RequiredRolesWithHierarchy is RequiredRoles where a role also implies the less
+// privileged system roles, e.g. admin passes RequiredRolesWithHierarchy("moderator")
+func RequiredRolesWithHierarchy(roles ...string) gin.HandlerFunc {
+	return authorize(roles, func(c *gin.Context, r sdkcm.Requester) bool {
+		return hasRole(r, roles, true)
+	})
+}
+
+// OwnerOr passes if the requester is the user of the route param, e.g. :user_id,
+// otherwise it requires one of roles. The param is a uid or a plain user id.
+func OwnerOr(param string, roles ...string) gin.HandlerFunc {
+	return authorize(roles, func(c *gin.Context, r sdkcm.Requester) bool {
+		return isOwner(r, c.Param(param)) || hasRole(r, roles, false)
+	})
+}
+
+// RequiredScopes aborts with 403 unless the requester has all scopes, "orders:*"
+// grants every scope of orders and "*" every scope. Requesters without scopes,
+// e.g. users of RequiredAuth, don't pass.
+//
+//	engine.POST("/orders", middleware.RequiredAPIKey(store), middleware.RequiredScopes("orders:write"), create)
+func RequiredScopes(scopes ...string) gin.HandlerFunc {
+	return authorize(scopes, func(c *gin.Context, r sdkcm.Requester) bool {
+		s, ok := r.(ScopedRequester)
+		if !ok {
+			return false
+		}
+		for _, scope := range scopes {
+			if !hasScope(s.GetScopes(), scope) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func authorize(roles []string, allowed func(c *gin.Context, r sdkcm.Requester) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requester, ok := RequesterFromContext(c)
+		if !ok {
+			writeAppError(c, errMissingRequester)
+			return
+		}
+
+		if !allowed(c, requester) {
+			logger.FromContext(c.Request.Context(), "auth").Withs(logger.Fields{
+				"user_id":        requester.UserID(),
+				"route":          c.FullPath(),
+				"required_roles": roles,
+			}).Warn("permission denied")
+
+			writeAppError(c, errForbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func requesterRoles(r sdkcm.Requester) []string {
+	if m, ok := r.(MultiRoleRequester); ok {
+		return m.GetRoles()
+	}
+	return []string{r.GetSystemRole()}
+}
+
+func hasRole(r sdkcm.Requester, required []string, hierarchy bool) bool {
+	for _, role := range requesterRoles(r) {
+		for _, req := range required {
+			if role == req {
+				return true
+			}
+			// system roles are ordered from the most privileged
+			if hierarchy && isSystemRole(role) && isSystemRole(req) &&
+				sdkcm.ParseSystemRole(role) <= sdkcm.ParseSystemRole(req) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isSystemRole(role string) bool {
+	for _, r := range sdkcm.AllSysRoles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func isOwner(r sdkcm.Requester, value string) bool {
+	if value == "" {
+		return false
+	}
+
+	if uid, err := sdkcm.DecodeUID(value); err == nil {
+		return uid.GetLocalID() == r.UserID()
+	}
+
+	id, err := strconv.ParseUint(value, 10, 32)
+	return err == nil && uint32(id) == r.UserID()
+}
+
+func hasScope(granted []string, scope string) bool {
+	for _, g := range granted {
+		if g == scope || g == "*" ||
+			(strings.HasSuffix(g, ":*") && strings.HasPrefix(scope, strings.TrimSuffix(g, "*"))) {
+			return true
+		}
+	}
+	return false
+}
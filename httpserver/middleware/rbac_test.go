@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+type roleUser struct {
+	id    uint32
+	roles []string
+}
+
+func (u roleUser) OAuthID() string       { return "oauth" }
+func (u roleUser) UserID() uint32        { return u.id }
+func (u roleUser) GetSystemRole() string { return u.roles[0] }
+func (u roleUser) GetUser() interface{}  { return u }
+
+type multiRoleUser struct {
+	roleUser
+}
+
+func (u multiRoleUser) GetRoles() []string { return u.roles }
+
+func TestAuthorization(t *testing.T) {
+	logger.InitServLogger(false)
+	gin.SetMode(gin.TestMode)
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	run := func(requester sdkcm.Requester, handler gin.HandlerFunc, path string) int {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if requester != nil {
+				c.Set(CurrentRequesterKey, requester)
+			}
+		})
+		router.GET("/users/:user_id", handler, ok)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	user := roleUser{id: 7, roles: []string{"user"}}
+	admin := roleUser{id: 1, roles: []string{"admin"}}
+	editor := multiRoleUser{roleUser{id: 8, roles: []string{"user", "editor"}}}
+
+	for _, c := range []struct {
+		requester sdkcm.Requester
+		handler   gin.HandlerFunc
+		path      string
+		expect    int
+	}{
+		{requester: admin, handler: RequiredRoles("admin"), path: "/users/1", expect: http.StatusOK},
+		{requester: user, handler: RequiredRoles("admin", "moderator"), path: "/users/1", expect: http.StatusForbidden},
+		{requester: admin, handler: RequiredRoles("moderator"), path: "/users/1", expect: http.StatusForbidden},
+		{requester: admin, handler: RequiredRolesWithHierarchy("moderator"), path: "/users/1", expect: http.StatusOK},
+		{requester: user, handler: RequiredRolesWithHierarchy("moderator"), path: "/users/1", expect: http.StatusForbidden},
+		{requester: editor, handler: RequiredRoles("editor"), path: "/users/1", expect: http.StatusOK},
+		{requester: user, handler: OwnerOr("user_id", "admin"), path: "/users/7", expect: http.StatusOK},
+		{requester: user, handler: OwnerOr("user_id", "admin"), path: "/users/" + sdkcm.NewUID(7, 1, 1).String(), expect: http.StatusOK},
+		{requester: user, handler: OwnerOr("user_id", "admin"), path: "/users/8", expect: http.StatusForbidden},
+		{requester: admin, handler: OwnerOr("user_id", "admin"), path: "/users/8", expect: http.StatusOK},
+		{requester: nil, handler: RequiredRoles("admin"), path: "/users/1", expect: http.StatusInternalServerError},
+	} {
+		assert.Equal(t, c.expect, run(c.requester, c.handler, c.path), "should be equal: %s", c.path)
+	}
+}
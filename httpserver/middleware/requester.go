@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+// PropagatedRequester trusts the requester signed by another service in the
+// sdkcm.RequesterHeader header, and stores it like RequiredAuth does.
+// Requests without the header or with an invalid one stay anonymous,
+// RequiredAuth still authenticates the routes needing a token.
+func PropagatedRequester(signer *sdkcm.RequesterSigner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.GetHeader(sdkcm.RequesterHeader)
+		if value == "" || signer == nil {
+			c.Next()
+			return
+		}
+
+		requester, err := signer.Verify(value)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		c.Set(CurrentRequesterKey, requester)
+		c.Request = c.Request.WithContext(sdkcm.ContextWithRequester(c.Request.Context(), requester))
+		c.Next()
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+func TestPropagatedRequester(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signer := sdkcm.NewRequesterSigner("shared")
+
+	r := gin.New()
+	r.Use(PropagatedRequester(signer))
+	r.GET("/me", func(c *gin.Context) {
+		requester, ok := RequesterFromContext(c.Request.Context())
+		if !ok {
+			c.String(http.StatusOK, "anonymous")
+			return
+		}
+		_, fromGin := RequesterFromContext(c)
+		assert.True(t, fromGin, "should be in the gin context too")
+		c.String(http.StatusOK, requester.GetSystemRole())
+	})
+
+	get := func(header string) string {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if header != "" {
+			req.Header.Set(sdkcm.RequesterHeader, header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+		return w.Body.String()
+	}
+
+	value, err := signer.Sign(sdkcm.CurrentUser(oauthID("sub"), testUser{id: 7}))
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "user", get(value), "should be equal")
+
+	assert.Equal(t, "anonymous", get(""), "should be equal")
+	forged, _ := sdkcm.NewRequesterSigner("other").Sign(sdkcm.CurrentUser(oauthID("sub"), testUser{id: 7}))
+	assert.Equal(t, "anonymous", get(forged), "should be equal")
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// Key of request id in gin context
+	RequestIDKey = "request_id"
+
+	maxRequestIDLength = 128
+)
+
+type requestIDCtxKey struct{}
+
+// RequestID takes request id from X-Request-ID header or generates a new one (UUIDv4),
+// stores it in gin and request context, the response header, the logger carried
+// by the request context and the active span
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+
+		ctx := ContextWithRequestID(c.Request.Context(), id)
+		ctx = logger.ContextWithLogger(ctx, logger.FromContext(ctx, "request").With(RequestIDKey, id))
+		c.Request = c.Request.WithContext(ctx)
+
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("http.request_id", id))
+
+		c.Next()
+	}
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the request id,
+// e.g. for jobs or outbound requests out of a request
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestIDFromContext returns request id of the request context or gin context,
+// empty if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDCtxKey{}).(string); ok {
+		return id
+	}
+
+	if c, ok := ctx.(*gin.Context); ok {
+		return c.GetString(RequestIDKey)
+	}
+
+	return ""
+}
+
+// Logger returns the logger carried by the request context, or the logger of the prefix,
+// with the request id and the trace_id and span_id of the active span:
+//
+//	middleware.Logger(c, "orders").Infof("order %d created", id)
+func Logger(c *gin.Context, prefix string) logger.Logger {
+	ctx := c.Request.Context()
+	return logger.FromContext(ctx, prefix).WithContext(ctx)
+}
+
+// isValidRequestID rejects empty, too long or non printable ids
+// so clients can't inject anything into logs
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestRequestID(t *testing.T) {
+	logger.InitServLogger(false)
+	gin.SetMode(gin.TestMode)
+
+	var fromRequest, fromGin string
+
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		fromRequest = RequestIDFromContext(c.Request.Context())
+		fromGin = RequestIDFromContext(c)
+	})
+
+	for _, c := range []struct {
+		header   string
+		generate bool
+	}{
+		{header: "abc-123", generate: false},
+		{header: "", generate: true},
+		{header: "bad id\n", generate: true},
+		{header: strings.Repeat("a", maxRequestIDLength+1), generate: true},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if c.header != "" {
+			req.Header.Set(RequestIDHeader, c.header)
+		}
+		router.ServeHTTP(w, req)
+
+		id := w.Header().Get(RequestIDHeader)
+		if c.generate {
+			_, err := uuid.Parse(id)
+			assert.Nil(t, err, "should be a generated uuid")
+		} else {
+			assert.Equal(t, c.header, id, "should be equal")
+		}
+
+		assert.Equal(t, id, fromRequest, "should be equal")
+		assert.Equal(t, id, fromGin, "should be equal")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	log := newRecordingLogger()
+
+	router := gin.New()
+	router.Use(otelgin.Middleware("test", otelgin.WithTracerProvider(sdktrace.NewTracerProvider())))
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logger.ContextWithLogger(c.Request.Context(), log))
+	})
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		Logger(c, "orders").Info("created")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := log.last()
+	assert.Equal(t, "created", entry.msg, "should be equal")
+	assert.Equal(t, "req-1", entry.fields[RequestIDKey], "should be equal")
+	assert.Len(t, entry.fields["trace_id"], 32, "should log the trace id")
+}
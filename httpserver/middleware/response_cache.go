@@ -0,0 +1,291 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/cache"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ResponseCacheHeader tells whether a response is a HIT, a STALE hit or a MISS
+const ResponseCacheHeader = "X-Cache"
+
+const (
+	responseCacheHit   = "HIT"
+	responseCacheStale = "STALE"
+	responseCacheMiss  = "MISS"
+)
+
+// these are not cached, in addition to the unreplayed ones. The body is
+// recorded before the compression.
+var uncachedHeaders = []string{"Age", "Content-Encoding", ResponseCacheHeader}
+
+type ResponseCacheConfig struct {
+	// nil => an LRU of 1000 responses
+	Cache cache.Cache `json:"-"`
+	// serves the background refreshes of stale responses, usually the engine.
+	// It's required when StaleTTL is positive.
+	Handler http.Handler `json:"-"`
+	// how long responses are fresh, routes may use a different one
+	TTL time.Duration `json:"ttl"`
+	// how long expired responses are served while they are refreshed, 0 => not served
+	StaleTTL time.Duration `json:"stale_ttl"`
+	// request headers the responses differ by, e.g. Accept. The locale of the
+	// Locale middleware always is.
+	VaryHeaders []string `json:"vary_headers"`
+	// larger responses are not cached
+	MaxBodySize int `json:"max_body_size"`
+	// cache the requests with a requester or an Authorization header, their
+	// responses are shared by every user
+	AllowAuthenticated bool `json:"allow_authenticated"`
+	// cache the responses setting cookies, without their Set-Cookie header
+	AllowSetCookie bool `json:"allow_set_cookie"`
+}
+
+func (cfg ResponseCacheConfig) Validate() error {
+	if cfg.TTL <= 0 || cfg.StaleTTL < 0 || cfg.MaxBodySize <= 0 {
+		return errors.New("response cache: ttl and max body size must be positive, stale ttl must not be negative")
+	}
+	if cfg.StaleTTL > 0 && cfg.Handler == nil {
+		return errors.New("response cache: a handler is required to refresh stale responses")
+	}
+	return nil
+}
+
+// CachedResponse is the response served for the requests with the same key
+type CachedResponse struct {
+	StatusCode int         `json:"status_code" msgpack:"status_code"`
+	Header     http.Header `json:"header" msgpack:"header"`
+	Body       []byte      `json:"body" msgpack:"body"`
+	StoredAt   time.Time   `json:"stored_at" msgpack:"stored_at"`
+	// served as stale then
+	FreshUntil time.Time `json:"fresh_until" msgpack:"fresh_until"`
+}
+
+type responseCacheRefreshKey struct{}
+
+// ResponseCache serves the successful GET responses of the routes using Handler
+// from a cache, keyed by path, sorted query and vary headers. Its invalidation
+// helpers are meant for the handlers changing the cached resources.
+type ResponseCache struct {
+	cfg      ResponseCacheConfig
+	requests metric.Int64Counter
+	now      func() time.Time
+	// keys being refreshed in the background
+	refreshing sync.Map
+}
+
+func NewResponseCache(cfg ResponseCacheConfig) (*ResponseCache, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Cache == nil {
+		lru, err := cache.NewLRU("http_responses", cache.LRUConfig{MaxEntries: 1000})
+		if err != nil {
+			return nil, err
+		}
+		cfg.Cache = lru
+	}
+
+	requests, err := otel.Meter(meterName).Int64Counter("http.server.response_cache.requests",
+		metric.WithDescription("Number of requests of the cached routes by result: hit, stale or miss"),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return &ResponseCache{cfg: cfg, requests: requests, now: time.Now}, nil
+}
+
+// Handler caches the responses of the routes it's used by for ttl, 0 => cfg.TTL:
+//
+//	catalog := engine.Group("/catalog", rc.Handler(10*time.Minute))
+func (rc *ResponseCache) Handler(ttl time.Duration) gin.HandlerFunc {
+	if ttl <= 0 {
+		ttl = rc.cfg.TTL
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet || !rc.cacheable(c) {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := rc.Key(c)
+
+		// refreshes always call the handlers
+		if refresh, _ := ctx.Value(responseCacheRefreshKey{}).(bool); !refresh {
+			var resp CachedResponse
+			err := rc.cfg.Cache.Get(ctx, key, &resp)
+			// the cache may keep it a bit longer, e.g. with another clock
+			if err == nil && rc.now().Before(resp.FreshUntil.Add(rc.cfg.StaleTTL)) {
+				rc.serve(c, key, &resp)
+				return
+			}
+			if err != nil && !errors.Is(err, cache.ErrNotFound) {
+				logger.FromContext(ctx, "response_cache").Warnf("failed to get cached response: %s", err.Error())
+			}
+			rc.record(c, responseCacheMiss)
+			c.Header(ResponseCacheHeader, responseCacheMiss)
+		}
+
+		w := &replayRecorder{ResponseWriter: c.Writer, limit: rc.cfg.MaxBodySize}
+		c.Writer = w
+
+		panicked := true
+		defer func() {
+			c.Writer = w.ResponseWriter
+			if !panicked {
+				// a new context, the request may be canceled already
+				rc.store(context.WithoutCancel(ctx), key, w, ttl)
+			}
+		}()
+
+		c.Next()
+		panicked = false
+	}
+}
+
+// Key returns the cache key of the request, the path comes first so
+// InvalidatePrefix can match it
+func (rc *ResponseCache) Key(c *gin.Context) string {
+	key := c.Request.URL.Path + "?" + c.Request.URL.Query().Encode()
+
+	vary := make([]string, 0, len(rc.cfg.VaryHeaders)+1)
+	for _, h := range rc.cfg.VaryHeaders {
+		vary = append(vary, http.CanonicalHeaderKey(h)+"="+c.GetHeader(h))
+	}
+	if locale := c.GetString(LocaleKey); locale != "" {
+		vary = append(vary, "locale="+locale)
+	}
+	if len(vary) > 0 {
+		sum := sha1.Sum([]byte(strings.Join(vary, "\n")))
+		key += "#" + hex.EncodeToString(sum[:8])
+	}
+	return key
+}
+
+// Invalidate deletes the responses of keys, as returned by Key
+func (rc *ResponseCache) Invalidate(ctx context.Context, keys ...string) error {
+	return rc.cfg.Cache.Delete(ctx, keys...)
+}
+
+// InvalidatePrefix deletes the responses of the paths starting with prefix,
+// with any query. The cache must be a cache.PrefixDeleter.
+//
+//	rc.InvalidatePrefix(ctx, "/catalog/items")
+func (rc *ResponseCache) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return cache.DeletePrefix(ctx, rc.cfg.Cache, prefix)
+}
+
+// cacheable reports whether the responses of the request may be shared
+func (rc *ResponseCache) cacheable(c *gin.Context) bool {
+	if hasNoStore(c.Request.Header) {
+		return false
+	}
+	if rc.cfg.AllowAuthenticated {
+		return true
+	}
+	_, authenticated := RequesterFromContext(c)
+	return !authenticated && c.GetHeader("Authorization") == ""
+}
+
+func (rc *ResponseCache) serve(c *gin.Context, key string, resp *CachedResponse) {
+	now := rc.now()
+	result := responseCacheHit
+	if !now.Before(resp.FreshUntil) {
+		result = responseCacheStale
+		rc.refresh(c, key)
+	}
+	rc.record(c, result)
+
+	header := c.Writer.Header()
+	for k, v := range resp.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set(ResponseCacheHeader, result)
+	header.Set("Age", strconv.Itoa(int(now.Sub(resp.StoredAt).Seconds())))
+
+	c.Status(resp.StatusCode)
+	if len(resp.Body) > 0 {
+		_, _ = c.Writer.Write(resp.Body)
+	} else {
+		c.Writer.WriteHeaderNow()
+	}
+	c.Abort()
+}
+
+// refresh calls the handlers again in the background unless key is refreshed already
+func (rc *ResponseCache) refresh(c *gin.Context, key string) {
+	if rc.cfg.Handler == nil {
+		return
+	}
+	if _, refreshing := rc.refreshing.LoadOrStore(key, struct{}{}); refreshing {
+		return
+	}
+
+	ctx := context.WithValue(context.WithoutCancel(c.Request.Context()), responseCacheRefreshKey{}, true)
+	r := c.Request.Clone(ctx)
+	r.Body = http.NoBody
+
+	go func() {
+		defer rc.refreshing.Delete(key)
+		rc.cfg.Handler.ServeHTTP(&discardResponseWriter{header: http.Header{}}, r)
+	}()
+}
+
+func (rc *ResponseCache) store(ctx context.Context, key string, w *replayRecorder, ttl time.Duration) {
+	header := w.Header()
+	if w.Status() != http.StatusOK || w.truncated || hasNoStore(header) ||
+		strings.Contains(strings.ToLower(header.Get("Cache-Control")), "private") ||
+		(!rc.cfg.AllowSetCookie && len(header.Values("Set-Cookie")) > 0) {
+		return
+	}
+
+	header = header.Clone()
+	for _, h := range unreplayedHeaders {
+		header.Del(h)
+	}
+	for _, h := range uncachedHeaders {
+		header.Del(h)
+	}
+
+	now := rc.now()
+	resp := CachedResponse{StatusCode: w.Status(), Header: header, Body: w.buf, StoredAt: now, FreshUntil: now.Add(ttl)}
+	if err := rc.cfg.Cache.Set(ctx, key, resp, ttl+rc.cfg.StaleTTL); err != nil {
+		logger.FromContext(ctx, "response_cache").Warnf("failed to cache response: %s", err.Error())
+	}
+}
+
+func (rc *ResponseCache) record(c *gin.Context, result string) {
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	rc.requests.Add(c.Request.Context(), 1, metric.WithAttributes(
+		attribute.String("http.route", route),
+		attribute.String("result", strings.ToLower(result)),
+	))
+}
+
+// discardResponseWriter is the writer of the background refreshes, the
+// response is only cached
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
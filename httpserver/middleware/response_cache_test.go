@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseCacheTest struct {
+	rc     *ResponseCache
+	router *gin.Engine
+	calls  atomic.Int32
+	now    time.Time
+}
+
+func newResponseCacheTest(t *testing.T, cfg ResponseCacheConfig) *responseCacheTest {
+	gin.SetMode(gin.TestMode)
+	rt := &responseCacheTest{router: gin.New(), now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cfg.Handler = rt.router
+
+	rc, err := NewResponseCache(cfg)
+	assert.Nil(t, err, "must be nil")
+	rc.now = func() time.Time { return rt.now }
+	rt.rc = rc
+
+	items := rt.router.Group("/items", rc.Handler(0))
+	items.GET("", func(c *gin.Context) {
+		n := rt.calls.Add(1)
+		c.Header("X-Request-Id", "req-"+strconv.Itoa(int(n)))
+		c.JSON(http.StatusOK, gin.H{"call": n, "page": c.Query("page")})
+	})
+	items.GET("/:id", func(c *gin.Context) {
+		n := rt.calls.Add(1)
+		c.JSON(http.StatusOK, gin.H{"call": n, "id": c.Param("id")})
+	})
+	items.GET("/cookie", func(c *gin.Context) {
+		rt.calls.Add(1)
+		c.SetCookie("session", "s", 60, "/", "", false, true)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	items.GET("/missing", func(c *gin.Context) {
+		rt.calls.Add(1)
+		c.JSON(http.StatusNotFound, gin.H{})
+	})
+	rt.router.GET("/catalog", rc.Handler(time.Hour), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"call": rt.calls.Add(1)})
+	})
+	return rt
+}
+
+func (rt *responseCacheTest) get(path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	rt.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestResponseCache(t *testing.T) {
+	rt := newResponseCacheTest(t, ResponseCacheConfig{TTL: time.Minute, MaxBodySize: 1 << 10, VaryHeaders: []string{"Accept"}})
+
+	w := rt.get("/items?page=1&sort=name", nil)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, responseCacheMiss, w.Header().Get(ResponseCacheHeader), "should be equal")
+
+	// the query is normalized
+	rt.now = rt.now.Add(30 * time.Second)
+	w = rt.get("/items?sort=name&page=1", nil)
+	assert.Equal(t, responseCacheHit, w.Header().Get(ResponseCacheHeader), "should be equal")
+	assert.Equal(t, "30", w.Header().Get("Age"), "should be equal")
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), "should be equal")
+	assert.Empty(t, w.Header().Get("X-Request-Id"), "should not be cached")
+	assert.JSONEq(t, `{"call":1,"page":"1"}`, w.Body.String(), "should be equal")
+
+	// other queries and vary headers are other keys
+	assert.Equal(t, responseCacheMiss, rt.get("/items?page=2", nil).Header().Get(ResponseCacheHeader), "should be equal")
+	assert.Equal(t, responseCacheMiss, rt.get("/items?page=1&sort=name", http.Header{"Accept": {"text/csv"}}).Header().Get(ResponseCacheHeader), "should be equal")
+	assert.Equal(t, int32(3), rt.calls.Load(), "should be equal")
+
+	// per route ttl
+	rt.get("/catalog", nil)
+	rt.now = rt.now.Add(time.Minute)
+	assert.Equal(t, responseCacheHit, rt.get("/catalog", nil).Header().Get(ResponseCacheHeader), "should be equal")
+	assert.Equal(t, responseCacheMiss, rt.get("/items?page=2", nil).Header().Get(ResponseCacheHeader), "should be expired")
+}
+
+func TestResponseCacheSkips(t *testing.T) {
+	rt := newResponseCacheTest(t, ResponseCacheConfig{TTL: time.Minute, MaxBodySize: 1 << 10})
+
+	for _, tc := range []struct {
+		path   string
+		header http.Header
+	}{
+		{"/items/cookie", nil},
+		{"/items/missing", nil},
+		{"/items", http.Header{"Authorization": {"Bearer token"}}},
+		{"/items", http.Header{"Cache-Control": {"no-store"}}},
+	} {
+		rt.calls.Store(0)
+		rt.get(tc.path, tc.header)
+		rt.get(tc.path, tc.header)
+		assert.Equal(t, int32(2), rt.calls.Load(), tc.path+" should not be cached")
+	}
+
+	// too large
+	rt = newResponseCacheTest(t, ResponseCacheConfig{TTL: time.Minute, MaxBodySize: 10})
+	rt.get("/items", nil)
+	rt.get("/items", nil)
+	assert.Equal(t, int32(2), rt.calls.Load(), "should not be cached")
+}
+
+func TestResponseCacheAllowed(t *testing.T) {
+	rt := newResponseCacheTest(t, ResponseCacheConfig{TTL: time.Minute, MaxBodySize: 1 << 10, AllowAuthenticated: true, AllowSetCookie: true})
+
+	rt.get("/items/cookie", http.Header{"Authorization": {"Bearer token"}})
+	w := rt.get("/items/cookie", nil)
+	assert.Equal(t, responseCacheHit, w.Header().Get(ResponseCacheHeader), "should be equal")
+	assert.Empty(t, w.Header().Get("Set-Cookie"), "should not be cached")
+	assert.Equal(t, int32(1), rt.calls.Load(), "should be equal")
+}
+
+func TestResponseCacheStale(t *testing.T) {
+	rt := newResponseCacheTest(t, ResponseCacheConfig{TTL: time.Minute, StaleTTL: time.Hour, MaxBodySize: 1 << 10})
+
+	rt.get("/items/1", nil)
+	rt.now = rt.now.Add(2 * time.Minute)
+
+	w := rt.get("/items/1", nil)
+	assert.Equal(t, responseCacheStale, w.Header().Get(ResponseCacheHeader), "should be equal")
+	assert.JSONEq(t, `{"call":1,"id":"1"}`, w.Body.String(), "should be the stale response")
+
+	// refreshed once in the background
+	assert.Eventually(t, func() bool {
+		return rt.get("/items/1", nil).Header().Get(ResponseCacheHeader) == responseCacheHit
+	}, time.Second, time.Millisecond, "should be refreshed")
+	assert.Equal(t, int32(2), rt.calls.Load(), "should be equal")
+	assert.JSONEq(t, `{"call":2,"id":"1"}`, rt.get("/items/1", nil).Body.String(), "should be the refreshed response")
+}
+
+func TestResponseCacheInvalidate(t *testing.T) {
+	rt := newResponseCacheTest(t, ResponseCacheConfig{TTL: time.Minute, MaxBodySize: 1 << 10})
+	ctx := context.Background()
+
+	for _, path := range []string{"/items?page=1", "/items/1", "/items/2", "/catalog"} {
+		rt.get(path, nil)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	assert.Nil(t, rt.rc.Invalidate(ctx, rt.rc.Key(c)), "must be nil")
+	assert.Equal(t, responseCacheMiss, rt.get("/items/1", nil).Header().Get(ResponseCacheHeader), "should be invalidated")
+	assert.Equal(t, responseCacheHit, rt.get("/items/2", nil).Header().Get(ResponseCacheHeader), "should be equal")
+
+	assert.Nil(t, rt.rc.InvalidatePrefix(ctx, "/items"), "must be nil")
+	for _, path := range []string{"/items?page=1", "/items/2"} {
+		assert.Equal(t, responseCacheMiss, rt.get(path, nil).Header().Get(ResponseCacheHeader), path+" should be invalidated")
+	}
+	assert.Equal(t, responseCacheHit, rt.get("/catalog", nil).Header().Get(ResponseCacheHeader), "should be equal")
+}
+
+func TestResponseCacheConfigValidate(t *testing.T) {
+	assert.Nil(t, ResponseCacheConfig{TTL: time.Minute, MaxBodySize: 1}.Validate(), "must be nil")
+	assert.NotNil(t, ResponseCacheConfig{MaxBodySize: 1}.Validate(), "should be an error")
+	assert.NotNil(t, ResponseCacheConfig{TTL: time.Minute, MaxBodySize: 1, StaleTTL: time.Minute}.Validate(), "should be an error")
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const shuttingDownKey = "shutting_down"
+
+// ShutdownSignal gives the handlers done, which is closed when the server
+// starts shutting down, so long-lived responses like streams can end
+func ShutdownSignal(done <-chan struct{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(shuttingDownKey, done)
+		c.Next()
+	}
+}
+
+// ShuttingDown returns the channel of ShutdownSignal,
+// nil without the middleware so it never fires
+func ShuttingDown(c *gin.Context) <-chan struct{} {
+	if v, ok := c.Get(shuttingDownKey); ok {
+		return v.(<-chan struct{})
+	}
+	return nil
+}
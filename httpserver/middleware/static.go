@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	cacheImmutable   = "public, max-age=31536000, immutable"
+	cacheRevalidate  = "no-cache"
+	spaIndex         = "index.html"
+	maxHashedETagLen = 16
+)
+
+// matches the content hash of bundler outputs: main.3f2a1b9c.js, index-DiwrgTda.css
+var hashedAssetRe = regexp.MustCompile(`[.-]([A-Za-z0-9_]{8,})\.[A-Za-z0-9]+$`)
+
+type StaticConfig struct {
+	// path the files are served under, e.g. /admin
+	Prefix string `json:"prefix"`
+	// unknown paths which don't look like files get index.html,
+	// for the client side routes of single page apps
+	SPA bool `json:"spa"`
+}
+
+// Static serves the files of fsys under cfg.Prefix with http.FileServer semantics
+// (ranges, conditional requests) but without directory listings. Hashed assets
+// are cached forever, other files are revalidated with their ETag.
+// It's meant for NoRoute, so the routes of the API always win:
+//
+//	engine.NoRoute(middleware.Static(os.DirFS("web/dist"), middleware.StaticConfig{SPA: true}))
+func Static(fsys fs.FS, cfg StaticConfig) gin.HandlerFunc {
+	prefix := "/" + strings.Trim(cfg.Prefix, "/")
+	s := &staticFS{fsys: fsys}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		name, ok := staticName(c.Request.URL.Path, prefix)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		if s.serve(c, name) {
+			c.Abort()
+			return
+		}
+
+		if cfg.SPA && !looksLikeFile(name) && acceptsHTML(c.Request) && s.serve(c, spaIndex) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// staticName returns the name in the FS of a request path under prefix
+func staticName(p, prefix string) (string, bool) {
+	if prefix != "/" {
+		if p != prefix && !strings.HasPrefix(p, prefix+"/") {
+			return "", false
+		}
+		p = strings.TrimPrefix(p, prefix)
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+// looksLikeFile reports whether the last segment of name has an extension
+func looksLikeFile(name string) bool {
+	return path.Ext(path.Base(name)) != ""
+}
+
+// acceptsHTML tells browser navigations from api calls and assets
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func isHashedAsset(name string) bool {
+	m := hashedAssetRe.FindStringSubmatch(path.Base(name))
+	if m == nil {
+		return false
+	}
+	// words like "component" aren't hashes
+	hash := m[1]
+	return strings.ContainsAny(hash, "0123456789") ||
+		(strings.ToLower(hash) != hash && strings.ToUpper(hash) != hash)
+}
+
+type staticFS struct {
+	fsys fs.FS
+	// strong ETags of files without a modification time, e.g. embed.FS
+	etags sync.Map
+}
+
+// serve writes name, or the index.html of a directory, it returns false
+// when there is no such file
+func (s *staticFS) serve(c *gin.Context, name string) bool {
+	f, info, err := s.open(name)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		f.Close()
+		// no listing, only the index of the directory
+		name = path.Join(name, spaIndex)
+		if f, info, err = s.open(name); err != nil || info.IsDir() {
+			if err == nil {
+				f.Close()
+			}
+			return false
+		}
+	}
+	defer f.Close()
+
+	content, err := readSeeker(f)
+	if err != nil {
+		return false
+	}
+
+	etag, err := s.etag(name, info, content)
+	if err != nil {
+		return false
+	}
+
+	header := c.Writer.Header()
+	header.Set("ETag", etag)
+	if path.Base(name) != spaIndex && isHashedAsset(name) {
+		header.Set("Cache-Control", cacheImmutable)
+	} else {
+		header.Set("Cache-Control", cacheRevalidate)
+	}
+
+	// the name only gives the content type
+	http.ServeContent(c.Writer, c.Request, path.Base(name), info.ModTime(), content)
+	return true
+}
+
+func (s *staticFS) open(name string) (fs.File, fs.FileInfo, error) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// etag is weak from the size and modification time, or strong from the
+// content when there is no modification time
+func (s *staticFS) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return `W/"` + strconv.FormatInt(info.Size(), 16) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 16) + `"`, nil
+	}
+
+	if v, ok := s.etags.Load(name); ok {
+		return v.(string), nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil))[:maxHashedETagLen] + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+func readSeeker(f fs.File) (io.ReadSeeker, error) {
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
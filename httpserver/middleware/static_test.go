@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStaticRouter(cfg StaticConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/users", func(c *gin.Context) { c.String(http.StatusOK, "users") })
+	router.NoRoute(Static(fstest.MapFS{
+		"index.html":              {Data: []byte("<html>app</html>")},
+		"assets/main.3f2a1b9c.js": {Data: []byte("console.log(1)")},
+		"assets/logo.svg":         {Data: []byte("<svg/>")},
+		"docs/index.html":         {Data: []byte("<html>docs</html>")},
+		"private/secret.txt":      {Data: []byte("secret")},
+	}, cfg))
+	return router
+}
+
+func serveStatic(router *gin.Engine, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestStatic(t *testing.T) {
+	router := newStaticRouter(StaticConfig{})
+
+	w := serveStatic(router, "/assets/main.3f2a1b9c.js", nil)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "console.log(1)", w.Body.String(), "should be equal")
+	assert.Equal(t, cacheImmutable, w.Header().Get("Cache-Control"), "hashed assets should be immutable")
+	assert.Contains(t, w.Header().Get("Content-Type"), "javascript", "should contain")
+
+	w = serveStatic(router, "/", nil)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "<html>app</html>", w.Body.String(), "should be equal")
+	assert.Equal(t, cacheRevalidate, w.Header().Get("Cache-Control"), "index should be revalidated")
+
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag, "should have an etag")
+	w = serveStatic(router, "/", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code, "should be equal")
+
+	w = serveStatic(router, "/assets/logo.svg", http.Header{"Range": {"bytes=0-1"}})
+	assert.Equal(t, http.StatusPartialContent, w.Code, "should be equal")
+	assert.Equal(t, "<s", w.Body.String(), "should be equal")
+	assert.Equal(t, cacheRevalidate, w.Header().Get("Cache-Control"), "should be equal")
+
+	// directories are only served with their index
+	assert.Equal(t, "<html>docs</html>", serveStatic(router, "/docs/", nil).Body.String(), "should be equal")
+	assert.Equal(t, http.StatusNotFound, serveStatic(router, "/private/", nil).Code, "should not list directories")
+	assert.Equal(t, http.StatusNotFound, serveStatic(router, "/../private/secret.txt/..", nil).Code, "should be equal")
+
+	// routes win, unknown paths are not found without spa mode
+	assert.Equal(t, "users", serveStatic(router, "/api/users", nil).Body.String(), "should be equal")
+	assert.Equal(t, http.StatusNotFound, serveStatic(router, "/orders/1", http.Header{"Accept": {"text/html"}}).Code, "should be equal")
+}
+
+func TestStaticSPA(t *testing.T) {
+	router := newStaticRouter(StaticConfig{Prefix: "/admin", SPA: true})
+	html := http.Header{"Accept": {"text/html,application/xhtml+xml"}}
+
+	w := serveStatic(router, "/admin/orders/1", html)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "<html>app</html>", w.Body.String(), "client side routes should get the index")
+	assert.Equal(t, cacheRevalidate, w.Header().Get("Cache-Control"), "should be equal")
+
+	assert.Equal(t, "<svg/>", serveStatic(router, "/admin/assets/logo.svg", nil).Body.String(), "should be equal")
+	assert.Equal(t, http.StatusNotFound, serveStatic(router, "/admin/assets/missing.js", html).Code, "missing files should not get the index")
+	assert.Equal(t, http.StatusNotFound, serveStatic(router, "/admin/api/orders", nil).Code, "api calls should not get the index")
+	assert.Equal(t, http.StatusNotFound, serveStatic(router, "/orders/1", html).Code, "paths out of the prefix should not be served")
+}
+
+func TestStaticDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>dir</html>"), 0o644), "must be nil")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.NoRoute(Static(os.DirFS(dir), StaticConfig{}))
+
+	w := serveStatic(router, "/index.html", nil)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "<html>dir</html>", w.Body.String(), "should be equal")
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"), "should have a modification time")
+	assert.Equal(t, http.StatusNotModified, serveStatic(router, "/index.html", http.Header{"If-None-Match": {w.Header().Get("ETag")}}).Code, "should be equal")
+}
+
+func TestIsHashedAsset(t *testing.T) {
+	assert.True(t, isHashedAsset("assets/main.3f2a1b9c.js"), "should be true")
+	assert.True(t, isHashedAsset("index-DiwrgTda.css"), "should be true")
+	assert.False(t, isHashedAsset("my-component.js"), "should be false")
+	assert.False(t, isHashedAsset("jquery.min.js"), "should be false")
+	assert.False(t, isHashedAsset("logo.svg"), "should be false")
+}
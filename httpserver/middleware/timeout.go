@@ -0,0 +1,302 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+const timeoutWriterKey = "timeout_writer"
+
+var errRequestTimeout = sdkcm.AppError{
+	Code:       "request_timeout",
+	StatusCode: http.StatusServiceUnavailable,
+	Message:    "request timeout",
+}
+
+// Timeout cancels the request context after timeout and responds 503.
+// Responses are buffered until the handlers return, so a handler writing
+// as the timeout fires never mixes with the 503 response.
+// The middleware still waits for the handlers to return before releasing
+// the gin context, handlers must honour the context to free the connection.
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		original := c.Writer
+		tw := newTimeoutWriter(original)
+		c.Writer = tw
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(timeoutWriterKey, tw)
+		defer func() { c.Writer = original }()
+
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+		go func() {
+			defer close(done)
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			c.Next()
+		}()
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+		case <-tw.skipped:
+			<-done
+		case <-timer.C:
+			if tw.timeout() {
+				cancel()
+				writeTimeoutResponse(original)
+			}
+			<-done
+		}
+
+		select {
+		case p := <-panicChan:
+			panic(p)
+		default:
+		}
+
+		tw.flush()
+	}
+}
+
+// SkipTimeout lets the rest of the handlers run without timeout and writes
+// the response directly, it's for streaming and websocket routes.
+// Responses with the text/event-stream content type skip it by themselves.
+//
+//	engine.GET("/events", middleware.SkipTimeout(), streamHandler)
+func SkipTimeout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		DisableTimeout(c)
+		c.Next()
+	}
+}
+
+// DisableTimeout is SkipTimeout for handlers, the response is written
+// directly from now on. It does nothing when the timeout isn't enabled.
+func DisableTimeout(c *gin.Context) {
+	if v, ok := c.Get(timeoutWriterKey); ok {
+		v.(*timeoutWriter).skip()
+	}
+}
+
+func writeTimeoutResponse(w gin.ResponseWriter) {
+	body, _ := json.Marshal(errRequestTimeout)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write(body)
+	w.Flush()
+}
+
+// timeoutWriter buffers the response until it's flushed,
+// after the timeout fires all writes are discarded
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	mu          sync.Mutex
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	timedOut    bool
+	passthrough bool
+	skipped     chan struct{}
+}
+
+func newTimeoutWriter(w gin.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+		skipped:        make(chan struct{}),
+	}
+}
+
+// timeout reports false if the response is written directly already
+func (w *timeoutWriter) timeout() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.passthrough {
+		return false
+	}
+	w.timedOut = true
+	return true
+}
+
+// skip switches to write directly to the original writer
+func (w *timeoutWriter) skip() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.skipLocked()
+}
+
+// skipLocked must be called with mu held
+func (w *timeoutWriter) skipLocked() {
+	if w.timedOut || w.passthrough {
+		return
+	}
+
+	w.writeBuffered()
+	w.passthrough = true
+	close(w.skipped)
+}
+
+func (w *timeoutWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut || w.passthrough {
+		return
+	}
+	w.writeBuffered()
+}
+
+// writeBuffered must be called with mu held
+func (w *timeoutWriter) writeBuffered() {
+	dst := w.ResponseWriter.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range w.header {
+		dst[k] = v
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.passthrough {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if isEventStream(w.header) {
+		w.skipLocked()
+	}
+
+	switch {
+	case w.timedOut:
+	case w.passthrough:
+		w.ResponseWriter.WriteHeader(code)
+	case w.body.Len() == 0:
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	} else if w.status == 0 {
+		w.status = http.StatusOK
+	}
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.passthrough || w.status == 0 {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.passthrough {
+		return w.ResponseWriter.Written()
+	}
+	return w.status != 0
+}
+
+// Flush only flushes after the timeout is skipped, buffered responses
+// are sent when the handlers return. Event streams skip the timeout.
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if isEventStream(w.header) {
+		w.skipLocked()
+	}
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// Unwrap is for http.ResponseController
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// isEventStream reports whether the response is server-sent events,
+// they are streamed for longer than any request timeout
+func isEventStream(h http.Header) bool {
+	return strings.HasPrefix(h.Get("Content-Type"), "text/event-stream")
+}
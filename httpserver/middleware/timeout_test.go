@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cancelled := make(chan bool, 1)
+
+	router := gin.New()
+	router.Use(Timeout(50 * time.Millisecond))
+	router.GET("/fast", func(c *gin.Context) {
+		c.Header("X-Test", "fast")
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+	router.GET("/slow", func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			cancelled <- true
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+		// discarded, the 503 response is sent already
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	router.GET("/stream", SkipTimeout(), func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)
+		assert.Nil(t, c.Request.Context().Err(), "must be nil")
+		c.String(http.StatusOK, "done")
+	})
+	router.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.Writer.WriteHeader(http.StatusOK)
+		c.Writer.Flush()
+		time.Sleep(100 * time.Millisecond)
+		assert.Nil(t, c.Request.Context().Err(), "event streams should skip the timeout")
+		c.SSEvent("done", "ok")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusCreated, w.Code, "should be equal")
+	assert.Equal(t, "fast", w.Header().Get("X-Test"), "should be equal")
+	assert.JSONEq(t, `{"ok":true}`, w.Body.String(), "should be equal")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "should be equal")
+	assert.JSONEq(t, `{"code":"request_timeout","status_code":503,"message":"request timeout"}`, w.Body.String(), "should be equal")
+	assert.True(t, <-cancelled, "handler context should be cancelled")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "done", w.Body.String(), "should be equal")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "event:done\ndata:ok\n\n", w.Body.String(), "should be equal")
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.Use(Timeout(time.Second))
+	router.GET("/", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "should be equal")
+}
@@ -0,0 +1,35 @@
+package httpserver
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNamed(t *testing.T) {
+	commandLine := flag.CommandLine
+	defer func() { flag.CommandLine = commandLine }()
+	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+
+	gs := New("svc")
+	partner := NewNamed("svc", "partner")
+	gs.InitFlags()
+	partner.InitFlags()
+
+	assert.Equal(t, "svc-gin", gs.Name(), "should be equal")
+	assert.Equal(t, "svc-partner", partner.Name(), "should be equal")
+	assert.NotNil(t, flag.Lookup("partner-port"), "should have its own flags")
+	assert.NotNil(t, flag.Lookup("gin-mode"), "should be registered by the default server")
+	assert.Nil(t, flag.Lookup("partner-mode"), "should be shared")
+
+	assert.Nil(t, flag.Set("partner-port", "8081"), "must be nil")
+	assert.Equal(t, 8081, partner.GetConfig().Port, "should be equal")
+	assert.Equal(t, defaultPort, gs.GetConfig().Port, "should be equal")
+
+	assert.False(t, partner.Enabled(), "should not listen without handlers")
+	partner.AddHandler(registerItems)
+	assert.True(t, partner.Enabled(), "should listen")
+	partner.Disable()
+	assert.False(t, partner.Enabled(), "should not listen when disabled")
+}
@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/taimaifika/go-sdk/httpserver/openapi"
+)
+
+// configureOpenAPI serves the document of the routes, and its Swagger UI
+func (gs *ginService) configureOpenAPI() error {
+	if !gs.OpenAPIEnabled {
+		return nil
+	}
+	if !strings.HasPrefix(gs.OpenAPIPath, "/") || (gs.OpenAPIUI && !strings.HasPrefix(gs.OpenAPIUIPath, "/")) {
+		return fmt.Errorf("invalid gin openapi config: paths must start with /")
+	}
+
+	gs.mu.Lock()
+	info := gs.buildInfo
+	gs.mu.Unlock()
+	version := info.Version
+	if version == "" {
+		version = "dev"
+	}
+	gs.docs.SetInfo(openapi.Info{Title: gs.name, Version: version})
+
+	gs.excludeFromOpenAPI(func() {
+		gs.router.GET(gs.OpenAPIPath, gs.docs.Handler(gs.router))
+		if gs.OpenAPIUI {
+			gs.router.GET(gs.OpenAPIUIPath, gs.docs.UIHandler(gs.OpenAPIPath))
+		}
+	})
+	return nil
+}
+
+// excludeFromOpenAPI leaves the routes added by register out of the document,
+// e.g. the ops endpoints
+func (gs *ginService) excludeFromOpenAPI(register func()) {
+	before := map[string]bool{}
+	for _, r := range gs.router.Routes() {
+		before[r.Method+" "+r.Path] = true
+	}
+
+	register()
+
+	for _, r := range gs.router.Routes() {
+		if !before[r.Method+" "+r.Path] {
+			gs.docs.Exclude(r.Path)
+		}
+	}
+}
+
+// OpenAPI documents the routes registered with it, the document is served
+// at gin-openapi-path when gin-openapi-enabled is set:
+//
+//	gs.AddHandler(func(engine *gin.Engine) {
+//		items := engine.Group("/items")
+//		gs.OpenAPI().GET(items, "/:id", getItem, openapi.WithResponse(Item{}))
+//	})
+func (gs *ginService) OpenAPI() *openapi.Docs {
+	return gs.docs
+}
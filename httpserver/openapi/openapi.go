@@ -0,0 +1,380 @@
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+const (
+	// BearerAuth is the security scheme of the JWT bearer tokens, the default of WithAuth
+	BearerAuth = "bearerAuth"
+	// APIKeyAuth is the security scheme of middleware.RequiredAPIKey
+	APIKeyAuth = "apiKeyAuth"
+
+	version = "3.0.3"
+)
+
+// :id and *path segments of gin paths
+var pathParams = regexp.MustCompile(`[:*]([^/]+)`)
+
+// Router is a gin engine or route group
+type Router interface {
+	gin.IRoutes
+	BasePath() string
+}
+
+// route is the metadata of a registered route
+type route struct {
+	method      string
+	path        string
+	summary     string
+	description string
+	operationID string
+	tags        []string
+	request     interface{}
+	query       interface{}
+	params      interface{}
+	responses   map[int]interface{}
+	security    []string
+	deprecated  bool
+}
+
+type Option func(*route)
+
+func WithSummary(summary string) Option {
+	return func(r *route) { r.summary = summary }
+}
+
+func WithDescription(description string) Option {
+	return func(r *route) { r.description = description }
+}
+
+func WithTags(tags ...string) Option {
+	return func(r *route) { r.tags = append(r.tags, tags...) }
+}
+
+func WithOperationID(id string) Option {
+	return func(r *route) { r.operationID = id }
+}
+
+// WithRequest documents the JSON body, a value of its type
+func WithRequest(body interface{}) Option {
+	return func(r *route) { r.request = body }
+}
+
+// WithQuery documents the query params, a struct with form tags like the ones of c.ShouldBindQuery
+func WithQuery(query interface{}) Option {
+	return func(r *route) { r.query = query }
+}
+
+// WithPathParams documents the path params, a struct with uri tags like the ones of c.ShouldBindUri.
+// Path params are strings otherwise.
+func WithPathParams(params interface{}) Option {
+	return func(r *route) { r.params = params }
+}
+
+// WithResponse documents the JSON body of the 200 response
+func WithResponse(body interface{}) Option {
+	return WithStatusResponse(http.StatusOK, body)
+}
+
+// WithStatusResponse documents the JSON body of the status response, nil => no body
+func WithStatusResponse(status int, body interface{}) Option {
+	return func(r *route) {
+		if r.responses == nil {
+			r.responses = map[int]interface{}{}
+		}
+		r.responses[status] = body
+	}
+}
+
+// WithAuth documents that one of the security schemes is required, none => BearerAuth
+func WithAuth(schemes ...string) Option {
+	return func(r *route) {
+		if len(schemes) == 0 {
+			schemes = []string{BearerAuth}
+		}
+		r.security = append(r.security, schemes...)
+	}
+}
+
+func Deprecated() Option {
+	return func(r *route) { r.deprecated = true }
+}
+
+// Docs generates the OpenAPI document of an engine, from the metadata of the
+// routes registered with it and reflection over their Go types. The routes
+// registered without it are documented with their path params only.
+type Docs struct {
+	mu              sync.Mutex
+	info            Info
+	routes          map[string]*route
+	securitySchemes map[string]*SecurityScheme
+	excluded        map[string]bool
+	// cached until the routes change
+	doc       []byte
+	docRoutes int
+}
+
+func New(info Info) *Docs {
+	return &Docs{
+		info:     info,
+		routes:   map[string]*route{},
+		excluded: map[string]bool{},
+		securitySchemes: map[string]*SecurityScheme{
+			BearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			APIKeyAuth: {Type: "apiKey", In: "header", Name: middleware.APIKeyHeader},
+		},
+	}
+}
+
+func (d *Docs) SetInfo(info Info) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.info, d.doc = info, nil
+}
+
+func (d *Docs) AddSecurityScheme(name string, scheme *SecurityScheme) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.securitySchemes[name], d.doc = scheme, nil
+}
+
+// Exclude leaves the routes of paths out of the document, e.g. the ops endpoints
+func (d *Docs) Exclude(paths ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, p := range paths {
+		d.excluded[p] = true
+	}
+	d.doc = nil
+}
+
+// Handle registers handler on r and documents it:
+//
+//	docs.Handle(rg, http.MethodGet, "/items/:id", getItem, openapi.WithResponse(Item{}))
+func (d *Docs) Handle(r Router, method, relativePath string, handler gin.HandlerFunc, opts ...Option) gin.IRoutes {
+	rt := &route{method: method, path: joinPaths(r.BasePath(), relativePath)}
+	for _, opt := range opts {
+		opt(rt)
+	}
+
+	d.mu.Lock()
+	d.routes[method+" "+rt.path], d.doc = rt, nil
+	d.mu.Unlock()
+
+	return r.Handle(method, relativePath, handler)
+}
+
+func (d *Docs) GET(r Router, relativePath string, handler gin.HandlerFunc, opts ...Option) gin.IRoutes {
+	return d.Handle(r, http.MethodGet, relativePath, handler, opts...)
+}
+
+func (d *Docs) POST(r Router, relativePath string, handler gin.HandlerFunc, opts ...Option) gin.IRoutes {
+	return d.Handle(r, http.MethodPost, relativePath, handler, opts...)
+}
+
+func (d *Docs) PUT(r Router, relativePath string, handler gin.HandlerFunc, opts ...Option) gin.IRoutes {
+	return d.Handle(r, http.MethodPut, relativePath, handler, opts...)
+}
+
+func (d *Docs) PATCH(r Router, relativePath string, handler gin.HandlerFunc, opts ...Option) gin.IRoutes {
+	return d.Handle(r, http.MethodPatch, relativePath, handler, opts...)
+}
+
+func (d *Docs) DELETE(r Router, relativePath string, handler gin.HandlerFunc, opts ...Option) gin.IRoutes {
+	return d.Handle(r, http.MethodDelete, relativePath, handler, opts...)
+}
+
+// Document generates the document of routes, usually engine.Routes()
+func (d *Docs) Document(routes gin.RoutesInfo) *Document {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.document(routes)
+}
+
+// document must be called with mu held
+func (d *Docs) document(routes gin.RoutesInfo) *Document {
+	g := newSchemaGenerator()
+	doc := &Document{
+		OpenAPI:    version,
+		Info:       d.info,
+		Paths:      map[string]map[string]*Operation{},
+		Components: Components{SecuritySchemes: map[string]*SecurityScheme{}},
+	}
+	for name, scheme := range d.securitySchemes {
+		doc.Components.SecuritySchemes[name] = scheme
+	}
+
+	add := func(method, ginPath string, op *Operation) {
+		p := pathParams.ReplaceAllString(ginPath, "{$1}")
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = map[string]*Operation{}
+		}
+		doc.Paths[p][strings.ToLower(method)] = op
+	}
+
+	for _, ri := range routes {
+		if d.excluded[ri.Path] {
+			continue
+		}
+		if rt, ok := d.routes[ri.Method+" "+ri.Path]; ok {
+			add(ri.Method, ri.Path, d.operation(g, rt))
+			continue
+		}
+		add(ri.Method, ri.Path, &Operation{
+			Description:  "handled by " + ri.Handler,
+			Parameters:   pathParameters(g, ri.Path, nil),
+			Responses:    map[string]*Response{"default": {Description: "undocumented"}},
+			Undocumented: true,
+		})
+	}
+
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+func (d *Docs) operation(g *schemaGenerator, rt *route) *Operation {
+	op := &Operation{
+		OperationID: rt.operationID,
+		Summary:     rt.summary,
+		Description: rt.description,
+		Tags:        rt.tags,
+		Deprecated:  rt.deprecated,
+		Parameters:  pathParameters(g, rt.path, rt.params),
+		Responses:   map[string]*Response{},
+	}
+
+	if rt.query != nil {
+		op.Parameters = append(op.Parameters, g.parameters(rt.query, "query", "form")...)
+	}
+
+	if rt.request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{gin.MIMEJSON: {Schema: g.schemaOf(rt.request)}},
+		}
+	}
+
+	statuses := make([]int, 0, len(rt.responses))
+	for status := range rt.responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		resp := &Response{Description: http.StatusText(status)}
+		if body := rt.responses[status]; body != nil {
+			resp.Content = map[string]*MediaType{gin.MIMEJSON: {Schema: g.schemaOf(body)}}
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+	}
+	if len(op.Responses) == 0 {
+		op.Responses[strconv.Itoa(http.StatusOK)] = &Response{Description: http.StatusText(http.StatusOK)}
+	}
+	// errors are responded by middleware.ErrorHandler
+	op.Responses["default"] = &Response{
+		Description: "Error",
+		Content:     map[string]*MediaType{gin.MIMEJSON: {Schema: g.schemaOf(sdkcm.AppError{})}},
+	}
+
+	for _, scheme := range rt.security {
+		op.Security = append(op.Security, map[string][]string{scheme: {}})
+	}
+	return op
+}
+
+// pathParameters returns the params of the gin path, documented by the uri
+// fields of params when set
+func pathParameters(g *schemaGenerator, ginPath string, params interface{}) []*Parameter {
+	documented := map[string]*Parameter{}
+	if params != nil {
+		for _, p := range g.parameters(params, "path", "uri") {
+			documented[p.Name] = p
+		}
+	}
+
+	var result []*Parameter
+	for _, m := range pathParams.FindAllStringSubmatch(ginPath, -1) {
+		p, ok := documented[m[1]]
+		if !ok {
+			p = &Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// Handler serves the document of the routes of engine as JSON
+func (d *Docs) Handler(engine *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		routes := engine.Routes()
+
+		d.mu.Lock()
+		if d.doc == nil || d.docRoutes != len(routes) {
+			data, err := json.Marshal(d.document(routes))
+			if err != nil {
+				d.mu.Unlock()
+				_ = c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			d.doc, d.docRoutes = data, len(routes)
+		}
+		doc := d.doc
+		d.mu.Unlock()
+
+		c.Data(http.StatusOK, gin.MIMEJSON, doc)
+	}
+}
+
+var uiTemplate = template.Must(template.New("swagger-ui").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: {{.URL}}, dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`))
+
+// UIHandler serves a Swagger UI page of the document at specURL, its assets
+// are loaded from unpkg.com
+func (d *Docs) UIHandler(specURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d.mu.Lock()
+		title := d.info.Title
+		d.mu.Unlock()
+
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = uiTemplate.Execute(c.Writer, struct{ Title, URL string }{title, specURL})
+	}
+}
+
+// joinPaths joins like gin, keeping the trailing slash of relativePath
+func joinPaths(base, relativePath string) string {
+	if relativePath == "" {
+		return base
+	}
+	joined := path.Join(base, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
@@ -0,0 +1,150 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+type base struct {
+	ID        sdkcm.UID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type Item struct {
+	base
+	Name     string            `json:"name" binding:"required,min=1,max=100"`
+	Price    float64           `json:"price" binding:"gt=0"`
+	Quantity int               `json:"quantity,string"`
+	Status   string            `json:"status" binding:"oneof=draft published"`
+	Email    string            `json:"email,omitempty" binding:"omitempty,email"`
+	Tags     []string          `json:"tags" binding:"max=5,dive,min=1"`
+	Labels   map[string]string `json:"labels"`
+	Parent   *Item             `json:"parent,omitempty"`
+	Secret   string            `json:"-"`
+	internal string
+}
+
+type itemFilter struct {
+	Status string `form:"status" binding:"omitempty,oneof=draft published"`
+	sdkcm.Paging
+}
+
+type itemURI struct {
+	ID sdkcm.UID `uri:"id" binding:"required"`
+}
+
+func newDocs() (*Docs, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	docs := New(Info{Title: "items", Version: "1.0.0"})
+
+	handler := func(c *gin.Context) {}
+	items := engine.Group("/items")
+	docs.GET(items, "", handler, WithSummary("List items"), WithTags("items"), WithQuery(itemFilter{}),
+		WithResponse(sdkcm.NewSuccessResponse([]Item{}, &sdkcm.Paging{}, nil)))
+	docs.GET(items, "/:id", handler, WithPathParams(itemURI{}), WithResponse(sdkcm.SimpleSuccessResponse(Item{})),
+		WithStatusResponse(http.StatusNotFound, nil))
+	docs.POST(items, "", handler, WithRequest(Item{}), WithStatusResponse(http.StatusCreated, Item{}), WithAuth(), Deprecated())
+	engine.DELETE("/items/:id/files/*path", handler)
+	engine.GET("/openapi.json", docs.Handler(engine))
+	docs.Exclude("/openapi.json")
+	return docs, engine
+}
+
+func TestDocument(t *testing.T) {
+	docs, engine := newDocs()
+	doc := docs.Document(engine.Routes())
+
+	assert.Equal(t, "3.0.3", doc.OpenAPI, "should be equal")
+	assert.Equal(t, "items", doc.Info.Title, "should be equal")
+	assert.NotContains(t, doc.Paths, "/openapi.json", "should be excluded")
+
+	list := doc.Paths["/items"]["get"]
+	assert.Equal(t, "List items", list.Summary, "should be equal")
+	assert.Equal(t, []string{"items"}, list.Tags, "should be equal")
+	names := []string{}
+	for _, p := range list.Parameters {
+		names = append(names, p.In+":"+p.Name)
+	}
+	assert.Equal(t, []string{"query:status", "query:cursor", "query:limit", "query:page"}, names, "should be equal")
+	assert.Equal(t, []interface{}{"draft", "published"}, list.Parameters[0].Schema.Enum, "should be equal")
+	data := list.Responses["200"].Content[gin.MIMEJSON].Schema.Properties["data"]
+	assert.Equal(t, "array", data.Type, "should be equal")
+	assert.Equal(t, "#/components/schemas/Item", data.Items.Ref, "should be equal")
+	assert.Equal(t, "#/components/schemas/AppError", list.Responses["default"].Content[gin.MIMEJSON].Schema.Ref, "should be equal")
+
+	get := doc.Paths["/items/{id}"]["get"]
+	assert.Equal(t, "id", get.Parameters[0].Name, "should be equal")
+	assert.True(t, get.Parameters[0].Required, "should be required")
+	assert.Equal(t, "string", get.Parameters[0].Schema.Type, "should be equal")
+	assert.Nil(t, get.Responses["404"].Content, "should be nil")
+
+	create := doc.Paths["/items"]["post"]
+	assert.Equal(t, "#/components/schemas/Item", create.RequestBody.Content[gin.MIMEJSON].Schema.Ref, "should be equal")
+	assert.Contains(t, create.Responses, "201", "should contain the status")
+	assert.Equal(t, []map[string][]string{{BearerAuth: {}}}, create.Security, "should be equal")
+	assert.True(t, create.Deprecated, "should be deprecated")
+
+	// without metadata
+	files := doc.Paths["/items/{id}/files/{path}"]["delete"]
+	assert.True(t, files.Undocumented, "should be undocumented")
+	assert.Len(t, files.Parameters, 2, "should be equal")
+}
+
+func TestSchema(t *testing.T) {
+	docs, engine := newDocs()
+	item := docs.Document(engine.Routes()).Components.Schemas["Item"]
+
+	assert.Equal(t, []string{"name"}, item.Required, "should be equal")
+	assert.Equal(t, "string", item.Properties["id"].Type, "uid should be a string")
+	assert.Equal(t, "date-time", item.Properties["created_at"].Format, "should be equal")
+	assert.True(t, item.Properties["deleted_at"].Nullable, "should be nullable")
+	assert.Equal(t, 1, *item.Properties["name"].MinLength, "should be equal")
+	assert.Equal(t, 100, *item.Properties["name"].MaxLength, "should be equal")
+	assert.Equal(t, 0.0, *item.Properties["price"].Minimum, "should be equal")
+	assert.True(t, item.Properties["price"].ExclusiveMinimum, "should be exclusive")
+	assert.Equal(t, "string", item.Properties["quantity"].Type, "should be a string")
+	assert.Equal(t, "email", item.Properties["email"].Format, "should be equal")
+	assert.Equal(t, 5, *item.Properties["tags"].MaxItems, "should be equal")
+	assert.Nil(t, item.Properties["tags"].Items.MinLength, "rules after dive should not apply to the array")
+	assert.Equal(t, "string", item.Properties["labels"].AdditionalProperties.Type, "should be equal")
+	assert.Equal(t, "#/components/schemas/Item", item.Properties["parent"].Ref, "should be recursive")
+	assert.NotContains(t, item.Properties, "Secret", "should be skipped")
+	assert.NotContains(t, item.Properties, "internal", "should be skipped")
+}
+
+func TestHandler(t *testing.T) {
+	_, engine := newDocs()
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+
+	var doc map[string]interface{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc), "must be nil")
+	assert.Contains(t, doc["paths"], "/items/{id}", "should contain the path")
+
+	// routes added later are documented
+	engine.GET("/orders", func(c *gin.Context) {})
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Contains(t, w.Body.String(), `"/orders"`, "should contain the new path")
+}
+
+func TestUIHandler(t *testing.T) {
+	docs, engine := newDocs()
+	engine.GET("/docs", docs.UIHandler("/openapi.json"))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Contains(t, w.Body.String(), `url: "/openapi.json"`, "should load the document")
+}
@@ -0,0 +1,358 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// knownSchemas are the types whose JSON is not their Go kind
+var knownSchemas = map[reflect.Type]func() *Schema{
+	reflect.TypeOf(time.Time{}):      func() *Schema { return &Schema{Type: "string", Format: "date-time"} },
+	reflect.TypeOf(time.Duration(0)): func() *Schema { return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"} },
+	reflect.TypeOf(sdkcm.UID{}):      func() *Schema { return &Schema{Type: "string", Description: "base58 uid"} },
+	reflect.TypeOf(sdkcm.PlainUID{}): func() *Schema { return &Schema{Type: "integer", Format: "int64"} },
+	reflect.TypeOf(sdkcm.JSONTime{}): func() *Schema { return &Schema{Type: "string", Format: "date-time"} },
+	reflect.TypeOf(sdkcm.JSONDate{}): func() *Schema { return &Schema{Type: "string", Format: "date"} },
+	reflect.TypeOf(sdkcm.Duration(0)): func() *Schema {
+		return &Schema{Type: "string", Description: "Go duration, ex: 1h30m0s"}
+	},
+	reflect.TypeOf(json.RawMessage{}): func() *Schema { return &Schema{} },
+}
+
+// schemaGenerator reflects Go values into schemas, named structs are
+// components referenced by the operations
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// schemaOf returns the schema of v, the values of its interface fields give
+// their schema, e.g. the data of sdkcm.SimpleSuccessResponse(Item{})
+func (g *schemaGenerator) schemaOf(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return g.schema(reflect.ValueOf(v), reflect.TypeOf(v))
+}
+
+// schema reflects t, v is its value or invalid when unknown
+func (g *schemaGenerator) schema(v reflect.Value, t reflect.Type) *Schema {
+	if known, ok := knownSchemas[t]; ok {
+		return known()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		var elem reflect.Value
+		if v.IsValid() && !v.IsNil() {
+			elem = v.Elem()
+		}
+		s := g.schema(elem, t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Interface:
+		if v.IsValid() && !v.IsNil() {
+			return g.schema(v.Elem(), v.Elem().Type())
+		}
+		return &Schema{}
+	}
+
+	// custom JSON, most of them are strings
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: floatPtr(0)}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64", Minimum: floatPtr(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		var elem reflect.Value
+		if v.IsValid() && v.Len() > 0 {
+			elem = v.Index(0)
+		}
+		return &Schema{Type: "array", Items: g.schema(elem, t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(reflect.Value{}, t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(v, t)
+	}
+	return &Schema{}
+}
+
+// structSchema references the component of named structs, the structs
+// with values in their interface fields are inlined since they differ
+func (g *schemaGenerator) structSchema(v reflect.Value, t reflect.Type) *Schema {
+	if t.Name() == "" || hasInterfaceValues(v) {
+		return g.objectSchema(v, t)
+	}
+
+	name, ok := g.names[t]
+	if !ok {
+		name = g.componentName(t)
+		// set first for the recursive types
+		g.names[t] = name
+		g.schemas[name] = &Schema{}
+		g.schemas[name] = g.objectSchema(reflect.Value{}, t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	name := invalidNameChars.ReplaceAllString(t.Name(), "_")
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+
+	// another package has a type with the same name
+	name = path.Base(t.PkgPath()) + "." + name
+	for i := 2; ; i++ {
+		candidate := name
+		if i > 2 {
+			candidate += strconv.Itoa(i)
+		}
+		if _, taken := g.schemas[candidate]; !taken {
+			return candidate
+		}
+	}
+}
+
+func (g *schemaGenerator) objectSchema(v reflect.Value, t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, v, t)
+	return s
+}
+
+// addFields adds the JSON fields of t, embedded structs are flattened
+func (g *schemaGenerator) addFields(s *Schema, v reflect.Value, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		var fv reflect.Value
+		if v.IsValid() {
+			fv = v.Field(i)
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+				if fv.IsValid() && !fv.IsNil() {
+					fv = fv.Elem()
+				} else {
+					fv = reflect.Value{}
+				}
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, fv, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schema(fv, f.Type)
+		if strings.Contains(opts, "string") && fs.Type != "" && fs.Type != "object" && fs.Type != "array" {
+			fs = &Schema{Type: "string", Nullable: fs.Nullable}
+		}
+		if applyValidation(fs, f) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// parameters returns the fields of the struct v as parameters in in,
+// named by their tag, e.g. form for query params
+func (g *schemaGenerator) parameters(v interface{}, in, tag string) []*Parameter {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	return g.structParameters(rv, in, tag)
+}
+
+func (g *schemaGenerator) structParameters(rv reflect.Value, in, tag string) []*Parameter {
+	var params []*Parameter
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			params = append(params, g.structParameters(rv.Field(i), in, tag)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := g.schema(rv.Field(i), f.Type)
+		required := applyValidation(s, f)
+		params = append(params, &Parameter{Name: name, In: in, Required: required || in == "path", Schema: s})
+	}
+	return params
+}
+
+// applyValidation sets the constraints of the binding or validate tag of f,
+// it returns whether the field is required
+func applyValidation(s *Schema, f reflect.StructField) bool {
+	tag := f.Tag.Get("binding")
+	if tag == "" {
+		tag = f.Tag.Get("validate")
+	}
+
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			// the next rules are of the elements
+			return required
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(s.Type, value))
+			}
+		case "min", "gte":
+			s.setMin(param, false)
+		case "gt":
+			s.setMin(param, true)
+		case "max", "lte":
+			s.setMax(param, false)
+		case "lt":
+			s.setMax(param, true)
+		case "len":
+			s.setMin(param, false)
+			s.setMax(param, false)
+		}
+	}
+	return required
+}
+
+func enumValue(typ, value string) interface{} {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// setMin is the length of strings and arrays, the value of numbers
+func (s *Schema) setMin(param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "string":
+		s.MinLength = intPtr(n)
+	case "array":
+		s.MinItems = intPtr(n)
+	case "integer", "number":
+		s.Minimum, s.ExclusiveMinimum = &n, exclusive
+	}
+}
+
+func (s *Schema) setMax(param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "string":
+		s.MaxLength = intPtr(n)
+	case "array":
+		s.MaxItems = intPtr(n)
+	case "integer", "number":
+		s.Maximum, s.ExclusiveMaximum = &n, exclusive
+	}
+}
+
+// hasInterfaceValues reports whether the interface fields of the struct v have values
+func hasInterfaceValues(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.Interface && !f.IsNil() {
+			return true
+		}
+	}
+	return false
+}
+
+func floatPtr(n float64) *float64 {
+	return &n
+}
+
+func intPtr(n float64) *int {
+	i := int(n)
+	return &i
+}
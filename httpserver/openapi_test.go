@@ -0,0 +1,53 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/openapi"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func TestOpenAPI(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("items")
+	gs.OpenAPIEnabled = true
+	gs.OpenAPIPath = defaultOpenAPIPath
+	gs.OpenAPIUI = true
+	gs.OpenAPIUIPath = defaultOpenAPIUIPath
+	gs.SetBuildInfo(BuildInfo{Version: "1.2.0"})
+	assert.Nil(t, gs.Configure(), "must be nil")
+
+	type item struct {
+		Name string `json:"name"`
+	}
+	gs.OpenAPI().GET(gs.router, "/items/:id", func(c *gin.Context) {}, openapi.WithResponse(item{}))
+	gs.router.GET("/orders", func(c *gin.Context) {})
+
+	w := httptest.NewRecorder()
+	gs.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+
+	var doc openapi.Document
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc), "must be nil")
+	assert.Equal(t, openapi.Info{Title: "items", Version: "1.2.0"}, doc.Info, "should be equal")
+	assert.False(t, doc.Paths["/items/{id}"]["get"].Undocumented, "should be documented")
+	assert.True(t, doc.Paths["/orders"]["get"].Undocumented, "should be undocumented")
+	for _, p := range []string{"/healthz", "/readyz", "/version", "/openapi.json", "/docs"} {
+		assert.NotContains(t, doc.Paths, p, p+" should be excluded")
+	}
+
+	w = httptest.NewRecorder()
+	gs.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+
+	gs = New("items")
+	gs.OpenAPIEnabled = true
+	gs.OpenAPIPath = "openapi.json"
+	assert.NotNil(t, gs.Configure(), "should be an error")
+}
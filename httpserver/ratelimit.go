@@ -0,0 +1,41 @@
+package httpserver
+
+import (
+	"fmt"
+
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+// configureRateLimit builds the global rate limit middleware from the flags,
+// it returns nil when rate limiting is disabled
+func (gs *ginService) configureRateLimit() (*middleware.RateLimitConfig, error) {
+	if gs.RateLimitRPS <= 0 {
+		return nil, nil
+	}
+
+	key, err := middleware.KeyFuncOf(gs.RateLimitKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gin rate limit config: %w", err)
+	}
+
+	exempt, err := middleware.ParseCIDRs(gs.rateLimitExemptCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gin rate limit config: %w", err)
+	}
+
+	limiter := gs.rateLimiter
+	if limiter == nil {
+		limiter = middleware.NewMemoryLimiter(gs.RateLimitRPS, gs.RateLimitBurst)
+	}
+
+	return &middleware.RateLimitConfig{Limiter: limiter, Key: key, ExemptCIDRs: exempt}, nil
+}
+
+// SetRateLimiter replaces the in-memory limiter of the global rate limit,
+// e.g. with sdkredis.NewRedisLimiter to share limits across replicas.
+// It only takes effect when gin-rate-limit-rps is set.
+func (gs *ginService) SetRateLimiter(l middleware.Limiter) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.rateLimiter = l
+}
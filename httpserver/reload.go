@@ -0,0 +1,199 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/httpserver/websocket"
+)
+
+// how often a reload checks whether the old router is drained
+const drainPollInterval = 10 * time.Millisecond
+
+// routerHandler serves the current router and counts its in-flight requests,
+// so the router replaced by Reload is drained
+type routerHandler struct {
+	current atomic.Pointer[servedRouter]
+}
+
+type servedRouter struct {
+	engine   *gin.Engine
+	inFlight atomic.Int64
+}
+
+func newRouterHandler(engine *gin.Engine) *routerHandler {
+	h := &routerHandler{}
+	h.current.Store(&servedRouter{engine: engine})
+	return h
+}
+
+func (h *routerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router := h.current.Load()
+	router.inFlight.Add(1)
+	defer router.inFlight.Add(-1)
+
+	router.engine.ServeHTTP(w, r)
+}
+
+// swap serves engine from now on, it returns the replaced router
+func (h *routerHandler) swap(engine *gin.Engine) *servedRouter {
+	return h.current.Swap(&servedRouter{engine: engine})
+}
+
+// drain waits at most until ctx is done for the requests of the router,
+// it returns whether they all completed
+func (r *servedRouter) drain(ctx context.Context) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for r.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// routerState is what configureRouter replaces, restored when a reload fails
+type routerState struct {
+	config        Config
+	router        *gin.Engine
+	stopping      chan struct{}
+	stoppingOnce  *sync.Once
+	ws            *websocket.Server
+	idempotency   gin.HandlerFunc
+	responseCache *middleware.ResponseCache
+}
+
+// Reload applies config without dropping requests: a new router is built
+// with the handlers and swapped in, then the requests of the old router are
+// drained, at most the shutdown timeout. The listener is kept unless the
+// address changes, then the new one listens before the old one is closed.
+// The settings of the http server like its timeouts are kept.
+// The comma separated flags are only parsed again when they change, so the
+// lists of config are kept. Before Run, config is used by Run.
+func (gs *ginService) Reload(config Config) error {
+	gs.reloadMu.Lock()
+	defer gs.reloadMu.Unlock()
+
+	gs.mu.Lock()
+	lis, lisAddr, inherited, done := gs.lis, gs.lisAddr, gs.lisInherited, gs.runDone
+	running := lis != nil && done != nil && !isClosed(done)
+	port := gs.Config.Port
+	gs.mu.Unlock()
+
+	if !running {
+		gs.mu.Lock()
+		gs.Config = config
+		gs.mu.Unlock()
+		return nil
+	}
+
+	if gs.ReadTimeout != config.ReadTimeout || gs.WriteTimeout != config.WriteTimeout || gs.IdleTimeout != config.IdleTimeout ||
+		gs.ReadHeaderTimeout != config.ReadHeaderTimeout || gs.MaxHeaderBytes != config.MaxHeaderBytes || gs.KeepAlivePeriod != config.KeepAlivePeriod ||
+		gs.H2CEnabled != config.H2CEnabled || gs.HTTP2 != config.HTTP2 {
+		gs.logger.Warn("the http server timeouts, max header bytes, keep-alive and http2 settings are not reloaded, they change on restart")
+	}
+
+	prev := routerState{
+		config: gs.Config, router: gs.router, stopping: gs.stopping, stoppingOnce: gs.stoppingOnce,
+		ws: gs.ws, idempotency: gs.idempotency, responseCache: gs.responseCache,
+	}
+
+	// 0 keeps the port like the address, the socket passed by systemd is kept
+	sameAddr := inherited || (listenAddr(config) == lisAddr &&
+		(config.ListenNetwork == ListenNetworkUnix || config.Port == 0 || config.Port == port))
+	if sameAddr {
+		config.Port = port
+	}
+
+	gs.mu.Lock()
+	gs.Config = config
+	gs.mu.Unlock()
+
+	rollback := func(err error) error {
+		gs.mu.Lock()
+		gs.Config = prev.config
+		gs.mu.Unlock()
+		gs.router, gs.stopping, gs.stoppingOnce = prev.router, prev.stopping, prev.stoppingOnce
+		gs.ws, gs.idempotency, gs.responseCache = prev.ws, prev.idempotency, prev.responseCache
+		return fmt.Errorf("failed to reload gin: %w", err)
+	}
+
+	if err := gs.configureRouter(); err != nil {
+		return rollback(err)
+	}
+	if err := gs.registerRoutes(); err != nil {
+		return rollback(err)
+	}
+
+	var newLis net.Listener
+	if !sameAddr {
+		l, err := newListener(config)
+		if err != nil {
+			return rollback(err)
+		}
+		newLis = l
+	}
+
+	old := gs.handler.swap(gs.router)
+
+	if newLis != nil {
+		gs.mu.Lock()
+		gs.lis, gs.lisAddr = newLis, listenAddr(config)
+		gs.Config.Port = getPort(newLis)
+		gs.mu.Unlock()
+
+		gs.serve(newLis)
+		// its connections stay open, they are served by the new router
+		_ = lis.Close()
+		gs.logger.Infof("listen on %s instead of %s...", addrURI(newLis.Addr()), addrURI(lis.Addr()))
+	}
+
+	gs.drainRouter(old, prev)
+	return nil
+}
+
+// drainRouter ends the streams and websockets of the replaced router, then
+// waits for its requests
+func (gs *ginService) drainRouter(old *servedRouter, prev routerState) {
+	timeout := gs.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	prev.stoppingOnce.Do(func() { close(prev.stopping) })
+
+	wsDone := make(chan bool, 1)
+	go func() { wsDone <- shutdownWebsockets(prev.ws, timeout) }()
+
+	drained := old.drain(ctx)
+	if !<-wsDone {
+		drained = false
+	}
+	if !drained {
+		gs.logger.Warnf("reloaded, the old router still has %d requests after %s", old.inFlight.Load(), timeout)
+		return
+	}
+	gs.logger.Info("reloaded, the old router is drained")
+}
+
+func isClosed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,98 @@
+package httpserver
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func getBody(t *testing.T, port int, path string) (int, string) {
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", port, path))
+	if !assert.Nil(t, err, "must be nil") {
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestReload(t *testing.T) {
+	logger.InitServLogger(false)
+
+	var generation atomic.Int32
+	release := make(chan struct{})
+	gs := New("test")
+	gs.AddHandler(func(engine *gin.Engine) {
+		gen := generation.Add(1)
+		engine.GET("/generation", func(c *gin.Context) { c.String(http.StatusOK, strconv.Itoa(int(gen))) })
+		engine.GET("/slow", func(c *gin.Context) {
+			<-release
+			c.String(http.StatusOK, strconv.Itoa(int(gen)))
+		})
+	})
+	runErr := make(chan error, 1)
+	go func() { runErr <- gs.Run() }()
+	<-gs.Listening()
+	port := gs.Port()
+
+	// a request in flight on the old router
+	slow := make(chan string, 1)
+	go func() {
+		_, body := getBody(t, port, "/slow")
+		slow <- body
+	}()
+	assert.Eventually(t, func() bool { return gs.handler.current.Load().inFlight.Load() == 1 }, time.Second, 5*time.Millisecond, "should be in flight")
+
+	reloaded := make(chan error, 1)
+	go func() { reloaded <- gs.Reload(gs.GetConfig()) }()
+	assert.Eventually(t, func() bool {
+		_, body := getBody(t, port, "/generation")
+		return body == "2"
+	}, time.Second, 5*time.Millisecond, "new requests should be served by the new router")
+
+	select {
+	case <-reloaded:
+		t.Fatal("reload should wait for the old router")
+	default:
+	}
+	close(release)
+	assert.Nil(t, <-reloaded, "must be nil")
+	assert.Equal(t, "1", <-slow, "should be served by the old router")
+
+	// another port listens before the old one is closed
+	cfg := gs.GetConfig()
+	cfg.Port = freePort(t)
+	assert.Nil(t, gs.Reload(cfg), "must be nil")
+	assert.Equal(t, cfg.Port, gs.Port(), "should be equal")
+	status, body := getBody(t, cfg.Port, "/generation")
+	assert.Equal(t, http.StatusOK, status, "should be equal")
+	assert.Equal(t, "3", body, "should be equal")
+	// the open connections stay, new ones are refused
+	_, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	assert.NotNil(t, err, "the old port should be closed")
+
+	// invalid configs keep the router
+	cfg.DuplicateRoutes = "ignore"
+	assert.NotNil(t, gs.Reload(cfg), "should be an error")
+	_, body = getBody(t, cfg.Port, "/generation")
+	assert.Equal(t, "3", body, "should be equal")
+	assert.NotEqual(t, "ignore", gs.GetConfig().DuplicateRoutes, "should be rolled back")
+
+	select {
+	case err := <-runErr:
+		t.Fatalf("run should not return: %v", err)
+	default:
+	}
+
+	assert.True(t, <-gs.Stop(), "should be drained")
+	assert.Nil(t, <-runErr, "must be nil")
+}
@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/cache"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+// configureResponseCache builds the cache of CachedRoutes from the flags
+func (gs *ginService) configureResponseCache() error {
+	if gs.ResponseCacheSize < 0 || gs.ResponseCacheMaxBytes < 0 {
+		return fmt.Errorf("invalid gin response cache config: size and max bytes must not be negative")
+	}
+
+	cfg := gs.ResponseCaching
+	if cfg.TTL == 0 {
+		cfg.TTL = defaultResponseCacheTTL
+	}
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = defaultResponseCacheMaxBody
+	}
+	if gs.listFlagChanged(&gs.responseCacheVary) {
+		cfg.VaryHeaders = splitList(gs.responseCacheVary)
+	}
+	cfg.Handler = gs.router
+	cfg.Cache = gs.responseCacheStore
+	if cfg.Cache == nil {
+		lru, err := cache.NewLRU("http_responses", cache.LRUConfig{MaxEntries: gs.ResponseCacheSize, MaxBytes: gs.ResponseCacheMaxBytes})
+		if err != nil {
+			return fmt.Errorf("invalid gin response cache config: %w", err)
+		}
+		cfg.Cache = lru
+	}
+
+	rc, err := middleware.NewResponseCache(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid gin response cache config: %w", err)
+	}
+	gs.ResponseCaching = cfg
+	gs.responseCache = rc
+	return nil
+}
+
+// SetResponseCacheStore replaces the in-memory cache of CachedRoutes, e.g. with
+// sdkredis.NewCache or a cache.Layered to share the responses across replicas
+func (gs *ginService) SetResponseCacheStore(c cache.Cache) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.responseCacheStore = c
+}
+
+// CachedRoutes caches the successful GET responses of the route groups using it
+// for ttl, 0 => gin-response-cache-ttl:
+//
+//	catalog := engine.Group("/catalog", gs.CachedRoutes(10*time.Minute))
+func (gs *ginService) CachedRoutes(ttl time.Duration) gin.HandlerFunc {
+	var once sync.Once
+	var handler gin.HandlerFunc
+
+	return func(c *gin.Context) {
+		// the cache is known once configured
+		once.Do(func() { handler = gs.responseCache.Handler(ttl) })
+		handler(c)
+	}
+}
+
+// ResponseCache returns the cache of CachedRoutes for its invalidation helpers,
+// it's nil until the service is configured:
+//
+//	gs.ResponseCache().InvalidatePrefix(ctx, "/catalog/items")
+func (gs *ginService) ResponseCache() *middleware.ResponseCache {
+	return gs.responseCache
+}
@@ -0,0 +1,45 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func TestCachedRoutes(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.responseCacheVary = "Accept"
+	assert.Nil(t, gs.Configure(), "must be nil")
+	assert.Equal(t, []string{"Accept"}, gs.ResponseCaching.VaryHeaders, "should be equal")
+
+	calls := 0
+	gs.router.GET("/catalog", gs.CachedRoutes(0), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"calls": calls})
+	})
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		gs.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/catalog", nil))
+		return w
+	}
+
+	assert.Equal(t, "MISS", get().Header().Get(middleware.ResponseCacheHeader), "should be equal")
+	assert.Equal(t, "HIT", get().Header().Get(middleware.ResponseCacheHeader), "should be equal")
+	assert.Equal(t, 1, calls, "should be equal")
+
+	assert.Nil(t, gs.ResponseCache().InvalidatePrefix(context.Background(), "/catalog"), "must be nil")
+	assert.Equal(t, "MISS", get().Header().Get(middleware.ResponseCacheHeader), "should be invalidated")
+
+	gs = New("test")
+	gs.ResponseCacheSize = -1
+	assert.NotNil(t, gs.Configure(), "should be an error")
+}
@@ -0,0 +1,115 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DuplicateRoutesFail fails the startup on conflicting routes
+	DuplicateRoutesFail = "fail"
+	// DuplicateRoutesWarn logs the conflicting routes and skips the rest of
+	// the routes of their handler
+	DuplicateRoutesWarn = "warn"
+
+	routesPath = "/admin/routes"
+)
+
+// RouteInfo is a route of the server, served by /admin/routes
+type RouteInfo struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+// registerHandlers runs the handlers on the router, gin panics on the
+// routes registered twice or conflicting with a wildcard
+func (gs *ginService) registerHandlers(handlers []func(*gin.Engine)) error {
+	for _, hdl := range handlers {
+		err := addRoutes(gs.router, hdl)
+		if err == nil {
+			continue
+		}
+		if gs.DuplicateRoutes != DuplicateRoutesWarn {
+			return err
+		}
+		gs.logger.Warnf("%s, its next routes are skipped", err.Error())
+	}
+	return nil
+}
+
+func addRoutes(engine *gin.Engine, hdl func(*gin.Engine)) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		msg, ok := r.(string)
+		if !ok || !(strings.Contains(msg, "already registered") || strings.Contains(msg, "conflicts with")) {
+			panic(r)
+		}
+		err = fmt.Errorf("conflicting route added by %s: %s", shortFuncName(funcName(hdl)), msg)
+	}()
+
+	hdl(engine)
+	return nil
+}
+
+// Routes returns the routes of the server sorted by path and method
+func (gs *ginService) Routes() []RouteInfo {
+	if gs.router == nil {
+		return []RouteInfo{}
+	}
+
+	routes := make([]RouteInfo, 0, len(gs.router.Routes()))
+	for _, r := range gs.router.Routes() {
+		routes = append(routes, RouteInfo{Method: r.Method, Path: r.Path, Handler: shortFuncName(r.Handler)})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// logRoutes logs the table of the routes at debug level
+func (gs *ginService) logRoutes() {
+	routes := gs.Routes()
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, r := range routes {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Method, r.Path, r.Handler)
+	}
+	_ = w.Flush()
+
+	gs.logger.Debugf("%d routes:\n%s", len(routes), b.String())
+}
+
+func (gs *ginService) registerRoutesHandler(engine *gin.Engine) {
+	engine.GET(routesPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gs.Routes())
+	})
+}
+
+func funcName(fn interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
+
+// shortFuncName trims the import path of name, and the -fm suffix of
+// method values: github.com/org/app/api.(*Handler).List-fm => api.(*Handler).List
+func shortFuncName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func listItems(c *gin.Context) {}
+
+func registerItems(engine *gin.Engine) {
+	engine.GET("/items", listItems)
+	engine.GET("/items/:id", listItems)
+}
+
+func TestDuplicateRoutes(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.Config.Port = freePort(t)
+	gs.AddHandler(registerItems)
+	gs.AddHandler(func(engine *gin.Engine) {
+		engine.GET("/items", listItems)
+	})
+	err := gs.Run()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), ".TestDuplicateRoutes.func1:", "should name the handler")
+	assert.Contains(t, err.Error(), "already registered for path '/items'", "should be equal")
+
+	// a wildcard conflicting with another one
+	gs = New("test")
+	gs.AddHandler(registerItems)
+	gs.AddHandler(func(engine *gin.Engine) {
+		engine.DELETE("/items/:id", listItems)
+		engine.GET("/items/:name/tags", listItems)
+	})
+	assert.Nil(t, gs.Configure(), "must be nil")
+	err = gs.registerHandlers(gs.handlers)
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "conflicts with existing wildcard", "should be equal")
+
+	gs.DuplicateRoutes = DuplicateRoutesWarn
+	assert.Nil(t, gs.Configure(), "must be nil")
+	assert.Nil(t, gs.registerHandlers(gs.handlers), "must be nil")
+	paths := []string{}
+	for _, r := range gs.Routes() {
+		paths = append(paths, r.Method+" "+r.Path)
+	}
+	assert.Subset(t, paths, []string{"GET /items", "GET /items/:id", "DELETE /items/:id"}, "should keep the routes before the conflict")
+	assert.NotContains(t, paths, "GET /items/:name/tags", "should be skipped")
+
+	gs.DuplicateRoutes = "ignore"
+	assert.NotNil(t, gs.Configure(), "should be an error")
+}
+
+func TestRoutesHandler(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.HealthDisabled = true
+	gs.VersionDisabled = true
+	gs.MetricsDisabled = true
+	gs.OpsToken = testOpsToken
+	gs.AddHandler(registerItems)
+	assert.Nil(t, gs.Configure(), "must be nil")
+	assert.Nil(t, gs.registerRoutes(), "must be nil")
+
+	w := serveOps(gs, "/admin/routes", testOpsToken)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+
+	var routes []RouteInfo
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &routes), "must be nil")
+	routes = routes[len(routes)-3:]
+	assert.Equal(t, "/admin/routes", routes[0].Path, "should be sorted by path")
+	assert.Equal(t, RouteInfo{Method: http.MethodGet, Path: "/items", Handler: shortFuncName(funcName(listItems))}, routes[1], "should be equal")
+	assert.Equal(t, "/items/:id", routes[2].Path, "should be equal")
+
+	gs.RoutesDisabled = true
+	assert.Nil(t, gs.Configure(), "must be nil")
+	assert.Nil(t, gs.registerRoutes(), "must be nil")
+	assert.Equal(t, http.StatusNotFound, serveOps(gs, "/admin/routes", testOpsToken).Code, "should be equal")
+}
+
+func TestShortFuncName(t *testing.T) {
+	assert.Equal(t, "api.(*Handler).List", shortFuncName("github.com/org/app/api.(*Handler).List-fm"), "should be equal")
+	assert.Equal(t, "main.main.func1", shortFuncName("main.main.func1"), "should be equal")
+}
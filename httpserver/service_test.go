@@ -0,0 +1,75 @@
+package httpserver_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/sdktest"
+)
+
+func TestHealthz(t *testing.T) {
+	ts := sdktest.NewTestService(t)
+
+	w := ts.PerformRequest(http.MethodGet, "/healthz", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestReadyz(t *testing.T) {
+	ts := sdktest.NewTestService(t, sdktest.WithFlags(map[string]string{"gin-health-check-timeout": "50ms"}))
+	hs := ts.HTTPServer()
+	hs.AddHealthCheck("db", func(ctx context.Context) error { return nil })
+	hs.AddHealthCheck("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+	hs.AddHealthCheck("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	w := ts.PerformRequest(http.MethodGet, "/readyz", nil, nil)
+	assert.Less(t, time.Since(start), time.Second, "slow check must not hang the probe")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "should be equal")
+	assert.JSONEq(t, `{"status":"unavailable","failures":[
+		{"name":"redis","error":"connection refused"},
+		{"name":"slow","error":"context deadline exceeded"}
+	]}`, w.Body.String())
+}
+
+func TestReadyzAllHealthy(t *testing.T) {
+	ts := sdktest.NewTestService(t)
+	ts.HTTPServer().AddHealthCheck("db", func(ctx context.Context) error { return nil })
+
+	w := ts.PerformRequest(http.MethodGet, "/readyz", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+}
+
+func TestHealthDisabled(t *testing.T) {
+	ts := sdktest.NewTestService(t, sdktest.WithFlags(map[string]string{"gin-health-disabled": "true"}))
+
+	assert.Equal(t, http.StatusNotFound, ts.PerformRequest(http.MethodGet, "/healthz", nil, nil).Code)
+	assert.Equal(t, http.StatusNotFound, ts.PerformRequest(http.MethodGet, "/readyz", nil, nil).Code)
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	ts := sdktest.NewTestService(t)
+	ts.HTTPServer().AddHandler(func(engine *gin.Engine) {
+		engine.GET("/panic", func(c *gin.Context) { panic("boom") })
+	})
+
+	w := ts.PerformRequest(http.MethodGet, "/panic", nil, map[string]string{middleware.RequestIDHeader: "req-1"})
+	// the panic logger recovers inside the request id and the access log
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "should be equal")
+	assert.Equal(t, "req-1", w.Header().Get(middleware.RequestIDHeader), "should be equal")
+
+	entries := ts.Logs().Find("error", "GET /panic 500")
+	if assert.Len(t, entries, 1, "should be logged once") {
+		assert.Equal(t, "req-1", entries[0].Fields[middleware.RequestIDKey], "should have the request id")
+		assert.Equal(t, "/panic", entries[0].Fields["route"], "should be equal")
+	}
+}
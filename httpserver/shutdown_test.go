@@ -0,0 +1,204 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/sse"
+	"github.com/taimaifika/go-sdk/httpserver/websocket"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// startTestServer runs a server on a random port with a /slow route
+// which takes delay or until the request is cancelled
+func startTestServer(t *testing.T, config Config, delay time.Duration) *ginService {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.Config = config
+	gs.AddHandler(func(engine *gin.Engine) {
+		engine.GET("/slow", func(c *gin.Context) {
+			select {
+			case <-time.After(delay):
+				c.String(http.StatusOK, "done")
+			case <-c.Request.Context().Done():
+			}
+		})
+	})
+
+	go func() { _ = gs.Run() }()
+
+	assert.Eventually(t, func() bool { return gs.Port() != 0 }, time.Second, 10*time.Millisecond, "server should listen")
+	return gs
+}
+
+func TestStopDrains(t *testing.T) {
+	gs := startTestServer(t, Config{ShutdownTimeout: time.Second}, 200*time.Millisecond)
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/slow", gs.Port()))
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	assert.True(t, <-gs.Stop(), "should drain cleanly")
+	assert.Equal(t, http.StatusOK, <-status, "in-flight request should be served")
+}
+
+func TestStopForced(t *testing.T) {
+	gs := startTestServer(t, Config{ShutdownTimeout: 100 * time.Millisecond}, 2*time.Second)
+
+	requested := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/slow", gs.Port()))
+		if err == nil {
+			resp.Body.Close()
+		}
+		requested <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	assert.False(t, <-gs.Stop(), "should close connections after the timeout")
+	assert.Less(t, time.Since(start), time.Second, "should not wait for the slow request")
+	assert.NotNil(t, <-requested, "should be an error")
+}
+
+func TestStopDelayFailsReadiness(t *testing.T) {
+	gs := startTestServer(t, Config{ShutdownDelay: 300 * time.Millisecond}, 0)
+	url := fmt.Sprintf("http://localhost:%d/readyz", gs.Port())
+
+	resp, err := http.Get(url)
+	assert.Nil(t, err, "must be nil")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "should be equal")
+
+	stopped := gs.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err = http.Get(url)
+	assert.Nil(t, err, "must be nil")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "should fail while draining")
+
+	assert.True(t, <-stopped, "should drain cleanly")
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	gs := startTestServer(t, Config{ReadHeaderTimeout: 100 * time.Millisecond}, 0)
+	defer func() { <-gs.Stop() }()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", gs.Port()))
+	assert.Nil(t, err, "must be nil")
+	defer conn.Close()
+
+	// headers are never finished
+	_, err = conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost\r\n"))
+	assert.Nil(t, err, "must be nil")
+
+	start := time.Now()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = io.ReadAll(conn)
+	assert.Nil(t, err, "connection should be closed by the server")
+	assert.Less(t, time.Since(start), time.Second, "should be disconnected after the read header timeout")
+}
+
+func TestServerTimeoutsConfig(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.Config = Config{ReadTimeout: time.Second, WriteTimeout: 2 * time.Second, IdleTimeout: 3 * time.Second, MaxHeaderBytes: 4096}
+	assert.Nil(t, gs.Configure(), "must be nil")
+
+	assert.Equal(t, time.Second, gs.svr.ReadTimeout, "should be equal")
+	assert.Equal(t, 2*time.Second, gs.svr.WriteTimeout, "should be equal")
+	assert.Equal(t, 3*time.Second, gs.svr.IdleTimeout, "should be equal")
+	assert.Equal(t, 4096, gs.svr.MaxHeaderBytes, "should be equal")
+	assert.Equal(t, time.Duration(0), gs.svr.keepAlivePeriod, "keep-alive should be disabled")
+	assert.Equal(t, 4096, gs.GetConfig().MaxHeaderBytes, "should be equal")
+}
+
+func TestStopClosesWebsockets(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.Config = Config{RequestTimeout: 50 * time.Millisecond, ReadTimeout: 100 * time.Millisecond, ShutdownTimeout: time.Second}
+	gs.Websocket = websocket.Config{PingInterval: 20 * time.Millisecond, PongTimeout: time.Second}
+	returned := make(chan struct{})
+	gs.AddWebsocketHandler("/ws", func(ctx context.Context, conn *websocket.Conn) {
+		defer close(returned)
+		for {
+			_, msg, err := conn.ReadMessage(ctx)
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(websocket.TextMessage, msg)
+		}
+	})
+
+	go func() { _ = gs.Run() }()
+	<-gs.Listening()
+
+	client, _, err := gorilla.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d/ws", gs.Port()), nil)
+	assert.Nil(t, err, "must be nil")
+	defer client.Close()
+
+	// longer than the request and read timeouts
+	time.Sleep(200 * time.Millisecond)
+	assert.Nil(t, client.WriteMessage(gorilla.TextMessage, []byte("hello")), "must be nil")
+	_, msg, err := client.ReadMessage()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "hello", string(msg), "should be equal")
+
+	closeErr := make(chan error, 1)
+	go func() {
+		_, _, err := client.ReadMessage()
+		closeErr <- err
+	}()
+
+	assert.True(t, <-gs.Stop(), "should drain cleanly")
+	<-returned
+	assert.True(t, gorilla.IsCloseError(<-closeErr, gorilla.CloseGoingAway), "should receive going away")
+}
+
+func TestStopEndsStreams(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.Config = Config{ShutdownTimeout: time.Second}
+	gs.AddHandler(func(engine *gin.Engine) {
+		engine.GET("/events", func(c *gin.Context) {
+			stream := sse.NewStream(c, sse.DefaultConfig())
+			defer stream.Close()
+			<-stream.Done()
+		})
+	})
+
+	go func() { _ = gs.Run() }()
+	<-gs.Listening()
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/events", gs.Port()))
+	assert.Nil(t, err, "must be nil")
+	defer resp.Body.Close()
+
+	start := time.Now()
+	assert.True(t, <-gs.Stop(), "should drain cleanly")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "should not wait for the shutdown timeout")
+
+	_, err = io.ReadAll(resp.Body)
+	assert.Nil(t, err, "stream should end")
+}
@@ -0,0 +1,118 @@
+package sse
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSlowClient is returned by Stream.Forward when the subscription is dropped
+var ErrSlowClient = errors.New("sse client is too slow, its subscription is dropped")
+
+var defaultBufferSize = 16
+
+// Broker publishes events to the subscribers of a topic. Publishing never
+// blocks: a subscriber whose buffer is full is dropped, its events channel
+// is closed and the client reconnects with Last-Event-ID.
+type Broker struct {
+	bufferSize int
+
+	mu     sync.Mutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+// NewBroker buffers bufferSize events for each subscriber, 0 => 16
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	return &Broker{
+		bufferSize: bufferSize,
+		topics:     map[string]map[*Subscription]struct{}{},
+	}
+}
+
+type Subscription struct {
+	broker *Broker
+	topic  string
+	events chan Event
+	// set with the broker lock held
+	dropped bool
+	closed  bool
+}
+
+func (b *Broker) Subscribe(topic string) *Subscription {
+	sub := &Subscription{
+		broker: b,
+		topic:  topic,
+		events: make(chan Event, b.bufferSize),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.topics[topic] == nil {
+		b.topics[topic] = map[*Subscription]struct{}{}
+	}
+	b.topics[topic][sub] = struct{}{}
+	return sub
+}
+
+// Publish sends e to the subscribers of topic and returns how many got it
+func (b *Broker) Publish(topic string, e Event) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sent := 0
+	for sub := range b.topics[topic] {
+		select {
+		case sub.events <- e:
+			sent++
+		default:
+			sub.dropped = true
+			b.removeLocked(sub)
+		}
+	}
+	return sent
+}
+
+// Subscribers is the number of subscribers of topic
+func (b *Broker) Subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.topics[topic])
+}
+
+// removeLocked must be called with mu held
+func (b *Broker) removeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.events)
+
+	delete(b.topics[sub.topic], sub)
+	if len(b.topics[sub.topic]) == 0 {
+		delete(b.topics, sub.topic)
+	}
+}
+
+// Events is closed when the subscription is closed or dropped
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped reports whether the subscriber didn't keep up with the events
+func (s *Subscription) Dropped() bool {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	return s.dropped
+}
+
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	s.broker.removeLocked(s)
+}
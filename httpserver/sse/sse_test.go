@@ -0,0 +1,194 @@
+package sse
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+// newTestServer serves handler on /events with a request timeout shorter than the tests
+func newTestServer(t *testing.T, shuttingDown <-chan struct{}, handler gin.HandlerFunc) string {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(middleware.ShutdownSignal(shuttingDown))
+	engine.Use(middleware.Timeout(50 * time.Millisecond))
+	engine.GET("/events", handler)
+
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return server.URL + "/events"
+}
+
+// readEvent reads the lines until the next blank line
+func readEvent(r *bufio.Reader) (string, error) {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == "\n" {
+			return strings.Join(lines, ""), nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestStream(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Heartbeat = 30 * time.Millisecond
+	cfg.Retry = 2 * time.Second
+
+	url := newTestServer(t, nil, func(c *gin.Context) {
+		stream := NewStream(c, cfg)
+		defer stream.Close()
+
+		// longer than the request timeout and the heartbeat
+		time.Sleep(100 * time.Millisecond)
+		assert.Nil(t, stream.Send("progress", "1", map[string]int{"percent": 50}), "must be nil")
+		assert.Nil(t, stream.Send("", "", "line 1\nline 2"), "must be nil")
+		assert.Equal(t, ErrInvalidField, stream.Send("bad\nevent", "", "x"), "should be equal")
+	})
+
+	resp, err := http.Get(url)
+	assert.Nil(t, err, "must be nil")
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "should be equal")
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"), "should be equal")
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"), "should be equal")
+
+	r := bufio.NewReader(resp.Body)
+	event, err := readEvent(r)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "retry: 2000\n", event, "should be equal")
+
+	heartbeats := 0
+	for {
+		event, err = readEvent(r)
+		assert.Nil(t, err, "must be nil")
+		if event != ":\n" {
+			break
+		}
+		heartbeats++
+	}
+	assert.GreaterOrEqual(t, heartbeats, 1, "should send heartbeats")
+	assert.Equal(t, "id: 1\nevent: progress\ndata: {\"percent\":50}\n", event, "should be equal")
+
+	event, err = readEvent(r)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "data: line 1\ndata: line 2\n", event, "should be equal")
+}
+
+func TestStreamDone(t *testing.T) {
+	shuttingDown := make(chan struct{})
+	ended := make(chan error, 2)
+
+	url := newTestServer(t, shuttingDown, func(c *gin.Context) {
+		stream := NewStream(c, DefaultConfig())
+		defer stream.Close()
+
+		<-stream.Done()
+		ended <- stream.Send("late", "", "x")
+	})
+
+	// the client disconnects
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err, "must be nil")
+	resp.Body.Close()
+
+	select {
+	case err := <-ended:
+		assert.Equal(t, ErrStreamClosed, err, "should be equal")
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream should end when the client disconnects")
+	}
+
+	// the server shuts down
+	resp, err = http.Get(url)
+	assert.Nil(t, err, "must be nil")
+	defer resp.Body.Close()
+	close(shuttingDown)
+
+	select {
+	case err := <-ended:
+		assert.Equal(t, ErrStreamClosed, err, "should be equal")
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream should end when the server shuts down")
+	}
+}
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker(2)
+
+	fast := broker.Subscribe("news")
+	slow := broker.Subscribe("news")
+	other := broker.Subscribe("sports")
+	defer other.Close()
+	assert.Equal(t, 2, broker.Subscribers("news"), "should be equal")
+
+	assert.Equal(t, 2, broker.Publish("news", Event{Data: "1"}), "should be equal")
+	<-fast.Events()
+	assert.Equal(t, 2, broker.Publish("news", Event{Data: "2"}), "should be equal")
+	<-fast.Events()
+
+	// the buffer of slow is full, it's dropped without blocking
+	assert.Equal(t, 1, broker.Publish("news", Event{Data: "3"}), "should be equal")
+	assert.True(t, slow.Dropped(), "should be dropped")
+	assert.False(t, fast.Dropped(), "should not be dropped")
+	assert.Equal(t, 1, broker.Subscribers("news"), "should be equal")
+
+	// the buffered events are still delivered before the channel is closed
+	var received []interface{}
+	for e := range slow.Events() {
+		received = append(received, e.Data)
+	}
+	assert.Equal(t, []interface{}{"1", "2"}, received, "should be equal")
+
+	fast.Close()
+	fast.Close()
+	assert.Equal(t, 0, broker.Subscribers("news"), "should be equal")
+	assert.Equal(t, 1, broker.Subscribers("sports"), "should be equal")
+}
+
+func TestForward(t *testing.T) {
+	broker := NewBroker(4)
+	forwarded := make(chan error, 1)
+
+	url := newTestServer(t, nil, func(c *gin.Context) {
+		stream := NewStream(c, DefaultConfig())
+		defer stream.Close()
+
+		sub := broker.Subscribe("job:1")
+		defer sub.Close()
+		forwarded <- stream.Forward(sub)
+	})
+
+	resp, err := http.Get(url)
+	assert.Nil(t, err, "must be nil")
+	defer resp.Body.Close()
+
+	assert.Eventually(t, func() bool { return broker.Subscribers("job:1") == 1 }, time.Second, 10*time.Millisecond)
+	broker.Publish("job:1", Event{Event: "progress", Data: 100})
+
+	event, err := readEvent(bufio.NewReader(resp.Body))
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "event: progress\ndata: 100\n", event, "should be equal")
+
+	resp.Body.Close()
+	select {
+	case err := <-forwarded:
+		assert.Nil(t, err, "must be nil")
+	case <-time.After(2 * time.Second):
+		t.Fatal("forward should return when the client disconnects")
+	}
+}
@@ -0,0 +1,274 @@
+// Package sse streams server-sent events from gin handlers:
+//
+//	engine.GET("/jobs/:id/progress", func(c *gin.Context) {
+//		stream := sse.NewStream(c, sse.DefaultConfig())
+//		defer stream.Close()
+//
+//		sub := broker.Subscribe("job:" + c.Param("id"))
+//		defer sub.Close()
+//		_ = stream.Forward(sub)
+//	})
+package sse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+var (
+	defaultHeartbeat    = 15 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+)
+
+var (
+	// ErrStreamClosed is returned by Send after the client disconnected,
+	// the server started shutting down or Close is called
+	ErrStreamClosed = errors.New("sse stream is closed")
+	// ErrInvalidField means the event name or id contains a line break
+	ErrInvalidField = errors.New("sse event name and id must be single line")
+)
+
+type Config struct {
+	// interval of comment lines keeping proxies from closing idle streams, 0 => disabled
+	Heartbeat time.Duration `json:"heartbeat"`
+	// max time to write an event, the server write timeout doesn't apply to streams
+	WriteTimeout time.Duration `json:"write_timeout"`
+	// reconnection delay of the clients, 0 => browser default
+	Retry time.Duration `json:"retry"`
+}
+
+// DefaultConfig sends a heartbeat every 15s
+func DefaultConfig() Config {
+	return Config{
+		Heartbeat:    defaultHeartbeat,
+		WriteTimeout: defaultWriteTimeout,
+	}
+}
+
+// Event is a server-sent event, Data is sent as is when it's a string
+// or []byte and as JSON otherwise
+type Event struct {
+	Event string
+	ID    string
+	Data  interface{}
+}
+
+// Stream writes events to the response of a gin handler, it's done when the
+// client disconnects or the server starts shutting down. Send is safe from
+// several goroutines, Close must be called before the handler returns.
+type Stream struct {
+	cfg Config
+	w   gin.ResponseWriter
+	rc  *http.ResponseController
+
+	mu     sync.Mutex
+	closed bool
+
+	done     chan struct{}
+	doneOnce sync.Once
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewStream sends the event stream headers right away, the request timeout
+// doesn't apply to the stream
+func NewStream(c *gin.Context, cfg Config) *Stream {
+	middleware.DisableTimeout(c)
+
+	s := &Stream{
+		cfg:  cfg,
+		w:    c.Writer,
+		rc:   http.NewResponseController(c.Writer),
+		done: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// nginx buffers responses by default
+	header.Set("X-Accel-Buffering", "no")
+	c.Writer.WriteHeader(http.StatusOK)
+
+	var prelude []byte
+	if cfg.Retry > 0 {
+		prelude = []byte("retry: " + strconv.FormatInt(cfg.Retry.Milliseconds(), 10) + "\n\n")
+	}
+	s.mu.Lock()
+	_ = s.write(prelude)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.watch(c.Request.Context(), middleware.ShuttingDown(c))
+	return s
+}
+
+// watch sends the heartbeats until the stream is done
+func (s *Stream) watch(ctx context.Context, shuttingDown <-chan struct{}) {
+	defer s.wg.Done()
+
+	var heartbeat <-chan time.Time
+	if s.cfg.Heartbeat > 0 {
+		ticker := time.NewTicker(s.cfg.Heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-heartbeat:
+			s.mu.Lock()
+			err := s.write([]byte(":\n\n"))
+			s.mu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-ctx.Done():
+			s.finish()
+			return
+		case <-shuttingDown:
+			s.finish()
+			return
+		case <-s.done:
+			return
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Done is closed when the client disconnects or the server starts shutting down
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Stream) finish() {
+	s.doneOnce.Do(func() { close(s.done) })
+}
+
+// Send writes an event and flushes it
+func (s *Stream) Send(event, id string, data interface{}) error {
+	return s.SendEvent(Event{Event: event, ID: id, Data: data})
+}
+
+func (s *Stream) SendEvent(e Event) error {
+	msg, err := encode(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(msg)
+}
+
+// write must be called with mu held, a failed write ends the stream
+func (s *Stream) write(msg []byte) error {
+	if s.closed {
+		return ErrStreamClosed
+	}
+	select {
+	case <-s.done:
+		return ErrStreamClosed
+	default:
+	}
+
+	timeout := s.cfg.WriteTimeout
+	if timeout <= 0 {
+		timeout = defaultWriteTimeout
+	}
+	// not supported by every writer, e.g. the recorder of tests
+	_ = s.rc.SetWriteDeadline(time.Now().Add(timeout))
+
+	if _, err := s.w.Write(msg); err != nil {
+		s.finish()
+		return err
+	}
+	s.w.Flush()
+	return nil
+}
+
+// Forward sends the events of sub until the stream is done. It returns
+// ErrSlowClient if sub is dropped because the client didn't keep up.
+func (s *Stream) Forward(sub *Subscription) error {
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				if sub.Dropped() {
+					return ErrSlowClient
+				}
+				return nil
+			}
+			if err := s.SendEvent(e); err != nil {
+				return err
+			}
+		case <-s.done:
+			return nil
+		}
+	}
+}
+
+// Close stops the heartbeats, the response ends when the handler returns
+func (s *Stream) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func encode(e Event) ([]byte, error) {
+	if strings.ContainsAny(e.Event, "\r\n") || strings.ContainsAny(e.ID, "\r\n") {
+		return nil, ErrInvalidField
+	}
+
+	var data []byte
+	switch v := e.Data.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if e.ID != "" {
+		buf.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		buf.WriteString("event: " + e.Event + "\n")
+	}
+	// every line of the data is a data field
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
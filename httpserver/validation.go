@@ -0,0 +1,50 @@
+package httpserver
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+var setupValidatorOnce sync.Once
+
+// validate returns the validator of gin binding. Once, fields are named by their
+// JSON tag in errors and the "uid" and "uid_required" rules are registered.
+func validate() (*validator.Validate, error) {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil, errors.New("gin binding validator is not validator/v10")
+	}
+
+	setupValidatorOnce.Do(func() {
+		v.RegisterTagNameFunc(sdkcm.JSONTagName)
+		_ = v.RegisterValidation("uid", sdkcm.ValidateUID)
+		_ = v.RegisterValidation("uid_required", sdkcm.ValidateUIDRequired)
+	})
+	return v, nil
+}
+
+// RegisterValidation adds a rule to the binding tags, call it before Run:
+//
+//	gs.RegisterValidation("sku", func(fl validator.FieldLevel) bool { ... })
+func (gs *ginService) RegisterValidation(tag string, fn validator.Func) error {
+	v, err := validate()
+	if err != nil {
+		return err
+	}
+	return v.RegisterValidation(tag, fn)
+}
+
+// RegisterStructValidation adds a validation of whole structs of the types,
+// e.g. fields depending on each other. Call it before Run.
+func (gs *ginService) RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) error {
+	v, err := validate()
+	if err != nil {
+		return err
+	}
+	v.RegisterStructValidation(fn, types...)
+	return nil
+}
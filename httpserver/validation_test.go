@@ -0,0 +1,94 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+type dateRange struct {
+	SKU  string `json:"sku" binding:"sku"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+func TestRegisterValidation(t *testing.T) {
+	gs := New("gin")
+	assert.Nil(t, gs.RegisterValidation("sku", func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "SKU-")
+	}), "must be nil")
+	assert.Nil(t, gs.RegisterStructValidation(func(sl validator.StructLevel) {
+		if r := sl.Current().Interface().(dateRange); r.To < r.From {
+			sl.ReportError(r.To, "to", "To", "gtefield", "from")
+		}
+	}, dateRange{}), "must be nil")
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/", func(c *gin.Context) {
+		var req dateRange
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	for body, fields := range map[string][]sdkcm.FieldError{
+		`{"sku":"SKU-1","from":1,"to":2}`: nil,
+		`{"sku":"1","from":1,"to":2}`:     {{Field: "sku", Rule: "sku", Message: "must satisfy sku"}},
+		`{"sku":"SKU-1","from":2,"to":1}`: {{Field: "to", Rule: "gtefield", Param: "from", Message: "must satisfy gtefield=from"}},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if fields == nil {
+			assert.Equal(t, http.StatusNoContent, w.Code, "should be equal")
+			continue
+		}
+		assert.Equal(t, http.StatusBadRequest, w.Code, "should be equal")
+		assert.Contains(t, w.Body.String(), `"rule":"`+fields[0].Rule+`"`, "should be equal")
+		assert.Contains(t, w.Body.String(), `"field":"`+fields[0].Field+`"`, "should be equal")
+	}
+}
+
+func TestUIDRequiredBinding(t *testing.T) {
+	_, err := validate()
+	assert.Nil(t, err, "must be nil")
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/", func(c *gin.Context) {
+		var req struct {
+			ID sdkcm.UID `json:"id" binding:"uid_required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	for body, expect := range map[string]string{
+		`{"id":"` + sdkcm.NewUID(1, 1, 1).String() + `"}`: "",
+		`{}`:          `"rule":"uid_required"`,
+		`{"id":""}`:   `"rule":"uid_required"`,
+		`{"id":null}`: `"rule":"uid_required"`,
+		`{"id":"0l"}`: `"code":"invalid_uid"`,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if expect == "" {
+			assert.Equal(t, http.StatusNoContent, w.Code, "should be equal")
+			continue
+		}
+		assert.Equal(t, http.StatusBadRequest, w.Code, "should be equal: %s", body)
+		assert.Contains(t, w.Body.String(), expect, "should be equal: %s", body)
+	}
+}
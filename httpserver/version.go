@@ -0,0 +1,44 @@
+package httpserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BuildInfo is served by the /version endpoint
+type BuildInfo struct {
+	Name      string    `json:"name"`
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	BuildTime string    `json:"build_time"`
+	GoVersion string    `json:"go_version"`
+	StartTime time.Time `json:"-"`
+}
+
+// SetBuildInfo sets the info served by /version
+func (gs *ginService) SetBuildInfo(info BuildInfo) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.buildInfo = info
+}
+
+func (gs *ginService) registerVersionHandler(engine *gin.Engine) {
+	engine.GET("/version", gs.versionHandler)
+}
+
+func (gs *ginService) versionHandler(c *gin.Context) {
+	gs.mu.Lock()
+	info := gs.buildInfo
+	gs.mu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":       info.Name,
+		"version":    info.Version,
+		"commit":     info.Commit,
+		"build_time": info.BuildTime,
+		"go_version": info.GoVersion,
+		"uptime":     time.Since(info.StartTime).Round(time.Second).String(),
+	})
+}
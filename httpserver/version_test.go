@@ -0,0 +1,44 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersion(t *testing.T) {
+	gs := newTestGinService(t, Config{}, func(gs *ginService) {
+		gs.SetBuildInfo(BuildInfo{
+			Name:      "orders",
+			Version:   "1.2.3",
+			Commit:    "abc123",
+			BuildTime: "2024-01-01T00:00:00Z",
+			GoVersion: "go1.23",
+			StartTime: time.Now().Add(-time.Minute),
+		})
+	})
+
+	w := performRequest(gs, http.MethodGet, "/version")
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+
+	var body map[string]string
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body), "must be nil")
+	assert.Equal(t, map[string]string{
+		"name":       "orders",
+		"version":    "1.2.3",
+		"commit":     "abc123",
+		"build_time": "2024-01-01T00:00:00Z",
+		"go_version": "go1.23",
+		"uptime":     "1m0s",
+	}, body)
+}
+
+func TestVersionDisabled(t *testing.T) {
+	gs := newTestGinService(t, Config{VersionDisabled: true})
+
+	w := performRequest(gs, http.MethodGet, "/version")
+	assert.Equal(t, http.StatusNotFound, w.Code, "should be equal")
+}
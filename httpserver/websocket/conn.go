@@ -0,0 +1,225 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// message types of ReadMessage and WriteMessage
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+)
+
+// close codes of Close
+const (
+	CloseNormalClosure   = websocket.CloseNormalClosure
+	CloseGoingAway       = websocket.CloseGoingAway
+	ClosePolicyViolation = websocket.ClosePolicyViolation
+	CloseInternalErr     = websocket.CloseInternalServerErr
+)
+
+// ErrClosed is returned by writes after the connection is closed
+var ErrClosed = errors.New("websocket connection is closed")
+
+// max time to wait for the close frame of the peer
+const closeGracePeriod = time.Second
+
+type message struct {
+	messageType int
+	data        []byte
+}
+
+// Conn is an upgraded connection. Messages are read in the background,
+// so pings and close frames are handled even when the handler only writes.
+// Writes are safe from several goroutines.
+type Conn struct {
+	ws      *websocket.Conn
+	request *http.Request
+	cfg     Config
+
+	messages chan message
+	// closed when the read loop stops
+	done    chan struct{}
+	readErr error
+	// closed by Close, messages are dropped until the peer answers
+	closing chan struct{}
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closeSent bool
+}
+
+func newConn(ws *websocket.Conn, r *http.Request, cfg Config) *Conn {
+	conn := &Conn{
+		ws:       ws,
+		request:  r,
+		cfg:      cfg,
+		messages: make(chan message),
+		done:     make(chan struct{}),
+		closing:  make(chan struct{}),
+	}
+
+	if cfg.MaxMessageSize > 0 {
+		ws.SetReadLimit(cfg.MaxMessageSize)
+	}
+	// the deadline of the http server read timeout is still set
+	var deadline time.Time
+	if cfg.PongTimeout > 0 {
+		deadline = time.Now().Add(cfg.PongTimeout)
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(time.Now().Add(cfg.PongTimeout))
+		})
+	}
+	_ = ws.SetReadDeadline(deadline)
+	return conn
+}
+
+// Request is the upgraded http request, for its headers and query
+func (c *Conn) Request() *http.Request {
+	return c.request
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+// Done is closed once the connection can't be read anymore,
+// the peer closed it or it timed out
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// readLoop delivers the messages until the connection fails.
+// A handler not reading for longer than the pong timeout is disconnected.
+func (c *Conn) readLoop() {
+	defer close(c.done)
+
+	for {
+		messageType, data, err := c.ws.ReadMessage()
+		if err != nil {
+			c.readErr = err
+			return
+		}
+
+		select {
+		case c.messages <- message{messageType: messageType, data: data}:
+		case <-c.closing:
+		}
+	}
+}
+
+// pingLoop sends pings every ping interval until the connection is done
+func (c *Conn) pingLoop() {
+	if c.cfg.PingInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.writeTimeout())); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// ReadMessage waits for the next message, the error is a *CloseError
+// when the peer closed the connection
+func (c *Conn) ReadMessage(ctx context.Context) (int, []byte, error) {
+	select {
+	case msg := <-c.messages:
+		return msg.messageType, msg.data, nil
+	case <-c.done:
+		return 0, nil, c.readErr
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+// ReadJSON reads the next message into v
+func (c *Conn) ReadJSON(ctx context.Context, v interface{}) error {
+	_, data, err := c.ReadMessage(ctx)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends a message, it fails if it isn't written within the write timeout
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	_ = c.ws.SetWriteDeadline(time.Now().Add(c.writeTimeout()))
+	return c.ws.WriteMessage(messageType, data)
+}
+
+// WriteJSON sends v as a text message
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Close sends a close frame with code and reason, then waits
+// a moment for the peer to answer before closing the connection
+func (c *Conn) Close(code int, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		c.sendClose(code, reason)
+
+		select {
+		case <-c.done:
+		case <-time.After(closeGracePeriod):
+		}
+		err = c.ws.Close()
+	})
+	return err
+}
+
+// sendClose starts the close handshake, the read loop stops once the peer answers
+func (c *Conn) sendClose(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return
+	}
+	c.closeSent = true
+
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(c.writeTimeout()))
+}
+
+func (c *Conn) writeTimeout() time.Duration {
+	if c.cfg.WriteTimeout > 0 {
+		return c.cfg.WriteTimeout
+	}
+	return defaultWriteTimeout
+}
+
+// IsCloseError reports whether err is a close frame with one of codes
+func IsCloseError(err error, codes ...int) bool {
+	return websocket.IsCloseError(err, codes...)
+}
@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Hub broadcasts messages to the connections subscribed to a topic,
+// connections are unsubscribed when they are done:
+//
+//	hub := websocket.NewHub()
+//	engine.GET("/ws/orders", ws.Handler(func(ctx context.Context, conn *websocket.Conn) {
+//		hub.Subscribe("order:"+conn.Request().URL.Query().Get("id"), conn)
+//		<-ctx.Done()
+//	}))
+//	...
+//	hub.BroadcastJSON("order:42", event)
+type Hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Conn]struct{}
+	// topics of each connection
+	conns map[*Conn]map[string]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		topics: map[string]map[*Conn]struct{}{},
+		conns:  map[*Conn]map[string]struct{}{},
+	}
+}
+
+func (h *Hub) Subscribe(topic string, conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.topics[topic] == nil {
+		h.topics[topic] = map[*Conn]struct{}{}
+	}
+	h.topics[topic][conn] = struct{}{}
+
+	if h.conns[conn] == nil {
+		h.conns[conn] = map[string]struct{}{}
+		go func() {
+			<-conn.Done()
+			h.remove(conn)
+		}()
+	}
+	h.conns[conn][topic] = struct{}{}
+}
+
+func (h *Hub) Unsubscribe(topic string, conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.topics[topic], conn)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+	delete(h.conns[conn], topic)
+}
+
+// remove unsubscribes conn from all its topics
+func (h *Hub) remove(conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for topic := range h.conns[conn] {
+		delete(h.topics[topic], conn)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	delete(h.conns, conn)
+}
+
+// Subscribers is the number of connections subscribed to topic
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.topics[topic])
+}
+
+// Broadcast sends the message to the subscribers of topic and returns how many
+// got it. The subscribers are written concurrently, each within the write timeout.
+func (h *Hub) Broadcast(topic string, messageType int, data []byte) int {
+	h.mu.RLock()
+	conns := make([]*Conn, 0, len(h.topics[topic]))
+	for conn := range h.topics[topic] {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		sent int
+	)
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn *Conn) {
+			defer wg.Done()
+
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				return
+			}
+			mu.Lock()
+			sent++
+			mu.Unlock()
+		}(conn)
+	}
+	wg.Wait()
+
+	return sent
+}
+
+// BroadcastJSON sends v as a text message to the subscribers of topic
+func (h *Hub) BroadcastJSON(topic string, v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	return h.Broadcast(topic, TextMessage, data), nil
+}
@@ -0,0 +1,310 @@
+// Package websocket upgrades gin requests to websocket connections with
+// keepalive, and tracks them so they are closed cleanly when the server stops:
+//
+//	ws := websocket.NewServer(websocket.Config{AllowedOrigins: []string{"https://app.example.com"}})
+//	engine.GET("/ws", ws.Handler(func(ctx context.Context, conn *websocket.Conn) {
+//		for {
+//			_, msg, err := conn.ReadMessage(ctx)
+//			if err != nil {
+//				return
+//			}
+//			_ = conn.WriteMessage(websocket.TextMessage, msg)
+//		}
+//	}))
+//	...
+//	_ = ws.Shutdown(ctx)
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+var (
+	defaultHandshakeTimeout = 10 * time.Second
+	defaultWriteTimeout     = 10 * time.Second
+	defaultPingInterval     = 30 * time.Second
+	defaultPongTimeout      = 60 * time.Second
+	defaultMaxMessageSize   = int64(1 << 20)
+)
+
+// reason of the close frame sent by Shutdown
+const shutdownReason = "server is shutting down"
+
+// set by Track, true once Handler counted the request as running
+const trackedKey = "websocket_tracked"
+
+type Config struct {
+	// origins allowed to connect, "*" allows any origin and
+	// "https://*.example.com" any subdomain. Empty => same origin only
+	AllowedOrigins   []string      `json:"allowed_origins"`
+	HandshakeTimeout time.Duration `json:"handshake_timeout"`
+	// max time to write a message
+	WriteTimeout time.Duration `json:"write_timeout"`
+	// a ping is sent every interval, 0 => no ping
+	PingInterval time.Duration `json:"ping_interval"`
+	// the connection is closed when nothing, not even a pong,
+	// is received for this long. 0 => no timeout
+	PongTimeout time.Duration `json:"pong_timeout"`
+	// larger messages close the connection, 0 => no limit
+	MaxMessageSize int64 `json:"max_message_size"`
+}
+
+// DefaultConfig pings every 30s and closes connections silent for 60s
+func DefaultConfig() Config {
+	return Config{
+		HandshakeTimeout: defaultHandshakeTimeout,
+		WriteTimeout:     defaultWriteTimeout,
+		PingInterval:     defaultPingInterval,
+		PongTimeout:      defaultPongTimeout,
+		MaxMessageSize:   defaultMaxMessageSize,
+	}
+}
+
+func (cfg Config) Validate() error {
+	if cfg.PongTimeout > 0 && cfg.PingInterval >= cfg.PongTimeout {
+		return errors.New("websocket: ping interval must be shorter than the pong timeout")
+	}
+	if cfg.PongTimeout > 0 && cfg.PingInterval <= 0 {
+		return errors.New("websocket: pong timeout requires a ping interval")
+	}
+	if cfg.MaxMessageSize < 0 {
+		return errors.New("websocket: max message size must not be negative")
+	}
+	return nil
+}
+
+// Handler serves an upgraded connection, ctx is cancelled when the peer
+// disconnects or the server shuts down. The connection is closed when it returns.
+type Handler func(ctx context.Context, conn *Conn)
+
+// Server upgrades requests and tracks the connections until they are closed
+type Server struct {
+	cfg      Config
+	upgrader websocket.Upgrader
+	logger   logger.Logger
+
+	mu      sync.Mutex
+	conns   map[*Conn]context.CancelFunc
+	closed  bool
+	running sync.WaitGroup
+}
+
+func NewServer(cfg Config) *Server {
+	s := &Server{
+		cfg:    cfg,
+		logger: logger.GetCurrent().GetLogger("websocket"),
+		conns:  map[*Conn]context.CancelFunc{},
+	}
+
+	s.upgrader = websocket.Upgrader{
+		HandshakeTimeout: cfg.HandshakeTimeout,
+		CheckOrigin:      originChecker(cfg.AllowedOrigins),
+		Error: func(w http.ResponseWriter, _ *http.Request, status int, reason error) {
+			http.Error(w, reason.Error(), status)
+		},
+	}
+	return s
+}
+
+// Handler upgrades the request and runs handler. The request timeout doesn't
+// apply to the connection, and requests which aren't upgrades get a 400.
+func (s *Server) Handler(handler Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// the timeout middleware would write a 503 over the upgraded connection
+		middleware.DisableTimeout(c)
+
+		s.mu.Lock()
+		closed := s.closed
+		if !closed {
+			s.running.Add(1)
+		}
+		s.mu.Unlock()
+
+		if closed {
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		if _, ok := c.Get(trackedKey); ok {
+			c.Set(trackedKey, true)
+		} else {
+			defer s.running.Done()
+		}
+
+		ws, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// the upgrader already responded
+			s.logger.Debugf("websocket upgrade of %s failed: %v", c.Request.URL.Path, err)
+			c.Abort()
+			return
+		}
+
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		conn := newConn(ws, c.Request, s.cfg)
+		if !s.track(conn, cancel) {
+			// shut down during the upgrade
+			conn.sendClose(CloseGoingAway, shutdownReason)
+			cancel()
+		}
+		defer s.untrack(conn)
+
+		go conn.readLoop()
+		go conn.pingLoop()
+		go func() {
+			select {
+			case <-conn.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		s.serve(ctx, conn, handler)
+		cancel()
+		_ = conn.Close(CloseNormalClosure, "")
+	}
+}
+
+// Track is a router-level middleware making Shutdown wait for the whole
+// upgraded request, it must come before the access log, the metrics and the
+// other middlewares still running on the hijacked connection after Handler.
+func (s *Server) Track() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(trackedKey, false)
+		defer func() {
+			if c.GetBool(trackedKey) {
+				s.running.Done()
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+// serve runs handler, a panic closes the connection with an internal error
+func (s *Server) serve(ctx context.Context, conn *Conn, handler Handler) {
+	defer func() {
+		if p := recover(); p != nil {
+			s.logger.Errorf("websocket handler of %s panicked: %v", conn.Request().URL.Path, p)
+			_ = conn.Close(CloseInternalErr, "")
+		}
+	}()
+
+	handler(ctx, conn)
+}
+
+// track reports false when the server is shut down
+func (s *Server) track(conn *Conn, cancel context.CancelFunc) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conns[conn] = cancel
+	return !s.closed
+}
+
+func (s *Server) untrack(conn *Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+}
+
+// Len is the number of open connections
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+// Shutdown rejects new connections, sends a going away close frame to the open
+// ones and cancels their handlers, then waits for the handlers to return,
+// or for the whole requests with Track.
+// When ctx is done first, the connections are closed and ctx error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	conns := make(map[*Conn]context.CancelFunc, len(s.conns))
+	for conn, cancel := range s.conns {
+		conns[conn] = cancel
+	}
+	s.mu.Unlock()
+
+	for conn, cancel := range conns {
+		conn.sendClose(CloseGoingAway, shutdownReason)
+		cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	dropped := len(s.conns)
+	for conn := range s.conns {
+		_ = conn.ws.Close()
+	}
+	s.mu.Unlock()
+
+	s.logger.Warnf("websocket handlers didn't return in time, dropped %d connections", dropped)
+	return ctx.Err()
+}
+
+// originChecker returns nil for the same origin check of gorilla
+func originChecker(allowed []string) func(r *http.Request) bool {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		// not a browser
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+
+		for _, o := range allowed {
+			if isAllowedOrigin(o, u) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func isAllowedOrigin(pattern string, origin *url.URL) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	scheme, host, ok := strings.Cut(pattern, "://")
+	if !ok || !strings.EqualFold(scheme, origin.Scheme) {
+		return false
+	}
+	if suffix, ok := strings.CutPrefix(host, "*."); ok {
+		h := strings.ToLower(origin.Host)
+		return strings.HasSuffix(h, "."+strings.ToLower(suffix))
+	}
+	return strings.EqualFold(host, origin.Host)
+}
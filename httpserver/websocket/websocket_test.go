@@ -0,0 +1,242 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func newTestServer(t *testing.T, cfg Config, handler Handler) (*Server, string) {
+	logger.InitServLogger(false)
+	gin.SetMode(gin.TestMode)
+
+	s := NewServer(cfg)
+	engine := gin.New()
+	engine.Use(middleware.Timeout(50 * time.Millisecond))
+	engine.GET("/ws", s.Handler(handler))
+
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return s, "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+}
+
+func echo(ctx context.Context, conn *Conn) {
+	for {
+		messageType, msg, err := conn.ReadMessage(ctx)
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage(messageType, msg)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	_, url := newTestServer(t, DefaultConfig(), echo)
+
+	client, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err, "must be nil")
+	defer client.Close()
+
+	// longer than the request timeout
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Nil(t, client.WriteMessage(websocket.TextMessage, []byte("hello")), "must be nil")
+	_, msg, err := client.ReadMessage()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "hello", string(msg), "should be equal")
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxMessageSize = 8
+	_, url := newTestServer(t, cfg, echo)
+
+	client, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err, "must be nil")
+	defer client.Close()
+
+	assert.Nil(t, client.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 64))), "must be nil")
+	_, _, err = client.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), "should be closed as too big")
+}
+
+func TestKeepalive(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PingInterval = 20 * time.Millisecond
+	cfg.PongTimeout = 60 * time.Millisecond
+
+	done := make(chan struct{}, 2)
+	_, url := newTestServer(t, cfg, func(ctx context.Context, conn *Conn) {
+		<-ctx.Done()
+		done <- struct{}{}
+	})
+
+	// the client answers pings while it reads
+	client, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err, "must be nil")
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("connection answering pings should stay open")
+	case <-time.After(200 * time.Millisecond):
+	}
+	_ = client.Close()
+	<-done
+
+	// a silent client doesn't answer pings
+	silent, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err, "must be nil")
+	defer silent.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("silent connection should time out")
+	}
+}
+
+func TestOrigins(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AllowedOrigins = []string{"https://app.example.com", "https://*.example.org"}
+	_, url := newTestServer(t, cfg, echo)
+
+	for origin, allowed := range map[string]bool{
+		"https://app.example.com":  true,
+		"https://a.b.example.org":  true,
+		"https://evil.example.com": false,
+		"http://app.example.com":   false,
+		"https://example.org":      false,
+	} {
+		client, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+		if allowed {
+			assert.Nil(t, err, "must be nil")
+			_ = client.Close()
+		} else {
+			assert.NotNil(t, err, "should be an error")
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode, "should be equal")
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	returned := make(chan struct{})
+	s, url := newTestServer(t, DefaultConfig(), func(ctx context.Context, conn *Conn) {
+		echo(ctx, conn)
+		close(returned)
+	})
+
+	client, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err, "must be nil")
+	defer client.Close()
+	assert.Eventually(t, func() bool { return s.Len() == 1 }, time.Second, 10*time.Millisecond)
+
+	// the client answers the close frame while reading
+	closeErr := make(chan error, 1)
+	go func() {
+		_, _, err := client.ReadMessage()
+		closeErr <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.Nil(t, s.Shutdown(ctx), "must be nil")
+
+	<-returned
+	assert.True(t, websocket.IsCloseError(<-closeErr, websocket.CloseGoingAway), "should receive going away")
+	assert.Equal(t, 0, s.Len(), "should be equal")
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NotNil(t, err, "should be an error")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "should be equal")
+}
+
+func TestShutdownWaitsForTrackedRequests(t *testing.T) {
+	logger.InitServLogger(false)
+	gin.SetMode(gin.TestMode)
+
+	s := NewServer(DefaultConfig())
+	var after atomic.Bool
+	engine := gin.New()
+	engine.Use(s.Track())
+	// like the access log, it runs once the handler returned
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		time.Sleep(100 * time.Millisecond)
+		after.Store(true)
+	})
+	engine.GET("/ws", s.Handler(echo))
+
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	assert.Nil(t, err, "must be nil")
+	defer client.Close()
+	assert.Eventually(t, func() bool { return s.Len() == 1 }, time.Second, 10*time.Millisecond)
+
+	go func() { _, _, _ = client.ReadMessage() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.Nil(t, s.Shutdown(ctx), "must be nil")
+	assert.True(t, after.Load(), "should wait for the outer middlewares")
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	_, url := newTestServer(t, DefaultConfig(), func(ctx context.Context, conn *Conn) {
+		hub.Subscribe(conn.Request().URL.Query().Get("topic"), conn)
+		<-ctx.Done()
+	})
+
+	news1, _, err := websocket.DefaultDialer.Dial(url+"?topic=news", nil)
+	assert.Nil(t, err, "must be nil")
+	news2, _, err := websocket.DefaultDialer.Dial(url+"?topic=news", nil)
+	assert.Nil(t, err, "must be nil")
+	sports, _, err := websocket.DefaultDialer.Dial(url+"?topic=sports", nil)
+	assert.Nil(t, err, "must be nil")
+	defer sports.Close()
+
+	assert.Eventually(t, func() bool { return hub.Subscribers("news") == 2 }, time.Second, 10*time.Millisecond)
+
+	sent, err := hub.BroadcastJSON("news", map[string]string{"title": "hi"})
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, 2, sent, "should be equal")
+
+	for _, client := range []*websocket.Conn{news1, news2} {
+		_, msg, err := client.ReadMessage()
+		assert.Nil(t, err, "must be nil")
+		assert.JSONEq(t, `{"title":"hi"}`, string(msg), "should be equal")
+	}
+
+	// disconnected connections are unsubscribed
+	_ = news1.Close()
+	assert.Eventually(t, func() bool { return hub.Subscribers("news") == 1 }, time.Second, 10*time.Millisecond)
+	_ = news2.Close()
+	assert.Eventually(t, func() bool { return hub.Subscribers("news") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, hub.Subscribers("sports"), "should be equal")
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, DefaultConfig().Validate(), "must be nil")
+	assert.Nil(t, Config{}.Validate(), "must be nil")
+	assert.NotNil(t, Config{PingInterval: time.Minute, PongTimeout: time.Second}.Validate(), "should be an error")
+	assert.NotNil(t, Config{PongTimeout: time.Second}.Validate(), "should be an error")
+}
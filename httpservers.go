@@ -0,0 +1,69 @@
+package goservice
+
+import (
+	"fmt"
+
+	"github.com/taimaifika/go-sdk/httpserver"
+)
+
+// allHTTPServers returns the default http server then the ones of WithHTTPServer
+func (s *service) allHTTPServers() []HttpServer {
+	result := []HttpServer{}
+	for _, name := range s.httpServerPrefixes() {
+		if hs := s.HTTPServerNamed(name); hs != nil {
+			result = append(result, hs)
+		}
+	}
+	return result
+}
+
+func (s *service) httpServerPrefixes() []string {
+	return append([]string{httpserver.DefaultPrefix}, s.httpServerNames...)
+}
+
+// checkHTTPAddrs fails when two http servers which listen resolve to the
+// same address, random ports never conflict
+func (s *service) checkHTTPAddrs() error {
+	names := s.httpServerPrefixes()
+	servers := make([]HttpServer, len(names))
+	for i, name := range names {
+		servers[i] = s.HTTPServerNamed(name)
+	}
+
+	for i := range servers {
+		if servers[i] == nil || !servers[i].Enabled() {
+			continue
+		}
+		a := servers[i].GetConfig()
+		for j := i + 1; j < len(servers); j++ {
+			if servers[j] == nil || !servers[j].Enabled() {
+				continue
+			}
+			if b := servers[j].GetConfig(); sameHTTPAddr(a, b) {
+				return fmt.Errorf("http servers %s and %s both listen on %s, set %s-port or %s-port",
+					names[i], names[j], servers[i].URI(), names[i], names[j])
+			}
+		}
+	}
+	return nil
+}
+
+func sameHTTPAddr(a, b httpserver.Config) bool {
+	aUnix, bUnix := a.ListenNetwork == httpserver.ListenNetworkUnix, b.ListenNetwork == httpserver.ListenNetworkUnix
+	if aUnix || bUnix {
+		return aUnix && bUnix && a.UnixSocketPath == b.UnixSocketPath
+	}
+	if a.Port == 0 || a.Port != b.Port {
+		return false
+	}
+	return a.BindAddr == b.BindAddr || isWildcardAddr(a.BindAddr) || isWildcardAddr(b.BindAddr)
+}
+
+// isWildcardAddr returns whether addr listens on all the interfaces
+func isWildcardAddr(addr string) bool {
+	switch addr {
+	case "", "0.0.0.0", "::", "[::]":
+		return true
+	}
+	return false
+}
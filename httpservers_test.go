@@ -0,0 +1,58 @@
+package goservice
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver"
+)
+
+func TestWithHTTPServer(t *testing.T) {
+	s := newTestService(WithHTTPServer("partner"), WithHTTPServer("partner"), WithHTTPServer("gin"))
+	assert.Equal(t, []string{"partner"}, s.httpServerNames, "should be equal")
+	assert.NotNil(t, s.initErr, "should be an error")
+	assert.Contains(t, s.initErr.Error(), "http server partner is duplicated", "should be equal")
+	assert.Contains(t, s.initErr.Error(), `invalid http server name "gin"`, "should be equal")
+}
+
+func TestCheckHTTPAddrs(t *testing.T) {
+	gs := httpserver.New("test")
+	partner := httpserver.NewNamed("test", "partner")
+	s := newTestService()
+	s.httpServer = gs
+	s.httpServers = map[string]HttpServer{"partner": partner}
+	s.httpServerNames = []string{"partner"}
+
+	assert.Equal(t, gs, s.HTTPServerNamed("gin"), "should be the default server")
+	assert.Equal(t, partner, s.HTTPServerNamed("partner"), "should be equal")
+	assert.Nil(t, s.HTTPServerNamed("admin"), "must be nil")
+	assert.Len(t, s.allHTTPServers(), 2, "should be equal")
+
+	gs.Config.Port, partner.Config.Port = 3000, 3000
+	assert.Nil(t, s.checkHTTPAddrs(), "servers without handlers don't listen")
+
+	gs.AddHandler(func(*gin.Engine) {})
+	partner.AddHandler(func(*gin.Engine) {})
+	err := s.checkHTTPAddrs()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "http servers gin and partner both listen on :3000", "should be equal")
+
+	partner.Config.BindAddr = "127.0.0.1"
+	assert.NotNil(t, s.checkHTTPAddrs(), "should conflict with all the interfaces")
+
+	gs.Config.BindAddr = "10.0.0.1"
+	assert.Nil(t, s.checkHTTPAddrs(), "must be nil")
+
+	gs.Config.BindAddr, partner.Config.Port = "", 3001
+	assert.Nil(t, s.checkHTTPAddrs(), "must be nil")
+
+	gs.Config.Port, partner.Config.Port = 0, 0
+	assert.Nil(t, s.checkHTTPAddrs(), "random ports never conflict")
+
+	gs.ListenNetwork, gs.UnixSocketPath = httpserver.ListenNetworkUnix, "/run/app.sock"
+	partner.ListenNetwork, partner.UnixSocketPath = httpserver.ListenNetworkUnix, "/run/app.sock"
+	assert.NotNil(t, s.checkHTTPAddrs(), "should conflict on the socket")
+	partner.UnixSocketPath = "/run/partner.sock"
+	assert.Nil(t, s.checkHTTPAddrs(), "must be nil")
+}
@@ -0,0 +1,326 @@
+// Package i18n translates messages from catalogs of JSON or TOML files,
+// one file per locale named after it, e.g. locales/en.toml and locales/vi.json:
+//
+//	order_not_found = "order %s not found"
+//
+//	[cart.items]
+//	one = "%d item"
+//	other = "%d items"
+//
+// Nested tables give dotted keys (cart.items), tables of plural categories
+// (zero, one, two, few, many, other) are plural messages.
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+const DefaultLocale = "en"
+
+type localeCtxKey struct{}
+
+var defaultBundle atomic.Pointer[Bundle]
+
+func init() {
+	defaultBundle.Store(NewBundle(DefaultLocale))
+}
+
+// Default returns the bundle of T, an empty bundle of en until SetDefault is called
+func Default() *Bundle {
+	return defaultBundle.Load()
+}
+
+// SetDefault makes b the bundle of T and of the translated errors of the http server
+func SetDefault(b *Bundle) {
+	defaultBundle.Store(b)
+}
+
+// T translates key with the default bundle in the locale of ctx, see Bundle.T
+func T(ctx context.Context, key string, args ...interface{}) string {
+	return Default().T(ctx, key, args...)
+}
+
+// WithLocale returns a copy of ctx carrying the locale, middleware.Locale sets it
+// from the requests
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeCtxKey{}, locale)
+}
+
+// LocaleFromContext returns the locale of ctx, empty if there is none
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeCtxKey{}).(string)
+	return locale
+}
+
+type message struct {
+	text string
+	// by plural category, nil for messages which are not plural
+	forms map[string]string
+}
+
+// Bundle holds the catalogs of the locales, it's safe for concurrent use
+type Bundle struct {
+	defaultLocale string
+
+	mu       sync.RWMutex
+	catalogs map[string]map[string]message
+	// locale and key of the missing messages already logged
+	missing sync.Map
+}
+
+// NewBundle returns an empty bundle, messages missing in a locale fall back to defaultLocale
+func NewBundle(defaultLocale string) *Bundle {
+	return &Bundle{defaultLocale: normalizeLocale(defaultLocale), catalogs: map[string]map[string]message{}}
+}
+
+func (b *Bundle) DefaultLocale() string {
+	return b.defaultLocale
+}
+
+// Locales returns the sorted locales having a catalog
+func (b *Bundle) Locales() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	locales := make([]string, 0, len(b.catalogs))
+	for l := range b.catalogs {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// AddMessages adds the messages of locale, the values are strings, maps of
+// plural categories to strings, or nested maps giving dotted keys
+func (b *Bundle) AddMessages(locale string, messages map[string]interface{}) error {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return fmt.Errorf("i18n: locale is required")
+	}
+
+	flat := map[string]message{}
+	if err := flatten(flat, "", messages); err != nil {
+		return fmt.Errorf("i18n: invalid messages of %s: %w", locale, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	catalog, ok := b.catalogs[locale]
+	if !ok {
+		catalog = map[string]message{}
+		b.catalogs[locale] = catalog
+	}
+	for k, m := range flat {
+		catalog[k] = m
+	}
+	return nil
+}
+
+// LoadFS loads every .json and .toml file of dir in fsys, e.g. an embed.FS:
+//
+//	//go:embed locales
+//	var locales embed.FS
+//
+//	err := bundle.LoadFS(locales, "locales")
+func (b *Bundle) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("i18n: %w", err)
+	}
+
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if e.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+
+		name := path.Join(dir, e.Name())
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("i18n: %w", err)
+		}
+
+		messages := map[string]interface{}{}
+		if ext == ".json" {
+			err = json.Unmarshal(data, &messages)
+		} else {
+			err = toml.Unmarshal(data, &messages)
+		}
+		if err != nil {
+			return fmt.Errorf("i18n: invalid catalog %s: %w", name, err)
+		}
+
+		if err := b.AddMessages(strings.TrimSuffix(e.Name(), ext), messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadDir loads the catalogs of a directory, see LoadFS
+func (b *Bundle) LoadDir(dir string) error {
+	return b.LoadFS(os.DirFS(dir), ".")
+}
+
+// Match returns the first of locales having a catalog, "vi-VN" matches vi when
+// there is no vi-VN catalog
+func (b *Bundle) Match(locales ...string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, l := range locales {
+		l = normalizeLocale(l)
+		if _, ok := b.catalogs[l]; ok {
+			return l, true
+		}
+		if base := baseLanguage(l); base != l {
+			if _, ok := b.catalogs[base]; ok {
+				return base, true
+			}
+		}
+	}
+	return "", false
+}
+
+// T translates key in the locale of ctx, see Translate. Missing keys give the key.
+func (b *Bundle) T(ctx context.Context, key string, args ...interface{}) string {
+	msg, _ := b.Translate(LocaleFromContext(ctx), key, args...)
+	return msg
+}
+
+// Translate formats the message of key in locale with args like fmt.Sprintf.
+// Plural messages choose their form by the first arg, which is the count.
+// Keys missing in locale fall back to the default locale, then to the key
+// itself and false. Each missing key is logged once.
+func (b *Bundle) Translate(locale, key string, args ...interface{}) (string, bool) {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		locale = b.defaultLocale
+	}
+
+	m, ok := b.lookup(locale, key)
+	if !ok {
+		b.logMissing(locale, key)
+		if locale != b.defaultLocale {
+			m, ok = b.lookup(b.defaultLocale, key)
+			if !ok {
+				b.logMissing(b.defaultLocale, key)
+			}
+			locale = b.defaultLocale
+		}
+	}
+	if !ok {
+		return key, false
+	}
+
+	text := m.text
+	if m.forms != nil {
+		text = m.forms["other"]
+		if len(args) > 0 {
+			if n, isCount := count(args[0]); isCount {
+				if form, ok := m.forms[pluralCategory(locale, n)]; ok {
+					text = form
+				}
+			}
+		}
+	}
+	return format(text, args), true
+}
+
+// lookup finds key in the catalog of locale, or of its base language
+func (b *Bundle) lookup(locale, key string) (message, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if m, ok := b.catalogs[locale][key]; ok {
+		return m, true
+	}
+	m, ok := b.catalogs[baseLanguage(locale)][key]
+	return m, ok
+}
+
+func (b *Bundle) logMissing(locale, key string) {
+	if _, logged := b.missing.LoadOrStore(locale+" "+key, struct{}{}); logged {
+		return
+	}
+	if log := logger.GetCurrent(); log != nil {
+		log.GetLogger("i18n").Warnf("missing message %s of locale %s", key, locale)
+	}
+}
+
+// format is fmt.Sprintf, the forms of plural messages may not use every arg
+// (one = "an item") so extra args are not reported
+func format(text string, args []interface{}) string {
+	if len(args) == 0 {
+		return text
+	}
+	s := fmt.Sprintf(text, args...)
+	if i := strings.Index(s, "%!(EXTRA "); i >= 0 && strings.HasSuffix(s, ")") {
+		s = s[:i]
+	}
+	return s
+}
+
+func flatten(out map[string]message, prefix string, messages map[string]interface{}) error {
+	for k, v := range messages {
+		key := prefix + k
+		switch v := v.(type) {
+		case string:
+			out[key] = message{text: v}
+		case map[string]interface{}:
+			if forms, ok := pluralForms(v); ok {
+				out[key] = message{forms: forms}
+				continue
+			}
+			if err := flatten(out, key+".", v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %s must be a string or a table, got %T", key, v)
+		}
+	}
+	return nil
+}
+
+// pluralForms returns the forms of a table of plural categories, which must have other
+func pluralForms(v map[string]interface{}) (map[string]string, bool) {
+	if _, ok := v["other"]; !ok {
+		return nil, false
+	}
+
+	forms := make(map[string]string, len(v))
+	for k, form := range v {
+		s, ok := form.(string)
+		if !ok || !isPluralCategory(k) {
+			return nil, false
+		}
+		forms[k] = s
+	}
+	return forms, true
+}
+
+// normalizeLocale makes vi_VN, vi-VN and VI-vn the same
+func normalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
+}
+
+// baseLanguage returns vi of vi-vn
+func baseLanguage(locale string) string {
+	if i := strings.IndexByte(locale, '-'); i > 0 {
+		return locale[:i]
+	}
+	return locale
+}
@@ -0,0 +1,94 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/locales
+var locales embed.FS
+
+func newTestBundle(t *testing.T) *Bundle {
+	b := NewBundle("en")
+	assert.Nil(t, b.LoadFS(locales, "testdata/locales"), "must be nil")
+	return b
+}
+
+func TestBundle(t *testing.T) {
+	b := newTestBundle(t)
+	assert.Equal(t, []string{"en", "vi"}, b.Locales(), "should be equal")
+
+	vi := WithLocale(context.Background(), "vi")
+	assert.Equal(t, "xin chào An", b.T(vi, "greeting", "An"), "should be equal")
+	assert.Equal(t, "không tìm thấy đơn hàng 42", b.T(vi, "errors.order_not_found", "42"), "should be equal")
+	assert.Equal(t, "hello An", b.T(context.Background(), "greeting", "An"), "should be the default locale")
+
+	msg, ok := b.Translate("vi-VN", "greeting", "An")
+	assert.True(t, ok, "should be true")
+	assert.Equal(t, "xin chào An", msg, "region should fall back to the language")
+
+	// missing in vi, from en
+	assert.Nil(t, b.AddMessages("en", map[string]interface{}{"bye": "bye"}), "must be nil")
+	assert.Equal(t, "bye", b.T(vi, "bye"), "should be equal")
+
+	msg, ok = b.Translate("vi", "unknown.key")
+	assert.False(t, ok, "should be false")
+	assert.Equal(t, "unknown.key", msg, "should be the key")
+}
+
+func TestPlural(t *testing.T) {
+	b := newTestBundle(t)
+	en := WithLocale(context.Background(), "en-US")
+	vi := WithLocale(context.Background(), "vi")
+
+	assert.Equal(t, "1 item", b.T(en, "cart.items", 1), "should be equal")
+	assert.Equal(t, "0 items", b.T(en, "cart.items", 0), "should be equal")
+	assert.Equal(t, "2 items", b.T(en, "cart.items", int64(2)), "should be equal")
+	assert.Equal(t, "1 sản phẩm", b.T(vi, "cart.items", 1), "should be equal")
+	assert.Equal(t, "5 sản phẩm", b.T(vi, "cart.items", uint(5)), "should be equal")
+
+	// forms may not use the count
+	assert.Nil(t, b.AddMessages("en", map[string]interface{}{
+		"unread": map[string]interface{}{"one": "a new message", "other": "%d new messages"},
+	}), "must be nil")
+	assert.Equal(t, "a new message", b.T(en, "unread", 1), "should be equal")
+	assert.Equal(t, "3 new messages", b.T(en, "unread", 3), "should be equal")
+}
+
+func TestMatch(t *testing.T) {
+	b := newTestBundle(t)
+
+	for _, c := range []struct {
+		locales []string
+		expect  string
+		ok      bool
+	}{
+		{[]string{"vi"}, "vi", true},
+		{[]string{"VI_vn"}, "vi", true},
+		{[]string{"fr", "en-GB"}, "en", true},
+		{[]string{"fr"}, "", false},
+	} {
+		locale, ok := b.Match(c.locales...)
+		assert.Equal(t, c.expect, locale, "should be equal")
+		assert.Equal(t, c.ok, ok, "should be equal")
+	}
+}
+
+func TestAddMessagesInvalid(t *testing.T) {
+	b := NewBundle("en")
+	assert.NotNil(t, b.AddMessages("", map[string]interface{}{"a": "b"}), "should be an error")
+	assert.NotNil(t, b.AddMessages("en", map[string]interface{}{"a": 1}), "should be an error")
+}
+
+func TestDefault(t *testing.T) {
+	prev := Default()
+	defer SetDefault(prev)
+
+	SetDefault(newTestBundle(t))
+	assert.Equal(t, "xin chào An", T(WithLocale(context.Background(), "vi"), "greeting", "An"), "should be equal")
+	assert.Equal(t, "vi", LocaleFromContext(WithLocale(context.Background(), "vi")), "should be equal")
+	assert.Empty(t, LocaleFromContext(context.Background()), "should be empty")
+}
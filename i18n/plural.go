@@ -0,0 +1,85 @@
+package i18n
+
+import (
+	"math"
+	"sync"
+)
+
+// PluralRule returns the CLDR plural category of n: zero, one, two, few, many or other
+type PluralRule func(n float64) string
+
+var (
+	pluralMu    sync.RWMutex
+	pluralRules = map[string]PluralRule{
+		// 1 item, 0 items, 1.5 items
+		"en": func(n float64) string {
+			if n == 1 {
+				return "one"
+			}
+			return "other"
+		},
+		// Vietnamese nouns don't inflect: 1 sản phẩm, 2 sản phẩm
+		"vi": func(float64) string { return "other" },
+	}
+)
+
+// RegisterPluralRule sets the plural rule of a language, languages without
+// rule only use the other form
+func RegisterPluralRule(lang string, rule PluralRule) {
+	pluralMu.Lock()
+	defer pluralMu.Unlock()
+	pluralRules[normalizeLocale(lang)] = rule
+}
+
+func pluralCategory(locale string, n float64) string {
+	pluralMu.RLock()
+	rule, ok := pluralRules[locale]
+	if !ok {
+		rule, ok = pluralRules[baseLanguage(locale)]
+	}
+	pluralMu.RUnlock()
+
+	if !ok {
+		return "other"
+	}
+	return rule(math.Abs(n))
+}
+
+func isPluralCategory(s string) bool {
+	switch s {
+	case "zero", "one", "two", "few", "many", "other":
+		return true
+	}
+	return false
+}
+
+// count returns the number of a plural message arg
+func count(arg interface{}) (float64, bool) {
+	switch n := arg.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
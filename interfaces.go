@@ -1,8 +1,24 @@
 package goservice
 
 import (
+	"context"
+	"flag"
+	"io"
+	"io/fs"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/taimaifika/go-sdk/cache"
+	"github.com/taimaifika/go-sdk/httpserver"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/httpserver/openapi"
+	"github.com/taimaifika/go-sdk/httpserver/websocket"
+	"github.com/taimaifika/go-sdk/i18n"
 	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/worker"
+	"google.golang.org/grpc"
 )
 
 // Convenience option method for creating/initializing a service
@@ -39,8 +55,18 @@ type Service interface {
 	Name() string
 	// Version of the service
 	Version() string
-	// Gin HTTP Server wrapper
+	// Name, version and build metadata of the service
+	BuildInfo() httpserver.BuildInfo
+	// Gin HTTP Server wrapper, never nil. It only listens once a handler is added,
+	// and ignores handlers with WithHTTPServerDisabled
 	HTTPServer() HttpServer
+	// Server added by WithHTTPServer(name), nil for other names.
+	// The name of the default server is gin.
+	HTTPServerNamed(name string) HttpServer
+	// Internal HTTP server for operational endpoints
+	AdminServer() AdminServer
+	// gRPC Server wrapper
+	GRPCServer() GrpcServer
 	// Init with options, they can be db connections or
 	// anything the service need handle before starting
 	Init() error
@@ -51,9 +77,27 @@ type Service interface {
 	Start() error
 	// Stop service and its all component.
 	Stop()
+	// Stop all components in reverse start order,
+	// returns an error if any of them doesn't stop before ctx is done
+	Shutdown(ctx context.Context) error
 	// Method export all flags to std/terminal
 	// We might use: "> .env" to move its content .env file
 	OutEnv()
+	// Write the current flag values as env (dotenv), yaml (config file)
+	// or k8s (ConfigMap and Secret), secret values are not written
+	ExportConfig(w io.Writer, format string) error
+	// Final value of every flag with its source and owner plugin
+	EffectiveConfig() []ConfigEntry
+	// Flag name => name of the plugin registered it
+	FlagOwners() map[string]string
+	// Flags of the service and its components, not flag.CommandLine.
+	// Ex: cobraCmd.Flags().AddGoFlagSet(service.FlagSet())
+	FlagSet() *flag.FlagSet
+	// Write ConfigMap and Secret manifests from registered flags
+	GenerateK8sManifests(w io.Writer, opts ManifestOptions) error
+	// Validate the config and configure the components without running them,
+	// e.g. in a cobra subcommand. See WriteCheckReport and the flag -check.
+	Check(ctx context.Context) CheckReport
 }
 
 // Service Context: A wrapper for all things needed for developing a service
@@ -61,10 +105,14 @@ type ServiceContext interface {
 	// Logger for a specific service, usually it has a prefix to distinguish
 	// with each others
 	Logger(prefix string) logger.Logger
-	// Get component with prefix
+	// Get component with prefix, see the typed Get and MustGet functions
 	Get(prefix string) (interface{}, bool)
 	MustGet(prefix string) interface{}
+	// Prefixes of the components, sorted
+	ListPrefixes() []string
 	Env() string
+	// Active profile (dev, staging, prod...), empty if none selected
+	Profile() string
 }
 
 // Runnable is an abstract object in SDK
@@ -75,16 +123,156 @@ type Runnable interface {
 	InitFlags()
 	Configure() error
 	Run() error
+	// Receives false if the component had to be stopped forcibly,
+	// e.g. in-flight requests were cut
 	Stop() <-chan bool
 }
 
+// HealthChecker is optionally implemented by Runnable components,
+// the readiness endpoint fails while any of them returns an error
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// ServiceRegistrar is optionally implemented by Runnable components registering
+// the service to a service discovery, ex: plugin/consul. Register is called once
+// the http server listens on its final port, before the OnStarted hooks.
+// Deregister is called when the service stops, before the OnStopping hooks.
+type ServiceRegistrar interface {
+	Register(ctx context.Context, name string, port int) error
+	Deregister(ctx context.Context) error
+}
+
+// FlagAliaser is optionally implemented by Runnable components keeping
+// the old names of renamed flags as aliases. An alias is deprecated and
+// shares the source and precedence of the flag it stands for.
+type FlagAliaser interface {
+	// FlagAliases returns the current flag names by alias
+	FlagAliases() map[string]string
+}
+
+// FlagValueResolver is optionally implemented by init components substituting
+// flag values in Init before any component is configured,
+// ex: vault://secret/data/myapp#db_password => the password read from Vault
+type FlagValueResolver interface {
+	// ResolveFlagValue returns false when value is not a reference it handles
+	ResolveFlagValue(value string) (string, bool, error)
+}
+
+// ConnectivityChecker is optionally implemented by components checking their
+// dependencies are reachable once configured, without running,
+// ex: a ping of the database. It's called by Check.
+type ConnectivityChecker interface {
+	CheckConnectivity(ctx context.Context) error
+}
+
+// ConfigDumper is optionally implemented by components describing their resolved
+// config, it's logged by Start and printed with the flag -print-config. The values
+// of sensitive keys are redacted like log fields. Others show their flags.
+type ConfigDumper interface {
+	DumpConfig() map[string]interface{}
+}
+
+// FlagSetInitializer is optionally implemented by components registering their
+// flags into the flag set of the service, InitFlags isn't called then.
+// InitFlags of the others registers into flag.CommandLine, swapped meanwhile.
+type FlagSetInitializer interface {
+	InitFlagSet(fs *flag.FlagSet)
+}
+
+// Dependent is optionally implemented by init components,
+// they run after the components with the returned prefixes are running
+type Dependent interface {
+	DependsOn() []string
+}
+
+// Register gRPC services to the server
+type GrpcServerRegistrar = func(*grpc.Server)
+
+// gRPC server
+type GrpcServer interface {
+	Runnable
+	// Register gRPC services, it enables the server
+	AddRegistrar(GrpcServerRegistrar)
+	// Port that the server is listening
+	Port() int
+	// URI that the server is listening
+	URI() string
+}
+
+// Internal HTTP server on admin-port, it serves the health, metrics,
+// pprof and log level endpoints instead of HttpServer when enabled
+type AdminServer interface {
+	Runnable
+	// Add handlers to the admin server, they are not served when it's disabled
+	AddHandler(HttpServerHandler)
+	// URI that the server is listening
+	URI() string
+}
+
 // GIN HTTP server for REST API
 type HttpServer interface {
 	Runnable
 	// Add handlers to GIN
 	AddHandler(HttpServerHandler)
+	// Add handlers for operational endpoints,
+	// they don't enable the server by themselves
+	AddOpsHandler(HttpServerHandler)
+	// Serve fsys under prefix for the paths without route, e.g. an embed.FS
+	SetStaticFS(fsys fs.FS, prefix string)
+	// Serve websockets on path, they are closed cleanly when stopping
+	AddWebsocketHandler(path string, handler websocket.Handler)
+	// Add a check to the readiness endpoint
+	AddHealthCheck(name string, check func(ctx context.Context) error)
+	// Set the info served by /version
+	SetBuildInfo(httpserver.BuildInfo)
+	// Use a different CORS config for paths starting with pathPrefix
+	AddCORSOverride(pathPrefix string, cfg middleware.CORSConfig)
+	// Share the global rate limit with a different backend, e.g. redis
+	SetRateLimiter(middleware.Limiter)
+	// Share the idempotency keys with a different backend, e.g. redis
+	SetIdempotencyStore(middleware.IdempotencyStore)
+	// Middleware replaying the responses of requests with an Idempotency-Key
+	IdempotentRoutes() gin.HandlerFunc
+	// Share the cached responses with a different backend, e.g. redis
+	SetResponseCacheStore(c cache.Cache)
+	// Middleware caching the GET responses of route groups for ttl, 0 => the default ttl
+	CachedRoutes(ttl time.Duration) gin.HandlerFunc
+	// Cache of CachedRoutes, to invalidate the responses changed by mutations
+	ResponseCache() *middleware.ResponseCache
+	// Turn the maintenance mode on or off, eta gives the Retry-After of the 503 responses
+	SetMaintenance(ctx context.Context, enabled bool, message string, eta *time.Time) error
+	MaintenanceState() middleware.MaintenanceState
+	// Share the maintenance state across replicas, e.g. with sdkredis.NewMaintenanceSync
+	SetMaintenanceSync(s middleware.MaintenanceSync)
+	// Documents the routes registered with it in the OpenAPI document
+	OpenAPI() *openapi.Docs
+	// Routes of the server sorted by path, also served by /admin/routes
+	Routes() []httpserver.RouteInfo
+	// Keep the api keys of RequiredAPIKey in a different store, e.g. the database
+	SetAPIKeyStore(middleware.KeyStore)
+	// Middleware authenticating machine to machine callers with api keys
+	RequiredAPIKey(opts ...middleware.APIKeyOption) gin.HandlerFunc
+	// Write the audit entries to sink with the jobs of queue, e.g. a table
+	SetAuditSink(sink middleware.AuditSink, queue worker.Queue)
+	// Translate with the given catalogs instead of the ones of gin-i18n-dir, e.g. embedded ones
+	SetI18nBundle(b *i18n.Bundle)
+	// Forward panics of handlers, e.g. to Sentry or Slack
+	AddPanicNotifier(n middleware.PanicNotifier)
+	// Add a binding rule, e.g. binding:"required,sku"
+	RegisterValidation(tag string, fn validator.Func) error
+	// Add a validation of whole structs of the types
+	RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) error
+	// Whether the server listens: handlers or static files are added
+	Enabled() bool
+	// Closed once the server is listening, or right away when it's disabled
+	Listening() <-chan struct{}
+	// Configure the server and its routes without listening, to serve requests in memory
+	Handler() (http.Handler, error)
 	// Return server config
-	//GetConfig() http_server.Config
+	GetConfig() httpserver.Config
+	// Apply config without dropping requests, also done on SIGHUP and POST /admin/reload
+	Reload(config httpserver.Config) error
 	// URI that the server is listening
 	URI() string
 }
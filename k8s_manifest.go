@@ -0,0 +1,105 @@
+package goservice
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ManifestOptions customizes generated Kubernetes manifests
+type ManifestOptions struct {
+	// Namespace of the manifests, omitted if empty
+	Namespace string
+	// Labels added to metadata of the manifests
+	Labels map[string]string
+}
+
+var invalidK8sNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// k8sName converts service name to a valid Kubernetes object name
+func k8sName(name string) string {
+	name = invalidK8sNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "service"
+	}
+	return name
+}
+
+// GenerateK8sManifests writes a ConfigMap with every non-secret flag as env var
+// and a Secret stub with placeholders for secret flags.
+// Deprecated flags are omitted, required flags without value are rendered as comments.
+func (s *service) GenerateK8sManifests(w io.Writer, opts ManifestOptions) error {
+	var configs, secrets []*flag.Flag
+
+	s.cmdLine.VisitAll(func(f *flag.Flag) {
+		if s.isDeprecatedFlag(f.Name) {
+			return
+		}
+
+		if s.isSecretFlag(f) {
+			secrets = append(secrets, f)
+		} else {
+			configs = append(configs, f)
+		}
+	})
+
+	name := k8sName(s.name)
+
+	b := &strings.Builder{}
+	writeK8sHeader(b, "ConfigMap", name+"-config", opts)
+	b.WriteString("data:\n")
+	for _, f := range s.sortFlagsByEnvName(configs) {
+		value := f.Value.String()
+		if value == "" && s.isRequiredFlag(f.Name) {
+			fmt.Fprintf(b, "  # %s: # REQUIRED %s\n", s.cmdLine.envName(f.Name), f.Usage)
+			continue
+		}
+		fmt.Fprintf(b, "  %s: %s\n", s.cmdLine.envName(f.Name), strconv.Quote(value))
+	}
+
+	if len(secrets) > 0 {
+		b.WriteString("---\n")
+		writeK8sHeader(b, "Secret", name+"-secret", opts)
+		b.WriteString("type: Opaque\nstringData:\n")
+		for _, f := range s.sortFlagsByEnvName(secrets) {
+			if s.isRequiredFlag(f.Name) {
+				fmt.Fprintf(b, "  # REQUIRED %s\n", f.Usage)
+			}
+			fmt.Fprintf(b, "  %s: %s\n", s.cmdLine.envName(f.Name), strconv.Quote("<"+s.cmdLine.envName(f.Name)+">"))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeK8sHeader(b *strings.Builder, kind, name string, opts ManifestOptions) {
+	fmt.Fprintf(b, "apiVersion: v1\nkind: %s\nmetadata:\n  name: %s\n", kind, name)
+
+	if opts.Namespace != "" {
+		fmt.Fprintf(b, "  namespace: %s\n", opts.Namespace)
+	}
+
+	if len(opts.Labels) > 0 {
+		keys := make([]string, 0, len(opts.Labels))
+		for k := range opts.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b.WriteString("  labels:\n")
+		for _, k := range keys {
+			fmt.Fprintf(b, "    %s: %s\n", k, strconv.Quote(opts.Labels[k]))
+		}
+	}
+}
+
+func (s *service) sortFlagsByEnvName(flags []*flag.Flag) []*flag.Flag {
+	sort.Slice(flags, func(i, j int) bool { return s.cmdLine.envName(flags[i].Name) < s.cmdLine.envName(flags[j].Name) })
+	return flags
+}
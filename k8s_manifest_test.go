@@ -0,0 +1,72 @@
+package goservice
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver"
+	"github.com/taimaifika/go-sdk/plugin/otel"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+type fakeDBPlugin struct {
+	dsn      string
+	password string
+	poolSize int
+}
+
+func (p *fakeDBPlugin) Name() string { return "fake-db" }
+func (p *fakeDBPlugin) InitFlags() {
+	flag.StringVar(&p.dsn, "fake-db-dsn", "", "Fake database DSN")
+	SecretVar(&p.password, "fake-db-password", "", "Fake database password")
+	flag.IntVar(&p.poolSize, "fake-db-pool-size", 10, "Fake database pool size")
+	flag.IntVar(&p.poolSize, "fake-db-max-conns", 10, "Fake database max connections")
+}
+func (p *fakeDBPlugin) Configure() error { return nil }
+func (p *fakeDBPlugin) Run() error       { return nil }
+func (p *fakeDBPlugin) Stop() <-chan bool {
+	c := make(chan bool, 1)
+	c <- true
+	return c
+}
+
+func TestGenerateK8sManifests(t *testing.T) {
+	commandLine := flag.CommandLine
+	flag.CommandLine = flag.NewFlagSet("demo", flag.ContinueOnError)
+	defer func() { flag.CommandLine = commandLine }()
+
+	s := &service{
+		name:        "Demo Service",
+		subServices: []Runnable{httpserver.New("demo"), otel.NewOtelPlugin("otel", "otel"), &fakeDBPlugin{}},
+		cmdLine:     newFlagSet("demo", flag.CommandLine),
+		flagSources: map[string]ConfigSource{},
+		flagOwners:  map[string]string{},
+		flagAliases: map[string]string{},
+	}
+	s.initFlagMeta()
+	WithRequiredFlag("fake-db-dsn")(s)
+	WithRequiredFlag("fake-db-password")(s)
+	WithDeprecatedFlag("fake-db-max-conns", "use fake-db-pool-size")(s)
+	s.initFlags()
+	assert.Nil(t, s.initErr, "must be nil")
+
+	buf := &bytes.Buffer{}
+	err := s.GenerateK8sManifests(buf, ManifestOptions{
+		Namespace: "backend",
+		Labels:    map[string]string{"app": "demo", "team": "core"},
+	})
+	assert.Nil(t, err, "must be nil")
+
+	golden := "testdata/k8s_manifests.golden"
+	if *updateGolden {
+		assert.Nil(t, os.WriteFile(golden, buf.Bytes(), 0644))
+	}
+
+	expect, err := os.ReadFile(golden)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, string(expect), buf.String(), "should be equal")
+}
@@ -0,0 +1,111 @@
+package goservice
+
+import (
+	"context"
+	"fmt"
+)
+
+// Hook runs at a point of the service lifecycle
+type Hook func(Service) error
+
+// WithOnInit runs fn at the end of Init, once the init components are running
+// and before Start runs the servers, e.g. to warm caches. An error aborts Init.
+// Hooks of the same point run in the order they are added.
+func WithOnInit(fn Hook) Option {
+	return func(s *service) { s.onInit = append(s.onInit, fn) }
+}
+
+// WithOnStarted runs fn once the http server is listening and its Port is final,
+// e.g. to register to service discovery. An error stops the service.
+func WithOnStarted(fn Hook) Option {
+	return func(s *service) { s.onStarted = append(s.onStarted, fn) }
+}
+
+// WithOnStopping runs fn when the service stops, before the components are stopped,
+// e.g. to deregister from service discovery before the http server drains.
+// Errors are logged, the next hooks still run.
+func WithOnStopping(fn Hook) Option {
+	return func(s *service) { s.onStopping = append(s.onStopping, fn) }
+}
+
+// runHooks stops at the first error
+func (s *service) runHooks(point string, hooks []Hook) error {
+	for i, hook := range hooks {
+		if err := hook(s); err != nil {
+			return fmt.Errorf("%s hook #%d: %w", point, i+1, err)
+		}
+	}
+	return nil
+}
+
+// waitStarted registers the service and runs the OnStarted hooks once the
+// http servers are listening, it gives up when the service stops first
+func (s *service) waitStarted(errChan chan<- error) {
+	for _, hs := range s.allHTTPServers() {
+		select {
+		case <-hs.Listening():
+		case <-s.doneChan:
+			return
+		}
+	}
+
+	if err := s.registerService(); err != nil {
+		errChan <- err
+		return
+	}
+	if err := s.runHooks("on started", s.onStarted); err != nil {
+		errChan <- err
+	}
+}
+
+// registerService calls the ServiceRegistrar components with the final port
+// of the http server, registrations are given up when the service stops
+func (s *service) registerService() error {
+	port := 0
+	if p, ok := s.httpServer.(interface{ Port() int }); ok {
+		port = p.Port()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.doneChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for _, r := range s.runnables() {
+		if sr, ok := r.(ServiceRegistrar); ok {
+			if err := sr.Register(ctx, s.name, port); err != nil {
+				return fmt.Errorf("register %s: %w", r.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// runStoppingHooks deregisters the service first so no traffic is routed to
+// it while the components stop
+func (s *service) runStoppingHooks() {
+	timeout := s.shutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	for _, r := range s.runnables() {
+		if sr, ok := r.(ServiceRegistrar); ok {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			if err := sr.Deregister(ctx); err != nil {
+				s.logger.Errorf("deregister %s: %s", r.Name(), err.Error())
+			}
+			cancel()
+		}
+	}
+
+	for i, hook := range s.onStopping {
+		if err := hook(s); err != nil {
+			s.logger.Errorf("on stopping hook #%d: %s", i+1, err.Error())
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package goservice
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver"
+)
+
+type recordingRunnable struct {
+	fakeRunnable
+}
+
+func (r *recordingRunnable) Run() error {
+	r.recorder.add(r.name + " run")
+	return nil
+}
+
+func (r *recordingRunnable) Stop() <-chan bool {
+	c := make(chan bool, 1)
+	r.recorder.add(r.name + " stop")
+	c <- true
+	return c
+}
+
+type registrarRunnable struct {
+	recordingRunnable
+	port int
+}
+
+func (r *registrarRunnable) Register(_ context.Context, name string, port int) error {
+	r.port = port
+	r.recorder.add("register " + name)
+	return nil
+}
+
+func (r *registrarRunnable) Deregister(context.Context) error {
+	r.recorder.add("deregister")
+	return nil
+}
+
+func newHookService(rec *stopRecorder, opts ...Option) *service {
+	db := &recordingRunnable{fakeRunnable{name: "db", prefix: "db", recorder: rec}}
+	s := newTestService(append([]Option{WithInitRunnable(db)}, opts...)...)
+	s.cmdLine = newFlagSet("test", flag.NewFlagSet("test", flag.ContinueOnError))
+
+	gs := httpserver.New("test")
+	gs.AddHandler(func(engine *gin.Engine) {})
+	s.httpServer = gs
+	s.subServices = append(s.subServices, gs)
+	return s
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	rec := &stopRecorder{}
+	hook := func(name string) Hook {
+		return func(sv Service) error {
+			rec.add(name)
+			return nil
+		}
+	}
+
+	var port int
+	s := newHookService(rec,
+		WithOnInit(hook("on init 1")),
+		WithOnInit(hook("on init 2")),
+		WithOnStarted(func(sv Service) error {
+			port = sv.HTTPServer().(interface{ Port() int }).Port()
+			rec.add("on started")
+			return nil
+		}),
+		WithOnStopping(func(sv Service) error {
+			rec.add("on stopping")
+			return errors.New("deregister failed")
+		}),
+		WithOnStopping(hook("on stopping 2")),
+	)
+
+	assert.Nil(t, s.Init(), "must be nil")
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- s.Start() }()
+
+	assert.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.names) == 4
+	}, time.Second, 10*time.Millisecond, "on started should run")
+	assert.NotZero(t, port, "port should be final")
+
+	assert.Nil(t, s.Shutdown(context.Background()), "must be nil")
+	assert.Nil(t, <-errChan, "must be nil")
+
+	assert.Equal(t, []string{
+		"db run", "on init 1", "on init 2", "on started",
+		"on stopping", "on stopping 2", "db stop",
+	}, rec.names, "should be equal")
+}
+
+func TestLifecycleHookErrors(t *testing.T) {
+	rec := &stopRecorder{}
+	s := newHookService(rec, WithOnInit(func(Service) error { return errors.New("warm up failed") }))
+	err := s.Init()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "on init hook #1: warm up failed")
+
+	s = newHookService(rec, WithOnStarted(func(Service) error { return errors.New("register failed") }))
+	assert.Nil(t, s.Init(), "must be nil")
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- s.Start() }()
+
+	select {
+	case err := <-errChan:
+		assert.Contains(t, err.Error(), "on started hook #1: register failed")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start should fail")
+	}
+}
+
+func TestServiceRegistrar(t *testing.T) {
+	rec := &stopRecorder{}
+	consul := &registrarRunnable{recordingRunnable: recordingRunnable{fakeRunnable{name: "consul", prefix: "consul", recorder: rec}}}
+	s := newHookService(rec,
+		WithName("orders"),
+		WithInitRunnable(consul),
+		WithOnStarted(func(Service) error {
+			rec.add("on started")
+			return nil
+		}),
+		WithOnStopping(func(Service) error {
+			rec.add("on stopping")
+			return nil
+		}),
+	)
+
+	assert.Nil(t, s.Init(), "must be nil")
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- s.Start() }()
+
+	assert.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.names) == 4
+	}, time.Second, 10*time.Millisecond, "on started should run")
+	assert.Equal(t, s.httpServer.(interface{ Port() int }).Port(), consul.port, "should be the final port")
+
+	assert.Nil(t, s.Shutdown(context.Background()), "must be nil")
+	assert.Nil(t, <-errChan, "must be nil")
+
+	// runnables start concurrently, only the order around the registration is guaranteed
+	assert.ElementsMatch(t, []string{
+		"consul run", "db run", "register orders", "on started",
+		"deregister", "on stopping", "consul stop", "db stop",
+	}, rec.names, "should be equal")
+	assertBefore(t, rec.names, "consul run", "register orders")
+	assertBefore(t, rec.names, "db run", "register orders")
+	assertBefore(t, rec.names, "register orders", "on started")
+	assertBefore(t, rec.names, "on started", "deregister")
+	assertBefore(t, rec.names, "deregister", "on stopping")
+	assertBefore(t, rec.names, "deregister", "consul stop")
+	assertBefore(t, rec.names, "deregister", "db stop")
+}
+
+// assertBefore asserts that first is recorded before then
+func assertBefore(t *testing.T, names []string, first, then string) {
+	t.Helper()
+	i, j := indexOf(names, first), indexOf(names, then)
+	assert.True(t, i >= 0 && j >= 0 && i < j, "%s should be before %s: %v", first, then, names)
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
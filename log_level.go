@@ -0,0 +1,37 @@
+package goservice
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+type logLevelRequest struct {
+	// empty prefix changes the default level
+	Prefix string `json:"prefix"`
+	Level  string `json:"level" binding:"required"`
+}
+
+// logLevelHandler serves the log levels and changes them at runtime
+func (s *service) logLevelHandler(engine *gin.Engine) {
+	engine.GET("/admin/log-level", func(c *gin.Context) {
+		c.JSON(http.StatusOK, logger.GetCurrent().Levels())
+	})
+
+	engine.PUT("/admin/log-level", func(c *gin.Context) {
+		var req logLevelRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := logger.GetCurrent().SetLevel(req.Prefix, req.Level); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		s.logger.Infof("log level of %q is changed to %s", req.Prefix, req.Level)
+		c.JSON(http.StatusOK, logger.GetCurrent().Levels())
+	})
+}
@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	AsyncPolicyBlock = "block"
+	AsyncPolicyDrop  = "drop"
+)
+
+const (
+	// lines flushed in one write of the underlying writer
+	maxAsyncBatchBytes = 32 << 10
+	// dropped lines are reported at most once per interval
+	asyncDropReportInterval = 10 * time.Second
+)
+
+// asyncWriter buffers lines in a ring buffer, a background goroutine writes
+// them to out. When the buffer is full, Write waits for room with the block
+// policy, or drops the line with the drop policy. The dropped lines are
+// counted and reported to onDrop periodically.
+type asyncWriter struct {
+	out    io.Writer
+	block  bool
+	onDrop func(n uint64)
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	ring [][]byte
+	head int
+	size int
+	// lines put to the ring and written to out since the start
+	queued  uint64
+	written uint64
+	closed  bool
+
+	// serializes the writes of the flusher and WriteSync
+	outMu   sync.Mutex
+	dropped atomic.Uint64
+
+	stopReport chan struct{}
+	wg         sync.WaitGroup
+}
+
+func newAsyncWriter(out io.Writer, bufferSize int, policy string, onDrop func(n uint64)) (*asyncWriter, error) {
+	if bufferSize <= 0 {
+		return nil, fmt.Errorf("log async buffer must be positive, got %d", bufferSize)
+	}
+	if policy != AsyncPolicyBlock && policy != AsyncPolicyDrop {
+		return nil, fmt.Errorf("unknown log async policy %q, must be block or drop", policy)
+	}
+
+	w := &asyncWriter{
+		out:        out,
+		block:      policy == AsyncPolicyBlock,
+		onDrop:     onDrop,
+		ring:       make([][]byte, bufferSize),
+		stopReport: make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	w.wg.Add(2)
+	go w.flushLoop()
+	go w.reportLoop(asyncDropReportInterval)
+	return w, nil
+}
+
+// Write copies p to the buffer, the formatters reuse their buffers.
+// After Close, p is written to out directly.
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	w.mu.Lock()
+	for !w.closed && w.size == len(w.ring) {
+		if !w.block {
+			w.mu.Unlock()
+			w.dropped.Add(1)
+			return len(p), nil
+		}
+		w.cond.Wait()
+	}
+	if w.closed {
+		w.mu.Unlock()
+		return w.writeOut(p)
+	}
+
+	w.ring[(w.head+w.size)%len(w.ring)] = append([]byte(nil), p...)
+	w.size++
+	w.queued++
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	return len(p), nil
+}
+
+// WriteSync writes the buffered lines then p, before returning.
+// Fatal and panic entries are written with it so they are never dropped.
+func (w *asyncWriter) WriteSync(p []byte) (int, error) {
+	w.Flush()
+	return w.writeOut(p)
+}
+
+func (w *asyncWriter) writeOut(p []byte) (int, error) {
+	w.outMu.Lock()
+	defer w.outMu.Unlock()
+	return w.out.Write(p)
+}
+
+// Flush waits until the lines buffered before the call are written
+func (w *asyncWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for target := w.queued; w.written < target; {
+		w.cond.Wait()
+	}
+}
+
+// Close flushes the buffer and stops the background goroutines,
+// the lines dropped since the last report are reported
+func (w *asyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	close(w.stopReport)
+	w.wg.Wait()
+	w.report()
+	return nil
+}
+
+func (w *asyncWriter) flushLoop() {
+	defer w.wg.Done()
+
+	var batch []byte
+	for {
+		w.mu.Lock()
+		for w.size == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if w.size == 0 {
+			w.mu.Unlock()
+			return
+		}
+
+		batch = batch[:0]
+		n := uint64(0)
+		for w.size > 0 && (len(batch) == 0 || len(batch)+len(w.ring[w.head]) <= maxAsyncBatchBytes) {
+			batch = append(batch, w.ring[w.head]...)
+			w.ring[w.head] = nil
+			w.head = (w.head + 1) % len(w.ring)
+			w.size--
+			n++
+		}
+		// there is room for the blocked writers
+		w.cond.Broadcast()
+		w.mu.Unlock()
+
+		_, _ = w.writeOut(batch)
+
+		w.mu.Lock()
+		w.written += n
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+func (w *asyncWriter) reportLoop(interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.report()
+		case <-w.stopReport:
+			return
+		}
+	}
+}
+
+func (w *asyncWriter) report() {
+	if n := w.dropped.Swap(0); n > 0 && w.onDrop != nil {
+		w.onDrop(n)
+	}
+}
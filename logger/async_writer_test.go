@@ -0,0 +1,155 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lockedBuffer is written by the flusher and read by the tests
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	// writes wait until it's closed when not nil
+	gate chan struct{}
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	if b.gate != nil {
+		<-b.gate
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newAsyncService(t *testing.T, backend string, out *lockedBuffer) *stdLogger {
+	s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info", DefaultBackend: backend})
+	s.logger.Out = out
+	s.asyncLog = true
+	s.asyncBuffer = 16
+	s.asyncPolicy = AsyncPolicyBlock
+	assert.Nil(t, s.Configure(), "must be nil")
+	return s
+}
+
+func TestAsyncWriterFlush(t *testing.T) {
+	out := &lockedBuffer{}
+	s := newAsyncService(t, BackendLogrus, out)
+	l := s.GetLogger("api")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Info("line")
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Nil(t, s.Flush(), "must be nil")
+	assert.Equal(t, 1000, strings.Count(out.String(), "msg=line"), "every entry must be written")
+	<-s.Stop()
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	out := &lockedBuffer{gate: make(chan struct{})}
+	var dropped uint64
+	w, err := newAsyncWriter(out, 2, AsyncPolicyDrop, func(n uint64) { dropped += n })
+	assert.Nil(t, err, "must be nil")
+
+	// the flusher takes the first line and waits for the gate,
+	// 2 more lines fill the buffer
+	for i := 0; i < 10; i++ {
+		n, err := w.Write([]byte("line\n"))
+		assert.Nil(t, err, "must be nil")
+		assert.Equal(t, 5, n, "should be equal")
+	}
+
+	close(out.gate)
+	assert.Nil(t, w.Close(), "must be nil")
+	written := strings.Count(out.String(), "line\n")
+	assert.GreaterOrEqual(t, written, 2, "the buffered lines must be written")
+	assert.Equal(t, uint64(10-written), dropped, "the dropped lines must be reported")
+
+	// written directly once closed
+	_, _ = w.Write([]byte("after\n"))
+	assert.Contains(t, out.String(), "after\n")
+}
+
+func TestAsyncWriterInvalidConfig(t *testing.T) {
+	_, err := newAsyncWriter(&lockedBuffer{}, 0, AsyncPolicyBlock, nil)
+	assert.NotNil(t, err, "should be an error")
+
+	s := NewAppLogService(nil)
+	s.asyncLog = true
+	s.asyncBuffer = 10
+	s.asyncPolicy = "wait"
+	assert.NotNil(t, s.Configure(), "should be an error")
+}
+
+func TestAsyncWriterPanicBypass(t *testing.T) {
+	for _, backend := range []string{BackendLogrus, BackendSlog} {
+		t.Run(backend, func(t *testing.T) {
+			out := &lockedBuffer{}
+			s := newAsyncService(t, backend, out)
+			l := s.GetLogger("api")
+
+			l.Info("before")
+			assert.Panics(t, func() { l.Panic("boom") }, "should panic")
+
+			// written before panicking, with the entries buffered before it
+			written := out.String()
+			assert.Contains(t, written, "msg=before")
+			assert.Contains(t, written, "msg=boom")
+			assert.Less(t, strings.Index(written, "msg=before"), strings.Index(written, "msg=boom"), "should keep the order")
+			<-s.Stop()
+		})
+	}
+}
+
+func benchmarkOutput(b *testing.B, async bool) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+
+	s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info"})
+	s.logger.Out = devNull
+	s.logFormat = FormatJSON
+	s.asyncLog = async
+	s.asyncBuffer = 8192
+	s.asyncPolicy = AsyncPolicyBlock
+	if err := s.Configure(); err != nil {
+		b.Fatal(err)
+	}
+	l := s.GetLogger("api")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Withs(Fields{"method": "GET", "status": 200}).Info("request")
+		}
+	})
+	b.StopTimer()
+	<-s.Stop()
+}
+
+func BenchmarkSyncOutput(b *testing.B) { benchmarkOutput(b, false) }
+
+func BenchmarkAsyncOutput(b *testing.B) { benchmarkOutput(b, true) }
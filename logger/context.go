@@ -0,0 +1,20 @@
+package logger
+
+import "context"
+
+type loggerCtxKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying the logger,
+// middlewares use it to attach request scoped fields (request id...)
+func ContextWithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx,
+// or a logger with the given prefix if there is none
+func FromContext(ctx context.Context, prefix string) Logger {
+	if l, ok := ctx.Value(loggerCtxKey{}).(Logger); ok {
+		return l
+	}
+	return GetCurrent().GetLogger(prefix)
+}
@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	serviceName    string
+	serviceVersion string
+)
+
+// SetServiceInfo sets service name and version which are added
+// to every entry of the json format
+func SetServiceInfo(name, version string) {
+	serviceName = name
+	serviceVersion = version
+}
+
+// serviceFormatter wraps the logrus formatters to add service metadata
+// and convert timestamps to UTC
+type serviceFormatter struct {
+	logrus.Formatter
+	withService bool
+	utc         bool
+	// nil doesn't redact
+	redactor *redactor
+}
+
+func newFormatter(format string, utc bool, r *redactor) (logrus.Formatter, error) {
+	switch format {
+	case FormatText:
+		return &serviceFormatter{Formatter: &logrus.TextFormatter{}, utc: utc, redactor: r}, nil
+	case FormatJSON:
+		return &serviceFormatter{
+			Formatter:   &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano},
+			withService: true,
+			utc:         utc,
+			redactor:    r,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, must be text or json", format)
+	}
+}
+
+func (f *serviceFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	e := *entry
+
+	if f.utc {
+		e.Time = e.Time.UTC()
+	}
+
+	if f.redactor != nil {
+		e.Data = f.redactor.fields(e.Data)
+		e.Message = f.redactor.message(e.Message)
+	}
+
+	if f.withService {
+		data := e.Data
+		e.Data = make(logrus.Fields, len(data)+2)
+		for k, v := range data {
+			e.Data[k] = v
+		}
+		e.Data["service"] = serviceName
+		e.Data["version"] = serviceVersion
+	}
+
+	return f.Formatter.Format(&e)
+}
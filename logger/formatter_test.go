@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBufferedLogService(t *testing.T, format string, utc bool) (*stdLogger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info"})
+	s.logger.Out = buf
+	s.logFormat = format
+	s.timestampUTC = utc
+	assert.Nil(t, s.Configure(), "must be nil")
+	return s, buf
+}
+
+func TestJSONFormat(t *testing.T) {
+	SetServiceInfo("orders", "1.2.3")
+	defer SetServiceInfo("", "")
+
+	s, buf := newBufferedLogService(t, FormatJSON, true)
+	s.GetLogger("api").
+		With("request_id", "abc").
+		Withs(Fields{"user": Fields{"id": 1, "roles": []string{"admin"}}}).
+		Info("hello")
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry), "must be nil")
+
+	assert.Equal(t, "orders", entry["service"], "should be equal")
+	assert.Equal(t, "1.2.3", entry["version"], "should be equal")
+	assert.Equal(t, "info", entry["level"], "should be equal")
+	assert.Equal(t, "hello", entry["msg"], "should be equal")
+	assert.Equal(t, "core.api", entry["prefix"], "should be equal")
+	assert.Equal(t, "abc", entry["request_id"], "should be equal")
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "roles": []interface{}{"admin"}}, entry["user"], "nested fields must be json objects")
+
+	ts, err := time.Parse(time.RFC3339Nano, entry["time"].(string))
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, time.UTC, ts.Location(), "should be UTC")
+	assert.True(t, strings.HasSuffix(entry["time"].(string), "Z"), "should be UTC")
+}
+
+func TestJSONFormatSource(t *testing.T) {
+	s, buf := newBufferedLogService(t, FormatJSON, false)
+	assert.Nil(t, s.SetLevel("", "debug"), "must be nil")
+	s.GetLogger("api").Debug("debugging")
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry), "must be nil")
+	assert.Contains(t, entry["source"], "formatter_test.go:", "should contain source")
+}
+
+func TestUnknownFormat(t *testing.T) {
+	s := NewAppLogService(nil)
+	s.logFormat = "xml"
+	assert.NotNil(t, s.Configure(), "should be an error")
+}
@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// prefixLoggers keeps a logrus logger per prefix so each of them
+// can have its own level. They write through the parent logger,
+// so output, formatter and hooks stay shared.
+type prefixLoggers struct {
+	parent  *logrus.Logger
+	writeMu sync.Mutex
+	// > 0 while fatal or panic entries of the slog backend are written
+	syncing atomic.Int32
+
+	mu      sync.RWMutex
+	level   logrus.Level
+	loggers map[string]*logrus.Logger
+	// prefix => level set by flag or SetLevel
+	overrides map[string]logrus.Level
+}
+
+func newPrefixLoggers(parent *logrus.Logger) *prefixLoggers {
+	return &prefixLoggers{
+		parent:    parent,
+		level:     parent.GetLevel(),
+		loggers:   map[string]*logrus.Logger{},
+		overrides: map[string]logrus.Level{},
+	}
+}
+
+// get returns the logger of the prefix, creating it on first use
+func (p *prefixLoggers) get(prefix string) *logrus.Logger {
+	p.mu.RLock()
+	l, ok := p.loggers[prefix]
+	p.mu.RUnlock()
+	if ok {
+		return l
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if l, ok := p.loggers[prefix]; ok {
+		return l
+	}
+
+	l = logrus.New()
+	l.Out = parentWriter{p}
+	l.Formatter = parentFormatter{p}
+	l.Hooks = p.parent.Hooks
+	l.SetLevel(p.levelOf(prefix))
+	p.loggers[prefix] = l
+
+	return l
+}
+
+// levelOf must be called with mu held
+func (p *prefixLoggers) levelOf(prefix string) logrus.Level {
+	if lv, ok := p.overrides[prefix]; ok {
+		return lv
+	}
+	return p.level
+}
+
+// setLevel sets the level of a prefix, empty prefix sets the level
+// of all prefixes without their own level
+func (p *prefixLoggers) setLevel(prefix string, level logrus.Level) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if prefix == "" {
+		p.level = level
+		p.parent.SetLevel(level)
+	} else {
+		p.overrides[prefix] = level
+	}
+
+	for name, l := range p.loggers {
+		l.SetLevel(p.levelOf(name))
+	}
+}
+
+// levels returns the default level under the empty prefix
+// and the prefixes with their own level
+func (p *prefixLoggers) levels() map[string]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := map[string]string{"": p.level.String()}
+	for prefix, lv := range p.overrides {
+		result[prefix] = lv.String()
+	}
+	return result
+}
+
+type parentWriter struct {
+	p *prefixLoggers
+}
+
+func (w parentWriter) Write(b []byte) (int, error) {
+	if w.p.syncing.Load() > 0 {
+		return w.p.writeSync(b)
+	}
+
+	w.p.writeMu.Lock()
+	defer w.p.writeMu.Unlock()
+	return w.p.parent.Out.Write(b)
+}
+
+// writeSync writes b bypassing the buffer of the async output
+func (p *prefixLoggers) writeSync(b []byte) (int, error) {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	if aw, ok := p.parent.Out.(*asyncWriter); ok {
+		return aw.WriteSync(b)
+	}
+	return p.parent.Out.Write(b)
+}
+
+type parentFormatter struct {
+	p *prefixLoggers
+}
+
+func (f parentFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	b, err := f.p.parent.Formatter.Format(entry)
+	if err != nil || entry.Level > logrus.FatalLevel {
+		return b, err
+	}
+
+	// fatal and panic entries are written before exiting, logrus writes nothing more
+	if _, err := f.p.writeSync(b); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// parseLevels parses levels like "gin=debug,otel=warn"
+func parseLevels(s string) (map[string]logrus.Level, error) {
+	levels := map[string]logrus.Level{}
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		prefix, level, ok := strings.Cut(item, "=")
+		if !ok || prefix == "" {
+			return nil, fmt.Errorf("invalid log level %q, must be prefix=level", item)
+		}
+
+		lv, err := logrus.ParseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level of %s: %w", prefix, err)
+		}
+		levels[prefix] = lv
+	}
+
+	return levels, nil
+}
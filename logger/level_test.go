@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info"})
+	s.logger.Out = buf
+	s.logLevels = "gin=debug, otel=error"
+	assert.Nil(t, s.Configure(), "must be nil")
+
+	gin, otel, db := s.GetLogger("gin"), s.GetLogger("otel"), s.GetLogger("db")
+	assert.Equal(t, "debug", gin.GetLevel(), "should be equal")
+	assert.Equal(t, "error", otel.GetLevel(), "should be equal")
+	assert.Equal(t, "info", db.GetLevel(), "should be equal")
+
+	gin.Debug("gin debug")
+	otel.Warn("otel warn")
+	db.Debug("db debug")
+	db.Info("db info")
+
+	out := buf.String()
+	assert.Contains(t, out, "gin debug")
+	assert.NotContains(t, out, "otel warn")
+	assert.NotContains(t, out, "db debug")
+	assert.Contains(t, out, "db info")
+
+	assert.Equal(t, map[string]string{"": "info", "gin": "debug", "otel": "error"}, s.Levels(), "should be equal")
+}
+
+func TestSetLevelAtRuntime(t *testing.T) {
+	s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info"})
+	assert.Nil(t, s.Configure(), "must be nil")
+
+	db := s.GetLogger("db")
+	assert.Nil(t, s.SetLevel("db", "trace"), "must be nil")
+	assert.Equal(t, "trace", db.GetLevel(), "existing loggers must be updated")
+
+	// default level doesn't change prefixes with their own level
+	assert.Nil(t, s.SetLevel("", "warn"), "must be nil")
+	assert.Equal(t, "trace", db.GetLevel(), "should be equal")
+	assert.Equal(t, "warning", s.GetLogger("api").GetLevel(), "should be equal")
+
+	assert.NotNil(t, s.SetLevel("db", "loud"), "should be an error")
+}
+
+func TestSetLevelConcurrently(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info"})
+	s.logger.Out = buf
+	assert.Nil(t, s.Configure(), "must be nil")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.GetLogger("api").Info("hello")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = s.SetLevel("api", "info")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, strings.Count(buf.String(), "hello"), "should be equal")
+}
+
+func TestInvalidLevels(t *testing.T) {
+	s := NewAppLogService(nil)
+	s.logLevels = "gin"
+	assert.NotNil(t, s.Configure(), "should be an error")
+
+	s.logLevels = "gin=loud"
+	assert.NotNil(t, s.Configure(), "should be an error")
+}
@@ -4,11 +4,14 @@
 package logger
 
 import (
+	"context"
 	"fmt"
-	"github.com/sirupsen/logrus"
 	"log"
 	"runtime"
 	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Fields logrus.Fields
@@ -27,6 +30,20 @@ func GetCurrent() ServiceLogger {
 	return currentServLog
 }
 
+// SetCurrent replaces the current logger, e.g. by a recording one in tests
+func SetCurrent(l ServiceLogger) {
+	currentServLog = l
+}
+
+// Flush writes the entries buffered by the current logger with log-async,
+// the service calls it on shutdown
+func Flush() error {
+	if f, ok := currentServLog.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
 type Logger interface {
 	Print(args ...interface{})
 	Debug(...interface{})
@@ -57,6 +74,8 @@ type Logger interface {
 	Withs(Fields) Logger
 	// add source field to log
 	WithSrc() Logger
+	// add trace_id and span_id fields of the active span of ctx
+	WithContext(ctx context.Context) Logger
 	GetLevel() string
 }
 
@@ -65,7 +84,7 @@ type logger struct {
 }
 
 func (l *logger) GetLevel() string {
-	return l.Entry.Logger.Level.String()
+	return l.Entry.Logger.GetLevel().String()
 }
 
 func (l *logger) debugSrc() *logrus.Entry {
@@ -86,26 +105,26 @@ func (l *logger) debugSrc() *logrus.Entry {
 }
 
 func (l *logger) Debug(args ...interface{}) {
-	if l.Entry.Logger.Level >= logrus.DebugLevel {
+	if l.Entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
 		l.debugSrc().Debug(args...)
 	}
 }
 
 func (l *logger) Debugln(args ...interface{}) {
-	if l.Entry.Logger.Level >= logrus.DebugLevel {
+	if l.Entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
 		l.debugSrc().Debugln(args...)
 	}
 }
 
 func (l *logger) Debugf(format string, args ...interface{}) {
-	if l.Entry.Logger.Level >= logrus.DebugLevel {
+	if l.Entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
 		l.debugSrc().Debugf(format, args...)
 	}
 }
 
 func (l *logger) Print(args ...interface{}) {
-	if l.Entry.Logger.Level >= logrus.DebugLevel {
-		l.debugSrc().Debug(args)
+	if l.Entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		l.debugSrc().Debug(args...)
 	}
 }
 
@@ -121,6 +140,18 @@ func (l *logger) WithSrc() Logger {
 	return &logger{l.debugSrc()}
 }
 
+func (l *logger) WithContext(ctx context.Context) Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return l
+	}
+	// the context is also kept for the otel bridge
+	return &logger{l.Entry.WithContext(ctx).WithFields(logrus.Fields{
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	})}
+}
+
 func mustParseLevel(level string) logrus.Level {
 	lv, err := logrus.ParseLevel(level)
 	if err != nil {
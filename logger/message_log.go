@@ -2,6 +2,10 @@ package logger
 
 import (
 	"flag"
+	"io"
+	"os"
+	"time"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,9 +21,17 @@ type messageLogger struct {
 	*stdLogger
 
 	// log error message
-	log Logger
+	log  Logger
+	file *rotateFile
+
 	// flags
-	logPath string
+	logPath    string
+	oldLogPath string
+	maxSizeMB  int
+	maxBackups int
+	maxAgeDays int
+	compress   bool
+	teeStdout  bool
 }
 
 func NewMessageLogService(config *Config) *messageLogger {
@@ -27,8 +39,12 @@ func NewMessageLogService(config *Config) *messageLogger {
 		config = &Config{}
 	}
 
+	// message logs are shipped to remote, json by default
+	if config.DefaultFormat == "" {
+		config.DefaultFormat = FormatJSON
+	}
+
 	appLog := NewAppLogService(config)
-	appLog.logger.Formatter = &logrus.JSONFormatter{}
 
 	newLog := logrus.New()
 	newLog.Formatter = logrus.Formatter(&logrus.TextFormatter{
@@ -48,25 +64,60 @@ func NewMessageLogService(config *Config) *messageLogger {
 func (m *messageLogger) Name() string { return "file-logger" }
 
 func (m *messageLogger) InitFlags() {
-	flag.StringVar(&m.logPath, "logfile", "", "file to write log to. Default write to console")
-	m.stdLogger.InitFlags()
+	m.InitFlagSet(flag.CommandLine)
+}
+
+func (m *messageLogger) InitFlagSet(fs *flag.FlagSet) {
+	fs.StringVar(&m.logPath, "log-file-path", "", "file to write log to. Default write to console")
+	fs.StringVar(&m.oldLogPath, "logfile", "", "deprecated, use log-file-path")
+	fs.IntVar(&m.maxSizeMB, "log-max-size-mb", 100, "log file is rotated when it reaches this size, 0 => never rotated")
+	fs.IntVar(&m.maxBackups, "log-max-backups", 0, "max rotated log files kept, 0 => all")
+	fs.IntVar(&m.maxAgeDays, "log-max-age-days", 0, "rotated log files older than this are removed, 0 => never")
+	fs.BoolVar(&m.compress, "log-compress", false, "gzip rotated log files")
+	fs.BoolVar(&m.teeStdout, "log-stdout", false, "also write log to console when it's written to a file")
+	m.stdLogger.InitFlagSet(fs)
 }
 
 func (m *messageLogger) Configure() error {
+	if err := m.stdLogger.configureFormatter(); err != nil {
+		return err
+	}
+	if err := m.stdLogger.configureLevels(m.stdLogger.logLevel); err != nil {
+		return err
+	}
 
-	if m.logPath == "" {
-		lv := mustParseLevel(m.stdLogger.cfg.DefaultLevel)
-		m.stdLogger.logger.SetLevel(lv)
-		return nil
+	m.configureFile()
+	return m.stdLogger.configureAsync()
+}
+
+func (m *messageLogger) configureFile() {
+	path := m.logPath
+	if path == "" {
+		path = m.oldLogPath
+	}
+	if path == "" || m.file != nil {
+		return
 	}
 
-	out, err := newReloadFile(m.logPath)
+	file, err := newRotateFile(path, int64(m.maxSizeMB)<<20, m.maxBackups, time.Duration(m.maxAgeDays)*24*time.Hour, m.compress)
 	if err != nil {
 		m.log.Fatal("Fail to open log file: ", err.Error())
 	}
-	m.logger.Out = out
+	m.file = file
+
+	if m.teeStdout {
+		m.logger.Out = io.MultiWriter(os.Stdout, file)
+	} else {
+		m.logger.Out = file
+	}
+}
 
-	return nil
+// Reopen reopens the log file moved by logrotate, the service calls it on SIGHUP
+func (m *messageLogger) Reopen() error {
+	if m.file == nil {
+		return nil
+	}
+	return m.file.Reopen()
 }
 
 func (m *messageLogger) Run() error {
@@ -77,8 +128,10 @@ func (m *messageLogger) Stop() <-chan bool {
 	c := make(chan bool)
 
 	go func() {
-		if file, ok := m.logger.Out.(*reloadFile); ok {
-			file.Close()
+		// the buffered entries are written before closing the file
+		m.stdLogger.closeAsync()
+		if m.file != nil {
+			_ = m.file.Close()
 		}
 		c <- true
 	}()
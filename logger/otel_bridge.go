@@ -0,0 +1,172 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+const otelScopeName = "github.com/taimaifika/go-sdk/logger"
+
+// otelHook emits logrus entries to the global otel LoggerProvider, which the otel plugin
+// sets up with the OTLP exporter. Entries of WithContext loggers are correlated to their span.
+type otelHook struct {
+	logger otellog.Logger
+	// nil doesn't redact
+	redactor *redactor
+}
+
+func newOtelHook(r *redactor) *otelHook {
+	return &otelHook{logger: global.GetLoggerProvider().Logger(otelScopeName), redactor: r}
+}
+
+func (h *otelHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *otelHook) Fire(entry *logrus.Entry) error {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	msg, data := entry.Message, entry.Data
+	if h.redactor != nil {
+		msg, data = h.redactor.message(msg), h.redactor.fields(data)
+	}
+
+	var r otellog.Record
+	r.SetTimestamp(entry.Time)
+	r.SetBody(otellog.StringValue(msg))
+	r.SetSeverity(otelSeverity(entry.Level))
+	r.SetSeverityText(entry.Level.String())
+	for k, v := range data {
+		r.AddAttributes(otellog.KeyValue{Key: k, Value: otelValue(v)})
+	}
+
+	h.logger.Emit(ctx, r)
+	return nil
+}
+
+// otelHandler is the otel bridge of the slog backend, records are written by next then emitted
+type otelHandler struct {
+	next     slog.Handler
+	logger   otellog.Logger
+	attrs    []otellog.KeyValue
+	group    string
+	redactor *redactor
+}
+
+func newOtelHandler(next slog.Handler, r *redactor) *otelHandler {
+	return &otelHandler{next: next, logger: global.GetLoggerProvider().Logger(otelScopeName), redactor: r}
+}
+
+func (h *otelHandler) keyValue(a slog.Attr) otellog.KeyValue {
+	v := a.Value.Resolve().Any()
+	if h.redactor != nil {
+		v, _ = h.redactor.field(a.Key, v)
+	}
+	return otellog.KeyValue{Key: h.group + a.Key, Value: otelValue(v)}
+}
+
+func (h *otelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *otelHandler) Handle(ctx context.Context, record slog.Record) error {
+	err := h.next.Handle(ctx, record)
+
+	level := fromSlogLevel(record.Level)
+
+	var r otellog.Record
+	r.SetTimestamp(record.Time)
+	msg := record.Message
+	if h.redactor != nil {
+		msg = h.redactor.message(msg)
+	}
+	r.SetBody(otellog.StringValue(msg))
+	r.SetSeverity(otelSeverity(level))
+	r.SetSeverityText(level.String())
+	r.AddAttributes(h.attrs...)
+	record.Attrs(func(a slog.Attr) bool {
+		r.AddAttributes(h.keyValue(a))
+		return true
+	})
+
+	h.logger.Emit(ctx, r)
+	return err
+}
+
+func (h *otelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	kvs := make([]otellog.KeyValue, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(kvs, h.attrs)
+	for _, a := range attrs {
+		kvs = append(kvs, h.keyValue(a))
+	}
+	return &otelHandler{next: h.next.WithAttrs(attrs), logger: h.logger, attrs: kvs, group: h.group, redactor: h.redactor}
+}
+
+func (h *otelHandler) WithGroup(name string) slog.Handler {
+	return &otelHandler{next: h.next.WithGroup(name), logger: h.logger, attrs: h.attrs, group: h.group + name + ".", redactor: h.redactor}
+}
+
+func otelSeverity(level logrus.Level) otellog.Severity {
+	switch level {
+	case logrus.TraceLevel:
+		return otellog.SeverityTrace
+	case logrus.DebugLevel:
+		return otellog.SeverityDebug
+	case logrus.InfoLevel:
+		return otellog.SeverityInfo
+	case logrus.WarnLevel:
+		return otellog.SeverityWarn
+	case logrus.ErrorLevel:
+		return otellog.SeverityError
+	case logrus.FatalLevel:
+		return otellog.SeverityFatal
+	default:
+		return otellog.SeverityFatal4
+	}
+}
+
+func otelValue(v interface{}) otellog.Value {
+	switch v := v.(type) {
+	case string:
+		return otellog.StringValue(v)
+	case bool:
+		return otellog.BoolValue(v)
+	case int:
+		return otellog.IntValue(v)
+	case int32:
+		return otellog.Int64Value(int64(v))
+	case int64:
+		return otellog.Int64Value(v)
+	case uint32:
+		return otellog.Int64Value(int64(v))
+	case float32:
+		return otellog.Float64Value(float64(v))
+	case float64:
+		return otellog.Float64Value(v)
+	case time.Duration:
+		return otellog.StringValue(v.String())
+	case time.Time:
+		return otellog.StringValue(v.Format(time.RFC3339Nano))
+	case error:
+		return otellog.StringValue(v.Error())
+	case fmt.Stringer:
+		return otellog.StringValue(v.String())
+	case Fields:
+		kvs := make([]otellog.KeyValue, 0, len(v))
+		for k, item := range v {
+			kvs = append(kvs, otellog.KeyValue{Key: k, Value: otelValue(item)})
+		}
+		return otellog.MapValue(kvs...)
+	default:
+		return otellog.StringValue(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type memoryExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *memoryExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error   { return nil }
+func (e *memoryExporter) ForceFlush(context.Context) error { return nil }
+
+func TestWithContext(t *testing.T) {
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	for _, backend := range []string{BackendLogrus, BackendSlog} {
+		buf := &bytes.Buffer{}
+		s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info", DefaultBackend: backend})
+		s.logger.Out = buf
+		s.logFormat = FormatJSON
+		assert.Nil(t, s.Configure(), "must be nil")
+
+		api := s.GetLogger("api")
+		assert.Equal(t, api, api.WithContext(context.Background()), "no span is a no-op")
+
+		api.WithContext(ctx).Info("hello")
+		var entry map[string]interface{}
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry), "must be nil")
+		assert.Equal(t, span.SpanContext().TraceID().String(), entry["trace_id"], "should be equal")
+		assert.Equal(t, span.SpanContext().SpanID().String(), entry["span_id"], "should be equal")
+	}
+}
+
+func TestOtelBridge(t *testing.T) {
+	exporter := &memoryExporter{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	global.SetLoggerProvider(provider)
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	for _, backend := range []string{BackendLogrus, BackendSlog} {
+		exporter.records = nil
+
+		s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info", DefaultBackend: backend})
+		s.logger.Out = &bytes.Buffer{}
+		s.otelBridge = true
+		assert.Nil(t, s.Configure(), "must be nil")
+
+		s.GetLogger("api").With("order_id", 7).WithContext(ctx).Warn("late")
+		s.GetLogger("api").Debug("not logged")
+
+		assert.Equal(t, 1, len(exporter.records), "should be equal")
+		r := exporter.records[0]
+		assert.Equal(t, "late", r.Body().AsString(), "should be equal")
+		assert.Equal(t, otellog.SeverityWarn, r.Severity(), "should be equal")
+		assert.Equal(t, span.SpanContext().TraceID(), r.TraceID(), "should be correlated")
+
+		attrs := map[string]otellog.Value{}
+		r.WalkAttributes(func(kv otellog.KeyValue) bool {
+			attrs[kv.Key] = kv.Value
+			return true
+		})
+		assert.Equal(t, int64(7), attrs["order_id"].AsInt64(), "should be equal")
+		assert.Equal(t, "core.api", attrs["prefix"].AsString(), "should be equal")
+	}
+}
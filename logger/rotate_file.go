@@ -0,0 +1,234 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotateFile is a log file rotated when it reaches maxSize bytes, the rotated
+// files are renamed with their rotation time, e.g. app-2024-01-02T15-04-05.000.log.
+// Reopen reopens the path for logrotate managed files.
+type rotateFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// compressing and removing backups runs in background, one at a time
+	millMu sync.Mutex
+	millWg sync.WaitGroup
+}
+
+// newRotateFile opens path, maxSize 0 => never rotated,
+// maxBackups and maxAge 0 => backups are kept
+func newRotateFile(path string, maxSize int64, maxBackups int, maxAge time.Duration, compress bool) (*rotateFile, error) {
+	r := &rotateFile{path: path, maxSize: maxSize, maxBackups: maxBackups, maxAge: maxAge, compress: compress}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open must be called with mu held
+func (r *rotateFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if r.file != nil {
+		_ = r.file.Close()
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotateFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate must be called with mu held
+func (r *rotateFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	// rotations of the same millisecond don't overwrite each other
+	now := time.Now()
+	backupName := r.backupName(now)
+	for fileExists(backupName) || fileExists(backupName+".gz") {
+		now = now.Add(time.Millisecond)
+		backupName = r.backupName(now)
+	}
+
+	if err := os.Rename(r.path, backupName); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.millWg.Add(1)
+	go func() {
+		defer r.millWg.Done()
+		r.mill()
+	}()
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (r *rotateFile) backupName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	return strings.TrimSuffix(r.path, ext) + "-" + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// Reopen reopens the path after it was moved by logrotate
+func (r *rotateFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.open()
+}
+
+func (r *rotateFile) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+
+	r.millWg.Wait()
+	return err
+}
+
+type backup struct {
+	path string
+	time time.Time
+}
+
+// mill compresses the backups and removes the ones over maxBackups or older than maxAge
+func (r *rotateFile) mill() {
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+
+	backups, err := r.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list log backups: %s\n", err)
+		return
+	}
+
+	for i, b := range backups {
+		expired := (r.maxBackups > 0 && i >= r.maxBackups) || (r.maxAge > 0 && time.Since(b.time) > r.maxAge)
+		if expired {
+			if err := os.Remove(b.path); err != nil {
+				fmt.Fprintf(os.Stderr, "remove log backup: %s\n", err)
+			}
+			continue
+		}
+
+		if r.compress && !strings.HasSuffix(b.path, ".gz") {
+			if err := compressFile(b.path); err != nil {
+				fmt.Fprintf(os.Stderr, "compress log backup: %s\n", err)
+			}
+		}
+	}
+}
+
+// backups returns the backups of the file, newest first
+func (r *rotateFile) backups() ([]backup, error) {
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return nil, err
+	}
+
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+
+	var backups []backup
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gz")
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(filepath.Dir(r.path), e.Name()), time: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time.After(backups[j].time) })
+	return backups, nil
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	err = errors.Join(err, gz.Close(), dst.Close())
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateFileConcurrentWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := newRotateFile(path, 1000, 0, 0, false)
+	assert.Nil(t, err, "must be nil")
+
+	line := strings.Repeat("x", 99) + "\n"
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_, err := r.Write([]byte(line))
+				assert.Nil(t, err, "must be nil")
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, r.Close(), "must be nil")
+
+	// every line is written once, files don't exceed the max size
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "app*.log"))
+	total := 0
+	for _, f := range files {
+		b, _ := os.ReadFile(f)
+		assert.LessOrEqual(t, len(b), 1000, "should not exceed max size")
+		total += strings.Count(string(b), line)
+	}
+	assert.Equal(t, 200, total, "should be equal")
+	assert.Greater(t, len(files), 1, "should be rotated")
+}
+
+func TestRotateFileRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// an expired backup
+	old := filepath.Join(dir, "app-"+time.Now().Add(-48*time.Hour).UTC().Format(backupTimeFormat)+".log")
+	assert.Nil(t, os.WriteFile(old, []byte("old"), 0644), "must be nil")
+
+	r, err := newRotateFile(path, 10, 2, 24*time.Hour, true)
+	assert.Nil(t, err, "must be nil")
+	for i := 0; i < 4; i++ {
+		_, err = r.Write([]byte("0123456789"))
+		assert.Nil(t, err, "must be nil")
+	}
+	assert.Nil(t, r.Close(), "must be nil")
+
+	backups, err := r.backups()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, 2, len(backups), "should keep max backups")
+	for _, b := range backups {
+		assert.True(t, strings.HasSuffix(b.path, ".log.gz"), "should be compressed")
+	}
+	_, err = os.Stat(old)
+	assert.True(t, os.IsNotExist(err), "expired backup should be removed")
+}
+
+func TestRotateFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	r, err := newRotateFile(path, 0, 0, 0, false)
+	assert.Nil(t, err, "must be nil")
+	defer r.Close()
+
+	_, _ = r.Write([]byte("before\n"))
+	// logrotate moves the file then sends SIGHUP
+	assert.Nil(t, os.Rename(path, path+".1"), "must be nil")
+	assert.Nil(t, r.Reopen(), "must be nil")
+	_, _ = r.Write([]byte("after\n"))
+
+	b, _ := os.ReadFile(path)
+	assert.Equal(t, "after\n", string(b), "should be equal")
+	b, _ = os.ReadFile(path + ".1")
+	assert.Equal(t, "before\n", string(b), "should be equal")
+}
@@ -2,6 +2,8 @@ package logger
 
 import (
 	"flag"
+	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -16,20 +18,44 @@ var (
 )
 
 type Config struct {
-	DefaultLevel string
-	BasePrefix   string
+	DefaultLevel  string
+	BasePrefix    string
+	DefaultFormat string
+	// DefaultBackend is logrus when empty
+	DefaultBackend string
 }
 
 type ServiceLogger interface {
 	GetLogger(prefix string) Logger
+	// SetLevel changes the level of loggers of the prefix at runtime,
+	// empty prefix changes the default level
+	SetLevel(prefix, level string) error
+	// Levels returns the default level under the empty prefix
+	// and the prefixes with their own level
+	Levels() map[string]string
 }
 
 // A default app logger
 // Just write everything to console
 type stdLogger struct {
-	logger   *logrus.Logger
-	cfg      Config
-	logLevel string
+	logger       *logrus.Logger
+	cfg          Config
+	logLevel     string
+	logFormat    string
+	timestampUTC bool
+	logLevels    string
+	logBackend   string
+	otelBridge   bool
+	redactKeys   string
+	redactValues bool
+	redactor     *redactor
+	otelHook     *otelHook
+	prefixes     *prefixLoggers
+	slogHandler  slog.Handler
+	asyncLog     bool
+	asyncBuffer  int
+	asyncPolicy  string
+	async        *asyncWriter
 }
 
 func NewAppLogService(config *Config) *stdLogger {
@@ -43,6 +69,14 @@ func NewAppLogService(config *Config) *stdLogger {
 		config.DefaultLevel = "info"
 	}
 
+	if config.DefaultFormat == "" {
+		config.DefaultFormat = FormatText
+	}
+
+	if config.DefaultBackend == "" {
+		config.DefaultBackend = BackendLogrus
+	}
+
 	logger := logrus.New()
 	// logger.Formatter = logrus.Formatter(&prefixed.TextFormatter{
 	// 	FullTimestamp:   true,
@@ -50,21 +84,35 @@ func NewAppLogService(config *Config) *stdLogger {
 	// })
 
 	return &stdLogger{
-		logger:   logger,
-		cfg:      *config,
-		logLevel: config.DefaultLevel,
+		logger:     logger,
+		cfg:        *config,
+		logLevel:   config.DefaultLevel,
+		logFormat:  config.DefaultFormat,
+		logBackend: config.DefaultBackend,
+		prefixes:   newPrefixLoggers(logger),
 	}
 }
 
 func (s *stdLogger) GetLogger(prefix string) Logger {
 	var entry *logrus.Entry
 
+	pl := s.prefixes.get(prefix)
 	prefix = s.cfg.BasePrefix + "." + prefix
 	prefix = strings.Trim(prefix, ".")
+
+	// loggers got before Configure use logrus
+	if s.slogHandler != nil {
+		handler := s.slogHandler
+		if prefix != "" {
+			handler = handler.WithAttrs([]slog.Attr{slog.String("prefix", prefix)})
+		}
+		return &slogLogger{handler: handler, levels: pl}
+	}
+
 	if prefix == "" {
-		entry = logrus.NewEntry(s.logger)
+		entry = logrus.NewEntry(pl)
 	} else {
-		entry = s.logger.WithField("prefix", prefix)
+		entry = pl.WithField("prefix", prefix)
 	}
 
 	l := &logger{entry}
@@ -73,21 +121,164 @@ func (s *stdLogger) GetLogger(prefix string) Logger {
 	return log
 }
 
+// Redact redacts data like the fields of the entries, it uses the default
+// keys until the logger is configured
+func (s *stdLogger) Redact(data Fields) Fields {
+	return Fields(s.currentRedactor().fields(logrus.Fields(data)))
+}
+
+// IsSensitiveKey tells whether the values of key are redacted, see Redact
+func (s *stdLogger) IsSensitiveKey(key string) bool {
+	return s.currentRedactor().sensitive(key)
+}
+
+func (s *stdLogger) currentRedactor() *redactor {
+	if s.redactor == nil {
+		return newRedactor(s.redactKeys, false)
+	}
+	return s.redactor
+}
+
 // Implement Runnable interface
 func (s *stdLogger) Name() string { return "file-logger" }
 func (s *stdLogger) InitFlags() {
-	flag.StringVar(&s.logLevel, "log-level", s.cfg.DefaultLevel, "Log level: panic | fatal | error | warn | info | debug | trace")
+	s.InitFlagSet(flag.CommandLine)
+}
+
+func (s *stdLogger) InitFlagSet(fs *flag.FlagSet) {
+	fs.StringVar(&s.logLevel, "log-level", s.cfg.DefaultLevel, "Log level: panic | fatal | error | warn | info | debug | trace")
+	fs.StringVar(&s.logLevels, "log-levels", "", "Log levels of prefixed loggers, overriding log-level. Ex: gin=debug,otel=warn")
+	fs.StringVar(&s.logFormat, "log-format", s.cfg.DefaultFormat, "Log format: text | json")
+	fs.BoolVar(&s.timestampUTC, "log-timestamp-utc", false, "log timestamps in UTC")
+	fs.BoolVar(&s.otelBridge, "log-otel-bridge", false, "also send log entries to the OTLP endpoint of the otel plugin")
+	fs.StringVar(&s.logBackend, "log-backend", s.cfg.DefaultBackend, "Log backend: logrus | slog. slog allocates less with fields")
+	fs.StringVar(&s.redactKeys, "log-redact-keys", "", "Field names redacted in addition to "+strings.Join(defaultRedactKeys, ",")+" and the names ending with "+connectionKeySuffix+", comma separated")
+	fs.BoolVar(&s.redactValues, "log-redact-values", false, "Redact bearer tokens and card numbers in messages and string fields, it costs a regex per entry")
+	fs.BoolVar(&s.asyncLog, "log-async", false, "write log entries from a buffer in background, fatal and panic entries are written synchronously")
+	fs.IntVar(&s.asyncBuffer, "log-async-buffer", 8192, "max log entries buffered by log-async")
+	fs.StringVar(&s.asyncPolicy, "log-async-policy", AsyncPolicyBlock, "when the log-async buffer is full: block | drop. Dropped entries are counted and reported")
 }
 func (s *stdLogger) Configure() error {
-	lv := mustParseLevel(s.logLevel)
-	s.logger.SetLevel(lv)
+	if err := s.configureLevels(s.logLevel); err != nil {
+		return err
+	}
+	if err := s.configureFormatter(); err != nil {
+		return err
+	}
+	return s.configureAsync()
+}
+
+func (s *stdLogger) configureLevels(level string) error {
+	lv := mustParseLevel(level)
+	s.prefixes.setLevel("", lv)
+
+	levels, err := parseLevels(s.logLevels)
+	if err != nil {
+		return err
+	}
+
+	for prefix, lv := range levels {
+		s.prefixes.setLevel(prefix, lv)
+	}
+
+	return nil
+}
+
+func (s *stdLogger) SetLevel(prefix, level string) error {
+	lv, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	s.prefixes.setLevel(prefix, lv)
+	return nil
+}
+
+func (s *stdLogger) Levels() map[string]string {
+	return s.prefixes.levels()
+}
+
+func (s *stdLogger) configureFormatter() error {
+	s.redactor = newRedactor(s.redactKeys, s.redactValues)
+
+	formatter, err := newFormatter(s.logFormat, s.timestampUTC, s.redactor)
+	if err != nil {
+		return err
+	}
+	s.logger.SetFormatter(formatter)
+
+	switch s.logBackend {
+	case BackendLogrus:
+		s.slogHandler = nil
+	case BackendSlog:
+		handler, err := newSlogHandler(s.prefixes, s.logFormat, s.timestampUTC, s.redactor)
+		if err != nil {
+			return err
+		}
+		if s.otelBridge {
+			handler = newOtelHandler(handler, s.redactor)
+		}
+		s.slogHandler = handler
+	default:
+		return fmt.Errorf("unknown log backend %q, must be logrus or slog", s.logBackend)
+	}
+
+	// prefix loggers share the hooks of the parent
+	if s.otelBridge && s.otelHook == nil {
+		s.otelHook = newOtelHook(s.redactor)
+		s.logger.AddHook(s.otelHook)
+	} else if s.otelHook != nil {
+		s.otelHook.redactor = s.redactor
+	}
+	return nil
+}
+
+// configureAsync must be called once the output is set
+func (s *stdLogger) configureAsync() error {
+	if !s.asyncLog || s.async != nil {
+		return nil
+	}
+
+	log := s.GetLogger("logger")
+	async, err := newAsyncWriter(s.logger.Out, s.asyncBuffer, s.asyncPolicy, func(n uint64) {
+		log.Warnf("%d log entries dropped, the log-async buffer is full", n)
+	})
+	if err != nil {
+		return err
+	}
+	s.async = async
+	s.logger.Out = async
+	return nil
+}
+
+// Flush writes the entries buffered by log-async
+func (s *stdLogger) Flush() error {
+	if s.async != nil {
+		s.async.Flush()
+	}
 	return nil
 }
 
+// closeAsync writes the buffered entries and restores the output
+func (s *stdLogger) closeAsync() {
+	if s.async == nil {
+		return
+	}
+	_ = s.async.Close()
+
+	s.prefixes.writeMu.Lock()
+	s.logger.Out = s.async.out
+	s.prefixes.writeMu.Unlock()
+	s.async = nil
+}
+
 func (s *stdLogger) Run() error { return s.Configure() }
 func (s *stdLogger) Stop() <-chan bool {
 	c := make(chan bool)
-	go func() { c <- true }()
+	go func() {
+		s.closeAsync()
+		c <- true
+	}()
 
 	return c
 }
@@ -0,0 +1,331 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	BackendLogrus = "logrus"
+	BackendSlog   = "slog"
+)
+
+// slog levels of the logrus levels slog doesn't have
+const (
+	slogLevelTrace = slog.LevelDebug - 4
+	slogLevelFatal = slog.LevelError + 4
+	slogLevelPanic = slog.LevelError + 8
+)
+
+func toSlogLevel(lv logrus.Level) slog.Level {
+	switch lv {
+	case logrus.TraceLevel:
+		return slogLevelTrace
+	case logrus.DebugLevel:
+		return slog.LevelDebug
+	case logrus.InfoLevel:
+		return slog.LevelInfo
+	case logrus.WarnLevel:
+		return slog.LevelWarn
+	case logrus.ErrorLevel:
+		return slog.LevelError
+	case logrus.FatalLevel:
+		return slogLevelFatal
+	default:
+		return slogLevelPanic
+	}
+}
+
+func fromSlogLevel(lv slog.Level) logrus.Level {
+	switch {
+	case lv <= slogLevelTrace:
+		return logrus.TraceLevel
+	case lv <= slog.LevelDebug:
+		return logrus.DebugLevel
+	case lv <= slog.LevelInfo:
+		return logrus.InfoLevel
+	case lv <= slog.LevelWarn:
+		return logrus.WarnLevel
+	case lv <= slog.LevelError:
+		return logrus.ErrorLevel
+	case lv <= slogLevelFatal:
+		return logrus.FatalLevel
+	default:
+		return logrus.PanicLevel
+	}
+}
+
+// newSlogHandler writes through the parent logrus logger output, so the log file
+// and its reload are shared with the logrus backend. Levels are checked by slogLogger.
+func newSlogHandler(p *prefixLoggers, format string, utc bool, r *redactor) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slogLevelTrace,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if r != nil {
+				a = redactAttr(r, groups, a)
+			}
+			if len(groups) > 0 {
+				return a
+			}
+
+			switch a.Key {
+			case slog.LevelKey:
+				return slog.String(slog.LevelKey, fromSlogLevel(a.Value.Any().(slog.Level)).String())
+			case slog.TimeKey:
+				if utc {
+					return slog.Time(slog.TimeKey, a.Value.Time().UTC())
+				}
+			case slog.SourceKey:
+				// only debug entries and WithSrc loggers have a source, like the logrus backend
+				src, ok := a.Value.Any().(*slog.Source)
+				if !ok || src.File == "" {
+					return slog.Attr{}
+				}
+				return slog.String(slog.SourceKey, filepath.Base(src.File)+":"+strconv.Itoa(src.Line))
+			}
+			return a
+		},
+	}
+
+	switch format {
+	case FormatText:
+		return slog.NewTextHandler(parentWriter{p}, opts), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(parentWriter{p}, opts).WithAttrs([]slog.Attr{
+			slog.String("service", serviceName),
+			slog.String("version", serviceVersion),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, must be text or json", format)
+	}
+}
+
+// redactAttr redacts the message and the attrs, the other builtin attrs are kept
+func redactAttr(r *redactor, groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch a.Key {
+		case slog.MessageKey:
+			return slog.String(a.Key, r.message(a.Value.String()))
+		case slog.TimeKey, slog.LevelKey, slog.SourceKey:
+			return a
+		}
+	}
+
+	if r.sensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	if k := a.Value.Kind(); k == slog.KindAny || k == slog.KindString {
+		if v, ok := r.value(a.Value.Any(), 0); ok {
+			return slog.Any(a.Key, v)
+		}
+	}
+	return a
+}
+
+// slogLogger is the Logger of the slog backend. The level of its prefix
+// is kept by the logrus logger of the prefix, so SetLevel works for both backends.
+type slogLogger struct {
+	handler slog.Handler
+	levels  *logrus.Logger
+	// call site of WithSrc, 0 => source is only added to debug entries
+	srcPC uintptr
+	// set by WithContext for the otel bridge
+	ctx context.Context
+}
+
+func (l *slogLogger) GetLevel() string {
+	return l.levels.GetLevel().String()
+}
+
+// log must be called by the Logger methods only, the source is their caller
+func (l *slogLogger) log(level logrus.Level, msg string) {
+	pc := l.srcPC
+	if pc == 0 && level == logrus.DebugLevel {
+		var pcs [1]uintptr
+		// skip runtime.Callers, log and the Logger method
+		runtime.Callers(3, pcs[:])
+		pc = pcs[0]
+	}
+
+	ctx := l.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	r := slog.NewRecord(time.Now(), toSlogLevel(level), msg, pc)
+	if w, ok := l.levels.Out.(parentWriter); ok && level <= logrus.FatalLevel {
+		// fatal and panic entries bypass the async buffer
+		w.p.syncing.Add(1)
+		_ = l.handler.Handle(ctx, r)
+		w.p.syncing.Add(-1)
+	} else {
+		_ = l.handler.Handle(ctx, r)
+	}
+
+	switch level {
+	case logrus.FatalLevel:
+		l.levels.Exit(1)
+	case logrus.PanicLevel:
+		panic(msg)
+	}
+}
+
+func sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+func (l *slogLogger) Print(args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.DebugLevel) {
+		l.log(logrus.DebugLevel, fmt.Sprint(args...))
+	}
+}
+
+func (l *slogLogger) Debug(args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.DebugLevel) {
+		l.log(logrus.DebugLevel, fmt.Sprint(args...))
+	}
+}
+
+func (l *slogLogger) Debugln(args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.DebugLevel) {
+		l.log(logrus.DebugLevel, sprintln(args...))
+	}
+}
+
+func (l *slogLogger) Debugf(format string, args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.DebugLevel) {
+		l.log(logrus.DebugLevel, fmt.Sprintf(format, args...))
+	}
+}
+
+func (l *slogLogger) Info(args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.InfoLevel) {
+		l.log(logrus.InfoLevel, fmt.Sprint(args...))
+	}
+}
+
+func (l *slogLogger) Infoln(args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.InfoLevel) {
+		l.log(logrus.InfoLevel, sprintln(args...))
+	}
+}
+
+func (l *slogLogger) Infof(format string, args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.InfoLevel) {
+		l.log(logrus.InfoLevel, fmt.Sprintf(format, args...))
+	}
+}
+
+func (l *slogLogger) Warn(args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.WarnLevel) {
+		l.log(logrus.WarnLevel, fmt.Sprint(args...))
+	}
+}
+
+func (l *slogLogger) Warnln(args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.WarnLevel) {
+		l.log(logrus.WarnLevel, sprintln(args...))
+	}
+}
+
+func (l *slogLogger) Warnf(format string, args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.WarnLevel) {
+		l.log(logrus.WarnLevel, fmt.Sprintf(format, args...))
+	}
+}
+
+func (l *slogLogger) Error(args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.ErrorLevel) {
+		l.log(logrus.ErrorLevel, fmt.Sprint(args...))
+	}
+}
+
+func (l *slogLogger) Errorln(args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.ErrorLevel) {
+		l.log(logrus.ErrorLevel, sprintln(args...))
+	}
+}
+
+func (l *slogLogger) Errorf(format string, args ...interface{}) {
+	if l.levels.IsLevelEnabled(logrus.ErrorLevel) {
+		l.log(logrus.ErrorLevel, fmt.Sprintf(format, args...))
+	}
+}
+
+// Fatal and Panic log whatever the level, like logrus
+func (l *slogLogger) Fatal(args ...interface{}) {
+	l.log(logrus.FatalLevel, fmt.Sprint(args...))
+}
+
+func (l *slogLogger) Fatalln(args ...interface{}) {
+	l.log(logrus.FatalLevel, sprintln(args...))
+}
+
+func (l *slogLogger) Fatalf(format string, args ...interface{}) {
+	l.log(logrus.FatalLevel, fmt.Sprintf(format, args...))
+}
+
+func (l *slogLogger) Panic(args ...interface{}) {
+	l.log(logrus.PanicLevel, fmt.Sprint(args...))
+}
+
+func (l *slogLogger) Panicln(args ...interface{}) {
+	l.log(logrus.PanicLevel, sprintln(args...))
+}
+
+func (l *slogLogger) Panicf(format string, args ...interface{}) {
+	l.log(logrus.PanicLevel, fmt.Sprintf(format, args...))
+}
+
+func (l *slogLogger) With(key string, value interface{}) Logger {
+	return &slogLogger{
+		handler: l.handler.WithAttrs([]slog.Attr{slog.Any(key, value)}),
+		levels:  l.levels,
+		srcPC:   l.srcPC,
+		ctx:     l.ctx,
+	}
+}
+
+func (l *slogLogger) Withs(fields Fields) Logger {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for k, v := range fields {
+		attrs = append(attrs, slog.Any(k, v))
+	}
+	return &slogLogger{handler: l.handler.WithAttrs(attrs), levels: l.levels, srcPC: l.srcPC, ctx: l.ctx}
+}
+
+func (l *slogLogger) WithSrc() Logger {
+	if l.srcPC != 0 {
+		return l
+	}
+
+	var pcs [1]uintptr
+	// skip runtime.Callers and WithSrc
+	runtime.Callers(2, pcs[:])
+	return &slogLogger{handler: l.handler, levels: l.levels, srcPC: pcs[0], ctx: l.ctx}
+}
+
+func (l *slogLogger) WithContext(ctx context.Context) Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return l
+	}
+	return &slogLogger{
+		handler: l.handler.WithAttrs([]slog.Attr{
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		}),
+		levels: l.levels,
+		srcPC:  l.srcPC,
+		ctx:    ctx,
+	}
+}
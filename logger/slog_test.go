@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBufferedSlogService(t *testing.T, format string) (*stdLogger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info", DefaultBackend: BackendSlog})
+	s.logger.Out = buf
+	s.logFormat = format
+	assert.Nil(t, s.Configure(), "must be nil")
+	return s, buf
+}
+
+func TestSlogJSON(t *testing.T) {
+	SetServiceInfo("orders", "1.2.3")
+	defer SetServiceInfo("", "")
+
+	s, buf := newBufferedSlogService(t, FormatJSON)
+	api := s.GetLogger("api")
+	assert.IsType(t, &slogLogger{}, api, "should be equal")
+
+	api.With("request_id", "abc").
+		Withs(Fields{"user": Fields{"id": 1}}).
+		Warnf("hello %s", "john")
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry), "must be nil")
+	assert.Equal(t, "orders", entry["service"], "should be equal")
+	assert.Equal(t, "warning", entry["level"], "should be equal")
+	assert.Equal(t, "hello john", entry["msg"], "should be equal")
+	assert.Equal(t, "core.api", entry["prefix"], "should be equal")
+	assert.Equal(t, "abc", entry["request_id"], "should be equal")
+	assert.Equal(t, map[string]interface{}{"id": float64(1)}, entry["user"], "should be equal")
+	assert.Nil(t, entry["source"], "only debug entries have a source")
+}
+
+func TestSlogSource(t *testing.T) {
+	s, buf := newBufferedSlogService(t, FormatJSON)
+	assert.Nil(t, s.SetLevel("api", "debug"), "must be nil")
+
+	s.GetLogger("api").Debug("debugging")
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry), "must be nil")
+	assert.Contains(t, entry["source"], "slog_test.go:", "should contain source")
+
+	buf.Reset()
+	s.GetLogger("api").WithSrc().Info("with source")
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry), "must be nil")
+	assert.Contains(t, entry["source"], "slog_test.go:", "should contain source")
+}
+
+func TestSlogLevels(t *testing.T) {
+	s, buf := newBufferedSlogService(t, FormatText)
+
+	db := s.GetLogger("db")
+	assert.Equal(t, "info", db.GetLevel(), "should be equal")
+	db.Debug("db debug")
+	db.Infoln("db", "info")
+
+	assert.Nil(t, s.SetLevel("db", "error"), "must be nil")
+	assert.Equal(t, "error", db.GetLevel(), "existing loggers must be updated")
+	db.Warn("db warn")
+
+	out := buf.String()
+	assert.NotContains(t, out, "db debug")
+	assert.Contains(t, out, `level=info msg="db info" prefix=core.db`)
+	assert.NotContains(t, out, "db warn")
+
+	assert.Panics(t, func() { db.Panic("boom") }, "should panic")
+	assert.Contains(t, buf.String(), "level=panic msg=boom")
+}
+
+func TestUnknownBackend(t *testing.T) {
+	s := NewAppLogService(nil)
+	s.logBackend = "zap"
+	assert.NotNil(t, s.Configure(), "should be an error")
+}
+
+func benchmarkFields(b *testing.B, backend string) {
+	s := NewAppLogService(&Config{BasePrefix: "core", DefaultLevel: "info", DefaultBackend: backend})
+	s.logger.Out = io.Discard
+	s.logFormat = FormatJSON
+	if err := s.Configure(); err != nil {
+		b.Fatal(err)
+	}
+	l := s.GetLogger("api")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Withs(Fields{"method": "GET", "status": 200, "latency_ms": 1.5}).Info("request")
+	}
+}
+
+func BenchmarkLogrusFields(b *testing.B) { benchmarkFields(b, BackendLogrus) }
+
+func BenchmarkSlogFields(b *testing.B) { benchmarkFields(b, BackendSlog) }
+
+func TestSlogText(t *testing.T) {
+	s, buf := newBufferedSlogService(t, FormatText)
+	s.GetLogger("").Error("oops")
+	assert.True(t, strings.HasPrefix(buf.String(), "time="), "should be key=value")
+	assert.Contains(t, buf.String(), "level=error msg=oops")
+}
@@ -0,0 +1,149 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type check struct {
+	HTTP                           string `json:"HTTP"`
+	Interval                       string `json:"Interval"`
+	Timeout                        string `json:"Timeout"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+type registration struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   *check            `json:"Check,omitempty"`
+}
+
+// serviceEntry is an entry of the health endpoint
+type serviceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Tags    []string          `json:"Tags"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+}
+
+// agent calls the HTTP API of the Consul agent
+type agent struct {
+	address string
+	token   string
+	timeout time.Duration
+	client  *http.Client
+}
+
+func (a *agent) register(ctx context.Context, reg registration) error {
+	_, err := a.do(ctx, http.MethodPut, "agent/service/register", nil, reg, nil, a.timeout)
+	return err
+}
+
+func (a *agent) deregister(ctx context.Context, id string) error {
+	_, err := a.do(ctx, http.MethodPut, "agent/service/deregister/"+url.PathEscape(id), nil, nil, nil, a.timeout)
+	return err
+}
+
+// healthyInstances returns the instances of service passing their checks.
+// With a positive index, it's a blocking query returning once the index
+// changes or after wait.
+func (a *agent) healthyInstances(ctx context.Context, service string, index uint64, wait time.Duration) ([]Instance, uint64, error) {
+	query := url.Values{"passing": {"1"}}
+	timeout := a.timeout
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", wait.String())
+		// the agent adds up to wait/16 of jitter
+		timeout += wait + wait/16
+	}
+
+	var entries []serviceEntry
+	header, err := a.do(ctx, http.MethodGet, "health/service/"+url.PathEscape(service), query, nil, &entries, timeout)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	index, _ = strconv.ParseUint(header.Get("X-Consul-Index"), 10, 64)
+
+	instances := make([]Instance, 0, len(entries))
+	for _, e := range entries {
+		address := e.Service.Address
+		if address == "" {
+			address = e.Node.Address
+		}
+		instances = append(instances, Instance{
+			ID:      e.Service.ID,
+			Address: address,
+			Port:    e.Service.Port,
+			Tags:    e.Service.Tags,
+			Meta:    e.Service.Meta,
+		})
+	}
+	return instances, index, nil
+}
+
+// do sends a request of the HTTP API and returns the response headers
+func (a *agent) do(ctx context.Context, method, path string, query url.Values, in, out interface{}, timeout time.Duration) (http.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body io.Reader = http.NoBody
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	u := a.address + "/v1/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.token != "" {
+		req.Header.Set("X-Consul-Token", a.token)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Consul %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		// errors are plain text
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		if s := strings.TrimSpace(string(msg)); s != "" {
+			return nil, fmt.Errorf("Consul %s %s: %s: %s", method, path, resp.Status, s)
+		}
+		return nil, fmt.Errorf("Consul %s %s: %s", method, path, resp.Status)
+	}
+
+	if out == nil {
+		return resp.Header, nil
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
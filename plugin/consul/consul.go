@@ -0,0 +1,330 @@
+package consul
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+var (
+	defaultCheckPath       = "/healthz"
+	defaultCheckInterval   = 10 * time.Second
+	defaultCheckTimeout    = 5 * time.Second
+	defaultDeregisterAfter = time.Minute
+	defaultRegisterRetries = 5
+	defaultRetryBackoff    = time.Second
+	defaultTimeout         = 10 * time.Second
+	// backoff of registrations and watches doesn't grow past it
+	maxRetryBackoff = 30 * time.Second
+	// how long a blocking query of a watch waits for a change
+	watchWait = 5 * time.Minute
+)
+
+var errDisabled = errors.New("consul plugin is disabled, the Consul address is not set")
+
+type consulPlugin struct {
+	name   string
+	prefix string
+	logger logger.Logger
+
+	address         string
+	token           string
+	tlsCAFile       string
+	tlsCertFile     string
+	tlsKeyFile      string
+	tlsSkipVerify   bool
+	serviceName     string
+	serviceAddress  string
+	tags            string
+	checkPath       string
+	checkInterval   time.Duration
+	checkTimeout    time.Duration
+	deregisterAfter time.Duration
+	registerRetries int
+	retryBackoff    time.Duration
+	timeout         time.Duration
+
+	agent *agent
+
+	mu        sync.Mutex
+	serviceID string
+	resolvers map[string]*resolver
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// New registers the service to the Consul agent once the http server listens on
+// its final port, with an http check of the health endpoint. It's deregistered
+// before the http server drains. Get returns a Discovery resolving other services.
+func New(name, prefix string) *consulPlugin {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &consulPlugin{
+		name:      name,
+		prefix:    prefix,
+		resolvers: map[string]*resolver{},
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+func (cp *consulPlugin) Name() string {
+	return cp.name
+}
+
+func (cp *consulPlugin) GetPrefix() string {
+	return cp.prefix
+}
+
+func (cp *consulPlugin) Get() interface{} {
+	return Discovery(cp)
+}
+
+func (cp *consulPlugin) InitFlags() {
+	cp.InitFlagSet(flag.CommandLine)
+}
+
+func (cp *consulPlugin) InitFlagSet(fs *flag.FlagSet) {
+	prefix := cp.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&cp.address, prefix+"address", os.Getenv("CONSUL_HTTP_ADDR"), "Consul agent address, ex: http://127.0.0.1:8500, empty => disabled, default is CONSUL_HTTP_ADDR")
+	fs.StringVar(&cp.token, prefix+"token", os.Getenv("CONSUL_HTTP_TOKEN"), "ACL token, default is CONSUL_HTTP_TOKEN")
+	fs.StringVar(&cp.tlsCAFile, prefix+"tls-ca-file", "", "CA certificate of the agent, default is the system pool")
+	fs.StringVar(&cp.tlsCertFile, prefix+"tls-cert-file", "", "client certificate, for mutual TLS")
+	fs.StringVar(&cp.tlsKeyFile, prefix+"tls-key-file", "", "client certificate key, for mutual TLS")
+	fs.BoolVar(&cp.tlsSkipVerify, prefix+"tls-skip-verify", false, "don't verify the agent certificate")
+	fs.StringVar(&cp.serviceName, prefix+"service-name", "", "registered service name, default is the service name")
+	fs.StringVar(&cp.serviceAddress, prefix+"service-address", "", "registered address, default is the first non loopback IP")
+	fs.StringVar(&cp.tags, prefix+"tags", "", "registered tags, comma separated, ex: v1,primary")
+	fs.StringVar(&cp.checkPath, prefix+"check-path", defaultCheckPath, "path of the http health check")
+	fs.DurationVar(&cp.checkInterval, prefix+"check-interval", defaultCheckInterval, "interval of the http health check")
+	fs.DurationVar(&cp.checkTimeout, prefix+"check-timeout", defaultCheckTimeout, "timeout of the http health check")
+	fs.DurationVar(&cp.deregisterAfter, prefix+"deregister-after", defaultDeregisterAfter, "the agent deregisters the service once critical for it")
+	fs.IntVar(&cp.registerRetries, prefix+"register-retries", defaultRegisterRetries, "registration retries, with an exponential backoff")
+	fs.DurationVar(&cp.retryBackoff, prefix+"retry-backoff", defaultRetryBackoff, "first backoff of the registration retries")
+	fs.DurationVar(&cp.timeout, prefix+"timeout", defaultTimeout, "timeout of agent requests")
+}
+
+func (cp *consulPlugin) isDisabled() bool {
+	return cp.address == ""
+}
+
+func (cp *consulPlugin) Configure() error {
+	cp.logger = logger.GetCurrent().GetLogger(cp.name)
+	if cp.isDisabled() {
+		return nil
+	}
+
+	address := strings.TrimSuffix(cp.address, "/")
+	useTLS := cp.tlsCAFile != "" || cp.tlsCertFile != "" || cp.tlsSkipVerify
+	if !strings.Contains(address, "://") {
+		if useTLS {
+			address = "https://" + address
+		} else {
+			address = "http://" + address
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if useTLS {
+		tlsConfig, err := cp.tlsConfig()
+		if err != nil {
+			return err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	cp.agent = &agent{address: address, token: cp.token, timeout: cp.timeout, client: &http.Client{Transport: transport}}
+	return nil
+}
+
+func (cp *consulPlugin) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: cp.tlsSkipVerify}
+
+	if cp.tlsCAFile != "" {
+		pem, err := os.ReadFile(cp.tlsCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read tls ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in tls ca file %s", cp.tlsCAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if cp.tlsCertFile != "" || cp.tlsKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cp.tlsCertFile, cp.tlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load tls client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// Run does nothing, the service registers in Register once its port is known
+func (cp *consulPlugin) Run() error {
+	return nil
+}
+
+// Register registers the service listening on port, it's called by the service
+// once the http server listens. Failures are retried with an exponential backoff.
+func (cp *consulPlugin) Register(ctx context.Context, name string, port int) error {
+	if cp.isDisabled() {
+		return nil
+	}
+
+	if cp.serviceName != "" {
+		name = cp.serviceName
+	}
+	address := cp.serviceAddress
+	if address == "" {
+		address = localIP()
+	}
+	hostname, _ := os.Hostname()
+
+	reg := registration{
+		ID:      name + "-" + hostname + "-" + strconv.Itoa(port),
+		Name:    name,
+		Tags:    splitTags(cp.tags),
+		Address: address,
+		Port:    port,
+		Check: &check{
+			HTTP:                           "http://" + net.JoinHostPort(address, strconv.Itoa(port)) + cp.checkPath,
+			Interval:                       cp.checkInterval.String(),
+			Timeout:                        cp.checkTimeout.String(),
+			DeregisterCriticalServiceAfter: cp.deregisterAfter.String(),
+		},
+	}
+
+	backoff := cp.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := cp.agent.register(ctx, reg)
+		if err == nil {
+			break
+		}
+		if attempt >= cp.registerRetries {
+			return fmt.Errorf("cannot register %s to Consul: %w", reg.ID, err)
+		}
+
+		cp.logger.Warnf("cannot register %s to Consul, retrying in %s: %s", reg.ID, backoff, err.Error())
+		if !sleep(ctx, backoff) {
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+
+	cp.mu.Lock()
+	cp.serviceID = reg.ID
+	cp.mu.Unlock()
+
+	cp.logger.Infof("registered %s to Consul at %s:%d", reg.ID, address, port)
+	return nil
+}
+
+// Deregister removes the registration, it's called by the service when
+// it stops, before the http server drains
+func (cp *consulPlugin) Deregister(ctx context.Context) error {
+	cp.mu.Lock()
+	id := cp.serviceID
+	cp.serviceID = ""
+	cp.mu.Unlock()
+
+	if id == "" {
+		return nil
+	}
+	if err := cp.agent.deregister(ctx, id); err != nil {
+		return fmt.Errorf("cannot deregister %s from Consul: %w", id, err)
+	}
+	cp.logger.Infof("deregistered %s from Consul", id)
+	return nil
+}
+
+// Stop stops the watches, the service is deregistered too unless it's done already
+func (cp *consulPlugin) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		if !cp.isDisabled() {
+			ctx, cancel := context.WithTimeout(context.Background(), cp.timeout)
+			if err := cp.Deregister(ctx); err != nil {
+				cp.logger.Errorf("%s", err.Error())
+			}
+			cancel()
+		}
+
+		// no watch starts once canceled
+		cp.mu.Lock()
+		cp.cancel()
+		cp.mu.Unlock()
+
+		cp.wg.Wait()
+		c <- true
+	}()
+	return c
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// localIP returns the first non loopback IPv4 address, or the IPv6 one
+func localIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "127.0.0.1"
+	}
+
+	var ipv6 string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+		if ipv6 == "" {
+			ipv6 = ipNet.IP.String()
+		}
+	}
+	if ipv6 != "" {
+		return ipv6
+	}
+	return "127.0.0.1"
+}
+
+// sleep returns false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,244 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// fakeAgent keeps the registrations, the health endpoint returns all of them
+// and supports blocking queries
+type fakeAgent struct {
+	mu       sync.Mutex
+	services map[string]registration
+	index    uint64
+	changed  chan struct{}
+	failures int
+	tokens   []string
+}
+
+func newFakeAgent() *fakeAgent {
+	return &fakeAgent{services: map[string]registration{}, index: 1, changed: make(chan struct{})}
+}
+
+// update must be called with mu held
+func (f *fakeAgent) update() {
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.tokens = append(f.tokens, r.Header.Get("X-Consul-Token"))
+	if f.failures > 0 {
+		f.failures--
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("agent is starting"))
+		return
+	}
+	f.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		var reg registration
+		_ = json.NewDecoder(r.Body).Decode(&reg)
+		f.mu.Lock()
+		f.services[reg.ID] = reg
+		f.update()
+		f.mu.Unlock()
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		f.mu.Lock()
+		delete(f.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+		f.update()
+		f.mu.Unlock()
+	case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+		index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+
+		f.mu.Lock()
+		if index >= f.index {
+			changed := f.changed
+			f.mu.Unlock()
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+			f.mu.Lock()
+		}
+
+		var entries []serviceEntry
+		for _, reg := range f.services {
+			if reg.Name != name {
+				continue
+			}
+			var e serviceEntry
+			e.Node.Address = "10.0.0.1"
+			e.Service.ID, e.Service.Address, e.Service.Port, e.Service.Tags = reg.ID, reg.Address, reg.Port, reg.Tags
+			entries = append(entries, e)
+		}
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+		f.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(entries)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeAgent) registered(id string) (registration, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reg, ok := f.services[id]
+	return reg, ok
+}
+
+func newPlugin(t *testing.T, address string) *consulPlugin {
+	logger.InitServLogger(false)
+
+	cp := New("consul", "consul")
+	cp.address = address
+	cp.token = "acl-token"
+	cp.serviceAddress = "10.0.0.2"
+	cp.tags = "v1, primary"
+	cp.checkPath = defaultCheckPath
+	cp.checkInterval = defaultCheckInterval
+	cp.checkTimeout = defaultCheckTimeout
+	cp.deregisterAfter = defaultDeregisterAfter
+	cp.registerRetries = 2
+	cp.retryBackoff = time.Millisecond
+	cp.timeout = time.Second
+	assert.Nil(t, cp.Configure(), "must be nil")
+	return cp
+}
+
+func TestRegister(t *testing.T) {
+	fake := newFakeAgent()
+	fake.failures = 2
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cp := newPlugin(t, srv.URL)
+	ctx := context.Background()
+
+	// retried
+	assert.Nil(t, cp.Register(ctx, "orders", 8080), "must be nil")
+
+	reg, ok := fake.registered(cp.serviceID)
+	assert.True(t, ok, "should be registered")
+	assert.Equal(t, "orders", reg.Name, "should be equal")
+	assert.Equal(t, 8080, reg.Port, "should be equal")
+	assert.Equal(t, []string{"v1", "primary"}, reg.Tags, "should be equal")
+	assert.Equal(t, "http://10.0.0.2:8080/healthz", reg.Check.HTTP, "should be equal")
+	assert.Equal(t, "1m0s", reg.Check.DeregisterCriticalServiceAfter, "should be equal")
+	assert.Equal(t, "acl-token", fake.tokens[0], "should be equal")
+
+	id := cp.serviceID
+	assert.Nil(t, cp.Deregister(ctx), "must be nil")
+	_, ok = fake.registered(id)
+	assert.False(t, ok, "should be deregistered")
+
+	<-cp.Stop()
+}
+
+func TestRegisterFailure(t *testing.T) {
+	fake := newFakeAgent()
+	fake.failures = 3
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cp := newPlugin(t, srv.URL)
+	err := cp.Register(context.Background(), "orders", 8080)
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "agent is starting", "should contain the agent error")
+}
+
+func TestDisabled(t *testing.T) {
+	cp := newPlugin(t, "")
+
+	assert.Nil(t, cp.Register(context.Background(), "orders", 8080), "must be nil")
+	_, err := cp.Lookup(context.Background(), "orders")
+	assert.ErrorIs(t, err, errDisabled, "should be equal")
+	<-cp.Stop()
+}
+
+func TestLookup(t *testing.T) {
+	fake := newFakeAgent()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ctx := context.Background()
+	instances := []*consulPlugin{newPlugin(t, srv.URL), newPlugin(t, srv.URL)}
+	instances[1].serviceAddress = ""
+	for i, cp := range instances {
+		assert.Nil(t, cp.Register(ctx, "orders", 8080+i), "must be nil")
+	}
+
+	cp := newPlugin(t, srv.URL)
+	defer func() { <-cp.Stop() }()
+
+	_, err := cp.Lookup(ctx, "payments")
+	assert.NotNil(t, err, "should be an error")
+
+	found, err := cp.Lookup(ctx, "orders")
+	assert.Nil(t, err, "must be nil")
+	assert.Len(t, found, 2, "should be equal")
+
+	// round robin
+	seen := map[int]int{}
+	for i := 0; i < 4; i++ {
+		instance, err := cp.Next(ctx, "orders")
+		assert.Nil(t, err, "must be nil")
+		seen[instance.Port]++
+	}
+	assert.Equal(t, map[int]int{8080: 2, 8081: 2}, seen, "should be equal")
+
+	// the watch sees the deregistration
+	changes := make(chan []Instance, 10)
+	stop, err := cp.Watch("orders", func(instances []Instance) { changes <- instances })
+	assert.Nil(t, err, "must be nil")
+	defer stop()
+	assert.Len(t, <-changes, 2, "should be the current instances")
+
+	assert.Nil(t, instances[0].Deregister(ctx), "must be nil")
+	select {
+	case got := <-changes:
+		assert.Len(t, got, 1, "should be equal")
+		assert.Equal(t, 8081, got[0].Port, "should be equal")
+		assert.NotEmpty(t, got[0].Address, "should be the local IP")
+	case <-time.After(time.Second):
+		assert.Fail(t, "should be notified")
+	}
+
+	found, _ = cp.Lookup(ctx, "orders")
+	assert.Len(t, found, 1, "should be equal")
+	assert.Equal(t, "[::1]:8081", Instance{Address: "::1", Port: 8081}.Addr(), "should be equal")
+}
+
+func TestStopDeregisters(t *testing.T) {
+	fake := newFakeAgent()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cp := newPlugin(t, srv.URL)
+	assert.Nil(t, cp.Register(context.Background(), "orders", 8080), "must be nil")
+	id := cp.serviceID
+
+	_, _ = cp.Lookup(context.Background(), "orders")
+	<-cp.Stop()
+
+	_, ok := fake.registered(id)
+	assert.False(t, ok, "should be deregistered")
+	_, err := cp.Lookup(context.Background(), "payments")
+	assert.ErrorIs(t, err, errStopped, "should be equal")
+}
@@ -0,0 +1,219 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var errStopped = errors.New("consul plugin is stopped")
+
+// Discovery is returned by Get:
+//
+//	discovery := service.MustGet("consul").(consul.Discovery)
+//	instance, err := discovery.Next(ctx, "orders")
+//	resp, err := http.Get("http://" + instance.Addr() + "/orders")
+type Discovery interface {
+	// Lookup returns the healthy instances of service. The first lookup of a
+	// service starts watching it, the next ones are served from memory.
+	Lookup(ctx context.Context, service string) ([]Instance, error)
+	// Next returns the healthy instances of service in turn
+	Next(ctx context.Context, service string) (Instance, error)
+	// Watch calls fn with the healthy instances of service now and on each
+	// change, until stop is called or the plugin stops
+	Watch(service string, fn func([]Instance)) (stop func(), err error)
+}
+
+// Instance is a healthy instance of a service
+type Instance struct {
+	ID      string
+	Address string
+	Port    int
+	Tags    []string
+	Meta    map[string]string
+}
+
+// Addr returns host:port
+func (i Instance) Addr() string {
+	return net.JoinHostPort(i.Address, strconv.Itoa(i.Port))
+}
+
+// resolver keeps the healthy instances of a service, updated by a watch
+type resolver struct {
+	service   string
+	ready     chan struct{}
+	readyOnce sync.Once
+	next      atomic.Uint64
+
+	mu        sync.RWMutex
+	instances []Instance
+	// error of the last query, the instances are kept
+	err         error
+	watchers    map[int]func([]Instance)
+	lastWatcher int
+}
+
+func (cp *consulPlugin) Lookup(ctx context.Context, service string) ([]Instance, error) {
+	_, instances, err := cp.lookup(ctx, service)
+	return instances, err
+}
+
+func (cp *consulPlugin) Next(ctx context.Context, service string) (Instance, error) {
+	r, instances, err := cp.lookup(ctx, service)
+	if err != nil {
+		return Instance{}, err
+	}
+	return instances[(r.next.Add(1)-1)%uint64(len(instances))], nil
+}
+
+func (cp *consulPlugin) Watch(service string, fn func([]Instance)) (func(), error) {
+	r, err := cp.startResolver(service)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.lastWatcher++
+	id := r.lastWatcher
+	r.watchers[id] = fn
+	var instances []Instance
+	ready := r.isReady()
+	if ready {
+		instances = append([]Instance(nil), r.instances...)
+	}
+	r.mu.Unlock()
+
+	if ready {
+		fn(instances)
+	}
+
+	return func() {
+		r.mu.Lock()
+		delete(r.watchers, id)
+		r.mu.Unlock()
+	}, nil
+}
+
+// lookup waits for the first query of the resolver of service
+func (cp *consulPlugin) lookup(ctx context.Context, service string) (*resolver, []Instance, error) {
+	r, err := cp.startResolver(service)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	select {
+	case <-r.ready:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.instances) == 0 {
+		if r.err != nil {
+			return nil, nil, r.err
+		}
+		return nil, nil, fmt.Errorf("no healthy instance of %s", service)
+	}
+	return r, append([]Instance(nil), r.instances...), nil
+}
+
+func (cp *consulPlugin) startResolver(service string) (*resolver, error) {
+	if cp.isDisabled() {
+		return nil, errDisabled
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if r, ok := cp.resolvers[service]; ok {
+		return r, nil
+	}
+	if cp.ctx.Err() != nil {
+		return nil, errStopped
+	}
+
+	r := &resolver{service: service, ready: make(chan struct{}), watchers: map[int]func([]Instance){}}
+	cp.resolvers[service] = r
+
+	cp.wg.Add(1)
+	go cp.watch(r)
+	return r, nil
+}
+
+// watch updates the instances of r with blocking queries until the plugin stops
+func (cp *consulPlugin) watch(r *resolver) {
+	defer cp.wg.Done()
+
+	var index uint64
+	backoff := cp.retryBackoff
+	for {
+		instances, next, err := cp.agent.healthyInstances(cp.ctx, r.service, index, watchWait)
+		if cp.ctx.Err() != nil {
+			r.fail(errStopped)
+			return
+		}
+		if err != nil {
+			cp.logger.Warnf("cannot watch %s, retrying in %s: %s", r.service, backoff, err.Error())
+			r.fail(err)
+			if !sleep(cp.ctx, backoff) {
+				r.fail(errStopped)
+				return
+			}
+			backoff = min(2*backoff, maxRetryBackoff)
+			continue
+		}
+		backoff = cp.retryBackoff
+
+		if !r.isReady() || next != index {
+			r.set(instances)
+		}
+
+		// as advised by Consul, the index must be positive and is reset when it goes backwards
+		switch {
+		case next == 0:
+			index = 1
+		case next < index:
+			index = 0
+		default:
+			index = next
+		}
+	}
+}
+
+func (r *resolver) isReady() bool {
+	select {
+	case <-r.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *resolver) set(instances []Instance) {
+	r.mu.Lock()
+	r.instances, r.err = instances, nil
+	watchers := make([]func([]Instance), 0, len(r.watchers))
+	for _, fn := range r.watchers {
+		watchers = append(watchers, fn)
+	}
+	r.mu.Unlock()
+	r.readyOnce.Do(func() { close(r.ready) })
+
+	for _, fn := range watchers {
+		fn(append([]Instance(nil), instances...))
+	}
+}
+
+// fail keeps the last instances, lookups fail with err when there are none
+func (r *resolver) fail(err error) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+	r.readyOnce.Do(func() { close(r.ready) })
+}
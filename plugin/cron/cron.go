@@ -0,0 +1,274 @@
+package cron
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/taimaifika/go-sdk/plugin/cron"
+
+var defaultGracePeriod = 30 * time.Second
+
+// Overlap decides what to do when a job is due while its previous run is still going
+type Overlap int
+
+const (
+	// OverlapSkip skips the run
+	OverlapSkip Overlap = iota
+	// OverlapQueue starts the run after the previous one is done
+	OverlapQueue
+)
+
+type JobFunc func(ctx context.Context) error
+
+type JobOption func(*job)
+
+// WithOverlap sets the overlap behaviour of a job, default is OverlapSkip
+func WithOverlap(overlap Overlap) JobOption {
+	return func(j *job) {
+		j.overlap = overlap
+	}
+}
+
+// Elector tells whether this replica is the leader, ex: locking.LeaderElector
+type Elector interface {
+	IsLeader() bool
+}
+
+// WithSingleton runs the job only on the leader replica, it's skipped on the others
+func WithSingleton(elector Elector) JobOption {
+	return func(j *job) {
+		j.elector = elector
+	}
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	fn       JobFunc
+	overlap  Overlap
+	elector  Elector
+}
+
+type cronService struct {
+	name         string
+	logger       logger.Logger
+	cron         *cron.Cron
+	mu           *sync.Mutex
+	jobs         []*job
+	gracePeriod  time.Duration
+	disabledJobs string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func New(name string) *cronService {
+	return &cronService{
+		name: name,
+		mu:   &sync.Mutex{},
+	}
+}
+
+func (cs *cronService) Name() string {
+	return cs.name
+}
+
+func (cs *cronService) InitFlags() {
+	cs.InitFlagSet(flag.CommandLine)
+}
+
+func (cs *cronService) InitFlagSet(fs *flag.FlagSet) {
+	prefix := cs.name
+	fs.DurationVar(&cs.gracePeriod, prefix+"-grace-period", defaultGracePeriod, "max time to wait for running jobs when stopping")
+	fs.StringVar(&cs.disabledJobs, prefix+"-disabled-jobs", "", "comma separated names of jobs which are not scheduled")
+}
+
+// AddJob adds a job running fn on the standard cron spec (or descriptors like @every 1m).
+// It must be called before the service starts.
+func (cs *cronService) AddJob(spec, name string, fn JobFunc, opts ...JobOption) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("invalid spec %q of cron job %s: %w", spec, name, err)
+	}
+
+	j := &job{name: name, spec: spec, schedule: schedule, fn: fn, overlap: OverlapSkip}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, existing := range cs.jobs {
+		if existing.name == name {
+			return fmt.Errorf("cron job %s is already added", name)
+		}
+	}
+	cs.jobs = append(cs.jobs, j)
+
+	return nil
+}
+
+func (cs *cronService) Configure() error {
+	cs.logger = logger.GetCurrent().GetLogger(cs.name)
+	cs.ctx, cs.cancel = context.WithCancel(context.Background())
+
+	disabled := map[string]bool{}
+	for _, name := range strings.Split(cs.disabledJobs, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			disabled[name] = true
+		}
+	}
+
+	cs.cron = cron.New(cron.WithLogger(cronLogger{cs.logger}))
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, j := range cs.jobs {
+		if disabled[j.name] {
+			cs.logger.Infof("cron job %s is disabled", j.name)
+			continue
+		}
+
+		var wrapper cron.JobWrapper
+		if j.overlap == OverlapQueue {
+			wrapper = cron.DelayIfStillRunning(cronLogger{cs.logger})
+		} else {
+			wrapper = cron.SkipIfStillRunning(cronLogger{cs.logger})
+		}
+
+		cs.cron.Schedule(j.schedule, wrapper(cron.FuncJob(cs.runner(j))))
+		cs.logger.Debugf("scheduled cron job %s (%s)", j.name, j.spec)
+	}
+
+	return nil
+}
+
+// runner runs a job in its own span and logs its duration
+func (cs *cronService) runner(j *job) func() {
+	return func() {
+		if cs.ctx.Err() != nil {
+			return
+		}
+
+		if j.elector != nil && !j.elector.IsLeader() {
+			cs.logger.Withs(logger.Fields{"job": j.name}).Debug("cron job skipped, not the leader")
+			return
+		}
+
+		ctx, span := otel.Tracer(tracerName).Start(cs.ctx, j.name)
+		defer span.End()
+
+		log := cs.logger.Withs(logger.Fields{"job": j.name})
+		log.Info("cron job started")
+
+		start := time.Now()
+		err := safeRun(ctx, j.fn)
+		log = log.With("duration", time.Since(start).String())
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			log.With("error", err.Error()).Error("cron job failed")
+			return
+		}
+
+		log.Info("cron job finished")
+	}
+}
+
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e panicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.value, e.stack)
+}
+
+// safeRun returns the panic of fn as an error, a job must not crash the service
+func safeRun(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError{value: r, stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+// Run starts the scheduler, it doesn't block
+func (cs *cronService) Run() error {
+	cs.mu.Lock()
+	hasJobs := len(cs.jobs) > 0
+	cs.mu.Unlock()
+
+	if !hasJobs {
+		return nil
+	}
+
+	if err := cs.Configure(); err != nil {
+		return err
+	}
+
+	cs.cron.Start()
+	return nil
+}
+
+// Stop stops scheduling, cancels the context of running jobs
+// and waits for them at most the grace period. It sends false if they didn't finish.
+func (cs *cronService) Stop() <-chan bool {
+	c := make(chan bool)
+
+	go func() {
+		finished := true
+		if cs.cron != nil {
+			// done when running jobs return
+			running := cs.cron.Stop()
+			cs.cancel()
+
+			select {
+			case <-running.Done():
+			case <-time.After(cs.gracePeriod):
+				cs.logger.Warnf("cron jobs didn't finish in %s", cs.gracePeriod)
+				finished = false
+			}
+		}
+		c <- finished
+	}()
+
+	return c
+}
+
+// cronLogger adapts the service logger to cron.Logger
+type cronLogger struct {
+	logger logger.Logger
+}
+
+func (l cronLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Withs(toFields(keysAndValues)).Debug(msg)
+}
+
+func (l cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.logger.Withs(toFields(keysAndValues)).With("error", err).Error(msg)
+}
+
+func toFields(keysAndValues []interface{}) logger.Fields {
+	fields := logger.Fields{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+	return fields
+}
@@ -0,0 +1,167 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestCronService() *cronService {
+	logger.InitServLogger(false)
+
+	cs := New("cron")
+	cs.gracePeriod = time.Second
+	return cs
+}
+
+func TestInitFlagSet(t *testing.T) {
+	cs := New("cron")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cs.InitFlagSet(fs)
+
+	err := fs.Parse([]string{"-cron-grace-period=3s", "-cron-disabled-jobs=report"})
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, 3*time.Second, cs.gracePeriod, "should be equal")
+	assert.Equal(t, "report", cs.disabledJobs, "should be equal")
+	assert.Nil(t, flag.CommandLine.Lookup("cron-grace-period"), "must be nil")
+}
+
+func TestAddJobInvalid(t *testing.T) {
+	cs := newTestCronService()
+
+	assert.NotNil(t, cs.AddJob("every minute", "cleanup", func(ctx context.Context) error { return nil }), "should be an error")
+	assert.Nil(t, cs.AddJob("@every 1m", "cleanup", func(ctx context.Context) error { return nil }), "must be nil")
+	assert.NotNil(t, cs.AddJob("@every 1m", "cleanup", func(ctx context.Context) error { return nil }), "duplicated job")
+}
+
+func TestJobRunsWithSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(trace.NewTracerProvider(trace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prev)
+
+	cs := newTestCronService()
+	var runs atomic.Int32
+	assert.Nil(t, cs.AddJob("@every 1s", "report", func(ctx context.Context) error {
+		runs.Add(1)
+		return errors.New("no data")
+	}), "must be nil")
+	assert.Nil(t, cs.AddJob("@every 1s", "cleanup", func(ctx context.Context) error { return nil }), "must be nil")
+	cs.disabledJobs = "cleanup"
+
+	assert.Nil(t, cs.Run(), "must be nil")
+	assert.Eventually(t, func() bool { return runs.Load() > 0 }, 3*time.Second, 10*time.Millisecond)
+	<-cs.Stop()
+
+	spans := recorder.Ended()
+	assert.NotEmpty(t, spans, "should have spans")
+	for _, span := range spans {
+		assert.Equal(t, "report", span.Name(), "disabled jobs must not run")
+		assert.Equal(t, codes.Error, span.Status().Code, "should be equal")
+	}
+}
+
+func TestJobPanicIsRecovered(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(trace.NewTracerProvider(trace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prev)
+
+	cs := newTestCronService()
+	var runs atomic.Int32
+	assert.Nil(t, cs.AddJob("@every 1s", "report", func(ctx context.Context) error {
+		runs.Add(1)
+		panic("nil map")
+	}), "must be nil")
+
+	assert.Nil(t, cs.Run(), "must be nil")
+	assert.Eventually(t, func() bool { return runs.Load() > 1 }, 4*time.Second, 10*time.Millisecond, "should keep running after a panic")
+	<-cs.Stop()
+
+	spans := recorder.Ended()
+	assert.NotEmpty(t, spans, "should have spans")
+	assert.Equal(t, codes.Error, spans[0].Status().Code, "should be equal")
+	assert.Contains(t, spans[0].Status().Description, "panic: nil map", "should be equal")
+}
+
+func TestStopCancelsRunningJobs(t *testing.T) {
+	cs := newTestCronService()
+
+	var started, cancelled atomic.Bool
+	assert.Nil(t, cs.AddJob("@every 1s", "slow", func(ctx context.Context) error {
+		started.Store(true)
+		<-ctx.Done()
+		cancelled.Store(true)
+		return ctx.Err()
+	}), "must be nil")
+
+	assert.Nil(t, cs.Run(), "must be nil")
+	assert.Eventually(t, started.Load, 3*time.Second, 10*time.Millisecond)
+
+	start := time.Now()
+	<-cs.Stop()
+	assert.True(t, cancelled.Load(), "running job must be cancelled")
+	assert.Less(t, time.Since(start), cs.gracePeriod, "should not wait the grace period")
+}
+
+func TestOverlapSkip(t *testing.T) {
+	cs := newTestCronService()
+
+	var running, overlaps atomic.Int32
+	assert.Nil(t, cs.AddJob("@every 1s", "long", func(ctx context.Context) error {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer running.Add(-1)
+
+		select {
+		case <-time.After(2500 * time.Millisecond):
+		case <-ctx.Done():
+		}
+		return nil
+	}), "must be nil")
+
+	assert.Nil(t, cs.Run(), "must be nil")
+	time.Sleep(3500 * time.Millisecond)
+	<-cs.Stop()
+
+	assert.Equal(t, int32(0), overlaps.Load(), "runs must not overlap")
+}
+
+type testElector struct {
+	leader atomic.Bool
+}
+
+func (e *testElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+func TestSingletonSkipsFollowers(t *testing.T) {
+	cs := newTestCronService()
+	elector := &testElector{}
+
+	var runs atomic.Int32
+	assert.Nil(t, cs.AddJob("@every 1s", "invoices", func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, WithSingleton(elector)), "must be nil")
+
+	assert.Nil(t, cs.Run(), "must be nil")
+	defer func() { <-cs.Stop() }()
+
+	time.Sleep(1500 * time.Millisecond)
+	assert.Equal(t, int32(0), runs.Load(), "followers must skip the job")
+
+	elector.leader.Store(true)
+	assert.Eventually(t, func() bool { return runs.Load() > 0 }, 3*time.Second, 10*time.Millisecond, "leader should run the job")
+}
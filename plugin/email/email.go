@@ -0,0 +1,225 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+const (
+	BackendSMTP = "smtp"
+	BackendSink = "sink"
+)
+
+var (
+	defaultPort     = 587
+	defaultPoolSize = 2
+	defaultTimeout  = 10 * time.Second
+	defaultRetries  = 3
+	// delay of the first retry, doubled on each retry
+	retryBackoff = time.Second
+)
+
+// Backend sends the messages of the plugin, the SMTP backend is the default
+type Backend interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Email is returned by Get:
+//
+//	mailer := service.MustGet("email").(email.Email)
+//	err := mailer.SendTemplate(ctx, "welcome", user, user.Email)
+//	if email.IsPermanent(err) {
+//		// don't retry, ex: the address is rejected
+//	}
+type Email interface {
+	// Send sends msg, transient failures are retried with backoff
+	Send(ctx context.Context, msg *Message) error
+	// SendTemplate renders the registered template to the subject and bodies of a message
+	SendTemplate(ctx context.Context, templateName string, data any, to ...string) error
+}
+
+type email struct {
+	name   string
+	prefix string
+	logger logger.Logger
+
+	backendName  string
+	smtp         smtpConfig
+	from         string
+	retries      int
+	templatesDir string
+
+	mu        sync.RWMutex
+	backend   Backend
+	custom    bool
+	templates *templates
+}
+
+func New(name, prefix string) *email {
+	return &email{name: name, prefix: prefix, templates: newTemplates()}
+}
+
+// SetBackend replaces the SMTP backend, ex: a Sink in tests. Call it before the service runs.
+func (e *email) SetBackend(b Backend) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.backend, e.custom = b, true
+}
+
+// RegisterTemplates parses the templates of fsys matching pattern, ex: an embed.FS and "*.html".
+// Call it before the service runs.
+func (e *email) RegisterTemplates(fsys fs.FS, pattern string) error {
+	return e.templates.register(fsys, pattern)
+}
+
+func (e *email) Name() string {
+	return e.name
+}
+
+func (e *email) GetPrefix() string {
+	return e.prefix
+}
+
+func (e *email) Get() interface{} {
+	return Email(e)
+}
+
+func (e *email) InitFlags() {
+	e.InitFlagSet(flag.CommandLine)
+}
+
+func (e *email) InitFlagSet(fs *flag.FlagSet) {
+	prefix := e.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&e.backendName, prefix+"backend", BackendSMTP, "email backend: smtp | sink (records the messages, for local dev)")
+	fs.StringVar(&e.smtp.host, prefix+"host", "", "SMTP host")
+	fs.IntVar(&e.smtp.port, prefix+"port", defaultPort, "SMTP port")
+	fs.StringVar(&e.smtp.username, prefix+"username", "", "SMTP username, empty => no auth")
+	fs.StringVar(&e.smtp.password, prefix+"password", "", "SMTP password")
+	fs.StringVar(&e.smtp.tlsMode, prefix+"tls", TLSStartTLS, "SMTP TLS mode: none | starttls | tls (implicit TLS, usually port 465)")
+	fs.IntVar(&e.smtp.poolSize, prefix+"pool-size", defaultPoolSize, "max SMTP connections")
+	fs.DurationVar(&e.smtp.timeout, prefix+"timeout", defaultTimeout, "timeout of the SMTP operations")
+	fs.StringVar(&e.from, prefix+"from", "", "default from address, ex: Shop <no-reply@example.com>")
+	fs.IntVar(&e.retries, prefix+"retries", defaultRetries, "retries of transient failures")
+	fs.StringVar(&e.templatesDir, prefix+"templates-dir", "", "directory of the *.html templates of SendTemplate")
+}
+
+func (e *email) Configure() error {
+	e.logger = logger.GetCurrent().GetLogger(e.name)
+
+	if e.templatesDir != "" {
+		if err := e.templates.register(os.DirFS(e.templatesDir), "*.html"); err != nil {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.custom {
+		return nil
+	}
+
+	switch e.backendName {
+	case BackendSink:
+		e.backend = NewSink()
+		return nil
+	case BackendSMTP:
+	default:
+		return fmt.Errorf("invalid email backend %q, must be %s or %s", e.backendName, BackendSMTP, BackendSink)
+	}
+
+	if e.smtp.host == "" {
+		return errors.New("email host is required by the smtp backend")
+	}
+	switch e.smtp.tlsMode {
+	case TLSNone, TLSStartTLS, TLSImplicit:
+	default:
+		return fmt.Errorf("invalid email tls mode %q, must be %s, %s or %s", e.smtp.tlsMode, TLSNone, TLSStartTLS, TLSImplicit)
+	}
+	if e.smtp.poolSize <= 0 {
+		return fmt.Errorf("invalid email pool size %d, must be positive", e.smtp.poolSize)
+	}
+
+	e.backend = newSMTPBackend(e.smtp)
+	return nil
+}
+
+func (e *email) Run() error {
+	return e.Configure()
+}
+
+func (e *email) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		if closer, ok := e.currentBackend().(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				e.logger.Warnf("cannot close email backend: %s", err.Error())
+			}
+		}
+		c <- true
+	}()
+	return c
+}
+
+func (e *email) currentBackend() Backend {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.backend
+}
+
+func (e *email) Send(ctx context.Context, msg *Message) error {
+	backend := e.currentBackend()
+	if backend == nil {
+		return errors.New("email plugin is not running")
+	}
+
+	m := *msg
+	if m.From == "" {
+		m.From = e.from
+	}
+	if err := m.validate(); err != nil {
+		return err
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := backend.Send(ctx, &m)
+		if err == nil || IsPermanent(err) || attempt >= e.retries {
+			return err
+		}
+
+		e.logger.Withs(logger.Fields{"attempt": attempt + 1, "subject": m.Subject}).
+			Warnf("cannot send email, retry in %s: %s", backoff, err.Error())
+
+		select {
+		case <-ctx.Done():
+			return &Error{Err: ctx.Err()}
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (e *email) SendTemplate(ctx context.Context, templateName string, data any, to ...string) error {
+	msg, err := e.templates.render(templateName, data)
+	if err != nil {
+		return permanent(err)
+	}
+
+	msg.To = to
+	return e.Send(ctx, msg)
+}
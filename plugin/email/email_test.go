@@ -0,0 +1,262 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// fakeSMTP is an SMTP server rejecting the recipients of reject.example
+// and replying 421 to the first failures messages
+type fakeSMTP struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	failures int
+	messages []string
+	conns    int
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "must be nil")
+
+	s := &fakeSMTP{listener: l}
+	go s.serve()
+	t.Cleanup(func() { _ = l.Close() })
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(cmd, "MAIL FROM"), cmd == "RSET", cmd == "NOOP":
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			if strings.Contains(cmd, "REJECT.EXAMPLE") {
+				reply("550 5.1.1 mailbox unavailable")
+			} else {
+				reply("250 OK")
+			}
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+
+			s.mu.Lock()
+			if s.failures > 0 {
+				s.failures--
+				s.mu.Unlock()
+				reply("421 4.3.2 try again later")
+				return
+			}
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTP) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.messages...)
+}
+
+func newTestEmail(port int) *email {
+	logger.InitServLogger(false)
+
+	e := New("email", "email")
+	e.backendName = BackendSMTP
+	e.smtp = smtpConfig{host: "127.0.0.1", port: port, tlsMode: TLSNone, poolSize: 2, timeout: 2 * time.Second}
+	e.from = "Shop <no-reply@example.com>"
+	e.retries = 2
+	return e
+}
+
+func TestSendSMTP(t *testing.T) {
+	server := newFakeSMTP(t)
+	e := newTestEmail(server.port())
+	assert.Nil(t, e.Run(), "must be nil")
+	defer func() { <-e.Stop() }()
+
+	msg := &Message{
+		To:          []string{"Bob <bob@example.com>"},
+		Bcc:         []string{"audit@example.com"},
+		Subject:     "Xin chào",
+		Text:        "hello",
+		HTML:        "<p>hello</p>",
+		Attachments: []Attachment{{Filename: "report.csv", Data: []byte("a,b\n1,2\n")}},
+		Headers:     map[string]string{"X-Campaign": "welcome"},
+	}
+	assert.Nil(t, e.Send(context.Background(), msg), "must be nil")
+	assert.Nil(t, e.Send(context.Background(), msg), "must be nil")
+
+	received := server.received()
+	assert.Len(t, received, 2, "should be equal")
+
+	parsed, err := mail.ReadMessage(strings.NewReader(received[0]))
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, `"Shop" <no-reply@example.com>`, parsed.Header.Get("From"), "should be equal")
+	assert.Equal(t, `"Bob" <bob@example.com>`, parsed.Header.Get("To"), "should be equal")
+	assert.Empty(t, parsed.Header.Get("Bcc"), "bcc must not be in the headers")
+	assert.Equal(t, "welcome", parsed.Header.Get("X-Campaign"), "should be equal")
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "Xin chào", subject, "should be equal")
+
+	_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	assert.Nil(t, err, "must be nil")
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+
+	body, err := mr.NextPart()
+	assert.Nil(t, err, "must be nil")
+	assert.True(t, strings.HasPrefix(body.Header.Get("Content-Type"), "multipart/alternative"), "should be true")
+
+	attachment, err := mr.NextPart()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "report.csv", attachment.FileName(), "should be equal")
+
+	server.mu.Lock()
+	conns := server.conns
+	server.mu.Unlock()
+	assert.Equal(t, 1, conns, "connection should be reused")
+}
+
+func TestSendErrors(t *testing.T) {
+	server := newFakeSMTP(t)
+	e := newTestEmail(server.port())
+	assert.Nil(t, e.Run(), "must be nil")
+	defer func() { <-e.Stop() }()
+
+	err := e.Send(context.Background(), &Message{To: []string{"bob@reject.example"}, Text: "hi"})
+	assert.True(t, IsPermanent(err), "rejected address should be permanent")
+	var emailErr *Error
+	assert.True(t, errors.As(err, &emailErr), "should be an Error")
+	assert.Equal(t, 550, emailErr.Code, "should be equal")
+
+	err = e.Send(context.Background(), &Message{To: []string{"not an address"}, Text: "hi"})
+	assert.True(t, IsPermanent(err), "invalid address should be permanent")
+
+	err = e.Send(context.Background(), &Message{To: []string{"bob@example.com"}, Headers: map[string]string{"X-Bad": "a\r\nBcc: x@example.com"}})
+	assert.True(t, IsPermanent(err), "header injection should be permanent")
+
+	retryBackoff = time.Millisecond
+	server.mu.Lock()
+	server.failures = 2
+	server.mu.Unlock()
+	assert.Nil(t, e.Send(context.Background(), &Message{To: []string{"bob@example.com"}, Text: "hi"}), "transient failures should be retried")
+
+	server.mu.Lock()
+	server.failures = 3
+	server.mu.Unlock()
+	err = e.Send(context.Background(), &Message{To: []string{"bob@example.com"}, Text: "hi"})
+	assert.NotNil(t, err, "should be an error")
+	assert.False(t, IsPermanent(err), "421 should be transient")
+}
+
+func TestSendTemplateToSink(t *testing.T) {
+	logger.InitServLogger(false)
+
+	e := New("email", "email")
+	e.from = "no-reply@example.com"
+	sink := NewSink()
+	e.SetBackend(sink)
+
+	err := e.RegisterTemplates(fstest.MapFS{
+		"templates/welcome.html": {Data: []byte(`{{define "subject"}}Welcome {{.Name}} & co{{end}}
+{{define "text"}}Hi {{.Name}}{{end}}
+<p>Hi {{.Name}}</p>`)},
+	}, "templates/*.html")
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, e.Run(), "must be nil")
+
+	assert.Nil(t, e.SendTemplate(context.Background(), "welcome", map[string]string{"Name": "<Bob>"}, "bob@example.com"), "must be nil")
+	assert.True(t, IsPermanent(e.SendTemplate(context.Background(), "missing", nil, "bob@example.com")), "missing template should be permanent")
+
+	messages := sink.Messages()
+	assert.Len(t, messages, 1, "should be equal")
+	assert.Equal(t, "Welcome <Bob> & co", messages[0].Subject, "subject must not be escaped")
+	assert.Equal(t, "Hi <Bob>", messages[0].Text, "should be equal")
+	assert.Equal(t, "<p>Hi &lt;Bob&gt;</p>", messages[0].HTML, "html must be escaped")
+	assert.Equal(t, []string{"bob@example.com"}, messages[0].To, "should be equal")
+	assert.Equal(t, "no-reply@example.com", messages[0].From, "should be equal")
+}
+
+func TestConfigure(t *testing.T) {
+	logger.InitServLogger(false)
+
+	e := newTestEmail(25)
+	e.smtp.host = ""
+	assert.NotNil(t, e.Configure(), "should be an error")
+
+	e = newTestEmail(25)
+	e.smtp.tlsMode = "ssl"
+	assert.NotNil(t, e.Configure(), "should be an error")
+
+	e = newTestEmail(25)
+	e.backendName = BackendSink
+	assert.Nil(t, e.Configure(), "must be nil")
+	_, ok := e.currentBackend().(*Sink)
+	assert.True(t, ok, "should be a sink")
+}
@@ -0,0 +1,229 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is an email, From is the from flag when it's empty
+type Message struct {
+	From        string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	ReplyTo     string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+	Headers     map[string]string
+}
+
+type Attachment struct {
+	Filename string
+	// ContentType is detected from the filename when it's empty
+	ContentType string
+	Data        []byte
+}
+
+// recipients returns the envelope addresses of To, Cc and Bcc
+func (m *Message) recipients() ([]string, error) {
+	var rcpts []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, a := range list {
+			addr, err := mail.ParseAddress(a)
+			if err != nil {
+				return nil, permanent(fmt.Errorf("invalid address %q: %w", a, err))
+			}
+			rcpts = append(rcpts, addr.Address)
+		}
+	}
+	if len(rcpts) == 0 {
+		return nil, permanent(fmt.Errorf("message has no recipient"))
+	}
+	return rcpts, nil
+}
+
+func (m *Message) validate() error {
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		return permanent(fmt.Errorf("invalid from address %q: %w", m.From, err))
+	}
+	if m.ReplyTo != "" {
+		if _, err := mail.ParseAddress(m.ReplyTo); err != nil {
+			return permanent(fmt.Errorf("invalid reply-to address %q: %w", m.ReplyTo, err))
+		}
+	}
+	for k, v := range m.Headers {
+		if strings.ContainsAny(k, "\r\n:") || strings.ContainsAny(v, "\r\n") {
+			return permanent(fmt.Errorf("invalid header %q", k))
+		}
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return permanent(fmt.Errorf("invalid subject"))
+	}
+	return nil
+}
+
+// bytes renders the message as multipart/mixed of a multipart/alternative body and the attachments
+func (m *Message) bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", formatAddresses([]string{m.From}))
+	if len(m.To) > 0 {
+		header.Set("To", formatAddresses(m.To))
+	}
+	if len(m.Cc) > 0 {
+		header.Set("Cc", formatAddresses(m.Cc))
+	}
+	if m.ReplyTo != "" {
+		header.Set("Reply-To", formatAddresses([]string{m.ReplyTo}))
+	}
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	header.Set("Message-Id", messageID(m.From))
+	header.Set("Mime-Version", "1.0")
+	for k, v := range m.Headers {
+		header.Set(k, v)
+	}
+
+	mixed := multipart.NewWriter(buf)
+	header.Set("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	writeHeader(buf, header)
+
+	alternative := &bytes.Buffer{}
+	alt := multipart.NewWriter(alternative)
+	if m.Text != "" || m.HTML == "" {
+		if err := writeQuotedPrintable(alt, "text/plain; charset=utf-8", m.Text); err != nil {
+			return nil, err
+		}
+	}
+	if m.HTML != "" {
+		if err := writeQuotedPrintable(alt, "text/html; charset=utf-8", m.HTML); err != nil {
+			return nil, err
+		}
+	}
+	if err := alt.Close(); err != nil {
+		return nil, err
+	}
+
+	body, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + alt.Boundary()}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := body.Write(alternative.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for _, a := range m.Attachments {
+		if err := writeAttachment(mixed, a); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formatAddresses encodes the display names, the addresses are validated
+func formatAddresses(list []string) string {
+	formatted := make([]string, 0, len(list))
+	for _, a := range list {
+		if addr, err := mail.ParseAddress(a); err == nil {
+			formatted = append(formatted, addr.String())
+		}
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func writeQuotedPrintable(w *multipart.Writer, contentType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeAttachment(w *multipart.Writer, a Attachment) error {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(a.Filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": a.Filename})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+
+	// lines of 76 characters
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	for len(encoded) > 76 {
+		if _, err := fmt.Fprintf(part, "%s\r\n", encoded[:76]); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = fmt.Fprintf(part, "%s\r\n", encoded)
+	return err
+}
+
+func messageID(from string) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	domain := ""
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+	if domain == "" {
+		domain, _ = os.Hostname()
+	}
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
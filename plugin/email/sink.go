@@ -0,0 +1,53 @@
+package email
+
+import (
+	"context"
+	"sync"
+)
+
+// Sink is a backend recording the messages instead of sending them, for tests and local dev:
+//
+//	sink := email.NewSink()
+//	mailer.SetBackend(sink)
+//	...
+//	assert.Len(t, sink.Messages(), 1)
+type Sink struct {
+	mu       sync.Mutex
+	messages []Message
+	// Err is returned by Send when it's set, the message isn't recorded
+	Err error
+}
+
+func NewSink() *Sink {
+	return &Sink{}
+}
+
+func (s *Sink) Send(_ context.Context, msg *Message) error {
+	if _, err := msg.recipients(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return s.Err
+	}
+	s.messages = append(s.messages, *msg)
+	return nil
+}
+
+// Messages returns the recorded messages
+func (s *Sink) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+}
@@ -0,0 +1,256 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+const (
+	TLSNone     = "none"
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+)
+
+// Error is returned by Send, a permanent error fails again when it's retried,
+// ex: a rejected address, a transient one may succeed later, ex: a connection error
+type Error struct {
+	Permanent bool
+	// Code is the SMTP reply code, 0 when the error isn't a reply of the server
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	kind := "transient"
+	if e.Permanent {
+		kind = "permanent"
+	}
+	return fmt.Sprintf("%s email error: %s", kind, e.Err.Error())
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent tells whether err is a permanent email error, which must not be retried
+func IsPermanent(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Permanent
+}
+
+func permanent(err error) error {
+	return &Error{Permanent: true, Err: err}
+}
+
+// classify makes an Error of an SMTP error, 5xx replies are permanent
+func classify(err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return &Error{Permanent: tpErr.Code >= 500, Code: tpErr.Code, Err: err}
+	}
+	return &Error{Err: err}
+}
+
+type smtpConfig struct {
+	host     string
+	port     int
+	username string
+	password string
+	tlsMode  string
+	poolSize int
+	timeout  time.Duration
+}
+
+// smtpBackend sends with a pool of at most poolSize connections
+type smtpBackend struct {
+	cfg   smtpConfig
+	slots chan struct{}
+	idle  chan *smtpConn
+}
+
+type smtpConn struct {
+	conn   net.Conn
+	client *smtp.Client
+}
+
+func newSMTPBackend(cfg smtpConfig) *smtpBackend {
+	return &smtpBackend{
+		cfg:   cfg,
+		slots: make(chan struct{}, cfg.poolSize),
+		idle:  make(chan *smtpConn, cfg.poolSize),
+	}
+}
+
+func (b *smtpBackend) Send(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return permanent(fmt.Errorf("invalid from address %q: %w", msg.From, err))
+	}
+	rcpts, err := msg.recipients()
+	if err != nil {
+		return err
+	}
+	data, err := msg.bytes()
+	if err != nil {
+		return permanent(err)
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+	case <-ctx.Done():
+		return &Error{Err: ctx.Err()}
+	}
+	defer func() { <-b.slots }()
+
+	c, err := b.conn(ctx)
+	if err != nil {
+		return classify(err)
+	}
+
+	if err := c.send(ctx, b.cfg.timeout, from.Address, rcpts, data); err != nil {
+		c.close()
+		return classify(err)
+	}
+
+	b.release(c)
+	return nil
+}
+
+// conn returns an idle connection which is still alive, or a new one
+func (b *smtpBackend) conn(ctx context.Context) (*smtpConn, error) {
+	for {
+		select {
+		case c := <-b.idle:
+			_ = c.conn.SetDeadline(time.Now().Add(b.cfg.timeout))
+			if err := c.client.Reset(); err == nil {
+				return c, nil
+			}
+			c.close()
+			continue
+		default:
+		}
+		return b.dial(ctx)
+	}
+}
+
+func (b *smtpBackend) release(c *smtpConn) {
+	select {
+	case b.idle <- c:
+	default:
+		c.quit()
+	}
+}
+
+func (b *smtpBackend) dial(ctx context.Context) (*smtpConn, error) {
+	addr := net.JoinHostPort(b.cfg.host, strconv.Itoa(b.cfg.port))
+	dialer := &net.Dialer{Timeout: b.cfg.timeout}
+	tlsConfig := &tls.Config{ServerName: b.cfg.host}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if b.cfg.tlsMode == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(deadline(ctx, b.cfg.timeout))
+
+	client, err := smtp.NewClient(conn, b.cfg.host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	c := &smtpConn{conn: conn, client: client}
+
+	if b.cfg.tlsMode == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			c.close()
+			return nil, permanent(errors.New("server doesn't support STARTTLS, set the tls flag to none or tls"))
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+
+	if b.cfg.username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(smtp.PlainAuth("", b.cfg.username, b.cfg.password, b.cfg.host)); err != nil {
+				c.close()
+				return nil, err
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func (c *smtpConn) send(ctx context.Context, timeout time.Duration, from string, rcpts []string, data []byte) error {
+	_ = c.conn.SetDeadline(deadline(ctx, timeout))
+
+	if err := c.client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := c.client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func (c *smtpConn) quit() {
+	_ = c.client.Quit()
+	_ = c.conn.Close()
+}
+
+func (c *smtpConn) close() {
+	_ = c.conn.Close()
+}
+
+// Close quits the idle connections
+func (b *smtpBackend) Close() error {
+	for {
+		select {
+		case c := <-b.idle:
+			c.quit()
+		default:
+			return nil
+		}
+	}
+}
+
+// deadline is the earliest of the deadline of ctx and timeout from now
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	d := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(d) {
+		return ctxDeadline
+	}
+	return d
+}
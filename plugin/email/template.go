@@ -0,0 +1,106 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+const (
+	subjectBlock = "subject"
+	textBlock    = "text"
+)
+
+// templates of SendTemplate, a template is a file named <name>.html. The file is the HTML body,
+// its subject and text blocks are the subject and the text body, they aren't HTML escaped:
+//
+//	{{define "subject"}}Welcome {{.Name}}{{end}}
+//	{{define "text"}}Hi {{.Name}}, welcome!{{end}}
+//	<p>Hi {{.Name}}, <b>welcome</b>!</p>
+type templates struct {
+	mu     sync.RWMutex
+	byName map[string]*emailTemplate
+}
+
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+func newTemplates() *templates {
+	return &templates{byName: map[string]*emailTemplate{}}
+}
+
+func (t *templates) register(fsys fs.FS, pattern string) error {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no email template matches %s", pattern)
+	}
+
+	parsed := make(map[string]*emailTemplate, len(files))
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("cannot read email template %s: %w", file, err)
+		}
+
+		name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+		html, err := htmltemplate.New(name).Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("invalid email template %s: %w", file, err)
+		}
+		text, err := texttemplate.New(name).Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("invalid email template %s: %w", file, err)
+		}
+		parsed[name] = &emailTemplate{html: html, text: text}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name, tmpl := range parsed {
+		t.byName[name] = tmpl
+	}
+	return nil
+}
+
+// render executes a template to a message without recipients
+func (t *templates) render(name string, data any) (*Message, error) {
+	t.mu.RLock()
+	tmpl, ok := t.byName[name]
+	t.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("email template %s is not registered", name)
+	}
+
+	msg := &Message{}
+	buf := &bytes.Buffer{}
+
+	if err := tmpl.html.Execute(buf, data); err != nil {
+		return nil, fmt.Errorf("cannot render email template %s: %w", name, err)
+	}
+	msg.HTML = strings.TrimSpace(buf.String())
+
+	for block, dst := range map[string]*string{subjectBlock: &msg.Subject, textBlock: &msg.Text} {
+		if tmpl.text.Lookup(block) == nil {
+			continue
+		}
+		buf.Reset()
+		if err := tmpl.text.ExecuteTemplate(buf, block, data); err != nil {
+			return nil, fmt.Errorf("cannot render %s of email template %s: %w", block, name, err)
+		}
+		*dst = strings.TrimSpace(buf.String())
+	}
+
+	return msg, nil
+}
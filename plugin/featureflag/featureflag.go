@@ -0,0 +1,258 @@
+package featureflag
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	meterName = "github.com/taimaifika/go-sdk/plugin/featureflag"
+
+	ExposureNone   = "none"
+	ExposureEvent  = "event"
+	ExposureMetric = "metric"
+	ExposureAll    = "all"
+
+	// KeyAttribute is the attribute overriding the bucketing key, ex: a tenant id
+	KeyAttribute = "key"
+)
+
+var errFlagDisabled = sdkcm.AppError{StatusCode: http.StatusNotFound, Code: "not_found", Message: "not found"}
+
+// FeatureFlags is returned by Get:
+//
+//	flags := service.MustGet("featureflag").(featureflag.FeatureFlags)
+//
+// The requester of the auth middleware is the default bucketing key, so a rollout is sticky per user.
+type FeatureFlags interface {
+	// IsEnabled evaluates a flag, attrs are matched by the rules of the flag and may be nil
+	IsEnabled(ctx context.Context, flagName string, attrs map[string]any) bool
+	// Variant returns the variant of the requester, off when the flag is disabled
+	Variant(ctx context.Context, flagName string) string
+}
+
+type featureFlags struct {
+	name   string
+	prefix string
+	logger logger.Logger
+
+	file      string
+	envPrefix string
+	reload    bool
+	exposure  string
+
+	mu       sync.RWMutex
+	provider Provider
+	custom   bool
+	exposed  metric.Int64Counter
+}
+
+func New(name, prefix string) *featureFlags {
+	return &featureFlags{name: name, prefix: prefix}
+}
+
+// SetProvider replaces the file provider, call it before the service runs
+func (ff *featureFlags) SetProvider(p Provider) {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+
+	ff.provider, ff.custom = p, true
+}
+
+func (ff *featureFlags) Name() string {
+	return ff.name
+}
+
+func (ff *featureFlags) GetPrefix() string {
+	return ff.prefix
+}
+
+func (ff *featureFlags) Get() interface{} {
+	return FeatureFlags(ff)
+}
+
+func (ff *featureFlags) InitFlags() {
+	ff.InitFlagSet(flag.CommandLine)
+}
+
+func (ff *featureFlags) InitFlagSet(fs *flag.FlagSet) {
+	prefix := ff.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&ff.file, prefix+"file", "", "JSON file of the feature flags, empty => flags only from env")
+	fs.StringVar(&ff.envPrefix, prefix+"env-prefix", "FEATURE", "env vars <prefix>_<FLAG_NAME>=on|off|<percentage> override the flags of the file")
+	fs.BoolVar(&ff.reload, prefix+"reload", true, "reload the file when it changes")
+	fs.StringVar(&ff.exposure, prefix+"exposure", ExposureNone, "exposure tracking of the evaluations: none | event (span event) | metric | all")
+}
+
+func (ff *featureFlags) Configure() error {
+	ff.logger = logger.GetCurrent().GetLogger(ff.name)
+
+	switch ff.exposure {
+	case ExposureNone, ExposureEvent, ExposureMetric, ExposureAll:
+	default:
+		return fmt.Errorf("invalid exposure %q, must be %s, %s, %s or %s", ff.exposure, ExposureNone, ExposureEvent, ExposureMetric, ExposureAll)
+	}
+
+	if ff.exposure == ExposureMetric || ff.exposure == ExposureAll {
+		exposed, err := otel.Meter(meterName).Int64Counter("feature_flag.evaluations",
+			metric.WithDescription("Number of feature flag evaluations"),
+			metric.WithUnit("{evaluation}"))
+		if err != nil {
+			return err
+		}
+		ff.exposed = exposed
+	}
+
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+
+	if ff.custom {
+		return nil
+	}
+
+	provider, err := newFileProvider(ff.file, ff.envPrefix, ff.logger)
+	if err != nil {
+		return err
+	}
+	ff.provider = provider
+	return nil
+}
+
+func (ff *featureFlags) Run() error {
+	if err := ff.Configure(); err != nil {
+		return err
+	}
+
+	provider := ff.currentProvider()
+	if _, ok := provider.(*fileProvider); ok && !ff.reload {
+		return nil
+	}
+
+	if starter, ok := provider.(interface{ Start() error }); ok {
+		return starter.Start()
+	}
+	return nil
+}
+
+func (ff *featureFlags) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		if closer, ok := ff.currentProvider().(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				ff.logger.Warnf("cannot close feature flags provider: %s", err.Error())
+			}
+		}
+		c <- true
+	}()
+	return c
+}
+
+func (ff *featureFlags) currentProvider() Provider {
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+
+	return ff.provider
+}
+
+func (ff *featureFlags) IsEnabled(ctx context.Context, flagName string, attrs map[string]any) bool {
+	return ff.evaluate(ctx, flagName, attrs).Enabled
+}
+
+func (ff *featureFlags) Variant(ctx context.Context, flagName string) string {
+	return ff.evaluate(ctx, flagName, nil).Variant
+}
+
+func (ff *featureFlags) evaluate(ctx context.Context, flagName string, attrs map[string]any) Evaluation {
+	provider := ff.currentProvider()
+	if provider == nil {
+		return Evaluation{Variant: VariantOff, Reason: ReasonNotFound}
+	}
+
+	e := provider.Evaluate(ctx, flagName, evalContext(ctx, attrs))
+	ff.track(ctx, provider, flagName, e)
+	return e
+}
+
+// evalContext buckets by the key attribute, or by the requester of the auth middleware.
+// The user_id and role attributes of the requester are added for the rules.
+func evalContext(ctx context.Context, attrs map[string]any) EvalContext {
+	evalCtx := EvalContext{Attributes: make(map[string]any, len(attrs)+2)}
+	for k, v := range attrs {
+		evalCtx.Attributes[k] = v
+	}
+
+	if requester, ok := middleware.RequesterFromContext(ctx); ok && requester != nil {
+		userID := strconv.FormatUint(uint64(requester.UserID()), 10)
+		evalCtx.Key = userID
+		if _, ok := evalCtx.Attributes["user_id"]; !ok {
+			evalCtx.Attributes["user_id"] = userID
+		}
+		if _, ok := evalCtx.Attributes["role"]; !ok {
+			evalCtx.Attributes["role"] = requester.GetSystemRole()
+		}
+	}
+
+	if key, ok := evalCtx.Attributes[KeyAttribute]; ok {
+		evalCtx.Key = fmt.Sprint(key)
+	}
+
+	return evalCtx
+}
+
+// track records the exposure of an evaluation as a span event and a metric
+func (ff *featureFlags) track(ctx context.Context, provider Provider, flagName string, e Evaluation) {
+	if ff.exposure == ExposureEvent || ff.exposure == ExposureAll {
+		trace.SpanFromContext(requestContext(ctx)).AddEvent("feature_flag", trace.WithAttributes(
+			attribute.String("feature_flag.key", flagName),
+			attribute.String("feature_flag.provider_name", provider.Name()),
+			attribute.String("feature_flag.variant", e.Variant),
+			attribute.String("feature_flag.reason", e.Reason),
+		))
+	}
+
+	if ff.exposed != nil {
+		ff.exposed.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("feature_flag.key", flagName),
+			attribute.String("feature_flag.variant", e.Variant),
+		))
+	}
+}
+
+// requestContext returns the request context of a *gin.Context, where the span is
+func requestContext(ctx context.Context) context.Context {
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		return c.Request.Context()
+	}
+	return ctx
+}
+
+// RequireFlag responds 404 to the routes behind a flag which is disabled for the requester,
+// use it after RequiredAuth for rollouts per user
+func RequireFlag(flags FeatureFlags, flagName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !flags.IsEnabled(c, flagName, nil) {
+			appErr := errFlagDisabled
+			appErr.RequestID = middleware.RequestIDFromContext(c)
+			c.AbortWithStatusJSON(appErr.StatusCode, appErr)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,169 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+const testFlags = `{"flags": {
+	"new-checkout": {"enabled": true, "rollout": 25},
+	"beta": {
+		"enabled": true,
+		"rollout": 0,
+		"rules": [
+			{"attribute": "country", "operator": "in", "values": ["VN", "SG"]},
+			{"attribute": "role", "operator": "not_in", "values": ["user"], "rollout": 100}
+		]
+	},
+	"pricing": {"enabled": true, "variants": {"control": 50, "treatment": 50}},
+	"killed": {"enabled": false}
+}}`
+
+type testRequester struct {
+	id   uint32
+	role string
+}
+
+func (r testRequester) OAuthID() string       { return fmt.Sprint(r.id) }
+func (r testRequester) UserID() uint32        { return r.id }
+func (r testRequester) GetSystemRole() string { return r.role }
+func (r testRequester) GetUser() interface{}  { return nil }
+
+func writeFlags(t *testing.T, path, data string) {
+	assert.Nil(t, os.WriteFile(path, []byte(data), 0644), "must be nil")
+}
+
+func newFlags(t *testing.T, data string) (*featureFlags, string) {
+	logger.InitServLogger(false)
+
+	path := filepath.Join(t.TempDir(), "flags.json")
+	writeFlags(t, path, data)
+
+	ff := New("featureflag", "featureflag")
+	ff.file = path
+	ff.envPrefix = "FEATURE"
+	ff.reload = true
+	ff.exposure = ExposureAll
+	assert.Nil(t, ff.Run(), "must be nil")
+	t.Cleanup(func() { <-ff.Stop() })
+
+	return ff, path
+}
+
+func TestRollout(t *testing.T) {
+	ff, _ := newFlags(t, testFlags)
+	ctx := context.Background()
+
+	enabled := 0
+	for i := 0; i < 10000; i++ {
+		attrs := map[string]any{KeyAttribute: i}
+		e := ff.IsEnabled(ctx, "new-checkout", attrs)
+		assert.Equal(t, e, ff.IsEnabled(ctx, "new-checkout", attrs), "should be sticky")
+		if e {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 2500, enabled, 200, "about 25%% should be enabled")
+
+	// partial rollouts need a key
+	assert.False(t, ff.IsEnabled(ctx, "new-checkout", nil), "should be disabled without key")
+	assert.False(t, ff.IsEnabled(ctx, "killed", nil), "should be disabled")
+	assert.False(t, ff.IsEnabled(ctx, "unknown", nil), "should be disabled")
+	assert.Equal(t, VariantOff, ff.Variant(ctx, "killed"), "should be equal")
+}
+
+func TestRules(t *testing.T) {
+	ff, _ := newFlags(t, testFlags)
+	ctx := context.Background()
+
+	assert.True(t, ff.IsEnabled(ctx, "beta", map[string]any{"country": "VN"}), "should match in")
+	assert.False(t, ff.IsEnabled(ctx, "beta", map[string]any{"country": "US"}), "should fall to the rollout")
+
+	gc, _ := gin.CreateTestContext(httptest.NewRecorder())
+	gc.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	gc.Set(middleware.CurrentRequesterKey, sdkcm.Requester(testRequester{id: 7, role: "admin"}))
+	assert.True(t, ff.IsEnabled(gc, "beta", nil), "role of the requester should match not_in")
+
+	gc.Set(middleware.CurrentRequesterKey, sdkcm.Requester(testRequester{id: 7, role: "user"}))
+	assert.False(t, ff.IsEnabled(gc, "beta", nil), "should be disabled")
+}
+
+func TestVariant(t *testing.T) {
+	ff, _ := newFlags(t, testFlags)
+
+	counts := map[string]int{}
+	for i := uint32(1); i <= 2000; i++ {
+		gc, _ := gin.CreateTestContext(httptest.NewRecorder())
+		gc.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		gc.Set(middleware.CurrentRequesterKey, sdkcm.Requester(testRequester{id: i, role: "user"}))
+
+		variant := ff.Variant(gc, "pricing")
+		assert.Equal(t, variant, ff.Variant(gc, "pricing"), "should be sticky per user")
+		counts[variant]++
+	}
+	assert.Equal(t, 2, len(counts), "should have 2 variants")
+	assert.InDelta(t, 1000, counts["control"], 150, "should be about half")
+}
+
+func TestEnvOverride(t *testing.T) {
+	t.Setenv("FEATURE_KILLED", "on")
+	t.Setenv("FEATURE_NEW_CHECKOUT", "off")
+	t.Setenv("FEATURE_UNKNOWN_WITH_ENV", "true")
+
+	ff, _ := newFlags(t, testFlags)
+	ctx := context.Background()
+
+	assert.True(t, ff.IsEnabled(ctx, "killed", nil), "should be enabled by env")
+	assert.False(t, ff.IsEnabled(ctx, "new-checkout", map[string]any{KeyAttribute: 1}), "should be disabled by env")
+	assert.True(t, ff.IsEnabled(ctx, "unknown-with-env", nil), "should be created by env")
+
+	t.Setenv("FEATURE_KILLED", "sometimes")
+	fp := ff.currentProvider().(*fileProvider)
+	assert.NotNil(t, fp.load(), "should be an error")
+	assert.True(t, ff.IsEnabled(ctx, "killed", nil), "current flags should be kept")
+}
+
+func TestReload(t *testing.T) {
+	reloadDelay = 10 * time.Millisecond
+	ff, path := newFlags(t, testFlags)
+	ctx := context.Background()
+
+	assert.False(t, ff.IsEnabled(ctx, "killed", nil), "should be disabled")
+
+	writeFlags(t, path, `{"flags": {"killed": {"enabled": true}}}`)
+	assert.Eventually(t, func() bool { return ff.IsEnabled(ctx, "killed", nil) }, 5*time.Second, 20*time.Millisecond, "should be reloaded")
+
+	// an invalid file keeps the flags
+	writeFlags(t, path, `{"flags": {"killed": {"enabled": true, "rules": [{"operator": "like"}]}}}`)
+	time.Sleep(200 * time.Millisecond)
+	assert.True(t, ff.IsEnabled(ctx, "killed", nil), "current flags should be kept")
+}
+
+func TestRequireFlag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ff, _ := newFlags(t, testFlags)
+
+	router := gin.New()
+	router.GET("/beta", RequireFlag(ff, "beta"), func(c *gin.Context) { c.String(http.StatusOK, "beta") })
+	router.GET("/pricing", RequireFlag(ff, "pricing"), func(c *gin.Context) { c.String(http.StatusOK, "pricing") })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/beta", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "should be equal")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pricing", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+}
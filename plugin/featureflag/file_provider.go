@@ -0,0 +1,287 @@
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+const (
+	OperatorIn    = "in"
+	OperatorNotIn = "not_in"
+)
+
+// changes of the file are applied after it, editors write a file in several steps
+var reloadDelay = 100 * time.Millisecond
+
+// fileFlags is the JSON file of the file provider:
+//
+//	{"flags": {"new-checkout": {
+//	  "enabled": true,
+//	  "rollout": 25,
+//	  "rules": [{"attribute": "country", "operator": "in", "values": ["VN"], "rollout": 100}],
+//	  "variants": {"control": 50, "treatment": 50}
+//	}}}
+type fileFlags struct {
+	Flags map[string]*flagConfig `json:"flags"`
+}
+
+type flagConfig struct {
+	Enabled bool `json:"enabled"`
+	// percentage of the keys the flag is enabled for, nil is 100
+	Rollout *float64 `json:"rollout,omitempty"`
+	// the first matching rule decides, the rollout applies when none matches
+	Rules []flagRule `json:"rules,omitempty"`
+	// weights of the variants of the enabled keys
+	Variants map[string]float64 `json:"variants,omitempty"`
+}
+
+type flagRule struct {
+	Attribute string   `json:"attribute"`
+	Operator  string   `json:"operator"`
+	Values    []string `json:"values"`
+	// percentage of the matching keys, nil is 100
+	Rollout *float64 `json:"rollout,omitempty"`
+}
+
+func (r flagRule) matches(attrs map[string]any) bool {
+	value, ok := attrs[r.Attribute]
+	in := false
+	if ok {
+		s := fmt.Sprint(value)
+		for _, v := range r.Values {
+			if v == s {
+				in = true
+				break
+			}
+		}
+	}
+
+	if r.Operator == OperatorNotIn {
+		return ok && !in
+	}
+	return in
+}
+
+func (fc *fileFlags) validate() error {
+	for name, f := range fc.Flags {
+		if f == nil {
+			return fmt.Errorf("flag %s is null", name)
+		}
+		for _, r := range f.Rules {
+			if r.Operator != OperatorIn && r.Operator != OperatorNotIn {
+				return fmt.Errorf("rule of flag %s has operator %q, must be %s or %s", name, r.Operator, OperatorIn, OperatorNotIn)
+			}
+		}
+	}
+	return nil
+}
+
+// fileProvider evaluates the flags of a JSON file overridden by env vars,
+// the file is reloaded when it changes
+type fileProvider struct {
+	path      string
+	envPrefix string
+	logger    logger.Logger
+
+	flags   atomic.Pointer[fileFlags]
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newFileProvider(path, envPrefix string, log logger.Logger) (*fileProvider, error) {
+	fp := &fileProvider{path: path, envPrefix: envPrefix, logger: log}
+	if err := fp.load(); err != nil {
+		return nil, err
+	}
+	return fp, nil
+}
+
+func (fp *fileProvider) Name() string {
+	return "file"
+}
+
+// load reads the file and the env vars, the current flags are kept on errors
+func (fp *fileProvider) load() error {
+	flags := &fileFlags{Flags: map[string]*flagConfig{}}
+
+	if fp.path != "" {
+		data, err := os.ReadFile(fp.path)
+		if err != nil {
+			return fmt.Errorf("cannot read feature flags file: %w", err)
+		}
+		if err := json.Unmarshal(data, flags); err != nil {
+			return fmt.Errorf("invalid feature flags file %s: %w", fp.path, err)
+		}
+		if flags.Flags == nil {
+			flags.Flags = map[string]*flagConfig{}
+		}
+		if err := flags.validate(); err != nil {
+			return fmt.Errorf("invalid feature flags file %s: %w", fp.path, err)
+		}
+	}
+
+	if err := fp.applyEnv(flags); err != nil {
+		return err
+	}
+
+	fp.flags.Store(flags)
+	return nil
+}
+
+// applyEnv overrides the flags with <prefix>_<FLAG_NAME>=on|off|true|false|<percentage>,
+// flags only in env are named in lower case with - instead of _
+func (fp *fileProvider) applyEnv(flags *fileFlags) error {
+	if fp.envPrefix == "" {
+		return nil
+	}
+
+	byEnvName := map[string]string{}
+	for name := range flags.Flags {
+		byEnvName[envName(name)] = name
+	}
+
+	prefix := envName(fp.envPrefix) + "_"
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, prefix) || len(key) == len(prefix) {
+			continue
+		}
+
+		flagKey := strings.TrimPrefix(key, prefix)
+		name, ok := byEnvName[flagKey]
+		if !ok {
+			name = strings.ReplaceAll(strings.ToLower(flagKey), "_", "-")
+			flags.Flags[name] = &flagConfig{}
+		}
+
+		f := flags.Flags[name]
+		switch strings.ToLower(value) {
+		case "on", "true":
+			f.Enabled, f.Rollout, f.Rules = true, nil, nil
+		case "off", "false":
+			f.Enabled = false
+		default:
+			percentage, err := strconv.ParseFloat(value, 64)
+			if err != nil || percentage < 0 || percentage > 100 {
+				return fmt.Errorf("invalid value %q of env %s, must be on, off or a percentage", value, key)
+			}
+			f.Enabled, f.Rollout = true, &percentage
+		}
+	}
+
+	return nil
+}
+
+func envName(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+func (fp *fileProvider) Evaluate(_ context.Context, flagName string, evalCtx EvalContext) Evaluation {
+	f, ok := fp.flags.Load().Flags[flagName]
+	if !ok {
+		return Evaluation{Variant: VariantOff, Reason: ReasonNotFound}
+	}
+	if !f.Enabled {
+		return Evaluation{Variant: VariantOff, Reason: ReasonDisabled}
+	}
+
+	enabled, reason := false, ""
+	for _, r := range f.Rules {
+		if r.matches(evalCtx.Attributes) {
+			enabled, reason = inRollout(flagName, evalCtx.Key, r.Rollout), ReasonRule
+			break
+		}
+	}
+	if reason == "" {
+		enabled, reason = inRollout(flagName, evalCtx.Key, f.Rollout), ReasonRollout
+		if f.Rollout == nil {
+			reason = ReasonDefault
+		}
+	}
+
+	if !enabled {
+		return Evaluation{Variant: VariantOff, Reason: reason}
+	}
+	return Evaluation{Enabled: true, Variant: pickVariant(flagName, evalCtx.Key, f.Variants), Reason: reason}
+}
+
+// Start watches the directory of the file, which also sees the file
+// replaced by editors and by the symlink swap of Kubernetes ConfigMaps
+func (fp *fileProvider) Start() error {
+	if fp.path == "" {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(fp.path)); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+
+	fp.watcher = watcher
+	fp.done = make(chan struct{})
+	fp.wg.Add(1)
+	go fp.watch()
+	return nil
+}
+
+func (fp *fileProvider) watch() {
+	defer fp.wg.Done()
+
+	name := filepath.Base(fp.path)
+	var reload <-chan time.Time
+
+	for {
+		select {
+		case event, ok := <-fp.watcher.Events:
+			if !ok {
+				return
+			}
+			base := filepath.Base(event.Name)
+			if base == name || strings.HasPrefix(base, "..") {
+				reload = time.After(reloadDelay)
+			}
+		case err, ok := <-fp.watcher.Errors:
+			if !ok {
+				return
+			}
+			fp.logger.Warnf("feature flags watcher: %s", err.Error())
+		case <-reload:
+			reload = nil
+			if err := fp.load(); err != nil {
+				fp.logger.Errorf("cannot reload feature flags, the current flags are kept: %s", err.Error())
+				continue
+			}
+			fp.logger.Infof("feature flags reloaded from %s", fp.path)
+		case <-fp.done:
+			return
+		}
+	}
+}
+
+func (fp *fileProvider) Close() error {
+	if fp.watcher == nil {
+		return nil
+	}
+
+	close(fp.done)
+	err := fp.watcher.Close()
+	fp.wg.Wait()
+	fp.watcher = nil
+	return err
+}
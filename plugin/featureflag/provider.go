@@ -0,0 +1,96 @@
+package featureflag
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+)
+
+const (
+	// VariantOn is the variant of an enabled flag without variants
+	VariantOn = "on"
+	// VariantOff is the variant of a disabled flag
+	VariantOff = "off"
+
+	ReasonNotFound = "not_found"
+	ReasonDisabled = "disabled"
+	ReasonRule     = "rule"
+	ReasonRollout  = "rollout"
+	ReasonDefault  = "default"
+)
+
+// EvalContext is the subject of an evaluation
+type EvalContext struct {
+	// Key buckets the rollouts, a key is in or out of a rollout on every evaluation
+	Key        string
+	Attributes map[string]any
+}
+
+// Evaluation is the result of a flag for a subject
+type Evaluation struct {
+	Enabled bool
+	Variant string
+	// why the value was chosen, ex: rule, rollout, not_found
+	Reason string
+}
+
+// Provider evaluates flags, the file provider is the default.
+// Implement it to use a feature flag service like Unleash or LaunchDarkly.
+// Providers may implement Start() error and Close() error, they are called by Run and Stop.
+type Provider interface {
+	// Name is reported in the exposure events
+	Name() string
+	Evaluate(ctx context.Context, flagName string, evalCtx EvalContext) Evaluation
+}
+
+// Bucket returns the position of a key in [0, 100) for a flag, providers use it
+// for percentage rollouts so a key stays in the same side of a rollout
+func Bucket(flagName, key string) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(flagName))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return float64(h.Sum64()%10000) / 100
+}
+
+// inRollout tells whether a key is in the first percentage of the keys,
+// keys are required by partial rollouts
+func inRollout(flagName, key string, percentage *float64) bool {
+	switch {
+	case percentage == nil || *percentage >= 100:
+		return true
+	case *percentage <= 0 || key == "":
+		return false
+	default:
+		return Bucket(flagName, key) < *percentage
+	}
+}
+
+// pickVariant picks a variant by weight, independently of the rollout bucket
+func pickVariant(flagName, key string, weights map[string]float64) string {
+	if len(weights) == 0 {
+		return VariantOn
+	}
+
+	names := make([]string, 0, len(weights))
+	var total float64
+	for name, weight := range weights {
+		if weight > 0 {
+			names = append(names, name)
+			total += weight
+		}
+	}
+	if total == 0 {
+		return VariantOn
+	}
+	sort.Strings(names)
+
+	point := Bucket(flagName+"/variant", key) * total / 100
+	for _, name := range names {
+		point -= weights[name]
+		if point < 0 {
+			return name
+		}
+	}
+	return names[len(names)-1]
+}
@@ -0,0 +1,133 @@
+package gcppubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// self signed JWTs are accepted as access tokens by Google APIs with the service as audience
+	tokenAudience = "https://pubsub.googleapis.com/"
+	metadataURL   = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	// tokens are renewed this long before they expire
+	tokenExpiryDelta = time.Minute
+)
+
+// tokenSource returns the bearer token of requests, the emulator has none
+type tokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+}
+
+// newServiceAccountSource signs JWT access tokens with the key of a credentials file
+func newServiceAccountSource(path string) (tokenSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read credentials file: %w", err)
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+	if key.Type != "service_account" {
+		return nil, fmt.Errorf("credentials file %s is a %q, must be a service account key", path, key.Type)
+	}
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(key.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key of %s: %w", path, err)
+	}
+
+	return &cachedSource{fetch: func(context.Context) (string, time.Time, error) {
+		now := time.Now()
+		expiry := now.Add(time.Hour)
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+			Issuer:    key.ClientEmail,
+			Subject:   key.ClientEmail,
+			Audience:  jwt.ClaimStrings{tokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiry),
+		})
+		token.Header["kid"] = key.PrivateKeyID
+
+		signed, err := token.SignedString(privateKey)
+		return signed, expiry, err
+	}}, nil
+}
+
+// newMetadataSource gets the tokens of the attached service account from the metadata server,
+// it's the workload identity on GKE and the instance service account on GCE and Cloud Run
+func newMetadataSource(client *http.Client) tokenSource {
+	return &cachedSource{fetch: func(ctx context.Context) (string, time.Time, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("cannot get token from metadata server: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", time.Time{}, fmt.Errorf("cannot get token from metadata server: %s", resp.Status)
+		}
+
+		var body struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", time.Time{}, err
+		}
+		if body.AccessToken == "" {
+			return "", time.Time{}, errors.New("metadata server returned an empty token")
+		}
+
+		return body.AccessToken, time.Now().Add(time.Duration(body.ExpiresIn) * time.Second), nil
+	}}
+}
+
+// cachedSource reuses a token until it's about to expire
+type cachedSource struct {
+	fetch func(ctx context.Context) (string, time.Time, error)
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (s *cachedSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expiry) > tokenExpiryDelta {
+		return s.token, nil
+	}
+
+	token, expiry, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiry = token, expiry
+
+	return token, nil
+}
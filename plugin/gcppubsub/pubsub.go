@@ -0,0 +1,624 @@
+package gcppubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/taimaifika/go-sdk/plugin/gcppubsub"
+
+var (
+	defaultEndpoint       = "https://pubsub.googleapis.com"
+	defaultMaxOutstanding = 1000
+	defaultMaxExtension   = 10 * time.Minute
+	defaultGoroutines     = 4
+	defaultDrainTimeout   = 30 * time.Second
+
+	// ack deadline of the messages being handled, renewed at half of it
+	leaseDeadline   = 60 * time.Second
+	maxPullMessages = 100
+	pullRetryWait   = time.Second
+	// timeout of acks sent after the handler, the receiving may be stopped
+	ackTimeout = 10 * time.Second
+)
+
+// Message is a received message
+type Message struct {
+	ID          string
+	Data        []byte
+	Attributes  map[string]string
+	OrderingKey string
+	PublishTime time.Time
+	// 0 unless the subscription has a dead letter policy
+	DeliveryAttempt int
+}
+
+// Handler handles a message, ctx carries the trace of the publisher.
+// nil acks the message and an error nacks it for redelivery.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Client is returned by Get:
+//
+//	client := service.MustGet("pubsub").(gcppubsub.Client)
+type Client interface {
+	// Publish returns the message id. topic is the topic id or its full name projects/*/topics/*
+	Publish(ctx context.Context, topic string, data []byte, attrs map[string]string, opts ...PublishOption) (string, error)
+	// Subscribe calls handler for each message of subscription, the id or its full name
+	Subscribe(subscription string, handler Handler, opts ...SubscribeOption) error
+}
+
+type publishConfig struct {
+	orderingKey string
+}
+
+type PublishOption func(*publishConfig)
+
+// WithOrderingKey publishes in order with the messages of the same key,
+// the subscription must have message ordering enabled
+func WithOrderingKey(key string) PublishOption {
+	return func(c *publishConfig) {
+		c.orderingKey = key
+	}
+}
+
+type receiveSettings struct {
+	maxOutstanding int
+	maxExtension   time.Duration
+	goroutines     int
+}
+
+type SubscribeOption func(*receiveSettings)
+
+// WithMaxOutstandingMessages sets the max messages being handled at the same time
+func WithMaxOutstandingMessages(n int) SubscribeOption {
+	return func(s *receiveSettings) {
+		s.maxOutstanding = n
+	}
+}
+
+// WithMaxExtension sets how long the ack deadline of a message is extended while it's handled
+func WithMaxExtension(d time.Duration) SubscribeOption {
+	return func(s *receiveSettings) {
+		s.maxExtension = d
+	}
+}
+
+// WithNumGoroutines sets the number of goroutines pulling messages
+func WithNumGoroutines(n int) SubscribeOption {
+	return func(s *receiveSettings) {
+		s.goroutines = n
+	}
+}
+
+// subscription is kept until Run when Subscribe is called before it
+type subscription struct {
+	name     string
+	handler  Handler
+	settings receiveSettings
+}
+
+type pubsubClient struct {
+	name   string
+	prefix string
+	logger logger.Logger
+
+	projectID       string
+	credentialsFile string
+	emulatorHost    string
+	endpoint        string
+	settings        receiveSettings
+	drainTimeout    time.Duration
+
+	httpClient *http.Client
+	tokens     tokenSource
+	baseURL    string
+
+	mu      *sync.Mutex
+	running bool
+	pending []*subscription
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	receivers sync.WaitGroup
+	handlers  sync.WaitGroup
+	publishes sync.WaitGroup
+	// publishes of an ordering key are sent one at a time
+	keyLocks [64]sync.Mutex
+}
+
+func New(name, prefix string) *pubsubClient {
+	return &pubsubClient{name: name, prefix: prefix, mu: &sync.Mutex{}}
+}
+
+func (pc *pubsubClient) Name() string {
+	return pc.name
+}
+
+func (pc *pubsubClient) GetPrefix() string {
+	return pc.prefix
+}
+
+func (pc *pubsubClient) Get() interface{} {
+	return Client(pc)
+}
+
+func (pc *pubsubClient) InitFlags() {
+	pc.InitFlagSet(flag.CommandLine)
+}
+
+func (pc *pubsubClient) InitFlagSet(fs *flag.FlagSet) {
+	prefix := pc.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&pc.projectID, prefix+"project-id", "", "GCP project id, empty => disabled")
+	fs.StringVar(&pc.credentialsFile, prefix+"credentials-file", "", "service account key file, empty => workload identity or the attached service account")
+	fs.StringVar(&pc.emulatorHost, prefix+"emulator-host", os.Getenv("PUBSUB_EMULATOR_HOST"), "host:port of the Pub/Sub emulator, default is PUBSUB_EMULATOR_HOST")
+	fs.StringVar(&pc.endpoint, prefix+"endpoint", defaultEndpoint, "Pub/Sub API endpoint, ex: a regional endpoint for ordered publishing")
+	fs.IntVar(&pc.settings.maxOutstanding, prefix+"max-outstanding-messages", defaultMaxOutstanding, "max messages handled at the same time per subscription")
+	fs.DurationVar(&pc.settings.maxExtension, prefix+"max-extension", defaultMaxExtension, "max time the ack deadline of a message is extended while it's handled")
+	fs.IntVar(&pc.settings.goroutines, prefix+"num-goroutines", defaultGoroutines, "goroutines pulling messages per subscription")
+	fs.DurationVar(&pc.drainTimeout, prefix+"drain-timeout", defaultDrainTimeout, "max time to wait for in-flight handlers and publishes when stopping")
+}
+
+func (pc *pubsubClient) isDisabled() bool {
+	return pc.projectID == ""
+}
+
+func (pc *pubsubClient) Configure() error {
+	pc.logger = logger.GetCurrent().GetLogger(pc.name)
+	pc.httpClient = &http.Client{}
+
+	switch {
+	case pc.emulatorHost != "":
+		pc.baseURL = "http://" + pc.emulatorHost
+		pc.tokens = nil
+	case pc.credentialsFile != "":
+		tokens, err := newServiceAccountSource(pc.credentialsFile)
+		if err != nil {
+			return err
+		}
+		pc.baseURL, pc.tokens = strings.TrimSuffix(pc.endpoint, "/"), tokens
+	default:
+		pc.baseURL, pc.tokens = strings.TrimSuffix(pc.endpoint, "/"), newMetadataSource(pc.httpClient)
+	}
+
+	return nil
+}
+
+// Run starts the subscriptions made before it
+func (pc *pubsubClient) Run() error {
+	if pc.isDisabled() {
+		return nil
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.running {
+		return nil
+	}
+
+	if err := pc.Configure(); err != nil {
+		return err
+	}
+
+	pc.ctx, pc.cancel = context.WithCancel(context.Background())
+	pc.running = true
+	for _, s := range pc.pending {
+		pc.startReceiving(s)
+	}
+	pc.pending = nil
+
+	if pc.emulatorHost != "" {
+		pc.logger.Infof("using Pub/Sub emulator at %s", pc.emulatorHost)
+	}
+
+	return nil
+}
+
+func (pc *pubsubClient) Publish(ctx context.Context, topic string, data []byte, attrs map[string]string, opts ...PublishOption) (string, error) {
+	pc.mu.Lock()
+	if !pc.running {
+		pc.mu.Unlock()
+		return "", errors.New("pubsub is not running")
+	}
+	pc.publishes.Add(1)
+	pc.mu.Unlock()
+	defer pc.publishes.Done()
+
+	var cfg publishConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ctx, span := startSpan(ctx, topic, "publish", trace.SpanKindProducer)
+	defer span.End()
+
+	// the trace context is sent in the attributes, attrs of the caller are kept as is
+	attributes := make(map[string]string, len(attrs)+2)
+	for k, v := range attrs {
+		attributes[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(attributes))
+
+	if cfg.orderingKey != "" {
+		lock := pc.keyLock(cfg.orderingKey)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	req := publishRequest{Messages: []pubsubMessage{{Data: data, Attributes: attributes, OrderingKey: cfg.orderingKey}}}
+	var resp publishResponse
+	err := pc.call(ctx, pc.fullName("topics", topic)+":publish", req, &resp)
+	if err == nil && len(resp.MessageIDs) == 0 {
+		err = errors.New("pubsub returned no message id")
+	}
+	recordError(span, err)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.MessageIDs[0], nil
+}
+
+func (pc *pubsubClient) keyLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &pc.keyLocks[h.Sum32()%uint32(len(pc.keyLocks))]
+}
+
+func (pc *pubsubClient) Subscribe(name string, handler Handler, opts ...SubscribeOption) error {
+	s := &subscription{name: name, handler: handler, settings: pc.settings}
+	for _, opt := range opts {
+		opt(&s.settings)
+	}
+	if s.settings.maxOutstanding <= 0 || s.settings.goroutines <= 0 {
+		return fmt.Errorf("max outstanding messages and goroutines of %s must be positive", name)
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if !pc.running {
+		pc.pending = append(pc.pending, s)
+		return nil
+	}
+	pc.startReceiving(s)
+	return nil
+}
+
+// startReceiving must be called with mu held
+func (pc *pubsubClient) startReceiving(s *subscription) {
+	// a slot per message being handled
+	slots := make(chan struct{}, s.settings.maxOutstanding)
+
+	for i := 0; i < s.settings.goroutines; i++ {
+		pc.receivers.Add(1)
+		go pc.receive(s, slots)
+	}
+}
+
+// receive pulls messages while there are free slots, until the plugin stops
+func (pc *pubsubClient) receive(s *subscription, slots chan struct{}) {
+	defer pc.receivers.Done()
+
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-pc.ctx.Done():
+			return
+		}
+
+		n := 1
+	more:
+		for n < maxPullMessages {
+			select {
+			case slots <- struct{}{}:
+				n++
+			default:
+				break more
+			}
+		}
+
+		var resp pullResponse
+		err := pc.call(pc.ctx, pc.fullName("subscriptions", s.name)+":pull", pullRequest{MaxMessages: n}, &resp)
+
+		// the slots of the messages not received are released
+		for i := len(resp.ReceivedMessages); i < n; i++ {
+			<-slots
+		}
+
+		if err != nil {
+			if pc.ctx.Err() != nil {
+				return
+			}
+			pc.logger.Warnf("cannot pull messages of %s: %s", s.name, err.Error())
+			select {
+			case <-time.After(pullRetryWait):
+			case <-pc.ctx.Done():
+				return
+			}
+			continue
+		}
+
+		// messages of an ordering key are handled one after another in the order received
+		ordered := map[string][]receivedMessage{}
+		for _, rm := range resp.ReceivedMessages {
+			if key := rm.Message.OrderingKey; key != "" {
+				ordered[key] = append(ordered[key], rm)
+				continue
+			}
+			pc.handlers.Add(1)
+			go pc.handle(s, rm, func() { <-slots })
+		}
+		for _, rms := range ordered {
+			pc.handlers.Add(len(rms))
+			go func(rms []receivedMessage) {
+				for _, rm := range rms {
+					pc.handle(s, rm, func() { <-slots })
+				}
+			}(rms)
+		}
+	}
+}
+
+// handle runs the handler in a span linked to the publisher, then acks or nacks the message
+func (pc *pubsubClient) handle(s *subscription, rm receivedMessage, release func()) {
+	defer pc.handlers.Done()
+	defer release()
+
+	stopExtending := pc.extendDeadline(s, rm.AckID)
+
+	msg := rm.Message.toMessage(rm.DeliveryAttempt)
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(msg.Attributes))
+	ctx, span := startSpan(ctx, s.name, "process", trace.SpanKindConsumer)
+	defer span.End()
+
+	err := s.handler(ctx, msg)
+	stopExtending()
+	recordError(span, err)
+
+	ackCtx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+	defer cancel()
+
+	path := pc.fullName("subscriptions", s.name)
+	if err != nil {
+		pc.logger.Withs(logger.Fields{"subscription": s.name, "message_id": msg.ID, "error": err.Error()}).Error("cannot handle Pub/Sub message")
+		err = pc.call(ackCtx, path+":modifyAckDeadline", modifyAckDeadlineRequest{AckIDs: []string{rm.AckID}}, nil)
+	} else {
+		err = pc.call(ackCtx, path+":acknowledge", acknowledgeRequest{AckIDs: []string{rm.AckID}}, nil)
+	}
+	if err != nil {
+		pc.logger.Warnf("cannot ack Pub/Sub message %s of %s: %s", msg.ID, s.name, err.Error())
+	}
+}
+
+// extendDeadline keeps the message leased while it's handled, for max extension at most
+func (pc *pubsubClient) extendDeadline(s *subscription, ackID string) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		deadline := time.Now().Add(s.settings.maxExtension)
+		ticker := time.NewTicker(leaseDeadline / 2)
+		defer ticker.Stop()
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+			req := modifyAckDeadlineRequest{AckIDs: []string{ackID}, AckDeadlineSeconds: int(leaseDeadline / time.Second)}
+			if err := pc.call(ctx, pc.fullName("subscriptions", s.name)+":modifyAckDeadline", req, nil); err != nil {
+				pc.logger.Warnf("cannot extend ack deadline of %s: %s", s.name, err.Error())
+			}
+			cancel()
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if time.Now().After(deadline) {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// HealthCheck fails after Stop, a disabled plugin is always healthy
+func (pc *pubsubClient) HealthCheck(_ context.Context) error {
+	if pc.isDisabled() {
+		return nil
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if !pc.running {
+		return errors.New("pubsub is not running")
+	}
+	return nil
+}
+
+// Stop stops receiving and waits for the in-flight handlers and publishes.
+// It sends false when they are not done in the drain timeout.
+func (pc *pubsubClient) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		pc.mu.Lock()
+		if !pc.running {
+			pc.mu.Unlock()
+			c <- true
+			return
+		}
+		pc.running = false
+		pc.mu.Unlock()
+
+		pc.cancel()
+
+		done := make(chan struct{})
+		go func() {
+			pc.receivers.Wait()
+			pc.handlers.Wait()
+			pc.publishes.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			c <- true
+		case <-time.After(pc.drainTimeout):
+			pc.logger.Warnf("%s didn't drain in %s", pc.name, pc.drainTimeout)
+			c <- false
+		}
+	}()
+	return c
+}
+
+// fullName returns projects/<project>/<kind>/<id> unless name is a full name
+func (pc *pubsubClient) fullName(kind, name string) string {
+	if strings.HasPrefix(name, "projects/") {
+		return name
+	}
+	return "projects/" + pc.projectID + "/" + kind + "/" + name
+}
+
+// call posts a request of the REST API, out may be nil
+func (pc *pubsubClient) call(ctx context.Context, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pc.baseURL+"/v1/"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if pc.tokens != nil {
+		token, err := pc.tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := pc.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+				Status  string `json:"status"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error.Message == "" {
+			return fmt.Errorf("pubsub %s: %s", path, resp.Status)
+		}
+		return fmt.Errorf("pubsub %s: %s: %s", path, apiErr.Error.Status, apiErr.Error.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type pubsubMessage struct {
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	MessageID   string            `json:"messageId,omitempty"`
+	PublishTime time.Time         `json:"publishTime,omitempty"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+func (m pubsubMessage) toMessage(deliveryAttempt int) *Message {
+	return &Message{
+		ID:              m.MessageID,
+		Data:            m.Data,
+		Attributes:      m.Attributes,
+		OrderingKey:     m.OrderingKey,
+		PublishTime:     m.PublishTime,
+		DeliveryAttempt: deliveryAttempt,
+	}
+}
+
+type publishRequest struct {
+	Messages []pubsubMessage `json:"messages"`
+}
+
+type publishResponse struct {
+	MessageIDs []string `json:"messageIds"`
+}
+
+type pullRequest struct {
+	MaxMessages int `json:"maxMessages"`
+}
+
+type receivedMessage struct {
+	AckID           string        `json:"ackId"`
+	Message         pubsubMessage `json:"message"`
+	DeliveryAttempt int           `json:"deliveryAttempt"`
+}
+
+type pullResponse struct {
+	ReceivedMessages []receivedMessage `json:"receivedMessages"`
+}
+
+type acknowledgeRequest struct {
+	AckIDs []string `json:"ackIds"`
+}
+
+// a zero deadline nacks the messages
+type modifyAckDeadlineRequest struct {
+	AckIDs             []string `json:"ackIds"`
+	AckDeadlineSeconds int      `json:"ackDeadlineSeconds"`
+}
+
+func startSpan(ctx context.Context, destination, operation string, kind trace.SpanKind) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, destination+" "+operation,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			semconv.MessagingSystemGCPPubsub,
+			semconv.MessagingDestinationName(destination),
+			attribute.String("messaging.operation", operation),
+		),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
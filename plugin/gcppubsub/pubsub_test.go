@@ -0,0 +1,256 @@
+package gcppubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeServer serves the REST calls of one topic with one subscription
+type fakeServer struct {
+	messages chan receivedMessage
+
+	mu     sync.Mutex
+	nextID int
+	acked  []string
+	nacked []string
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, ":publish"):
+		var req publishRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		var resp publishResponse
+		for _, m := range req.Messages {
+			f.mu.Lock()
+			f.nextID++
+			id := fmt.Sprint(f.nextID)
+			f.mu.Unlock()
+
+			m.MessageID, m.PublishTime = id, time.Now()
+			f.messages <- receivedMessage{AckID: "ack-" + id, Message: m}
+			resp.MessageIDs = append(resp.MessageIDs, id)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	case strings.HasSuffix(r.URL.Path, ":pull"):
+		var resp pullResponse
+		select {
+		case m := <-f.messages:
+			resp.ReceivedMessages = append(resp.ReceivedMessages, m)
+		case <-time.After(100 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	case strings.HasSuffix(r.URL.Path, ":acknowledge"):
+		var req acknowledgeRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		f.acked = append(f.acked, req.AckIDs...)
+		f.mu.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	case strings.HasSuffix(r.URL.Path, ":modifyAckDeadline"):
+		var req modifyAckDeadlineRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.AckDeadlineSeconds == 0 {
+			f.mu.Lock()
+			f.nacked = append(f.nacked, req.AckIDs...)
+			f.mu.Unlock()
+		}
+		_, _ = w.Write([]byte("{}"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":404,"message":"not found","status":"NOT_FOUND"}}`))
+	}
+}
+
+func newClient(host string) *pubsubClient {
+	logger.InitServLogger(false)
+
+	pc := New("pubsub", "pubsub")
+	pc.projectID = "test-project"
+	pc.emulatorHost = host
+	pc.settings = receiveSettings{maxOutstanding: 10, maxExtension: time.Minute, goroutines: 2}
+	pc.drainTimeout = 5 * time.Second
+	return pc
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	fake := &fakeServer{messages: make(chan receivedMessage, 10)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	pc := newClient(strings.TrimPrefix(srv.URL, "http://"))
+
+	received := make(chan *Message, 2)
+	contexts := make(chan trace.SpanContext, 2)
+	// subscribed at Run
+	assert.Nil(t, pc.Subscribe("orders-workers", func(ctx context.Context, msg *Message) error {
+		received <- msg
+		contexts <- trace.SpanContextFromContext(ctx)
+		if string(msg.Data) == "fail" {
+			return errors.New("cannot handle")
+		}
+		return nil
+	}), "must be nil")
+
+	_, err := pc.Publish(context.Background(), "orders", []byte("1"), nil)
+	assert.NotNil(t, err, "should be an error before Run")
+
+	assert.Nil(t, pc.Run(), "must be nil")
+	assert.Nil(t, pc.HealthCheck(context.Background()), "must be nil")
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "parent")
+	id, err := pc.Publish(ctx, "orders", []byte("1"), map[string]string{"kind": "created"}, WithOrderingKey("customer-1"))
+	span.End()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "1", id, "should be equal")
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, []byte("1"), msg.Data, "should be equal")
+		assert.Equal(t, "created", msg.Attributes["kind"], "should be equal")
+		assert.Equal(t, "customer-1", msg.OrderingKey, "should be equal")
+		assert.Equal(t, span.SpanContext().TraceID(), (<-contexts).TraceID(), "trace should be propagated")
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	_, err = pc.Publish(context.Background(), "orders", []byte("fail"), nil)
+	assert.Nil(t, err, "must be nil")
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	assert.True(t, <-pc.Stop(), "should be drained")
+	assert.NotNil(t, pc.HealthCheck(context.Background()), "should be an error after Stop")
+
+	fake.mu.Lock()
+	assert.Equal(t, []string{"ack-1"}, fake.acked, "should be equal")
+	assert.Equal(t, []string{"ack-2"}, fake.nacked, "should be equal")
+	fake.mu.Unlock()
+
+	var names []string
+	for _, s := range recorder.Ended() {
+		names = append(names, s.Name())
+	}
+	assert.Contains(t, names, "orders publish", "should contain publish span")
+	assert.Contains(t, names, "orders-workers process", "should contain process span")
+}
+
+func TestStopWaitsForHandlers(t *testing.T) {
+	fake := &fakeServer{messages: make(chan receivedMessage, 10)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	pc := newClient(strings.TrimPrefix(srv.URL, "http://"))
+	assert.Nil(t, pc.Run(), "must be nil")
+
+	started := make(chan struct{})
+	assert.Nil(t, pc.Subscribe("slow", func(ctx context.Context, msg *Message) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	}), "must be nil")
+
+	_, err := pc.Publish(context.Background(), "slow", []byte("1"), nil)
+	assert.Nil(t, err, "must be nil")
+	<-started
+
+	assert.True(t, <-pc.Stop(), "should be drained")
+
+	fake.mu.Lock()
+	assert.Equal(t, []string{"ack-1"}, fake.acked, "in-flight message should be acked")
+	fake.mu.Unlock()
+}
+
+func TestAPIError(t *testing.T) {
+	srv := httptest.NewServer(&fakeServer{})
+	defer srv.Close()
+
+	pc := newClient(strings.TrimPrefix(srv.URL, "http://"))
+	assert.Nil(t, pc.Run(), "must be nil")
+	defer pc.Stop()
+
+	err := pc.call(context.Background(), "projects/test-project/topics/orders:get", struct{}{}, nil)
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "NOT_FOUND: not found", "should contain the API error")
+}
+
+// TestEmulator runs against the Pub/Sub emulator:
+//
+//	gcloud beta emulators pubsub start --host-port=localhost:8085
+//	PUBSUB_EMULATOR_HOST=localhost:8085 go test ./plugin/gcppubsub -run TestEmulator
+func TestEmulator(t *testing.T) {
+	host := os.Getenv("PUBSUB_EMULATOR_HOST")
+	if host == "" {
+		t.Skip("PUBSUB_EMULATOR_HOST is not set")
+	}
+
+	pc := newClient(host)
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	topic, sub := "topic-"+suffix, "sub-"+suffix
+
+	put := func(path string, body interface{}) {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPut, "http://"+host+"/v1/projects/test-project/"+path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err, "must be nil")
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "should be equal")
+		resp.Body.Close()
+	}
+	put("topics/"+topic, struct{}{})
+	put("subscriptions/"+sub, map[string]interface{}{
+		"topic":                 "projects/test-project/topics/" + topic,
+		"enableMessageOrdering": true,
+	})
+
+	received := make(chan string, 3)
+	assert.Nil(t, pc.Subscribe(sub, func(ctx context.Context, msg *Message) error {
+		received <- string(msg.Data)
+		return nil
+	}), "must be nil")
+	assert.Nil(t, pc.Run(), "must be nil")
+
+	for _, data := range []string{"1", "2", "3"} {
+		_, err := pc.Publish(context.Background(), topic, []byte(data), nil, WithOrderingKey("key"))
+		assert.Nil(t, err, "must be nil")
+	}
+
+	var actual []string
+	for len(actual) < 3 {
+		select {
+		case data := <-received:
+			actual = append(actual, data)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("received %v", actual)
+		}
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, actual, "should be in order")
+	assert.True(t, <-pc.Stop(), "should be drained")
+}
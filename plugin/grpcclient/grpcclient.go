@@ -0,0 +1,442 @@
+package grpcclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/otel"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	defaultMaxRecvMsgSize   = 4 * 1024 * 1024
+	defaultDialTimeout      = 10 * time.Second
+	defaultKeepAliveTimeout = 20 * time.Second
+)
+
+type Config struct {
+	Addr                  string        `json:"grpc_addr"`
+	TLS                   bool          `json:"grpc_tls"`
+	TLSCAFile             string        `json:"grpc_tls_ca_file"`
+	TLSCertFile           string        `json:"grpc_tls_cert_file"`
+	TLSKeyFile            string        `json:"grpc_tls_key_file"`
+	TLSServerName         string        `json:"grpc_tls_server_name"`
+	TLSSkipVerify         bool          `json:"grpc_tls_skip_verify"`
+	KeepAliveTime         time.Duration `json:"grpc_keepalive_time"`
+	KeepAliveTimeout      time.Duration `json:"grpc_keepalive_timeout"`
+	KeepAliveWithoutCalls bool          `json:"grpc_keepalive_without_calls"`
+	MaxRecvMsgSize        int           `json:"grpc_max_recv_msg_size"`
+	RetryPolicy           string        `json:"grpc_retry_policy"`
+	HedgingPolicy         string        `json:"grpc_hedging_policy"`
+	EagerDial             bool          `json:"grpc_eager_dial"`
+	DialTimeout           time.Duration `json:"grpc_dial_timeout"`
+	// HMAC secret shared by the services, the requester of the call context is sent signed
+	RequesterSecret string `json:"-"`
+}
+
+type grpcClient struct {
+	Config
+	name   string
+	prefix string
+
+	logger logger.Logger
+	mu     *sync.Mutex
+	conn   *grpc.ClientConn
+}
+
+// New creates a client of one target, flags are <prefix>-grpc-*. Get returns the *grpc.ClientConn:
+//
+//	conn := service.MustGet("users").(*grpc.ClientConn)
+func New(name, prefix string) *grpcClient {
+	return &grpcClient{name: name, prefix: prefix, mu: &sync.Mutex{}}
+}
+
+func (gc *grpcClient) Name() string {
+	return gc.name
+}
+
+func (gc *grpcClient) GetPrefix() string {
+	return gc.prefix
+}
+
+// Get returns the *grpc.ClientConn, nil before Run
+func (gc *grpcClient) Get() interface{} {
+	if conn := gc.clientConn(); conn != nil {
+		return conn
+	}
+	return nil
+}
+
+func (gc *grpcClient) clientConn() *grpc.ClientConn {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return gc.conn
+}
+
+func (gc *grpcClient) InitFlags() {
+	gc.InitFlagSet(flag.CommandLine)
+}
+
+func (gc *grpcClient) InitFlagSet(fs *flag.FlagSet) {
+	prefix := gc.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+	prefix += "grpc"
+
+	fs.StringVar(&gc.Addr, prefix+"-addr", "", "gRPC target. Ex: localhost:50051 or dns:///users:50051, empty => disabled")
+	fs.BoolVar(&gc.TLS, prefix+"-tls", false, "connect with TLS")
+	fs.StringVar(&gc.TLSCAFile, prefix+"-tls-ca-file", "", "CA certificate of the server, default is the system pool")
+	fs.StringVar(&gc.TLSCertFile, prefix+"-tls-cert-file", "", "client certificate, for mutual TLS")
+	fs.StringVar(&gc.TLSKeyFile, prefix+"-tls-key-file", "", "client certificate key, for mutual TLS")
+	fs.StringVar(&gc.TLSServerName, prefix+"-tls-server-name", "", "expected server name, default is the host of the target")
+	fs.BoolVar(&gc.TLSSkipVerify, prefix+"-tls-skip-verify", false, "don't verify the server certificate")
+	fs.DurationVar(&gc.KeepAliveTime, prefix+"-keepalive-time", 0, "ping the server after this idle time, 0 => disabled")
+	fs.DurationVar(&gc.KeepAliveTimeout, prefix+"-keepalive-timeout", defaultKeepAliveTimeout, "close the connection when a ping isn't answered in this time")
+	fs.BoolVar(&gc.KeepAliveWithoutCalls, prefix+"-keepalive-without-calls", false, "ping even without active calls")
+	fs.IntVar(&gc.MaxRecvMsgSize, prefix+"-max-recv-msg-size", defaultMaxRecvMsgSize, "max size in bytes of a message the client can receive")
+	fs.StringVar(&gc.RetryPolicy, prefix+"-retry-policy", "", "retry policy of all methods. Ex: max-attempts=3,initial-backoff=100ms,max-backoff=1s,backoff-multiplier=2,codes=UNAVAILABLE")
+	fs.StringVar(&gc.HedgingPolicy, prefix+"-hedging-policy", "", "hedging policy of all methods, exclusive with retries. Ex: max-attempts=3,delay=50ms,codes=UNAVAILABLE")
+	fs.BoolVar(&gc.EagerDial, prefix+"-eager-dial", false, "connect when the service starts and fail if the target isn't ready, else connect on the first call")
+	fs.DurationVar(&gc.DialTimeout, prefix+"-dial-timeout", defaultDialTimeout, "max time to wait for an eager connection")
+	fs.StringVar(&gc.RequesterSecret, prefix+"-requester-secret", "", "HMAC secret shared with the target, the requester of the call context is sent in the x-requester metadata. Empty => not sent")
+}
+
+func (gc *grpcClient) isDisabled() bool {
+	return gc.Addr == ""
+}
+
+func (gc *grpcClient) Configure() error {
+	gc.logger = logger.GetCurrent().GetLogger(gc.name)
+	if gc.isDisabled() {
+		return nil
+	}
+
+	_, err := gc.dialOptions()
+	return err
+}
+
+func (gc *grpcClient) dialOptions() ([]grpc.DialOption, error) {
+	creds := insecure.NewCredentials()
+	if gc.TLS {
+		tlsConfig, err := gc.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(gc.MaxRecvMsgSize)),
+	}
+
+	if gc.KeepAliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                gc.KeepAliveTime,
+			Timeout:             gc.KeepAliveTimeout,
+			PermitWithoutStream: gc.KeepAliveWithoutCalls,
+		}))
+	}
+
+	serviceConfig, err := buildServiceConfig(gc.RetryPolicy, gc.HedgingPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if serviceConfig != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(serviceConfig))
+	}
+
+	if otel.IsEnabled() {
+		opts = append(opts, grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+	}
+
+	if signer := sdkcm.NewRequesterSigner(gc.RequesterSecret); signer != nil {
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(UnaryRequesterInterceptor(signer)),
+			grpc.WithChainStreamInterceptor(StreamRequesterInterceptor(signer)),
+		)
+	}
+
+	return opts, nil
+}
+
+func (gc *grpcClient) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: gc.TLSServerName, InsecureSkipVerify: gc.TLSSkipVerify}
+
+	if gc.TLSCAFile != "" {
+		pem, err := os.ReadFile(gc.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read tls ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in tls ca file %s", gc.TLSCAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if gc.TLSCertFile != "" || gc.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(gc.TLSCertFile, gc.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load tls client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// Run creates the connection, it's only dialed now with eager-dial
+func (gc *grpcClient) Run() error {
+	if gc.isDisabled() || gc.clientConn() != nil {
+		return nil
+	}
+
+	if err := gc.Configure(); err != nil {
+		return err
+	}
+
+	opts, err := gc.dialOptions()
+	if err != nil {
+		return err
+	}
+
+	conn, err := grpc.NewClient(gc.Addr, opts...)
+	if err != nil {
+		return fmt.Errorf("cannot create gRPC client of %s: %w", gc.Addr, err)
+	}
+
+	if gc.EagerDial {
+		if err := waitReady(conn, gc.DialTimeout); err != nil {
+			_ = conn.Close()
+			gc.logger.Errorf("cannot connect %s: %s", gc.Addr, err.Error())
+			return err
+		}
+		gc.logger.Infof("connected to %s", gc.Addr)
+	}
+
+	gc.mu.Lock()
+	gc.conn = conn
+	gc.mu.Unlock()
+
+	return nil
+}
+
+func waitReady(conn *grpc.ClientConn, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection is %s after %s", strings.ToLower(state.String()), timeout)
+		}
+	}
+}
+
+// HealthCheck uses the gRPC health service of the target, targets without it
+// are healthy unless the connection is failing. A disabled plugin is always healthy.
+func (gc *grpcClient) HealthCheck(ctx context.Context) error {
+	if gc.isDisabled() {
+		return nil
+	}
+
+	conn := gc.clientConn()
+	if conn == nil {
+		return errors.New("grpc client is not created")
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		if state := conn.GetState(); state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			return fmt.Errorf("connection to %s is %s", gc.Addr, strings.ToLower(state.String()))
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("%s is %s", gc.Addr, resp.Status)
+	}
+	return nil
+}
+
+func (gc *grpcClient) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		gc.mu.Lock()
+		conn := gc.conn
+		gc.conn = nil
+		gc.mu.Unlock()
+
+		if conn != nil {
+			if err := conn.Close(); err != nil {
+				gc.logger.Warnf("cannot close %s: %s", gc.name, err.Error())
+			}
+		}
+		c <- true
+	}()
+	return c
+}
+
+// buildServiceConfig returns the service config JSON applying the retry or
+// hedging policy to all methods, empty when there is no policy
+func buildServiceConfig(retryPolicy, hedgingPolicy string) (string, error) {
+	if retryPolicy == "" && hedgingPolicy == "" {
+		return "", nil
+	}
+	if retryPolicy != "" && hedgingPolicy != "" {
+		return "", errors.New("grpc retry and hedging policies are exclusive")
+	}
+
+	method := map[string]interface{}{"name": []map[string]string{{}}}
+
+	if retryPolicy != "" {
+		p, err := parsePolicy(retryPolicy, "max-attempts", "initial-backoff", "max-backoff", "backoff-multiplier", "codes")
+		if err != nil {
+			return "", fmt.Errorf("invalid grpc retry policy: %w", err)
+		}
+		method["retryPolicy"] = map[string]interface{}{
+			"maxAttempts":          p.int("max-attempts", 3),
+			"initialBackoff":       p.duration("initial-backoff", 100*time.Millisecond),
+			"maxBackoff":           p.duration("max-backoff", time.Second),
+			"backoffMultiplier":    p.float("backoff-multiplier", 2),
+			"retryableStatusCodes": p.codes("codes", "UNAVAILABLE"),
+		}
+		if p.err != nil {
+			return "", fmt.Errorf("invalid grpc retry policy: %w", p.err)
+		}
+	} else {
+		p, err := parsePolicy(hedgingPolicy, "max-attempts", "delay", "codes")
+		if err != nil {
+			return "", fmt.Errorf("invalid grpc hedging policy: %w", err)
+		}
+		method["hedgingPolicy"] = map[string]interface{}{
+			"maxAttempts":         p.int("max-attempts", 3),
+			"hedgingDelay":        p.duration("delay", 0),
+			"nonFatalStatusCodes": p.codes("codes", "UNAVAILABLE"),
+		}
+		if p.err != nil {
+			return "", fmt.Errorf("invalid grpc hedging policy: %w", p.err)
+		}
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"methodConfig": []interface{}{method}})
+	return string(b), err
+}
+
+// policy holds key=value pairs, the first invalid value is kept in err
+type policy struct {
+	values map[string]string
+	err    error
+}
+
+func parsePolicy(s string, keys ...string) (*policy, error) {
+	p := &policy{values: map[string]string{}}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		k, v, found := strings.Cut(pair, "=")
+		if !found {
+			// codes are separated by "|" or follow the codes key
+			if len(p.values["codes"]) > 0 {
+				p.values["codes"] += "|" + pair
+				continue
+			}
+			return nil, fmt.Errorf("%q must be key=value", pair)
+		}
+
+		k = strings.TrimSpace(k)
+		known := false
+		for _, key := range keys {
+			known = known || key == k
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown key %q, must be one of %s", k, strings.Join(keys, ", "))
+		}
+		p.values[k] = strings.TrimSpace(v)
+	}
+	return p, nil
+}
+
+func (p *policy) int(key string, def int) int {
+	v, ok := p.values[key]
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s: %w", key, err)
+	}
+	return n
+}
+
+func (p *policy) float(key string, def float64) float64 {
+	v, ok := p.values[key]
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s: %w", key, err)
+	}
+	return f
+}
+
+// duration is formatted as the service config wants, e.g. 0.1s
+func (p *policy) duration(key string, def time.Duration) string {
+	d := def
+	if v, ok := p.values[key]; ok {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil && p.err == nil {
+			p.err = fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+func (p *policy) codes(key string, def string) []string {
+	v, ok := p.values[key]
+	if !ok {
+		v = def
+	}
+
+	var result []string
+	for _, name := range strings.Split(v, "|") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		var c codes.Code
+		if err := c.UnmarshalJSON([]byte(strconv.Quote(name))); err != nil && p.err == nil {
+			p.err = fmt.Errorf("%s: unknown code %s", key, name)
+		}
+		result = append(result, name)
+	}
+	return result
+}
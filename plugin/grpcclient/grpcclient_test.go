@@ -0,0 +1,137 @@
+package grpcclient
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func startServer(t *testing.T, opts ...grpc.ServerOption) (string, *grpc.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "must be nil")
+
+	srv := grpc.NewServer(opts...)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String(), srv
+}
+
+func newClient(addr string) *grpcClient {
+	logger.InitServLogger(false)
+
+	gc := New("users", "users")
+	gc.Addr = addr
+	gc.MaxRecvMsgSize = defaultMaxRecvMsgSize
+	gc.DialTimeout = time.Second
+	return gc
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var calls atomic.Int32
+	// the first call is unavailable
+	addr, srv := startServer(t, grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if calls.Add(1) == 1 {
+			return nil, status.Error(codes.Unavailable, "try again")
+		}
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	gc := newClient(addr)
+	gc.RetryPolicy = "max-attempts=3,initial-backoff=10ms,max-backoff=50ms,codes=UNAVAILABLE"
+	assert.Nil(t, gc.Run(), "must be nil")
+	defer func() { <-gc.Stop() }()
+
+	conn := gc.Get().(*grpc.ClientConn)
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status, "should be equal")
+	assert.Equal(t, int32(2), calls.Load(), "should be retried")
+}
+
+func TestBuildServiceConfig(t *testing.T) {
+	cfg, err := buildServiceConfig("", "")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "", cfg, "should be equal")
+
+	cfg, err = buildServiceConfig("max-attempts=4,initial-backoff=100ms,codes=UNAVAILABLE,DEADLINE_EXCEEDED", "")
+	assert.Nil(t, err, "must be nil")
+	assert.JSONEq(t, `{"methodConfig":[{"name":[{}],"retryPolicy":{"maxAttempts":4,"initialBackoff":"0.1s","maxBackoff":"1s","backoffMultiplier":2,"retryableStatusCodes":["UNAVAILABLE","DEADLINE_EXCEEDED"]}}]}`, cfg, "should be equal")
+
+	cfg, err = buildServiceConfig("", "max-attempts=2,delay=50ms")
+	assert.Nil(t, err, "must be nil")
+	assert.JSONEq(t, `{"methodConfig":[{"name":[{}],"hedgingPolicy":{"maxAttempts":2,"hedgingDelay":"0.05s","nonFatalStatusCodes":["UNAVAILABLE"]}}]}`, cfg, "should be equal")
+
+	_, err = buildServiceConfig("max-attempts=3", "max-attempts=3")
+	assert.NotNil(t, err, "policies are exclusive")
+
+	_, err = buildServiceConfig("attempts=3", "")
+	assert.NotNil(t, err, "should be an error")
+
+	_, err = buildServiceConfig("max-backoff=soon", "")
+	assert.NotNil(t, err, "should be an error")
+
+	_, err = buildServiceConfig("codes=NOT_A_CODE", "")
+	assert.NotNil(t, err, "should be an error")
+}
+
+func TestEagerDial(t *testing.T) {
+	addr, _ := startServer(t)
+	gc := newClient(addr)
+	gc.EagerDial = true
+	assert.Nil(t, gc.Run(), "must be nil")
+	<-gc.Stop()
+
+	// nothing listens on port 1
+	gc = newClient("127.0.0.1:1")
+	gc.DialTimeout = 200 * time.Millisecond
+	gc.EagerDial = true
+	assert.NotNil(t, gc.Run(), "should be an error")
+	assert.Nil(t, gc.Get(), "must be nil")
+
+	gc.EagerDial = false
+	assert.Nil(t, gc.Run(), "lazy client doesn't connect")
+	<-gc.Stop()
+}
+
+func TestHealthCheck(t *testing.T) {
+	addr, srv := startServer(t)
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+
+	gc := newClient(addr)
+	assert.Nil(t, gc.Run(), "must be nil")
+	defer func() { <-gc.Stop() }()
+
+	ctx := context.Background()
+	assert.Nil(t, gc.HealthCheck(ctx), "must be nil")
+
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.NotNil(t, gc.HealthCheck(ctx), "should be an error")
+
+	// without the health service, the connection state is used
+	addr, _ = startServer(t)
+	plain := newClient(addr)
+	assert.Nil(t, plain.Run(), "must be nil")
+	defer func() { <-plain.Stop() }()
+	assert.Nil(t, plain.HealthCheck(ctx), "must be nil")
+}
+
+func TestDisabled(t *testing.T) {
+	gc := New("users", "users")
+	assert.Nil(t, gc.Run(), "must be nil")
+	assert.Nil(t, gc.Get(), "must be nil")
+	assert.Nil(t, gc.HealthCheck(context.Background()), "disabled plugin is always healthy")
+	assert.True(t, <-gc.Stop(), "should be true")
+}
@@ -0,0 +1,33 @@
+package grpcclient
+
+import (
+	"context"
+	"strings"
+
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryRequesterInterceptor sends the requester of the call context, signed
+// in the x-requester metadata, to the services trusting the same secret
+func UnaryRequesterInterceptor(signer *sdkcm.RequesterSigner) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(contextWithRequester(ctx, signer), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamRequesterInterceptor is UnaryRequesterInterceptor of the streams
+func StreamRequesterInterceptor(signer *sdkcm.RequesterSigner) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(contextWithRequester(ctx, signer), desc, cc, method, opts...)
+	}
+}
+
+func contextWithRequester(ctx context.Context, signer *sdkcm.RequesterSigner) context.Context {
+	value := signer.HeaderFromContext(ctx)
+	if value == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, strings.ToLower(sdkcm.RequesterHeader), value)
+}
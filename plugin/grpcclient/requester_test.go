@@ -0,0 +1,42 @@
+package grpcclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type testRequester struct{}
+
+func (testRequester) OAuthID() string       { return "sub-1" }
+func (testRequester) UserID() uint32        { return 7 }
+func (testRequester) GetSystemRole() string { return "admin" }
+func (testRequester) GetUser() interface{}  { return nil }
+
+func TestUnaryRequesterInterceptor(t *testing.T) {
+	signer := sdkcm.NewRequesterSigner("shared")
+
+	call := func(ctx context.Context) []string {
+		var values []string
+		err := UnaryRequesterInterceptor(signer)(ctx, "/test/Method", nil, nil, nil, func(ctx context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			values = md.Get("x-requester")
+			return nil
+		})
+		assert.Nil(t, err, "must be nil")
+		return values
+	}
+
+	values := call(sdkcm.ContextWithRequester(context.Background(), testRequester{}))
+	if assert.Len(t, values, 1, "should be sent") {
+		r, err := signer.Verify(values[0])
+		assert.Nil(t, err, "must be nil")
+		assert.Equal(t, "admin", r.GetSystemRole(), "should be equal")
+	}
+
+	assert.Empty(t, call(context.Background()), "should not be sent without requester")
+}
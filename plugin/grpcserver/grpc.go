@@ -0,0 +1,212 @@
+package grpcserver
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/otel"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+var (
+	defaultPort              = 50051
+	defaultMaxRecvMsgSize    = 4 * 1024 * 1024
+	defaultMaxSendMsgSize    = 4 * 1024 * 1024
+	defaultConnectionTimeout = 120 * time.Second
+	defaultDrainTimeout      = 10 * time.Second
+)
+
+type Config struct {
+	Port              int           `json:"grpc_port"`
+	BindAddr          string        `json:"grpc_bind_addr"`
+	MaxRecvMsgSize    int           `json:"grpc_max_recv_msg_size"`
+	MaxSendMsgSize    int           `json:"grpc_max_send_msg_size"`
+	ConnectionTimeout time.Duration `json:"grpc_connection_timeout"`
+	Reflection        bool          `json:"grpc_reflection"`
+	DrainTimeout      time.Duration `json:"grpc_drain_timeout"`
+	// HMAC secret shared by the services, the requesters they sign in x-requester are trusted
+	RequesterSecret string `json:"-"`
+}
+
+type grpcService struct {
+	Config
+	isEnabled bool
+	name      string
+
+	logger     logger.Logger
+	server     *grpc.Server
+	mu         *sync.Mutex
+	registrars []func(*grpc.Server)
+	// closed when Run returns
+	runDone chan struct{}
+}
+
+func New(name string) *grpcService {
+	return &grpcService{
+		name:       name,
+		mu:         &sync.Mutex{},
+		registrars: []func(*grpc.Server){},
+	}
+}
+
+func (gs *grpcService) Name() string {
+	return gs.name + "-grpc"
+}
+
+func (gs *grpcService) InitFlags() {
+	gs.InitFlagSet(flag.CommandLine)
+}
+
+func (gs *grpcService) InitFlagSet(fs *flag.FlagSet) {
+	prefix := "grpc"
+	fs.IntVar(&gs.Config.Port, prefix+"-port", defaultPort, "gRPC server Port. If 0 => get a random Port")
+	fs.StringVar(&gs.BindAddr, prefix+"-bind-addr", "", "gRPC server bind address")
+	fs.IntVar(&gs.MaxRecvMsgSize, prefix+"-max-recv-msg-size", defaultMaxRecvMsgSize, "max size in bytes of a message the gRPC server can receive")
+	fs.IntVar(&gs.MaxSendMsgSize, prefix+"-max-send-msg-size", defaultMaxSendMsgSize, "max size in bytes of a message the gRPC server can send")
+	fs.DurationVar(&gs.ConnectionTimeout, prefix+"-connection-timeout", defaultConnectionTimeout, "timeout of new gRPC connections, including the handshake")
+	fs.BoolVar(&gs.Reflection, prefix+"-reflection", false, "enable gRPC server reflection")
+	fs.StringVar(&gs.RequesterSecret, prefix+"-requester-secret", "", "HMAC secret shared with the other services, the requesters they send in the x-requester metadata are trusted. Empty => the metadata is ignored")
+	fs.DurationVar(&gs.DrainTimeout, prefix+"-drain-timeout", defaultDrainTimeout, "max time to wait for in-flight RPCs when stopping, then they are cancelled")
+}
+
+func (gs *grpcService) Configure() error {
+	gs.logger = logger.GetCurrent().GetLogger("grpc")
+
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(gs.MaxRecvMsgSize),
+		grpc.MaxSendMsgSize(gs.MaxSendMsgSize),
+	}
+
+	if gs.ConnectionTimeout > 0 {
+		opts = append(opts, grpc.ConnectionTimeout(gs.ConnectionTimeout))
+	}
+
+	if otel.IsEnabled() {
+		opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	}
+
+	if signer := sdkcm.NewRequesterSigner(gs.RequesterSecret); signer != nil {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(UnaryRequesterInterceptor(signer)),
+			grpc.ChainStreamInterceptor(StreamRequesterInterceptor(signer)),
+		)
+	}
+
+	gs.logger.Debug("init gRPC server...")
+	gs.mu.Lock()
+	gs.server = grpc.NewServer(opts...)
+	gs.mu.Unlock()
+
+	if gs.Reflection {
+		reflection.Register(gs.server)
+	}
+
+	return nil
+}
+
+func formatBindAddr(s string, p int) string {
+	if strings.Contains(s, ":") && !strings.Contains(s, "[") {
+		s = "[" + s + "]"
+	}
+	return fmt.Sprintf("%s:%d", s, p)
+}
+
+func (gs *grpcService) Run() error {
+	if !gs.isEnabled {
+		return nil
+	}
+
+	done := make(chan struct{})
+	gs.mu.Lock()
+	gs.runDone = done
+	gs.mu.Unlock()
+	defer close(done)
+
+	if err := gs.Configure(); err != nil {
+		return err
+	}
+
+	for _, reg := range gs.registrars {
+		reg(gs.server)
+	}
+
+	addr := formatBindAddr(gs.BindAddr, gs.Config.Port)
+	gs.logger.Debugf("start listen tcp %s...", addr)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("grpc server failed to listen on %s: %w", addr, err)
+	}
+
+	gs.mu.Lock()
+	gs.Config.Port = lis.Addr().(*net.TCPAddr).Port
+	gs.mu.Unlock()
+
+	gs.logger.Infof("listen on %s...", lis.Addr().String())
+
+	// Serve returns nil after GracefulStop or Stop
+	return gs.server.Serve(lis)
+}
+
+func (gs *grpcService) Port() int {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.Config.Port
+}
+
+// Stop waits for in-flight RPCs at most the drain timeout,
+// then cancels the remaining ones. It sends false if RPCs are cancelled.
+func (gs *grpcService) Stop() <-chan bool {
+	c := make(chan bool)
+
+	go func() {
+		gs.mu.Lock()
+		server, done := gs.server, gs.runDone
+		gs.mu.Unlock()
+
+		drained := true
+		if server != nil {
+			stopped := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+			case <-time.After(gs.DrainTimeout):
+				gs.logger.Warnf("gRPC server didn't drain in %s, stopping it", gs.DrainTimeout)
+				server.Stop()
+				drained = false
+			}
+		}
+
+		// wait for Run to return
+		if done != nil {
+			<-done
+		}
+		c <- drained
+	}()
+	return c
+}
+
+func (gs *grpcService) URI() string {
+	return formatBindAddr(gs.BindAddr, gs.Config.Port)
+}
+
+// AddRegistrar registers gRPC services, it enables the server
+func (gs *grpcService) AddRegistrar(reg func(*grpc.Server)) {
+	gs.isEnabled = true
+	gs.registrars = append(gs.registrars, reg)
+}
+
+func (gs *grpcService) GetConfig() Config {
+	return gs.Config
+}
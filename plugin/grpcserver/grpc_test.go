@@ -0,0 +1,111 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+)
+
+func startTestGrpcService(t *testing.T, config Config, reg func(*grpc.Server)) (*grpcService, *grpc.ClientConn) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.Config = config
+	gs.AddRegistrar(reg)
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- gs.Run() }()
+
+	assert.Eventually(t, func() bool { return gs.Port() != 0 }, time.Second, 10*time.Millisecond)
+
+	conn, err := grpc.NewClient(gs.URI(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err, "must be nil")
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		<-gs.Stop()
+		assert.Nil(t, <-errChan, "must be nil")
+	})
+
+	return gs, conn
+}
+
+func TestGrpcServiceServe(t *testing.T) {
+	_, conn := startTestGrpcService(t, Config{BindAddr: "127.0.0.1", MaxRecvMsgSize: defaultMaxRecvMsgSize, MaxSendMsgSize: defaultMaxSendMsgSize}, func(s *grpc.Server) {
+		healthpb.RegisterHealthServer(s, health.NewServer())
+	})
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status, "should be equal")
+
+	// reflection is disabled by default
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	assert.Nil(t, err, "must be nil")
+	_ = stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}})
+	_, err = stream.Recv()
+	assert.NotNil(t, err, "should be an error")
+}
+
+func TestGrpcServiceReflection(t *testing.T) {
+	_, conn := startTestGrpcService(t, Config{BindAddr: "127.0.0.1", Reflection: true, MaxRecvMsgSize: defaultMaxRecvMsgSize, MaxSendMsgSize: defaultMaxSendMsgSize}, func(s *grpc.Server) {
+		healthpb.RegisterHealthServer(s, health.NewServer())
+	})
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}}), "must be nil")
+
+	resp, err := stream.Recv()
+	assert.Nil(t, err, "must be nil")
+
+	var names []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		names = append(names, s.Name)
+	}
+	assert.Contains(t, names, "grpc.health.v1.Health")
+}
+
+type slowHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (slowHealthServer) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	select {
+	case <-time.After(5 * time.Second):
+	case <-ctx.Done():
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func TestGrpcServiceDrainTimeout(t *testing.T) {
+	logger.InitServLogger(false)
+
+	gs := New("test")
+	gs.Config = Config{BindAddr: "127.0.0.1", DrainTimeout: 100 * time.Millisecond, MaxRecvMsgSize: defaultMaxRecvMsgSize, MaxSendMsgSize: defaultMaxSendMsgSize}
+	gs.AddRegistrar(func(s *grpc.Server) { healthpb.RegisterHealthServer(s, slowHealthServer{}) })
+
+	go func() { _ = gs.Run() }()
+	assert.Eventually(t, func() bool { return gs.Port() != 0 }, time.Second, 10*time.Millisecond)
+
+	conn, err := grpc.NewClient(gs.URI(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err, "must be nil")
+	defer conn.Close()
+
+	go func() {
+		_, _ = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	<-gs.Stop()
+	assert.Less(t, time.Since(start), 2*time.Second, "in-flight RPCs must be cancelled after the drain timeout")
+}
@@ -0,0 +1,43 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryRequesterInterceptor stores the requester signed by the caller in the
+// x-requester metadata into the context, sdkcm.RequesterFromContext returns it.
+// Calls without it or with an invalid one are anonymous.
+func UnaryRequesterInterceptor(signer *sdkcm.RequesterSigner) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(contextWithRequester(ctx, signer), req)
+	}
+}
+
+// StreamRequesterInterceptor is UnaryRequesterInterceptor of the streams
+func StreamRequesterInterceptor(signer *sdkcm.RequesterSigner) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &requesterStream{ServerStream: ss, ctx: contextWithRequester(ss.Context(), signer)})
+	}
+}
+
+func contextWithRequester(ctx context.Context, signer *sdkcm.RequesterSigner) context.Context {
+	values := metadata.ValueFromIncomingContext(ctx, strings.ToLower(sdkcm.RequesterHeader))
+	if len(values) == 0 {
+		return ctx
+	}
+	return signer.ContextWithHeader(ctx, values[0])
+}
+
+type requesterStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requesterStream) Context() context.Context {
+	return s.ctx
+}
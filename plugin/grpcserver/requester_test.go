@@ -0,0 +1,44 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type testRequester struct{}
+
+func (testRequester) OAuthID() string       { return "sub-1" }
+func (testRequester) UserID() uint32        { return 7 }
+func (testRequester) GetSystemRole() string { return "admin" }
+func (testRequester) GetUser() interface{}  { return nil }
+
+func TestUnaryRequesterInterceptor(t *testing.T) {
+	signer := sdkcm.NewRequesterSigner("shared")
+	value, err := signer.Sign(testRequester{})
+	assert.Nil(t, err, "must be nil")
+
+	call := func(ctx context.Context) (sdkcm.Requester, bool) {
+		var requester sdkcm.Requester
+		var ok bool
+		_, err := UnaryRequesterInterceptor(signer)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			requester, ok = sdkcm.RequesterFromContext(ctx)
+			return nil, nil
+		})
+		assert.Nil(t, err, "must be nil")
+		return requester, ok
+	}
+
+	r, ok := call(metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-requester", value)))
+	assert.True(t, ok, "should be propagated")
+	assert.Equal(t, uint32(7), r.UserID(), "should be equal")
+
+	_, ok = call(context.Background())
+	assert.False(t, ok, "should be anonymous")
+	_, ok = call(metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-requester", "forged")))
+	assert.False(t, ok, "should be anonymous")
+}
@@ -0,0 +1,193 @@
+package httpclient
+
+import (
+	"context"
+	"flag"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/otel"
+	"github.com/taimaifika/go-sdk/sdkcm"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var (
+	defaultTimeout             = 30 * time.Second
+	defaultDialTimeout         = 5 * time.Second
+	defaultTLSHandshakeTimeout = 5 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 10
+	defaultRetryMax            = 2
+	defaultRetryBackoff        = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+	defaultBreakerOpenTimeout  = 30 * time.Second
+	defaultSlowThreshold       = 2 * time.Second
+)
+
+// Client is returned by Get:
+//
+//	client := service.MustGet("httpclient").(httpclient.Client)
+type Client interface {
+	// HTTPClient returns the shared client, with tracing, retries and the circuit breaker
+	HTTPClient() *http.Client
+	// DoWithContext sends req with the trace and request id of ctx, which can be a *gin.Context
+	DoWithContext(ctx context.Context, req *http.Request) (*http.Response, error)
+}
+
+type Config struct {
+	Timeout             time.Duration
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	AttemptTimeout      time.Duration
+	RetryMax            int
+	RetryBackoff        time.Duration
+	RetryMaxBackoff     time.Duration
+	BreakerFailures     int
+	BreakerOpenTimeout  time.Duration
+	SlowThreshold       time.Duration
+	// HMAC secret shared by the services, the requester of the request context is sent signed
+	RequesterSecret string
+}
+
+type httpClient struct {
+	Config
+	name   string
+	prefix string
+	logger logger.Logger
+	client *http.Client
+}
+
+func New(name, prefix string) *httpClient {
+	return &httpClient{name: name, prefix: prefix}
+}
+
+func (hc *httpClient) Name() string {
+	return hc.name
+}
+
+func (hc *httpClient) GetPrefix() string {
+	return hc.prefix
+}
+
+func (hc *httpClient) Get() interface{} {
+	return Client(hc)
+}
+
+func (hc *httpClient) InitFlags() {
+	hc.InitFlagSet(flag.CommandLine)
+}
+
+func (hc *httpClient) InitFlagSet(fs *flag.FlagSet) {
+	prefix := hc.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.DurationVar(&hc.Timeout, prefix+"timeout", defaultTimeout, "max time of a request including retries and reading the body, 0 => none")
+	fs.DurationVar(&hc.DialTimeout, prefix+"dial-timeout", defaultDialTimeout, "max time to open a connection")
+	fs.DurationVar(&hc.TLSHandshakeTimeout, prefix+"tls-handshake-timeout", defaultTLSHandshakeTimeout, "max time of the TLS handshake")
+	fs.DurationVar(&hc.IdleConnTimeout, prefix+"idle-conn-timeout", defaultIdleConnTimeout, "idle connections are closed after this time")
+	fs.IntVar(&hc.MaxIdleConns, prefix+"max-idle-conns", defaultMaxIdleConns, "max idle connections of all hosts")
+	fs.IntVar(&hc.MaxIdleConnsPerHost, prefix+"max-idle-conns-per-host", defaultMaxIdleConnsPerHost, "max idle connections of each host")
+	fs.IntVar(&hc.MaxConnsPerHost, prefix+"max-conns-per-host", 0, "max connections of each host, 0 => unlimited")
+	fs.DurationVar(&hc.AttemptTimeout, prefix+"attempt-timeout", 0, "max time of each attempt until the response headers, 0 => none")
+	fs.IntVar(&hc.RetryMax, prefix+"retry-max", defaultRetryMax, "max retries of idempotent requests failing with a network error, 429 or 5xx")
+	fs.DurationVar(&hc.RetryBackoff, prefix+"retry-backoff", defaultRetryBackoff, "base backoff between retries, doubled on each retry with full jitter")
+	fs.DurationVar(&hc.RetryMaxBackoff, prefix+"retry-max-backoff", defaultRetryMaxBackoff, "max backoff between retries")
+	fs.IntVar(&hc.BreakerFailures, prefix+"breaker-failures", 0, "consecutive failures of a host opening its circuit breaker, 0 => disabled")
+	fs.DurationVar(&hc.BreakerOpenTimeout, prefix+"breaker-open-timeout", defaultBreakerOpenTimeout, "time an open breaker rejects requests before a trial one")
+	fs.DurationVar(&hc.SlowThreshold, prefix+"slow-threshold", defaultSlowThreshold, "requests slower than this are logged, 0 => disabled")
+	fs.StringVar(&hc.RequesterSecret, prefix+"requester-secret", "", "HMAC secret shared with the called services, the requester of the request context is sent in the X-Requester header. Empty => not sent")
+}
+
+func (hc *httpClient) Configure() error {
+	hc.logger = logger.GetCurrent().GetLogger(hc.name)
+	hc.client = hc.newClient()
+	return nil
+}
+
+func (hc *httpClient) newClient() *http.Client {
+	base := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   hc.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: hc.TLSHandshakeTimeout,
+		IdleConnTimeout:     hc.IdleConnTimeout,
+		MaxIdleConns:        hc.MaxIdleConns,
+		MaxIdleConnsPerHost: hc.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     hc.MaxConnsPerHost,
+	}
+
+	var transport http.RoundTripper = base
+	if otel.IsEnabled() {
+		transport = otelhttp.NewTransport(transport)
+	}
+	if hc.BreakerFailures > 0 {
+		transport = newBreakerTransport(transport, hc.BreakerFailures, hc.BreakerOpenTimeout)
+	}
+	transport = &retryTransport{
+		next:           transport,
+		max:            hc.RetryMax,
+		backoff:        hc.RetryBackoff,
+		maxBackoff:     hc.RetryMaxBackoff,
+		attemptTimeout: hc.AttemptTimeout,
+	}
+	transport = &loggingTransport{next: transport, logger: hc.logger, slowThreshold: hc.SlowThreshold}
+	if signer := sdkcm.NewRequesterSigner(hc.RequesterSecret); signer != nil {
+		transport = NewRequesterTransport(transport, signer)
+	}
+
+	return &http.Client{Transport: transport, Timeout: hc.Timeout}
+}
+
+func (hc *httpClient) Run() error {
+	if hc.client == nil {
+		return hc.Configure()
+	}
+	return nil
+}
+
+func (hc *httpClient) HTTPClient() *http.Client {
+	return hc.client
+}
+
+func (hc *httpClient) DoWithContext(ctx context.Context, req *http.Request) (*http.Response, error) {
+	// the span, the request id and the requester are in the request context of gin
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		ctx = c.Request.Context()
+		if middleware.RequestIDFromContext(ctx) == "" {
+			if id := c.GetString(middleware.RequestIDKey); id != "" {
+				ctx = middleware.ContextWithRequestID(ctx, id)
+			}
+		}
+		if _, ok := sdkcm.RequesterFromContext(ctx); !ok {
+			if r, ok := c.Value(middleware.CurrentRequesterKey).(sdkcm.Requester); ok {
+				ctx = sdkcm.ContextWithRequester(ctx, r)
+			}
+		}
+	}
+	return hc.client.Do(req.WithContext(ctx))
+}
+
+func (hc *httpClient) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		if hc.client != nil {
+			hc.client.CloseIdleConnections()
+		}
+		c <- true
+	}()
+	return c
+}
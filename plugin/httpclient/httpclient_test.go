@@ -0,0 +1,278 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+func newClient(cfg Config) *httpClient {
+	logger.InitServLogger(false)
+
+	hc := New("httpclient", "httpclient")
+	hc.Config = Config{
+		Timeout:         5 * time.Second,
+		DialTimeout:     time.Second,
+		RetryMax:        2,
+		RetryBackoff:    time.Millisecond,
+		RetryMaxBackoff: 10 * time.Millisecond,
+	}
+	if cfg.RetryMax != 0 {
+		hc.RetryMax = cfg.RetryMax
+	}
+	hc.AttemptTimeout = cfg.AttemptTimeout
+	hc.BreakerFailures = cfg.BreakerFailures
+	hc.BreakerOpenTimeout = cfg.BreakerOpenTimeout
+	hc.SlowThreshold = cfg.SlowThreshold
+	hc.RequesterSecret = cfg.RequesterSecret
+	_ = hc.Run()
+	return hc
+}
+
+// flakyServer fails the first n requests with 500
+func flakyServer(t *testing.T, n int32) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(append([]byte("ok"), body...))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	srv, calls := flakyServer(t, 2)
+	hc := newClient(Config{})
+
+	resp, err := hc.HTTPClient().Get(srv.URL)
+	assert.Nil(t, err, "must be nil")
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, "ok", string(body), "should be equal")
+	assert.Equal(t, int32(3), calls.Load(), "should be retried twice")
+
+	// the body is sent again
+	srv, calls = flakyServer(t, 1)
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("!"))
+	resp, err = hc.HTTPClient().Do(req)
+	assert.Nil(t, err, "must be nil")
+	body, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, "ok!", string(body), "should be equal")
+	assert.Equal(t, int32(2), calls.Load(), "should be equal")
+
+	// retries are exhausted
+	srv, calls = flakyServer(t, 10)
+	resp, err = hc.HTTPClient().Get(srv.URL)
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "should be equal")
+	assert.Equal(t, int32(3), calls.Load(), "should be equal")
+}
+
+func TestNoRetryOfPost(t *testing.T) {
+	srv, calls := flakyServer(t, 1)
+	hc := newClient(Config{})
+
+	resp, err := hc.HTTPClient().Post(srv.URL, "text/plain", strings.NewReader("x"))
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "should be equal")
+	assert.Equal(t, int32(1), calls.Load(), "should not be retried")
+
+	// unless it has an idempotency key
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("x"))
+	req.Header.Set("Idempotency-Key", "order-1")
+	resp, err = hc.HTTPClient().Do(req)
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "should be equal")
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first request hangs
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	hc := newClient(Config{AttemptTimeout: 100 * time.Millisecond})
+	resp, err := hc.HTTPClient().Get(srv.URL)
+	assert.Nil(t, err, "must be nil")
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, "ok", string(body), "should be equal")
+	assert.Equal(t, int32(2), calls.Load(), "should be equal")
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	hc := newClient(Config{RetryMax: -1, BreakerFailures: 3, BreakerOpenTimeout: 100 * time.Millisecond})
+
+	for i := 0; i < 3; i++ {
+		resp, err := hc.HTTPClient().Get(srv.URL)
+		assert.Nil(t, err, "must be nil")
+		_ = resp.Body.Close()
+	}
+
+	_, err := hc.HTTPClient().Get(srv.URL)
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "should be open")
+	assert.Equal(t, int32(3), calls.Load(), "open breaker doesn't send requests")
+
+	// a trial request closes it after the timeout
+	time.Sleep(150 * time.Millisecond)
+	healthy.Store(true)
+	resp, err := hc.HTTPClient().Get(srv.URL)
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "should be equal")
+
+	resp, err = hc.HTTPClient().Get(srv.URL)
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+}
+
+func TestDoWithGinContext(t *testing.T) {
+	var requestID atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID.Store(r.Header.Get(middleware.RequestIDHeader))
+	}))
+	defer srv.Close()
+
+	hc := newClient(Config{})
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(middleware.RequestID())
+	engine.GET("/", func(c *gin.Context) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := hc.DoWithContext(c, req)
+		assert.Nil(t, err, "must be nil")
+		_ = resp.Body.Close()
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	engine.ServeHTTP(w, req)
+
+	assert.Equal(t, "req-1", requestID.Load(), "should be propagated")
+
+	// from a plain context
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := hc.DoWithContext(middleware.ContextWithRequestID(context.Background(), "req-2"), req)
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+	assert.Equal(t, "req-2", requestID.Load(), "should be propagated")
+}
+
+type testRequester struct{}
+
+func (testRequester) OAuthID() string       { return "sub-1" }
+func (testRequester) UserID() uint32        { return 7 }
+func (testRequester) GetSystemRole() string { return "admin" }
+func (testRequester) GetUser() interface{}  { return nil }
+
+func TestRequesterPropagation(t *testing.T) {
+	signer := sdkcm.NewRequesterSigner("shared")
+	var header atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header.Store(r.Header.Get(sdkcm.RequesterHeader))
+	}))
+	defer srv.Close()
+
+	hc := newClient(Config{RequesterSecret: "shared"})
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/", func(c *gin.Context) {
+		c.Set(middleware.CurrentRequesterKey, sdkcm.Requester(testRequester{}))
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := hc.DoWithContext(c, req)
+		assert.Nil(t, err, "must be nil")
+		_ = resp.Body.Close()
+	})
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	r, err := signer.Verify(header.Load().(string))
+	assert.Nil(t, err, "must be nil")
+	if assert.NotNil(t, r, "should be propagated") {
+		assert.Equal(t, uint32(7), r.UserID(), "should be equal")
+		assert.Equal(t, "admin", r.GetSystemRole(), "should be equal")
+		assert.Equal(t, "sub-1", r.OAuthID(), "should be equal")
+	}
+
+	// anonymous requests have no header
+	resp, err := hc.HTTPClient().Get(srv.URL)
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+	assert.Equal(t, "", header.Load(), "should be equal")
+}
+
+type recordingLogger struct {
+	logger.Logger
+	warnings *atomic.Int32
+}
+
+func (l recordingLogger) Withs(logger.Fields) logger.Logger {
+	return l
+}
+
+func (l recordingLogger) Warn(...interface{}) {
+	l.warnings.Add(1)
+}
+
+func TestSlowRequestLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	warnings := &atomic.Int32{}
+	transport := &loggingTransport{next: http.DefaultTransport, logger: recordingLogger{warnings: warnings}, slowThreshold: 10 * time.Millisecond}
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(srv.URL)
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(1), warnings.Load(), "should be logged")
+
+	transport.slowThreshold = time.Second
+	resp, err = client.Get(srv.URL)
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(1), warnings.Load(), "should not be logged")
+}
@@ -0,0 +1,259 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+// ErrCircuitOpen is returned without sending the request while the breaker of its host is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// loggingTransport sets the request id header and logs slow requests
+type loggingTransport struct {
+	next          http.RoundTripper
+	logger        logger.Logger
+	slowThreshold time.Duration
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id := middleware.RequestIDFromContext(req.Context()); id != "" && req.Header.Get(middleware.RequestIDHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	if latency := time.Since(start); t.slowThreshold > 0 && latency > t.slowThreshold {
+		fields := logger.Fields{
+			"method":     req.Method,
+			"host":       req.URL.Host,
+			"path":       req.URL.Path,
+			"latency_ms": latency.Milliseconds(),
+		}
+		if resp != nil {
+			fields["status"] = resp.StatusCode
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		t.logger.Withs(fields).Warn("slow outbound request")
+	}
+
+	return resp, err
+}
+
+// requesterTransport signs the requester of the request context in the X-Requester header
+type requesterTransport struct {
+	next   http.RoundTripper
+	signer *sdkcm.RequesterSigner
+}
+
+// NewRequesterTransport returns a transport sending the requester of the request
+// context, signed by signer, to the services trusting the same secret.
+// Requests without requester or already having the header are sent as is.
+func NewRequesterTransport(next http.RoundTripper, signer *sdkcm.RequesterSigner) http.RoundTripper {
+	return &requesterTransport{next: next, signer: signer}
+}
+
+func (t *requesterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(sdkcm.RequesterHeader) == "" {
+		if value := t.signer.HeaderFromContext(req.Context()); value != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set(sdkcm.RequesterHeader, value)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// retryTransport retries idempotent requests failing with a network error, 429 or 5xx
+type retryTransport struct {
+	next           http.RoundTripper
+	max            int
+	backoff        time.Duration
+	maxBackoff     time.Duration
+	attemptTimeout time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retryable := isIdempotent(req) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req, attempt)
+		if !retryable || attempt >= t.max || !shouldRetry(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		wait := t.wait(attempt, resp)
+		if resp != nil {
+			// the connection is reused when the body is read
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func (t *retryTransport) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+
+	if t.attemptTimeout <= 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.attemptTimeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the timeout stops at the headers, the body can be read until it's closed
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// wait is the backoff with full jitter, or Retry-After when the server sent it
+func (t *retryTransport) wait(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, t.maxBackoff)
+		}
+	}
+
+	backoff := min(t.backoff<<attempt, t.maxBackoff)
+	if backoff <= 0 {
+		return 0
+	}
+	return rand.N(backoff + 1)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || isServerError(resp.StatusCode)
+}
+
+func isServerError(status int) bool {
+	return status >= 500 && status != http.StatusNotImplemented
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// breakerTransport opens the breaker of a host after consecutive failures,
+// requests are rejected until the open timeout, then one trial request closes or reopens it
+type breakerTransport struct {
+	next        http.RoundTripper
+	failures    int
+	openTimeout time.Duration
+
+	mu       *sync.Mutex
+	breakers map[string]*breaker
+}
+
+type breaker struct {
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func newBreakerTransport(next http.RoundTripper, failures int, openTimeout time.Duration) *breakerTransport {
+	return &breakerTransport{
+		next:        next,
+		failures:    failures,
+		openTimeout: openTimeout,
+		mu:          &sync.Mutex{},
+		breakers:    map[string]*breaker{},
+	}
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if !t.allow(host) {
+		return nil, fmt.Errorf("%w for %s", ErrCircuitOpen, host)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	t.record(host, err == nil && !isServerError(resp.StatusCode))
+	return resp, err
+}
+
+func (t *breakerTransport) allow(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.breakers[host]
+	if !ok || b.failures < t.failures {
+		return true
+	}
+
+	// open, a single trial request is let through after the timeout
+	if b.trial || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (t *breakerTransport) record(host string, success bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if success {
+		delete(t.breakers, host)
+		return
+	}
+
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &breaker{}
+		t.breakers[host] = b
+	}
+	b.failures++
+	b.trial = false
+	if b.failures >= t.failures {
+		b.openUntil = time.Now().Add(t.openTimeout)
+	}
+}
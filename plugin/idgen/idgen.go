@@ -0,0 +1,84 @@
+package idgen
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+// idGen provides a sdkcm.UIDGenerator, every instance of the service must have its own shard id:
+//
+//	goservice.WithInitRunnable(idgen.New("idgen", "idgen"))
+//	gen := sc.MustGet("idgen").(*sdkcm.UIDGenerator)
+type idGen struct {
+	name   string
+	prefix string
+
+	shardID          uint
+	objectType       int
+	maxClockBackward time.Duration
+	generator        *sdkcm.UIDGenerator
+}
+
+func New(name, prefix string) *idGen {
+	return &idGen{name: name, prefix: prefix}
+}
+
+func (ig *idGen) Name() string {
+	return ig.name
+}
+
+func (ig *idGen) GetPrefix() string {
+	return ig.prefix
+}
+
+// Get returns the *sdkcm.UIDGenerator, nil before the plugin runs
+func (ig *idGen) Get() interface{} {
+	return ig.generator
+}
+
+func (ig *idGen) InitFlags() {
+	ig.InitFlagSet(flag.CommandLine)
+}
+
+func (ig *idGen) InitFlagSet(fs *flag.FlagSet) {
+	prefix := ig.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.UintVar(&ig.shardID, prefix+"shard-id", 0, fmt.Sprintf("shard id of the generated uids, unique per instance, max %d", sdkcm.MaxUIDGenShardID))
+	fs.IntVar(&ig.objectType, prefix+"object-type", 0, "object type of the generated uids")
+	fs.DurationVar(&ig.maxClockBackward, prefix+"max-clock-backward", 10*time.Millisecond, "max time waited for when the clock goes back, generating fails beyond")
+}
+
+func (ig *idGen) Configure() error {
+	if ig.generator != nil {
+		return nil
+	}
+
+	if ig.shardID > sdkcm.MaxUIDGenShardID {
+		return fmt.Errorf("invalid %s config: shard id must be at most %d", ig.name, sdkcm.MaxUIDGenShardID)
+	}
+
+	generator, err := sdkcm.NewUIDGenerator(uint32(ig.shardID), ig.objectType)
+	if err != nil {
+		return fmt.Errorf("invalid %s config: %w", ig.name, err)
+	}
+	generator.SetMaxClockBackward(ig.maxClockBackward)
+	ig.generator = generator
+
+	return nil
+}
+
+func (ig *idGen) Run() error {
+	return ig.Configure()
+}
+
+func (ig *idGen) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() { c <- true }()
+	return c
+}
@@ -0,0 +1,28 @@
+package idgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/sdkcm"
+)
+
+func TestConfigure(t *testing.T) {
+	ig := New("idgen", "idgen")
+	assert.Nil(t, ig.Get(), "must be nil before running")
+
+	ig.shardID = sdkcm.MaxUIDGenShardID + 1
+	assert.NotNil(t, ig.Configure(), "should be an error")
+
+	ig.shardID = 2
+	ig.objectType = 5
+	assert.Nil(t, ig.Run(), "must be nil")
+
+	gen, ok := ig.Get().(*sdkcm.UIDGenerator)
+	assert.True(t, ok, "should be a generator")
+
+	uid, err := gen.Next()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, 5, uid.GetObjectType(), "should be equal")
+	assert.True(t, <-ig.Stop(), "should stop")
+}
@@ -0,0 +1,92 @@
+package jwtauth
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+var defaultCacheTTL = 5 * time.Minute
+
+// jwtAuth validates access tokens for middleware.RequiredAuth.
+// Register it with the default prefix so RequiredAuth finds it:
+//
+//	goservice.WithInitRunnable(jwtauth.New("jwt", middleware.TokenValidatorPrefix))
+type jwtAuth struct {
+	name   string
+	prefix string
+	logger logger.Logger
+
+	config    middleware.JWTConfig
+	cacheTTL  time.Duration
+	validator middleware.TokenValidator
+}
+
+func New(name, prefix string) *jwtAuth {
+	return &jwtAuth{name: name, prefix: prefix}
+}
+
+func (ja *jwtAuth) Name() string {
+	return ja.name
+}
+
+func (ja *jwtAuth) GetPrefix() string {
+	return ja.prefix
+}
+
+func (ja *jwtAuth) Get() interface{} {
+	return ja
+}
+
+func (ja *jwtAuth) InitFlags() {
+	ja.InitFlagSet(flag.CommandLine)
+}
+
+func (ja *jwtAuth) InitFlagSet(fs *flag.FlagSet) {
+	prefix := ja.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&ja.config.Secret, prefix+"secret", "", "HS256 secret of access tokens")
+	fs.StringVar(&ja.config.JWKSURL, prefix+"jwks-url", "", "JSON Web Key Set URL verifying RS256 access tokens")
+	fs.StringVar(&ja.config.Issuer, prefix+"issuer", "", "required iss claim, empty => not checked")
+	fs.StringVar(&ja.config.Audience, prefix+"audience", "", "required aud claim, empty => not checked")
+	fs.DurationVar(&ja.config.JWKSRefreshInterval, prefix+"jwks-refresh-interval", 15*time.Minute, "max time the key set is cached")
+	fs.DurationVar(&ja.config.Leeway, prefix+"leeway", 0, "allowed clock skew when checking exp and nbf")
+	fs.DurationVar(&ja.cacheTTL, prefix+"cache-ttl", defaultCacheTTL, "how long authenticated users are cached, capped by the token expiry")
+}
+
+func (ja *jwtAuth) Configure() error {
+	ja.logger = logger.GetCurrent().GetLogger(ja.name)
+
+	validator, err := middleware.NewJWTValidator(ja.config)
+	if err != nil {
+		return fmt.Errorf("invalid %s config: %w", ja.name, err)
+	}
+	ja.validator = validator
+
+	return nil
+}
+
+func (ja *jwtAuth) Run() error {
+	return ja.Configure()
+}
+
+func (ja *jwtAuth) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() { c <- true }()
+	return c
+}
+
+func (ja *jwtAuth) ValidateToken(ctx context.Context, token string) (*middleware.TokenClaims, error) {
+	return ja.validator.ValidateToken(ctx, token)
+}
+
+func (ja *jwtAuth) CacheTTL() time.Duration {
+	return ja.cacheTTL
+}
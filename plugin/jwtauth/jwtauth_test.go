@@ -0,0 +1,33 @@
+package jwtauth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func TestConfigure(t *testing.T) {
+	logger.InitServLogger(false)
+
+	ja := New("jwt", "jwt")
+	assert.NotNil(t, ja.Configure(), "should be an error")
+
+	ja.config.Secret = "secret"
+	ja.cacheTTL = time.Minute
+	assert.Nil(t, ja.Configure(), "must be nil")
+	assert.Equal(t, time.Minute, ja.CacheTTL(), "should be equal")
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "user-1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("secret"))
+	assert.Nil(t, err, "must be nil")
+
+	claims, err := ja.ValidateToken(context.Background(), token)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "user-1", claims.Subject, "should be equal")
+}
@@ -0,0 +1,445 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/taimaifika/go-sdk/plugin/nats"
+
+var (
+	defaultMaxReconnects = 60
+	defaultReconnectWait = 2 * time.Second
+	defaultDrainTimeout  = 30 * time.Second
+	defaultAckWait       = 30 * time.Second
+)
+
+// Handler handles a message, ctx carries the trace of the publisher.
+// With JetStream, nil acks the message and an error naks it for redelivery.
+type Handler func(ctx context.Context, msg *nats.Msg) error
+
+// Client is returned by Get:
+//
+//	client := service.MustGet("nats").(nats.Client)
+type Client interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+	// Subscribe calls handler for each message of subject, messages are load balanced
+	// between the subscribers of a non empty queue group
+	Subscribe(subject, queueGroup string, handler Handler) error
+	// JetStreamPublish publishes to a stream and waits for its ack
+	JetStreamPublish(ctx context.Context, subject string, data []byte) error
+	// JetStreamSubscribe consumes subject with a durable consumer, handler errors are redelivered
+	JetStreamSubscribe(subject, durable string, handler Handler, opts ...ConsumerOption) error
+	// Conn returns the connection, nil before Run
+	Conn() *nats.Conn
+}
+
+type consumerConfig struct {
+	queueGroup string
+	ackWait    time.Duration
+	maxDeliver int
+}
+
+type ConsumerOption func(*consumerConfig)
+
+// WithQueueGroup load balances messages between the consumers of the group
+func WithQueueGroup(group string) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.queueGroup = group
+	}
+}
+
+// WithAckWait sets how long the server waits for an ack before redelivering, default 30s
+func WithAckWait(d time.Duration) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.ackWait = d
+	}
+}
+
+// WithMaxDeliver sets the max deliveries of a message, default is unlimited
+func WithMaxDeliver(n int) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.maxDeliver = n
+	}
+}
+
+// subscription is kept until Run when Subscribe is called before it
+type subscription struct {
+	subject    string
+	jetStream  bool
+	durable    string
+	handler    Handler
+	consumer   consumerConfig
+	queueGroup string
+}
+
+type natsClient struct {
+	name   string
+	prefix string
+	logger logger.Logger
+
+	urls          string
+	credsFile     string
+	nkeyFile      string
+	token         string
+	connName      string
+	maxReconnects int
+	reconnectWait time.Duration
+	drainTimeout  time.Duration
+
+	mu      *sync.Mutex
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	pending []*subscription
+	closed  chan struct{}
+}
+
+func New(name, prefix string) *natsClient {
+	return &natsClient{name: name, prefix: prefix, mu: &sync.Mutex{}}
+}
+
+func (nc *natsClient) Name() string {
+	return nc.name
+}
+
+func (nc *natsClient) GetPrefix() string {
+	return nc.prefix
+}
+
+func (nc *natsClient) Get() interface{} {
+	return Client(nc)
+}
+
+func (nc *natsClient) InitFlags() {
+	nc.InitFlagSet(flag.CommandLine)
+}
+
+func (nc *natsClient) InitFlagSet(fs *flag.FlagSet) {
+	prefix := nc.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&nc.urls, prefix+"urls", "", "comma separated NATS server URLs. Ex: nats://localhost:4222, empty => disabled")
+	fs.StringVar(&nc.credsFile, prefix+"creds-file", "", "path of the user credentials file (JWT and nkey seed)")
+	fs.StringVar(&nc.nkeyFile, prefix+"nkey-file", "", "path of the nkey seed file")
+	fs.StringVar(&nc.token, prefix+"token", "", "authentication token")
+	fs.StringVar(&nc.connName, prefix+"connection-name", "", "connection name shown by the server, default is the plugin name")
+	fs.IntVar(&nc.maxReconnects, prefix+"max-reconnects", defaultMaxReconnects, "max reconnect attempts, -1 => forever")
+	fs.DurationVar(&nc.reconnectWait, prefix+"reconnect-wait", defaultReconnectWait, "wait between reconnect attempts to the same server")
+	fs.DurationVar(&nc.drainTimeout, prefix+"drain-timeout", defaultDrainTimeout, "max time to drain subscriptions when stopping")
+}
+
+func (nc *natsClient) isDisabled() bool {
+	return nc.urls == ""
+}
+
+func (nc *natsClient) Configure() error {
+	nc.logger = logger.GetCurrent().GetLogger(nc.name)
+	return nil
+}
+
+func (nc *natsClient) options() ([]nats.Option, error) {
+	connName := nc.connName
+	if connName == "" {
+		connName = nc.name
+	}
+
+	opts := []nats.Option{
+		nats.Name(connName),
+		nats.MaxReconnects(nc.maxReconnects),
+		nats.ReconnectWait(nc.reconnectWait),
+		nats.DrainTimeout(nc.drainTimeout),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				nc.logger.Warnf("disconnected from NATS: %s", err.Error())
+				return
+			}
+			nc.logger.Info("disconnected from NATS")
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			nc.logger.Infof("reconnected to NATS at %s", c.ConnectedUrlRedacted())
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+			if sub != nil {
+				nc.logger.Errorf("NATS error on %s: %s", sub.Subject, err.Error())
+				return
+			}
+			nc.logger.Errorf("NATS error: %s", err.Error())
+		}),
+		nats.ClosedHandler(func(*nats.Conn) {
+			close(nc.closed)
+		}),
+	}
+
+	if nc.credsFile != "" {
+		opts = append(opts, nats.UserCredentials(nc.credsFile))
+	}
+	if nc.nkeyFile != "" {
+		opt, err := nats.NkeyOptionFromSeed(nc.nkeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read nkey file %s: %w", nc.nkeyFile, err)
+		}
+		opts = append(opts, opt)
+	}
+	if nc.token != "" {
+		opts = append(opts, nats.Token(nc.token))
+	}
+
+	return opts, nil
+}
+
+// Run connects to the servers and starts the subscriptions made before it
+func (nc *natsClient) Run() error {
+	if nc.isDisabled() || nc.Conn() != nil {
+		return nil
+	}
+
+	if err := nc.Configure(); err != nil {
+		return err
+	}
+
+	opts, err := nc.options()
+	if err != nil {
+		return err
+	}
+
+	nc.closed = make(chan struct{})
+	conn, err := nats.Connect(nc.urls, opts...)
+	if err != nil {
+		nc.logger.Error("Cannot connect NATS. ", err.Error())
+		return err
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	nc.logger.Infof("connected to NATS at %s", conn.ConnectedUrlRedacted())
+
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	nc.conn, nc.js = conn, js
+	for _, s := range nc.pending {
+		if err := nc.subscribe(s); err != nil {
+			return err
+		}
+	}
+	nc.pending = nil
+
+	return nil
+}
+
+func (nc *natsClient) Conn() *nats.Conn {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	return nc.conn
+}
+
+func (nc *natsClient) Publish(ctx context.Context, subject string, data []byte) error {
+	conn := nc.Conn()
+	if conn == nil {
+		return errors.New("nats is not connected")
+	}
+
+	ctx, span := startSpan(ctx, subject, "publish", trace.SpanKindProducer)
+	defer span.End()
+
+	err := conn.PublishMsg(newMsg(ctx, subject, data))
+	recordError(span, err)
+	return err
+}
+
+func (nc *natsClient) JetStreamPublish(ctx context.Context, subject string, data []byte) error {
+	conn := nc.Conn()
+	if conn == nil {
+		return errors.New("nats is not connected")
+	}
+
+	ctx, span := startSpan(ctx, subject, "publish", trace.SpanKindProducer)
+	defer span.End()
+
+	_, err := nc.js.PublishMsg(newMsg(ctx, subject, data), nats.Context(ctx))
+	recordError(span, err)
+	return err
+}
+
+func (nc *natsClient) Subscribe(subject, queueGroup string, handler Handler) error {
+	return nc.addSubscription(&subscription{subject: subject, queueGroup: queueGroup, handler: handler})
+}
+
+func (nc *natsClient) JetStreamSubscribe(subject, durable string, handler Handler, opts ...ConsumerOption) error {
+	s := &subscription{
+		subject:   subject,
+		jetStream: true,
+		durable:   durable,
+		handler:   handler,
+		consumer:  consumerConfig{ackWait: defaultAckWait},
+	}
+	for _, opt := range opts {
+		opt(&s.consumer)
+	}
+	return nc.addSubscription(s)
+}
+
+// addSubscription subscribes now when connected, else at Run
+func (nc *natsClient) addSubscription(s *subscription) error {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	if nc.conn == nil {
+		nc.pending = append(nc.pending, s)
+		return nil
+	}
+	return nc.subscribe(s)
+}
+
+func (nc *natsClient) subscribe(s *subscription) error {
+	var err error
+	if s.jetStream {
+		opts := []nats.SubOpt{nats.ManualAck(), nats.AckWait(s.consumer.ackWait)}
+		if s.durable != "" {
+			opts = append(opts, nats.Durable(s.durable))
+		}
+		if s.consumer.maxDeliver > 0 {
+			opts = append(opts, nats.MaxDeliver(s.consumer.maxDeliver))
+		}
+		_, err = nc.js.QueueSubscribe(s.subject, s.consumer.queueGroup, nc.msgHandler(s), opts...)
+	} else {
+		_, err = nc.conn.QueueSubscribe(s.subject, s.queueGroup, nc.msgHandler(s))
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot subscribe %s: %w", s.subject, err)
+	}
+	return nil
+}
+
+// msgHandler runs handler in a span linked to the publisher, JetStream messages are acked
+func (nc *natsClient) msgHandler(s *subscription) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(msg))
+		ctx, span := startSpan(ctx, msg.Subject, "process", trace.SpanKindConsumer)
+		defer span.End()
+
+		err := s.handler(ctx, msg)
+		recordError(span, err)
+
+		if err != nil {
+			nc.logger.Withs(logger.Fields{"subject": msg.Subject, "error": err.Error()}).Error("cannot handle NATS message")
+		}
+
+		if !s.jetStream {
+			return
+		}
+
+		if err != nil {
+			err = msg.Nak()
+		} else {
+			err = msg.Ack()
+		}
+		if err != nil {
+			nc.logger.Warnf("cannot ack NATS message of %s: %s", msg.Subject, err.Error())
+		}
+	}
+}
+
+// HealthCheck fails while disconnected, a disabled plugin is always healthy
+func (nc *natsClient) HealthCheck(_ context.Context) error {
+	if nc.isDisabled() {
+		return nil
+	}
+
+	conn := nc.Conn()
+	if conn == nil {
+		return errors.New("nats is not connected")
+	}
+	if status := conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("nats is %s", strings.ToLower(status.String()))
+	}
+	return nil
+}
+
+// Stop drains subscriptions, so in-flight messages are handled and acked,
+// then closes the connection. It sends false when the drain timed out.
+func (nc *natsClient) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		conn := nc.Conn()
+		if conn == nil {
+			c <- true
+			return
+		}
+
+		clean := true
+		if err := conn.Drain(); err != nil {
+			nc.logger.Warnf("cannot drain %s: %s", nc.name, err.Error())
+			conn.Close()
+		}
+
+		// the drain timeout is enforced by the client, the connection is closed after it
+		select {
+		case <-nc.closed:
+		case <-time.After(nc.drainTimeout + time.Second):
+			clean = false
+			conn.Close()
+		}
+
+		if err := conn.LastError(); errors.Is(err, nats.ErrDrainTimeout) {
+			clean = false
+		}
+
+		c <- clean
+	}()
+	return c
+}
+
+func newMsg(ctx context.Context, subject string, data []byte) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(msg))
+	return msg
+}
+
+func headerCarrier(msg *nats.Msg) propagation.HeaderCarrier {
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+	return propagation.HeaderCarrier(http.Header(msg.Header))
+}
+
+func startSpan(ctx context.Context, subject, operation string, kind trace.SpanKind) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, subject+" "+operation,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("nats"),
+			semconv.MessagingDestinationName(subject),
+			attribute.String("messaging.operation", operation),
+		),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,126 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func runServer(t *testing.T) *server.Server {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	assert.Nil(t, err, "must be nil")
+	go srv.Start()
+	assert.True(t, srv.ReadyForConnections(5*time.Second), "server should be ready")
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func newClient(url string) *natsClient {
+	logger.InitServLogger(false)
+
+	nc := New("nats", "nats")
+	nc.urls = url
+	nc.maxReconnects = defaultMaxReconnects
+	nc.reconnectWait = 10 * time.Millisecond
+	nc.drainTimeout = time.Second
+	return nc
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	srv := runServer(t)
+	nc := newClient(srv.ClientURL())
+
+	received := make(chan trace.SpanContext, 1)
+	// subscribed at Run
+	assert.Nil(t, nc.Subscribe("orders.created", "workers", func(ctx context.Context, msg *nats.Msg) error {
+		received <- trace.SpanContextFromContext(ctx)
+		return nil
+	}), "must be nil")
+
+	assert.Nil(t, nc.Run(), "must be nil")
+	assert.Nil(t, nc.HealthCheck(context.Background()), "must be nil")
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "parent")
+	assert.Nil(t, nc.Publish(ctx, "orders.created", []byte("1")), "must be nil")
+	span.End()
+
+	select {
+	case sc := <-received:
+		assert.Equal(t, span.SpanContext().TraceID(), sc.TraceID(), "should be the publisher trace")
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	assert.True(t, <-nc.Stop(), "should be true")
+	assert.NotNil(t, nc.Publish(context.Background(), "orders.created", nil), "should be an error")
+}
+
+func TestJetStream(t *testing.T) {
+	srv := runServer(t)
+	nc := newClient(srv.ClientURL())
+	assert.Nil(t, nc.Run(), "must be nil")
+
+	_, err := nc.js.AddStream(&nats.StreamConfig{Name: "EVENTS", Subjects: []string{"events.>"}})
+	assert.Nil(t, err, "must be nil")
+
+	var deliveries atomic.Int32
+	done := make(chan struct{})
+	assert.Nil(t, nc.JetStreamSubscribe("events.>", "billing", func(ctx context.Context, msg *nats.Msg) error {
+		// the first delivery fails and is redelivered
+		if deliveries.Add(1) == 1 {
+			return errors.New("try again")
+		}
+		close(done)
+		return nil
+	}, WithAckWait(time.Second), WithMaxDeliver(3)), "must be nil")
+
+	assert.Nil(t, nc.JetStreamPublish(context.Background(), "events.paid", []byte("1")), "must be nil")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not redelivered")
+	}
+
+	info, err := nc.js.ConsumerInfo("EVENTS", "billing")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, 3, info.Config.MaxDeliver, "should be equal")
+
+	assert.True(t, <-nc.Stop(), "should be true")
+}
+
+func TestHealthCheckDisconnected(t *testing.T) {
+	srv := runServer(t)
+	nc := newClient(srv.ClientURL())
+	assert.Nil(t, nc.Run(), "must be nil")
+
+	srv.Shutdown()
+	assert.Eventually(t, func() bool {
+		return nc.HealthCheck(context.Background()) != nil
+	}, 5*time.Second, 10*time.Millisecond, "should be unhealthy while disconnected")
+
+	<-nc.Stop()
+}
+
+func TestDisabled(t *testing.T) {
+	nc := New("nats", "nats")
+	assert.Nil(t, nc.Run(), "must be nil")
+	assert.Nil(t, nc.HealthCheck(context.Background()), "disabled plugin is always healthy")
+	assert.True(t, <-nc.Stop(), "should be true")
+}
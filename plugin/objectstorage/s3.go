@@ -0,0 +1,308 @@
+package objectstorage
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/taimaifika/go-sdk/plugin/objectstorage"
+
+// S3 requires parts of at least 5MiB, except the last one
+const minPartSize = 5 << 20
+
+var (
+	defaultEndpoint = "https://s3.amazonaws.com"
+	defaultPartSize = uint64(16 << 20)
+)
+
+// Storage is returned by Get:
+//
+//	storage := service.MustGet("s3").(objectstorage.Storage)
+type Storage interface {
+	// Upload writes the object, bodies larger than the part size are uploaded in parts
+	Upload(ctx context.Context, key string, r io.Reader, contentType string) error
+	Download(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// PresignedURL returns a GET URL of the object which is valid for ttl
+	PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+type s3Storage struct {
+	name   string
+	prefix string
+	logger logger.Logger
+	client *minio.Client
+
+	endpoint           string
+	region             string
+	bucket             string
+	accessKey          string
+	secretKey          string
+	credentialsFromEnv bool
+	pathStyle          bool
+	tlsSkipVerify      bool
+	createBucket       bool
+	partSize           uint64
+}
+
+// NewS3 creates an S3 compatible storage, e.g. AWS S3 or MinIO
+func NewS3(name, prefix string) *s3Storage {
+	return &s3Storage{name: name, prefix: prefix}
+}
+
+func (s *s3Storage) Name() string {
+	return s.name
+}
+
+func (s *s3Storage) GetPrefix() string {
+	return s.prefix
+}
+
+func (s *s3Storage) Get() interface{} {
+	return Storage(s)
+}
+
+func (s *s3Storage) InitFlags() {
+	s.InitFlagSet(flag.CommandLine)
+}
+
+func (s *s3Storage) InitFlagSet(fs *flag.FlagSet) {
+	prefix := s.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&s.endpoint, prefix+"endpoint", defaultEndpoint, "S3 endpoint, http:// for plain connections. Ex: http://localhost:9000 for MinIO")
+	fs.StringVar(&s.region, prefix+"region", "", "bucket region, empty => detected")
+	fs.StringVar(&s.bucket, prefix+"bucket", "", "bucket name, empty => disabled")
+	fs.StringVar(&s.accessKey, prefix+"access-key", "", "access key id")
+	fs.StringVar(&s.secretKey, prefix+"secret-key", "", "secret access key")
+	fs.BoolVar(&s.credentialsFromEnv, prefix+"credentials-from-env", false, "read credentials only from AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY or MINIO_ROOT_USER/MINIO_ROOT_PASSWORD, key flags must be empty")
+	fs.BoolVar(&s.pathStyle, prefix+"path-style", false, "path-style addressing (endpoint/bucket/key) instead of bucket.endpoint, needed by MinIO")
+	fs.BoolVar(&s.tlsSkipVerify, prefix+"tls-skip-verify", false, "don't verify the endpoint certificate, for dev MinIO only")
+	fs.BoolVar(&s.createBucket, prefix+"create-bucket", false, "create the bucket when it doesn't exist")
+	fs.Uint64Var(&s.partSize, prefix+"part-size", defaultPartSize, "part size of multipart uploads in bytes, min 5MiB")
+}
+
+func (s *s3Storage) isDisabled() bool {
+	return s.bucket == ""
+}
+
+func (s *s3Storage) Configure() error {
+	s.logger = logger.GetCurrent().GetLogger(s.name)
+	if s.isDisabled() {
+		return nil
+	}
+
+	if s.partSize < minPartSize {
+		return fmt.Errorf("part size %d is less than the min of 5MiB", s.partSize)
+	}
+
+	opts, host, err := s.options()
+	if err != nil {
+		return err
+	}
+
+	s.client, err = minio.New(host, opts)
+	return err
+}
+
+func (s *s3Storage) options() (*minio.Options, string, error) {
+	u, err := url.Parse(s.endpoint)
+	if err != nil || u.Host == "" {
+		return nil, "", fmt.Errorf("invalid endpoint %q, must be like https://host[:port]", s.endpoint)
+	}
+
+	var creds *credentials.Credentials
+	if s.credentialsFromEnv {
+		if s.accessKey != "" || s.secretKey != "" {
+			return nil, "", errors.New("access and secret keys must be empty with credentials-from-env")
+		}
+		creds = credentials.NewChainCredentials([]credentials.Provider{&credentials.EnvAWS{}, &credentials.EnvMinio{}})
+	} else {
+		creds = credentials.NewStaticV4(s.accessKey, s.secretKey, "")
+	}
+
+	opts := &minio.Options{
+		Creds:        creds,
+		Secure:       u.Scheme != "http",
+		Region:       s.region,
+		BucketLookup: minio.BucketLookupAuto,
+	}
+	if s.pathStyle {
+		opts.BucketLookup = minio.BucketLookupPath
+	}
+
+	if s.tlsSkipVerify {
+		transport, err := minio.DefaultTransport(opts.Secure)
+		if err != nil {
+			return nil, "", err
+		}
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		opts.Transport = transport
+	}
+
+	return opts, u.Host, nil
+}
+
+// Run checks the bucket exists, it's created with create-bucket
+func (s *s3Storage) Run() error {
+	if s.isDisabled() {
+		return nil
+	}
+
+	if s.client == nil {
+		if err := s.Configure(); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		s.logger.Error("Cannot check bucket. ", err.Error())
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	if !s.createBucket {
+		return fmt.Errorf("bucket %s doesn't exist", s.bucket)
+	}
+
+	if err := s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{Region: s.region}); err != nil {
+		return fmt.Errorf("cannot create bucket %s: %w", s.bucket, err)
+	}
+	s.logger.Infof("created bucket %s", s.bucket)
+	return nil
+}
+
+func (s *s3Storage) Upload(ctx context.Context, key string, r io.Reader, contentType string) (err error) {
+	ctx, span := s.startSpan(ctx, "Upload", key)
+	defer func() { endSpan(span, err) }()
+
+	size := readerSize(r)
+	span.SetAttributes(attribute.Int64("objectstorage.size", size))
+
+	_, err = s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    s.partSize,
+	})
+	return err
+}
+
+// readerSize returns the size of bytes, strings readers and files, else -1
+func readerSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case *os.File:
+		if st, err := v.Stat(); err == nil && st.Mode().IsRegular() {
+			if offset, err := v.Seek(0, io.SeekCurrent); err == nil {
+				return st.Size() - offset
+			}
+		}
+	}
+	return -1
+}
+
+// Download returns the object body, a missing object is an error
+func (s *s3Storage) Download(ctx context.Context, key string) (_ io.ReadCloser, err error) {
+	ctx, span := s.startSpan(ctx, "Download", key)
+	defer func() { endSpan(span, err) }()
+
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// GetObject is lazy, Stat does the request so missing objects fail here
+	if _, err = obj.Stat(); err != nil {
+		_ = obj.Close()
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := s.startSpan(ctx, "Delete", key)
+	defer func() { endSpan(span, err) }()
+
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Storage) PresignedURL(ctx context.Context, key string, ttl time.Duration) (_ string, err error) {
+	ctx, span := s.startSpan(ctx, "PresignedURL", key)
+	defer func() { endSpan(span, err) }()
+
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, ttl, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// IsNotFound tells if err is returned for a missing object
+func IsNotFound(err error) bool {
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) {
+		return resp.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// HealthCheck checks the bucket is reachable, a disabled plugin is always healthy
+func (s *s3Storage) HealthCheck(ctx context.Context) error {
+	if s.isDisabled() {
+		return nil
+	}
+	if s.client == nil {
+		return errors.New("object storage is not configured")
+	}
+
+	_, err := s.client.BucketExists(ctx, s.bucket)
+	return err
+}
+
+func (s *s3Storage) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() { c <- true }()
+	return c
+}
+
+func (s *s3Storage) startSpan(ctx context.Context, operation, key string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "objectstorage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("objectstorage.bucket", s.bucket),
+			attribute.String("objectstorage.key", key),
+		),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,212 @@
+package objectstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// fakeS3 is an in-memory S3 with path-style addressing, enough for the plugin
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]bool
+	objects map[string][]byte
+	parts   map[string]map[int][]byte
+	uploads int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{buckets: map[string]bool{}, objects: map[string][]byte{}, parts: map[string]map[int][]byte{}}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !f.buckets[bucket] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			f.buckets[bucket] = true
+		}
+		return
+	}
+
+	path := bucket + "/" + key
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.uploads++
+		id := fmt.Sprint(f.uploads)
+		f.parts[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", bucket, key, id)
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		var n int
+		fmt.Sscan(q.Get("partNumber"), &n)
+		f.parts[q.Get("uploadId")][n] = body
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts := f.parts[q.Get("uploadId")]
+		numbers := make([]int, 0, len(parts))
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, parts[n]...)
+		}
+		f.objects[path] = data
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>", bucket, key, etag(data))
+	case r.Method == http.MethodPut:
+		f.objects[path] = body
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>")
+			return
+		}
+		w.Header().Set("ETag", etag(data))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func newStorage(t *testing.T, fake *fakeS3) *s3Storage {
+	logger.InitServLogger(false)
+
+	srv := httptest.NewTLSServer(fake)
+	t.Cleanup(srv.Close)
+
+	s := NewS3("s3", "s3")
+	s.endpoint = srv.URL
+	s.region = "us-east-1"
+	s.bucket = "files"
+	s.accessKey, s.secretKey = "minio", "minio123"
+	s.pathStyle = true
+	s.tlsSkipVerify = true
+	s.partSize = minPartSize
+	return s
+}
+
+func TestRunBucket(t *testing.T) {
+	fake := newFakeS3()
+	s := newStorage(t, fake)
+	assert.NotNil(t, s.Run(), "missing bucket should be an error")
+
+	s.createBucket = true
+	assert.Nil(t, s.Run(), "must be nil")
+	assert.True(t, fake.buckets["files"], "should be created")
+	assert.Nil(t, s.HealthCheck(context.Background()), "must be nil")
+}
+
+func TestUploadDownload(t *testing.T) {
+	fake := newFakeS3()
+	fake.buckets["files"] = true
+	s := newStorage(t, fake)
+	assert.Nil(t, s.Run(), "must be nil")
+
+	ctx := context.Background()
+	assert.Nil(t, s.Upload(ctx, "docs/a.txt", strings.NewReader("hello"), "text/plain"), "must be nil")
+
+	body, err := s.Download(ctx, "docs/a.txt")
+	assert.Nil(t, err, "must be nil")
+	data, _ := io.ReadAll(body)
+	_ = body.Close()
+	assert.Equal(t, "hello", string(data), "should be equal")
+
+	u, err := s.PresignedURL(ctx, "docs/a.txt", time.Minute)
+	assert.Nil(t, err, "must be nil")
+	assert.Contains(t, u, "/files/docs/a.txt?", "should be equal")
+	assert.Contains(t, u, "X-Amz-Expires=60", "should be equal")
+
+	assert.Nil(t, s.Delete(ctx, "docs/a.txt"), "must be nil")
+	_, err = s.Download(ctx, "docs/a.txt")
+	assert.True(t, IsNotFound(err), "should be not found")
+}
+
+func TestMultipartUpload(t *testing.T) {
+	fake := newFakeS3()
+	fake.buckets["files"] = true
+	s := newStorage(t, fake)
+	assert.Nil(t, s.Run(), "must be nil")
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), (2*minPartSize+1024)/16)
+	// a reader of unknown size
+	r := io.MultiReader(bytes.NewReader(data))
+	assert.Nil(t, s.Upload(context.Background(), "big.bin", r, "application/octet-stream"), "must be nil")
+
+	assert.Equal(t, 1, fake.uploads, "should be a multipart upload")
+	assert.Equal(t, data, fake.objects["files/big.bin"], "should be equal")
+}
+
+func TestOptions(t *testing.T) {
+	s := NewS3("s3", "s3")
+	s.endpoint = "localhost:9000"
+	_, _, err := s.options()
+	assert.NotNil(t, err, "endpoint needs a scheme")
+
+	s.endpoint = "http://localhost:9000"
+	opts, host, err := s.options()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "localhost:9000", host, "should be equal")
+	assert.False(t, opts.Secure, "should be false")
+
+	s.credentialsFromEnv = true
+	s.secretKey = "secret"
+	_, _, err = s.options()
+	assert.NotNil(t, err, "should be an error")
+
+	s.secretKey = ""
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	opts, _, err = s.options()
+	assert.Nil(t, err, "must be nil")
+	v, err := opts.Creds.Get()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "env-key", v.AccessKeyID, "should be equal")
+
+	s.bucket = "files"
+	s.partSize = 1 << 20
+	logger.InitServLogger(false)
+	assert.NotNil(t, s.Configure(), "part size is too small")
+}
+
+func TestDisabled(t *testing.T) {
+	s := NewS3("s3", "s3")
+	assert.Nil(t, s.Run(), "must be nil")
+	assert.Nil(t, s.HealthCheck(context.Background()), "disabled plugin is always healthy")
+	assert.True(t, <-s.Stop(), "should be true")
+}
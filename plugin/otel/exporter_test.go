@@ -0,0 +1,189 @@
+package otel
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	headers, err := parseKeyValues("authorization=Bearer%20secret, x-team = orders")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, map[string]string{"authorization": "Bearer secret", "x-team": "orders"}, headers, "should be equal")
+	assert.Equal(t, "authorization=*****,x-team=*****", maskHeaders(headers), "should be equal")
+
+	headers, err = parseKeyValues("")
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, headers, "must be nil")
+
+	_, err = parseKeyValues("authorization")
+	assert.NotNil(t, err, "should be an error")
+
+	_, err = parseKeyValues("=value")
+	assert.NotNil(t, err, "should be an error")
+}
+
+func TestConfigureExporter(t *testing.T) {
+	logger.InitServLogger(false)
+	t.Setenv(envExporterOtlpHeaders, "api-key=secret")
+
+	op := NewOtelPlugin("otel", "otel")
+	op.exporterOtlpCompress = CompressionGzip
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, map[string]string{"api-key": "secret"}, op.headers, "should be equal")
+	assert.Equal(t, CompressionGzip, op.sdkConfig().compression, "should be equal")
+
+	op = NewOtelPlugin("otel", "otel")
+	op.exporterOtlpCompress = "zstd"
+	assert.NotNil(t, op.Configure(), "should be an error")
+
+	op = NewOtelPlugin("otel", "otel")
+	op.exporterOtlpCAFile = filepath.Join(t.TempDir(), "missing.pem")
+	assert.NotNil(t, op.Configure(), "should be an error")
+
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+
+	op = NewOtelPlugin("otel", "otel")
+	op.exporterOtlpCAFile = writeCAFile(t, srv)
+	op.exporterOtlpInsecure = true
+	assert.NotNil(t, op.Configure(), "should be an error")
+}
+
+// writeCAFile writes the certificate of a TLS test server to a PEM file
+func writeCAFile(t *testing.T, srv *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.Nil(t, os.WriteFile(path, data, 0o600), "must be nil")
+	return path
+}
+
+func TestHTTPExporterOptions(t *testing.T) {
+	type request struct {
+		auth     string
+		encoding string
+	}
+
+	var mu sync.Mutex
+	received := map[string]request{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[r.URL.Path] = request{auth: r.Header.Get("Authorization"), encoding: r.Header.Get("Content-Encoding")}
+	}))
+	defer srv.Close()
+
+	tlsConfig, err := newTLSConfig(writeCAFile(t, srv))
+	assert.Nil(t, err, "must be nil")
+
+	ctx := context.Background()
+	cfg := sdkConfig{
+		endpoint:    srv.Listener.Addr().String(),
+		protocol:    ProtocolHTTP,
+		headers:     map[string]string{"Authorization": "Bearer secret"},
+		tlsConfig:   tlsConfig,
+		compression: CompressionGzip,
+		timeout:     5 * time.Second,
+	}
+
+	traceExporter, err := newTraceExporter(ctx, cfg)
+	assert.Nil(t, err, "must be nil")
+	tp := trace.NewTracerProvider(trace.WithSyncer(traceExporter))
+	_, span := tp.Tracer("test").Start(ctx, "span")
+	span.End()
+	assert.Nil(t, tp.Shutdown(ctx), "must be nil")
+
+	metricExporter, err := newMetricExporter(ctx, cfg)
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, metricExporter.Export(ctx, &metricdata.ResourceMetrics{}), "must be nil")
+
+	logExporter, err := newLogExporter(ctx, cfg)
+	assert.Nil(t, err, "must be nil")
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(logExporter)))
+	lp.Logger("test").Emit(ctx, otellog.Record{})
+	assert.Nil(t, lp.Shutdown(ctx), "must be nil")
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/v1/traces", "/v1/metrics", "/v1/logs"} {
+		assert.Equal(t, request{auth: "Bearer secret", encoding: "gzip"}, received[path], "should be equal: %s", path)
+	}
+}
+
+type fakeTraceCollector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	md chan metadata.MD
+	// compression of the received requests
+	compression chan string
+}
+
+func (c *fakeTraceCollector) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (c *fakeTraceCollector) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if h, ok := s.(*stats.InHeader); ok {
+		c.compression <- h.Compression
+	}
+}
+
+func (c *fakeTraceCollector) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (c *fakeTraceCollector) HandleConn(context.Context, stats.ConnStats) {}
+
+func (c *fakeTraceCollector) Export(ctx context.Context, _ *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.md <- md
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func TestGRPCExporterOptions(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "must be nil")
+
+	collector := &fakeTraceCollector{md: make(chan metadata.MD, 1), compression: make(chan string, 1)}
+	srv := grpc.NewServer(grpc.StatsHandler(collector))
+	collectortrace.RegisterTraceServiceServer(srv, collector)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	ctx := context.Background()
+	exporter, err := newTraceExporter(ctx, sdkConfig{
+		endpoint:    lis.Addr().String(),
+		protocol:    ProtocolGRPC,
+		headers:     map[string]string{"api-key": "secret"},
+		insecure:    true,
+		compression: CompressionGzip,
+		timeout:     5 * time.Second,
+	})
+	assert.Nil(t, err, "must be nil")
+
+	tp := trace.NewTracerProvider(trace.WithSyncer(exporter))
+	_, span := tp.Tracer("test").Start(ctx, "span")
+	span.End()
+	assert.Nil(t, tp.Shutdown(ctx), "must be nil")
+
+	md := <-collector.md
+	assert.Equal(t, []string{"secret"}, md.Get("api-key"), "should be equal")
+	assert.Equal(t, CompressionGzip, <-collector.compression, "should be equal")
+}
@@ -2,9 +2,18 @@ package otel
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/propagation"
@@ -14,15 +23,106 @@ import (
 	"go.opentelemetry.io/otel/sdk/trace"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
 
-	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc/credentials"
 )
 
-// setupOTelSDK bootstraps the OpenTelemetry pipeline.
+// deployment.environment resource attribute, empty means not set
+var deploymentEnvironment string
+
+// SetDeploymentEnvironment sets the deployment.environment resource attribute.
+// The service sets it to the active profile by default.
+func SetDeploymentEnvironment(env string) {
+	deploymentEnvironment = env
+}
+
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+const (
+	MetricsExporterOTLP       = "otlp"
+	MetricsExporterPrometheus = "prometheus"
+	MetricsExporterStdout     = "stdout"
+	MetricsExporterNone       = "none"
+)
+
+// set when metrics are exported with the prometheus exporter
+var (
+	metricsHandler  atomic.Pointer[http.Handler]
+	metricsRegistry atomic.Pointer[prom.Registry]
+)
+
+// MetricsHandler returns the handler serving metrics in the prometheus format,
+// it's nil unless the metrics exporter is prometheus
+func MetricsHandler() http.Handler {
+	if h := metricsHandler.Load(); h != nil {
+		return *h
+	}
+	return nil
+}
+
+// sdkConfig configures the OpenTelemetry pipeline
+type sdkConfig struct {
+	serviceName    string
+	serviceVersion string
+	// empty uses the exporter default (localhost)
+	endpoint string
+	protocol string
+	// signal => endpoint and protocol overriding the shared ones
+	signals map[string]otlpTarget
+	// empty uses otlp
+	metricsExporter string
+	// nil uses the sdk default, parentbased_always_on
+	sampler trace.Sampler
+	// sent with every export request
+	headers  map[string]string
+	insecure bool
+	// nil uses the system roots
+	tlsConfig *tls.Config
+	// empty uses the exporter default (none)
+	compression string
+	// 0 uses the exporter default (10s)
+	timeout time.Duration
+	// nil detects it with newResource
+	resource *resource.Resource
+	// nil uses tracecontext and baggage
+	propagator propagation.TextMapPropagator
+	// zero values use the sdk defaults
+	metricInterval          time.Duration
+	traceBatchTimeout       time.Duration
+	traceMaxQueueSize       int
+	traceMaxExportBatchSize int
+}
+
+// SetupOTelSDK bootstraps the OpenTelemetry pipeline with OTLP gRPC exporters.
 // If it does not return an error, make sure to call shutdown for proper cleanup.
 func SetupOTelSDK(ctx context.Context, serviceName, serviceVersion string) (shutdown func(context.Context) error, err error) {
+	return setupOTelSDK(ctx, sdkConfig{
+		serviceName:    serviceName,
+		serviceVersion: serviceVersion,
+		protocol:       ProtocolGRPC,
+	})
+}
+
+func (cfg sdkConfig) resourceConfig() resourceConfig {
+	return resourceConfig{
+		serviceName:           cfg.serviceName,
+		serviceVersion:        cfg.serviceVersion,
+		serviceInstanceID:     defaultInstanceID(),
+		deploymentEnvironment: deploymentEnvironment,
+	}
+}
+
+func setupOTelSDK(ctx context.Context, cfg sdkConfig) (shutdown func(context.Context) error, err error) {
 	var shutdownFuncs []func(context.Context) error
 
 	// shutdown calls cleanup functions registered via shutdownFuncs.
@@ -42,12 +142,24 @@ func SetupOTelSDK(ctx context.Context, serviceName, serviceVersion string) (shut
 		err = errors.Join(inErr, shutdown(ctx))
 	}
 
+	if cfg.resource == nil {
+		// detection errors are not fatal
+		cfg.resource, err = newResource(ctx, cfg.resourceConfig())
+		if err != nil {
+			otel.Handle(err)
+			err = nil
+		}
+	}
+
 	// Set up propagator.
-	prop := newPropagator()
+	prop := cfg.propagator
+	if prop == nil {
+		prop, _ = newPropagator(defaultPropagators)
+	}
 	otel.SetTextMapPropagator(prop)
 
 	// Set up trace provider.
-	tracerProvider, err := newTraceProvider(serviceName, serviceVersion)
+	tracerProvider, err := newTraceProvider(ctx, cfg)
 	if err != nil {
 		handleErr(err)
 		return
@@ -56,7 +168,7 @@ func SetupOTelSDK(ctx context.Context, serviceName, serviceVersion string) (shut
 	otel.SetTracerProvider(tracerProvider)
 
 	// Set up meter provider.
-	meterProvider, err := newMeterProvider(ctx)
+	meterProvider, err := newMeterProvider(ctx, cfg)
 	if err != nil {
 		handleErr(err)
 		return
@@ -65,7 +177,7 @@ func SetupOTelSDK(ctx context.Context, serviceName, serviceVersion string) (shut
 	otel.SetMeterProvider(meterProvider)
 
 	// Set up logger provider.
-	loggerProvider, err := newLoggerProvider(ctx)
+	loggerProvider, err := newLoggerProvider(ctx, cfg)
 	if err != nil {
 		handleErr(err)
 		return
@@ -76,14 +188,7 @@ func SetupOTelSDK(ctx context.Context, serviceName, serviceVersion string) (shut
 	return
 }
 
-func newPropagator() propagation.TextMapPropagator {
-	return propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	)
-}
-
-func newTraceProvider(serviceName, serviceVersion string) (*trace.TracerProvider, error) {
+func newTraceProvider(ctx context.Context, cfg sdkConfig) (*trace.TracerProvider, error) {
 	// // Exporter to stdout
 	// traceExporter, err := stdouttrace.New(
 	// 	stdouttrace.WithPrettyPrint(),
@@ -93,65 +198,250 @@ func newTraceProvider(serviceName, serviceVersion string) (*trace.TracerProvider
 	// }
 
 	// Exporter to otlp
-	traceExporter, err := newTraceExporter(context.Background())
+	traceExporter, err := newTraceExporter(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	// Resource attributes
-	res := newResource(serviceName, serviceVersion)
+	// zero values use the sdk defaults
+	var batchOpts []trace.BatchSpanProcessorOption
+	if cfg.traceBatchTimeout > 0 {
+		batchOpts = append(batchOpts, trace.WithBatchTimeout(cfg.traceBatchTimeout))
+	}
+	if cfg.traceMaxQueueSize > 0 {
+		batchOpts = append(batchOpts, trace.WithMaxQueueSize(cfg.traceMaxQueueSize))
+	}
+	if cfg.traceMaxExportBatchSize > 0 {
+		batchOpts = append(batchOpts, trace.WithMaxExportBatchSize(cfg.traceMaxExportBatchSize))
+	}
 
-	traceProvider := trace.NewTracerProvider(
-		trace.WithBatcher(traceExporter,
-			// Default is 5s. Set to 1s for demonstrative purposes.
-			trace.WithBatchTimeout(time.Second)),
-		trace.WithResource(res),
-	)
+	opts := []trace.TracerProviderOption{
+		trace.WithBatcher(traceExporter, batchOpts...),
+		trace.WithResource(cfg.resource),
+	}
+	if cfg.sampler != nil {
+		opts = append(opts, trace.WithSampler(cfg.sampler))
+	}
+
+	traceProvider := trace.NewTracerProvider(opts...)
 	return traceProvider, nil
 }
 
-// newTraceExporter creates a new OTLP trace exporter. (gRPC)
-func newTraceExporter(ctx context.Context) (trace.SpanExporter, error) {
-	return otlptracegrpc.New(ctx)
+// newTraceExporter creates a new OTLP trace exporter. (gRPC or HTTP)
+func newTraceExporter(ctx context.Context, cfg sdkConfig) (trace.SpanExporter, error) {
+	endpoint, protocol := cfg.target(signalTraces)
+	if protocol == ProtocolHTTP {
+		var opts []otlptracehttp.Option
+		if isEndpointURL(endpoint) {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		} else if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+		}
+		if len(cfg.headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.headers))
+		}
+		if cfg.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		} else if cfg.tlsConfig != nil {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(cfg.tlsConfig))
+		}
+		switch cfg.compression {
+		case CompressionGzip:
+			opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		case CompressionNone:
+			opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.NoCompression))
+		}
+		if cfg.timeout > 0 {
+			opts = append(opts, otlptracehttp.WithTimeout(cfg.timeout))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+
+	var opts []otlptracegrpc.Option
+	if isEndpointURL(endpoint) {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(endpoint))
+	} else if endpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+	}
+	if len(cfg.headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(cfg.headers))
+	}
+	if cfg.insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else if cfg.tlsConfig != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(cfg.tlsConfig)))
+	}
+	if cfg.compression != "" {
+		opts = append(opts, otlptracegrpc.WithCompressor(cfg.compression))
+	}
+	if cfg.timeout > 0 {
+		opts = append(opts, otlptracegrpc.WithTimeout(cfg.timeout))
+	}
+	return otlptracegrpc.New(ctx, opts...)
 }
 
-// newResource creates a new resource with service.name and service.namespace.
-func newResource(serviceName, serviceVersion string) *resource.Resource {
-	res := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.ServiceNameKey.String(serviceName),
-		semconv.ServiceVersionKey.String(serviceVersion),
-	)
-	return res
+const (
+	signalTraces  = "traces"
+	signalMetrics = "metrics"
+	signalLogs    = "logs"
+)
+
+// otlpTarget is where the OTLP exporter of a signal sends to
+type otlpTarget struct {
+	// empty falls back to the shared endpoint
+	endpoint string
+	// empty falls back to the shared protocol
+	protocol string
 }
 
-func newMeterProvider(ctx context.Context) (*metric.MeterProvider, error) {
-	// // Exporter to stdout
-	// metricExporter, err := stdoutmetric.New()
-	// if err != nil {
-	// 	return nil, err
-	// }
+// target resolves the endpoint and protocol of a signal. A signal specific URL
+// is used as is, while the shared URL gets the /v1/<signal> path of OTLP/HTTP appended.
+func (cfg sdkConfig) target(signal string) (endpoint, protocol string) {
+	t := cfg.signals[signal]
 
-	// Exporter to otlp
-	metricExporter, err := newMetricExporter(ctx)
+	protocol = t.protocol
+	if protocol == "" {
+		protocol = cfg.protocol
+	}
+
+	if t.endpoint != "" {
+		return t.endpoint, protocol
+	}
+
+	endpoint = cfg.endpoint
+	if protocol == ProtocolHTTP && isEndpointURL(endpoint) {
+		// an invalid URL is reported by the exporter
+		if u, err := url.JoinPath(endpoint, "v1", signal); err == nil {
+			endpoint = u
+		}
+	}
+	return endpoint, protocol
+}
+
+// isEndpointURL reports whether endpoint has a scheme (http://host:port)
+// rather than only host:port
+func isEndpointURL(endpoint string) bool {
+	return strings.Contains(endpoint, "://")
+}
+
+func newMeterProvider(ctx context.Context, cfg sdkConfig) (*metric.MeterProvider, error) {
+	reader, err := newMetricReader(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	meterProvider := metric.NewMeterProvider(
-		metric.WithReader(metric.NewPeriodicReader(metricExporter,
-			// Default is 1m. Set to 3s for demonstrative purposes.
-			metric.WithInterval(3*time.Second))),
-	)
-	return meterProvider, nil
+	opts := []metric.Option{metric.WithResource(cfg.resource)}
+	// no reader drops the measurements
+	if reader != nil {
+		opts = append(opts, metric.WithReader(reader))
+	}
+	return metric.NewMeterProvider(opts...), nil
+}
+
+// newMetricReader creates the reader of the configured metrics exporter.
+// The prometheus reader is pulled by scraping MetricsHandler, the others push periodically.
+// The none exporter has no reader.
+func newMetricReader(ctx context.Context, cfg sdkConfig) (metric.Reader, error) {
+	switch cfg.metricsExporter {
+	case MetricsExporterNone:
+		return nil, nil
+
+	case MetricsExporterPrometheus:
+		// the exporter sanitizes the names per the spec, e.g. http.server.duration
+		// => http_server_duration_seconds, and adds the resource as target_info
+		registry := prom.NewRegistry()
+		exporter, err := prometheus.New(prometheus.WithRegisterer(registry))
+		if err != nil {
+			return nil, err
+		}
+
+		var h http.Handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+		metricsHandler.Store(&h)
+		metricsRegistry.Store(registry)
+		return exporter, nil
+
+	case MetricsExporterStdout:
+		exporter, err := stdoutmetric.New()
+		if err != nil {
+			return nil, err
+		}
+		return metric.NewPeriodicReader(exporter, cfg.periodicReaderOptions()...), nil
+
+	case MetricsExporterOTLP, "":
+		// Exporter to otlp
+		exporter, err := newMetricExporter(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return metric.NewPeriodicReader(exporter, cfg.periodicReaderOptions()...), nil
+
+	default:
+		return nil, fmt.Errorf("unknown metrics exporter %q", cfg.metricsExporter)
+	}
+}
+
+// periodicReaderOptions uses the sdk default interval (1m) when it's not set
+func (cfg sdkConfig) periodicReaderOptions() []metric.PeriodicReaderOption {
+	if cfg.metricInterval <= 0 {
+		return nil
+	}
+	return []metric.PeriodicReaderOption{metric.WithInterval(cfg.metricInterval)}
 }
 
-// newMetricExporter creates a new OTLP metric exporter. (gRPC)
-func newMetricExporter(ctx context.Context) (metric.Exporter, error) {
-	return otlpmetricgrpc.New(ctx)
+// newMetricExporter creates a new OTLP metric exporter. (gRPC or HTTP)
+func newMetricExporter(ctx context.Context, cfg sdkConfig) (metric.Exporter, error) {
+	endpoint, protocol := cfg.target(signalMetrics)
+	if protocol == ProtocolHTTP {
+		var opts []otlpmetrichttp.Option
+		if isEndpointURL(endpoint) {
+			opts = append(opts, otlpmetrichttp.WithEndpointURL(endpoint))
+		} else if endpoint != "" {
+			opts = append(opts, otlpmetrichttp.WithEndpoint(endpoint))
+		}
+		if len(cfg.headers) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(cfg.headers))
+		}
+		if cfg.insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		} else if cfg.tlsConfig != nil {
+			opts = append(opts, otlpmetrichttp.WithTLSClientConfig(cfg.tlsConfig))
+		}
+		switch cfg.compression {
+		case CompressionGzip:
+			opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+		case CompressionNone:
+			opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.NoCompression))
+		}
+		if cfg.timeout > 0 {
+			opts = append(opts, otlpmetrichttp.WithTimeout(cfg.timeout))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+
+	var opts []otlpmetricgrpc.Option
+	if isEndpointURL(endpoint) {
+		opts = append(opts, otlpmetricgrpc.WithEndpointURL(endpoint))
+	} else if endpoint != "" {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(endpoint))
+	}
+	if len(cfg.headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(cfg.headers))
+	}
+	if cfg.insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else if cfg.tlsConfig != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(cfg.tlsConfig)))
+	}
+	if cfg.compression != "" {
+		opts = append(opts, otlpmetricgrpc.WithCompressor(cfg.compression))
+	}
+	if cfg.timeout > 0 {
+		opts = append(opts, otlpmetricgrpc.WithTimeout(cfg.timeout))
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
 }
 
-func newLoggerProvider(ctx context.Context) (*log.LoggerProvider, error) {
+func newLoggerProvider(ctx context.Context, cfg sdkConfig) (*log.LoggerProvider, error) {
 	// // Exporter to stdout
 	// logExporter, err := stdoutlog.New()
 	// if err != nil {
@@ -159,17 +449,67 @@ func newLoggerProvider(ctx context.Context) (*log.LoggerProvider, error) {
 	// }
 
 	// Exporter to otlp
-	logExporter, err := newLogExporter(ctx)
+	logExporter, err := newLogExporter(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	loggerProvider := log.NewLoggerProvider(
 		log.WithProcessor(log.NewBatchProcessor(logExporter)),
+		log.WithResource(cfg.resource),
 	)
 	return loggerProvider, nil
 }
 
-func newLogExporter(ctx context.Context) (log.Exporter, error) {
-	return otlploggrpc.New(ctx)
+// newLogExporter creates a new OTLP log exporter. (gRPC or HTTP)
+func newLogExporter(ctx context.Context, cfg sdkConfig) (log.Exporter, error) {
+	endpoint, protocol := cfg.target(signalLogs)
+	if protocol == ProtocolHTTP {
+		var opts []otlploghttp.Option
+		if isEndpointURL(endpoint) {
+			opts = append(opts, otlploghttp.WithEndpointURL(endpoint))
+		} else if endpoint != "" {
+			opts = append(opts, otlploghttp.WithEndpoint(endpoint))
+		}
+		if len(cfg.headers) > 0 {
+			opts = append(opts, otlploghttp.WithHeaders(cfg.headers))
+		}
+		if cfg.insecure {
+			opts = append(opts, otlploghttp.WithInsecure())
+		} else if cfg.tlsConfig != nil {
+			opts = append(opts, otlploghttp.WithTLSClientConfig(cfg.tlsConfig))
+		}
+		switch cfg.compression {
+		case CompressionGzip:
+			opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+		case CompressionNone:
+			opts = append(opts, otlploghttp.WithCompression(otlploghttp.NoCompression))
+		}
+		if cfg.timeout > 0 {
+			opts = append(opts, otlploghttp.WithTimeout(cfg.timeout))
+		}
+		return otlploghttp.New(ctx, opts...)
+	}
+
+	var opts []otlploggrpc.Option
+	if isEndpointURL(endpoint) {
+		opts = append(opts, otlploggrpc.WithEndpointURL(endpoint))
+	} else if endpoint != "" {
+		opts = append(opts, otlploggrpc.WithEndpoint(endpoint))
+	}
+	if len(cfg.headers) > 0 {
+		opts = append(opts, otlploggrpc.WithHeaders(cfg.headers))
+	}
+	if cfg.insecure {
+		opts = append(opts, otlploggrpc.WithInsecure())
+	} else if cfg.tlsConfig != nil {
+		opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(cfg.tlsConfig)))
+	}
+	if cfg.compression != "" {
+		opts = append(opts, otlploggrpc.WithCompressor(cfg.compression))
+	}
+	if cfg.timeout > 0 {
+		opts = append(opts, otlploggrpc.WithTimeout(cfg.timeout))
+	}
+	return otlploggrpc.New(ctx, opts...)
 }
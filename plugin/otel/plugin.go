@@ -0,0 +1,545 @@
+package otel
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Standard OpenTelemetry env vars, used when the flags are not set
+const (
+	envServiceName          = "OTEL_SERVICE_NAME"
+	envExporterOtlpEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envExporterOtlpProtocol = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	envTracesSampler        = "OTEL_TRACES_SAMPLER"
+	envTracesSamplerArg     = "OTEL_TRACES_SAMPLER_ARG"
+	envMetricsExporter      = "OTEL_METRICS_EXPORTER"
+	envExporterOtlpHeaders  = "OTEL_EXPORTER_OTLP_HEADERS"
+	envExporterOtlpCAFile   = "OTEL_EXPORTER_OTLP_CERTIFICATE"
+	envExporterOtlpCompress = "OTEL_EXPORTER_OTLP_COMPRESSION"
+	envResourceAttributes   = "OTEL_RESOURCE_ATTRIBUTES"
+	envPropagators          = "OTEL_PROPAGATORS"
+)
+
+// Signal specific env vars, e.g. OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+func envSignalEndpoint(signal string) string {
+	return "OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_ENDPOINT"
+}
+
+func envSignalProtocol(signal string) string {
+	return "OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_PROTOCOL"
+}
+
+var (
+	serviceName    string
+	serviceVersion string
+	// set when the plugin has set up the sdk
+	enabled atomic.Bool
+)
+
+// IsEnabled reports whether the otel plugin has set up the sdk,
+// servers use it to decide whether to install instrumentation
+func IsEnabled() bool {
+	return enabled.Load()
+}
+
+// SetServiceInfo sets the default service.name and service.version
+// resource attributes. The service sets them from its name and version.
+func SetServiceInfo(name, version string) {
+	serviceName = name
+	serviceVersion = version
+}
+
+type otelPlugin struct {
+	name   string
+	prefix string
+	logger logger.Logger
+
+	serviceName           string
+	serviceNamespace      string
+	serviceInstanceID     string
+	deploymentEnvironment string
+	resourceAttributes    string
+	exporterOtlpEndpoint  string
+	exporterOtlpProtocol  string
+	// signal => overrides of the shared endpoint and protocol
+	signals                map[string]*otlpTarget
+	propagators            string
+	tracesSampler          string
+	tracesSamplerArg       string
+	metricsExporter        string
+	pushgatewayURL         string
+	exporterOtlpHeaders    string
+	exporterOtlpInsecure   bool
+	exporterOtlpCAFile     string
+	exporterOtlpCompress   string
+	exporterOtlpTimeout    time.Duration
+	runtimeMetrics         bool
+	runtimeMetricsInterval time.Duration
+	shutdownTimeout        time.Duration
+	// export settings, the defaults are the sdk ones
+	metricExportInterval    time.Duration
+	traceBatchTimeout       time.Duration
+	traceMaxQueueSize       int
+	traceMaxExportBatchSize int
+
+	sampler    trace.Sampler
+	propagator propagation.TextMapPropagator
+	headers    map[string]string
+	resource   map[string]string
+	tlsConfig  *tls.Config
+	ctx        context.Context
+	shutdown   func(context.Context) error
+	// set by Run
+	tracerProvider oteltrace.TracerProvider
+	meterProvider  otelmetric.MeterProvider
+}
+
+// Telemetry is returned by Get of the plugin to create custom instruments
+type Telemetry interface {
+	Tracer(name string, opts ...oteltrace.TracerOption) oteltrace.Tracer
+	Meter(name string, opts ...otelmetric.MeterOption) otelmetric.Meter
+}
+
+var _ Telemetry = (*otelPlugin)(nil)
+
+func NewOtelPlugin(name, prefix string) *otelPlugin {
+	return &otelPlugin{
+		name:   name,
+		prefix: prefix,
+		ctx:    context.Background(),
+		// defaults of the flags
+		runtimeMetrics:          true,
+		runtimeMetricsInterval:  15 * time.Second,
+		shutdownTimeout:         10 * time.Second,
+		metricExportInterval:    time.Minute,
+		traceBatchTimeout:       5 * time.Second,
+		traceMaxQueueSize:       2048,
+		traceMaxExportBatchSize: 512,
+		signals: map[string]*otlpTarget{
+			signalTraces:  {},
+			signalMetrics: {},
+			signalLogs:    {},
+		},
+	}
+}
+
+func (op *otelPlugin) Name() string {
+	return op.name
+}
+
+func (op *otelPlugin) GetPrefix() string {
+	return op.prefix
+}
+
+func (op *otelPlugin) Get() interface{} {
+	return op
+}
+
+func (op *otelPlugin) InitFlags() {
+	op.InitFlagSet(flag.CommandLine)
+}
+
+func (op *otelPlugin) InitFlagSet(fs *flag.FlagSet) {
+	prefix := op.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&op.serviceName, prefix+"service-name", "", "service.name resource attribute, default is the service name (also $"+envServiceName+")")
+	fs.StringVar(&op.serviceNamespace, prefix+"service-namespace", "", "service.namespace resource attribute, e.g. the team or the product")
+	fs.StringVar(&op.serviceInstanceID, prefix+"service-instance-id", "", "service.instance.id resource attribute, default is hostname-pid")
+	fs.StringVar(&op.deploymentEnvironment, prefix+"deployment-environment", "", "deployment.environment resource attribute, default is the active profile")
+	fs.StringVar(&op.resourceAttributes, prefix+"resource-attributes", "", "Extra resource attributes, comma separated key=value. They override $"+envResourceAttributes)
+	fs.StringVar(&op.exporterOtlpEndpoint, prefix+"exporter-otlp-endpoint", "", "OTLP collector endpoint, host:port or URL. Default is localhost (also $"+envExporterOtlpEndpoint+")")
+	fs.StringVar(&op.exporterOtlpProtocol, prefix+"exporter-otlp-protocol", "", "OTLP protocol: grpc | http/protobuf. Default is grpc (also $"+envExporterOtlpProtocol+")")
+	for _, signal := range []string{signalTraces, signalMetrics, signalLogs} {
+		t := op.signals[signal]
+		fs.StringVar(&t.endpoint, prefix+"exporter-otlp-"+signal+"-endpoint", "", "OTLP collector endpoint of "+signal+", the URL is used as is. Default is exporter-otlp-endpoint (also $"+envSignalEndpoint(signal)+")")
+		fs.StringVar(&t.protocol, prefix+"exporter-otlp-"+signal+"-protocol", "", "OTLP protocol of "+signal+": grpc | http/protobuf. Default is exporter-otlp-protocol (also $"+envSignalProtocol(signal)+")")
+	}
+	fs.StringVar(&op.propagators, prefix+"propagators", "", "Context propagators, comma separated: tracecontext | baggage | b3 | b3multi | jaeger | none. Default is tracecontext,baggage (also $"+envPropagators+")")
+	fs.StringVar(&op.tracesSampler, prefix+"traces-sampler", "", "Traces sampler: always_on | always_off | traceidratio | parentbased_always_on | parentbased_always_off | parentbased_traceidratio. Default is parentbased_always_on (also $"+envTracesSampler+")")
+	fs.StringVar(&op.metricsExporter, prefix+"metrics-exporter", "", "Metrics exporter: otlp | prometheus | stdout | none. prometheus serves /metrics on the http server. Default is otlp (also $"+envMetricsExporter+")")
+	fs.StringVar(&op.pushgatewayURL, prefix+"prometheus-pushgateway-url", "", "Push the prometheus metrics to this pushgateway on stop, for batch jobs exiting before a scrape")
+	fs.StringVar(&op.tracesSamplerArg, prefix+"traces-sampler-arg", "", "Sampling ratio within [0,1] of the ratio based samplers. Default is 1 (also $"+envTracesSamplerArg+")")
+	fs.StringVar(&op.exporterOtlpHeaders, prefix+"exporter-otlp-headers", "", "Headers sent to the OTLP collector, comma separated key=value. E.g. authorization=Bearer%20token (also $"+envExporterOtlpHeaders+")")
+	fs.BoolVar(&op.exporterOtlpInsecure, prefix+"exporter-otlp-insecure", false, "Export to the OTLP collector without TLS")
+	fs.StringVar(&op.exporterOtlpCAFile, prefix+"exporter-otlp-ca-file", "", "PEM file of the CA certificates verifying the OTLP collector, default is the system roots (also $"+envExporterOtlpCAFile+")")
+	fs.StringVar(&op.exporterOtlpCompress, prefix+"exporter-otlp-compression", "", "OTLP compression: gzip | none. Default is none (also $"+envExporterOtlpCompress+")")
+	fs.DurationVar(&op.exporterOtlpTimeout, prefix+"exporter-otlp-timeout", 0, "Timeout of each OTLP export request. Default is 10s")
+	fs.DurationVar(&op.metricExportInterval, prefix+"metric-export-interval", op.metricExportInterval, "Interval between pushes of the otlp and stdout metrics exporters")
+	fs.DurationVar(&op.traceBatchTimeout, prefix+"trace-batch-timeout", op.traceBatchTimeout, "Maximum delay before a batch of spans is exported")
+	fs.IntVar(&op.traceMaxQueueSize, prefix+"trace-max-queue-size", op.traceMaxQueueSize, "Maximum spans buffered before export, more spans are dropped")
+	fs.IntVar(&op.traceMaxExportBatchSize, prefix+"trace-max-export-batch-size", op.traceMaxExportBatchSize, "Maximum spans of an export request, must not exceed trace-max-queue-size")
+	fs.DurationVar(&op.shutdownTimeout, prefix+"shutdown-timeout", op.shutdownTimeout, "Maximum time to flush the pending telemetry on stop")
+	fs.BoolVar(&op.runtimeMetrics, prefix+"runtime-metrics-enabled", op.runtimeMetrics, "Report the go runtime (gc, goroutines, heap) and host metrics")
+	fs.DurationVar(&op.runtimeMetricsInterval, prefix+"runtime-metrics-interval", op.runtimeMetricsInterval, "Minimum interval between reads of the go memory stats")
+}
+
+// Configure falls back to the standard OTEL_* env vars for flags
+// which are not set, explicit flags take precedence
+func (op *otelPlugin) Configure() error {
+	op.logger = logger.GetCurrent().GetLogger(op.name)
+
+	op.serviceName = valueOrEnv(op.serviceName, envServiceName, serviceName)
+	if op.serviceInstanceID == "" {
+		op.serviceInstanceID = defaultInstanceID()
+	}
+	if op.deploymentEnvironment == "" {
+		op.deploymentEnvironment = deploymentEnvironment
+	}
+
+	var err error
+	if op.resource, err = parseKeyValues(op.resourceAttributes); err != nil {
+		return fmt.Errorf("invalid resource attributes: %w", err)
+	}
+
+	op.exporterOtlpEndpoint = valueOrEnv(op.exporterOtlpEndpoint, envExporterOtlpEndpoint, "")
+	op.exporterOtlpProtocol = valueOrEnv(op.exporterOtlpProtocol, envExporterOtlpProtocol, ProtocolGRPC)
+
+	if op.exporterOtlpProtocol, err = parseProtocol(op.exporterOtlpProtocol); err != nil {
+		return err
+	}
+
+	for signal, t := range op.signals {
+		t.endpoint = valueOrEnv(t.endpoint, envSignalEndpoint(signal), "")
+		// empty falls back to the shared protocol
+		if t.protocol = valueOrEnv(t.protocol, envSignalProtocol(signal), ""); t.protocol == "" {
+			continue
+		}
+		if t.protocol, err = parseProtocol(t.protocol); err != nil {
+			return fmt.Errorf("%s: %w", signal, err)
+		}
+	}
+
+	op.metricsExporter = valueOrEnv(op.metricsExporter, envMetricsExporter, MetricsExporterOTLP)
+
+	if err := op.configureBatch(); err != nil {
+		return err
+	}
+
+	if op.shutdownTimeout <= 0 {
+		return fmt.Errorf("invalid shutdown timeout %v, must be positive", op.shutdownTimeout)
+	}
+
+	if op.runtimeMetrics && op.runtimeMetricsInterval <= 0 {
+		return fmt.Errorf("invalid runtime metrics interval %v, must be positive", op.runtimeMetricsInterval)
+	}
+
+	switch op.metricsExporter {
+	case MetricsExporterOTLP, MetricsExporterPrometheus, MetricsExporterStdout, MetricsExporterNone:
+	default:
+		return fmt.Errorf("unknown metrics exporter %q, must be %s, %s, %s or %s", op.metricsExporter,
+			MetricsExporterOTLP, MetricsExporterPrometheus, MetricsExporterStdout, MetricsExporterNone)
+	}
+
+	if op.pushgatewayURL != "" {
+		if op.metricsExporter != MetricsExporterPrometheus {
+			return fmt.Errorf("pushgateway requires the %s metrics exporter", MetricsExporterPrometheus)
+		}
+		if err := validatePushgatewayURL(op.pushgatewayURL); err != nil {
+			return err
+		}
+	}
+
+	if err := op.configureExporter(); err != nil {
+		return err
+	}
+
+	op.propagators = valueOrEnv(op.propagators, envPropagators, defaultPropagators)
+	if op.propagator, err = newPropagator(op.propagators); err != nil {
+		return err
+	}
+
+	op.tracesSampler = valueOrEnv(op.tracesSampler, envTracesSampler, SamplerParentBasedAlwaysOn)
+	op.tracesSamplerArg = valueOrEnv(op.tracesSamplerArg, envTracesSamplerArg, "")
+
+	ratio, err := parseSamplerArg(op.tracesSamplerArg)
+	if err != nil {
+		return err
+	}
+
+	op.sampler, err = newSampler(op.tracesSampler, ratio)
+	return err
+}
+
+// configureExporter parses the headers, TLS and compression of the OTLP exporters
+func (op *otelPlugin) configureExporter() error {
+	var err error
+	op.exporterOtlpHeaders = valueOrEnv(op.exporterOtlpHeaders, envExporterOtlpHeaders, "")
+	if op.headers, err = parseKeyValues(op.exporterOtlpHeaders); err != nil {
+		return fmt.Errorf("invalid otlp headers: %w", err)
+	}
+
+	op.exporterOtlpCompress = valueOrEnv(op.exporterOtlpCompress, envExporterOtlpCompress, "")
+	switch op.exporterOtlpCompress {
+	case "", CompressionGzip, CompressionNone:
+	default:
+		return fmt.Errorf("unknown otlp compression %q, must be %s or %s", op.exporterOtlpCompress, CompressionGzip, CompressionNone)
+	}
+
+	if op.exporterOtlpTimeout < 0 {
+		return fmt.Errorf("invalid otlp timeout %v, must not be negative", op.exporterOtlpTimeout)
+	}
+
+	op.exporterOtlpCAFile = valueOrEnv(op.exporterOtlpCAFile, envExporterOtlpCAFile, "")
+	op.tlsConfig = nil
+	if op.exporterOtlpCAFile != "" {
+		if op.exporterOtlpInsecure {
+			return fmt.Errorf("otlp ca file can't be used with insecure")
+		}
+		if op.tlsConfig, err = newTLSConfig(op.exporterOtlpCAFile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configureBatch validates the export settings of metrics and traces
+func (op *otelPlugin) configureBatch() error {
+	if op.metricExportInterval <= 0 {
+		return fmt.Errorf("invalid metric export interval %v, must be positive", op.metricExportInterval)
+	}
+	if op.traceBatchTimeout <= 0 {
+		return fmt.Errorf("invalid trace batch timeout %v, must be positive", op.traceBatchTimeout)
+	}
+	if op.traceMaxExportBatchSize <= 0 {
+		return fmt.Errorf("invalid trace max export batch size %d, must be positive", op.traceMaxExportBatchSize)
+	}
+	if op.traceMaxQueueSize < op.traceMaxExportBatchSize {
+		return fmt.Errorf("trace max queue size %d must not be less than the max export batch size %d", op.traceMaxQueueSize, op.traceMaxExportBatchSize)
+	}
+	return nil
+}
+
+// parseProtocol accepts http as an alias of http/protobuf
+func parseProtocol(protocol string) (string, error) {
+	switch protocol {
+	case ProtocolGRPC, ProtocolHTTP:
+		return protocol, nil
+	case "http":
+		return ProtocolHTTP, nil
+	default:
+		return "", fmt.Errorf("unknown otlp protocol %q, must be %s or %s", protocol, ProtocolGRPC, ProtocolHTTP)
+	}
+}
+
+func valueOrEnv(value, env, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+func (op *otelPlugin) sdkConfig() sdkConfig {
+	signals := make(map[string]otlpTarget, len(op.signals))
+	for signal, t := range op.signals {
+		signals[signal] = *t
+	}
+
+	return sdkConfig{
+		serviceName:     op.serviceName,
+		serviceVersion:  serviceVersion,
+		endpoint:        op.exporterOtlpEndpoint,
+		protocol:        op.exporterOtlpProtocol,
+		signals:         signals,
+		metricsExporter: op.metricsExporter,
+		sampler:         op.sampler,
+		propagator:      op.propagator,
+		headers:         op.headers,
+		insecure:        op.exporterOtlpInsecure,
+		tlsConfig:       op.tlsConfig,
+		compression:     op.exporterOtlpCompress,
+		timeout:         op.exporterOtlpTimeout,
+
+		metricInterval:          op.metricExportInterval,
+		traceBatchTimeout:       op.traceBatchTimeout,
+		traceMaxQueueSize:       op.traceMaxQueueSize,
+		traceMaxExportBatchSize: op.traceMaxExportBatchSize,
+	}
+}
+
+func (op *otelPlugin) Run() error {
+	if err := op.Configure(); err != nil {
+		return err
+	}
+
+	cfg := op.sdkConfig()
+	res, err := newResource(op.ctx, resourceConfig{
+		serviceName:           op.serviceName,
+		serviceVersion:        serviceVersion,
+		serviceNamespace:      op.serviceNamespace,
+		serviceInstanceID:     op.serviceInstanceID,
+		deploymentEnvironment: op.deploymentEnvironment,
+		attributes:            op.resource,
+	})
+	if err != nil {
+		op.logger.Warn(err.Error())
+	}
+	cfg.resource = res
+
+	shutdown, err := setupOTelSDK(op.ctx, cfg)
+	if err != nil {
+		return err
+	}
+	op.shutdown = shutdown
+	op.tracerProvider = otel.GetTracerProvider()
+	op.meterProvider = otel.GetMeterProvider()
+
+	if op.runtimeMetrics && op.metricsExporter != MetricsExporterNone {
+		if err := startRuntimeMetrics(op.meterProvider, op.runtimeMetricsInterval); err != nil {
+			return errors.Join(err, shutdown(op.ctx))
+		}
+	}
+
+	enabled.Store(true)
+
+	for _, signal := range []string{signalTraces, signalMetrics, signalLogs} {
+		if signal == signalMetrics && op.metricsExporter != MetricsExporterOTLP {
+			continue
+		}
+
+		endpoint, protocol := cfg.target(signal)
+		if endpoint == "" {
+			endpoint = "default endpoint"
+		}
+		op.logger.Infof("exporting %s to %s over %s", signal, endpoint, protocol)
+	}
+	if len(op.headers) > 0 {
+		op.logger.Debugf("otlp headers: %s", maskHeaders(op.headers))
+	}
+
+	return nil
+}
+
+// CheckConnectivity dials the OTLP endpoints of the exported signals over TCP
+func (op *otelPlugin) CheckConnectivity(ctx context.Context) error {
+	cfg := op.sdkConfig()
+	dialer := net.Dialer{}
+	dialed := map[string]bool{}
+
+	var errs []error
+	for _, signal := range []string{signalTraces, signalMetrics, signalLogs} {
+		if signal == signalMetrics && op.metricsExporter != MetricsExporterOTLP {
+			continue
+		}
+
+		addr := dialAddress(cfg.target(signal))
+		if dialed[addr] {
+			continue
+		}
+		dialed[addr] = true
+
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s endpoint: %w", signal, err))
+			continue
+		}
+		_ = conn.Close()
+	}
+	return errors.Join(errs...)
+}
+
+// dialAddress returns the host:port of an endpoint, the default one of the protocol when empty
+func dialAddress(endpoint, protocol string) string {
+	if endpoint == "" {
+		if protocol == ProtocolHTTP {
+			return "localhost:4318"
+		}
+		return "localhost:4317"
+	}
+
+	if !isEndpointURL(endpoint) {
+		return endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		// reported by the dial
+		return endpoint
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// Tracer falls back to the global provider when the plugin isn't running
+func (op *otelPlugin) Tracer(name string, opts ...oteltrace.TracerOption) oteltrace.Tracer {
+	if op.tracerProvider == nil {
+		return otel.Tracer(name, opts...)
+	}
+	return op.tracerProvider.Tracer(name, opts...)
+}
+
+// Meter falls back to the global provider when the plugin isn't running
+func (op *otelPlugin) Meter(name string, opts ...otelmetric.MeterOption) otelmetric.Meter {
+	if op.meterProvider == nil {
+		return otel.Meter(name, opts...)
+	}
+	return op.meterProvider.Meter(name, opts...)
+}
+
+// Stop flushes the pending telemetry and shuts down the providers,
+// it stops the runtime metrics too. Receives false if the flush timed out.
+func (op *otelPlugin) Stop() <-chan bool {
+	c := make(chan bool, 1)
+	go func() { c <- op.stop() }()
+	return c
+}
+
+func (op *otelPlugin) stop() bool {
+	// Run was never called or the plugin is already stopped
+	if op.shutdown == nil {
+		return true
+	}
+	shutdown := op.shutdown
+	op.shutdown = nil
+
+	enabled.Store(false)
+	metricsHandler.Store(nil)
+
+	ctx, cancel := context.WithTimeout(op.ctx, op.shutdownTimeout)
+	defer cancel()
+
+	// the entries buffered by log-async are written before the process exits
+	if err := logger.Flush(); err != nil {
+		op.logger.Error(err.Error())
+	}
+
+	if op.pushgatewayURL != "" {
+		if err := pushMetrics(ctx, op.pushgatewayURL, op.serviceName, op.serviceInstanceID); err != nil {
+			op.logger.Error(err.Error())
+		}
+	}
+	metricsRegistry.Store(nil)
+
+	if err := shutdown(ctx); err != nil {
+		op.logger.Error("cannot shutdown otel: ", err.Error())
+		return !errors.Is(err, context.DeadlineExceeded)
+	}
+	return true
+}
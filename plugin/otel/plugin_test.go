@@ -0,0 +1,311 @@
+package otel
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestConfigureEnvFallback(t *testing.T) {
+	logger.InitServLogger(false)
+	SetServiceInfo("orders", "1.0.0")
+	defer SetServiceInfo("", "")
+
+	t.Setenv(envServiceName, "orders-api")
+	t.Setenv(envExporterOtlpEndpoint, "collector:4317")
+	t.Setenv(envExporterOtlpProtocol, "http/protobuf")
+
+	op := NewOtelPlugin("otel", "otel")
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, "orders-api", op.serviceName, "should be equal")
+	assert.Equal(t, "collector:4317", op.exporterOtlpEndpoint, "should be equal")
+	assert.Equal(t, ProtocolHTTP, op.exporterOtlpProtocol, "should be equal")
+
+	// explicit flags take precedence
+	op = NewOtelPlugin("otel", "otel")
+	op.exporterOtlpEndpoint = "other:4317"
+	op.exporterOtlpProtocol = ProtocolGRPC
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, "other:4317", op.exporterOtlpEndpoint, "should be equal")
+	assert.Equal(t, ProtocolGRPC, op.exporterOtlpProtocol, "should be equal")
+}
+
+func TestConfigureDefaults(t *testing.T) {
+	logger.InitServLogger(false)
+	SetServiceInfo("orders", "1.0.0")
+	defer SetServiceInfo("", "")
+
+	op := NewOtelPlugin("otel", "otel")
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, "orders", op.serviceName, "should be equal")
+	assert.Equal(t, ProtocolGRPC, op.exporterOtlpProtocol, "should be equal")
+
+	op = NewOtelPlugin("otel", "otel")
+	op.exporterOtlpProtocol = "thrift"
+	assert.NotNil(t, op.Configure(), "should be an error")
+}
+
+func TestTraceExporterEndpoint(t *testing.T) {
+	var received atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" {
+			received.Add(1)
+		}
+	}))
+	defer srv.Close()
+
+	exporter, err := newTraceExporter(context.Background(), sdkConfig{endpoint: srv.URL, protocol: ProtocolHTTP})
+	assert.Nil(t, err, "must be nil")
+
+	tp := trace.NewTracerProvider(trace.WithSyncer(exporter))
+	_, span := tp.Tracer("test").Start(context.Background(), "span")
+	span.End()
+	assert.Nil(t, tp.Shutdown(context.Background()), "must be nil")
+
+	assert.Equal(t, int32(1), received.Load(), "spans must be sent to the configured endpoint")
+}
+
+func TestMetricsExporter(t *testing.T) {
+	logger.InitServLogger(false)
+
+	op := NewOtelPlugin("otel", "otel")
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, MetricsExporterOTLP, op.metricsExporter, "should be equal")
+
+	t.Setenv(envMetricsExporter, MetricsExporterPrometheus)
+	op = NewOtelPlugin("otel", "otel")
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, MetricsExporterPrometheus, op.metricsExporter, "should be equal")
+
+	op = NewOtelPlugin("otel", "otel")
+	op.metricsExporter = "statsd"
+	assert.NotNil(t, op.Configure(), "should be an error")
+
+	defer metricsHandler.Store(nil)
+	assert.Nil(t, MetricsHandler(), "must be nil")
+	_, err := newMetricReader(context.Background(), sdkConfig{metricsExporter: MetricsExporterPrometheus})
+	assert.Nil(t, err, "must be nil")
+	assert.NotNil(t, MetricsHandler(), "should serve prometheus metrics")
+}
+
+func TestConfigureSignals(t *testing.T) {
+	logger.InitServLogger(false)
+	t.Setenv(envExporterOtlpEndpoint, "http://collector:4318")
+	t.Setenv(envExporterOtlpProtocol, ProtocolHTTP)
+	t.Setenv(envSignalEndpoint(signalTraces), "tempo:4317")
+	t.Setenv(envSignalProtocol(signalTraces), ProtocolGRPC)
+
+	op := NewOtelPlugin("otel", "otel")
+	op.signals[signalMetrics].endpoint = "http://mimir:4318/otlp/v1/metrics"
+	op.signals[signalLogs].protocol = "http"
+	assert.Nil(t, op.Configure(), "must be nil")
+
+	cfg := op.sdkConfig()
+	endpoint, protocol := cfg.target(signalTraces)
+	assert.Equal(t, "tempo:4317", endpoint, "should be equal")
+	assert.Equal(t, ProtocolGRPC, protocol, "should be equal")
+
+	// signal specific URLs are used as is
+	endpoint, protocol = cfg.target(signalMetrics)
+	assert.Equal(t, "http://mimir:4318/otlp/v1/metrics", endpoint, "should be equal")
+	assert.Equal(t, ProtocolHTTP, protocol, "should be equal")
+
+	// the shared URL gets the signal path
+	endpoint, protocol = cfg.target(signalLogs)
+	assert.Equal(t, "http://collector:4318/v1/logs", endpoint, "should be equal")
+	assert.Equal(t, ProtocolHTTP, protocol, "should be equal")
+
+	op = NewOtelPlugin("otel", "otel")
+	op.signals[signalLogs].protocol = "thrift"
+	assert.NotNil(t, op.Configure(), "should be an error")
+}
+
+func TestSignalExporterEndpoint(t *testing.T) {
+	var logs, metrics atomic.Int32
+	shared := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/otlp/v1/logs" {
+			logs.Add(1)
+		}
+	}))
+	defer shared.Close()
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" {
+			metrics.Add(1)
+		}
+	}))
+	defer other.Close()
+
+	ctx := context.Background()
+	cfg := sdkConfig{
+		endpoint: shared.URL + "/otlp",
+		protocol: ProtocolHTTP,
+		signals:  map[string]otlpTarget{signalMetrics: {endpoint: other.URL + "/metrics"}},
+	}
+
+	logExporter, err := newLogExporter(ctx, cfg)
+	assert.Nil(t, err, "must be nil")
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(logExporter)))
+	lp.Logger("test").Emit(ctx, otellog.Record{})
+	assert.Nil(t, lp.Shutdown(ctx), "must be nil")
+
+	metricExporter, err := newMetricExporter(ctx, cfg)
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, metricExporter.Export(ctx, &metricdata.ResourceMetrics{}), "must be nil")
+
+	assert.Equal(t, int32(1), logs.Load(), "logs must be sent to the shared endpoint")
+	assert.Equal(t, int32(1), metrics.Load(), "metrics must be sent to their own endpoint")
+}
+
+func TestConfigureBatch(t *testing.T) {
+	logger.InitServLogger(false)
+
+	op := NewOtelPlugin("otel", "otel")
+	assert.Nil(t, op.Configure(), "must be nil")
+	cfg := op.sdkConfig()
+	assert.Equal(t, time.Minute, cfg.metricInterval, "should be equal")
+	assert.Equal(t, 5*time.Second, cfg.traceBatchTimeout, "should be equal")
+	assert.Equal(t, 2048, cfg.traceMaxQueueSize, "should be equal")
+	assert.Equal(t, 512, cfg.traceMaxExportBatchSize, "should be equal")
+
+	for _, invalid := range []func(op *otelPlugin){
+		func(op *otelPlugin) { op.metricExportInterval = 0 },
+		func(op *otelPlugin) { op.traceBatchTimeout = -time.Second },
+		func(op *otelPlugin) { op.traceMaxExportBatchSize = 0 },
+		func(op *otelPlugin) { op.traceMaxQueueSize = 100 },
+	} {
+		op = NewOtelPlugin("otel", "otel")
+		invalid(op)
+		assert.NotNil(t, op.Configure(), "should be an error")
+	}
+}
+
+func TestExportIntervals(t *testing.T) {
+	var traces, metrics atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/traces":
+			traces.Add(1)
+		case "/v1/metrics":
+			metrics.Add(1)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	cfg := sdkConfig{
+		endpoint:          srv.URL,
+		protocol:          ProtocolHTTP,
+		metricInterval:    50 * time.Millisecond,
+		traceBatchTimeout: 50 * time.Millisecond,
+	}
+
+	tp, err := newTraceProvider(ctx, cfg)
+	assert.Nil(t, err, "must be nil")
+	defer tp.Shutdown(ctx)
+	mp, err := newMeterProvider(ctx, cfg)
+	assert.Nil(t, err, "must be nil")
+	defer mp.Shutdown(ctx)
+
+	_, span := tp.Tracer("test").Start(ctx, "span")
+	span.End()
+	counter, err := mp.Meter("test").Int64Counter("orders.created")
+	assert.Nil(t, err, "must be nil")
+	counter.Add(ctx, 1)
+
+	// exported without flushing
+	assert.Eventually(t, func() bool {
+		return traces.Load() > 0 && metrics.Load() > 0
+	}, 2*time.Second, 10*time.Millisecond, "should be exported by the intervals")
+}
+
+// slowExporter takes delay to export, or until the context is done
+type slowExporter struct {
+	delay    time.Duration
+	exported atomic.Int32
+}
+
+func (e *slowExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	select {
+	case <-time.After(e.delay):
+		e.exported.Add(int32(len(spans)))
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *slowExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestStop(t *testing.T) {
+	logger.InitServLogger(false)
+
+	// Run was never called
+	op := NewOtelPlugin("otel", "otel")
+	assert.True(t, <-op.Stop(), "should be true")
+
+	exporter := &slowExporter{delay: 100 * time.Millisecond}
+	tp := trace.NewTracerProvider(trace.WithBatcher(exporter))
+	assert.Nil(t, op.Configure(), "must be nil")
+	op.shutdown = tp.Shutdown
+
+	_, span := tp.Tracer("test").Start(context.Background(), "span")
+	span.End()
+	assert.True(t, <-op.Stop(), "should be true")
+	assert.Equal(t, int32(1), exporter.exported.Load(), "pending spans must be exported before stopped")
+	// stopping twice is a no-op
+	assert.True(t, <-op.Stop(), "should be true")
+
+	exporter = &slowExporter{delay: time.Minute}
+	tp = trace.NewTracerProvider(trace.WithBatcher(exporter))
+	op = NewOtelPlugin("otel", "otel")
+	op.shutdownTimeout = 50 * time.Millisecond
+	assert.Nil(t, op.Configure(), "must be nil")
+	op.shutdown = tp.Shutdown
+
+	_, span = tp.Tracer("test").Start(context.Background(), "span")
+	span.End()
+	assert.False(t, <-op.Stop(), "should be stopped forcibly")
+	assert.Equal(t, int32(0), exporter.exported.Load(), "should be equal")
+}
+
+func TestCheckConnectivity(t *testing.T) {
+	logger.InitServLogger(false)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "must be nil")
+
+	op := NewOtelPlugin("otel", "otel")
+	op.exporterOtlpEndpoint = lis.Addr().String()
+	assert.Nil(t, op.Configure(), "must be nil")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, op.CheckConnectivity(ctx), "must be nil")
+
+	_ = lis.Close()
+	err = op.CheckConnectivity(ctx)
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "traces endpoint")
+}
+
+func TestDialAddress(t *testing.T) {
+	assert.Equal(t, "localhost:4317", dialAddress("", ProtocolGRPC), "should be equal")
+	assert.Equal(t, "localhost:4318", dialAddress("", ProtocolHTTP), "should be equal")
+	assert.Equal(t, "collector:4317", dialAddress("collector:4317", ProtocolGRPC), "should be equal")
+	assert.Equal(t, "collector:4318", dialAddress("http://collector:4318/v1/traces", ProtocolHTTP), "should be equal")
+	assert.Equal(t, "collector:443", dialAddress("https://collector/v1/traces", ProtocolHTTP), "should be equal")
+}
@@ -0,0 +1,55 @@
+package otel
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	PropagatorTraceContext = "tracecontext"
+	PropagatorBaggage      = "baggage"
+	PropagatorB3           = "b3"
+	PropagatorB3Multi      = "b3multi"
+	PropagatorJaeger       = "jaeger"
+	PropagatorNone         = "none"
+)
+
+const defaultPropagators = PropagatorTraceContext + "," + PropagatorBaggage
+
+// newPropagator maps the comma separated OTEL_PROPAGATORS names to a composite propagator.
+// none disables propagation.
+func newPropagator(names string) (propagation.TextMapPropagator, error) {
+	var props []propagation.TextMapPropagator
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case PropagatorTraceContext:
+			props = append(props, propagation.TraceContext{})
+		case PropagatorBaggage:
+			props = append(props, propagation.Baggage{})
+		case PropagatorB3:
+			props = append(props, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case PropagatorB3Multi:
+			props = append(props, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case PropagatorJaeger:
+			props = append(props, jaeger.Jaeger{})
+		case PropagatorNone:
+		default:
+			return nil, fmt.Errorf("unknown propagator %q, must be one of %s, %s, %s, %s, %s, %s", name,
+				PropagatorTraceContext, PropagatorBaggage, PropagatorB3, PropagatorB3Multi, PropagatorJaeger, PropagatorNone)
+		}
+	}
+
+	return propagation.NewCompositeTextMapPropagator(props...), nil
+}
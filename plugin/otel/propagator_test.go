@@ -0,0 +1,81 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestNewPropagator(t *testing.T) {
+	prop, err := newPropagator(defaultPropagators)
+	assert.Nil(t, err, "must be nil")
+	assert.ElementsMatch(t, []string{"traceparent", "tracestate", "baggage"}, prop.Fields(), "should be equal")
+
+	prop, err = newPropagator("b3multi, jaeger")
+	assert.Nil(t, err, "must be nil")
+	assert.Contains(t, prop.Fields(), "x-b3-traceid", "should contain")
+	assert.Contains(t, prop.Fields(), "uber-trace-id", "should contain")
+
+	prop, err = newPropagator(PropagatorNone)
+	assert.Nil(t, err, "must be nil")
+	assert.Empty(t, prop.Fields(), "should be empty")
+
+	_, err = newPropagator("tracecontext,xray")
+	assert.NotNil(t, err, "should be an error")
+}
+
+func TestConfigurePropagators(t *testing.T) {
+	logger.InitServLogger(false)
+	t.Setenv(envPropagators, "b3")
+
+	op := NewOtelPlugin("otel", "otel")
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, []string{"b3"}, op.propagator.Fields(), "should be equal")
+
+	op = NewOtelPlugin("otel", "otel")
+	op.propagators = "zipkin"
+	assert.NotNil(t, op.Configure(), "should be an error")
+}
+
+func TestB3Propagation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	prop, err := newPropagator("tracecontext,b3")
+	assert.Nil(t, err, "must be nil")
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+	otel.SetTextMapPropagator(prop)
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := trace.NewTracerProvider(trace.WithSpanProcessor(recorder))
+	defer tp.Shutdown(context.Background())
+
+	r := gin.New()
+	r.Use(otelgin.Middleware("orders", otelgin.WithTracerProvider(tp)))
+	r.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("b3", "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1, "should have a span")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String(), "should continue the b3 trace")
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String(), "should be a child of the b3 span")
+	assert.True(t, spans[0].Parent().IsRemote(), "should be true")
+
+	// injected as b3 for the downstream services
+	carrier := propagation.HeaderCarrier{}
+	prop.Inject(oteltrace.ContextWithSpanContext(context.Background(), spans[0].SpanContext()), carrier)
+	assert.Contains(t, carrier.Get("b3"), "4bf92f3577b34da6a3ce929d0e0e4736", "should contain")
+}
@@ -0,0 +1,40 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// validatePushgatewayURL requires an absolute http(s) URL
+func validatePushgatewayURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid pushgateway url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid pushgateway url %q, must be http(s)://host:port", rawURL)
+	}
+	return nil
+}
+
+// pushMetrics pushes the metrics of the prometheus exporter to a pushgateway,
+// so batch jobs exiting before a scrape still report them.
+// The metrics of job and instance are replaced on every push.
+func pushMetrics(ctx context.Context, gatewayURL, job, instance string) error {
+	registry := metricsRegistry.Load()
+	if registry == nil {
+		return nil
+	}
+
+	err := push.New(gatewayURL, job).
+		Grouping("instance", instance).
+		Gatherer(registry).
+		PushContext(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot push metrics to %s: %w", gatewayURL, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package otel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+func TestPushMetrics(t *testing.T) {
+	var path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	defer metricsHandler.Store(nil)
+	defer metricsRegistry.Store(nil)
+
+	mp, err := newMeterProvider(ctx, sdkConfig{metricsExporter: MetricsExporterPrometheus})
+	assert.Nil(t, err, "must be nil")
+	defer mp.Shutdown(ctx)
+
+	counter, err := mp.Meter("test").Int64Counter("orders.created")
+	assert.Nil(t, err, "must be nil")
+	counter.Add(ctx, 1)
+
+	assert.Nil(t, pushMetrics(ctx, srv.URL, "orders", "orders-1"), "must be nil")
+	assert.Equal(t, "/metrics/job/orders/instance/orders-1", path, "should be equal")
+	// names are sanitized and the resource is reported as target_info
+	assert.Contains(t, body, "orders_created_total", "should contain")
+	assert.Contains(t, body, "target_info", "should contain")
+
+	srv.Close()
+	assert.NotNil(t, pushMetrics(ctx, srv.URL, "orders", "orders-1"), "should be an error")
+}
+
+func TestMetricsExporterNone(t *testing.T) {
+	logger.InitServLogger(false)
+
+	reader, err := newMetricReader(context.Background(), sdkConfig{metricsExporter: MetricsExporterNone})
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, reader, "must be nil")
+
+	mp, err := newMeterProvider(context.Background(), sdkConfig{metricsExporter: MetricsExporterNone})
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, mp.Shutdown(context.Background()), "must be nil")
+
+	op := NewOtelPlugin("otel", "otel")
+	op.metricsExporter = MetricsExporterNone
+	assert.Nil(t, op.Configure(), "must be nil")
+}
+
+func TestConfigurePushgateway(t *testing.T) {
+	logger.InitServLogger(false)
+
+	op := NewOtelPlugin("otel", "otel")
+	op.metricsExporter = MetricsExporterPrometheus
+	op.pushgatewayURL = "http://pushgateway:9091"
+	assert.Nil(t, op.Configure(), "must be nil")
+
+	// requires the prometheus exporter
+	op = NewOtelPlugin("otel", "otel")
+	op.pushgatewayURL = "http://pushgateway:9091"
+	assert.NotNil(t, op.Configure(), "should be an error")
+
+	op = NewOtelPlugin("otel", "otel")
+	op.metricsExporter = MetricsExporterPrometheus
+	op.pushgatewayURL = "pushgateway:9091"
+	assert.NotNil(t, op.Configure(), "should be an error")
+}
@@ -0,0 +1,102 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// file of the namespace mounted in every pod with a service account
+var k8sNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// resourceConfig sets the attributes identifying the service instance
+type resourceConfig struct {
+	serviceName           string
+	serviceVersion        string
+	serviceNamespace      string
+	serviceInstanceID     string
+	deploymentEnvironment string
+	// extra attributes, they override the detected ones
+	attributes map[string]string
+}
+
+// newResource detects the host, os, process, container and k8s attributes,
+// merged with $OTEL_RESOURCE_ATTRIBUTES and the configured ones.
+// The resource is usable even if detection fails, the error is only a warning.
+func newResource(ctx context.Context, cfg resourceConfig) (*resource.Resource, error) {
+	extra := make([]attribute.KeyValue, 0, len(cfg.attributes))
+	for k, v := range cfg.attributes {
+		extra = append(extra, attribute.String(k, v))
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(cfg.serviceName),
+		semconv.ServiceVersionKey.String(cfg.serviceVersion),
+		semconv.ServiceInstanceIDKey.String(cfg.serviceInstanceID),
+	}
+	if cfg.serviceNamespace != "" {
+		attrs = append(attrs, semconv.ServiceNamespaceKey.String(cfg.serviceNamespace))
+	}
+	if cfg.deploymentEnvironment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(cfg.deploymentEnvironment))
+	}
+
+	// later options override the earlier ones
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOS(),
+		// command args are not detected, they may contain secrets passed as flags
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithContainer(),
+		resource.WithDetectors(k8sDetector{}),
+		resource.WithFromEnv(),
+		resource.WithAttributes(extra...),
+		resource.WithAttributes(attrs...),
+	)
+	if res == nil {
+		res = resource.NewWithAttributes(semconv.SchemaURL, append(extra, attrs...)...)
+	}
+	if err != nil {
+		return res, fmt.Errorf("cannot detect all resource attributes: %w", err)
+	}
+	return res, nil
+}
+
+// defaultInstanceID is unique per process, even with several instances on a host
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// k8sDetector detects the pod and namespace when running in kubernetes.
+// The hostname of a pod is its name.
+type k8sDetector struct{}
+
+func (k8sDetector) Detect(context.Context) (*resource.Resource, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return resource.Empty(), nil
+	}
+
+	var attrs []attribute.KeyValue
+	if hostname, err := os.Hostname(); err == nil {
+		attrs = append(attrs, semconv.K8SPodNameKey.String(hostname))
+	}
+	if ns, err := os.ReadFile(k8sNamespaceFile); err == nil {
+		attrs = append(attrs, semconv.K8SNamespaceNameKey.String(strings.TrimSpace(string(ns))))
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
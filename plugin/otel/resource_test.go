@@ -0,0 +1,87 @@
+package otel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func resourceValues(res *resource.Resource) map[attribute.Key]string {
+	values := make(map[attribute.Key]string)
+	for _, kv := range res.Attributes() {
+		values[kv.Key] = kv.Value.Emit()
+	}
+	return values
+}
+
+func TestNewResource(t *testing.T) {
+	t.Setenv(envResourceAttributes, "team=payments,service.namespace=from-env")
+
+	res, err := newResource(context.Background(), resourceConfig{
+		serviceName:           "orders",
+		serviceVersion:        "1.0.0",
+		serviceNamespace:      "shop",
+		serviceInstanceID:     "orders-1",
+		deploymentEnvironment: "staging",
+		attributes:            map[string]string{"region": "eu-west-1"},
+	})
+	assert.Nil(t, err, "must be nil")
+
+	values := resourceValues(res)
+	for _, key := range []attribute.Key{"host.name", "os.type", "process.pid", "process.runtime.name", "telemetry.sdk.name"} {
+		assert.NotEmpty(t, values[key], "should be detected: %s", key)
+	}
+	assert.NotContains(t, values, attribute.Key("process.command_args"), "command args must not be exported")
+
+	assert.Equal(t, "orders", values["service.name"], "should be equal")
+	assert.Equal(t, "1.0.0", values["service.version"], "should be equal")
+	assert.Equal(t, "orders-1", values["service.instance.id"], "should be equal")
+	assert.Equal(t, "staging", values["deployment.environment"], "should be equal")
+	assert.Equal(t, "eu-west-1", values["region"], "should be equal")
+
+	// configured attributes override the env ones
+	assert.Equal(t, "payments", values["team"], "should be equal")
+	assert.Equal(t, "shop", values["service.namespace"], "should be equal")
+}
+
+func TestK8sDetector(t *testing.T) {
+	res, err := k8sDetector{}.Detect(context.Background())
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, 0, res.Len(), "should be equal")
+
+	defer func(file string) { k8sNamespaceFile = file }(k8sNamespaceFile)
+	k8sNamespaceFile = filepath.Join(t.TempDir(), "namespace")
+	assert.Nil(t, os.WriteFile(k8sNamespaceFile, []byte("shop\n"), 0o600), "must be nil")
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+
+	res, err = k8sDetector{}.Detect(context.Background())
+	assert.Nil(t, err, "must be nil")
+
+	hostname, _ := os.Hostname()
+	values := resourceValues(res)
+	assert.Equal(t, hostname, values["k8s.pod.name"], "should be equal")
+	assert.Equal(t, "shop", values["k8s.namespace.name"], "should be equal")
+}
+
+func TestConfigureResource(t *testing.T) {
+	logger.InitServLogger(false)
+	SetDeploymentEnvironment("prod")
+	defer SetDeploymentEnvironment("")
+
+	op := NewOtelPlugin("otel", "otel")
+	op.resourceAttributes = "region=eu-west-1"
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, defaultInstanceID(), op.serviceInstanceID, "should be equal")
+	assert.Equal(t, "prod", op.deploymentEnvironment, "should be equal")
+	assert.Equal(t, map[string]string{"region": "eu-west-1"}, op.resource, "should be equal")
+
+	op = NewOtelPlugin("otel", "otel")
+	op.resourceAttributes = "region"
+	assert.NotNil(t, op.Configure(), "should be an error")
+}
@@ -0,0 +1,29 @@
+package otel
+
+import (
+	"fmt"
+	"time"
+
+	hostinst "go.opentelemetry.io/contrib/instrumentation/host"
+	runtimeinst "go.opentelemetry.io/contrib/instrumentation/runtime"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+// startRuntimeMetrics reports the go runtime (gc, goroutines, heap) and the host
+// (cpu, memory, network) metrics. They are collected by callbacks of mp,
+// so they stop with its shutdown.
+func startRuntimeMetrics(mp otelmetric.MeterProvider, interval time.Duration) error {
+	err := runtimeinst.Start(
+		runtimeinst.WithMeterProvider(mp),
+		runtimeinst.WithMinimumReadMemStatsInterval(interval),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot start runtime metrics: %w", err)
+	}
+
+	if err := hostinst.Start(hostinst.WithMeterProvider(mp)); err != nil {
+		return fmt.Errorf("cannot start host metrics: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package otel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func metricNames(rm metricdata.ResourceMetrics) map[string]bool {
+	names := make(map[string]bool)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names[m.Name] = true
+		}
+	}
+	return names
+}
+
+func TestStartRuntimeMetrics(t *testing.T) {
+	reader := metric.NewManualReader()
+	mp := metric.NewMeterProvider(metric.WithReader(reader))
+	assert.Nil(t, startRuntimeMetrics(mp, time.Second), "must be nil")
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm), "must be nil")
+	names := metricNames(rm)
+	// the names depend on $OTEL_GO_X_DEPRECATED_RUNTIME_METRICS
+	assert.True(t, names["process.runtime.go.goroutines"] || names["go.goroutine.count"], "runtime metrics must be collected")
+	assert.True(t, names["process.cpu.time"], "host metrics must be collected")
+
+	// callbacks are not called after shutdown
+	assert.Nil(t, mp.Shutdown(context.Background()), "must be nil")
+	assert.NotNil(t, reader.Collect(context.Background(), &rm), "should be an error")
+}
+
+func TestTelemetry(t *testing.T) {
+	logger.InitServLogger(false)
+
+	op := NewOtelPlugin("otel", "otel")
+	telemetry, ok := op.Get().(Telemetry)
+	assert.True(t, ok, "plugin must implement Telemetry")
+	// the global providers are used before Run
+	assert.NotNil(t, telemetry.Tracer("orders"), "should not be nil")
+	assert.NotNil(t, telemetry.Meter("orders"), "should not be nil")
+
+	reader := metric.NewManualReader()
+	op.meterProvider = metric.NewMeterProvider(metric.WithReader(reader))
+	counter, err := telemetry.Meter("orders").Int64Counter("orders.created")
+	assert.Nil(t, err, "must be nil")
+	counter.Add(context.Background(), 1)
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm), "must be nil")
+	assert.True(t, metricNames(rm)["orders.created"], "should be recorded by the plugin provider")
+
+	op = NewOtelPlugin("otel", "otel")
+	op.runtimeMetricsInterval = 0
+	assert.NotNil(t, op.Configure(), "should be an error")
+}
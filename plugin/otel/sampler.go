@@ -0,0 +1,59 @@
+package otel
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+// parseSamplerArg parses the ratio of the ratio based samplers,
+// empty means always sample
+func parseSamplerArg(arg string) (float64, error) {
+	if arg == "" {
+		return 1, nil
+	}
+
+	ratio, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid traces sampler arg %q: %w", arg, err)
+	}
+
+	if ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("invalid traces sampler arg %v, must be within [0,1]", ratio)
+	}
+
+	return ratio, nil
+}
+
+// newSampler maps the OTEL_TRACES_SAMPLER names to samplers.
+// Parent based samplers respect the decision of the upstream service.
+func newSampler(name string, ratio float64) (trace.Sampler, error) {
+	switch name {
+	case SamplerAlwaysOn:
+		return trace.AlwaysSample(), nil
+	case SamplerAlwaysOff:
+		return trace.NeverSample(), nil
+	case SamplerTraceIDRatio:
+		return trace.TraceIDRatioBased(ratio), nil
+	case SamplerParentBasedAlwaysOn:
+		return trace.ParentBased(trace.AlwaysSample()), nil
+	case SamplerParentBasedAlwaysOff:
+		return trace.ParentBased(trace.NeverSample()), nil
+	case SamplerParentBasedTraceIDRatio:
+		return trace.ParentBased(trace.TraceIDRatioBased(ratio)), nil
+	default:
+		return nil, fmt.Errorf("unknown traces sampler %q, must be one of %s, %s, %s, %s, %s, %s", name,
+			SamplerAlwaysOn, SamplerAlwaysOff, SamplerTraceIDRatio,
+			SamplerParentBasedAlwaysOn, SamplerParentBasedAlwaysOff, SamplerParentBasedTraceIDRatio)
+	}
+}
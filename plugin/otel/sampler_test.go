@@ -0,0 +1,67 @@
+package otel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNewSampler(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		ratio  float64
+		expect string
+	}{
+		{name: SamplerAlwaysOn, expect: "AlwaysOnSampler"},
+		{name: SamplerAlwaysOff, expect: "AlwaysOffSampler"},
+		{name: SamplerTraceIDRatio, ratio: 0.25, expect: "TraceIDRatioBased{0.25}"},
+		{name: SamplerParentBasedAlwaysOn, expect: trace.ParentBased(trace.AlwaysSample()).Description()},
+		{name: SamplerParentBasedTraceIDRatio, ratio: 0.1, expect: trace.ParentBased(trace.TraceIDRatioBased(0.1)).Description()},
+	} {
+		sampler, err := newSampler(c.name, c.ratio)
+		assert.Nil(t, err, "must be nil")
+		assert.Equal(t, c.expect, sampler.Description(), "should be equal")
+	}
+
+	_, err := newSampler("sometimes", 1)
+	assert.NotNil(t, err, "should be an error")
+}
+
+func TestSamplerArg(t *testing.T) {
+	logger.InitServLogger(false)
+
+	op := NewOtelPlugin("otel", "otel")
+	op.tracesSampler = SamplerParentBasedTraceIDRatio
+	op.tracesSamplerArg = "0.5"
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, trace.ParentBased(trace.TraceIDRatioBased(0.5)).Description(), op.sampler.Description(), "should be equal")
+
+	for _, arg := range []string{"1.5", "-0.1", "half"} {
+		op = NewOtelPlugin("otel", "otel")
+		op.tracesSampler = SamplerTraceIDRatio
+		op.tracesSamplerArg = arg
+		assert.NotNil(t, op.Configure(), "should be an error")
+	}
+}
+
+func TestSamplerEnvFallback(t *testing.T) {
+	logger.InitServLogger(false)
+	t.Setenv(envTracesSampler, SamplerAlwaysOff)
+
+	op := NewOtelPlugin("otel", "otel")
+	assert.Nil(t, op.Configure(), "must be nil")
+	assert.Equal(t, "AlwaysOffSampler", op.sampler.Description(), "should be equal")
+}
+
+func TestRunUnknownSampler(t *testing.T) {
+	logger.InitServLogger(false)
+
+	op := NewOtelPlugin("otel", "otel")
+	op.tracesSampler = "sometimes"
+	err := op.Run()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), `unknown traces sampler "sometimes"`)
+	<-op.Stop()
+}
@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileBackend reads secrets from a YAML or JSON file keyed by path,
+// so the vault:// flag values of production work in local development:
+//
+//	secret/data/myapp:
+//	  db_password: dev
+type fileBackend struct {
+	secrets map[string]map[string]interface{}
+}
+
+func newFileBackend(path string) (*fileBackend, error) {
+	if path == "" {
+		return nil, errors.New("secrets file is required by the file backend")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read secrets file: %w", err)
+	}
+
+	fb := &fileBackend{secrets: map[string]map[string]interface{}{}}
+	if err := yaml.Unmarshal(data, &fb.secrets); err != nil {
+		return nil, fmt.Errorf("invalid secrets file %s: %w", path, err)
+	}
+
+	// paths are matched without the surrounding slashes
+	for p, fields := range fb.secrets {
+		if trimmed := strings.Trim(p, "/"); trimmed != p {
+			delete(fb.secrets, p)
+			fb.secrets[trimmed] = fields
+		}
+	}
+
+	return fb, nil
+}
+
+func (fb *fileBackend) read(_ context.Context, path string) (*secret, error) {
+	fields, ok := fb.secrets[path]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", path)
+	}
+	return &secret{data: fields}, nil
+}
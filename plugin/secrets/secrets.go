@@ -0,0 +1,410 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+const (
+	BackendVault = "vault"
+	BackendFile  = "file"
+
+	AuthToken      = "token"
+	AuthKubernetes = "kubernetes"
+	AuthAppRole    = "approle"
+
+	// flag values with this scheme are resolved in service Init
+	refScheme = "vault://"
+)
+
+var (
+	defaultMountPath           = "secret"
+	defaultTimeout             = 10 * time.Second
+	defaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// failed renewals are retried after it
+	renewRetryWait = 10 * time.Second
+)
+
+// Secrets is returned by Get:
+//
+//	secrets := service.MustGet("secrets").(secrets.Secrets)
+type Secrets interface {
+	// Get returns a field of a secret, key is <path>#<field>. path is in the KV v2
+	// mount path unless key is a full reference like vault://secret/data/myapp#db_password
+	Get(key string) (string, error)
+}
+
+// secret is read from a backend, leased secrets are renewed until Stop
+type secret struct {
+	data          map[string]interface{}
+	leaseID       string
+	leaseDuration time.Duration
+	renewable     bool
+}
+
+type backend interface {
+	read(ctx context.Context, path string) (*secret, error)
+}
+
+// lease is renewed at half of its duration
+type lease struct {
+	name     string
+	duration time.Duration
+	renew    func(ctx context.Context) (time.Duration, error)
+}
+
+type secretsPlugin struct {
+	name   string
+	prefix string
+	logger logger.Logger
+
+	backendName         string
+	address             string
+	authMethod          string
+	authMount           string
+	token               string
+	role                string
+	roleID              string
+	secretID            string
+	kubernetesTokenFile string
+	mountPath           string
+	file                string
+	timeout             time.Duration
+
+	mu         sync.Mutex
+	configured bool
+	store      backend
+	// secrets read to resolve flag values, a path is read once
+	resolved map[string]*secret
+	leases   map[string]*lease
+	running  bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func New(name, prefix string) *secretsPlugin {
+	return &secretsPlugin{
+		name:     name,
+		prefix:   prefix,
+		resolved: map[string]*secret{},
+		leases:   map[string]*lease{},
+	}
+}
+
+func (sp *secretsPlugin) Name() string {
+	return sp.name
+}
+
+func (sp *secretsPlugin) GetPrefix() string {
+	return sp.prefix
+}
+
+func (sp *secretsPlugin) Get() interface{} {
+	return Secrets(secretsClient{sp})
+}
+
+func (sp *secretsPlugin) InitFlags() {
+	sp.InitFlagSet(flag.CommandLine)
+}
+
+func (sp *secretsPlugin) InitFlagSet(fs *flag.FlagSet) {
+	prefix := sp.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&sp.backendName, prefix+"backend", BackendVault, "Secrets backend: vault | file (local development)")
+	fs.StringVar(&sp.address, prefix+"address", os.Getenv("VAULT_ADDR"), "Vault address, ex: https://vault:8200, empty => disabled, default is VAULT_ADDR")
+	fs.StringVar(&sp.authMethod, prefix+"auth-method", AuthToken, "Vault auth method: token | kubernetes | approle")
+	fs.StringVar(&sp.authMount, prefix+"auth-mount", "", "mount path of the auth method, default is the method name")
+	fs.StringVar(&sp.token, prefix+"token", os.Getenv("VAULT_TOKEN"), "Vault token of the token auth method, default is VAULT_TOKEN")
+	fs.StringVar(&sp.role, prefix+"role", "", "Vault role of the kubernetes auth method")
+	fs.StringVar(&sp.roleID, prefix+"role-id", "", "role id of the approle auth method")
+	fs.StringVar(&sp.secretID, prefix+"secret-id", "", "secret id of the approle auth method")
+	fs.StringVar(&sp.kubernetesTokenFile, prefix+"kubernetes-token-file", defaultKubernetesTokenFile, "service account token of the kubernetes auth method")
+	fs.StringVar(&sp.mountPath, prefix+"mount-path", defaultMountPath, "mount path of the KV v2 engine of Get")
+	fs.StringVar(&sp.file, prefix+"file", "", "YAML or JSON file of the file backend, ex: secret/data/myapp: {db_password: dev}")
+	fs.DurationVar(&sp.timeout, prefix+"timeout", defaultTimeout, "timeout of backend requests")
+}
+
+func (sp *secretsPlugin) isDisabled() bool {
+	return sp.backendName == BackendVault && sp.address == ""
+}
+
+// Configure connects the backend once, it's called early by ResolveFlagValue
+func (sp *secretsPlugin) Configure() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	return sp.configure()
+}
+
+// configure must be called with mu held
+func (sp *secretsPlugin) configure() error {
+	if sp.configured {
+		return nil
+	}
+
+	sp.logger = logger.GetCurrent().GetLogger(sp.name)
+
+	switch sp.backendName {
+	case BackendVault:
+		ctx, cancel := context.WithTimeout(context.Background(), sp.timeout)
+		defer cancel()
+
+		vault, tokenLease, err := newVaultBackend(ctx, vaultConfig{
+			address:             sp.address,
+			authMethod:          sp.authMethod,
+			authMount:           sp.authMount,
+			token:               sp.token,
+			role:                sp.role,
+			roleID:              sp.roleID,
+			secretID:            sp.secretID,
+			kubernetesTokenFile: sp.kubernetesTokenFile,
+			timeout:             sp.timeout,
+		})
+		if err != nil {
+			return err
+		}
+		sp.store = vault
+		if tokenLease != nil {
+			sp.addLease(tokenLease)
+		}
+	case BackendFile:
+		file, err := newFileBackend(sp.file)
+		if err != nil {
+			return err
+		}
+		sp.store = file
+	default:
+		return fmt.Errorf("unknown secrets backend %q, must be %s or %s", sp.backendName, BackendVault, BackendFile)
+	}
+
+	sp.configured = true
+	return nil
+}
+
+// ResolveFlagValue reads the secret of a vault://<path>#<field> flag value,
+// secrets are read in service Init before the other components are configured
+func (sp *secretsPlugin) ResolveFlagValue(value string) (string, bool, error) {
+	if !strings.HasPrefix(value, refScheme) {
+		return "", false, nil
+	}
+
+	if sp.isDisabled() {
+		return "", true, errors.New("secrets plugin is disabled, the Vault address is not set")
+	}
+
+	path, field, err := parseKey(strings.TrimPrefix(value, refScheme))
+	if err != nil {
+		return "", true, err
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if err := sp.configure(); err != nil {
+		return "", true, err
+	}
+
+	s, ok := sp.resolved[path]
+	if !ok {
+		if s, err = sp.read(path); err != nil {
+			return "", true, err
+		}
+		sp.resolved[path] = s
+	}
+
+	resolved, err := fieldValue(s, path, field)
+	return resolved, true, err
+}
+
+// secretsClient is the Secrets of the plugin, whose Get returns the component
+type secretsClient struct {
+	sp *secretsPlugin
+}
+
+func (c secretsClient) Get(key string) (string, error) {
+	return c.sp.get(key)
+}
+
+func (sp *secretsPlugin) get(key string) (string, error) {
+	if sp.isDisabled() {
+		return "", errors.New("secrets plugin is disabled, the Vault address is not set")
+	}
+
+	var path, field string
+	var err error
+	if strings.HasPrefix(key, refScheme) {
+		path, field, err = parseKey(strings.TrimPrefix(key, refScheme))
+	} else {
+		path, field, err = parseKey(key)
+		path = strings.Trim(sp.mountPath, "/") + "/data/" + path
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if err := sp.configure(); err != nil {
+		return "", err
+	}
+
+	s, err := sp.read(path)
+	if err != nil {
+		return "", err
+	}
+	return fieldValue(s, path, field)
+}
+
+// read must be called with mu held, leased secrets are renewed
+func (sp *secretsPlugin) read(path string) (*secret, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sp.timeout)
+	defer cancel()
+
+	s, err := sp.store.read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if vault, ok := sp.store.(*vaultBackend); ok && s.renewable && s.leaseID != "" {
+		leaseID := s.leaseID
+		sp.addLease(&lease{
+			name:     "lease of " + path,
+			duration: s.leaseDuration,
+			renew: func(ctx context.Context) (time.Duration, error) {
+				return vault.renewLease(ctx, leaseID, s.leaseDuration)
+			},
+		})
+	}
+
+	return s, nil
+}
+
+// addLease must be called with mu held, leases are renewed once running
+func (sp *secretsPlugin) addLease(l *lease) {
+	if _, ok := sp.leases[l.name]; ok {
+		return
+	}
+	sp.leases[l.name] = l
+
+	if sp.running {
+		sp.wg.Add(1)
+		go sp.keepRenewed(l)
+	}
+}
+
+func (sp *secretsPlugin) keepRenewed(l *lease) {
+	defer sp.wg.Done()
+
+	wait := l.duration / 2
+	for {
+		select {
+		case <-time.After(wait):
+		case <-sp.ctx.Done():
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(sp.ctx, sp.timeout)
+		duration, err := l.renew(ctx)
+		cancel()
+
+		if err != nil {
+			if sp.ctx.Err() != nil {
+				return
+			}
+			sp.logger.Warnf("cannot renew %s: %s", l.name, err.Error())
+			wait = renewRetryWait
+			continue
+		}
+
+		// the lease reached its max TTL
+		if duration <= 0 {
+			sp.logger.Warnf("%s is not renewable anymore", l.name)
+			return
+		}
+		wait = duration / 2
+	}
+}
+
+// Run renews the leases of the secrets and of the Vault token
+func (sp *secretsPlugin) Run() error {
+	if sp.isDisabled() {
+		return nil
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if err := sp.configure(); err != nil {
+		return err
+	}
+	if sp.running {
+		return nil
+	}
+
+	sp.ctx, sp.cancel = context.WithCancel(context.Background())
+	sp.running = true
+	for _, l := range sp.leases {
+		sp.wg.Add(1)
+		go sp.keepRenewed(l)
+	}
+
+	return nil
+}
+
+func (sp *secretsPlugin) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		sp.mu.Lock()
+		if sp.running {
+			sp.running = false
+			sp.cancel()
+		}
+		sp.mu.Unlock()
+
+		sp.wg.Wait()
+		c <- true
+	}()
+	return c
+}
+
+// parseKey splits <path>#<field>
+func parseKey(key string) (string, string, error) {
+	idx := strings.LastIndex(key, "#")
+	if idx <= 0 || idx == len(key)-1 {
+		return "", "", errors.New("invalid secret reference, must be <path>#<field>")
+	}
+	return strings.Trim(key[:idx], "/"), key[idx+1:], nil
+}
+
+// fieldValue returns a field of the data, the fields of KV v2 secrets are nested in data
+func fieldValue(s *secret, path, field string) (string, error) {
+	data := s.data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+
+	value, ok := data[field]
+	if !ok || value == nil {
+		return "", fmt.Errorf("secret %s has no field %s", path, field)
+	}
+
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// fakeVault serves a KV v2 secret, a leased database secret and the approle login
+type fakeVault struct {
+	renewals atomic.Int32
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/auth/approle/login" {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "app" || body["secret_id"] != "s3cret" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":["invalid role or secret ID"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"auth":{"client_token":"approle-token","lease_duration":0,"renewable":false}}`))
+		return
+	}
+
+	token := r.Header.Get("X-Vault-Token")
+	if token != "root" && token != "approle-token" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/auth/token/lookup-self":
+		_, _ = w.Write([]byte(`{"data":{"ttl":0,"renewable":false}}`))
+	case "/v1/secret/data/myapp":
+		_, _ = w.Write([]byte(`{"data":{"data":{"db_password":"p@ss","port":5432},"metadata":{"version":3}}}`))
+	case "/v1/database/creds/app":
+		_, _ = w.Write([]byte(`{"lease_id":"database/creds/app/1","lease_duration":1,"renewable":true,"data":{"username":"v-app","password":"generated"}}`))
+	case "/v1/sys/leases/renew":
+		f.renewals.Add(1)
+		_, _ = w.Write([]byte(`{"lease_id":"database/creds/app/1","lease_duration":1,"renewable":true}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors":[]}`))
+	}
+}
+
+func newPlugin(address string) *secretsPlugin {
+	logger.InitServLogger(false)
+
+	sp := New("secrets", "secrets")
+	sp.backendName = BackendVault
+	sp.address = address
+	sp.authMethod = AuthToken
+	sp.token = "root"
+	sp.mountPath = defaultMountPath
+	sp.timeout = time.Second
+	return sp
+}
+
+func TestResolveFlagValue(t *testing.T) {
+	srv := httptest.NewServer(&fakeVault{})
+	defer srv.Close()
+
+	sp := newPlugin(srv.URL)
+
+	value, ok, err := sp.ResolveFlagValue("postgres://localhost:5432/app")
+	assert.Nil(t, err, "must be nil")
+	assert.False(t, ok, "plain values should not be resolved")
+	assert.Equal(t, "", value, "should be equal")
+
+	value, ok, err = sp.ResolveFlagValue("vault://secret/data/myapp#db_password")
+	assert.Nil(t, err, "must be nil")
+	assert.True(t, ok, "should be resolved")
+	assert.Equal(t, "p@ss", value, "should be equal")
+
+	value, _, err = sp.ResolveFlagValue("vault://secret/data/myapp#port")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "5432", value, "should be equal")
+
+	for _, ref := range []string{
+		"vault://secret/data/myapp#missing",
+		"vault://secret/data/other#db_password",
+		"vault://secret/data/myapp",
+	} {
+		_, ok, err := sp.ResolveFlagValue(ref)
+		assert.True(t, ok, "should be handled: %s", ref)
+		assert.NotNil(t, err, "should be an error: %s", ref)
+	}
+
+	value, err = sp.Get().(Secrets).Get("myapp#db_password")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "p@ss", value, "should be equal")
+}
+
+func TestAuthErrors(t *testing.T) {
+	srv := httptest.NewServer(&fakeVault{})
+	defer srv.Close()
+
+	sp := newPlugin(srv.URL)
+	sp.token = "wrong"
+	_, _, err := sp.ResolveFlagValue("vault://secret/data/myapp#db_password")
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "permission denied", "should contain the Vault error")
+
+	sp = newPlugin(srv.URL)
+	sp.authMethod = AuthAppRole
+	sp.roleID, sp.secretID = "app", "s3cret"
+	value, _, err := sp.ResolveFlagValue("vault://secret/data/myapp#db_password")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "p@ss", value, "should be equal")
+
+	disabled := newPlugin("")
+	_, ok, err := disabled.ResolveFlagValue("vault://secret/data/myapp#db_password")
+	assert.True(t, ok, "should be handled")
+	assert.NotNil(t, err, "should be an error when disabled")
+}
+
+func TestLeaseRenewal(t *testing.T) {
+	vault := &fakeVault{}
+	srv := httptest.NewServer(vault)
+	defer srv.Close()
+
+	sp := newPlugin(srv.URL)
+	value, _, err := sp.ResolveFlagValue("vault://database/creds/app#password")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "generated", value, "should be equal")
+
+	assert.Nil(t, sp.Run(), "must be nil")
+	assert.Eventually(t, func() bool { return vault.renewals.Load() >= 2 }, 3*time.Second, 50*time.Millisecond, "lease should be renewed")
+	assert.True(t, <-sp.Stop(), "should be stopped")
+}
+
+func TestFileBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("/secret/data/myapp/:\n  db_password: dev\n"), 0600), "must be nil")
+
+	sp := newPlugin("")
+	sp.backendName = BackendFile
+	sp.file = path
+
+	value, ok, err := sp.ResolveFlagValue("vault://secret/data/myapp#db_password")
+	assert.Nil(t, err, "must be nil")
+	assert.True(t, ok, "should be resolved")
+	assert.Equal(t, "dev", value, "should be equal")
+
+	value, err = sp.Get().(Secrets).Get("myapp#db_password")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "dev", value, "should be equal")
+
+	_, _, err = sp.ResolveFlagValue("vault://secret/data/other#db_password")
+	assert.NotNil(t, err, "should be an error")
+
+	sp = newPlugin("")
+	sp.backendName = BackendFile
+	_, _, err = sp.ResolveFlagValue("vault://secret/data/myapp#db_password")
+	assert.NotNil(t, err, "file is required")
+}
@@ -0,0 +1,231 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type vaultConfig struct {
+	address             string
+	authMethod          string
+	authMount           string
+	token               string
+	role                string
+	roleID              string
+	secretID            string
+	kubernetesTokenFile string
+	timeout             time.Duration
+}
+
+// vaultBackend reads secrets with the HTTP API of Vault
+type vaultBackend struct {
+	vaultConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *vaultAuth             `json:"auth"`
+}
+
+// newVaultBackend logs in, the returned lease renews the token when it expires
+func newVaultBackend(ctx context.Context, cfg vaultConfig) (*vaultBackend, *lease, error) {
+	vb := &vaultBackend{
+		vaultConfig: cfg,
+		client:      &http.Client{Timeout: cfg.timeout},
+	}
+	vb.address = strings.TrimSuffix(vb.address, "/")
+
+	if vb.authMount == "" {
+		vb.authMount = vb.authMethod
+	}
+
+	var duration time.Duration
+	var renewable bool
+
+	switch vb.authMethod {
+	case AuthToken:
+		if cfg.token == "" {
+			return nil, nil, fmt.Errorf("Vault token is required by the %s auth method", AuthToken)
+		}
+		// the token of the config is shadowed by the current token
+		vb.token = cfg.token
+
+		var resp vaultResponse
+		if err := vb.do(ctx, http.MethodGet, "auth/token/lookup-self", nil, &resp); err != nil {
+			return nil, nil, err
+		}
+		ttl, _ := resp.Data["ttl"].(float64)
+		renewable, _ = resp.Data["renewable"].(bool)
+		duration = time.Duration(ttl) * time.Second
+	case AuthKubernetes, AuthAppRole:
+		auth, err := vb.login(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		duration, renewable = time.Duration(auth.LeaseDuration)*time.Second, auth.Renewable
+	default:
+		return nil, nil, fmt.Errorf("unknown Vault auth method %q, must be %s, %s or %s", vb.authMethod, AuthToken, AuthKubernetes, AuthAppRole)
+	}
+
+	// root tokens never expire
+	if duration <= 0 {
+		return vb, nil, nil
+	}
+
+	return vb, &lease{name: "Vault token", duration: duration, renew: func(ctx context.Context) (time.Duration, error) {
+		if renewable {
+			if d, err := vb.renewToken(ctx); err == nil || vb.authMethod == AuthToken {
+				return d, err
+			}
+		}
+		if vb.authMethod == AuthToken {
+			return 0, nil
+		}
+
+		// a new token when the current one can't be renewed
+		auth, err := vb.login(ctx)
+		if err != nil {
+			return 0, err
+		}
+		renewable = auth.Renewable
+		return time.Duration(auth.LeaseDuration) * time.Second, nil
+	}}, nil
+}
+
+func (vb *vaultBackend) login(ctx context.Context) (*vaultAuth, error) {
+	var body map[string]string
+
+	switch vb.authMethod {
+	case AuthKubernetes:
+		jwt, err := os.ReadFile(vb.kubernetesTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read service account token: %w", err)
+		}
+		body = map[string]string{"role": vb.role, "jwt": strings.TrimSpace(string(jwt))}
+	case AuthAppRole:
+		body = map[string]string{"role_id": vb.roleID, "secret_id": vb.secretID}
+	}
+
+	var resp vaultResponse
+	if err := vb.do(ctx, http.MethodPost, "auth/"+strings.Trim(vb.authMount, "/")+"/login", body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("Vault %s login returned no token", vb.authMethod)
+	}
+
+	vb.mu.Lock()
+	vb.token = resp.Auth.ClientToken
+	vb.mu.Unlock()
+
+	return resp.Auth, nil
+}
+
+func (vb *vaultBackend) renewToken(ctx context.Context) (time.Duration, error) {
+	var resp vaultResponse
+	if err := vb.do(ctx, http.MethodPost, "auth/token/renew-self", map[string]string{}, &resp); err != nil {
+		return 0, err
+	}
+	if resp.Auth == nil {
+		return 0, nil
+	}
+	return time.Duration(resp.Auth.LeaseDuration) * time.Second, nil
+}
+
+func (vb *vaultBackend) renewLease(ctx context.Context, leaseID string, increment time.Duration) (time.Duration, error) {
+	body := map[string]interface{}{"lease_id": leaseID, "increment": int(increment / time.Second)}
+
+	var resp vaultResponse
+	if err := vb.do(ctx, http.MethodPut, "sys/leases/renew", body, &resp); err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+func (vb *vaultBackend) read(ctx context.Context, path string) (*secret, error) {
+	var resp vaultResponse
+	if err := vb.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("secret %s not found", path)
+	}
+
+	return &secret{
+		data:          resp.Data,
+		leaseID:       resp.LeaseID,
+		leaseDuration: time.Duration(resp.LeaseDuration) * time.Second,
+		renewable:     resp.Renewable,
+	}, nil
+}
+
+// do sends a request of the HTTP API, the errors of Vault never contain secret values
+func (vb *vaultBackend) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body *bytes.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, vb.address+"/v1/"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	vb.mu.Lock()
+	if vb.token != "" {
+		req.Header.Set("X-Vault-Token", vb.token)
+	}
+	vb.mu.Unlock()
+
+	resp, err := vb.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Vault %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return fmt.Errorf("secret %s not found", path)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&vaultErr); err != nil || len(vaultErr.Errors) == 0 {
+			return fmt.Errorf("Vault %s %s: %s", method, path, resp.Status)
+		}
+		return fmt.Errorf("Vault %s %s: %s", method, path, strings.Join(vaultErr.Errors, ", "))
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,118 @@
+// Package apikeys stores the api keys of middleware.RequiredAPIKey in a table,
+// only the hashes of the keys are stored:
+//
+//	store := apikeys.NewStore(db)
+//	key, err := store.Create(ctx, "billing", ownerID, "user", []string{"orders:read"}, nil)
+//	// key is shown once to its owner
+//	engine.Use(middleware.RequiredAPIKey(store, middleware.WithUsageQueue(queue)))
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"gorm.io/gorm"
+)
+
+const tableName = "api_keys"
+
+// Key is a row of the api keys table
+type Key struct {
+	ID         string     `gorm:"column:id;primaryKey;size:64"`
+	Hash       string     `gorm:"column:hash;size:64;not null;uniqueIndex"`
+	OwnerID    uint32     `gorm:"column:owner_id;not null;index"`
+	Role       string     `gorm:"column:role;size:64"`
+	Scopes     string     `gorm:"column:scopes;size:1024"` // space separated
+	CreatedAt  time.Time  `gorm:"column:created_at;not null"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+}
+
+func (Key) TableName() string {
+	return tableName
+}
+
+func (k *Key) apiKey() *middleware.APIKey {
+	return &middleware.APIKey{
+		ID:        k.ID,
+		Hash:      k.Hash,
+		OwnerID:   k.OwnerID,
+		Role:      k.Role,
+		Scopes:    strings.Fields(k.Scopes),
+		ExpiresAt: k.ExpiresAt,
+		RevokedAt: k.RevokedAt,
+	}
+}
+
+// Migrate creates or updates the api keys table
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Key{})
+}
+
+// Store implements middleware.KeyStore and middleware.KeyUsageRecorder
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Create stores a new key, the returned key is not stored and can't be shown again
+func (s *Store) Create(ctx context.Context, id string, ownerID uint32, role string, scopes []string, expiresAt *time.Time) (string, error) {
+	key, hash, err := middleware.GenerateAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	row := &Key{
+		ID:        id,
+		Hash:      hash,
+		OwnerID:   ownerID,
+		Role:      role,
+		Scopes:    strings.Join(scopes, " "),
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.db.WithContext(ctx).Create(row).Error; err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Revoke rejects the key from now, cached keys are accepted until their cache expires
+func (s *Store) Revoke(ctx context.Context, id string) error {
+	res := s.db.WithContext(ctx).Model(&Key{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return middleware.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (s *Store) FindAPIKey(ctx context.Context, hash string) (*middleware.APIKey, error) {
+	var row Key
+	err := s.db.WithContext(ctx).Where("hash = ?", hash).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, middleware.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.apiKey(), nil
+}
+
+// TouchAPIKey records the last use of the key, older uses don't overwrite newer ones
+func (s *Store) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	return s.db.WithContext(ctx).Model(&Key{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, usedAt.UTC()).
+		Update("last_used_at", usedAt.UTC()).Error
+}
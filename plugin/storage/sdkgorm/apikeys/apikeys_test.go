@@ -0,0 +1,62 @@
+package apikeys
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "keys.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	assert.Nil(t, err, "must be nil")
+
+	sqlDB, err := db.DB()
+	assert.Nil(t, err, "must be nil")
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	assert.Nil(t, Migrate(db), "must be nil")
+	return db
+}
+
+func TestStore(t *testing.T) {
+	db := openDB(t)
+	s := NewStore(db)
+	ctx := context.Background()
+
+	key, err := s.Create(ctx, "billing", 7, "user", []string{"orders:read", "orders:write"}, nil)
+	assert.Nil(t, err, "must be nil")
+
+	var row Key
+	assert.Nil(t, db.Take(&row, "id = ?", "billing").Error, "must be nil")
+	assert.NotContains(t, row.Hash, key, "the key should not be stored")
+
+	found, err := s.FindAPIKey(ctx, middleware.HashAPIKey(key))
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "billing", found.ID, "should be equal")
+	assert.Equal(t, uint32(7), found.UserID(), "should be equal")
+	assert.Equal(t, []string{"orders:read", "orders:write"}, found.Scopes, "should be equal")
+
+	_, err = s.FindAPIKey(ctx, middleware.HashAPIKey("sk_unknown"))
+	assert.ErrorIs(t, err, middleware.ErrAPIKeyNotFound, "should be equal")
+
+	usedAt := time.Now().UTC().Truncate(time.Second)
+	assert.Nil(t, s.TouchAPIKey(ctx, "billing", usedAt), "must be nil")
+	assert.Nil(t, s.TouchAPIKey(ctx, "billing", usedAt.Add(-time.Minute)), "must be nil")
+	assert.Nil(t, db.Take(&row, "id = ?", "billing").Error, "must be nil")
+	assert.True(t, row.LastUsedAt.Equal(usedAt), "older uses should not overwrite newer ones")
+
+	assert.Nil(t, s.Revoke(ctx, "billing"), "must be nil")
+	assert.ErrorIs(t, s.Revoke(ctx, "billing"), middleware.ErrAPIKeyNotFound, "should be equal")
+	found, err = s.FindAPIKey(ctx, middleware.HashAPIKey(key))
+	assert.Nil(t, err, "must be nil")
+	assert.NotNil(t, found.RevokedAt, "should be revoked")
+}
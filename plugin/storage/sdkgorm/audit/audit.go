@@ -0,0 +1,72 @@
+// Package audit stores the entries of middleware.Audit in a table:
+//
+//	engine.Use(middleware.Audit(middleware.AuditConfig{Sink: audit.NewSink(db), Queue: queue}))
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"gorm.io/gorm"
+)
+
+const tableName = "audit_logs"
+
+// Entry is a row of the audit table
+type Entry struct {
+	ID        uint64    `gorm:"column:id;primaryKey;autoIncrement"`
+	Time      time.Time `gorm:"column:time;not null;index"`
+	UserID    uint32    `gorm:"column:user_id;index"`
+	Principal string    `gorm:"column:principal;size:255"`
+	Method    string    `gorm:"column:method;size:16;not null"`
+	Route     string    `gorm:"column:route;size:255;not null"`
+	Path      string    `gorm:"column:path;size:1024;not null"`
+	Params    string    `gorm:"column:params;size:1024"` // json
+	Status    int       `gorm:"column:status;not null"`
+	ClientIP  string    `gorm:"column:client_ip;size:64"`
+	RequestID string    `gorm:"column:request_id;size:64"`
+	Body      string    `gorm:"column:body"` // redacted json
+}
+
+func (Entry) TableName() string {
+	return tableName
+}
+
+// Migrate creates or updates the audit table
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Entry{})
+}
+
+type sink struct {
+	db *gorm.DB
+}
+
+// NewSink writes the audit entries to the audit table of db, it implements middleware.AuditSink
+func NewSink(db *gorm.DB) *sink {
+	return &sink{db: db}
+}
+
+func (s *sink) WriteAudit(ctx context.Context, e *middleware.AuditEntry) error {
+	row := &Entry{
+		Time:      e.Time,
+		UserID:    e.UserID,
+		Principal: e.Principal,
+		Method:    e.Method,
+		Route:     e.Route,
+		Path:      e.Path,
+		Status:    e.Status,
+		ClientIP:  e.ClientIP,
+		RequestID: e.RequestID,
+		Body:      string(e.Body),
+	}
+	if len(e.Params) > 0 {
+		params, err := json.Marshal(e.Params)
+		if err != nil {
+			return err
+		}
+		row.Params = string(params)
+	}
+	return s.db.WithContext(ctx).Create(row).Error
+}
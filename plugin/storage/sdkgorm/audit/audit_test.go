@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestSink(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "audit.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	assert.Nil(t, err, "must be nil")
+	sqlDB, err := db.DB()
+	assert.Nil(t, err, "must be nil")
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	assert.Nil(t, Migrate(db), "must be nil")
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	err = NewSink(db).WriteAudit(context.Background(), &middleware.AuditEntry{
+		Time:      now,
+		UserID:    7,
+		Principal: "user-7",
+		Method:    "DELETE",
+		Route:     "/orders/:id",
+		Path:      "/orders/42",
+		Params:    map[string]string{"id": "42"},
+		Status:    204,
+		ClientIP:  "10.0.0.1",
+		RequestID: "req-1",
+		Body:      json.RawMessage(`{"reason":"duplicate"}`),
+	})
+	assert.Nil(t, err, "must be nil")
+
+	var rows []Entry
+	assert.Nil(t, db.Find(&rows).Error, "must be nil")
+	assert.Len(t, rows, 1, "should be equal")
+	assert.True(t, rows[0].Time.Equal(now), "should be equal")
+	assert.Equal(t, uint32(7), rows[0].UserID, "should be equal")
+	assert.Equal(t, "/orders/:id", rows[0].Route, "should be equal")
+	assert.JSONEq(t, `{"id":"42"}`, rows[0].Params, "should be equal")
+	assert.JSONEq(t, `{"reason":"duplicate"}`, rows[0].Body, "should be equal")
+}
@@ -1,14 +1,23 @@
 package sdkgorm
 
 import (
+	"context"
 	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
+	"os"
 	"strings"
 	"sync"
+	"time"
 
+	goservice "github.com/taimaifika/go-sdk"
 	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/otel"
 	"github.com/taimaifika/go-sdk/plugin/storage/sdkgorm/gormdialects"
+	"github.com/taimaifika/go-sdk/plugin/storage/sdkgorm/migration"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 type GormDBType int
@@ -23,19 +32,35 @@ const (
 
 const retryCount = 10
 
+var (
+	defaultMaxOpenConns = 0 // 0 is unlimited
+	defaultMaxIdleConns = 2
+	defaultLogLevel     = "warn"
+	defaultMigrateLock  = 5 * time.Minute
+)
+
 type GormOpt struct {
-	Uri          string
-	Prefix       string
-	DBType       string
-	PingInterval int // in seconds
+	Uri             string
+	Prefix          string
+	DBType          string
+	PingInterval    int // in seconds
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	LogLevel        string
+	// Migrate is off, up or up-to:N
+	Migrate            string
+	MigrationsDir      string
+	MigrateLockTimeout time.Duration
 }
 
 type gormDB struct {
-	name      string
-	logger    logger.Logger
-	db        *gorm.DB
-	isRunning bool
-	once      *sync.Once
+	name       string
+	logger     logger.Logger
+	db         *gorm.DB
+	isRunning  bool
+	once       *sync.Once
+	migrations fs.FS
 	*GormOpt
 }
 
@@ -59,20 +84,74 @@ func (gdb *gormDB) Name() string {
 }
 
 func (gdb *gormDB) InitFlags() {
+	gdb.InitFlagSet(flag.CommandLine)
+}
+
+func (gdb *gormDB) InitFlagSet(fs *flag.FlagSet) {
 	prefix := gdb.Prefix
 	if gdb.Prefix != "" {
 		prefix += "-"
 	}
 
-	flag.StringVar(&gdb.Uri, prefix+"gorm-db-uri", "", "Gorm database connection-string.")
-	flag.StringVar(&gdb.DBType, prefix+"gorm-db-type", "", "Gorm database type (mysql, postgres, sqlite, mssql)")
-	flag.IntVar(&gdb.PingInterval, prefix+"gorm-db-ping-interval", 5, "Gorm database ping check interval")
+	goservice.SecretFlagVar(fs, &gdb.Uri, prefix+"gorm-db-uri", "", "Gorm database connection-string.")
+	fs.StringVar(&gdb.DBType, prefix+"gorm-db-type", "", "Gorm database type (mysql, postgres, sqlite, mssql)")
+	fs.IntVar(&gdb.PingInterval, prefix+"gorm-db-ping-interval", 5, "Gorm database ping check interval")
+	fs.IntVar(&gdb.MaxOpenConns, prefix+"gorm-db-max-open-conns", defaultMaxOpenConns, "Gorm database max open connections, 0 is unlimited")
+	fs.IntVar(&gdb.MaxIdleConns, prefix+"gorm-db-max-idle-conns", defaultMaxIdleConns, "Gorm database max idle connections")
+	fs.DurationVar(&gdb.ConnMaxLifetime, prefix+"gorm-db-conn-max-lifetime", 0, "Gorm database max lifetime of a connection, 0 is unlimited")
+	fs.StringVar(&gdb.LogLevel, prefix+"gorm-db-log-level", defaultLogLevel, "Gorm log level (silent, error, warn, info)")
+	fs.StringVar(&gdb.Migrate, prefix+"gorm-db-migrate", migration.ModeOff, "Gorm database migrations applied on start (off, up, up-to:N)")
+	fs.StringVar(&gdb.MigrationsDir, prefix+"gorm-db-migrations-dir", "", "Gorm database migrations directory, files of golang-migrate like 1_init.up.sql")
+	fs.DurationVar(&gdb.MigrateLockTimeout, prefix+"gorm-db-migrate-lock-timeout", defaultMigrateLock, "Gorm database max time to wait for the migration lock of another replica")
+}
+
+// SetMigrations sets the migrations applied by gorm-db-migrate instead of gorm-db-migrations-dir,
+// ex: an embed.FS, use fs.Sub for a sub directory. Call it before the service runs.
+func (gdb *gormDB) SetMigrations(fsys fs.FS) {
+	gdb.migrations = fsys
+}
+
+func (gdb *gormDB) migrationsFS() fs.FS {
+	if gdb.migrations != nil {
+		return gdb.migrations
+	}
+	if gdb.MigrationsDir != "" {
+		return os.DirFS(gdb.MigrationsDir)
+	}
+	return nil
+}
+
+// Migrator returns the migration runner of the connected database, for a migrate subcommand
+// of the application. Down migrations are only run by it:
+//
+//	runner, err := db.Migrator()
+//	err = migration.RunCommand(ctx, runner, []string{"down", "1"}, os.Stdout)
+func (gdb *gormDB) Migrator() (*migration.Runner, error) {
+	if gdb.db == nil {
+		return nil, errors.New("gorm database is not connected")
+	}
+
+	fsys := gdb.migrationsFS()
+	if fsys == nil {
+		return nil, errors.New("gorm database has no migrations, set gorm-db-migrations-dir or call SetMigrations")
+	}
+
+	migrations, err := migration.Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return migration.NewRunner(gdb.db, migrations,
+		migration.WithLockTimeout(gdb.MigrateLockTimeout),
+		migration.WithLogger(gdb.logger)), nil
 }
 
+// isDisabled when neither the uri nor the type is set, a type without uri is invalid
 func (gdb *gormDB) isDisabled() bool {
-	return gdb.Uri == ""
+	return gdb.Uri == "" && gdb.DBType == ""
 }
 
+// Configure validates the database type, uri and log level
 func (gdb *gormDB) Configure() error {
 	if gdb.isDisabled() || gdb.isRunning {
 		return nil
@@ -82,37 +161,171 @@ func (gdb *gormDB) Configure() error {
 
 	dbType := getDBType(gdb.DBType)
 	if dbType == GormDBTypeNotSupported {
-		return errors.New("gorm database type is not supported")
+		return fmt.Errorf("gorm database type %q is not supported", gdb.DBType)
 	}
 
-	gdb.logger.Info("Connect to Gorm DB at ", gdb.Uri, " ...")
+	if err := validateURI(dbType, gdb.Uri); err != nil {
+		return fmt.Errorf("invalid gorm-db-uri for %s: %w", strings.ToLower(gdb.DBType), err)
+	}
+
+	if _, err := parseLogLevel(gdb.LogLevel); err != nil {
+		return err
+	}
 
-	var err error
-	gdb.db, err = gdb.getDBConn(dbType)
+	mode, err := migration.ParseMode(gdb.Migrate)
 	if err != nil {
-		gdb.logger.Error("Error connect to gorm database at ", gdb.Uri, ". ", err.Error())
 		return err
 	}
-	gdb.isRunning = true
+	if mode.Up && gdb.migrationsFS() == nil {
+		return errors.New("gorm-db-migrate requires gorm-db-migrations-dir or migrations set by SetMigrations")
+	}
 
 	return nil
 }
 
+// Run connects to the database, pings it and sets the pool settings
 func (gdb *gormDB) Run() error {
-	return gdb.Configure()
+	if gdb.isDisabled() || gdb.isRunning {
+		return nil
+	}
+
+	if err := gdb.Configure(); err != nil {
+		return err
+	}
+
+	gdb.logger.Info("Connect to Gorm DB at ", logger.RedactCredentials(gdb.Uri), " ...")
+
+	db, err := gdb.getDBConn(getDBType(gdb.DBType))
+	if err != nil {
+		gdb.logger.Error("Error connect to gorm database at ", logger.RedactCredentials(gdb.Uri), ". ", err.Error())
+		return err
+	}
+
+	logLevel, _ := parseLogLevel(gdb.LogLevel)
+	db.Logger = db.Logger.LogMode(logLevel)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	sqlDB.SetMaxOpenConns(gdb.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(gdb.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(gdb.ConnMaxLifetime)
+
+	if err := sqlDB.Ping(); err != nil {
+		_ = sqlDB.Close()
+		gdb.logger.Error("Cannot ping gorm database. ", err.Error())
+		return err
+	}
+
+	gdb.db = db
+	if err := gdb.migrate(); err != nil {
+		gdb.db = nil
+		_ = sqlDB.Close()
+		gdb.logger.Error("Cannot migrate gorm database. ", err.Error())
+		return err
+	}
+
+	if otel.IsEnabled() {
+		if err := registerTracing(db, strings.ToLower(gdb.DBType)); err != nil {
+			gdb.db = nil
+			_ = sqlDB.Close()
+			gdb.logger.Error("Cannot register gorm tracing. ", err.Error())
+			return err
+		}
+	}
+
+	gdb.isRunning = true
+
+	return nil
 }
 
-func (gdb *gormDB) Stop() <-chan bool {
-	gdb.isRunning = false
+// migrate applies the up migrations of gorm-db-migrate, replicas wait for each other
+func (gdb *gormDB) migrate() error {
+	mode, _ := migration.ParseMode(gdb.Migrate)
+	if !mode.Up {
+		return nil
+	}
 
+	runner, err := gdb.Migrator()
+	if err != nil {
+		return err
+	}
+	return runner.Up(context.Background(), mode.Target)
+}
+
+// Stop closes the connection pool
+func (gdb *gormDB) Stop() <-chan bool {
 	c := make(chan bool)
 	go func() {
+		if gdb.isRunning {
+			gdb.isRunning = false
+			if sqlDB, err := gdb.db.DB(); err == nil {
+				if err := sqlDB.Close(); err != nil {
+					gdb.logger.Info("cannot close ", gdb.name)
+				}
+			}
+			gdb.logger.Infoln("Stopped")
+		}
 		c <- true
-		gdb.logger.Infoln("Stopped")
 	}()
 	return c
 }
 
+func parseLogLevel(level string) (gormlogger.LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "silent":
+		return gormlogger.Silent, nil
+	case "error":
+		return gormlogger.Error, nil
+	case "warn", "":
+		return gormlogger.Warn, nil
+	case "info":
+		return gormlogger.Info, nil
+	}
+
+	return 0, fmt.Errorf("gorm log level %q is not supported", level)
+}
+
+// HealthCheck pings the database, a disabled plugin is always healthy
+func (gdb *gormDB) HealthCheck(ctx context.Context) error {
+	if gdb.isDisabled() {
+		return nil
+	}
+
+	if gdb.db == nil {
+		return errors.New("gorm database is not connected")
+	}
+
+	sqlDB, err := gdb.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
+// CheckConnectivity opens a connection and pings the database, it's closed after
+func (gdb *gormDB) CheckConnectivity(ctx context.Context) error {
+	if gdb.isDisabled() {
+		return nil
+	}
+
+	db, err := gdb.getDBConn(getDBType(gdb.DBType))
+	if err != nil {
+		return err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sqlDB.Close() }()
+
+	return sqlDB.PingContext(ctx)
+}
+
 func (gdb *gormDB) Get() interface{} {
 	if gdb.logger.GetLevel() == "debug" || gdb.logger.GetLevel() == "trace" {
 		return gdb.db.Session(&gorm.Session{NewDB: true}).Debug()
@@ -135,6 +348,25 @@ func getDBType(dbType string) GormDBType {
 	return GormDBTypeNotSupported
 }
 
+// validateURI parses uri for the driver without connecting
+func validateURI(t GormDBType, uri string) error {
+	if strings.TrimSpace(uri) == "" {
+		return errors.New("uri is empty")
+	}
+
+	switch t {
+	case GormDBTypeMySQL:
+		return gormdialects.ValidateMySqlURI(uri)
+	case GormDBTypePostgres:
+		return gormdialects.ValidatePostgresURI(uri)
+	case GormDBTypeMSSQL:
+		return gormdialects.ValidateMSSqlURI(uri)
+	}
+
+	// sqlite opens a path or a file: uri
+	return nil
+}
+
 func (gdb *gormDB) getDBConn(t GormDBType) (dbConn *gorm.DB, err error) {
 	switch t {
 	case GormDBTypeMySQL:
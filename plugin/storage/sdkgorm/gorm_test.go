@@ -0,0 +1,119 @@
+package sdkgorm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"gorm.io/gorm"
+)
+
+func newTestGormDB(dbType, uri string) *gormDB {
+	logger.InitServLogger(false)
+
+	gdb := NewGormDB("test-db", "")
+	gdb.DBType = dbType
+	gdb.Uri = uri
+	gdb.MaxOpenConns = 5
+	gdb.MaxIdleConns = 1
+	gdb.ConnMaxLifetime = time.Minute
+	gdb.LogLevel = "silent"
+	return gdb
+}
+
+func TestGormConfigure(t *testing.T) {
+	assert.Nil(t, NewGormDB("test-db", "").Configure(), "disabled plugin must be valid")
+
+	err := newTestGormDB("oracle", "dsn").Configure()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), `"oracle" is not supported`)
+
+	gdb := newTestGormDB("sqlite", ":memory:")
+	gdb.LogLevel = "verbose"
+	assert.NotNil(t, gdb.Configure(), "should be an error")
+
+	for dbType, uri := range map[string]string{
+		"sqlite":   " ",
+		"mysql":    "user:pass@db/app",
+		"postgres": "host=db port=notaport",
+		"mssql":    "server=db;pass",
+	} {
+		err = newTestGormDB(dbType, uri).Configure()
+		assert.NotNil(t, err, "should be an error")
+		assert.Contains(t, err.Error(), "invalid gorm-db-uri for "+dbType)
+		assert.NotContains(t, err.Error(), "pass@", "must not contain the password")
+	}
+
+	for dbType, uri := range map[string]string{
+		"mysql":    "user:pass@tcp(db:3306)/app?parseTime=true",
+		"postgres": "postgres://user:pass@db:5432/app?sslmode=disable",
+		"mssql":    "sqlserver://sa:pass@db:1433?database=app",
+	} {
+		assert.Nil(t, newTestGormDB(dbType, uri).Configure(), "must be nil")
+	}
+}
+
+func TestGormRunStop(t *testing.T) {
+	gdb := newTestGormDB("sqlite", ":memory:")
+	assert.Nil(t, gdb.Run(), "must be nil")
+
+	db, ok := gdb.Get().(*gorm.DB)
+	assert.True(t, ok, "should be *gorm.DB")
+
+	sqlDB, err := db.DB()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, 5, sqlDB.Stats().MaxOpenConnections, "should be equal")
+	assert.Nil(t, gdb.HealthCheck(context.Background()), "must be nil")
+
+	<-gdb.Stop()
+	assert.NotNil(t, gdb.HealthCheck(context.Background()), "closed database must be unhealthy")
+}
+
+func TestGormTracing(t *testing.T) {
+	gdb := newTestGormDB("sqlite", ":memory:")
+	assert.Nil(t, gdb.Run(), "must be nil")
+	defer func() { <-gdb.Stop() }()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := trace.NewTracerProvider(trace.WithSpanProcessor(recorder))
+
+	db := gdb.db
+	assert.Nil(t, registerTracingWithProvider(db, "sqlite", tp), "must be nil")
+
+	var n int
+	assert.Nil(t, db.Raw("SELECT 1").Scan(&n).Error, "must be nil")
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1, "should have one span")
+	assert.Equal(t, "gorm.row", spans[0].Name(), "should be equal")
+	assert.Contains(t, spans[0].Attributes(), semconv.DBQueryText("SELECT 1"))
+}
+
+func TestGormMigrate(t *testing.T) {
+	gdb := newTestGormDB("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	gdb.Migrate = "up"
+	assert.NotNil(t, gdb.Configure(), "migrate without migrations should be an error")
+
+	gdb.SetMigrations(fstest.MapFS{
+		"1_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+		"1_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	})
+	assert.Nil(t, gdb.Run(), "must be nil")
+	defer func() { <-gdb.Stop() }()
+
+	assert.True(t, gdb.db.Migrator().HasTable("users"), "should be migrated on run")
+
+	runner, err := gdb.Migrator()
+	assert.Nil(t, err, "must be nil")
+	version, dirty, err := runner.Version(context.Background())
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, uint64(1), version, "should be equal")
+	assert.False(t, dirty, "should be false")
+}
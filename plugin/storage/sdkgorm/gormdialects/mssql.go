@@ -1,6 +1,11 @@
 package gormdialects
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
 	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 )
@@ -11,3 +16,26 @@ import (
 func MSSqlDB(uri string) (db *gorm.DB, err error) {
 	return gorm.Open(sqlserver.Open(uri))
 }
+
+// ValidateMSSqlURI checks that uri is a sqlserver:// url or
+// semicolon separated key=value pairs like server=localhost;user id=sa
+func ValidateMSSqlURI(uri string) error {
+	// the errors don't quote uri, it holds the password
+	if strings.HasPrefix(strings.ToLower(uri), "sqlserver://") {
+		var urlErr *url.Error
+		if _, err := url.Parse(uri); errors.As(err, &urlErr) {
+			return fmt.Errorf("invalid sqlserver url: %w", urlErr.Err)
+		}
+		return nil
+	}
+
+	for i, pair := range strings.Split(uri, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		if key, _, ok := strings.Cut(pair, "="); !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("invalid pair #%d, must be key=value", i+1)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package gormdialects
 
 import (
+	gomysql "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -11,3 +12,9 @@ import (
 func MySqlDB(uri string) (db *gorm.DB, err error) {
 	return gorm.Open(mysql.Open(uri), &gorm.Config{})
 }
+
+// ValidateMySqlURI parses uri like MySqlDB without connecting
+func ValidateMySqlURI(uri string) error {
+	_, err := gomysql.ParseDSN(uri)
+	return err
+}
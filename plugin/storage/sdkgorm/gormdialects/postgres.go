@@ -1,6 +1,7 @@
 package gormdialects
 
 import (
+	"github.com/jackc/pgx/v5"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -11,3 +12,10 @@ import (
 func PostgresDB(uri string) (db *gorm.DB, err error) {
 	return gorm.Open(postgres.Open(uri))
 }
+
+// ValidatePostgresURI parses uri like PostgresDB without connecting,
+// a key-value string or a postgres:// url
+func ValidatePostgresURI(uri string) error {
+	_, err := pgx.ParseConfig(uri)
+	return err
+}
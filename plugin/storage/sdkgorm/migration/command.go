@@ -0,0 +1,74 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// CommandUsage documents the arguments of RunCommand
+const CommandUsage = `migrate commands:
+  up             apply all the migrations
+  up-to N        apply the migrations up to version N
+  down N         revert the last N migrations
+  version        print the current version
+  force N        set the version to N and clear the dirty state, after fixing a failed migration`
+
+// RunCommand runs the arguments of a migrate subcommand of the application, ex: app migrate down 1.
+// It's the only way to run down migrations.
+func RunCommand(ctx context.Context, r *Runner, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", CommandUsage)
+	}
+
+	number := func() (uint64, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("migrate %s requires a number\n%s", args[0], CommandUsage)
+		}
+		n, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q of migrate %s", args[1], args[0])
+		}
+		return n, nil
+	}
+
+	switch args[0] {
+	case "up":
+		return r.Up(ctx, 0)
+	case "up-to":
+		target, err := number()
+		if err != nil {
+			return err
+		}
+		if target == 0 {
+			return fmt.Errorf("invalid version 0 of migrate up-to")
+		}
+		return r.Up(ctx, target)
+	case "down":
+		steps, err := number()
+		if err != nil {
+			return err
+		}
+		return r.Down(ctx, int(steps))
+	case "force":
+		version, err := number()
+		if err != nil {
+			return err
+		}
+		return r.Force(ctx, version)
+	case "version":
+		version, dirty, err := r.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			_, err = fmt.Fprintf(out, "%d (dirty)\n", version)
+		} else {
+			_, err = fmt.Fprintf(out, "%d\n", version)
+		}
+		return err
+	}
+
+	return fmt.Errorf("unknown migrate command %q\n%s", args[0], CommandUsage)
+}
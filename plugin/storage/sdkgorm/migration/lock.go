@@ -0,0 +1,115 @@
+package migration
+
+import (
+	"hash/crc32"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// locker is the advisory lock of a database session, it's released when the session ends
+type locker interface {
+	lock(conn *gorm.DB) error
+	unlock(conn *gorm.DB) error
+}
+
+func newLocker(dialect, table string, timeout time.Duration) locker {
+	name := "migrate:" + table
+
+	switch dialect {
+	case "postgres":
+		return postgresLocker{key: int64(crc32.ChecksumIEEE([]byte(name))), timeout: timeout}
+	case "mysql":
+		return mysqlLocker{name: name, timeout: timeout}
+	case "sqlserver":
+		return mssqlLocker{name: name, timeout: timeout}
+	}
+	// sqlite locks the database file while writing
+	return noLocker{}
+}
+
+type postgresLocker struct {
+	key     int64
+	timeout time.Duration
+}
+
+// lock retries pg_try_advisory_lock, pg_advisory_lock would ignore the timeout
+func (l postgresLocker) lock(conn *gorm.DB) error {
+	deadline := time.Now().Add(l.timeout)
+	for {
+		var acquired bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", l.key).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errLockTimeout
+		}
+
+		select {
+		case <-conn.Statement.Context.Done():
+			return conn.Statement.Context.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (l postgresLocker) unlock(conn *gorm.DB) error {
+	return conn.Exec("SELECT pg_advisory_unlock(?)", l.key).Error
+}
+
+type mysqlLocker struct {
+	name    string
+	timeout time.Duration
+}
+
+// lock takes a named lock of the database, GET_LOCK names are global to the server
+func (l mysqlLocker) lock(conn *gorm.DB) error {
+	var acquired *int
+	if err := conn.Raw("SELECT GET_LOCK(CONCAT(DATABASE(), ':', ?), ?)", l.name, int(l.timeout.Seconds())).
+		Scan(&acquired).Error; err != nil {
+		return err
+	}
+	if acquired == nil || *acquired != 1 {
+		return errLockTimeout
+	}
+	return nil
+}
+
+func (l mysqlLocker) unlock(conn *gorm.DB) error {
+	return conn.Exec("SELECT RELEASE_LOCK(CONCAT(DATABASE(), ':', ?))", l.name).Error
+}
+
+type mssqlLocker struct {
+	name    string
+	timeout time.Duration
+}
+
+func (l mssqlLocker) lock(conn *gorm.DB) error {
+	var result int
+	if err := conn.Raw("DECLARE @result int; EXEC @result = sp_getapplock @Resource = ?, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = ?; SELECT @result",
+		l.name, l.timeout.Milliseconds()).Scan(&result).Error; err != nil {
+		return err
+	}
+	// 0 is granted, 1 is granted after waiting
+	if result < 0 {
+		return errLockTimeout
+	}
+	return nil
+}
+
+func (l mssqlLocker) unlock(conn *gorm.DB) error {
+	return conn.Exec("EXEC sp_releaseapplock @Resource = ?, @LockOwner = 'Session'", l.name).Error
+}
+
+type noLocker struct{}
+
+func (noLocker) lock(*gorm.DB) error {
+	return nil
+}
+
+func (noLocker) unlock(*gorm.DB) error {
+	return nil
+}
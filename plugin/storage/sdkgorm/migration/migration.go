@@ -0,0 +1,370 @@
+// Package migration applies SQL migrations with the files and the schema_migrations table
+// of golang-migrate, so a database migrated by its CLI keeps working:
+//
+//	1_create_users.up.sql
+//	1_create_users.down.sql
+//	2_add_email.up.sql
+//
+// Replicas starting together are serialized by an advisory lock of the database session.
+// A migration which fails leaves the database dirty, it must be fixed by hand and forced.
+// MySQL runs files of several statements only with multiStatements=true in the uri.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTable       = "schema_migrations"
+	defaultLockTimeout = 5 * time.Minute
+
+	ModeOff = "off"
+	ModeUp  = "up"
+	// ModeUpTo is followed by the target version, ex: up-to:12
+	ModeUpTo = "up-to:"
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(.*)\.(up|down)\.sql$`)
+
+// Migration is a version of the schema
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+	hasDown bool
+}
+
+// Load reads the migrations of the root directory of fsys, ex: an embed.FS or os.DirFS
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migrations: %w", err)
+	}
+
+	byVersion := map[uint64]*Migration{}
+	hasUp := map[uint64]bool{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of migration %s: %w", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("cannot read migration %s: %w", e.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("version %d has migrations %s and %s", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up, hasUp[version] = string(data), true
+		} else {
+			mig.Down, mig.hasDown = string(data), true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version, mig := range byVersion {
+		if !hasUp[version] {
+			return nil, fmt.Errorf("migration %d_%s has no up file", version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Mode is the value of the migrate flag of the gorm plugin
+type Mode struct {
+	Up bool
+	// Target is the last version to apply, 0 => all
+	Target uint64
+}
+
+// ParseMode parses off, up or up-to:N
+func ParseMode(s string) (Mode, error) {
+	switch {
+	case s == ModeOff || s == "":
+		return Mode{}, nil
+	case s == ModeUp:
+		return Mode{Up: true}, nil
+	case strings.HasPrefix(s, ModeUpTo):
+		target, err := strconv.ParseUint(strings.TrimPrefix(s, ModeUpTo), 10, 64)
+		if err != nil || target == 0 {
+			return Mode{}, fmt.Errorf("invalid migrate mode %q, the version of up-to must be a positive number", s)
+		}
+		return Mode{Up: true, Target: target}, nil
+	}
+	return Mode{}, fmt.Errorf("invalid migrate mode %q, must be off, up or up-to:<version>", s)
+}
+
+// DirtyError means a migration failed midway, the database needs a manual fix
+type DirtyError struct {
+	Version uint64
+}
+
+func (e DirtyError) Error() string {
+	return fmt.Sprintf("database is dirty at version %d, its migration failed midway: "+
+		"fix the schema by hand, then run the migrate force subcommand with the version "+
+		"the schema is at (%d if the migration is fully applied, else the previous one) and restart", e.Version, e.Version)
+}
+
+type Option func(*Runner)
+
+// WithTable sets the version table, default is schema_migrations
+func WithTable(table string) Option {
+	return func(r *Runner) {
+		r.table = table
+	}
+}
+
+// WithLockTimeout sets how long to wait for the lock held by another replica, default 5m
+func WithLockTimeout(d time.Duration) Option {
+	return func(r *Runner) {
+		r.lockTimeout = d
+	}
+}
+
+// WithLogger sets the logger of the applied migrations
+func WithLogger(l logger.Logger) Option {
+	return func(r *Runner) {
+		r.logger = l
+	}
+}
+
+// Runner applies migrations to a database, every operation holds the lock
+type Runner struct {
+	db          *gorm.DB
+	migrations  []Migration
+	table       string
+	lockTimeout time.Duration
+	logger      logger.Logger
+}
+
+func NewRunner(db *gorm.DB, migrations []Migration, opts ...Option) *Runner {
+	r := &Runner{db: db, migrations: migrations, table: defaultTable, lockTimeout: defaultLockTimeout}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.logger == nil {
+		r.logger = logger.GetCurrent().GetLogger("migration")
+	}
+	return r
+}
+
+// schemaMigration is the single row of the version table of golang-migrate
+type schemaMigration struct {
+	Version int64 `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Dirty   bool  `gorm:"column:dirty;not null"`
+}
+
+// Version returns the current version, 0 when no migration is applied
+func (r *Runner) Version(ctx context.Context) (version uint64, dirty bool, err error) {
+	err = r.withLock(ctx, func(conn *gorm.DB) error {
+		version, dirty, err = r.version(conn)
+		return err
+	})
+	return version, dirty, err
+}
+
+// Up applies the migrations after the current version up to target, 0 => all.
+// A database newer than target is left as it is, down migrations are only run by Down.
+func (r *Runner) Up(ctx context.Context, target uint64) error {
+	return r.withLock(ctx, func(conn *gorm.DB) error {
+		current, dirty, err := r.version(conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return DirtyError{Version: current}
+		}
+		if target > 0 && current > target {
+			r.logger.Warnf("database version %d is newer than %d, down migrations are not run automatically", current, target)
+			return nil
+		}
+
+		applied := 0
+		for _, m := range r.migrations {
+			if m.Version <= current || (target > 0 && m.Version > target) {
+				continue
+			}
+			if err := r.apply(conn, m.Version, m.Name, "up", m.Up, m.Version); err != nil {
+				return err
+			}
+			applied++
+		}
+
+		if applied == 0 {
+			r.logger.Infof("database is up to date at version %d", current)
+		}
+		return nil
+	})
+}
+
+// Down reverts the last steps migrations, it's never called automatically
+func (r *Runner) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("invalid steps %d, must be positive", steps)
+	}
+
+	return r.withLock(ctx, func(conn *gorm.DB) error {
+		current, dirty, err := r.version(conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return DirtyError{Version: current}
+		}
+
+		idx := -1
+		for i, m := range r.migrations {
+			if m.Version == current {
+				idx = i
+			}
+		}
+		if current > 0 && idx < 0 {
+			return fmt.Errorf("database version %d has no migration file", current)
+		}
+
+		for ; steps > 0 && idx >= 0; steps, idx = steps-1, idx-1 {
+			m := r.migrations[idx]
+			if !m.hasDown {
+				return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+			}
+
+			var previous uint64
+			if idx > 0 {
+				previous = r.migrations[idx-1].Version
+			}
+			if err := r.apply(conn, m.Version, m.Name, "down", m.Down, previous); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Force sets the version and clears the dirty state without running migrations
+func (r *Runner) Force(ctx context.Context, version uint64) error {
+	return r.withLock(ctx, func(conn *gorm.DB) error {
+		if err := r.setVersion(conn, version, false); err != nil {
+			return err
+		}
+		r.logger.Infof("database version forced to %d", version)
+		return nil
+	})
+}
+
+// apply runs a migration between the dirty and the clean version marks,
+// like golang-migrate it isn't wrapped in a transaction
+func (r *Runner) apply(conn *gorm.DB, version uint64, name, direction, query string, after uint64) error {
+	if err := r.setVersion(conn, version, true); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if strings.TrimSpace(query) != "" {
+		if err := conn.Exec(query).Error; err != nil {
+			return fmt.Errorf("migration %d_%s %s failed, the database is dirty: %w", version, name, direction, err)
+		}
+	}
+
+	if err := r.setVersion(conn, after, false); err != nil {
+		return err
+	}
+
+	r.logger.Withs(logger.Fields{"version": version, "duration": time.Since(start).String()}).
+		Infof("applied migration %d_%s %s", version, name, direction)
+	return nil
+}
+
+func (r *Runner) version(conn *gorm.DB) (uint64, bool, error) {
+	if err := r.ensureTable(conn); err != nil {
+		return 0, false, err
+	}
+
+	var rows []schemaMigration
+	if err := conn.Table(r.table).Limit(1).Find(&rows).Error; err != nil {
+		return 0, false, err
+	}
+	if len(rows) == 0 {
+		return 0, false, nil
+	}
+	return uint64(rows[0].Version), rows[0].Dirty, nil
+}
+
+// setVersion replaces the row of the version table, 0 and clean means nothing is applied
+func (r *Runner) setVersion(conn *gorm.DB, version uint64, dirty bool) error {
+	if err := r.ensureTable(conn); err != nil {
+		return err
+	}
+
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(r.table).Where("1 = 1").Delete(&schemaMigration{}).Error; err != nil {
+			return err
+		}
+		if version == 0 && !dirty {
+			return nil
+		}
+		return tx.Table(r.table).Create(&schemaMigration{Version: int64(version), Dirty: dirty}).Error
+	})
+}
+
+func (r *Runner) ensureTable(conn *gorm.DB) error {
+	if conn.Migrator().HasTable(r.table) {
+		return nil
+	}
+	return conn.Table(r.table).Migrator().CreateTable(&schemaMigration{})
+}
+
+// withLock runs fn on a single connection holding the advisory lock
+func (r *Runner) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		// a new statement for each query, still on the connection
+		conn = conn.Session(&gorm.Session{NewDB: true})
+		l := newLocker(conn.Dialector.Name(), r.table, r.lockTimeout)
+
+		start := time.Now()
+		if err := l.lock(conn); err != nil {
+			return err
+		}
+		if waited := time.Since(start); waited > time.Second {
+			r.logger.Infof("waited %s for the migration lock", waited)
+		}
+
+		err := fn(conn)
+		// the session lock is released anyway when the connection closes
+		if unlockErr := l.unlock(conn.WithContext(context.Background())); unlockErr != nil {
+			r.logger.Warnf("cannot release the migration lock: %s", unlockErr.Error())
+		}
+		return err
+	})
+}
+
+var errLockTimeout = errors.New("timeout waiting for the migration lock, another replica may be migrating")
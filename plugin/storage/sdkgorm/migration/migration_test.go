@@ -0,0 +1,139 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+var testMigrations = fstest.MapFS{
+	"1_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")},
+	"1_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"2_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+	"2_add_email.down.sql":    {Data: []byte("ALTER TABLE users DROP COLUMN email;")},
+	"3_create_orders.up.sql":  {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY); CREATE INDEX idx_orders ON orders (id);")},
+	"README.md":               {Data: []byte("not a migration")},
+}
+
+func newTestRunner(t *testing.T, fsys fstest.MapFS) (*Runner, *gorm.DB) {
+	logger.InitServLogger(false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	assert.Nil(t, err, "must be nil")
+
+	migrations, err := Load(fsys)
+	assert.Nil(t, err, "must be nil")
+
+	return NewRunner(db, migrations), db
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testMigrations)
+	assert.Nil(t, err, "must be nil")
+	assert.Len(t, migrations, 3, "should be equal")
+	assert.Equal(t, uint64(1), migrations[0].Version, "should be equal")
+	assert.Equal(t, "add_email", migrations[1].Name, "should be equal")
+	assert.False(t, migrations[2].hasDown, "should be false")
+
+	_, err = Load(fstest.MapFS{"1_init.down.sql": {Data: []byte("")}})
+	assert.NotNil(t, err, "should be an error")
+
+	_, err = Load(fstest.MapFS{
+		"1_init.up.sql":  {Data: []byte("")},
+		"1_other.up.sql": {Data: []byte("")},
+	})
+	assert.NotNil(t, err, "should be an error")
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("up-to:12")
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, Mode{Up: true, Target: 12}, mode, "should be equal")
+
+	mode, err = ParseMode("off")
+	assert.Nil(t, err, "must be nil")
+	assert.False(t, mode.Up, "should be false")
+
+	for _, invalid := range []string{"down", "up-to:", "up-to:0", "up-to:x"} {
+		_, err = ParseMode(invalid)
+		assert.NotNil(t, err, "should be an error")
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	r, db := newTestRunner(t, testMigrations)
+	ctx := context.Background()
+
+	assert.Nil(t, r.Up(ctx, 2), "must be nil")
+	version, dirty, err := r.Version(ctx)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, uint64(2), version, "should be equal")
+	assert.False(t, dirty, "should be false")
+	assert.False(t, db.Migrator().HasTable("orders"), "should be false")
+
+	assert.Nil(t, r.Up(ctx, 0), "must be nil")
+	assert.True(t, db.Migrator().HasTable("orders"), "should be true")
+	// applied migrations are not run again
+	assert.Nil(t, r.Up(ctx, 0), "must be nil")
+
+	// 3 has no down file
+	assert.NotNil(t, r.Down(ctx, 1), "should be an error")
+
+	assert.Nil(t, r.Force(ctx, 2), "must be nil")
+	assert.Nil(t, r.Down(ctx, 2), "must be nil")
+	version, _, err = r.Version(ctx)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, uint64(0), version, "should be equal")
+	assert.False(t, db.Migrator().HasTable("users"), "should be false")
+}
+
+func TestDirty(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+		"2_broken.up.sql":       {Data: []byte("ALTER TABLE missing ADD COLUMN x TEXT;")},
+	}
+	r, _ := newTestRunner(t, fsys)
+	ctx := context.Background()
+
+	assert.NotNil(t, r.Up(ctx, 0), "should be an error")
+
+	version, dirty, err := r.Version(ctx)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, uint64(2), version, "should be equal")
+	assert.True(t, dirty, "should be true")
+
+	err = r.Up(ctx, 0)
+	assert.Equal(t, DirtyError{Version: 2}, err, "should be equal")
+	assert.Contains(t, err.Error(), "force", "should explain the fix")
+
+	assert.Nil(t, r.Force(ctx, 1), "must be nil")
+	version, dirty, err = r.Version(ctx)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, uint64(1), version, "should be equal")
+	assert.False(t, dirty, "should be false")
+}
+
+func TestRunCommand(t *testing.T) {
+	r, _ := newTestRunner(t, testMigrations)
+	ctx := context.Background()
+	out := &bytes.Buffer{}
+
+	assert.Nil(t, RunCommand(ctx, r, []string{"up-to", "2"}, out), "must be nil")
+	assert.Nil(t, RunCommand(ctx, r, []string{"down", "1"}, out), "must be nil")
+	assert.Nil(t, RunCommand(ctx, r, []string{"version"}, out), "must be nil")
+	assert.Equal(t, "1\n", out.String(), "should be equal")
+
+	assert.NotNil(t, RunCommand(ctx, r, []string{"down"}, out), "should be an error")
+	assert.NotNil(t, RunCommand(ctx, r, []string{"drop"}, out), "should be an error")
+	assert.NotNil(t, RunCommand(ctx, r, nil, out), "should be an error")
+}
@@ -0,0 +1,75 @@
+// Package outbox publishes messages written in the same transaction as the domain rows,
+// so a message is never lost when the publish fails after the commit.
+//
+//	err := outbox.WithinTx(ctx, db, func(tx outbox.Tx) error {
+//		if err := tx.Create(&order).Error; err != nil {
+//			return err
+//		}
+//		return tx.EnqueueJSON("orders.created", order)
+//	})
+//
+// The relay publishes the pending messages in the background and retries them with backoff.
+// Messages are delivered at least once, consumers must be idempotent.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const tableName = "outbox_messages"
+
+// Message is a row of the outbox table
+type Message struct {
+	ID            uint64     `gorm:"column:id;primaryKey;autoIncrement"`
+	Topic         string     `gorm:"column:topic;size:255;not null"`
+	Payload       []byte     `gorm:"column:payload;not null"`
+	CreatedAt     time.Time  `gorm:"column:created_at;not null"`
+	Attempts      int        `gorm:"column:attempts;not null;default:0"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;not null;index:idx_outbox_pending,priority:2"`
+	SentAt        *time.Time `gorm:"column:sent_at;index:idx_outbox_pending,priority:1"`
+	LastError     string     `gorm:"column:last_error;size:1024"`
+	// the relay replica publishing the message, until LockedUntil
+	LockedBy    string     `gorm:"column:locked_by;size:64"`
+	LockedUntil *time.Time `gorm:"column:locked_until"`
+}
+
+func (Message) TableName() string {
+	return tableName
+}
+
+// Migrate creates or updates the outbox table
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Message{})
+}
+
+// Tx is the transaction of WithinTx, the domain rows are written with its DB
+type Tx struct {
+	*gorm.DB
+}
+
+// Enqueue adds a message which is published after the transaction commits
+func (tx Tx) Enqueue(topic string, payload []byte) error {
+	now := time.Now().UTC()
+	return tx.Create(&Message{Topic: topic, Payload: payload, CreatedAt: now, NextAttemptAt: now}).Error
+}
+
+// EnqueueJSON adds a message with the JSON of v
+func (tx Tx) EnqueueJSON(topic string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Enqueue(topic, payload)
+}
+
+// WithinTx runs fn in a transaction, the domain rows and the messages are committed together
+// or rolled back when fn returns an error
+func WithinTx(ctx context.Context, db *gorm.DB, fn func(tx Tx) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(Tx{DB: tx})
+	})
+}
@@ -0,0 +1,192 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type order struct {
+	ID    uint64 `gorm:"primaryKey"`
+	Total int
+}
+
+// fakePublisher fails the first failures publishes of each topic
+type fakePublisher struct {
+	mu        sync.Mutex
+	failures  map[string]int
+	published map[string][]string
+}
+
+func newFakePublisher(failures map[string]int) *fakePublisher {
+	return &fakePublisher{failures: failures, published: map[string][]string{}}
+}
+
+func (p *fakePublisher) Publish(_ context.Context, topic string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failures[topic] > 0 {
+		p.failures[topic]--
+		return errors.New("broker unavailable")
+	}
+	p.published[topic] = append(p.published[topic], string(data))
+	return nil
+}
+
+func (p *fakePublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for _, msgs := range p.published {
+		n += len(msgs)
+	}
+	return n
+}
+
+func openDB(t *testing.T, path string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(path+"?_busy_timeout=5000&_journal_mode=WAL"), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	assert.Nil(t, err, "must be nil")
+
+	sqlDB, err := db.DB()
+	assert.Nil(t, err, "must be nil")
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	return db
+}
+
+func newTestRelay(db *gorm.DB, publisher Publisher) *relay {
+	logger.InitServLogger(false)
+
+	r := NewRelay("outbox", "outbox",
+		func() *gorm.DB { return db },
+		func() Publisher { return publisher })
+	r.pollInterval = 10 * time.Millisecond
+	r.batchSize = 5
+	r.lockTimeout = time.Minute
+	r.publishTimeout = time.Second
+	r.minBackoff = 10 * time.Millisecond
+	r.maxBackoff = 50 * time.Millisecond
+	r.retention = time.Hour
+	return r
+}
+
+func TestWithinTx(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "outbox.db"))
+	assert.Nil(t, Migrate(db), "must be nil")
+	assert.Nil(t, db.AutoMigrate(&order{}), "must be nil")
+
+	err := WithinTx(context.Background(), db, func(tx Tx) error {
+		if err := tx.Create(&order{ID: 1, Total: 10}).Error; err != nil {
+			return err
+		}
+		return tx.EnqueueJSON("orders.created", map[string]int{"id": 1})
+	})
+	assert.Nil(t, err, "must be nil")
+
+	errRollback := errors.New("rollback")
+	err = WithinTx(context.Background(), db, func(tx Tx) error {
+		if err := tx.Create(&order{ID: 2, Total: 20}).Error; err != nil {
+			return err
+		}
+		if err := tx.Enqueue("orders.created", []byte(`{"id":2}`)); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback, "should be an error")
+
+	var orders, messages int64
+	assert.Nil(t, db.Model(&order{}).Count(&orders).Error, "must be nil")
+	assert.Nil(t, db.Model(&Message{}).Count(&messages).Error, "must be nil")
+	assert.Equal(t, int64(1), orders, "should be equal")
+	assert.Equal(t, int64(1), messages, "should be equal")
+}
+
+func TestRelayPublishesAndRetries(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "outbox.db"))
+	assert.Nil(t, Migrate(db), "must be nil")
+
+	for i := 0; i < 12; i++ {
+		topic := "orders.created"
+		if i%3 == 0 {
+			topic = "orders.paid"
+		}
+		err := WithinTx(context.Background(), db, func(tx Tx) error {
+			return tx.Enqueue(topic, []byte(fmt.Sprint(i)))
+		})
+		assert.Nil(t, err, "must be nil")
+	}
+
+	publisher := newFakePublisher(map[string]int{"orders.paid": 2})
+	r := newTestRelay(db, publisher)
+	assert.Nil(t, r.Run(), "must be nil")
+
+	assert.Eventually(t, func() bool { return publisher.count() == 12 }, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, <-r.Stop(), "should be true")
+
+	assert.Equal(t, []string{"1", "2", "4", "5", "7", "8", "10", "11"}, publisher.published["orders.created"], "should be equal")
+	assert.ElementsMatch(t, []string{"0", "3", "6", "9"}, publisher.published["orders.paid"], "should be equal")
+
+	var pending int64
+	assert.Nil(t, db.Model(&Message{}).Where("sent_at IS NULL").Count(&pending).Error, "must be nil")
+	assert.Equal(t, int64(0), pending, "should be equal")
+
+	var retried Message
+	assert.Nil(t, db.Where("topic = ? AND attempts > 0", "orders.paid").First(&retried).Error, "must be nil")
+	assert.Equal(t, "broker unavailable", retried.LastError, "should be equal")
+}
+
+func TestRelayReplicasPublishOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	db := openDB(t, path)
+	assert.Nil(t, Migrate(db), "must be nil")
+
+	for i := 0; i < 50; i++ {
+		err := WithinTx(context.Background(), db, func(tx Tx) error {
+			return tx.Enqueue("events", []byte(fmt.Sprint(i)))
+		})
+		assert.Nil(t, err, "must be nil")
+	}
+
+	publisher := newFakePublisher(nil)
+	replicas := []*relay{newTestRelay(db, publisher), newTestRelay(openDB(t, path), publisher)}
+	for _, r := range replicas {
+		assert.Nil(t, r.Run(), "must be nil")
+	}
+
+	assert.Eventually(t, func() bool { return publisher.count() == 50 }, 5*time.Second, 10*time.Millisecond)
+	for _, r := range replicas {
+		assert.True(t, <-r.Stop(), "should be true")
+	}
+
+	seen := map[string]bool{}
+	for _, payload := range publisher.published["events"] {
+		assert.False(t, seen[payload], "should be published once")
+		seen[payload] = true
+	}
+	assert.Len(t, seen, 50, "should be equal")
+}
+
+func TestBackoff(t *testing.T) {
+	r := &relay{minBackoff: time.Second, maxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, r.backoff(1), "should be equal")
+	assert.Equal(t, 2*time.Second, r.backoff(2), "should be equal")
+	assert.Equal(t, 4*time.Second, r.backoff(3), "should be equal")
+	assert.Equal(t, 5*time.Second, r.backoff(10), "should be equal")
+}
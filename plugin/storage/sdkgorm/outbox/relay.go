@@ -0,0 +1,368 @@
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+)
+
+const (
+	meterName = "github.com/taimaifika/go-sdk/plugin/storage/sdkgorm/outbox"
+
+	// sent messages older than the retention are deleted at most once per cleanupInterval
+	cleanupInterval = time.Minute
+	maxErrorLength  = 1024
+)
+
+// Publisher publishes the messages of the relay, nats.Client implements it
+type Publisher interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+}
+
+// PublisherFunc adapts a function to Publisher, ex: for rabbitmq or gcppubsub
+type PublisherFunc func(ctx context.Context, topic string, data []byte) error
+
+func (f PublisherFunc) Publish(ctx context.Context, topic string, data []byte) error {
+	return f(ctx, topic, data)
+}
+
+type RelayOption func(*relay)
+
+// WithDependsOn starts the relay after the services of the prefixes, ex: the gorm and nats plugins
+func WithDependsOn(prefixes ...string) RelayOption {
+	return func(r *relay) {
+		r.dependsOn = append(r.dependsOn, prefixes...)
+	}
+}
+
+// relay publishes the pending messages of the outbox table. Replicas claim the messages
+// they publish with a lock which expires after the lock timeout, so they can run together.
+type relay struct {
+	name      string
+	prefix    string
+	logger    logger.Logger
+	db        func() *gorm.DB
+	publisher func() Publisher
+	dependsOn []string
+
+	pollInterval   time.Duration
+	batchSize      int
+	lockTimeout    time.Duration
+	publishTimeout time.Duration
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	retention      time.Duration
+
+	owner       string
+	backlog     atomic.Int64
+	latency     metric.Float64Histogram
+	gauge       metric.Registration
+	lastCleanup time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRelay returns the relay Runnable, db and publisher are called when it runs
+// because the plugins providing them are started by the service:
+//
+//	relay := outbox.NewRelay("outbox", "outbox",
+//		func() *gorm.DB { return service.MustGet("db").(*gorm.DB) },
+//		func() outbox.Publisher { return service.MustGet("nats").(nats.Client) },
+//		outbox.WithDependsOn("db", "nats"))
+func NewRelay(name, prefix string, db func() *gorm.DB, publisher func() Publisher, opts ...RelayOption) *relay {
+	r := &relay{name: name, prefix: prefix, db: db, publisher: publisher}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *relay) Name() string {
+	return r.name
+}
+
+func (r *relay) GetPrefix() string {
+	return r.prefix
+}
+
+func (r *relay) Get() interface{} {
+	return r
+}
+
+func (r *relay) DependsOn() []string {
+	return r.dependsOn
+}
+
+func (r *relay) InitFlags() {
+	r.InitFlagSet(flag.CommandLine)
+}
+
+func (r *relay) InitFlagSet(fs *flag.FlagSet) {
+	prefix := r.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.DurationVar(&r.pollInterval, prefix+"poll-interval", time.Second, "interval of polling the pending messages")
+	fs.IntVar(&r.batchSize, prefix+"batch-size", 100, "max messages claimed by a poll")
+	fs.DurationVar(&r.lockTimeout, prefix+"lock-timeout", time.Minute, "claimed messages are released to the other replicas after it")
+	fs.DurationVar(&r.publishTimeout, prefix+"publish-timeout", 10*time.Second, "timeout of publishing a message")
+	fs.DurationVar(&r.minBackoff, prefix+"min-backoff", time.Second, "delay of the first retry of a failed message, doubled on each retry")
+	fs.DurationVar(&r.maxBackoff, prefix+"max-backoff", 5*time.Minute, "max delay between the retries of a failed message")
+	fs.DurationVar(&r.retention, prefix+"retention", 7*24*time.Hour, "sent messages are deleted after it, 0 => keep them")
+}
+
+func (r *relay) Configure() error {
+	r.logger = logger.GetCurrent().GetLogger(r.name)
+
+	if r.db == nil || r.publisher == nil {
+		return errors.New("outbox relay requires a db and a publisher")
+	}
+	if r.batchSize <= 0 {
+		return fmt.Errorf("invalid batch size %d, must be positive", r.batchSize)
+	}
+	if r.pollInterval <= 0 || r.lockTimeout <= 0 {
+		return errors.New("poll interval and lock timeout must be positive")
+	}
+
+	owner, err := newOwner()
+	if err != nil {
+		return err
+	}
+	r.owner = owner
+
+	meter := otel.Meter(meterName)
+	if r.latency, err = meter.Float64Histogram("outbox.publish.duration",
+		metric.WithDescription("Duration of publishing outbox messages"),
+		metric.WithUnit("s")); err != nil {
+		return err
+	}
+
+	backlog, err := meter.Int64ObservableGauge("outbox.backlog",
+		metric.WithDescription("Number of outbox messages which are not sent yet"),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		return err
+	}
+	r.gauge, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(backlog, r.backlog.Load())
+		return nil
+	}, backlog)
+	return err
+}
+
+// newOwner identifies the replica in the locked_by column
+func newOwner() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	host, _ := os.Hostname()
+	if len(host) > 47 {
+		host = host[:47]
+	}
+	return host + "-" + hex.EncodeToString(b), nil
+}
+
+// Run starts polling, it doesn't block
+func (r *relay) Run() error {
+	if err := r.Configure(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.loop(ctx)
+
+	return nil
+}
+
+func (r *relay) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		if r.cancel != nil {
+			r.cancel()
+			<-r.done
+		}
+		if r.gauge != nil {
+			_ = r.gauge.Unregister()
+		}
+		c <- true
+	}()
+	return c
+}
+
+func (r *relay) loop(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.poll(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Warnf("cannot relay outbox messages: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll publishes the pending messages until there is no full batch
+func (r *relay) poll(ctx context.Context) error {
+	db := r.db().WithContext(ctx)
+
+	var backlog int64
+	if err := db.Model(&Message{}).Where("sent_at IS NULL").Count(&backlog).Error; err != nil {
+		return err
+	}
+	r.backlog.Store(backlog)
+
+	for backlog > 0 {
+		n, err := r.relayBatch(ctx, db)
+		if err != nil {
+			return err
+		}
+		if n < r.batchSize {
+			break
+		}
+	}
+
+	return r.cleanup(db)
+}
+
+// relayBatch claims a batch of due messages and publishes them in order
+func (r *relay) relayBatch(ctx context.Context, db *gorm.DB) (int, error) {
+	messages, err := r.claim(db)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+
+	publisher := r.publisher()
+	for i := range messages {
+		if ctx.Err() != nil {
+			// the locks expire and the messages are claimed again
+			return len(messages), ctx.Err()
+		}
+		if err := r.publish(ctx, db, publisher, &messages[i]); err != nil {
+			return len(messages), err
+		}
+	}
+
+	return len(messages), nil
+}
+
+// claim locks due messages which are not locked by another replica.
+// It's an update of the selected ids, which works on every database gorm supports.
+func (r *relay) claim(db *gorm.DB) ([]Message, error) {
+	now := time.Now().UTC()
+
+	var ids []uint64
+	if err := db.Model(&Message{}).
+		Where("sent_at IS NULL AND next_attempt_at <= ?", now).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Order("id").Limit(r.batchSize).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if err := db.Model(&Message{}).
+		Where("id IN ? AND sent_at IS NULL", ids).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Updates(map[string]interface{}{"locked_by": r.owner, "locked_until": now.Add(r.lockTimeout)}).Error; err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	if err := db.Where("id IN ? AND locked_by = ? AND sent_at IS NULL", ids, r.owner).
+		Order("id").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// publish marks the message sent, or schedules its retry when the publisher fails
+func (r *relay) publish(ctx context.Context, db *gorm.DB, publisher Publisher, m *Message) error {
+	pubCtx, cancel := context.WithTimeout(ctx, r.publishTimeout)
+	start := time.Now()
+	pubErr := publisher.Publish(pubCtx, m.Topic, m.Payload)
+	cancel()
+
+	outcome := "ok"
+	if pubErr != nil {
+		outcome = "error"
+	}
+	r.latency.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("messaging.destination.name", m.Topic),
+		attribute.String("outcome", outcome),
+	))
+
+	now := time.Now().UTC()
+	owned := db.Model(&Message{}).Where("id = ? AND locked_by = ?", m.ID, r.owner)
+
+	if pubErr == nil {
+		r.backlog.Add(-1)
+		return owned.Updates(map[string]interface{}{"sent_at": now, "locked_by": "", "locked_until": nil}).Error
+	}
+
+	attempts := m.Attempts + 1
+	lastError := pubErr.Error()
+	if len(lastError) > maxErrorLength {
+		lastError = lastError[:maxErrorLength]
+	}
+
+	r.logger.Withs(logger.Fields{"id": m.ID, "topic": m.Topic, "attempts": attempts}).
+		Warnf("cannot publish outbox message: %s", pubErr.Error())
+
+	return owned.Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"next_attempt_at": now.Add(r.backoff(attempts)),
+		"last_error":      lastError,
+		"locked_by":       "",
+		"locked_until":    nil,
+	}).Error
+}
+
+// backoff is the delay before the next attempt, doubled after each failed attempt
+func (r *relay) backoff(attempts int) time.Duration {
+	d := r.minBackoff
+	for i := 1; i < attempts && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	return d
+}
+
+// cleanup deletes the sent messages older than the retention
+func (r *relay) cleanup(db *gorm.DB) error {
+	if r.retention <= 0 || time.Since(r.lastCleanup) < cleanupInterval {
+		return nil
+	}
+	r.lastCleanup = time.Now()
+
+	return db.Where("sent_at IS NOT NULL AND sent_at < ?", time.Now().UTC().Add(-r.retention)).
+		Delete(&Message{}).Error
+}
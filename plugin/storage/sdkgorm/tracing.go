@@ -0,0 +1,81 @@
+package sdkgorm
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	tracerName       = "github.com/taimaifika/go-sdk/plugin/storage/sdkgorm"
+	spanInstanceKey  = "sdkgorm:span"
+	callbackBeforeID = "sdkgorm:before_"
+	callbackAfterID  = "sdkgorm:after_"
+)
+
+// registerTracing starts a span for every gorm operation,
+// the span is a child of the span in the statement context
+func registerTracing(db *gorm.DB, dbSystem string) error {
+	return registerTracingWithProvider(db, dbSystem, otel.GetTracerProvider())
+}
+
+func registerTracingWithProvider(db *gorm.DB, dbSystem string, tp trace.TracerProvider) error {
+	tracer := tp.Tracer(tracerName)
+
+	before := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			ctx, span := tracer.Start(tx.Statement.Context, "gorm."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(semconv.DBSystemKey.String(dbSystem)))
+			tx.Statement.Context = ctx
+			tx.InstanceSet(spanInstanceKey, span)
+		}
+	}
+
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(spanInstanceKey)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		defer span.End()
+
+		span.SetAttributes(
+			semconv.DBQueryText(tx.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		)
+		if tx.Statement.Table != "" {
+			span.SetAttributes(semconv.DBCollectionName(tx.Statement.Table))
+		}
+
+		if err := tx.Error; err != nil && err != gorm.ErrRecordNotFound {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register(callbackBeforeID+"create", before("create")),
+		cb.Create().After("gorm:create").Register(callbackAfterID+"create", after),
+		cb.Query().Before("gorm:query").Register(callbackBeforeID+"query", before("query")),
+		cb.Query().After("gorm:query").Register(callbackAfterID+"query", after),
+		cb.Update().Before("gorm:update").Register(callbackBeforeID+"update", before("update")),
+		cb.Update().After("gorm:update").Register(callbackAfterID+"update", after),
+		cb.Delete().Before("gorm:delete").Register(callbackBeforeID+"delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register(callbackAfterID+"delete", after),
+		cb.Row().Before("gorm:row").Register(callbackBeforeID+"row", before("row")),
+		cb.Row().After("gorm:row").Register(callbackAfterID+"row", after),
+		cb.Raw().Before("gorm:raw").Register(callbackBeforeID+"raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register(callbackAfterID+"raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package sdkredis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/taimaifika/go-sdk/cache"
+)
+
+const (
+	cacheKeyPrefix = "cache:"
+	// channel of the invalidations of the layered caches
+	cacheInvalidationChannel = "cache:invalidations"
+)
+
+type redisCache struct {
+	name    string
+	client  redis.UniversalClient
+	codec   cache.Codec
+	metrics *cache.Metrics
+}
+
+// NewCache stores the values of the cache name in client, encoded with codec
+// (nil => cache.JSON). It implements cache.Cache.
+func NewCache(name string, client redis.UniversalClient, codec cache.Codec) *redisCache {
+	if codec == nil {
+		codec = cache.JSON
+	}
+	return &redisCache{name: name, client: client, codec: codec, metrics: cache.NewMetrics(name)}
+}
+
+func (c *redisCache) Name() string {
+	return c.name
+}
+
+func (c *redisCache) key(key string) string {
+	return cacheKeyPrefix + c.name + ":" + key
+}
+
+func (c *redisCache) Get(ctx context.Context, key string, dst interface{}) error {
+	cmd := redis.NewStringCmd("get", c.key(key))
+	_ = c.client.ProcessContext(ctx, cmd)
+	data, err := cmd.Bytes()
+	if errors.Is(err, redis.Nil) {
+		err = cache.ErrNotFound
+	}
+	c.metrics.RecordGet(ctx, err)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, dst)
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{"set", c.key(key), data}
+	if ttl > 0 {
+		args = append(args, "px", ttl.Milliseconds())
+	}
+	return c.client.DoContext(ctx, args...).Err()
+}
+
+func (c *redisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := []interface{}{"del"}
+	for _, key := range keys {
+		args = append(args, c.key(key))
+	}
+	return c.client.DoContext(ctx, args...).Err()
+}
+
+// DeletePrefix deletes the keys starting with prefix, scanning all the masters
+// of a cluster
+func (c *redisCache) DeletePrefix(_ context.Context, prefix string) error {
+	match := globEscaper.Replace(c.key(prefix)) + "*"
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(func(node *redis.Client) error {
+			return deleteMatching(node, match)
+		})
+	}
+	return deleteMatching(c.client, match)
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// deleteMatching deletes the keys one by one, the keys of a cluster node
+// are in different slots
+func deleteMatching(client redis.Cmdable, match string) error {
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(cursor, match, 500).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			pipe := client.Pipeline()
+			for _, key := range keys {
+				pipe.Del(key)
+			}
+			if _, err := pipe.Exec(); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+type redisInvalidator struct {
+	client redis.UniversalClient
+}
+
+// NewCacheInvalidator broadcasts the invalidations of the layered caches on
+// redis pub/sub, it implements cache.Invalidator
+func NewCacheInvalidator(client redis.UniversalClient) *redisInvalidator {
+	return &redisInvalidator{client: client}
+}
+
+func (i *redisInvalidator) Publish(ctx context.Context, inv cache.Invalidation) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return i.client.DoContext(ctx, "publish", cacheInvalidationChannel, data).Err()
+}
+
+func (i *redisInvalidator) Subscribe(fn func(cache.Invalidation)) (io.Closer, error) {
+	sub := i.client.Subscribe(cacheInvalidationChannel)
+	// waits for the confirmation so no invalidation published after is missed
+	if _, err := sub.Receive(); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+
+	go func() {
+		for msg := range sub.Channel() {
+			var inv cache.Invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				continue
+			}
+			fn(inv)
+		}
+	}()
+	return sub, nil
+}
@@ -0,0 +1,90 @@
+package sdkredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/cache"
+)
+
+type cachedItem struct {
+	ID   int    `json:"id" msgpack:"id"`
+	Name string `json:"name" msgpack:"name"`
+}
+
+func TestCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	for _, codec := range []cache.Codec{cache.JSON, cache.Msgpack} {
+		c := NewCache("items", client, codec)
+
+		var got cachedItem
+		assert.ErrorIs(t, c.Get(ctx, "1", &got), cache.ErrNotFound, "should be a miss")
+
+		assert.Nil(t, c.Set(ctx, "1", cachedItem{ID: 1, Name: "one"}, time.Minute), "must be nil")
+		assert.Nil(t, c.Get(ctx, "1", &got), "must be nil")
+		assert.Equal(t, cachedItem{ID: 1, Name: "one"}, got, "should be equal")
+		assert.Equal(t, time.Minute, server.TTL(cacheKeyPrefix+"items:1"), "should be equal")
+
+		assert.Nil(t, c.Set(ctx, "2", cachedItem{ID: 2}, 0), "must be nil")
+		assert.Equal(t, time.Duration(0), server.TTL(cacheKeyPrefix+"items:2"), "should not expire")
+
+		assert.Nil(t, c.Delete(ctx, "1", "2"), "must be nil")
+		assert.ErrorIs(t, c.Get(ctx, "2", &got), cache.ErrNotFound, "should be deleted")
+	}
+}
+
+func TestCacheDeletePrefix(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	c := NewCache("responses", client, nil)
+	for _, key := range []string{"/items?page=1", "/items/1?", "/items*?", "/orders?"} {
+		assert.Nil(t, c.Set(ctx, key, "", 0), "must be nil")
+	}
+	assert.Nil(t, server.Set("cache:other:/items?", "x"), "must be nil")
+
+	assert.Nil(t, c.DeletePrefix(ctx, "/items*"), "must be nil")
+	assert.Len(t, server.Keys(), 4, "should only delete the literal prefix")
+
+	assert.Nil(t, c.DeletePrefix(ctx, "/items"), "must be nil")
+	assert.Equal(t, []string{"cache:other:/items?", "cache:responses:/orders?"}, server.Keys(), "should be equal")
+}
+
+func TestCacheInvalidator(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	remote := NewCache("items", client, cache.Msgpack)
+	newReplica := func() (*cache.Layered, *cache.LRU) {
+		local, _ := cache.NewLRU("items.local", cache.LRUConfig{})
+		c, err := cache.NewLayered("items", local, remote, NewCacheInvalidator(client), time.Minute)
+		assert.Nil(t, err, "must be nil")
+		return c, local
+	}
+	a, _ := newReplica()
+	defer a.Close()
+	b, localB := newReplica()
+	defer b.Close()
+
+	assert.Nil(t, a.Set(ctx, "1", cachedItem{ID: 1}, time.Hour), "must be nil")
+	var got cachedItem
+	assert.Nil(t, b.Get(ctx, "1", &got), "must be nil")
+	assert.Equal(t, 1, localB.Len(), "should be set locally")
+
+	assert.Nil(t, a.Set(ctx, "1", cachedItem{ID: 1, Name: "one"}, time.Hour), "must be nil")
+	assert.Eventually(t, func() bool { return localB.Len() == 0 }, time.Second, 5*time.Millisecond, "should be invalidated")
+	assert.Nil(t, b.Get(ctx, "1", &got), "must be nil")
+	assert.Equal(t, "one", got.Name, "should be equal")
+}
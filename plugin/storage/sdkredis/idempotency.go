@@ -0,0 +1,71 @@
+package sdkredis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+const (
+	idempotencyKeyPrefix = "idempotency:"
+	// value of the keys being handled, completed keys hold their json response
+	idempotencyInProgress = "in_progress"
+)
+
+// startIdempotent returns the value of KEYS[1], or sets it to in progress.
+// ARGV: in progress value, ttl in milliseconds.
+var startIdempotent = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if value then
+	return value
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false
+`)
+
+type redisIdempotencyStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisIdempotencyStore records the idempotency keys of all replicas using client,
+// it implements middleware.IdempotencyStore
+func NewRedisIdempotencyStore(client redis.UniversalClient) *redisIdempotencyStore {
+	return &redisIdempotencyStore{client: client}
+}
+
+func (s *redisIdempotencyStore) Start(_ context.Context, key string, lockTTL time.Duration) (*middleware.IdempotentResponse, bool, error) {
+	value, err := startIdempotent.Run(s.client, []string{idempotencyKeyPrefix + key},
+		idempotencyInProgress, lockTTL.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if value == idempotencyInProgress {
+		return nil, false, nil
+	}
+
+	var resp middleware.IdempotentResponse
+	if err := json.Unmarshal([]byte(value), &resp); err != nil {
+		return nil, false, err
+	}
+	return &resp, false, nil
+}
+
+func (s *redisIdempotencyStore) Complete(_ context.Context, key string, resp *middleware.IdempotentResponse, ttl time.Duration) error {
+	value, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(idempotencyKeyPrefix+key, value, ttl).Err()
+}
+
+func (s *redisIdempotencyStore) Release(_ context.Context, key string) error {
+	return s.client.Del(idempotencyKeyPrefix + key).Err()
+}
@@ -0,0 +1,53 @@
+package sdkredis
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+func TestRedisIdempotencyStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	// replicas share the keys
+	s, other := NewRedisIdempotencyStore(client), NewRedisIdempotencyStore(client)
+	ctx := context.Background()
+
+	resp, started, err := s.Start(ctx, "k", time.Minute)
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, resp, "must be nil")
+	assert.True(t, started, "should be started")
+
+	resp, started, err = other.Start(ctx, "k", time.Minute)
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, resp, "must be nil")
+	assert.False(t, started, "should be in progress")
+	assert.True(t, server.TTL(idempotencyKeyPrefix+"k") <= time.Minute, "lock should expire")
+
+	assert.Nil(t, s.Complete(ctx, "k", &middleware.IdempotentResponse{
+		StatusCode: http.StatusCreated,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       []byte(`{"id":1}`),
+	}, time.Hour), "must be nil")
+
+	resp, started, err = other.Start(ctx, "k", time.Minute)
+	assert.Nil(t, err, "must be nil")
+	assert.False(t, started, "should be completed")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, "should be equal")
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), "should be equal")
+	assert.Equal(t, `{"id":1}`, string(resp.Body), "should be equal")
+	assert.Equal(t, time.Hour, server.TTL(idempotencyKeyPrefix+"k"), "should be equal")
+
+	assert.Nil(t, other.Release(ctx, "k"), "must be nil")
+	_, started, err = s.Start(ctx, "k", time.Minute)
+	assert.Nil(t, err, "must be nil")
+	assert.True(t, started, "released keys should be started again")
+}
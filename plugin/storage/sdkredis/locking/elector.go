@@ -0,0 +1,81 @@
+package locking
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// LeaderElector elects one replica holding the lock of a key:
+//
+//	elector := locking.NewLeaderElector(locker, "billing-jobs", 15*time.Second)
+//	go elector.Run(ctx, nil)
+//	cronService.AddJob("@every 1m", "invoices", job, cron.WithSingleton(elector))
+type LeaderElector struct {
+	locker *Locker
+	key    string
+	ttl    time.Duration
+	// wait between the attempts of the followers
+	retryInterval time.Duration
+
+	leader atomic.Bool
+}
+
+// NewLeaderElector campaigns for key, the leadership is lost at most ttl after the leader is gone
+func NewLeaderElector(locker *Locker, key string, ttl time.Duration) *LeaderElector {
+	return &LeaderElector{locker: locker, key: key, ttl: ttl, retryInterval: ttl / 3}
+}
+
+// IsLeader tells whether this replica holds the leadership now
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx is done. While leading, fn runs with a context canceled
+// when the leadership is lost. The leadership is relinquished when fn returns,
+// a nil fn leads until ctx is done. Run returns the error of ctx, or right away
+// the error of a ttl shorter than MinTTL.
+func (e *LeaderElector) Run(ctx context.Context, fn func(ctx context.Context)) error {
+	if err := validateTTL(e.ttl); err != nil {
+		return err
+	}
+
+	for {
+		// followers keep trying, also while Redis is unreachable
+		if lock, err := e.locker.AcquireLock(ctx, e.key, e.ttl); err == nil {
+			e.lead(ctx, lock, fn)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.retryInterval):
+		}
+	}
+}
+
+func (e *LeaderElector) lead(ctx context.Context, lock Lock, fn func(ctx context.Context)) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-lock.Lost():
+			cancel()
+		case <-leaderCtx.Done():
+		}
+	}()
+
+	e.leader.Store(true)
+	if fn != nil {
+		fn(leaderCtx)
+	} else {
+		<-leaderCtx.Done()
+	}
+	e.leader.Store(false)
+
+	// a new leader can be elected right away
+	releaseCtx, cancelRelease := context.WithTimeout(context.Background(), e.ttl)
+	defer cancelRelease()
+	_ = lock.Release(releaseCtx)
+}
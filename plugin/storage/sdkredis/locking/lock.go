@@ -0,0 +1,210 @@
+// Package locking provides distributed locks and leader election on Redis.
+//
+//	locker := locking.NewLocker(service.MustGet("redis").(redis.UniversalClient))
+//	lock, err := locker.AcquireLock(ctx, "reports", 30*time.Second)
+//	if errors.Is(err, locking.ErrNotAcquired) {
+//		return nil // another replica runs it
+//	}
+//	defer lock.Release(context.Background())
+//
+// A lock is extended by a watchdog goroutine until it's released, Lost is closed
+// when it can't be extended anymore and the work under the lock must stop.
+package locking
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+const defaultKeyPrefix = "lock:"
+
+// MinTTL is the shortest ttl of a lock, Redis expires the keys by milliseconds
+const MinTTL = time.Millisecond
+
+var (
+	// ErrNotAcquired means the lock is held by another owner
+	ErrNotAcquired = errors.New("lock is held by another owner")
+	// ErrLockLost means the lock expired or was taken by another owner
+	ErrLockLost = errors.New("lock is lost")
+)
+
+// extend sets the ttl of KEYS[1] if it still holds the token ARGV[1]
+var extend = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// release deletes KEYS[1] if it still holds the token ARGV[1]
+var release = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type Option func(*Locker)
+
+// WithKeyPrefix sets the prefix of the lock keys, default is lock:
+func WithKeyPrefix(prefix string) Option {
+	return func(l *Locker) {
+		l.prefix = prefix
+	}
+}
+
+// Locker acquires locks shared by all replicas using the same Redis
+type Locker struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+func NewLocker(client redis.UniversalClient, opts ...Option) *Locker {
+	l := &Locker{client: client, prefix: defaultKeyPrefix}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Lock is held until Release or until it's lost
+type Lock interface {
+	Key() string
+	// Lost is closed when the lock can't be extended anymore, and on Release
+	Lost() <-chan struct{}
+	// Release stops extending the lock and deletes it, ErrLockLost if it was lost
+	Release(ctx context.Context) error
+}
+
+// AcquireLock tries to take key once, ErrNotAcquired when it's held by another owner.
+// The lock expires after ttl unless it's extended, the watchdog extends it every ttl/3.
+func (l *Locker) AcquireLock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	if err := validateTTL(ttl); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	key = l.prefix + key
+	ok, err := l.client.SetNX(key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+
+	lk := &lock{
+		client:  l.client,
+		key:     key,
+		token:   token,
+		ttl:     ttl,
+		lost:    make(chan struct{}),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go lk.watchdog()
+
+	return lk, nil
+}
+
+func validateTTL(ttl time.Duration) error {
+	if ttl < MinTTL {
+		return fmt.Errorf("lock ttl must be at least %s, got %s", MinTTL, ttl)
+	}
+	return nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+type lock struct {
+	client redis.UniversalClient
+	key    string
+	token  string
+	ttl    time.Duration
+
+	lost     chan struct{}
+	lostOnce sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+func (lk *lock) Key() string {
+	return lk.key
+}
+
+func (lk *lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// watchdog extends the lock, failed extensions are retried until the lock expires
+func (lk *lock) watchdog() {
+	defer close(lk.stopped)
+
+	interval := lk.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	expiry := time.Now().Add(lk.ttl)
+	for {
+		select {
+		case <-lk.stop:
+			return
+		case <-ticker.C:
+		}
+
+		res, err := extend.Run(lk.client, []string{lk.key}, lk.token, lk.ttl.Milliseconds()).Int()
+		switch {
+		case err == nil && res == 1:
+			expiry = time.Now().Add(lk.ttl)
+		case err == nil || time.Now().Add(interval).After(expiry):
+			// taken by another owner, or expired while Redis is unreachable
+			lk.markLost()
+			return
+		}
+	}
+}
+
+func (lk *lock) markLost() {
+	lk.lostOnce.Do(func() { close(lk.lost) })
+}
+
+func (lk *lock) Release(ctx context.Context) error {
+	lk.stopOnce.Do(func() { close(lk.stop) })
+	<-lk.stopped
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	res, err := release.Run(lk.client, []string{lk.key}, lk.token).Int()
+	if err != nil {
+		return err
+	}
+
+	lk.markLost()
+	if res == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
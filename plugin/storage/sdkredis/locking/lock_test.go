@@ -0,0 +1,152 @@
+package locking
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLocker(t *testing.T) (*Locker, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return NewLocker(client), server
+}
+
+func TestLockContention(t *testing.T) {
+	locker, _ := newTestLocker(t)
+	ctx := context.Background()
+
+	var acquired atomic.Int32
+	var wg sync.WaitGroup
+	locks := make(chan Lock, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := locker.AcquireLock(ctx, "reports", time.Second)
+			if err == nil {
+				acquired.Add(1)
+				locks <- lock
+				return
+			}
+			assert.True(t, errors.Is(err, ErrNotAcquired), "should be ErrNotAcquired")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), acquired.Load(), "only one owner should acquire the lock")
+
+	lock := <-locks
+	assert.Equal(t, "lock:reports", lock.Key(), "should be equal")
+	assert.Nil(t, lock.Release(ctx), "must be nil")
+
+	// released locks can be acquired again
+	lock, err := locker.AcquireLock(ctx, "reports", time.Second)
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, lock.Release(ctx), "must be nil")
+}
+
+func TestLockInvalidTTL(t *testing.T) {
+	locker, server := newTestLocker(t)
+	ctx := context.Background()
+
+	for _, ttl := range []time.Duration{0, -time.Second, 2 * time.Nanosecond, 999 * time.Microsecond} {
+		_, err := locker.AcquireLock(ctx, "reports", ttl)
+		assert.NotNil(t, err, "should be an error")
+		assert.False(t, server.Exists("lock:reports"), "must not be acquired")
+	}
+
+	assert.NotNil(t, NewLeaderElector(locker, "billing", 0).Run(ctx, nil), "should be an error")
+
+	lock, err := locker.AcquireLock(ctx, "reports", MinTTL)
+	assert.Nil(t, err, "must be nil")
+	_ = lock.Release(ctx)
+}
+
+func TestLockExtendedDuringLongTask(t *testing.T) {
+	locker, server := newTestLocker(t)
+	ctx := context.Background()
+
+	lock, err := locker.AcquireLock(ctx, "reports", 300*time.Millisecond)
+	assert.Nil(t, err, "must be nil")
+
+	// miniredis keys expire only when time is fast forwarded
+	for i := 0; i < 10; i++ {
+		time.Sleep(100 * time.Millisecond)
+		server.FastForward(100 * time.Millisecond)
+	}
+	assert.True(t, server.Exists("lock:reports"), "lock should be extended by the watchdog")
+
+	select {
+	case <-lock.Lost():
+		t.Fatal("lock should not be lost")
+	default:
+	}
+
+	_, err = locker.AcquireLock(ctx, "reports", time.Second)
+	assert.True(t, errors.Is(err, ErrNotAcquired), "should be ErrNotAcquired")
+	assert.Nil(t, lock.Release(ctx), "must be nil")
+}
+
+func TestLockExpired(t *testing.T) {
+	locker, server := newTestLocker(t)
+	ctx := context.Background()
+
+	lock, err := locker.AcquireLock(ctx, "reports", 300*time.Millisecond)
+	assert.Nil(t, err, "must be nil")
+
+	// expired before the watchdog extends it, then taken by another owner
+	server.FastForward(time.Second)
+	other, err := locker.AcquireLock(ctx, "reports", time.Second)
+	assert.Nil(t, err, "must be nil")
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lock should be lost")
+	}
+
+	assert.True(t, errors.Is(lock.Release(ctx), ErrLockLost), "should be ErrLockLost")
+	assert.True(t, server.Exists("lock:reports"), "the lock of the other owner must be kept")
+	assert.Nil(t, other.Release(ctx), "must be nil")
+}
+
+func TestLeadershipHandover(t *testing.T) {
+	locker, _ := newTestLocker(t)
+
+	first := NewLeaderElector(locker, "jobs", 300*time.Millisecond)
+	second := NewLeaderElector(locker, "jobs", 300*time.Millisecond)
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	leading := make(chan struct{})
+	firstDone := make(chan error, 1)
+	go func() {
+		firstDone <- first.Run(firstCtx, func(ctx context.Context) {
+			close(leading)
+			<-ctx.Done()
+		})
+	}()
+	<-leading
+	assert.True(t, first.IsLeader(), "first should lead")
+
+	secondCtx, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+	go func() { _ = second.Run(secondCtx, nil) }()
+
+	time.Sleep(300 * time.Millisecond)
+	assert.False(t, second.IsLeader(), "second should follow")
+
+	cancelFirst()
+	assert.True(t, errors.Is(<-firstDone, context.Canceled), "should be canceled")
+	assert.False(t, first.IsLeader(), "first should relinquish")
+
+	assert.Eventually(t, second.IsLeader, 2*time.Second, 10*time.Millisecond, "second should take over")
+}
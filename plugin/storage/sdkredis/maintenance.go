@@ -0,0 +1,74 @@
+package sdkredis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+// prefix of the key and the channel of the maintenance state of a service
+const maintenanceKeyPrefix = "maintenance:"
+
+type redisMaintenanceSync struct {
+	client redis.UniversalClient
+	key    string
+}
+
+// NewMaintenanceSync shares the maintenance state of the replicas of service
+// using client, it implements middleware.MaintenanceSync. The last state is
+// kept so the replicas starting during a maintenance get it.
+func NewMaintenanceSync(client redis.UniversalClient, service string) *redisMaintenanceSync {
+	return &redisMaintenanceSync{client: client, key: maintenanceKeyPrefix + service}
+}
+
+func (s *redisMaintenanceSync) Load(_ context.Context) (middleware.MaintenanceState, bool, error) {
+	var state middleware.MaintenanceState
+	data, err := s.client.Get(s.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return state, false, nil
+	}
+	if err != nil {
+		return state, false, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, false, err
+	}
+	return state, true, nil
+}
+
+func (s *redisMaintenanceSync) Publish(_ context.Context, state middleware.MaintenanceState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(s.key, data, 0)
+	pipe.Publish(s.key, data)
+	_, err = pipe.Exec()
+	return err
+}
+
+func (s *redisMaintenanceSync) Subscribe(fn func(middleware.MaintenanceState)) (io.Closer, error) {
+	sub := s.client.Subscribe(s.key)
+	// waits for the confirmation so no state published after is missed
+	if _, err := sub.Receive(); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+
+	go func() {
+		for msg := range sub.Channel() {
+			var state middleware.MaintenanceState
+			if err := json.Unmarshal([]byte(msg.Payload), &state); err != nil {
+				continue
+			}
+			fn(state)
+		}
+	}()
+	return sub, nil
+}
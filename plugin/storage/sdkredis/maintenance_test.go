@@ -0,0 +1,55 @@
+package sdkredis
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver/middleware"
+)
+
+func TestMaintenanceSync(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	s := NewMaintenanceSync(client, "orders")
+	_, ok, err := s.Load(ctx)
+	assert.Nil(t, err, "must be nil")
+	assert.False(t, ok, "should be empty")
+
+	var mu sync.Mutex
+	var received []middleware.MaintenanceState
+	sub, err := NewMaintenanceSync(client, "orders").Subscribe(func(state middleware.MaintenanceState) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, state)
+	})
+	assert.Nil(t, err, "must be nil")
+	defer sub.Close()
+
+	eta := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	state := middleware.MaintenanceState{Enabled: true, Message: "migrating", ETA: &eta, UpdatedAt: time.Now().UTC()}
+	assert.Nil(t, s.Publish(ctx, state), "must be nil")
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1
+	}, time.Second, 5*time.Millisecond, "should be received")
+	assert.Equal(t, "migrating", received[0].Message, "should be equal")
+	assert.True(t, eta.Equal(*received[0].ETA), "should be equal")
+
+	// replicas starting later
+	loaded, ok, err := NewMaintenanceSync(client, "orders").Load(ctx)
+	assert.Nil(t, err, "must be nil")
+	assert.True(t, ok, "should be found")
+	assert.True(t, loaded.Enabled, "should be enabled")
+
+	_, ok, _ = NewMaintenanceSync(client, "payments").Load(ctx)
+	assert.False(t, ok, "services should not share the state")
+}
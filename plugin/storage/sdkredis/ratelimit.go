@@ -0,0 +1,73 @@
+package sdkredis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+const rateLimitKeyPrefix = "ratelimit:"
+
+// tokenBucket refills the bucket of KEYS[1] then takes a token.
+// ARGV: rate per second, burst, now in milliseconds.
+// It returns {allowed, milliseconds until the next token}.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+
+return {allowed, wait}
+`)
+
+type redisLimiter struct {
+	client redis.UniversalClient
+	rate   float64
+	burst  int
+	now    func() time.Time
+}
+
+// NewRedisLimiter is a token bucket limiter shared by all replicas using client,
+// it implements middleware.Limiter. Buckets expire once they are full again.
+func NewRedisLimiter(client redis.UniversalClient, rps float64, burst int) *redisLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &redisLimiter{client: client, rate: rps, burst: burst, now: time.Now}
+}
+
+func (l *redisLimiter) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	res, err := tokenBucket.Run(l.client, []string{rateLimitKeyPrefix + key},
+		l.rate, l.burst, l.now().UnixMilli()).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	values := res.([]interface{})
+	allowed, _ := values[0].(int64)
+	wait, _ := values[1].(int64)
+
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,48 @@
+package sdkredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisLimiter(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	now := time.Unix(1700000000, 0)
+	l := NewRedisLimiter(client, 2, 3)
+	l.now = func() time.Time { return now }
+
+	// replicas share the bucket
+	other := NewRedisLimiter(client, 2, 3)
+	other.now = l.now
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		allowed, _, err := l.Allow(ctx, "ip:10.0.0.1")
+		assert.Nil(t, err, "must be nil")
+		assert.True(t, allowed, "burst should be allowed")
+	}
+
+	allowed, retryAfter, err := other.Allow(ctx, "ip:10.0.0.1")
+	assert.Nil(t, err, "must be nil")
+	assert.False(t, allowed, "bucket should be empty")
+	assert.Equal(t, 500*time.Millisecond, retryAfter, "should be equal")
+
+	allowed, _, err = l.Allow(ctx, "ip:10.0.0.2")
+	assert.Nil(t, err, "must be nil")
+	assert.True(t, allowed, "keys should have their own bucket")
+
+	now = now.Add(500 * time.Millisecond)
+	allowed, _, err = other.Allow(ctx, "ip:10.0.0.1")
+	assert.Nil(t, err, "must be nil")
+	assert.True(t, allowed, "a token should be refilled")
+
+	assert.True(t, server.TTL(rateLimitKeyPrefix+"ip:10.0.0.1") > 0, "bucket should expire")
+}
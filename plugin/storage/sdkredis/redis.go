@@ -21,10 +21,24 @@ package sdkredis
 // 		Distributed Locks.
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v7"
+	goservice "github.com/taimaifika/go-sdk"
 	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/otel"
+	otelapi "go.opentelemetry.io/otel"
+)
+
+const (
+	ModeSingle   = "single"
+	ModeCluster  = "cluster"
+	ModeSentinel = "sentinel"
 )
 
 var (
@@ -32,18 +46,29 @@ var (
 	DefaultRedisDB        = getDefaultRedisDB()
 	defaultRedisMaxActive = 0 // 0 is unlimited max active connection
 	defaultRedisMaxIdle   = 10
+	defaultDialTimeout    = 5 * time.Second
+	defaultReadTimeout    = 3 * time.Second
+	healthCheckTimeout    = time.Second
 )
 
 type RedisDBOpt struct {
-	Prefix    string
-	RedisUri  string
-	MaxActive int
-	MaxIde    int
+	Prefix       string
+	RedisUri     string
+	MaxActive    int
+	MaxIde       int
+	Mode         string
+	Addrs        string // comma separated host:port
+	MasterName   string
+	Password     string
+	DB           int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
 }
 
 type redisDB struct {
 	name   string
-	client *redis.Client
+	client redis.UniversalClient
 	logger logger.Logger
 	*RedisDBOpt
 }
@@ -59,6 +84,7 @@ func NewRedisDB(name, flagPrefix string) *redisDB {
 			Prefix:    flagPrefix,
 			MaxActive: defaultRedisMaxActive,
 			MaxIde:    defaultRedisMaxIdle,
+			Mode:      ModeSingle,
 		},
 	}
 }
@@ -68,18 +94,87 @@ func (r *redisDB) GetPrefix() string {
 }
 
 func (r *redisDB) isDisabled() bool {
-	return r.RedisUri == ""
+	return r.RedisUri == "" && r.Addrs == ""
 }
 
 func (r *redisDB) InitFlags() {
+	r.InitFlagSet(flag.CommandLine)
+}
+
+func (r *redisDB) InitFlagSet(fs *flag.FlagSet) {
 	prefix := r.Prefix
 	if r.Prefix != "" {
 		prefix += "-"
 	}
 
-	flag.StringVar(&r.RedisUri, prefix+"go-redis-uri", "", "(For go-redis) Redis connection-string. Ex: redis://localhost/0")
-	flag.IntVar(&r.MaxActive, prefix+"go-redis-pool-max-active", defaultRedisMaxActive, "(For go-redis) Override redis pool MaxActive")
-	flag.IntVar(&r.MaxIde, prefix+"go-redis-pool-max-idle", defaultRedisMaxIdle, "(For go-redis) Override redis pool MaxIdle")
+	goservice.SecretFlagVar(fs, &r.RedisUri, prefix+"go-redis-uri", "", "(For go-redis) Redis connection-string. Ex: redis://localhost/0")
+	fs.IntVar(&r.MaxActive, prefix+"go-redis-pool-max-active", defaultRedisMaxActive, "(For go-redis) Override redis pool MaxActive")
+	fs.IntVar(&r.MaxIde, prefix+"go-redis-pool-max-idle", defaultRedisMaxIdle, "(For go-redis) Override redis pool MaxIdle")
+	fs.StringVar(&r.Mode, prefix+"go-redis-mode", ModeSingle, "(For go-redis) Redis mode: single | cluster | sentinel")
+	fs.StringVar(&r.Addrs, prefix+"go-redis-addrs", "", "(For go-redis) Comma separated host:port of cluster or sentinel nodes, used instead of the uri")
+	fs.StringVar(&r.MasterName, prefix+"go-redis-master-name", "", "(For go-redis) Master name in sentinel mode")
+	fs.StringVar(&r.Password, prefix+"go-redis-password", "", "(For go-redis) Redis password, overrides the one in the uri")
+	fs.IntVar(&r.DB, prefix+"go-redis-db", 0, "(For go-redis) Redis DB index when using addrs, not supported in cluster mode")
+	fs.DurationVar(&r.DialTimeout, prefix+"go-redis-dial-timeout", defaultDialTimeout, "(For go-redis) Timeout of new connections")
+	fs.DurationVar(&r.ReadTimeout, prefix+"go-redis-read-timeout", defaultReadTimeout, "(For go-redis) Timeout of socket reads")
+	fs.DurationVar(&r.WriteTimeout, prefix+"go-redis-write-timeout", defaultReadTimeout, "(For go-redis) Timeout of socket writes")
+}
+
+// options builds client options from the uri or the addrs flags
+func (r *redisDB) options() (*redis.UniversalOptions, error) {
+	opt := &redis.UniversalOptions{
+		DB:           r.DB,
+		Password:     r.Password,
+		PoolSize:     r.MaxActive,
+		MinIdleConns: r.MaxIde,
+		DialTimeout:  r.DialTimeout,
+		ReadTimeout:  r.ReadTimeout,
+		WriteTimeout: r.WriteTimeout,
+		MasterName:   r.MasterName,
+	}
+
+	if r.Addrs != "" {
+		for _, addr := range strings.Split(r.Addrs, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				opt.Addrs = append(opt.Addrs, addr)
+			}
+		}
+	} else {
+		uriOpt, err := redis.ParseURL(r.RedisUri)
+		if err != nil {
+			return nil, err
+		}
+
+		opt.Addrs = []string{uriOpt.Addr}
+		opt.DB = uriOpt.DB
+		opt.TLSConfig = uriOpt.TLSConfig
+		if opt.Password == "" {
+			opt.Password = uriOpt.Password
+		}
+	}
+
+	switch r.Mode {
+	case ModeSingle, ModeCluster:
+	case ModeSentinel:
+		if r.MasterName == "" {
+			return nil, errors.New("redis master name is required in sentinel mode")
+		}
+	default:
+		return nil, fmt.Errorf("redis mode %q is not supported", r.Mode)
+	}
+
+	return opt, nil
+}
+
+func (r *redisDB) newClient(opt *redis.UniversalOptions) redis.UniversalClient {
+	switch r.Mode {
+	case ModeCluster:
+		return redis.NewClusterClient(opt.Cluster())
+	case ModeSentinel:
+		return redis.NewFailoverClient(opt.Failover())
+	default:
+		return redis.NewClient(opt.Simple())
+	}
 }
 
 func (r *redisDB) Configure() error {
@@ -88,22 +183,24 @@ func (r *redisDB) Configure() error {
 	}
 
 	r.logger = logger.GetCurrent().GetLogger(r.name)
-	r.logger.Info("Connecting to Redis at ", r.RedisUri, "...")
-
-	opt, err := redis.ParseURL(r.RedisUri)
 
+	opt, err := r.options()
 	if err != nil {
 		r.logger.Error("Cannot parse Redis ", err.Error())
 		return err
 	}
 
-	opt.PoolSize = r.MaxActive
-	opt.MinIdleConns = r.MaxIde
+	r.logger.Infof("Connecting to Redis (%s) at %s...", r.Mode, strings.Join(opt.Addrs, ","))
 
-	client := redis.NewClient(opt)
+	client := r.newClient(opt)
+
+	if otel.IsEnabled() {
+		client.AddHook(newTracingHook(otelapi.GetTracerProvider()))
+	}
 
 	// Ping to test Redis connection
 	if err := client.Ping().Err(); err != nil {
+		_ = client.Close()
 		r.logger.Error("Cannot connect Redis. ", err.Error())
 		return err
 	}
@@ -117,10 +214,33 @@ func (r *redisDB) Name() string {
 	return r.name
 }
 
+// Get returns the client as redis.UniversalClient,
+// its dynamic type is *redis.Client in single mode
 func (r *redisDB) Get() interface{} {
 	return r.client
 }
 
+// HealthCheck pings Redis, a disabled plugin is always healthy
+func (r *redisDB) HealthCheck(ctx context.Context) error {
+	if r.isDisabled() {
+		return nil
+	}
+
+	if r.client == nil {
+		return errors.New("redis is not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	return r.client.DoContext(ctx, "ping").Err()
+}
+
+// CheckConnectivity pings Redis, Configure has connected
+func (r *redisDB) CheckConnectivity(ctx context.Context) error {
+	return r.HealthCheck(ctx)
+}
+
 func (r *redisDB) Run() error {
 	return r.Configure()
 }
@@ -0,0 +1,72 @@
+package sdkredis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRedisOptions(t *testing.T) {
+	r := NewRedisDB("redis", "")
+	r.RedisUri = "redis://:secret@localhost:6380/2"
+	opt, err := r.options()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, []string{"localhost:6380"}, opt.Addrs, "should be equal")
+	assert.Equal(t, 2, opt.DB, "should be equal")
+	assert.Equal(t, "secret", opt.Password, "should be equal")
+	assert.IsType(t, &redis.Client{}, r.newClient(opt), "should be a single client")
+
+	r = NewRedisDB("redis", "")
+	r.Mode = ModeCluster
+	r.Addrs = "node1:6379, node2:6379,"
+	opt, err = r.options()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, []string{"node1:6379", "node2:6379"}, opt.Addrs, "should be equal")
+	assert.IsType(t, &redis.ClusterClient{}, r.newClient(opt), "should be a cluster client")
+
+	r = NewRedisDB("redis", "")
+	r.Mode = ModeSentinel
+	r.Addrs = "sentinel:26379"
+	_, err = r.options()
+	assert.NotNil(t, err, "master name is required")
+
+	r.Mode = "ring"
+	r.MasterName = "mymaster"
+	_, err = r.options()
+	assert.NotNil(t, err, "should be an error")
+}
+
+func TestRedisDisabled(t *testing.T) {
+	r := NewRedisDB("redis", "")
+	assert.Nil(t, r.Configure(), "must be nil")
+	assert.Nil(t, r.HealthCheck(context.Background()), "disabled plugin is always healthy")
+	<-r.Stop()
+}
+
+func TestTracingHook(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	hook := newTracingHook(trace.NewTracerProvider(trace.WithSpanProcessor(recorder)))
+
+	cmd := redis.NewStatusCmd("ping")
+	ctx, err := hook.BeforeProcess(context.Background(), cmd)
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, hook.AfterProcess(ctx, cmd), "must be nil")
+
+	failed := redis.NewStringCmd("get", "key")
+	failed.SetErr(errors.New("connection refused"))
+	ctx, _ = hook.BeforeProcessPipeline(context.Background(), []redis.Cmder{redis.NewStatusCmd("ping"), failed})
+	assert.Nil(t, hook.AfterProcessPipeline(ctx, []redis.Cmder{failed}), "must be nil")
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2, "should have two spans")
+	assert.Equal(t, "redis.ping", spans[0].Name(), "should be equal")
+	assert.Equal(t, codes.Unset, spans[0].Status().Code, "should be equal")
+	assert.Equal(t, "redis.pipeline", spans[1].Name(), "should be equal")
+	assert.Equal(t, codes.Error, spans[1].Status().Code, "should be equal")
+}
@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the timestamp and the signatures of a delivery: t=<unix seconds>,v1=<hex>
+const SignatureHeader = "X-Signature"
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrSignatureExpired means the timestamp is older than the max skew, the delivery may be replayed
+	ErrSignatureExpired = errors.New("webhook signature is expired")
+)
+
+// Sign returns the X-Signature value of body, the HMAC-SHA256 of "<timestamp>.<body>"
+func Sign(body []byte, secret string, timestamp time.Time) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(ts, body, secret)
+}
+
+func signature(ts string, body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the X-Signature header of a received webhook, its timestamp must be
+// within maxSkew of now. Several v1 signatures are accepted while the secret is rotated:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	if err := webhook.VerifySignature(r.Header.Get(webhook.SignatureHeader), body, secret, 5*time.Minute); err != nil {
+//		w.WriteHeader(http.StatusUnauthorized)
+//		return
+//	}
+func VerifySignature(header string, body []byte, secret string, maxSkew time.Duration) error {
+	var (
+		ts         string
+		signatures []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if ts == "" || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return ErrSignatureExpired
+	}
+
+	expected := []byte(signature(ts, body, secret))
+	for _, s := range signatures {
+		if hmac.Equal([]byte(s), expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
@@ -0,0 +1,406 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/worker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/taimaifika/go-sdk/plugin/webhook"
+	meterName  = "github.com/taimaifika/go-sdk/plugin/webhook"
+
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+	outcomeDropped = "dropped"
+
+	// only the start of the responses is read, so the connection is reused
+	maxResponseRead = 64 << 10
+)
+
+var (
+	defaultTimeout     = 10 * time.Second
+	defaultRetries     = 8
+	defaultMinBackoff  = 5 * time.Second
+	defaultMaxBackoff  = time.Hour
+	defaultMaxAge      = 24 * time.Hour
+	defaultMaxBodySize = 256 << 10
+)
+
+var (
+	// ErrBodyTooLarge means the JSON of the event is larger than max-body-size
+	ErrBodyTooLarge = errors.New("webhook body is too large")
+	// ErrNoSecret means there is no secret to sign the deliveries of the url
+	ErrNoSecret = errors.New("webhook destination has no secret")
+)
+
+// Event is the JSON body of a delivery, ID is generated when it's empty.
+// Receivers use the ID to ignore the retries of a delivery they handled.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// SecretFunc returns the signing secret of a destination
+type SecretFunc func(ctx context.Context, url string) (string, error)
+
+// Dispatcher is returned by Get:
+//
+//	webhooks := service.MustGet("webhook").(webhook.Dispatcher)
+//	err := webhooks.Dispatch(ctx, subscription.URL, webhook.Event{Type: "order.paid", Data: order})
+type Dispatcher interface {
+	// Dispatch queues the delivery of event to url on the worker pool,
+	// failed deliveries are retried until the max age
+	Dispatch(ctx context.Context, url string, event Event) error
+}
+
+type Option func(*dispatcher)
+
+// WithDependsOn starts the dispatcher after the services of the prefixes, ex: the worker plugin
+func WithDependsOn(prefixes ...string) Option {
+	return func(d *dispatcher) {
+		d.dependsOn = append(d.dependsOn, prefixes...)
+	}
+}
+
+// WithSecretFunc sets the per destination secrets, the secret flag is used when it's not set
+func WithSecretFunc(fn SecretFunc) Option {
+	return func(d *dispatcher) {
+		d.secretFunc = fn
+	}
+}
+
+type delivery struct {
+	ctx      context.Context
+	url      string
+	host     string
+	eventID  string
+	body     []byte
+	secret   string
+	created  time.Time
+	attempts int
+}
+
+type dispatcher struct {
+	name       string
+	prefix     string
+	logger     logger.Logger
+	queue      func() worker.Queue
+	dependsOn  []string
+	secretFunc SecretFunc
+
+	secret      string
+	timeout     time.Duration
+	retries     int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	maxAge      time.Duration
+	maxBodySize int
+
+	client      *http.Client
+	deliveries  metric.Int64Counter
+	mu          sync.Mutex
+	retryTimers map[*time.Timer]*delivery
+	stopped     bool
+}
+
+// New returns the dispatcher, queue is called when it runs because the worker plugin
+// is started by the service:
+//
+//	webhooks := webhook.New("webhook", "webhook",
+//		func() worker.Queue { return service.MustGet("worker").(worker.Queue) },
+//		webhook.WithDependsOn("worker"))
+func New(name, prefix string, queue func() worker.Queue, opts ...Option) *dispatcher {
+	d := &dispatcher{name: name, prefix: prefix, queue: queue, retryTimers: map[*time.Timer]*delivery{}}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+func (d *dispatcher) Name() string {
+	return d.name
+}
+
+func (d *dispatcher) GetPrefix() string {
+	return d.prefix
+}
+
+func (d *dispatcher) Get() interface{} {
+	return Dispatcher(d)
+}
+
+func (d *dispatcher) DependsOn() []string {
+	return d.dependsOn
+}
+
+func (d *dispatcher) InitFlags() {
+	d.InitFlagSet(flag.CommandLine)
+}
+
+func (d *dispatcher) InitFlagSet(fs *flag.FlagSet) {
+	prefix := d.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&d.secret, prefix+"secret", "", "secret signing the deliveries of the destinations without their own secret")
+	fs.DurationVar(&d.timeout, prefix+"timeout", defaultTimeout, "timeout of a delivery attempt")
+	fs.IntVar(&d.retries, prefix+"retries", defaultRetries, "max retries of a failed delivery")
+	fs.DurationVar(&d.minBackoff, prefix+"min-backoff", defaultMinBackoff, "delay of the first retry, doubled on each retry with jitter")
+	fs.DurationVar(&d.maxBackoff, prefix+"max-backoff", defaultMaxBackoff, "max delay between retries")
+	fs.DurationVar(&d.maxAge, prefix+"max-age", defaultMaxAge, "a delivery is dropped when it's not delivered after it")
+	fs.IntVar(&d.maxBodySize, prefix+"max-body-size", defaultMaxBodySize, "max size of the JSON body of an event in bytes")
+}
+
+func (d *dispatcher) Configure() error {
+	d.logger = logger.GetCurrent().GetLogger(d.name)
+
+	if d.queue == nil {
+		return errors.New("webhook dispatcher requires the worker queue")
+	}
+	if d.retries < 0 || d.minBackoff <= 0 || d.maxBackoff < d.minBackoff {
+		return errors.New("webhook retries must not be negative and backoffs must be positive with min <= max")
+	}
+
+	d.client = &http.Client{
+		Timeout: d.timeout,
+		// a redirect may point to an internal address
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var err error
+	d.deliveries, err = otel.Meter(meterName).Int64Counter("webhook.deliveries",
+		metric.WithDescription("Number of webhook delivery attempts"),
+		metric.WithUnit("{delivery}"))
+	return err
+}
+
+func (d *dispatcher) Run() error {
+	return d.Configure()
+}
+
+// Stop cancels the scheduled retries, they are logged as dropped
+func (d *dispatcher) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() {
+		d.mu.Lock()
+		d.stopped = true
+		timers := d.retryTimers
+		d.retryTimers = map[*time.Timer]*delivery{}
+		d.mu.Unlock()
+
+		for t, dl := range timers {
+			if t.Stop() {
+				d.logger.Withs(logger.Fields{"id": dl.eventID, "url": dl.url}).Warn("webhook retry dropped on shutdown")
+			}
+		}
+		c <- true
+	}()
+	return c
+}
+
+func (d *dispatcher) Dispatch(ctx context.Context, rawURL string, event Event) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url %q", rawURL)
+	}
+
+	secret := d.secret
+	if d.secretFunc != nil {
+		if secret, err = d.secretFunc(ctx, rawURL); err != nil {
+			return err
+		}
+	}
+	if secret == "" {
+		return ErrNoSecret
+	}
+
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if len(body) > d.maxBodySize {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrBodyTooLarge, len(body), d.maxBodySize)
+	}
+
+	return d.enqueue(&delivery{
+		ctx:     ctx,
+		url:     rawURL,
+		host:    u.Hostname(),
+		eventID: event.ID,
+		body:    body,
+		secret:  secret,
+		created: time.Now(),
+	})
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (d *dispatcher) enqueue(dl *delivery) error {
+	return d.queue().Enqueue(dl.ctx, "webhook "+dl.host, func(ctx context.Context) error {
+		return d.attempt(ctx, dl)
+	}, worker.WithTimeout(d.timeout))
+}
+
+// attempt sends a delivery once and schedules its retry when it fails,
+// failures are logged here so the worker doesn't log them again
+func (d *dispatcher) attempt(ctx context.Context, dl *delivery) error {
+	dl.attempts++
+	status, err := d.send(ctx, dl)
+	if err == nil {
+		d.record(ctx, dl, outcomeSuccess)
+		return nil
+	}
+
+	log := d.logger.Withs(logger.Fields{"id": dl.eventID, "url": dl.url, "attempts": dl.attempts})
+
+	// the destination rejects the delivery, it would be rejected again
+	if status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+		d.record(ctx, dl, outcomeDropped)
+		log.Errorf("webhook delivery dropped, rejected with status %d", status)
+		return nil
+	}
+
+	backoff := d.backoff(dl.attempts)
+	if dl.attempts > d.retries || time.Since(dl.created)+backoff > d.maxAge {
+		d.record(ctx, dl, outcomeDropped)
+		log.Errorf("webhook delivery dropped after %d attempts: %s", dl.attempts, err.Error())
+		return nil
+	}
+
+	d.record(ctx, dl, outcomeFailure)
+	log.Warnf("webhook delivery failed, retry in %s: %s", backoff.Round(time.Millisecond), err.Error())
+	d.scheduleRetry(dl, backoff)
+	return nil
+}
+
+func (d *dispatcher) scheduleRetry(dl *delivery, backoff time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(backoff, func() {
+		d.mu.Lock()
+		delete(d.retryTimers, t)
+		d.mu.Unlock()
+
+		if err := d.enqueue(dl); err != nil {
+			// ex: the queue is full, it counts as a failed attempt
+			d.enqueueFailed(dl, err)
+		}
+	})
+	d.retryTimers[t] = dl
+}
+
+// enqueueFailed schedules the next retry of a delivery which couldn't be queued
+func (d *dispatcher) enqueueFailed(dl *delivery, err error) {
+	dl.attempts++
+	backoff := d.backoff(dl.attempts)
+	if dl.attempts > d.retries || time.Since(dl.created)+backoff > d.maxAge {
+		d.record(context.Background(), dl, outcomeDropped)
+		d.logger.Withs(logger.Fields{"id": dl.eventID, "url": dl.url}).
+			Errorf("webhook delivery dropped, cannot queue it: %s", err.Error())
+		return
+	}
+	d.scheduleRetry(dl, backoff)
+}
+
+// backoff doubles from min-backoff up to max-backoff, with a random jitter of half of it
+func (d *dispatcher) backoff(attempts int) time.Duration {
+	b := d.minBackoff
+	for i := 1; i < attempts && b < d.maxBackoff; i++ {
+		b *= 2
+	}
+	if b > d.maxBackoff {
+		b = d.maxBackoff
+	}
+	return b/2 + rand.N(b/2+1)
+}
+
+// send posts the signed body, it returns the status code of the response, 0 without response
+func (d *dispatcher) send(ctx context.Context, dl *delivery) (int, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "webhook POST", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodPost,
+			semconv.ServerAddress(dl.host),
+			attribute.String("webhook.id", dl.eventID),
+			attribute.Int("webhook.attempt", dl.attempts),
+		))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.url, bytes.NewReader(dl.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", dl.eventID)
+	req.Header.Set("X-Webhook-Attempt", strconv.Itoa(dl.attempts))
+	req.Header.Set(SignatureHeader, Sign(dl.body, dl.secret, time.Now()))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseRead))
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("webhook destination responded %d", resp.StatusCode)
+		span.SetStatus(codes.Error, err.Error())
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *dispatcher) record(ctx context.Context, dl *delivery, outcome string) {
+	d.deliveries.Add(ctx, 1, metric.WithAttributes(
+		semconv.ServerAddress(dl.host),
+		attribute.String("outcome", outcome),
+	))
+}
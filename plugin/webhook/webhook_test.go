@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/worker"
+)
+
+// goQueue runs each job in a goroutine
+type goQueue struct {
+	wg sync.WaitGroup
+}
+
+func (q *goQueue) Enqueue(ctx context.Context, _ string, fn worker.JobFunc, _ ...worker.JobOption) error {
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		_ = fn(context.WithoutCancel(ctx))
+	}()
+	return nil
+}
+
+func newTestDispatcher(t *testing.T, opts ...Option) *dispatcher {
+	logger.InitServLogger(false)
+
+	queue := &goQueue{}
+	d := New("webhook", "webhook", func() worker.Queue { return queue }, opts...)
+	d.secret = "s3cret"
+	d.timeout = time.Second
+	d.retries = 3
+	d.minBackoff = 10 * time.Millisecond
+	d.maxBackoff = 20 * time.Millisecond
+	d.maxAge = time.Minute
+	d.maxBodySize = 1024
+	assert.Nil(t, d.Run(), "must be nil")
+	t.Cleanup(func() { <-d.Stop() })
+	return d
+}
+
+func TestDispatchSignsAndRetries(t *testing.T) {
+	var attempts atomic.Int32
+	received := make(chan Event, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifySignature(r.Header.Get(SignatureHeader), body, "s3cret", time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var e Event
+		_ = json.Unmarshal(body, &e)
+		assert.Equal(t, e.ID, r.Header.Get("X-Webhook-Id"), "should be equal")
+		received <- e
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t)
+	err := d.Dispatch(context.Background(), server.URL+"/hook", Event{Type: "order.paid", Data: map[string]int{"id": 7}})
+	assert.Nil(t, err, "must be nil")
+
+	select {
+	case e := <-received:
+		assert.Equal(t, "order.paid", e.Type, "should be equal")
+		assert.NotEmpty(t, e.ID, "should generate an id")
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	assert.Equal(t, int32(3), attempts.Load(), "should be equal")
+}
+
+func TestDispatchDrops(t *testing.T) {
+	var rejected, failing atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/rejected") {
+			rejected.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		failing.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t)
+	assert.Nil(t, d.Dispatch(context.Background(), server.URL+"/rejected", Event{Type: "t"}), "must be nil")
+	assert.Nil(t, d.Dispatch(context.Background(), server.URL+"/failing", Event{Type: "t"}), "must be nil")
+
+	// 1 attempt and 3 retries
+	assert.Eventually(t, func() bool { return failing.Load() == 4 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(4), failing.Load(), "should stop retrying")
+	assert.Equal(t, int32(1), rejected.Load(), "4xx should not be retried")
+}
+
+func TestDispatchErrors(t *testing.T) {
+	d := newTestDispatcher(t)
+
+	err := d.Dispatch(context.Background(), "ftp://example.com", Event{})
+	assert.NotNil(t, err, "should be an error")
+
+	err = d.Dispatch(context.Background(), "https://example.com", Event{Data: strings.Repeat("x", 2048)})
+	assert.True(t, errors.Is(err, ErrBodyTooLarge), "should be ErrBodyTooLarge")
+
+	d = newTestDispatcher(t, WithSecretFunc(func(context.Context, string) (string, error) { return "", nil }))
+	err = d.Dispatch(context.Background(), "https://example.com", Event{})
+	assert.Equal(t, ErrNoSecret, err, "should be equal")
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	now := time.Now()
+
+	assert.Nil(t, VerifySignature(Sign(body, "secret", now), body, "secret", time.Minute), "must be nil")
+
+	// rotated secret, one of the signatures matches
+	header := Sign(body, "old", now) + ",v1=" + signature(strings.TrimPrefix(strings.Split(Sign(body, "new", now), ",")[0], "t="), body, "new")
+	assert.Nil(t, VerifySignature(header, body, "new", time.Minute), "must be nil")
+
+	assert.Equal(t, ErrInvalidSignature, VerifySignature(Sign(body, "other", now), body, "secret", time.Minute), "should be equal")
+	assert.Equal(t, ErrInvalidSignature, VerifySignature(Sign(body, "secret", now), []byte(`{"id":"2"}`), "secret", time.Minute), "should be equal")
+	assert.Equal(t, ErrSignatureExpired, VerifySignature(Sign(body, "secret", now.Add(-time.Hour)), body, "secret", time.Minute), "should be equal")
+	assert.Equal(t, ErrInvalidSignature, VerifySignature("garbage", body, "secret", time.Minute), "should be equal")
+}
@@ -0,0 +1,362 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/taimaifika/go-sdk/plugin/worker"
+	meterName  = "github.com/taimaifika/go-sdk/plugin/worker"
+
+	outcomeOK      = "ok"
+	outcomeError   = "error"
+	outcomeTimeout = "timeout"
+	outcomePanic   = "panic"
+)
+
+var (
+	defaultQueueSize    = 1000
+	defaultConcurrency  = 10
+	defaultJobTimeout   = time.Minute
+	defaultDrainTimeout = 30 * time.Second
+	// running jobs get it to return after their context is canceled by Stop
+	abandonGrace = 5 * time.Second
+)
+
+var (
+	// ErrQueueFull means the queue has queue-size pending jobs
+	ErrQueueFull = errors.New("worker queue is full")
+	// ErrStopped means the worker pool doesn't accept jobs anymore
+	ErrStopped = errors.New("worker pool is stopped")
+)
+
+// JobFunc is a job, ctx is done after the job timeout or when Stop gives up waiting
+type JobFunc func(ctx context.Context) error
+
+type jobConfig struct {
+	timeout time.Duration
+}
+
+type JobOption func(*jobConfig)
+
+// WithTimeout overrides the job-timeout flag for a job
+func WithTimeout(d time.Duration) JobOption {
+	return func(c *jobConfig) {
+		c.timeout = d
+	}
+}
+
+// Queue is returned by Get:
+//
+//	queue := service.MustGet("worker").(worker.Queue)
+//	err := queue.Enqueue(c.Request.Context(), "send-welcome-email", func(ctx context.Context) error {
+//		return mailer.Send(ctx, email)
+//	})
+type Queue interface {
+	// Enqueue adds a job without blocking, ErrQueueFull when the queue is full.
+	// The job keeps the values of ctx but not its cancellation, its span is linked to the span of ctx.
+	Enqueue(ctx context.Context, name string, fn JobFunc, opts ...JobOption) error
+}
+
+type job struct {
+	name     string
+	fn       JobFunc
+	ctx      context.Context
+	timeout  time.Duration
+	enqueued time.Time
+}
+
+type workerPool struct {
+	name   string
+	prefix string
+	logger logger.Logger
+
+	queueSize    int
+	concurrency  int
+	jobTimeout   time.Duration
+	drainTimeout time.Duration
+
+	mu        sync.RWMutex
+	jobs      chan job
+	stopped   bool
+	abandoned atomic.Int64
+	wg        sync.WaitGroup
+
+	// canceled when Stop gives up draining
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	duration metric.Float64Histogram
+	wait     metric.Float64Histogram
+	gauge    metric.Registration
+}
+
+func New(name, prefix string) *workerPool {
+	return &workerPool{name: name, prefix: prefix}
+}
+
+func (w *workerPool) Name() string {
+	return w.name
+}
+
+func (w *workerPool) GetPrefix() string {
+	return w.prefix
+}
+
+func (w *workerPool) Get() interface{} {
+	return Queue(w)
+}
+
+func (w *workerPool) InitFlags() {
+	w.InitFlagSet(flag.CommandLine)
+}
+
+func (w *workerPool) InitFlagSet(fs *flag.FlagSet) {
+	prefix := w.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.IntVar(&w.queueSize, prefix+"queue-size", defaultQueueSize, "max pending jobs, enqueue fails when the queue is full")
+	fs.IntVar(&w.concurrency, prefix+"concurrency", defaultConcurrency, "number of jobs running at the same time")
+	fs.DurationVar(&w.jobTimeout, prefix+"job-timeout", defaultJobTimeout, "default timeout of a job")
+	fs.DurationVar(&w.drainTimeout, prefix+"drain-timeout", defaultDrainTimeout, "max time to wait for the queued jobs when stopping, the rest are abandoned")
+}
+
+func (w *workerPool) Configure() error {
+	w.logger = logger.GetCurrent().GetLogger(w.name)
+
+	if w.queueSize < 0 {
+		return fmt.Errorf("invalid queue size %d, must not be negative", w.queueSize)
+	}
+	if w.concurrency <= 0 {
+		return fmt.Errorf("invalid concurrency %d, must be positive", w.concurrency)
+	}
+
+	meter := otel.Meter(meterName)
+
+	var err error
+	if w.duration, err = meter.Float64Histogram("worker.job.duration",
+		metric.WithDescription("Duration of the jobs"),
+		metric.WithUnit("s")); err != nil {
+		return err
+	}
+	if w.wait, err = meter.Float64Histogram("worker.job.wait",
+		metric.WithDescription("Time the jobs wait in the queue"),
+		metric.WithUnit("s")); err != nil {
+		return err
+	}
+
+	depth, err := meter.Int64ObservableGauge("worker.queue.depth",
+		metric.WithDescription("Number of jobs waiting in the queue"),
+		metric.WithUnit("{job}"))
+	if err != nil {
+		return err
+	}
+	w.gauge, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(depth, int64(w.depth()))
+		return nil
+	}, depth)
+	return err
+}
+
+// Run starts the workers, it doesn't block
+func (w *workerPool) Run() error {
+	if err := w.Configure(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.jobs = make(chan job, w.queueSize)
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.mu.Unlock()
+
+	w.wg.Add(w.concurrency)
+	for i := 0; i < w.concurrency; i++ {
+		go w.work()
+	}
+
+	return nil
+}
+
+func (w *workerPool) depth() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return len(w.jobs)
+}
+
+func (w *workerPool) Enqueue(ctx context.Context, name string, fn JobFunc, opts ...JobOption) error {
+	cfg := jobConfig{timeout: w.jobTimeout}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.stopped || w.jobs == nil {
+		return ErrStopped
+	}
+
+	select {
+	case w.jobs <- job{name: name, fn: fn, ctx: ctx, timeout: cfg.timeout, enqueued: time.Now()}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (w *workerPool) work() {
+	defer w.wg.Done()
+
+	for j := range w.jobs {
+		if w.ctx.Err() != nil {
+			w.abandoned.Add(1)
+			continue
+		}
+		w.run(j)
+	}
+}
+
+// run runs a job in a new trace linked to the span of the enqueuing request
+func (w *workerPool) run(j job) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if j.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(j.ctx), j.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.WithoutCancel(j.ctx))
+	}
+	defer cancel()
+	defer context.AfterFunc(w.ctx, cancel)()
+
+	spanOpts := []trace.SpanStartOption{trace.WithNewRoot(), trace.WithSpanKind(trace.SpanKindConsumer)}
+	if sc := trace.SpanContextFromContext(j.ctx); sc.IsValid() {
+		spanOpts = append(spanOpts, trace.WithLinks(trace.Link{SpanContext: sc}))
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "job "+j.name, spanOpts...)
+	defer span.End()
+
+	start := time.Now()
+	w.wait.Record(ctx, start.Sub(j.enqueued).Seconds(), metric.WithAttributes(attribute.String("job", j.name)))
+
+	err := safeRun(ctx, j.fn)
+
+	outcome := outcomeOK
+	switch {
+	case errors.As(err, new(panicError)):
+		outcome = outcomePanic
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		outcome = outcomeTimeout
+	case err != nil:
+		outcome = outcomeError
+	}
+
+	duration := time.Since(start)
+	w.duration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+		attribute.String("job", j.name),
+		attribute.String("outcome", outcome),
+	))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		w.logger.Withs(logger.Fields{"job": j.name, "outcome": outcome, "duration": duration.String()}).
+			Errorf("job failed: %s", err.Error())
+	}
+}
+
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e panicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.value, e.stack)
+}
+
+func safeRun(ctx context.Context, fn JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError{value: r, stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+// Stop stops the intake and waits for the queued jobs at most the drain timeout.
+// Then the context of the running jobs is canceled and the queued jobs are abandoned,
+// it sends false when jobs were abandoned or didn't return.
+func (w *workerPool) Stop() <-chan bool {
+	c := make(chan bool)
+
+	go func() {
+		w.mu.Lock()
+		if w.stopped || w.jobs == nil {
+			w.stopped = true
+			w.mu.Unlock()
+			c <- true
+			return
+		}
+		w.stopped = true
+		close(w.jobs)
+		w.mu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			w.wg.Wait()
+			close(done)
+		}()
+
+		finished := true
+		select {
+		case <-done:
+		case <-time.After(w.drainTimeout):
+			w.cancel()
+			// workers may be stuck in jobs ignoring their context
+			for range w.jobs {
+				w.abandoned.Add(1)
+			}
+			select {
+			case <-done:
+			case <-time.After(abandonGrace):
+				w.logger.Warn("running jobs didn't return after their context was canceled")
+			}
+			finished = false
+		}
+		w.cancel()
+
+		if abandoned := w.abandoned.Load(); abandoned > 0 {
+			w.logger.Warnf("%d queued jobs were abandoned after %s", abandoned, w.drainTimeout)
+		}
+		if w.gauge != nil {
+			_ = w.gauge.Unregister()
+		}
+
+		c <- finished
+	}()
+
+	return c
+}
+
+// Abandoned returns the number of queued jobs which didn't run because Stop gave up waiting
+func (w *workerPool) Abandoned() int64 {
+	return w.abandoned.Load()
+}
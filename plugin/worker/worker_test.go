@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestWorkerPool(queueSize, concurrency int) *workerPool {
+	logger.InitServLogger(false)
+
+	w := New("worker", "worker")
+	w.queueSize = queueSize
+	w.concurrency = concurrency
+	w.jobTimeout = time.Second
+	w.drainTimeout = time.Second
+	return w
+}
+
+func TestEnqueue(t *testing.T) {
+	w := newTestWorkerPool(10, 2)
+	assert.Equal(t, ErrStopped, w.Enqueue(context.Background(), "early", func(context.Context) error { return nil }), "should be equal")
+	assert.Nil(t, w.Run(), "must be nil")
+
+	var ran atomic.Int32
+	for i := 0; i < 5; i++ {
+		err := w.Enqueue(context.Background(), "count", func(context.Context) error {
+			ran.Add(1)
+			return nil
+		})
+		assert.Nil(t, err, "must be nil")
+	}
+
+	assert.True(t, <-w.Stop(), "should be true")
+	assert.Equal(t, int32(5), ran.Load(), "queued jobs must run before stop returns")
+	assert.Equal(t, ErrStopped, w.Enqueue(context.Background(), "late", func(context.Context) error { return nil }), "should be equal")
+}
+
+func TestQueueFull(t *testing.T) {
+	w := newTestWorkerPool(1, 1)
+	assert.Nil(t, w.Run(), "must be nil")
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	assert.Nil(t, w.Enqueue(context.Background(), "block", func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}), "must be nil")
+	<-started
+
+	assert.Nil(t, w.Enqueue(context.Background(), "queued", func(context.Context) error { return nil }), "must be nil")
+	assert.Equal(t, ErrQueueFull, w.Enqueue(context.Background(), "full", func(context.Context) error { return nil }), "should be equal")
+
+	close(release)
+	assert.True(t, <-w.Stop(), "should be true")
+}
+
+func TestJobTimeoutAndPanic(t *testing.T) {
+	w := newTestWorkerPool(10, 2)
+	assert.Nil(t, w.Run(), "must be nil")
+
+	timedOut := make(chan error, 1)
+	assert.Nil(t, w.Enqueue(context.Background(), "slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		timedOut <- ctx.Err()
+		return ctx.Err()
+	}, WithTimeout(20*time.Millisecond)), "must be nil")
+
+	assert.Nil(t, w.Enqueue(context.Background(), "panic", func(context.Context) error {
+		panic("boom")
+	}), "must be nil")
+
+	assert.Equal(t, context.DeadlineExceeded, <-timedOut, "should be equal")
+
+	// the worker survives the panic
+	done := make(chan struct{})
+	assert.Nil(t, w.Enqueue(context.Background(), "after", func(context.Context) error {
+		close(done)
+		return nil
+	}), "must be nil")
+	<-done
+
+	assert.True(t, <-w.Stop(), "should be true")
+}
+
+func TestJobKeepsValuesNotCancellation(t *testing.T) {
+	w := newTestWorkerPool(10, 1)
+	assert.Nil(t, w.Run(), "must be nil")
+
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "v"))
+
+	result := make(chan error, 1)
+	release := make(chan struct{})
+	assert.Nil(t, w.Enqueue(ctx, "detached", func(ctx context.Context) error {
+		<-release
+		if ctx.Value(key{}) != "v" {
+			result <- errors.New("value is lost")
+		}
+		result <- ctx.Err()
+		return nil
+	}), "must be nil")
+
+	cancel()
+	close(release)
+	assert.Nil(t, <-result, "job must not be canceled with the request")
+	assert.True(t, <-w.Stop(), "should be true")
+}
+
+func TestStopAbandons(t *testing.T) {
+	w := newTestWorkerPool(10, 1)
+	w.drainTimeout = 50 * time.Millisecond
+	assert.Nil(t, w.Run(), "must be nil")
+
+	canceled := make(chan struct{})
+	assert.Nil(t, w.Enqueue(context.Background(), "long", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	}, WithTimeout(time.Hour)), "must be nil")
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, w.Enqueue(context.Background(), "pending", func(context.Context) error { return nil }), "must be nil")
+	}
+
+	assert.False(t, <-w.Stop(), "should be false")
+	<-canceled
+	assert.Equal(t, int64(3), w.Abandoned(), "should be equal")
+}
+
+func TestJobSpanIsLinked(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+
+	w := newTestWorkerPool(10, 1)
+	assert.Nil(t, w.Run(), "must be nil")
+
+	ctx, requestSpan := tp.Tracer("test").Start(context.Background(), "request")
+	assert.Nil(t, w.Enqueue(ctx, "email", func(context.Context) error { return nil }), "must be nil")
+	requestSpan.End()
+
+	assert.True(t, <-w.Stop(), "should be true")
+
+	var jobSpan sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Name() == "job email" {
+			jobSpan = s
+		}
+	}
+	assert.NotNil(t, jobSpan, "should record the job span")
+	assert.NotEqual(t, requestSpan.SpanContext().TraceID(), jobSpan.SpanContext().TraceID(), "job should run in its own trace")
+	assert.Len(t, jobSpan.Links(), 1, "should be linked")
+	assert.Equal(t, requestSpan.SpanContext().SpanID(), jobSpan.Links()[0].SpanContext.SpanID(), "should be equal")
+}
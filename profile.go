@@ -0,0 +1,109 @@
+package goservice
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/taimaifika/go-sdk/plugin/otel"
+)
+
+const (
+	ProfileDev     = "dev"
+	ProfileStaging = "staging"
+	ProfileProd    = "prod"
+
+	profileEnvName = "APP_PROFILE"
+)
+
+// registered for every service created afterwards, copied by goservice.New()
+// flag name => profile name => default value
+var globalProfileDefaults = map[string]map[string]string{}
+
+// ProfileDefault registers per-profile default values for a flag.
+// The value of the active profile replaces the flag default, but a value
+// coming from env, config file or command line still wins.
+// It applies to every service created afterwards, see WithProfileDefault for one service.
+//
+// Ex: goservice.ProfileDefault("log-level", map[string]string{"dev": "debug", "prod": "info"})
+func ProfileDefault(flagName string, values map[string]string) {
+	addProfileDefault(globalProfileDefaults, flagName, values)
+}
+
+// WithProfileDefault registers per-profile default values for a flag of the service, see ProfileDefault
+func WithProfileDefault(flagName string, values map[string]string) Option {
+	return func(s *service) { addProfileDefault(s.profileDefaults, flagName, values) }
+}
+
+func addProfileDefault(defaults map[string]map[string]string, flagName string, values map[string]string) {
+	if _, ok := defaults[flagName]; !ok {
+		defaults[flagName] = map[string]string{}
+	}
+
+	for profile, value := range values {
+		defaults[flagName][profile] = value
+	}
+}
+
+// knownProfiles returns built-in profiles and the ones registered by ProfileDefault
+func (s *service) knownProfiles() []string {
+	known := map[string]struct{}{ProfileDev: {}, ProfileStaging: {}, ProfileProd: {}}
+
+	for _, values := range s.profileDefaults {
+		for profile := range values {
+			known[profile] = struct{}{}
+		}
+	}
+
+	result := make([]string, 0, len(known))
+	for profile := range known {
+		result = append(result, profile)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func (s *service) isKnownProfile(profile string) bool {
+	for _, p := range s.knownProfiles() {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// applyProfile sets defaults of the active profile to flags which have no value
+// from a source with higher precedence (command line, env...)
+func (s *service) applyProfile() error {
+	if s.flagSource("profile") == SourceDefault {
+		if p, ok := os.LookupEnv(profileEnvName); ok {
+			s.profile = p
+			s.flagSources["profile"] = SourceEnv
+		}
+	}
+
+	if s.profile == "" {
+		return nil
+	}
+
+	if !s.isKnownProfile(s.profile) {
+		return fmt.Errorf("unknown profile %q, known profiles: %s", s.profile, strings.Join(s.knownProfiles(), ", "))
+	}
+
+	for name, values := range s.profileDefaults {
+		value, ok := values[s.profile]
+		if !ok {
+			continue
+		}
+
+		if _, err := s.setFlag(name, value, SourceProfile); err != nil {
+			return fmt.Errorf("profile %s: cannot set default of flag %s: %w", s.profile, name, err)
+		}
+	}
+
+	otel.SetDeploymentEnvironment(s.profile)
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package goservice
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newProfileTestService(args ...string) (*service, *string) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	s := &service{
+		cmdLine:     newFlagSet("test", fs),
+		flagSources: map[string]ConfigSource{},
+		flagOwners:  map[string]string{},
+		flagAliases: map[string]string{},
+	}
+	s.initFlagMeta()
+
+	fs.StringVar(&s.profile, "profile", "", "")
+	level := fs.String("log-level", "trace", "")
+	_ = fs.Parse(args)
+	s.recordCLIFlags()
+
+	return s, level
+}
+
+func TestProfileDefault(t *testing.T) {
+	globalProfileDefaults = map[string]map[string]string{}
+	ProfileDefault("log-level", map[string]string{"dev": "debug", "prod": "info"})
+
+	for _, c := range []struct {
+		profile string
+		expect  string
+	}{
+		{profile: "", expect: "trace"},
+		{profile: "dev", expect: "debug"},
+		{profile: "prod", expect: "info"},
+		{profile: "staging", expect: "trace"},
+	} {
+		s, level := newProfileTestService("-profile=" + c.profile)
+		assert.Nil(t, s.applyProfile(), "must be nil")
+		assert.Equal(t, c.expect, *level, "should be equal")
+	}
+}
+
+func TestProfileExplicitOverride(t *testing.T) {
+	globalProfileDefaults = map[string]map[string]string{}
+	ProfileDefault("log-level", map[string]string{"prod": "info"})
+
+	s, level := newProfileTestService("-profile=prod", "-log-level=warn")
+	assert.Nil(t, s.applyProfile(), "must be nil")
+	assert.Equal(t, "warn", *level, "command line should win")
+
+	t.Setenv("LOG_LEVEL", "error")
+	s, level = newProfileTestService("-profile=prod")
+	assert.Nil(t, s.applyEnv(), "must be nil")
+	assert.Nil(t, s.applyProfile(), "must be nil")
+	assert.Equal(t, "error", *level, "env should win")
+}
+
+func TestProfileFromEnv(t *testing.T) {
+	globalProfileDefaults = map[string]map[string]string{}
+	ProfileDefault("log-level", map[string]string{"prod": "info"})
+
+	t.Setenv(profileEnvName, "prod")
+	s, level := newProfileTestService()
+	assert.Nil(t, s.applyProfile(), "must be nil")
+	assert.Equal(t, "prod", s.profile, "should be equal")
+	assert.Equal(t, "info", *level, "should be equal")
+}
+
+func TestUnknownProfile(t *testing.T) {
+	globalProfileDefaults = map[string]map[string]string{}
+	ProfileDefault("log-level", map[string]string{"qa": "debug"})
+
+	s, _ := newProfileTestService("-profile=prd")
+	err := s.applyProfile()
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "dev, prod, qa, staging")
+}
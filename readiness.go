@@ -0,0 +1,155 @@
+package goservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strategies of service-wait-for-ready
+const (
+	// servers listen right away, the readiness endpoint fails until components are ready
+	WaitForReadyReadiness = "readiness"
+	// servers only listen once components are ready
+	WaitForReadyListen = "listen"
+	// components are not waited for
+	WaitForReadyNone = "none"
+)
+
+const defaultStartupTimeout = 5 * time.Minute
+
+// ReadyChecker is optionally implemented by components which are not ready when Run returns,
+// e.g. connecting in background. Ready blocks until the component is ready or ctx is done.
+type ReadyChecker interface {
+	Ready(ctx context.Context) error
+}
+
+// readiness tracks the components which are not ready yet
+type readiness struct {
+	mu      sync.Mutex
+	pending map[string]struct{}
+}
+
+func (r *readiness) pendingNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.pending))
+	for name := range r.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *readiness) done(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, name)
+}
+
+// HealthCheck fails while components are pending, it's added to the readiness endpoint
+func (r *readiness) HealthCheck(context.Context) error {
+	if pending := r.pendingNames(); len(pending) > 0 {
+		return fmt.Errorf("waiting for %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+func validateWaitForReady(strategy string) error {
+	switch strategy {
+	case WaitForReadyReadiness, WaitForReadyListen, WaitForReadyNone:
+		return nil
+	}
+	return fmt.Errorf("invalid service-wait-for-ready %q, must be %s, %s or %s",
+		strategy, WaitForReadyReadiness, WaitForReadyListen, WaitForReadyNone)
+}
+
+// readyCheckers returns the components implementing ReadyChecker by name
+func (s *service) readyCheckers() map[string]ReadyChecker {
+	checkers := map[string]ReadyChecker{}
+	for _, r := range s.runnables() {
+		if rc, ok := r.(ReadyChecker); ok {
+			checkers[r.Name()] = rc
+		}
+	}
+	return checkers
+}
+
+// waitReady waits for all ReadyChecker components at most the startup timeout,
+// the error names the components which are still pending
+func (s *service) waitReady() error {
+	checkers := s.readyCheckers()
+	if len(checkers) == 0 {
+		return nil
+	}
+
+	timeout := s.startupTimeout
+	if timeout <= 0 {
+		timeout = defaultStartupTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// stop waiting when the service stops
+	go func() {
+		select {
+		case <-s.doneChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	errs := make([]error, 0, len(checkers))
+	errsMu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for name, rc := range checkers {
+		wg.Add(1)
+		go func(name string, rc ReadyChecker) {
+			defer wg.Done()
+			if err := rc.Ready(ctx); err != nil {
+				errsMu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				errsMu.Unlock()
+				return
+			}
+			s.readiness.done(name)
+			s.logger.Debugf("%s is ready", name)
+		}(name, rc)
+	}
+	wg.Wait()
+
+	if pending := s.readiness.pendingNames(); len(pending) > 0 {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("startup timeout, components not ready: %s", strings.Join(pending, ", "))
+		}
+		return fmt.Errorf("components not ready: %w", errors.Join(errs...))
+	}
+
+	s.logger.Info("all components are ready")
+	return nil
+}
+
+// initReadiness marks the ReadyChecker components pending, with the readiness strategy
+// the readiness endpoint fails until they are ready
+func (s *service) initReadiness() {
+	if s.waitForReady == WaitForReadyNone {
+		return
+	}
+
+	s.readiness.pending = map[string]struct{}{}
+	for name := range s.readyCheckers() {
+		s.readiness.pending[name] = struct{}{}
+	}
+
+	if s.waitForReady == WaitForReadyReadiness && len(s.readiness.pending) > 0 {
+		for _, hs := range s.allHTTPServers() {
+			hs.AddHealthCheck("startup", s.readiness.HealthCheck)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package goservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowRunnable is ready after delay
+type slowRunnable struct {
+	recordingRunnable
+	delay time.Duration
+}
+
+func (r *slowRunnable) Ready(ctx context.Context) error {
+	select {
+	case <-time.After(r.delay):
+		r.recorder.add(r.name + " ready")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newSlow(name string, delay time.Duration, rec *stopRecorder) *slowRunnable {
+	return &slowRunnable{recordingRunnable{fakeRunnable{name: name, prefix: name, recorder: rec}}, delay}
+}
+
+func TestWaitForReadyListen(t *testing.T) {
+	rec := &stopRecorder{}
+	s := newHookService(rec,
+		WithInitRunnable(newSlow("cache", 100*time.Millisecond, rec)),
+		WithInitRunnable(newSlow("queue", 50*time.Millisecond, rec)),
+		WithOnStarted(func(Service) error {
+			rec.add("on started")
+			return nil
+		}),
+	)
+	s.waitForReady = WaitForReadyListen
+	assert.Nil(t, s.Init(), "must be nil")
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- s.Start() }()
+
+	// the server doesn't listen before the components are ready
+	select {
+	case <-s.httpServer.Listening():
+		t.Fatal("should not listen before ready")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	assert.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.names) > 0 && rec.names[len(rec.names)-1] == "on started"
+	}, time.Second, 10*time.Millisecond, "on started should run")
+
+	assert.Nil(t, s.Shutdown(context.Background()), "must be nil")
+	assert.Nil(t, <-errChan, "must be nil")
+	// components of the same init level run concurrently
+	assert.ElementsMatch(t, []string{"db run", "cache run", "queue run"}, rec.names[:3], "should be equal")
+	assert.Equal(t, []string{"queue ready", "cache ready", "on started"}, rec.names[3:6], "should be equal")
+}
+
+func TestWaitForReadyReadiness(t *testing.T) {
+	rec := &stopRecorder{}
+	s := newHookService(rec, WithInitRunnable(newSlow("cache", 200*time.Millisecond, rec)))
+	assert.Nil(t, s.Init(), "must be nil")
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- s.Start() }()
+	<-s.httpServer.Listening()
+
+	url := "http://" + s.httpServer.URI() + "/readyz"
+	resp, err := http.Get(url)
+	assert.Nil(t, err, "must be nil")
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "should not be ready")
+
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 20*time.Millisecond, "should be ready")
+
+	assert.Nil(t, s.Shutdown(context.Background()), "must be nil")
+	assert.Nil(t, <-errChan, "must be nil")
+}
+
+func TestStartupTimeout(t *testing.T) {
+	rec := &stopRecorder{}
+	s := newHookService(rec,
+		WithInitRunnable(newSlow("cache", time.Hour, rec)),
+		WithInitRunnable(newSlow("queue", 10*time.Millisecond, rec)),
+	)
+	s.startupTimeout = 50 * time.Millisecond
+	assert.Nil(t, s.Init(), "must be nil")
+
+	select {
+	case err := <-startAsync(s):
+		assert.Contains(t, err.Error(), "startup timeout, components not ready: cache", "should be equal")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start should fail")
+	}
+}
+
+func startAsync(s *service) <-chan error {
+	errChan := make(chan error, 1)
+	go func() { errChan <- s.Start() }()
+	return errChan
+}
@@ -0,0 +1,63 @@
+package goservice
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reloadHTTPServer sets the flags of the http servers in the config file
+// again, then reloads the servers without dropping requests. The keys removed from the file
+// keep their value.
+func (s *service) reloadHTTPServer() error {
+	if s.configFile != "" {
+		values, err := readConfigFile(s.configFile)
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+		for _, name := range sortedKeys(values) {
+			if !s.isHTTPServerFlag(name) || s.cmdLine.Lookup(name) == nil {
+				continue
+			}
+			if _, err := s.setFlag(name, values[name], SourceConfigFile); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q of %s in config file %s: %v", s.maskFlagValue(s.cmdLine.Lookup(name), values[name]), name, s.configFile, err))
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+	}
+
+	for _, hs := range s.allHTTPServers() {
+		if err := hs.Reload(hs.GetConfig()); err != nil {
+			return err
+		}
+	}
+	s.logger.Info("http server is reloaded")
+	return nil
+}
+
+func (s *service) isHTTPServerFlag(name string) bool {
+	for _, prefix := range s.httpServerPrefixes() {
+		if strings.HasPrefix(name, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadHandler reloads the http server like SIGHUP
+func (s *service) reloadHandler(engine *gin.Engine) {
+	engine.POST("/admin/reload", func(c *gin.Context) {
+		if err := s.reloadHTTPServer(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"reloaded": true})
+	})
+}
@@ -0,0 +1,42 @@
+package goservice
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver"
+)
+
+type reloadRecorder struct {
+	HttpServer
+	reloads int
+}
+
+func (r *reloadRecorder) GetConfig() httpserver.Config {
+	return httpserver.Config{}
+}
+
+func (r *reloadRecorder) Reload(httpserver.Config) error {
+	r.reloads++
+	return nil
+}
+
+func TestReloadHTTPServer(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "gin:\n  port: 8080\n")
+	s := newConfigTestService(path, "-gin-shutdown-timeout=5s")
+	assert.Nil(t, s.applyConfigFile(), "must be nil")
+	hs := &reloadRecorder{}
+	s.httpServer = hs
+
+	assert.Nil(t, os.WriteFile(path, []byte("gin:\n  port: 9090\n  shutdown-timeout: 1m\nlog-level: debug\n"), 0o600), "must be nil")
+	assert.Nil(t, s.reloadHTTPServer(), "must be nil")
+	assert.Equal(t, 1, hs.reloads, "should be reloaded")
+	assert.Equal(t, "9090", flagValue(s, "gin-port"), "should be equal")
+	assert.Equal(t, "5s", flagValue(s, "gin-shutdown-timeout"), "command line should win")
+	assert.Equal(t, "info", flagValue(s, "log-level"), "only the gin flags should be reloaded")
+
+	assert.Nil(t, os.WriteFile(path, []byte("gin:\n  port: abc\n"), 0o600), "must be nil")
+	assert.NotNil(t, s.reloadHTTPServer(), "should be an error")
+	assert.Equal(t, 1, hs.reloads, "should not be reloaded")
+}
@@ -0,0 +1,246 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen is returned without calling while the breaker is open
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+type BreakerConfig struct {
+	// outcomes of the last calls the rates are computed on, 0 => disabled
+	WindowSize int `json:"window_size"`
+	// the breaker doesn't open before the window has this many calls
+	MinCalls int `json:"min_calls"`
+	// failed calls rate opening the breaker, from 0 to 1
+	FailureRate float64 `json:"failure_rate"`
+	// calls slower than this are slow, 0 => not tracked
+	SlowCall time.Duration `json:"slow_call"`
+	// slow calls rate opening the breaker, from 0 to 1
+	SlowCallRate float64 `json:"slow_call_rate"`
+	// time the breaker rejects calls before letting trial ones through
+	OpenTimeout time.Duration `json:"open_timeout"`
+	// successful trial calls closing the breaker, a failed or slow one opens it again
+	HalfOpenCalls int `json:"half_open_calls"`
+	// nil counts every error but context.Canceled and ErrBulkheadFull
+	IsFailure func(error) bool `json:"-"`
+}
+
+func (cfg BreakerConfig) Validate() error {
+	if cfg.WindowSize == 0 {
+		return nil
+	}
+	if cfg.WindowSize < 0 || cfg.MinCalls < 1 || cfg.MinCalls > cfg.WindowSize {
+		return fmt.Errorf("breaker: min calls must be between 1 and the window size %d", cfg.WindowSize)
+	}
+	if cfg.FailureRate <= 0 || cfg.FailureRate > 1 || (cfg.SlowCall > 0 && (cfg.SlowCallRate <= 0 || cfg.SlowCallRate > 1)) {
+		return errors.New("breaker: failure and slow call rates must be in (0, 1]")
+	}
+	if cfg.SlowCall < 0 || cfg.OpenTimeout <= 0 || cfg.HalfOpenCalls < 1 {
+		return errors.New("breaker: open timeout and half open calls must be positive")
+	}
+	return nil
+}
+
+func (cfg BreakerConfig) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if cfg.IsFailure != nil {
+		return cfg.IsFailure(err)
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, ErrBulkheadFull)
+}
+
+type outcome struct {
+	failure bool
+	slow    bool
+}
+
+// Breaker stops calling a failing dependency: it opens when the failure or slow
+// call rate of the last calls reaches its threshold, rejects calls until the open
+// timeout, then lets trial calls through to close or open again.
+type Breaker struct {
+	name  string
+	cfg   BreakerConfig
+	clock Clock
+	m     *instruments
+
+	mu    sync.Mutex
+	state State
+	// changes with the state, outcomes of calls allowed in a previous state are ignored
+	generation uint64
+	// ring of the last outcomes
+	outcomes []outcome
+	next     int
+	calls    int
+	failures int
+	slow     int
+	openedAt time.Time
+	// calls let through and succeeded while half-open
+	trials    int
+	successes int
+}
+
+func NewBreaker(name string, cfg BreakerConfig, opts ...Option) (*Breaker, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.WindowSize == 0 {
+		return nil, errors.New("breaker: window size must be positive")
+	}
+
+	o := newOptions(opts)
+	b := &Breaker{name: name, cfg: cfg, clock: o.clock, m: newInstruments(), outcomes: make([]outcome, cfg.WindowSize)}
+	b.m.recordState(context.Background(), name, StateClosed)
+	return b, nil
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Execute calls fn unless the breaker is open, then returns ErrBreakerOpen
+func (b *Breaker) Execute(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	generation, err := b.allow(ctx)
+	if err != nil {
+		return err
+	}
+
+	start := b.clock.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			b.record(ctx, generation, fmt.Errorf("panic: %v", p), b.clock.Now().Sub(start))
+			panic(p)
+		}
+		b.record(ctx, generation, err, b.clock.Now().Sub(start))
+	}()
+	return fn(ctx)
+}
+
+func (b *Breaker) allow(ctx context.Context) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			b.m.reject(ctx, b.name, reasonBreakerOpen)
+			return 0, fmt.Errorf("%w: %s", ErrBreakerOpen, b.name)
+		}
+		b.transition(ctx, StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.trials >= b.cfg.HalfOpenCalls {
+			b.m.reject(ctx, b.name, reasonBreakerOpen)
+			return 0, fmt.Errorf("%w: %s", ErrBreakerOpen, b.name)
+		}
+		b.trials++
+	}
+	return b.generation, nil
+}
+
+func (b *Breaker) record(ctx context.Context, generation uint64, err error, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	o := outcome{failure: b.cfg.isFailure(err), slow: b.cfg.SlowCall > 0 && d >= b.cfg.SlowCall}
+
+	switch b.state {
+	case StateClosed:
+		b.push(o)
+		if b.calls < b.cfg.MinCalls {
+			return
+		}
+		if rate(b.failures, b.calls) >= b.cfg.FailureRate ||
+			(b.cfg.SlowCall > 0 && rate(b.slow, b.calls) >= b.cfg.SlowCallRate) {
+			b.transition(ctx, StateOpen)
+		}
+	case StateHalfOpen:
+		if o.failure || o.slow {
+			b.transition(ctx, StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenCalls {
+			b.transition(ctx, StateClosed)
+		}
+	}
+}
+
+// push adds o to the window, replacing the oldest outcome when it's full
+func (b *Breaker) push(o outcome) {
+	if b.calls == len(b.outcomes) {
+		old := b.outcomes[b.next]
+		b.calls--
+		if old.failure {
+			b.failures--
+		}
+		if old.slow {
+			b.slow--
+		}
+	}
+
+	b.outcomes[b.next] = o
+	b.next = (b.next + 1) % len(b.outcomes)
+	b.calls++
+	if o.failure {
+		b.failures++
+	}
+	if o.slow {
+		b.slow++
+	}
+}
+
+// transition changes the state and starts over with an empty window, b.mu is held
+func (b *Breaker) transition(ctx context.Context, to State) {
+	from := b.state
+	b.state = to
+	b.generation++
+	b.next, b.calls, b.failures, b.slow = 0, 0, 0, 0
+	b.trials, b.successes = 0, 0
+	if to == StateOpen {
+		b.openedAt = b.clock.Now()
+	}
+
+	b.m.recordState(ctx, b.name, to)
+	logTransition(b.name, from, to)
+}
+
+func rate(n, total int) float64 {
+	return float64(n) / float64(total)
+}
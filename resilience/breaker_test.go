@@ -0,0 +1,213 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var errDown = errors.New("dependency is down")
+
+// fakeClock only moves with Advance, which fires the timers it passes
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return t.c
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+}
+
+// waiters returns the timers not fired yet
+func (c *fakeClock) waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func testBreakerConfig() BreakerConfig {
+	return BreakerConfig{WindowSize: 4, MinCalls: 4, FailureRate: 0.5, SlowCall: time.Second, SlowCallRate: 0.75, OpenTimeout: 10 * time.Second, HalfOpenCalls: 2}
+}
+
+func call(b *Breaker, err error) error {
+	return b.Execute(context.Background(), func(context.Context) error { return err })
+}
+
+func TestBreaker(t *testing.T) {
+	clock := newFakeClock()
+	b, err := NewBreaker("payments", testBreakerConfig(), WithClock(clock))
+	assert.Nil(t, err, "must be nil")
+
+	// 1 failure of 4 calls
+	for _, err := range []error{nil, errDown, nil, nil} {
+		_ = call(b, err)
+	}
+	assert.Equal(t, StateClosed, b.State(), "should be equal")
+
+	// the window slides: nil, nil, errDown, errDown
+	_ = call(b, errDown)
+	assert.Equal(t, StateOpen, b.State(), "should open at 50% failures")
+
+	assert.ErrorIs(t, call(b, nil), ErrBreakerOpen, "should be rejected")
+	clock.Advance(9 * time.Second)
+	assert.ErrorIs(t, call(b, nil), ErrBreakerOpen, "should be rejected until the open timeout")
+
+	// a failed trial opens it again
+	clock.Advance(time.Second)
+	assert.ErrorIs(t, call(b, errDown), errDown, "should be called")
+	assert.Equal(t, StateOpen, b.State(), "should be equal")
+
+	clock.Advance(10 * time.Second)
+	assert.Nil(t, call(b, nil), "must be nil")
+	assert.Equal(t, StateHalfOpen, b.State(), "should be equal")
+	assert.Nil(t, call(b, nil), "must be nil")
+	assert.Equal(t, StateClosed, b.State(), "should close after the trial calls")
+
+	// canceled and bulkhead rejected calls are not failures
+	for i := 0; i < 4; i++ {
+		_ = call(b, context.Canceled)
+		_ = call(b, ErrBulkheadFull)
+	}
+	assert.Equal(t, StateClosed, b.State(), "should be equal")
+}
+
+func TestBreakerHalfOpenLimit(t *testing.T) {
+	clock := newFakeClock()
+	b, _ := NewBreaker("payments", testBreakerConfig(), WithClock(clock))
+	for i := 0; i < 4; i++ {
+		_ = call(b, errDown)
+	}
+	clock.Advance(10 * time.Second)
+
+	// 2 trials in flight, the third is rejected
+	for i := 0; i < 2; i++ {
+		_, err := b.allow(context.Background())
+		assert.Nil(t, err, "must be nil")
+	}
+	assert.ErrorIs(t, call(b, nil), ErrBreakerOpen, "should be rejected")
+}
+
+func TestBreakerSlowCalls(t *testing.T) {
+	clock := newFakeClock()
+	b, _ := NewBreaker("search", testBreakerConfig(), WithClock(clock))
+
+	slow := func(context.Context) error {
+		clock.Advance(2 * time.Second)
+		return nil
+	}
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, b.Execute(context.Background(), slow), "must be nil")
+	}
+	assert.Equal(t, StateClosed, b.State(), "should be equal")
+	assert.Nil(t, b.Execute(context.Background(), slow), "must be nil")
+	assert.Equal(t, StateOpen, b.State(), "should open at 75% slow calls")
+}
+
+func TestBreakerStaleOutcomes(t *testing.T) {
+	clock := newFakeClock()
+	b, _ := NewBreaker("payments", testBreakerConfig(), WithClock(clock))
+
+	// allowed while closed, done once it's open
+	generation, _ := b.allow(context.Background())
+	for i := 0; i < 4; i++ {
+		_ = call(b, errDown)
+	}
+	clock.Advance(10 * time.Second)
+	_ = call(b, nil)
+	b.record(context.Background(), generation, errDown, 0)
+	assert.Equal(t, StateHalfOpen, b.State(), "stale outcomes should be ignored")
+}
+
+func TestBreakerMetrics(t *testing.T) {
+	reader := metric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(metric.NewMeterProvider(metric.WithReader(reader)))
+	defer otel.SetMeterProvider(prev)
+
+	b, _ := NewBreaker("payments", testBreakerConfig(), WithClock(newFakeClock()))
+	for i := 0; i < 6; i++ {
+		_ = call(b, errDown)
+	}
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm), "must be nil")
+
+	metrics := map[string]metricdata.Metrics{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	state := metrics["resilience.breaker.state"].Data.(metricdata.Gauge[int64]).DataPoints
+	assert.Len(t, state, 1, "should be equal")
+	assert.Equal(t, int64(StateOpen), state[0].Value, "should be equal")
+	name, _ := state[0].Attributes.Value("name")
+	assert.Equal(t, attribute.StringValue("payments"), name, "should be equal")
+
+	rejected := metrics["resilience.rejected.calls"].Data.(metricdata.Sum[int64]).DataPoints
+	assert.Len(t, rejected, 1, "should be equal")
+	assert.Equal(t, int64(2), rejected[0].Value, "should be equal")
+	reason, _ := rejected[0].Attributes.Value("reason")
+	assert.Equal(t, attribute.StringValue(reasonBreakerOpen), reason, "should be equal")
+}
+
+func TestBreakerConfigValidate(t *testing.T) {
+	assert.Nil(t, testBreakerConfig().Validate(), "must be nil")
+	assert.Nil(t, BreakerConfig{}.Validate(), "disabled breakers should be valid")
+
+	for _, update := range []func(*BreakerConfig){
+		func(c *BreakerConfig) { c.MinCalls = 5 },
+		func(c *BreakerConfig) { c.FailureRate = 0 },
+		func(c *BreakerConfig) { c.SlowCallRate = 1.5 },
+		func(c *BreakerConfig) { c.OpenTimeout = 0 },
+		func(c *BreakerConfig) { c.HalfOpenCalls = 0 },
+	} {
+		cfg := testBreakerConfig()
+		update(&cfg)
+		assert.NotNil(t, cfg.Validate(), "should be an error")
+	}
+}
@@ -0,0 +1,108 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBulkheadFull is returned without calling when every slot is taken and the
+// wait queue is full, or the call waited too long
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+type BulkheadConfig struct {
+	// concurrent calls, 0 => unlimited
+	MaxConcurrent int `json:"max_concurrent"`
+	// calls waiting for a slot, further ones are rejected right away
+	MaxWaiting int `json:"max_waiting"`
+	// max time waited for a slot, 0 => until the context is done
+	MaxWait time.Duration `json:"max_wait"`
+}
+
+func (cfg BulkheadConfig) Validate() error {
+	if cfg.MaxConcurrent < 0 || cfg.MaxWaiting < 0 || cfg.MaxWait < 0 {
+		return errors.New("bulkhead: max concurrent, max waiting and max wait must not be negative")
+	}
+	return nil
+}
+
+// Bulkhead limits the concurrent calls to a dependency, so a slow one can't take
+// every goroutine or connection of the service
+type Bulkhead struct {
+	name    string
+	cfg     BulkheadConfig
+	clock   Clock
+	m       *instruments
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+func NewBulkhead(name string, cfg BulkheadConfig, opts ...Option) (*Bulkhead, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.MaxConcurrent == 0 {
+		return nil, errors.New("bulkhead: max concurrent must be positive")
+	}
+
+	o := newOptions(opts)
+	return &Bulkhead{name: name, cfg: cfg, clock: o.clock, m: newInstruments(), slots: make(chan struct{}, cfg.MaxConcurrent)}, nil
+}
+
+func (bh *Bulkhead) Name() string {
+	return bh.name
+}
+
+// InFlight returns the calls holding a slot
+func (bh *Bulkhead) InFlight() int {
+	return len(bh.slots)
+}
+
+// Acquire takes a slot, waiting for one if the queue isn't full. release must
+// be called once the call is done.
+func (bh *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	release = func() { <-bh.slots }
+
+	select {
+	case bh.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if bh.waiting.Add(1) > int64(bh.cfg.MaxWaiting) {
+		bh.waiting.Add(-1)
+		return nil, bh.reject(ctx)
+	}
+	defer bh.waiting.Add(-1)
+
+	var timeout <-chan time.Time
+	if bh.cfg.MaxWait > 0 {
+		timeout = bh.clock.After(bh.cfg.MaxWait)
+	}
+
+	select {
+	case bh.slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		return nil, bh.reject(ctx)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Execute calls fn with a slot
+func (bh *Bulkhead) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	release, err := bh.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(ctx)
+}
+
+func (bh *Bulkhead) reject(ctx context.Context) error {
+	bh.m.reject(ctx, bh.name, reasonBulkheadFull)
+	return fmt.Errorf("%w: %s", ErrBulkheadFull, bh.name)
+}
@@ -0,0 +1,79 @@
+package resilience
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkhead(t *testing.T) {
+	clock := newFakeClock()
+	bh, err := NewBulkhead("search", BulkheadConfig{MaxConcurrent: 2, MaxWaiting: 1, MaxWait: time.Second}, WithClock(clock))
+	assert.Nil(t, err, "must be nil")
+
+	ctx := context.Background()
+	release1, err := bh.Acquire(ctx)
+	assert.Nil(t, err, "must be nil")
+	release2, err := bh.Acquire(ctx)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, 2, bh.InFlight(), "should be equal")
+
+	// waits for a slot
+	acquired := make(chan error)
+	go func() {
+		release, err := bh.Acquire(ctx)
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	assert.Eventually(t, func() bool { return clock.waiters() == 1 }, time.Second, time.Millisecond, "should wait")
+
+	// the queue is full
+	_, err = bh.Acquire(ctx)
+	assert.ErrorIs(t, err, ErrBulkheadFull, "should be rejected")
+
+	release1()
+	assert.Nil(t, <-acquired, "must be nil")
+
+	// waits too long
+	release1, err = bh.Acquire(ctx)
+	assert.Nil(t, err, "must be nil")
+	go func() {
+		_, err := bh.Acquire(ctx)
+		acquired <- err
+	}()
+	assert.Eventually(t, func() bool { return clock.waiters() == 2 }, time.Second, time.Millisecond, "should wait")
+	clock.Advance(time.Second)
+	assert.ErrorIs(t, <-acquired, ErrBulkheadFull, "should be rejected after max wait")
+
+	// the context is done first
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = bh.Acquire(canceled)
+	assert.ErrorIs(t, err, context.Canceled, "should be canceled")
+
+	release2()
+	assert.Equal(t, 1, bh.InFlight(), "should be equal")
+}
+
+func TestBulkheadExecute(t *testing.T) {
+	bh, _ := NewBulkhead("search", BulkheadConfig{MaxConcurrent: 1})
+
+	err := bh.Execute(context.Background(), func(ctx context.Context) error {
+		assert.Equal(t, 1, bh.InFlight(), "should be equal")
+		_, err := bh.Acquire(ctx)
+		return err
+	})
+	assert.ErrorIs(t, err, ErrBulkheadFull, "should be rejected without waiting queue")
+	assert.Equal(t, 0, bh.InFlight(), "should be released")
+}
+
+func TestBulkheadConfigValidate(t *testing.T) {
+	assert.Nil(t, BulkheadConfig{}.Validate(), "must be nil")
+	assert.NotNil(t, BulkheadConfig{MaxConcurrent: -1}.Validate(), "should be an error")
+	_, err := NewBulkhead("search", BulkheadConfig{})
+	assert.NotNil(t, err, "should be an error")
+}
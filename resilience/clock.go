@@ -0,0 +1,33 @@
+package resilience
+
+import "time"
+
+// Clock tells the time of breakers, bulkheads and retries, tests use a fake one
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type options struct {
+	clock Clock
+}
+
+type Option func(*options)
+
+// WithClock replaces the clock, e.g. with a fake one in tests
+func WithClock(c Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: realClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
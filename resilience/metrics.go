@@ -0,0 +1,65 @@
+package resilience
+
+import (
+	"context"
+
+	"github.com/taimaifika/go-sdk/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	meterName = "github.com/taimaifika/go-sdk/resilience"
+
+	reasonBreakerOpen  = "breaker_open"
+	reasonBulkheadFull = "bulkhead_full"
+)
+
+type instruments struct {
+	state    metric.Int64Gauge
+	rejected metric.Int64Counter
+}
+
+func newInstruments() *instruments {
+	meter := otel.Meter(meterName)
+
+	state, err := meter.Int64Gauge("resilience.breaker.state",
+		metric.WithDescription("State of the circuit breakers: 0 closed, 1 open, 2 half-open"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	rejected, err := meter.Int64Counter("resilience.rejected.calls",
+		metric.WithDescription("Number of calls rejected by open circuit breakers and full bulkheads"),
+		metric.WithUnit("{call}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return &instruments{state: state, rejected: rejected}
+}
+
+func (m *instruments) recordState(ctx context.Context, name string, s State) {
+	m.state.Record(ctx, int64(s), metric.WithAttributes(attribute.String("name", name)))
+}
+
+// reject counts the rejected call and adds an event to its span
+func (m *instruments) reject(ctx context.Context, name, reason string) {
+	attrs := []attribute.KeyValue{attribute.String("name", name), attribute.String("reason", reason)}
+	m.rejected.Add(ctx, 1, metric.WithAttributes(attrs...))
+	trace.SpanFromContext(ctx).AddEvent("resilience.rejected", trace.WithAttributes(attrs...))
+}
+
+func logTransition(name string, from, to State) {
+	current := logger.GetCurrent()
+	if current == nil {
+		return
+	}
+
+	log := current.GetLogger("resilience").Withs(logger.Fields{"breaker": name, "from": from.String(), "to": to.String()})
+	if to == StateOpen {
+		log.Warnf("circuit breaker %s opened", name)
+		return
+	}
+	log.Infof("circuit breaker %s is %s", name, to.String())
+}
@@ -0,0 +1,139 @@
+package resilience
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// plugin makes the registry of Do from its flags, with per name overrides:
+//
+//	goservice.WithInitRunnable(resilience.New("resilience", "resilience"))
+//	registry := sc.MustGet("resilience").(*resilience.Registry)
+type plugin struct {
+	name   string
+	prefix string
+
+	defaults Config
+	// name:setting=value,...;name:... parsed by Configure
+	overrides string
+	registry  *Registry
+}
+
+func New(name, prefix string) *plugin {
+	return &plugin{name: name, prefix: prefix, defaults: DefaultConfig()}
+}
+
+func (p *plugin) Name() string {
+	return p.name
+}
+
+func (p *plugin) GetPrefix() string {
+	return p.prefix
+}
+
+// Get returns the *Registry, nil before the plugin runs
+func (p *plugin) Get() interface{} {
+	return p.registry
+}
+
+func (p *plugin) InitFlags() {
+	p.InitFlagSet(flag.CommandLine)
+}
+
+func (p *plugin) InitFlagSet(fs *flag.FlagSet) {
+	prefix := p.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	bindFlags(fs, prefix, &p.defaults)
+	fs.StringVar(&p.overrides, prefix+"overrides", "", "settings of dependency names overriding the defaults, the flags above without prefix. Ex: payments:retry-max=0,breaker-failure-rate=0.3;search:bulkhead-max-concurrent=20")
+}
+
+// bindFlags binds the settings of cfg to fs, the current values are the defaults
+func bindFlags(fs *flag.FlagSet, prefix string, cfg *Config) {
+	fs.IntVar(&cfg.Breaker.WindowSize, prefix+"breaker-window", cfg.Breaker.WindowSize, "last calls the failure and slow call rates of the breaker are computed on, 0 => breaker disabled")
+	fs.IntVar(&cfg.Breaker.MinCalls, prefix+"breaker-min-calls", cfg.Breaker.MinCalls, "calls of the window before the breaker can open")
+	fs.Float64Var(&cfg.Breaker.FailureRate, prefix+"breaker-failure-rate", cfg.Breaker.FailureRate, "failed calls rate opening the breaker, from 0 to 1")
+	fs.DurationVar(&cfg.Breaker.SlowCall, prefix+"breaker-slow-call", cfg.Breaker.SlowCall, "calls slower than this are slow, 0 => not tracked")
+	fs.Float64Var(&cfg.Breaker.SlowCallRate, prefix+"breaker-slow-call-rate", cfg.Breaker.SlowCallRate, "slow calls rate opening the breaker, from 0 to 1")
+	fs.DurationVar(&cfg.Breaker.OpenTimeout, prefix+"breaker-open-timeout", cfg.Breaker.OpenTimeout, "time an open breaker rejects calls before trial ones")
+	fs.IntVar(&cfg.Breaker.HalfOpenCalls, prefix+"breaker-half-open-calls", cfg.Breaker.HalfOpenCalls, "successful trial calls closing the breaker")
+	fs.IntVar(&cfg.Bulkhead.MaxConcurrent, prefix+"bulkhead-max-concurrent", cfg.Bulkhead.MaxConcurrent, "max concurrent calls of a dependency, 0 => unlimited")
+	fs.IntVar(&cfg.Bulkhead.MaxWaiting, prefix+"bulkhead-max-waiting", cfg.Bulkhead.MaxWaiting, "calls waiting for a bulkhead slot, further ones are rejected")
+	fs.DurationVar(&cfg.Bulkhead.MaxWait, prefix+"bulkhead-max-wait", cfg.Bulkhead.MaxWait, "max time waited for a bulkhead slot, 0 => until the context is done")
+	fs.IntVar(&cfg.Retry.MaxRetries, prefix+"retry-max", cfg.Retry.MaxRetries, "max retries of failed calls, rejected calls are not retried")
+	fs.DurationVar(&cfg.Retry.Backoff, prefix+"retry-backoff", cfg.Retry.Backoff, "base backoff between retries, doubled on each retry with full jitter")
+	fs.DurationVar(&cfg.Retry.MaxBackoff, prefix+"retry-max-backoff", cfg.Retry.MaxBackoff, "max backoff between retries")
+}
+
+// parseOverrides returns the configs of the names of s, the settings they
+// don't have are the defaults
+func parseOverrides(s string, defaults Config) (map[string]Config, error) {
+	configs := map[string]Config{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, settings, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid resilience override %q, must be name:setting=value,...", entry)
+		}
+
+		cfg := defaults
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		bindFlags(fs, "", &cfg)
+		for _, setting := range strings.Split(settings, ",") {
+			k, v, ok := strings.Cut(strings.TrimSpace(setting), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid resilience override %q of %s, must be setting=value", setting, name)
+			}
+			if err := fs.Set(strings.TrimSpace(k), strings.TrimSpace(v)); err != nil {
+				return nil, fmt.Errorf("invalid resilience override %s of %s: %w", setting, name, err)
+			}
+		}
+		configs[name] = cfg
+	}
+	return configs, nil
+}
+
+func (p *plugin) Configure() error {
+	if p.registry != nil {
+		return nil
+	}
+
+	registry, err := NewRegistry(p.defaults)
+	if err != nil {
+		return fmt.Errorf("invalid %s config: %w", p.name, err)
+	}
+
+	overrides, err := parseOverrides(p.overrides, p.defaults)
+	if err != nil {
+		return err
+	}
+	for name, cfg := range overrides {
+		if err := registry.Configure(name, cfg); err != nil {
+			return fmt.Errorf("invalid %s config: %w", p.name, err)
+		}
+	}
+
+	p.registry = registry
+	SetDefault(registry)
+	return nil
+}
+
+func (p *plugin) Run() error {
+	return p.Configure()
+}
+
+func (p *plugin) Stop() <-chan bool {
+	c := make(chan bool)
+	go func() { c <- true }()
+	return c
+}
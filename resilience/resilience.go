@@ -0,0 +1,264 @@
+// Package resilience protects the calls to dependencies with a circuit breaker,
+// a bulkhead and retries, configured per dependency name:
+//
+//	err := resilience.Do(ctx, "payments", func(ctx context.Context) error {
+//		return payments.Charge(ctx, order)
+//	})
+//
+// The calls are retried around the breaker, which is around the bulkhead.
+// Rejected calls return ErrBreakerOpen or ErrBulkheadFull and are not retried.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type RetryConfig struct {
+	// retries after the first attempt, 0 => none
+	MaxRetries int `json:"max_retries"`
+	// base backoff between retries, doubled on each retry with full jitter
+	Backoff    time.Duration `json:"backoff"`
+	MaxBackoff time.Duration `json:"max_backoff"`
+	// nil retries every error but the rejected calls and the context errors
+	Retryable func(error) bool `json:"-"`
+}
+
+func (cfg RetryConfig) Validate() error {
+	if cfg.MaxRetries < 0 || cfg.Backoff < 0 || cfg.MaxBackoff < 0 {
+		return errors.New("retry: max retries and backoffs must not be negative")
+	}
+	return nil
+}
+
+func (cfg RetryConfig) retryable(err error) bool {
+	if errors.Is(err, ErrBreakerOpen) || errors.Is(err, ErrBulkheadFull) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if cfg.Retryable != nil {
+		return cfg.Retryable(err)
+	}
+	return true
+}
+
+// backoff is the wait before the retry following attempt, with full jitter
+func (cfg RetryConfig) backoff(attempt int) time.Duration {
+	backoff := min(cfg.Backoff<<attempt, cfg.MaxBackoff)
+	if backoff <= 0 {
+		return 0
+	}
+	return rand.N(backoff + 1)
+}
+
+// Config of the calls to a dependency, zero window size, max concurrent or
+// max retries disable the breaker, the bulkhead or the retries
+type Config struct {
+	Breaker  BreakerConfig  `json:"breaker"`
+	Bulkhead BulkheadConfig `json:"bulkhead"`
+	Retry    RetryConfig    `json:"retry"`
+}
+
+// DefaultConfig opens the breaker at 50% failures of the last 20 calls for 30s,
+// retries twice and doesn't limit the concurrent calls
+func DefaultConfig() Config {
+	return Config{
+		Breaker: BreakerConfig{
+			WindowSize:    20,
+			MinCalls:      10,
+			FailureRate:   0.5,
+			SlowCallRate:  0.5,
+			OpenTimeout:   30 * time.Second,
+			HalfOpenCalls: 3,
+		},
+		Retry: RetryConfig{
+			MaxRetries: 2,
+			Backoff:    100 * time.Millisecond,
+			MaxBackoff: 2 * time.Second,
+		},
+	}
+}
+
+func (cfg Config) Validate() error {
+	return errors.Join(cfg.Breaker.Validate(), cfg.Bulkhead.Validate(), cfg.Retry.Validate())
+}
+
+// Policy combines the breaker, the bulkhead and the retries of a dependency
+type Policy struct {
+	name     string
+	cfg      Config
+	clock    Clock
+	breaker  *Breaker
+	bulkhead *Bulkhead
+}
+
+func NewPolicy(name string, cfg Config, opts ...Option) (*Policy, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid resilience config of %s: %w", name, err)
+	}
+
+	p := &Policy{name: name, cfg: cfg, clock: newOptions(opts).clock}
+
+	var err error
+	if cfg.Breaker.WindowSize > 0 {
+		if p.breaker, err = NewBreaker(name, cfg.Breaker, opts...); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Bulkhead.MaxConcurrent > 0 {
+		if p.bulkhead, err = NewBulkhead(name, cfg.Bulkhead, opts...); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *Policy) Name() string {
+	return p.name
+}
+
+// Breaker returns the breaker, nil when it's disabled
+func (p *Policy) Breaker() *Breaker {
+	return p.breaker
+}
+
+// Bulkhead returns the bulkhead, nil when it's disabled
+func (p *Policy) Bulkhead() *Bulkhead {
+	return p.bulkhead
+}
+
+// Do calls fn through the bulkhead and the breaker, and retries it
+func (p *Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	call := fn
+	if p.bulkhead != nil {
+		call = func(ctx context.Context) error { return p.bulkhead.Execute(ctx, fn) }
+	}
+	if p.breaker != nil {
+		guarded := call
+		call = func(ctx context.Context) error { return p.breaker.Execute(ctx, guarded) }
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := call(ctx)
+		if err == nil || attempt >= p.cfg.Retry.MaxRetries || ctx.Err() != nil || !p.cfg.Retry.retryable(err) {
+			return err
+		}
+
+		if wait := p.cfg.Retry.backoff(attempt); wait > 0 {
+			select {
+			case <-p.clock.After(wait):
+			case <-ctx.Done():
+				return err
+			}
+		}
+	}
+}
+
+// Registry keeps a policy per dependency name, made from the defaults unless
+// Configure sets its config
+type Registry struct {
+	defaults Config
+	opts     []Option
+
+	mu       sync.Mutex
+	configs  map[string]Config
+	policies map[string]*Policy
+}
+
+func NewRegistry(defaults Config, opts ...Option) (*Registry, error) {
+	if err := defaults.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid resilience defaults: %w", err)
+	}
+	return &Registry{defaults: defaults, opts: opts, configs: map[string]Config{}, policies: map[string]*Policy{}}, nil
+}
+
+// Defaults returns the config of the names without their own
+func (r *Registry) Defaults() Config {
+	return r.defaults
+}
+
+// Configure sets the config of name, its breaker and bulkhead start over
+func (r *Registry) Configure(name string, cfg Config) error {
+	p, err := NewPolicy(name, cfg, r.opts...)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[name] = cfg
+	r.policies[name] = p
+	return nil
+}
+
+// Config returns the config of name
+func (r *Registry) Config(name string) Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cfg, ok := r.configs[name]; ok {
+		return cfg
+	}
+	return r.defaults
+}
+
+// Policy returns the policy of name, made on first use
+func (r *Registry) Policy(name string) *Policy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.policies[name]; ok {
+		return p
+	}
+
+	// the defaults are valid
+	p, _ := NewPolicy(name, r.defaults, r.opts...)
+	r.policies[name] = p
+	return p
+}
+
+// Names returns the sorted names of the policies in use
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.policies))
+	for name := range r.policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Do calls fn with the policy of name
+func (r *Registry) Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	return r.Policy(name).Do(ctx, fn)
+}
+
+var defaultRegistry atomic.Pointer[Registry]
+
+func init() {
+	r, _ := NewRegistry(DefaultConfig())
+	defaultRegistry.Store(r)
+}
+
+// Default returns the registry of Do, the plugin replaces it with one of its flags
+func Default() *Registry {
+	return defaultRegistry.Load()
+}
+
+// SetDefault makes r the registry of Do
+func SetDefault(r *Registry) {
+	defaultRegistry.Store(r)
+}
+
+// Do calls fn with the policy of name of the default registry
+func Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	return Default().Do(ctx, name, fn)
+}
@@ -0,0 +1,176 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPolicyRetries(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Retry = RetryConfig{MaxRetries: 2}
+	p, err := NewPolicy("payments", cfg)
+	assert.Nil(t, err, "must be nil")
+
+	calls := 0
+	err = p.Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errDown
+		}
+		return nil
+	})
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, 3, calls, "should be equal")
+
+	// errors which are not retryable
+	cfg.Retry.Retryable = func(err error) bool { return !errors.Is(err, errDown) }
+	p, _ = NewPolicy("payments", cfg)
+	calls = 0
+	err = p.Do(context.Background(), func(context.Context) error {
+		calls++
+		return errDown
+	})
+	assert.ErrorIs(t, err, errDown, "should be equal")
+	assert.Equal(t, 1, calls, "should be equal")
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	clock := newFakeClock()
+	cfg := DefaultConfig()
+	cfg.Retry = RetryConfig{MaxRetries: 1, Backoff: time.Second, MaxBackoff: time.Second}
+	p, _ := NewPolicy("payments", cfg, WithClock(clock))
+
+	calls := 0
+	done := make(chan error)
+	go func() {
+		done <- p.Do(context.Background(), func(context.Context) error {
+			calls++
+			return errDown
+		})
+	}()
+
+	// waits for the backoff
+	var err error
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Second)
+		select {
+		case err = <-done:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond, "should retry after the backoff")
+	assert.ErrorIs(t, err, errDown, "should be equal")
+	assert.Equal(t, 2, calls, "should be equal")
+
+	for attempt := 0; attempt < 5; attempt++ {
+		assert.LessOrEqual(t, cfg.Retry.backoff(attempt), time.Second, "should be at most max backoff")
+	}
+}
+
+func TestPolicyRejections(t *testing.T) {
+	clock := newFakeClock()
+	cfg := Config{
+		Breaker:  testBreakerConfig(),
+		Bulkhead: BulkheadConfig{MaxConcurrent: 1},
+		Retry:    RetryConfig{MaxRetries: 3},
+	}
+	p, err := NewPolicy("payments", cfg, WithClock(clock))
+	assert.Nil(t, err, "must be nil")
+
+	// 4 calls of the retries open the breaker
+	calls := 0
+	err = p.Do(context.Background(), func(context.Context) error {
+		calls++
+		return errDown
+	})
+	assert.ErrorIs(t, err, errDown, "should be equal")
+	assert.Equal(t, 4, calls, "should be equal")
+	assert.Equal(t, StateOpen, p.Breaker().State(), "should be equal")
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, span := provider.Tracer("test").Start(context.Background(), "charge")
+	err = p.Do(ctx, func(context.Context) error {
+		calls++
+		return nil
+	})
+	span.End()
+	assert.ErrorIs(t, err, ErrBreakerOpen, "should be rejected")
+	assert.Equal(t, 4, calls, "rejected calls should not be retried")
+
+	events := exporter.GetSpans()[0].Events
+	assert.Len(t, events, 1, "should be equal")
+	assert.Equal(t, "resilience.rejected", events[0].Name, "should be equal")
+}
+
+func TestRegistry(t *testing.T) {
+	r, err := NewRegistry(DefaultConfig())
+	assert.Nil(t, err, "must be nil")
+
+	custom := DefaultConfig()
+	custom.Bulkhead.MaxConcurrent = 5
+	assert.Nil(t, r.Configure("search", custom), "must be nil")
+
+	assert.Nil(t, r.Policy("payments").Bulkhead(), "should use the defaults")
+	assert.NotNil(t, r.Policy("search").Bulkhead(), "should use its config")
+	assert.Same(t, r.Policy("payments"), r.Policy("payments"), "should be kept")
+	assert.Equal(t, []string{"payments", "search"}, r.Names(), "should be equal")
+	assert.Equal(t, 5, r.Config("search").Bulkhead.MaxConcurrent, "should be equal")
+
+	invalid := DefaultConfig()
+	invalid.Retry.MaxRetries = -1
+	assert.NotNil(t, r.Configure("search", invalid), "should be an error")
+
+	_, err = NewRegistry(invalid)
+	assert.NotNil(t, err, "should be an error")
+}
+
+func TestParseOverrides(t *testing.T) {
+	configs, err := parseOverrides("payments:retry-max=0, breaker-failure-rate=0.3; search:bulkhead-max-concurrent=20,bulkhead-max-wait=1s", DefaultConfig())
+	assert.Nil(t, err, "must be nil")
+	assert.Len(t, configs, 2, "should be equal")
+	assert.Equal(t, 0, configs["payments"].Retry.MaxRetries, "should be equal")
+	assert.Equal(t, 0.3, configs["payments"].Breaker.FailureRate, "should be equal")
+	assert.Equal(t, 20, configs["payments"].Breaker.WindowSize, "should be the default")
+	assert.Equal(t, 20, configs["search"].Bulkhead.MaxConcurrent, "should be equal")
+	assert.Equal(t, time.Second, configs["search"].Bulkhead.MaxWait, "should be equal")
+
+	for _, s := range []string{"payments", ":retry-max=1", "payments:retry-max", "payments:unknown=1", "payments:retry-max=abc"} {
+		_, err := parseOverrides(s, DefaultConfig())
+		assert.NotNil(t, err, s+" should be an error")
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	prev := Default()
+	defer SetDefault(prev)
+
+	prevFlags := flag.CommandLine
+	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	defer func() { flag.CommandLine = prevFlags }()
+
+	p := New("resilience", "resilience")
+	p.InitFlags()
+	assert.Nil(t, flag.CommandLine.Parse([]string{"-resilience-retry-max=1", "-resilience-overrides=payments:retry-max=0"}), "must be nil")
+	assert.Nil(t, p.Run(), "must be nil")
+
+	r := p.Get().(*Registry)
+	assert.Same(t, r, Default(), "should be the default registry")
+	assert.Equal(t, 1, r.Config("search").Retry.MaxRetries, "should be equal")
+	assert.Equal(t, 0, r.Config("payments").Retry.MaxRetries, "should be equal")
+
+	calls := 0
+	_ = Do(context.Background(), "search", func(context.Context) error {
+		calls++
+		return errDown
+	})
+	assert.Equal(t, 2, calls, "should be equal")
+}
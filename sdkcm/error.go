@@ -2,7 +2,9 @@ package sdkcm
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 )
 
 var (
@@ -81,8 +83,57 @@ var (
 		}
 		return NewAppErr(root, http.StatusUnauthorized, err.Error()).WithCode(err.Key())
 	}
+	ErrInternal = func(err error) AppError {
+		return NewAppErr(err, http.StatusInternalServerError, "internal server error").WithCode("internal_error")
+	}
+	ErrPermissionDenied = func(err error) AppError {
+		return NewAppErr(err, http.StatusForbidden, "you don't have permission to access").WithCode("no_permission")
+	}
+	ErrEntityNotFound = func(entity string, err error) AppError {
+		return NewAppErr(err, http.StatusNotFound, fmt.Sprintf("%s not found", strings.ToLower(entity))).
+			WithCode(fmt.Sprintf("%s_not_found", entityCode(entity)))
+	}
+	ErrEntityExisted = func(entity string, err error) AppError {
+		return NewAppErr(err, http.StatusConflict, fmt.Sprintf("%s already exists", strings.ToLower(entity))).
+			WithCode(fmt.Sprintf("%s_already_exists", entityCode(entity)))
+	}
+	ErrCannotGetEntity = func(entity string, err error) AppError {
+		return errCannotEntity("get", entity, err)
+	}
+	ErrCannotListEntity = func(entity string, err error) AppError {
+		return errCannotEntity("list", entity, err)
+	}
+	ErrCannotCreateEntity = func(entity string, err error) AppError {
+		return errCannotEntity("create", entity, err)
+	}
+	ErrCannotUpdateEntity = func(entity string, err error) AppError {
+		return errCannotEntity("update", entity, err)
+	}
+	ErrCannotDeleteEntity = func(entity string, err error) AppError {
+		return errCannotEntity("delete", entity, err)
+	}
 )
 
+// errCannotEntity is a 400 error, e.g. "cannot get user" with code cannot_get_user
+func errCannotEntity(action, entity string, err error) AppError {
+	return NewAppErr(err, http.StatusBadRequest, fmt.Sprintf("cannot %s %s", action, strings.ToLower(entity))).
+		WithCode(fmt.Sprintf("cannot_%s_%s", action, entityCode(entity)))
+}
+
+// entityCode turns an entity name to snake case, e.g. "User Device" => user_device
+func entityCode(entity string) string {
+	return strings.Join(strings.Fields(strings.ToLower(entity)), "_")
+}
+
+// FieldError describes an invalid field of a request
+type FieldError struct {
+	Field string `json:"field"`
+	// validation rule and its parameter, e.g. min and 3
+	Rule    string `json:"rule,omitempty"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
 type ErrorWithKey interface {
 	error
 	Key() string
@@ -90,14 +141,24 @@ type ErrorWithKey interface {
 
 type AppError struct {
 	// We don't show root cause to the clients
-	RootCause  error  `json:"-"`
-	Code       string `json:"code"`
-	Log        string `json:"log"`
-	StatusCode int    `json:"status_code"`
-	Message    string `json:"message"`
+	RootCause error  `json:"-"`
+	Code      string `json:"code"`
+	// Root cause message, only responded in gin debug mode by middleware.ErrorHandler
+	Log        string       `json:"log,omitempty"`
+	StatusCode int          `json:"status_code"`
+	Message    string       `json:"message"`
+	Fields     []FieldError `json:"fields,omitempty"`
+	// set by middleware.ErrorHandler, so clients can report it
+	RequestID string `json:"request_id,omitempty"`
+	// i18n key of the message, translated in the locale of the request when responded
+	MessageKey  string        `json:"-"`
+	MessageArgs []interface{} `json:"-"`
 }
 
 func NewAppErr(err error, statusCode int, msg string) AppError {
+	if err == nil {
+		err = errors.New(msg)
+	}
 	return AppError{RootCause: err, Log: err.Error(), StatusCode: statusCode, Message: msg}
 }
 
@@ -119,6 +180,27 @@ func (ae AppError) WithCode(code string) AppError {
 	return ae
 }
 
+// WithMessageKey translates the message with the i18n catalogs when the error is
+// responded, Message is kept for missing keys:
+//
+//	sdkcm.ErrEntityNotFound("Order", err).WithMessageKey("errors.order_not_found", id)
+func (ae AppError) WithMessageKey(key string, args ...interface{}) AppError {
+	ae.MessageKey = key
+	ae.MessageArgs = args
+	return ae
+}
+
+// WithFields adds the invalid fields of a request
+func (ae AppError) WithFields(fields ...FieldError) AppError {
+	ae.Fields = append(append([]FieldError{}, ae.Fields...), fields...)
+	return ae
+}
+
+// Unwrap lets errors.Is and errors.As reach the root cause
+func (ae AppError) Unwrap() error {
+	return ae.RootCause
+}
+
 type customError struct {
 	k string
 	v string
@@ -0,0 +1,57 @@
+package sdkcm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppErrorWireFormat(t *testing.T) {
+	root := errors.New("connection refused")
+
+	for _, c := range []struct {
+		err    AppError
+		status int
+		expect string
+	}{
+		{err: ErrInvalidRequest(root), status: http.StatusBadRequest,
+			expect: `{"code":"invalid_request","log":"connection refused","status_code":400,"message":"invalid request"}`},
+		{err: ErrInternal(root), status: http.StatusInternalServerError,
+			expect: `{"code":"internal_error","log":"connection refused","status_code":500,"message":"internal server error"}`},
+		{err: ErrPermissionDenied(nil), status: http.StatusForbidden,
+			expect: `{"code":"no_permission","log":"you don't have permission to access","status_code":403,"message":"you don't have permission to access"}`},
+		{err: ErrEntityNotFound("User", root), status: http.StatusNotFound,
+			expect: `{"code":"user_not_found","log":"connection refused","status_code":404,"message":"user not found"}`},
+		{err: ErrEntityExisted("User", root), status: http.StatusConflict,
+			expect: `{"code":"user_already_exists","log":"connection refused","status_code":409,"message":"user already exists"}`},
+		{err: ErrCannotGetEntity("User Device", root), status: http.StatusBadRequest,
+			expect: `{"code":"cannot_get_user_device","log":"connection refused","status_code":400,"message":"cannot get user device"}`},
+		{err: ErrCannotListEntity("User", root), status: http.StatusBadRequest,
+			expect: `{"code":"cannot_list_user","log":"connection refused","status_code":400,"message":"cannot list user"}`},
+		{err: ErrCannotCreateEntity("User", root), status: http.StatusBadRequest,
+			expect: `{"code":"cannot_create_user","log":"connection refused","status_code":400,"message":"cannot create user"}`},
+		{err: ErrCannotUpdateEntity("User", root), status: http.StatusBadRequest,
+			expect: `{"code":"cannot_update_user","log":"connection refused","status_code":400,"message":"cannot update user"}`},
+		{err: ErrCannotDeleteEntity("User", root), status: http.StatusBadRequest,
+			expect: `{"code":"cannot_delete_user","log":"connection refused","status_code":400,"message":"cannot delete user"}`},
+		{err: ErrInvalidRequest(root).WithFields(FieldError{Field: "email", Message: "is required"}), status: http.StatusBadRequest,
+			expect: `{"code":"invalid_request","log":"connection refused","status_code":400,"message":"invalid request","fields":[{"field":"email","message":"is required"}]}`},
+	} {
+		data, err := json.Marshal(c.err)
+		assert.Nil(t, err, "must be nil")
+		assert.JSONEq(t, c.expect, string(data), "should be equal")
+		assert.Equal(t, c.status, c.err.StatusCode, "should be equal")
+	}
+}
+
+func TestAppErrorUnwrap(t *testing.T) {
+	err := ErrCannotGetEntity("user", ErrDataNotFound)
+	assert.True(t, errors.Is(err, ErrDataNotFound), "should reach the root cause")
+
+	var appErr AppError
+	assert.True(t, errors.As(error(err), &appErr), "should be an AppError")
+	assert.Equal(t, "cannot_get_user", appErr.Code, "should be equal")
+}
@@ -4,58 +4,96 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 )
 
-var (
-	dateFmt                       = "2006-01-02"
-	timeAddDuration time.Duration = 0
-)
-
-func SetTimeZone(zone int64) {
-	timeAddDuration = time.Hour * time.Duration(zone)
-}
+var dateFmt = "2006-01-02"
 
+// JSONDate is a date marshaled as yyyy-mm-dd in the default timezone
 type JSONDate time.Time
 
 // Implement method MarshalJSON to output date with in formatted
 func (d JSONDate) MarshalJSON() ([]byte, error) {
-	stamp := fmt.Sprintf("\"%s\"", time.Time(d).Add(timeAddDuration).Format(dateFmt))
-	return []byte(stamp), nil
+	return []byte(strconv.Quote(d.String())), nil
 }
 
+// UnmarshalJSON accepts yyyy-mm-dd and the formats of JSONTime,
+// times are truncated to their date in the default timezone
 func (d *JSONDate) UnmarshalJSON(data []byte) error {
-	t, err := time.Parse(dateFmt, strings.Replace(string(data), "\"", "", -1))
-
-	*d = JSONDate(t)
+	s, err := unquoteJSON(data, "JSONDate")
+	if err != nil {
+		return err
+	}
 
+	parsed, err := parseDate(s)
 	if err != nil {
 		return err
 	}
+
+	*d = JSONDate(parsed)
 	return nil
 }
 
-// This method for mapping JSONDate to date data type in sql
-func (d *JSONDate) Value() (driver.Value, error) {
-	if d == nil {
-		return nil, nil
+// UnmarshalParam binds form, query and uri params with gin, empty params are the zero date
+func (d *JSONDate) UnmarshalParam(param string) error {
+	if param == "" {
+		*d = JSONDate{}
+		return nil
 	}
-	return time.Time(*d).Format(dateFmt), nil
+
+	parsed, err := parseDate(param)
+	if err != nil {
+		return err
+	}
+
+	*d = JSONDate(parsed)
+	return nil
 }
 
-// This method for scanning JSONDate from date data type in sql
-func (d *JSONDate) Scan(value interface{}) error {
-	if value == nil {
-		return nil
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateFmt, s, parseLocation()); err == nil {
+		return t, nil
+	}
+
+	t, err := parseTime(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, must be yyyy-mm-dd, RFC3339 or unix seconds or millis", s)
 	}
 
-	if v, ok := value.(time.Time); ok {
+	t = t.In(parseLocation())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+}
+
+func (d JSONDate) String() string {
+	return inTimeLocation(time.Time(d)).Format(dateFmt)
+}
+
+func (d JSONDate) Time() time.Time {
+	return time.Time(d)
+}
+
+// This method for mapping JSONDate to date data type in sql, nil pointers are NULL
+func (d JSONDate) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// This method for scanning JSONDate from date data type in sql,
+// NULL is an error, use *JSONDate for nullable columns
+func (d *JSONDate) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
 		*d = JSONDate(v)
 		return nil
+	case []byte:
+		return d.UnmarshalParam(string(v))
+	case string:
+		return d.UnmarshalParam(v)
+	case nil:
+		return errors.New("NULL JSONDate, use *JSONDate for nullable columns")
+	default:
+		return fmt.Errorf("invalid Scan Source %T", value)
 	}
-
-	return errors.New("invalid Scan Source")
 }
 
 func (d JSONDate) GetBSON() (interface{}, error) {
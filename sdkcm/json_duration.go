@@ -0,0 +1,101 @@
+package sdkcm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Duration is marshaled as a Go duration string like "1h30m0s".
+// Numbers are read as seconds, they may have a fraction.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	s, err := unquoteJSON(data, "Duration")
+	if err != nil {
+		return err
+	}
+
+	parsed, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// UnmarshalParam binds form, query and uri params with gin, empty params are zero
+func (d *Duration) UnmarshalParam(param string) error {
+	if param == "" {
+		*d = 0
+		return nil
+	}
+
+	parsed, err := parseDuration(param)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(secs) && !math.IsInf(secs, 0) {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, must be like 1h30m or seconds", s)
+	}
+	return parsed, nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// Value stores the nanoseconds, nil pointers are NULL
+func (d Duration) Value() (driver.Value, error) {
+	return int64(d), nil
+}
+
+// Scan reads nanoseconds or a string in a format of UnmarshalJSON,
+// NULL is an error, use *Duration for nullable columns
+func (d *Duration) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case int64:
+		*d = Duration(v)
+		return nil
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	case nil:
+		return errors.New("NULL Duration, use *Duration for nullable columns")
+	default:
+		return fmt.Errorf("invalid Scan Source %T", value)
+	}
+}
+
+// scanString reads integers as the nanoseconds stored by Value
+func (d *Duration) scanString(s string) error {
+	if ns, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*d = Duration(ns)
+		return nil
+	}
+	return d.UnmarshalParam(s)
+}
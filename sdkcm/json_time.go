@@ -0,0 +1,147 @@
+package sdkcm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numbers from this are unix millis, 1e11 seconds is in year 5138
+const unixMillisFrom = 1e11
+
+// location of the marshaled JSONTime and JSONDate and of the inputs without zone,
+// nil keeps the location of the values and parses the inputs in UTC
+var timeLocation *time.Location
+
+// SetTimeLocation sets the default timezone of JSONTime and JSONDate, call it at service init
+func SetTimeLocation(loc *time.Location) {
+	timeLocation = loc
+}
+
+// SetTimeZone sets the default timezone to a fixed offset in hours
+func SetTimeZone(zone int64) {
+	SetTimeLocation(time.FixedZone(fmt.Sprintf("UTC%+d", zone), int(zone)*3600))
+}
+
+func inTimeLocation(t time.Time) time.Time {
+	if timeLocation == nil {
+		return t
+	}
+	return t.In(timeLocation)
+}
+
+func parseLocation() *time.Location {
+	if timeLocation == nil {
+		return time.UTC
+	}
+	return timeLocation
+}
+
+// parseTime accepts RFC3339 with or without fraction of seconds, the same without zone
+// in the default timezone, and unix seconds or unix millis
+func parseTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n >= unixMillisFrom || n <= -unixMillisFrom {
+			return time.UnixMilli(n).In(parseLocation()), nil
+		}
+		return time.Unix(n, 0).In(parseLocation()), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05.999999999", s, parseLocation()); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, must be RFC3339 or unix seconds or millis", s)
+}
+
+// unquoteJSON returns the string or number of a JSON value, null is an error
+// since only pointers can be null
+func unquoteJSON(data []byte, typeName string) (string, error) {
+	s := string(data)
+	if s == "null" {
+		return "", fmt.Errorf("null %s, use *%s for nullable values", typeName, typeName)
+	}
+	if strings.HasPrefix(s, `"`) {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
+
+// JSONTime is a time marshaled as RFC3339 with nanoseconds in the default timezone
+type JSONTime time.Time
+
+func (t JSONTime) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(t.String())), nil
+}
+
+func (t *JSONTime) UnmarshalJSON(data []byte) error {
+	s, err := unquoteJSON(data, "JSONTime")
+	if err != nil {
+		return err
+	}
+
+	parsed, err := parseTime(s)
+	if err != nil {
+		return err
+	}
+
+	*t = JSONTime(parsed)
+	return nil
+}
+
+// UnmarshalParam binds form, query and uri params with gin, empty params are the zero time
+func (t *JSONTime) UnmarshalParam(param string) error {
+	if param == "" {
+		*t = JSONTime{}
+		return nil
+	}
+
+	parsed, err := parseTime(param)
+	if err != nil {
+		return err
+	}
+
+	*t = JSONTime(parsed)
+	return nil
+}
+
+func (t JSONTime) String() string {
+	return inTimeLocation(time.Time(t)).Format(time.RFC3339Nano)
+}
+
+func (t JSONTime) Time() time.Time {
+	return time.Time(t)
+}
+
+// Value stores the time, nil pointers are NULL
+func (t JSONTime) Value() (driver.Value, error) {
+	return time.Time(t), nil
+}
+
+// Scan reads a time column or a string in a format of UnmarshalJSON,
+// NULL is an error, use *JSONTime for nullable columns
+func (t *JSONTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		*t = JSONTime(v)
+		return nil
+	case []byte:
+		return t.UnmarshalParam(string(v))
+	case string:
+		return t.UnmarshalParam(v)
+	case nil:
+		return errors.New("NULL JSONTime, use *JSONTime for nullable columns")
+	default:
+		return fmt.Errorf("invalid Scan Source %T", value)
+	}
+}
+
+func (t JSONTime) GetBSON() (interface{}, error) {
+	return time.Time(t), nil
+}
@@ -0,0 +1,164 @@
+package sdkcm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONTimeUnmarshal(t *testing.T) {
+	expect := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	for _, c := range []struct {
+		data   string
+		expect time.Time
+	}{
+		{data: `"2024-03-15T10:30:00Z"`, expect: expect},
+		{data: `"2024-03-15T17:30:00+07:00"`, expect: expect},
+		{data: `"2024-03-15T10:30:00.123456789Z"`, expect: expect.Add(123456789)},
+		{data: `"2024-03-15T10:30:00"`, expect: expect},
+		{data: `1710498600`, expect: expect},
+		{data: `"1710498600"`, expect: expect},
+		{data: `1710498600123`, expect: expect.Add(123 * time.Millisecond)},
+	} {
+		var actual JSONTime
+		assert.Nil(t, json.Unmarshal([]byte(c.data), &actual), "must be nil: %s", c.data)
+		assert.True(t, c.expect.Equal(actual.Time()), "should be equal: %s => %s", c.data, actual)
+	}
+
+	for _, data := range []string{`null`, `"15/03/2024"`, `""`, `true`, `1.5`} {
+		var actual JSONTime
+		assert.NotNil(t, actual.UnmarshalJSON([]byte(data)), "should be an error: %s", data)
+	}
+
+	// pointers stay nil
+	var ptr struct {
+		At *JSONTime `json:"at"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(`{"at":null}`), &ptr), "must be nil")
+	assert.Nil(t, ptr.At, "must be nil")
+
+	var value struct {
+		At JSONTime `json:"at"`
+	}
+	assert.NotNil(t, json.Unmarshal([]byte(`{"at":null}`), &value), "should be an error")
+}
+
+func TestJSONTimeMarshal(t *testing.T) {
+	at := JSONTime(time.Date(2024, 3, 15, 10, 30, 0, 500, time.UTC))
+
+	data, err := json.Marshal(at)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, `"2024-03-15T10:30:00.0000005Z"`, string(data), "should be equal")
+
+	SetTimeZone(7)
+	defer SetTimeLocation(nil)
+
+	data, err = json.Marshal(at)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, `"2024-03-15T17:30:00.0000005+07:00"`, string(data), "should be equal")
+
+	// inputs without zone are in the default timezone
+	var parsed JSONTime
+	assert.Nil(t, json.Unmarshal([]byte(`"2024-03-15T17:30:00.0000005"`), &parsed), "must be nil")
+	assert.True(t, at.Time().Equal(parsed.Time()), "should be equal")
+}
+
+func TestJSONDate(t *testing.T) {
+	expect := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	for _, data := range []string{
+		`"2024-03-15"`,
+		`"2024-03-15T10:30:00Z"`,
+		`"2024-03-15T10:30:00.5Z"`,
+		`1710498600`,
+		`1710498600000`,
+	} {
+		var actual JSONDate
+		assert.Nil(t, json.Unmarshal([]byte(data), &actual), "must be nil: %s", data)
+		assert.True(t, expect.Equal(actual.Time()), "should be equal: %s => %s", data, actual)
+
+		out, err := json.Marshal(actual)
+		assert.Nil(t, err, "must be nil")
+		assert.Equal(t, `"2024-03-15"`, string(out), "should be equal")
+	}
+
+	for _, data := range []string{`null`, `"2024-13-01"`, `"15/03/2024"`} {
+		var actual JSONDate
+		assert.NotNil(t, actual.UnmarshalJSON([]byte(data)), "should be an error: %s", data)
+	}
+
+	var date JSONDate
+	assert.NotNil(t, date.Scan(nil), "should be an error")
+	assert.Nil(t, date.Scan("2024-03-15"), "must be nil")
+	value, err := date.Value()
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "2024-03-15", value, "should be equal")
+}
+
+func TestDuration(t *testing.T) {
+	for _, c := range []struct {
+		data   string
+		expect time.Duration
+	}{
+		{data: `"1h30m"`, expect: 90 * time.Minute},
+		{data: `"250ms"`, expect: 250 * time.Millisecond},
+		{data: `90`, expect: 90 * time.Second},
+		{data: `1.5`, expect: 1500 * time.Millisecond},
+		{data: `"90"`, expect: 90 * time.Second},
+	} {
+		var actual Duration
+		assert.Nil(t, json.Unmarshal([]byte(c.data), &actual), "must be nil: %s", c.data)
+		assert.Equal(t, c.expect, actual.Duration(), "should be equal: %s", c.data)
+	}
+
+	for _, data := range []string{`null`, `"1 hour"`, `"NaN"`, `true`} {
+		var actual Duration
+		assert.NotNil(t, actual.UnmarshalJSON([]byte(data)), "should be an error: %s", data)
+	}
+
+	data, err := json.Marshal(Duration(90 * time.Minute))
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, `"1h30m0s"`, string(data), "should be equal")
+
+	var d Duration
+	assert.Nil(t, d.Scan(int64(time.Second)), "must be nil")
+	assert.Equal(t, time.Second, d.Duration(), "should be equal")
+	assert.Nil(t, d.Scan([]byte("1000")), "must be nil")
+	assert.Equal(t, time.Microsecond, d.Duration(), "stored strings are nanoseconds")
+	assert.NotNil(t, d.Scan(nil), "should be an error")
+}
+
+func TestTimeTypesBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type query struct {
+		From    JSONTime  `form:"from"`
+		Day     *JSONDate `form:"day"`
+		Timeout Duration  `form:"timeout"`
+	}
+
+	router := gin.New()
+	router.GET("/items", func(c *gin.Context) {
+		var q query
+		if err := c.ShouldBindQuery(&q); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, q)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items?from=1710498600&day=2024-03-15&timeout=2m", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.JSONEq(t, `{"From":"2024-03-15T10:30:00Z","Day":"2024-03-15","Timeout":"2m0s"}`, w.Body.String(), "should be equal")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items?from=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "should be equal")
+}
@@ -1,9 +1,19 @@
 package sdkcm
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/btcsuite/btcutil/base58"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPagingLimit = 25
+	// MaxPagingLimit caps the limit unless Paging.MaxLimit is set
+	MaxPagingLimit = 200
 )
 
 type OrderBy struct {
@@ -22,6 +32,24 @@ type Paging struct {
 	OrderBy     string    `json:"-" form:"-"`
 	OB          []OrderBy `json:"-" form:"-"`
 	CursorIsUID bool      `json:"-" form:"-"`
+	// overrides MaxPagingLimit
+	MaxLimit int `json:"-" form:"-"`
+}
+
+// PagingFromGinContext reads the page, limit and cursor query params
+// and fulfills the paging. Invalid values fall back to the defaults.
+func PagingFromGinContext(c *gin.Context) Paging {
+	p := Paging{CursorStr: c.Query("cursor")}
+	p.Page, _ = strconv.Atoi(c.Query("page"))
+	p.Limit, _ = strconv.Atoi(c.Query("limit"))
+	p.Fulfill()
+
+	return p
+}
+
+// Fulfill is FullFill
+func (p *Paging) Fulfill() {
+	p.FullFill()
 }
 
 func (p *Paging) FullFill() {
@@ -42,7 +70,15 @@ func (p *Paging) FullFill() {
 	}
 
 	if p.Limit <= 0 {
-		p.Limit = 25
+		p.Limit = DefaultPagingLimit
+	}
+
+	maxLimit := p.MaxLimit
+	if maxLimit <= 0 {
+		maxLimit = MaxPagingLimit
+	}
+	if p.Limit > maxLimit {
+		p.Limit = maxLimit
 	}
 
 	if p.Page <= 0 {
@@ -68,3 +104,32 @@ func getOrderBy(ord string) []OrderBy {
 
 	return result
 }
+
+// SetNextCursor sets the opaque cursor of the next page from the uid of the last item
+func (p *Paging) SetNextCursor(last UID) {
+	p.NextCursor = last.String()
+	p.HasNext = true
+}
+
+// Offset of the page, starting from 0
+func (p *Paging) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+// Paginate is a gorm scope selecting the page by offset:
+//
+//	db.Scopes(paging.Paginate).Find(&items)
+func (p *Paging) Paginate(db *gorm.DB) *gorm.DB {
+	return db.Offset(p.Offset()).Limit(p.Limit)
+}
+
+// PaginateByCursor returns a gorm scope selecting the items after the cursor,
+// ordered by column descending. column stores the local id of the uids.
+func (p *Paging) PaginateByCursor(column string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if p.Cursor != nil {
+			db = db.Where(fmt.Sprintf("%s < ?", column), p.Cursor.GetLocalID())
+		}
+		return db.Order(fmt.Sprintf("%s desc", column)).Limit(p.Limit)
+	}
+}
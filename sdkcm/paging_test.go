@@ -0,0 +1,90 @@
+package sdkcm
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func pagingFromQuery(query string) Paging {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/items?"+query, nil)
+	return PagingFromGinContext(c)
+}
+
+func TestPagingFromGinContext(t *testing.T) {
+	for _, c := range []struct {
+		query string
+		page  int
+		limit int
+	}{
+		{query: "", page: 1, limit: DefaultPagingLimit},
+		{query: "page=3&limit=10", page: 3, limit: 10},
+		{query: "page=-1&limit=-5", page: 1, limit: DefaultPagingLimit},
+		{query: "page=abc&limit=ten", page: 1, limit: DefaultPagingLimit},
+		{query: "limit=1000", page: 1, limit: MaxPagingLimit},
+	} {
+		p := pagingFromQuery(c.query)
+		assert.Equal(t, c.page, p.Page, "should be equal")
+		assert.Equal(t, c.limit, p.Limit, "should be equal")
+	}
+
+	p := Paging{Limit: 1000, MaxLimit: 500}
+	p.Fulfill()
+	assert.Equal(t, 500, p.Limit, "should be equal")
+}
+
+func TestPagingCursor(t *testing.T) {
+	var prev Paging
+	prev.SetNextCursor(NewUID(42, 1, 1))
+	assert.True(t, prev.HasNext, "should have next page")
+
+	p := pagingFromQuery("cursor=" + prev.NextCursor)
+	assert.NotNil(t, p.Cursor, "must not be nil")
+	assert.Equal(t, uint32(42), p.Cursor.GetLocalID(), "should be equal")
+}
+
+func TestPagingScopes(t *testing.T) {
+	type item struct {
+		ID int
+	}
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, db.AutoMigrate(&item{}), "must be nil")
+	for i := 1; i <= 10; i++ {
+		assert.Nil(t, db.Create(&item{ID: i}).Error, "must be nil")
+	}
+
+	p := Paging{Page: 2, Limit: 3}
+	p.Fulfill()
+
+	var items []item
+	assert.Nil(t, db.Scopes(p.Paginate).Order("id").Find(&items).Error, "must be nil")
+	assert.Equal(t, []item{{4}, {5}, {6}}, items, "should be equal")
+
+	cursor := NewUID(8, 1, 1)
+	p = Paging{Limit: 3, Cursor: &cursor}
+	p.Fulfill()
+
+	items = nil
+	assert.Nil(t, db.Scopes(p.PaginateByCursor("id")).Find(&items).Error, "must be nil")
+	assert.Equal(t, []item{{7}, {6}, {5}}, items, "should be equal")
+}
+
+func TestNewSuccessResponse(t *testing.T) {
+	p := Paging{Page: 1, Limit: 2, Total: 5}
+	data, err := json.Marshal(NewSuccessResponse([]int{1, 2}, &p, map[string]string{"status": "active"}))
+	assert.Nil(t, err, "must be nil")
+	assert.JSONEq(t, `{
+		"code":200,
+		"data":[1,2],
+		"paging":{"next_cursor":"","cursor":"","limit":2,"total":5,"page":1,"has_next":false},
+		"filter":{"status":"active"}
+	}`, string(data), "should be equal")
+}
@@ -0,0 +1,18 @@
+package sdkcm
+
+import "context"
+
+type requesterCtxKey struct{}
+
+// ContextWithRequester returns a copy of ctx holding r, so the service layer
+// taking a context.Context knows who sends the request
+func ContextWithRequester(ctx context.Context, r Requester) context.Context {
+	return context.WithValue(ctx, requesterCtxKey{}, r)
+}
+
+// RequesterFromContext returns the requester of ctx, set by the auth
+// middlewares or received from another service
+func RequesterFromContext(ctx context.Context) (Requester, bool) {
+	r, ok := ctx.Value(requesterCtxKey{}).(Requester)
+	return r, ok && r != nil
+}
@@ -0,0 +1,150 @@
+package sdkcm
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// RequesterHeader carries the signed requester between services,
+	// it's also the gRPC metadata key in lower case
+	RequesterHeader = "X-Requester"
+
+	// DefaultRequesterMaxAge is how long a signed requester is accepted
+	DefaultRequesterMaxAge = 5 * time.Minute
+	// tolerated clock difference between the services
+	requesterClockSkew = 30 * time.Second
+)
+
+// ErrInvalidRequester is wrapped by the errors of RequesterSigner.Verify
+var ErrInvalidRequester = errors.New("invalid propagated requester")
+
+// RequesterSigner signs the requesters sent to other services with an HMAC
+// of a secret shared by them, so the receivers can trust the identity
+type RequesterSigner struct {
+	secret []byte
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// NewRequesterSigner returns nil without secret, then requesters are not propagated
+func NewRequesterSigner(secret string) *RequesterSigner {
+	if secret == "" {
+		return nil
+	}
+	return &RequesterSigner{secret: []byte(secret), maxAge: DefaultRequesterMaxAge, now: time.Now}
+}
+
+// WithMaxAge returns a signer accepting the requesters signed at most maxAge ago
+func (s *RequesterSigner) WithMaxAge(maxAge time.Duration) *RequesterSigner {
+	copied := *s
+	copied.maxAge = maxAge
+	return &copied
+}
+
+// propagatedClaims is what is known of a requester in other services
+type propagatedClaims struct {
+	UserID   uint32 `json:"uid"`
+	Role     string `json:"role,omitempty"`
+	OAuthID  string `json:"sub,omitempty"`
+	IssuedAt int64  `json:"iat"`
+}
+
+// Sign returns the header value of r: base64url(claims).base64url(hmac)
+func (s *RequesterSigner) Sign(r Requester) (string, error) {
+	payload, err := json.Marshal(propagatedClaims{
+		UserID:   r.UserID(),
+		Role:     r.GetSystemRole(),
+		OAuthID:  r.OAuthID(),
+		IssuedAt: s.now().Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// Verify returns the requester of a header value signed with the same secret.
+// Its GetUser is nil, only the user id, the role and the oauth id are propagated.
+func (s *RequesterSigner) Verify(value string) (Requester, error) {
+	encoded, sig, found := strings.Cut(value, ".")
+	if !found {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidRequester)
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidRequester)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidRequester)
+	}
+	var claims propagatedClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidRequester)
+	}
+
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	if age := s.now().Sub(issuedAt); age > s.maxAge || age < -requesterClockSkew {
+		return nil, fmt.Errorf("%w: signed at %s", ErrInvalidRequester, issuedAt.Format(time.RFC3339))
+	}
+
+	return &propagatedRequester{claims: claims}, nil
+}
+
+// ContextWithHeader returns ctx holding the requester of the header value,
+// ctx is returned as is when the value is missing or invalid: the request is anonymous
+func (s *RequesterSigner) ContextWithHeader(ctx context.Context, value string) context.Context {
+	if s == nil || value == "" {
+		return ctx
+	}
+	r, err := s.Verify(value)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRequester(ctx, r)
+}
+
+// HeaderFromContext returns the header value of the requester of ctx,
+// empty without requester
+func (s *RequesterSigner) HeaderFromContext(ctx context.Context) string {
+	if s == nil {
+		return ""
+	}
+	r, ok := RequesterFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	value, err := s.Sign(r)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+func (s *RequesterSigner) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// propagatedRequester is the requester received from another service
+type propagatedRequester struct {
+	claims propagatedClaims
+}
+
+func (r *propagatedRequester) OAuthID() string       { return r.claims.OAuthID }
+func (r *propagatedRequester) UserID() uint32        { return r.claims.UserID }
+func (r *propagatedRequester) GetSystemRole() string { return r.claims.Role }
+func (r *propagatedRequester) GetUser() interface{}  { return nil }
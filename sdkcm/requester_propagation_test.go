@@ -0,0 +1,71 @@
+package sdkcm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testOAuth string
+
+func (id testOAuth) OAuthID() string { return string(id) }
+
+type testUser struct{}
+
+func (testUser) UserID() uint32        { return 42 }
+func (testUser) GetSystemRole() string { return "admin" }
+func (testUser) GetUser() interface{}  { return nil }
+
+func TestRequesterSigner(t *testing.T) {
+	assert.Nil(t, NewRequesterSigner(""), "should be disabled without secret")
+
+	signer := NewRequesterSigner("shared")
+	value, err := signer.Sign(CurrentUser(testOAuth("sub-1"), testUser{}))
+	assert.Nil(t, err, "must be nil")
+
+	r, err := signer.Verify(value)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, uint32(42), r.UserID(), "should be equal")
+	assert.Equal(t, "admin", r.GetSystemRole(), "should be equal")
+	assert.Equal(t, "sub-1", r.OAuthID(), "should be equal")
+
+	_, err = NewRequesterSigner("other").Verify(value)
+	assert.True(t, errors.Is(err, ErrInvalidRequester), "should be a bad signature")
+
+	// the claims can't be changed
+	encoded, sig, _ := strings.Cut(value, ".")
+	_, err = signer.Verify(encoded + "x." + sig)
+	assert.True(t, errors.Is(err, ErrInvalidRequester), "should be a bad signature")
+
+	_, err = signer.Verify("garbage")
+	assert.True(t, errors.Is(err, ErrInvalidRequester), "should be malformed")
+
+	later := *signer
+	later.now = func() time.Time { return time.Now().Add(DefaultRequesterMaxAge + time.Minute) }
+	_, err = later.Verify(value)
+	assert.True(t, errors.Is(err, ErrInvalidRequester), "should be expired")
+	_, err = later.WithMaxAge(time.Hour).Verify(value)
+	assert.Nil(t, err, "must be nil")
+}
+
+func TestRequesterContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := RequesterFromContext(ctx)
+	assert.False(t, ok, "should be anonymous")
+
+	signer := NewRequesterSigner("shared")
+	assert.Equal(t, "", signer.HeaderFromContext(ctx), "should be empty without requester")
+	assert.Equal(t, ctx, signer.ContextWithHeader(ctx, "garbage"), "should stay anonymous")
+
+	ctx = ContextWithRequester(ctx, CurrentUser(testOAuth("sub-1"), testUser{}))
+	r, ok := signer.ContextWithHeader(context.Background(), signer.HeaderFromContext(ctx)).Value(requesterCtxKey{}).(Requester)
+	assert.True(t, ok, "should be propagated")
+	assert.Equal(t, uint32(42), r.UserID(), "should be equal")
+
+	var disabled *RequesterSigner
+	assert.Equal(t, "", disabled.HeaderFromContext(ctx), "should not be sent")
+}
@@ -11,6 +11,16 @@ var (
 		return newResponse(http.StatusOK, data, nil, nil)
 	}
 
+	// NewSuccessResponse is the envelope of list endpoints
+	NewSuccessResponse = func(data interface{}, paging *Paging, filter interface{}) Response {
+		res := newResponse(http.StatusOK, data, nil, nil)
+		if paging != nil {
+			res.Paging = paging
+		}
+		res.Filter = filter
+		return res
+	}
+
 	ResponseWithPaging = func(data, param interface{}, other interface{}) Response {
 		if v, ok := other.(Paging); ok {
 			if v.NextCursor != "" {
@@ -29,6 +39,7 @@ type Response struct {
 	Data   interface{} `json:"data"`
 	Param  interface{} `json:"param,omitempty"`
 	Paging interface{} `json:"paging,omitempty"`
+	Filter interface{} `json:"filter,omitempty"`
 }
 
 func newResponse(code int, data, param, other interface{}) Response {
@@ -4,8 +4,10 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/btcsuite/btcutil/base58"
 	// "github.com/globalsign/mgo/bson"
@@ -32,8 +34,13 @@ func NewUID(localID uint32, objType int, shardID uint32) UID {
 }
 
 func (uid UID) String() string {
-	val := uint64(uid.localID)<<28 | uint64(uid.objectType)<<18 | uint64(uid.shardID)<<0
-	return base58.Encode([]byte(fmt.Sprintf("%v", val)))
+	return base58.Encode([]byte(fmt.Sprintf("%v", uid.composed())))
+}
+
+// IsZero returns whether uid is unset, e.g. a missing, null or "" JSON field.
+// encoding/json never omits a UID with omitempty, use *UID for optional fields.
+func (uid UID) IsZero() bool {
+	return uid == UID{}
 }
 
 func (uid UID) GetLocalID() uint32 {
@@ -59,85 +66,243 @@ func DecomposeUID(s string) (UID, error) {
 		return UID{}, errors.New("wrong uid")
 	}
 
-	u := UID{
-		localID:    uint32(uid >> 28),
-		objectType: int(uid >> 18 & 0x3FF),
-		shardID:    uint32(uid >> 0 & 0x3FFFF),
-	}
-
-	return u, nil
+	return uidFromUint64(uid), nil
 }
 
 func FromBase58(s string) (UID, error) {
 	return DecomposeUID(string(base58.Decode(s)))
 }
 
+var (
+	// ErrInvalidUID is wrapped by all errors of DecodeUID
+	ErrInvalidUID = errors.New("invalid uid")
+)
+
+// max composed value, 32 bits local id + 10 bits object type + 18 bits shard id
+const maxComposedUID = 1<<60 - 1
+
+// DecodeUID parses the public base58 form of a uid (its String()).
+// Unlike FromBase58, it rejects non canonical encodings, overflowing values
+// and uids with a zero local id. Errors wrap ErrInvalidUID.
+func DecodeUID(s string) (UID, error) {
+	if s == "" {
+		return UID{}, fmt.Errorf("%w: empty", ErrInvalidUID)
+	}
+
+	decoded := base58.Decode(s)
+	if len(decoded) == 0 {
+		return UID{}, fmt.Errorf("%w: bad base58 encoding", ErrInvalidUID)
+	}
+
+	// the encoded bytes are the decimal composed value without leading zeros
+	if decoded[0] == '0' {
+		return UID{}, fmt.Errorf("%w: non canonical encoding", ErrInvalidUID)
+	}
+	for _, b := range decoded {
+		if b < '0' || b > '9' {
+			return UID{}, fmt.Errorf("%w: bad encoding", ErrInvalidUID)
+		}
+	}
+
+	v, err := strconv.ParseUint(string(decoded), 10, 64)
+	if err != nil || v > maxComposedUID {
+		return UID{}, fmt.Errorf("%w: overflow", ErrInvalidUID)
+	}
+
+	uid := uidFromUint64(v)
+	if uid.localID == 0 {
+		return UID{}, fmt.Errorf("%w: zero local id", ErrInvalidUID)
+	}
+
+	return uid, nil
+}
+
+// ParseUID is an alias of DecodeUID
+func ParseUID(s string) (UID, error) {
+	return DecodeUID(s)
+}
+
+// DecodeUIDWithType is DecodeUID also checking the object type
+func DecodeUIDWithType(s string, objectType int) (UID, error) {
+	uid, err := DecodeUID(s)
+	if err != nil {
+		return UID{}, err
+	}
+
+	if uid.objectType != objectType {
+		return UID{}, fmt.Errorf("%w: object type is %d, expected %d", ErrInvalidUID, uid.objectType, objectType)
+	}
+
+	return uid, nil
+}
+
+// UIDEncodeMode is how UID is marshaled to JSON
+type UIDEncodeMode int32
+
+const (
+	// UIDEncodeBase58 marshals the base58 public form, the default
+	UIDEncodeBase58 UIDEncodeMode = iota
+	// UIDEncodePlain marshals the composed integer, for internal APIs
+	UIDEncodePlain
+)
+
+var uidEncodeMode atomic.Int32
+
+// SetUIDEncodeMode sets how every UID is marshaled to JSON,
+// call it at service init. Use PlainUID to marshal only some fields as integers.
+func SetUIDEncodeMode(mode UIDEncodeMode) {
+	uidEncodeMode.Store(int32(mode))
+}
+
 func (uid UID) MarshalJSON() ([]byte, error) {
+	if UIDEncodeMode(uidEncodeMode.Load()) == UIDEncodePlain {
+		return strconv.AppendUint(nil, uid.composed(), 10), nil
+	}
 	return []byte(fmt.Sprintf("\"%s\"", uid.String())), nil
 }
 
+// UnmarshalJSON accepts the base58 string and the composed integer
+// sent by legacy clients, null and "" are the zero uid
 func (uid *UID) UnmarshalJSON(data []byte) error {
-	decodeUID, err := FromBase58(strings.Replace(string(data), "\"", "", -1))
+	s := string(data)
+
+	switch {
+	case s == "null" || s == `""`:
+		*uid = UID{}
+		return nil
+	case strings.HasPrefix(s, `"`):
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidUID, err.Error())
+		}
+		if s = string(base58.Decode(unquoted)); s == "" {
+			return fmt.Errorf("%w: bad base58 encoding", ErrInvalidUID)
+		}
+	}
 
+	decoded, err := uidFromComposed(s)
 	if err != nil {
 		return err
 	}
 
-	uid.localID = decodeUID.localID
-	uid.shardID = decodeUID.shardID
-	uid.objectType = decodeUID.objectType
+	*uid = decoded
 
 	return nil
 }
 
-func (uid *UID) Value() (driver.Value, error) {
-	if uid == nil {
-		return nil, nil
+// uidFromComposed parses the decimal composed value,
+// only 0 is accepted with a zero local id
+func uidFromComposed(s string) (UID, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if errors.Is(err, strconv.ErrRange) || (err == nil && v > maxComposedUID) {
+		return UID{}, fmt.Errorf("%w: %s exceeds 60 bits, max local id is %d", ErrInvalidUID, s, uint32(math.MaxUint32))
+	}
+	if err != nil {
+		return UID{}, fmt.Errorf("%w: bad encoding", ErrInvalidUID)
+	}
+
+	uid := uidFromUint64(v)
+	if v != 0 && uid.localID == 0 {
+		return UID{}, fmt.Errorf("%w: zero local id", ErrInvalidUID)
+	}
+
+	return uid, nil
+}
+
+// PlainUID is a UID always marshaled to JSON as the composed integer,
+// e.g. for the fields of internal admin APIs
+type PlainUID struct {
+	UID
+}
+
+func (uid PlainUID) MarshalJSON() ([]byte, error) {
+	return strconv.AppendUint(nil, uid.composed(), 10), nil
+}
+
+// RequiredUID is a UID whose UnmarshalJSON rejects null, "" and 0 with ErrInvalidUID.
+// Struct tags don't reach UnmarshalJSON, and it's not called for missing fields:
+// use binding:"uid_required" on UID fields to also reject them with a 400.
+type RequiredUID struct {
+	UID
+}
+
+func (uid *RequiredUID) UnmarshalJSON(data []byte) error {
+	if err := uid.UID.UnmarshalJSON(data); err != nil {
+		return err
 	}
-	return int64(uid.localID), nil
+	if uid.IsZero() {
+		return fmt.Errorf("%w: zero uid", ErrInvalidUID)
+	}
+	return nil
 }
 
+// Value stores the composed uint64 of the uid
+func (uid UID) Value() (driver.Value, error) {
+	return int64(uid.composed()), nil
+}
+
+// Scan reads a uid stored as integer or as string,
+// either the raw integer or the base58 public form
 func (uid *UID) Scan(value interface{}) error {
 	if value == nil {
+		*uid = UID{}
 		return nil
 	}
 
-	var i uint32
+	var v uint64
 
 	switch t := value.(type) {
+	case int64:
+		v = uint64(t)
+	case uint64:
+		v = t
 	case int:
-		i = uint32(t)
-	case int8:
-		i = uint32(t) // standardizes across systems
-	case int16:
-		i = uint32(t) // standardizes across systems
+		v = uint64(t)
 	case int32:
-		i = uint32(t) // standardizes across systems
-	case int64:
-		i = uint32(t) // standardizes across systems
-	case uint8:
-		i = uint32(t) // standardizes across systems
-	case uint16:
-		i = uint32(t) // standardizes across systems
+		v = uint64(t)
 	case uint32:
-		i = t
-	case uint64:
-		i = uint32(t)
+		v = uint64(t)
 	case []byte:
-		a, err := strconv.Atoi(string(t))
-		if err != nil {
-			return err
-		}
-		i = uint32(a)
+		return uid.scanString(string(t))
+	case string:
+		return uid.scanString(t)
 	default:
-		return errors.New("invalid Scan Source")
+		return fmt.Errorf("invalid Scan Source %T", value)
+	}
+
+	*uid = uidFromUint64(v)
+
+	return nil
+}
+
+func (uid *UID) scanString(s string) error {
+	if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+		*uid = uidFromUint64(v)
+		return nil
+	}
+
+	decoded, err := FromBase58(s)
+	if err != nil {
+		return err
 	}
 
-	*uid = NewUID(i, 0, 1)
+	*uid = decoded
 
 	return nil
 }
 
+func (uid UID) composed() uint64 {
+	return uint64(uid.localID)<<28 | uint64(uid.objectType)<<18 | uint64(uid.shardID)<<0
+}
+
+func uidFromUint64(v uint64) UID {
+	return UID{
+		localID:    uint32(v >> 28),
+		objectType: int(v >> 18 & 0x3FF),
+		shardID:    uint32(v >> 0 & 0x3FFFF),
+	}
+}
+
 func (uid *UID) GetBSON() (interface{}, error) {
 	if uid == nil {
 		return nil, nil
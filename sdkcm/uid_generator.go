@@ -0,0 +1,129 @@
+package sdkcm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The 50 bits of a generated uid beside the object type hold, from the highest:
+// 40 bits of milliseconds since uidGenEpoch (until 2058), 5 bits of generator shard id
+// and a 5 bits sequence, so a generator makes up to 32 uids per millisecond.
+// Local id and shard id of these uids are parts of this layout, the 10 bits
+// of the object type can't be used as it's decoded from every uid.
+const (
+	uidGenTimeBits  = 40
+	uidGenShardBits = 5
+	uidGenSeqBits   = 5
+	uidGenTick      = time.Millisecond
+
+	// MaxUIDGenShardID is the max shard id of NewUIDGenerator
+	MaxUIDGenShardID = 1<<uidGenShardBits - 1
+
+	// the clock going back for less than it is waited for by default
+	defaultMaxClockBackward = 10 * time.Millisecond
+)
+
+var uidGenEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	// ErrClockMovedBackwards is returned by UIDGenerator.Next when the clock
+	// goes back for more than the max clock backward
+	ErrClockMovedBackwards = errors.New("clock moved backwards")
+)
+
+// UIDGenerator makes unique uids sorted by creation time without a database round trip.
+// Every running generator of an object type must have its own shard id.
+type UIDGenerator struct {
+	shardID          uint32
+	objectType       int
+	maxClockBackward time.Duration
+	now              func() time.Time
+	sleep            func(time.Duration)
+
+	mu       sync.Mutex
+	lastTick int64
+	seq      uint32
+}
+
+// NewUIDGenerator returns a generator of uids sorted by their millisecond.
+// The millisecond precision leaves 5 bits for the sequence: a generator makes
+// at most 32 uids per millisecond (32k per second) and Next waits for the next
+// millisecond beyond, more throughput takes more generators with their own shard id.
+func NewUIDGenerator(shardID uint32, objectType int) (*UIDGenerator, error) {
+	if shardID > MaxUIDGenShardID {
+		return nil, fmt.Errorf("uid generator shard id must be at most %d, got %d", MaxUIDGenShardID, shardID)
+	}
+	if objectType < 0 || objectType >= 1<<10 {
+		return nil, fmt.Errorf("uid object type must be in [0, 1023], got %d", objectType)
+	}
+
+	return &UIDGenerator{
+		shardID:          shardID,
+		objectType:       objectType,
+		maxClockBackward: defaultMaxClockBackward,
+		now:              time.Now,
+		sleep:            time.Sleep,
+		lastTick:         -1,
+	}, nil
+}
+
+// SetMaxClockBackward sets how long Next waits when the clock goes back,
+// it returns ErrClockMovedBackwards when the clock goes back further
+func (g *UIDGenerator) SetMaxClockBackward(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.maxClockBackward = d
+}
+
+// Next returns a uid greater than the ones returned before by the generator.
+// When the sequence of the current millisecond is exhausted or the clock went back,
+// it waits without holding the lock, the other callers aren't blocked.
+func (g *UIDGenerator) Next() (UID, error) {
+	for {
+		uid, wait, err := g.next()
+		if err != nil || wait == 0 {
+			return uid, err
+		}
+		g.sleep(wait)
+	}
+}
+
+// next returns a uid, or how long to wait before calling it again
+func (g *UIDGenerator) next() (UID, time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	elapsed := g.now().Sub(uidGenEpoch)
+	tick := int64(elapsed / uidGenTick)
+	if elapsed < 0 || tick >= 1<<uidGenTimeBits {
+		return UID{}, 0, fmt.Errorf("uid generator clock %s is out of range", uidGenEpoch.Add(elapsed).UTC().Format(time.RFC3339))
+	}
+
+	if tick < g.lastTick {
+		back := time.Duration(g.lastTick)*uidGenTick - elapsed
+		if back > g.maxClockBackward {
+			return UID{}, 0, fmt.Errorf("%w by %s", ErrClockMovedBackwards, back)
+		}
+		return UID{}, back, nil
+	}
+
+	if tick == g.lastTick {
+		// the sequence of this millisecond is exhausted
+		if g.seq == 1<<uidGenSeqBits-1 {
+			return UID{}, time.Duration(tick+1)*uidGenTick - elapsed, nil
+		}
+		g.seq++
+	} else {
+		g.seq = 0
+	}
+	g.lastTick = tick
+
+	v := uint64(tick)<<(uidGenShardBits+uidGenSeqBits) | uint64(g.shardID)<<uidGenSeqBits | uint64(g.seq)
+	return UID{
+		localID:    uint32(v >> 18),
+		objectType: g.objectType,
+		shardID:    uint32(v & 0x3FFFF),
+	}, 0, nil
+}
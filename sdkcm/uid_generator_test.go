@@ -0,0 +1,143 @@
+package sdkcm
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUIDGeneratorParallel(t *testing.T) {
+	g, err := NewUIDGenerator(3, 7)
+	assert.Nil(t, err, "must be nil")
+
+	// the real clock, the sequence is exhausted in the milliseconds
+	const workers, perWorker = 8, 5000
+	results := make([][]UID, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			uids := make([]UID, 0, perWorker)
+			for i := 0; i < perWorker; i++ {
+				uid, err := g.Next()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				uids = append(uids, uid)
+			}
+			results[w] = uids
+		}(w)
+	}
+	wg.Wait()
+
+	seen := make(map[UID]struct{}, workers*perWorker)
+	for _, uids := range results {
+		for i, uid := range uids {
+			assert.Equal(t, 7, uid.GetObjectType(), "should keep the object type")
+			if i > 0 && uid.composed() <= uids[i-1].composed() {
+				t.Fatalf("%d is generated after %d", uid.composed(), uids[i-1].composed())
+			}
+			if _, ok := seen[uid]; ok {
+				t.Fatalf("%d is duplicated", uid.composed())
+			}
+			seen[uid] = struct{}{}
+		}
+	}
+	assert.Equal(t, workers*perWorker, len(seen), "should be equal")
+}
+
+func TestUIDGeneratorSequenceExhausted(t *testing.T) {
+	g, err := NewUIDGenerator(1, 1)
+	assert.Nil(t, err, "must be nil")
+
+	now := uidGenEpoch.Add(1000*uidGenTick + 250*time.Microsecond)
+	var waits []time.Duration
+	g.now = func() time.Time { return now }
+	g.sleep = func(d time.Duration) {
+		waits = append(waits, d)
+		now = now.Add(d)
+	}
+
+	var last UID
+	for i := 0; i <= 1<<uidGenSeqBits; i++ {
+		uid, err := g.Next()
+		assert.Nil(t, err, "must be nil")
+		if i > 0 && uid.composed() <= last.composed() {
+			t.Fatalf("%d is generated after %d", uid.composed(), last.composed())
+		}
+		last = uid
+	}
+	assert.Equal(t, []time.Duration{uidGenTick - 250*time.Microsecond}, waits, "should wait for the next millisecond once")
+}
+
+func TestUIDGeneratorClockBackwards(t *testing.T) {
+	g, err := NewUIDGenerator(1, 1)
+	assert.Nil(t, err, "must be nil")
+
+	base := uidGenEpoch.Add(24 * time.Hour)
+	g.sleep = func(time.Duration) {}
+	calls := 0
+	g.now = func() time.Time {
+		calls++
+		switch calls {
+		case 1:
+			return base
+		case 2:
+			// a step back to the previous millisecond, shorter than the max, is waited for
+			return base.Add(-time.Millisecond)
+		default:
+			return base.Add(time.Millisecond)
+		}
+	}
+
+	first, err := g.Next()
+	assert.Nil(t, err, "must be nil")
+	second, err := g.Next()
+	assert.Nil(t, err, "must be nil")
+	assert.Greater(t, second.composed(), first.composed(), "should be sorted")
+
+	g.SetMaxClockBackward(time.Second)
+	g.now = func() time.Time { return base.Add(-time.Hour) }
+	_, err = g.Next()
+	assert.True(t, errors.Is(err, ErrClockMovedBackwards), "should be an error")
+}
+
+func TestNewUIDGenerator(t *testing.T) {
+	_, err := NewUIDGenerator(MaxUIDGenShardID+1, 1)
+	assert.NotNil(t, err, "should be an error")
+
+	_, err = NewUIDGenerator(1, 1024)
+	assert.NotNil(t, err, "should be an error")
+
+	g, err := NewUIDGenerator(MaxUIDGenShardID, 1023)
+	assert.Nil(t, err, "must be nil")
+
+	uid, err := g.Next()
+	assert.Nil(t, err, "must be nil")
+
+	// generated uids are valid public uids
+	decoded, err := DecodeUIDWithType(uid.String(), 1023)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, uid, decoded, "should be equal")
+}
+
+func BenchmarkUIDGenerator(b *testing.B) {
+	g, err := NewUIDGenerator(1, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := g.Next(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
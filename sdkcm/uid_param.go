@@ -0,0 +1,41 @@
+package sdkcm
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrInvalidUIDParam is the 400 error responded by UIDParam
+func ErrInvalidUIDParam(err error, name string) AppError {
+	return NewAppErr(err, http.StatusBadRequest, fmt.Sprintf("invalid %s", name)).WithCode("invalid_uid")
+}
+
+// UIDParam decodes the path param name, it aborts with 400 if the param is not a valid uid:
+//
+//	id, ok := sdkcm.UIDParam(c, "id")
+//	if !ok {
+//		return
+//	}
+func UIDParam(c *gin.Context, name string) (UID, bool) {
+	return uidParam(c, name, DecodeUID)
+}
+
+// UIDParamWithType is UIDParam also checking the object type
+func UIDParamWithType(c *gin.Context, name string, objectType int) (UID, bool) {
+	return uidParam(c, name, func(s string) (UID, error) {
+		return DecodeUIDWithType(s, objectType)
+	})
+}
+
+func uidParam(c *gin.Context, name string, decode func(string) (UID, error)) (UID, bool) {
+	uid, err := decode(c.Param(name))
+	if err != nil {
+		appErr := ErrInvalidUIDParam(err, name)
+		c.AbortWithStatusJSON(appErr.StatusCode, appErr)
+		return UID{}, false
+	}
+
+	return uid, true
+}
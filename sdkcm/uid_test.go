@@ -1,8 +1,18 @@
 package sdkcm
 
 import (
-	"github.com/stretchr/testify/assert"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 func TestNewUID(t *testing.T) {
@@ -47,3 +57,271 @@ func TestDecomposeUID(t *testing.T) {
 	_, err := DecomposeUID(wrongFormat)
 	assert.NotNil(t, err, "should be an error")
 }
+
+func TestUIDScan(t *testing.T) {
+	expect := NewUID(3, 1, 1)
+
+	for _, src := range []interface{}{
+		int64(805568513),
+		uint64(805568513),
+		[]byte("805568513"),
+		"805568513",
+		expect.String(),
+	} {
+		var uid UID
+		assert.Nil(t, uid.Scan(src), "must be nil")
+		assert.Equal(t, expect, uid, "should be equal")
+	}
+
+	var uid UID
+	assert.NotNil(t, uid.Scan(1.5), "should be an error")
+	assert.NotNil(t, uid.Scan("not-a-uid"), "should be an error")
+}
+
+func TestUIDDatabaseRoundTrip(t *testing.T) {
+	type item struct {
+		ID       int
+		UID      UID
+		ParentID *UID
+	}
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.Nil(t, err, "must be nil")
+	assert.Nil(t, db.AutoMigrate(&item{}), "must be nil")
+
+	parent := NewUID(1, 2, 3)
+	assert.Nil(t, db.Create(&item{ID: 1, UID: NewUID(10, 1, 1), ParentID: &parent}).Error, "must be nil")
+	assert.Nil(t, db.Create(&item{ID: 2, UID: NewUID(20, 1, 1)}).Error, "must be nil")
+
+	var raw uint64
+	assert.Nil(t, db.Raw("SELECT uid FROM items WHERE id = 1").Scan(&raw).Error, "must be nil")
+	assert.Equal(t, uint64(2684616705), raw, "should store the composed uint64")
+
+	var first, second item
+	assert.Nil(t, db.First(&first, 1).Error, "must be nil")
+	assert.Equal(t, NewUID(10, 1, 1), first.UID, "should be equal")
+	assert.Equal(t, &parent, first.ParentID, "should be equal")
+
+	assert.Nil(t, db.First(&second, 2).Error, "must be nil")
+	assert.Equal(t, NewUID(20, 1, 1), second.UID, "should be equal")
+	assert.Nil(t, second.ParentID, "NULL column must stay nil")
+}
+
+func TestDecodeUID(t *testing.T) {
+	expect := NewUID(3, 7, 1)
+
+	uid, err := DecodeUID(expect.String())
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, expect, uid, "should be equal")
+
+	uid, err = ParseUID(expect.String())
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, expect, uid, "should be equal")
+
+	for _, s := range []string{
+		"",
+		"0OIl",                              // not base58
+		base58.Encode([]byte("12a")),        // not decimal
+		base58.Encode([]byte("0805568513")), // leading zero
+		"1" + expect.String(),               // leading zero byte
+		base58.Encode([]byte("99999999999999999999")), // overflows uint64
+		base58.Encode([]byte("1152921504606846976")),  // overflows 60 bits
+		NewUID(0, 7, 1).String(),                      // zero local id
+	} {
+		_, err := DecodeUID(s)
+		assert.True(t, errors.Is(err, ErrInvalidUID), "should be an error: %q", s)
+	}
+
+	_, err = DecodeUIDWithType(expect.String(), 7)
+	assert.Nil(t, err, "must be nil")
+
+	_, err = DecodeUIDWithType(expect.String(), 8)
+	assert.True(t, errors.Is(err, ErrInvalidUID), "should be an error")
+}
+
+func TestUIDParam(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	expect := NewUID(3, 7, 1)
+
+	router := gin.New()
+	router.GET("/items/:id", func(c *gin.Context) {
+		id, ok := UIDParamWithType(c, "id", 7)
+		if !ok {
+			return
+		}
+		c.String(http.StatusOK, id.String())
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/"+expect.String(), nil))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, expect.String(), w.Body.String(), "should be equal")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/"+NewUID(3, 8, 1).String(), nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "should be equal")
+	assert.Contains(t, w.Body.String(), `"code":"invalid_uid"`, "should be the standard error")
+	assert.Contains(t, w.Body.String(), `"message":"invalid id"`, "should be the standard error")
+}
+
+func TestUIDJSONRoundTrip(t *testing.T) {
+	type item struct {
+		ID       UID      `json:"id"`
+		ParentID *UID     `json:"parent_id"`
+		AdminID  PlainUID `json:"admin_id"`
+	}
+
+	for _, uid := range []UID{
+		{},
+		NewUID(1, 0, 0),
+		NewUID(1, 1, 1),
+		NewUID(3, 7, 1),
+		NewUID(math.MaxUint32, 1<<10-1, 1<<18-1),
+	} {
+		for _, mode := range []UIDEncodeMode{UIDEncodeBase58, UIDEncodePlain} {
+			SetUIDEncodeMode(mode)
+
+			in := item{ID: uid, ParentID: &uid, AdminID: PlainUID{uid}}
+			data, err := json.Marshal(in)
+			assert.Nil(t, err, "must be nil")
+
+			var out item
+			assert.Nil(t, json.Unmarshal(data, &out), "must be nil: %s", data)
+			assert.Equal(t, in, out, "should be equal: %s", data)
+		}
+	}
+	SetUIDEncodeMode(UIDEncodeBase58)
+}
+
+func TestUIDMarshalJSON(t *testing.T) {
+	uid := NewUID(3, 1, 1)
+
+	data, err := json.Marshal(uid)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, `"`+uid.String()+`"`, string(data), "should be equal")
+
+	data, err = json.Marshal(PlainUID{uid})
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "805568513", string(data), "should be equal")
+
+	SetUIDEncodeMode(UIDEncodePlain)
+	defer SetUIDEncodeMode(UIDEncodeBase58)
+
+	data, err = json.Marshal(uid)
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, "805568513", string(data), "should be equal")
+}
+
+func TestUIDUnmarshalJSON(t *testing.T) {
+	expect := NewUID(3, 1, 1)
+
+	for _, c := range []struct {
+		data   string
+		expect UID
+	}{
+		{data: `"` + expect.String() + `"`, expect: expect},
+		{data: `805568513`, expect: expect},
+		{data: `0`, expect: UID{}},
+		{data: `null`, expect: UID{}},
+		{data: `""`, expect: UID{}},
+		{data: `1152921504606846975`, expect: NewUID(math.MaxUint32, 1<<10-1, 1<<18-1)},
+	} {
+		uid := NewUID(9, 9, 9)
+		assert.Nil(t, uid.UnmarshalJSON([]byte(c.data)), "must be nil: %s", c.data)
+		assert.Equal(t, c.expect, uid, "should be equal: %s", c.data)
+	}
+
+	for _, data := range []string{
+		`1152921504606846976`,  // overflows 60 bits
+		`99999999999999999999`, // overflows uint64
+		`"` + base58.Encode([]byte("1152921504606846976")) + `"`,
+	} {
+		var uid UID
+		err := uid.UnmarshalJSON([]byte(data))
+		assert.True(t, errors.Is(err, ErrInvalidUID), "should be an error: %s", data)
+		assert.Contains(t, err.Error(), "max local id is 4294967295", "should state the max local id")
+	}
+
+	for _, data := range []string{
+		`-1`,
+		`1.5`,
+		`true`,
+		`"0OIl"`, // not base58
+		`"` + base58.Encode([]byte("12a")) + `"`,
+		`"` + NewUID(0, 7, 1).String() + `"`, // zero local id
+		`"unterminated`,
+	} {
+		var uid UID
+		err := uid.UnmarshalJSON([]byte(data))
+		assert.True(t, errors.Is(err, ErrInvalidUID), "should be an error: %s", data)
+	}
+}
+
+func FuzzDecodeUID(f *testing.F) {
+	for _, s := range []string{NewUID(1, 1, 1).String(), "", "0", "abc", "1111", base58.Encode([]byte("99999999999999999999"))} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		uid, err := DecodeUID(s)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidUID) {
+				t.Fatalf("error of %q doesn't wrap ErrInvalidUID: %v", s, err)
+			}
+			return
+		}
+
+		// only canonical encodings are accepted
+		if uid.String() != s {
+			t.Fatalf("%q is decoded to %v encoded as %q", s, uid, uid.String())
+		}
+	})
+}
+
+func BenchmarkDecodeUID(b *testing.B) {
+	s := NewUID(123456, 7, 89).String()
+
+	b.Run("DecodeUID", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = DecodeUID(s)
+		}
+	})
+
+	b.Run("FromBase58", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = FromBase58(s)
+		}
+	})
+}
+
+func TestUIDIsZero(t *testing.T) {
+	assert.True(t, UID{}.IsZero(), "should be zero")
+	assert.False(t, NewUID(1, 0, 0).IsZero(), "should not be zero")
+	assert.False(t, NewUID(0, 1, 0).IsZero(), "should not be zero")
+
+	type item struct {
+		ID       UID  `json:"id,omitempty"`
+		ParentID *UID `json:"parent_id,omitempty"`
+	}
+	data, err := json.Marshal(item{})
+	assert.Nil(t, err, "must be nil")
+	assert.Contains(t, string(data), `"id":`, "omitempty should not omit a UID")
+	assert.NotContains(t, string(data), "parent_id", "a nil *UID should be omitted")
+}
+
+func TestRequiredUID(t *testing.T) {
+	expect := NewUID(3, 1, 1)
+
+	var uid RequiredUID
+	assert.Nil(t, json.Unmarshal([]byte(`"`+expect.String()+`"`), &uid), "must be nil")
+	assert.Equal(t, expect, uid.UID, "should be equal")
+
+	for _, data := range []string{`null`, `""`, `0`, `"abc"`} {
+		err := json.Unmarshal([]byte(data), &uid)
+		assert.True(t, errors.Is(err, ErrInvalidUID), "should be an invalid uid: %s", data)
+	}
+
+	data, err := json.Marshal(RequiredUID{expect})
+	assert.Nil(t, err, "must be nil")
+	assert.Equal(t, `"`+expect.String()+`"`, string(data), "should be marshaled like UID")
+}
@@ -0,0 +1,150 @@
+package sdkcm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidationMessage returns the message of an invalid field, replace it to
+// translate messages. The field name is not part of the message.
+var ValidationMessage = func(fe validator.FieldError) string {
+	return defaultValidationMessage(fe)
+}
+
+// ErrValidation is the 400 error of a request failing validation, with one FieldError per invalid field
+func ErrValidation(err error) AppError {
+	return NewAppErr(err, http.StatusBadRequest, "invalid request").
+		WithCode("invalid_request").
+		WithFields(ValidationFieldErrors(err)...)
+}
+
+// ValidationFieldErrors converts validator errors to field errors named by their JSON path,
+// e.g. items[0].name. It returns nil when err doesn't hold validator errors.
+func ValidationFieldErrors(err error) []FieldError {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: ValidationMessage(fe),
+		})
+	}
+	return fields
+}
+
+// fieldPath drops the struct name of the namespace, e.g. Order.items[0].name => items[0].name
+func fieldPath(fe validator.FieldError) string {
+	if _, path, found := strings.Cut(fe.Namespace(), "."); found {
+		return path
+	}
+	return fe.Field()
+}
+
+// JSONTagName names fields by their JSON tag in validator errors:
+//
+//	v.RegisterTagNameFunc(sdkcm.JSONTagName)
+func JSONTagName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// ValidateUID is the "uid" rule, the field must be an encoded UID string
+func ValidateUID(fl validator.FieldLevel) bool {
+	if fl.Field().Kind() != reflect.String {
+		return false
+	}
+	_, err := DecodeUID(fl.Field().String())
+	return err == nil
+}
+
+// ValidateUIDRequired is the "uid_required" rule, the field must be a non zero
+// UID. A nil *UID fails too, unless the field is omitempty.
+func ValidateUIDRequired(fl validator.FieldLevel) bool {
+	uid, ok := fl.Field().Interface().(interface{ IsZero() bool })
+	return ok && !uid.IsZero()
+}
+
+func defaultValidationMessage(fe validator.FieldError) string {
+	param := fe.Param()
+	isString := fe.Kind() == reflect.String
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Array || fe.Kind() == reflect.Map
+
+	unit := ""
+	switch {
+	case isString:
+		unit = " characters"
+	case isList:
+		unit = " items"
+	}
+
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless", "required_with", "required_without", "uid_required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url", "http_url", "uri":
+		return "must be a valid URL"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "uid":
+		return "must be a valid id"
+	case "ip", "ipv4", "ipv6":
+		return "must be a valid IP address"
+	case "datetime":
+		return fmt.Sprintf("must be a date time like %s", param)
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", strings.Join(strings.Fields(param), ", "))
+	case "len":
+		return fmt.Sprintf("must have exactly %s%s", param, unit)
+	case "min":
+		if isString || isList {
+			return fmt.Sprintf("must have at least %s%s", param, unit)
+		}
+		return fmt.Sprintf("must be at least %s", param)
+	case "max":
+		if isString || isList {
+			return fmt.Sprintf("must have at most %s%s", param, unit)
+		}
+		return fmt.Sprintf("must be at most %s", param)
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", param)
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s", param)
+	case "lt":
+		return fmt.Sprintf("must be less than %s", param)
+	case "lte":
+		return fmt.Sprintf("must be less than or equal to %s", param)
+	case "eqfield":
+		return fmt.Sprintf("must be equal to %s", param)
+	case "nefield":
+		return fmt.Sprintf("must be different from %s", param)
+	case "alpha":
+		return "must contain letters only"
+	case "alphanum":
+		return "must contain letters and numbers only"
+	case "numeric", "number":
+		return "must be a number"
+	}
+
+	if param != "" {
+		return fmt.Sprintf("must satisfy %s=%s", fe.Tag(), param)
+	}
+	return fmt.Sprintf("must satisfy %s", fe.Tag())
+}
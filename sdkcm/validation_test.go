@@ -0,0 +1,97 @@
+package sdkcm
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+type orderItem struct {
+	Name string `json:"name" validate:"required"`
+	Qty  int    `json:"qty" validate:"min=1"`
+}
+
+type order struct {
+	ID     string      `json:"id" validate:"uid"`
+	Email  string      `json:"email" validate:"email"`
+	Status string      `json:"status,omitempty" validate:"oneof=new paid"`
+	Note   string      `validate:"max=3"`
+	Items  []orderItem `json:"items" validate:"min=1,dive"`
+}
+
+func TestValidationFieldErrors(t *testing.T) {
+	v := validator.New()
+	v.RegisterTagNameFunc(JSONTagName)
+	assert.Nil(t, v.RegisterValidation("uid", ValidateUID), "must be nil")
+
+	err := v.Struct(order{
+		ID:     NewUID(1, 1, 1).String(),
+		Email:  "john",
+		Status: "lost",
+		Note:   "too long",
+		Items:  []orderItem{{Qty: 1}, {Name: "pen"}},
+	})
+
+	assert.Equal(t, []FieldError{
+		{Field: "email", Rule: "email", Message: "must be a valid email address"},
+		{Field: "status", Rule: "oneof", Param: "new paid", Message: "must be one of new, paid"},
+		{Field: "Note", Rule: "max", Param: "3", Message: "must have at most 3 characters"},
+		{Field: "items[0].name", Rule: "required", Message: "is required"},
+		{Field: "items[1].qty", Rule: "min", Param: "1", Message: "must be at least 1"},
+	}, ValidationFieldErrors(err), "should be equal")
+
+	err = v.Struct(order{ID: "1", Email: "john@example.com", Status: "new"})
+	assert.Equal(t, []FieldError{
+		{Field: "id", Rule: "uid", Message: "must be a valid id"},
+		{Field: "items", Rule: "min", Param: "1", Message: "must have at least 1 items"},
+	}, ValidationFieldErrors(err), "should be equal")
+
+	appErr := ErrValidation(err)
+	assert.Equal(t, http.StatusBadRequest, appErr.StatusCode, "should be equal")
+	assert.Equal(t, "invalid_request", appErr.Code, "should be equal")
+	assert.Equal(t, 2, len(appErr.Fields), "should be equal")
+
+	assert.Nil(t, ValidationFieldErrors(ErrDataNotFound), "must be nil")
+}
+
+func TestValidationMessage(t *testing.T) {
+	defer func(translate func(validator.FieldError) string) { ValidationMessage = translate }(ValidationMessage)
+	ValidationMessage = func(fe validator.FieldError) string {
+		if fe.Tag() == "required" {
+			return "est obligatoire"
+		}
+		return defaultValidationMessage(fe)
+	}
+
+	err := validator.New().Struct(orderItem{Qty: 1})
+	assert.Equal(t, "est obligatoire", ValidationFieldErrors(err)[0].Message, "should be equal")
+}
+
+func TestValidateUIDRequired(t *testing.T) {
+	type request struct {
+		ID       UID  `json:"id" validate:"uid_required"`
+		ParentID *UID `json:"parent_id" validate:"uid_required"`
+		OwnerID  *UID `json:"owner_id" validate:"omitempty,uid_required"`
+	}
+
+	v := validator.New()
+	v.RegisterTagNameFunc(JSONTagName)
+	assert.Nil(t, v.RegisterValidation("uid_required", ValidateUIDRequired), "must be nil")
+
+	uid, zero := NewUID(1, 1, 1), UID{}
+	assert.Nil(t, v.Struct(request{ID: uid, ParentID: &uid}), "must be nil")
+
+	err := v.Struct(request{ParentID: &zero, OwnerID: &zero})
+	assert.Equal(t, []FieldError{
+		{Field: "id", Rule: "uid_required", Message: "is required"},
+		{Field: "parent_id", Rule: "uid_required", Message: "is required"},
+		{Field: "owner_id", Rule: "uid_required", Message: "is required"},
+	}, ValidationFieldErrors(err), "should be equal")
+
+	err = v.Struct(request{ID: uid})
+	assert.Equal(t, []FieldError{
+		{Field: "parent_id", Rule: "uid_required", Message: "is required"},
+	}, ValidationFieldErrors(err), "a nil pointer should fail unless omitempty")
+}
@@ -0,0 +1,146 @@
+package sdktest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+// Entry is a log entry recorded by Logger
+type Entry struct {
+	Level   string
+	Prefix  string
+	Message string
+	Fields  logger.Fields
+}
+
+// Logger records the entries of all levels instead of writing them,
+// it's the logger.ServiceLogger of the test services
+type Logger struct {
+	mu      *sync.Mutex
+	entries *[]Entry
+}
+
+func NewLogger() *Logger {
+	return &Logger{mu: &sync.Mutex{}, entries: &[]Entry{}}
+}
+
+func (l *Logger) GetLogger(prefix string) logger.Logger {
+	return &entryLogger{rec: l, prefix: prefix, fields: logger.Fields{}}
+}
+
+func (l *Logger) SetLevel(string, string) error { return nil }
+
+func (l *Logger) Levels() map[string]string { return map[string]string{"": "debug"} }
+
+// Entries returns the recorded entries in order
+func (l *Logger) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Entry{}, *l.entries...)
+}
+
+// Find returns the entries of the level containing substr, any level when level is empty
+func (l *Logger) Find(level, substr string) []Entry {
+	var found []Entry
+	for _, e := range l.Entries() {
+		if (level == "" || e.Level == level) && strings.Contains(e.Message, substr) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Reset forgets the recorded entries
+func (l *Logger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.entries = nil
+}
+
+func (l *Logger) add(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.entries = append(*l.entries, e)
+}
+
+type entryLogger struct {
+	rec    *Logger
+	prefix string
+	fields logger.Fields
+}
+
+func (l *entryLogger) log(level, msg string) {
+	fields := make(logger.Fields, len(l.fields))
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	l.rec.add(Entry{Level: level, Prefix: l.prefix, Message: msg, Fields: fields})
+}
+
+func (l *entryLogger) Print(args ...interface{})   { l.log("info", fmt.Sprint(args...)) }
+func (l *entryLogger) Debug(args ...interface{})   { l.log("debug", fmt.Sprint(args...)) }
+func (l *entryLogger) Debugln(args ...interface{}) { l.log("debug", sprintln(args...)) }
+func (l *entryLogger) Debugf(format string, args ...interface{}) {
+	l.log("debug", fmt.Sprintf(format, args...))
+}
+func (l *entryLogger) Info(args ...interface{})   { l.log("info", fmt.Sprint(args...)) }
+func (l *entryLogger) Infoln(args ...interface{}) { l.log("info", sprintln(args...)) }
+func (l *entryLogger) Infof(format string, args ...interface{}) {
+	l.log("info", fmt.Sprintf(format, args...))
+}
+func (l *entryLogger) Warn(args ...interface{})   { l.log("warn", fmt.Sprint(args...)) }
+func (l *entryLogger) Warnln(args ...interface{}) { l.log("warn", sprintln(args...)) }
+func (l *entryLogger) Warnf(format string, args ...interface{}) {
+	l.log("warn", fmt.Sprintf(format, args...))
+}
+func (l *entryLogger) Error(args ...interface{})   { l.log("error", fmt.Sprint(args...)) }
+func (l *entryLogger) Errorln(args ...interface{}) { l.log("error", sprintln(args...)) }
+func (l *entryLogger) Errorf(format string, args ...interface{}) {
+	l.log("error", fmt.Sprintf(format, args...))
+}
+
+// Fatal is recorded then panics instead of exiting the tests
+func (l *entryLogger) Fatal(args ...interface{})   { l.panic("fatal", fmt.Sprint(args...)) }
+func (l *entryLogger) Fatalln(args ...interface{}) { l.panic("fatal", sprintln(args...)) }
+func (l *entryLogger) Fatalf(format string, args ...interface{}) {
+	l.panic("fatal", fmt.Sprintf(format, args...))
+}
+func (l *entryLogger) Panic(args ...interface{})   { l.panic("panic", fmt.Sprint(args...)) }
+func (l *entryLogger) Panicln(args ...interface{}) { l.panic("panic", sprintln(args...)) }
+func (l *entryLogger) Panicf(format string, args ...interface{}) {
+	l.panic("panic", fmt.Sprintf(format, args...))
+}
+
+func (l *entryLogger) panic(level, msg string) {
+	l.log(level, msg)
+	panic(msg)
+}
+
+func (l *entryLogger) With(key string, value interface{}) logger.Logger {
+	return l.Withs(logger.Fields{key: value})
+}
+
+func (l *entryLogger) Withs(fields logger.Fields) logger.Logger {
+	merged := make(logger.Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &entryLogger{rec: l.rec, prefix: l.prefix, fields: merged}
+}
+
+func (l *entryLogger) WithSrc() logger.Logger { return l }
+
+func (l *entryLogger) WithContext(context.Context) logger.Logger { return l }
+
+func (l *entryLogger) GetLevel() string { return "debug" }
+
+func sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
@@ -0,0 +1,82 @@
+package sdktest
+
+import (
+	"flag"
+	"sync"
+)
+
+// Recorder records the lifecycle calls of RunnableStubs in order,
+// ex: [db configure, db run, cache configure, cache run, cache stop, db stop]
+type Recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *Recorder) add(call string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+// Calls returns the recorded calls, "<name> <method>"
+func (r *Recorder) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+// RunnableStub is a plugin doing nothing but recording its lifecycle,
+// its Get returns Value. Registered with WithPlugin or goservice.WithInitRunnable.
+type RunnableStub struct {
+	// Name of the component, Prefix when empty
+	ID       string
+	Prefix   string
+	Value    interface{}
+	Recorder *Recorder
+	// prefixes of the components running before it, see goservice.Dependent
+	Dependencies []string
+	// returned by Configure and Run
+	ConfigureErr error
+	RunErr       error
+}
+
+func (r *RunnableStub) Name() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Prefix
+}
+
+func (r *RunnableStub) GetPrefix() string { return r.Prefix }
+
+func (r *RunnableStub) Get() interface{} { return r.Value }
+
+func (r *RunnableStub) DependsOn() []string { return r.Dependencies }
+
+func (r *RunnableStub) InitFlags() {}
+
+func (r *RunnableStub) InitFlagSet(*flag.FlagSet) {}
+
+func (r *RunnableStub) Configure() error {
+	r.Recorder.add(r.Name() + " configure")
+	return r.ConfigureErr
+}
+
+// Run configures the stub like the plugins do
+func (r *RunnableStub) Run() error {
+	if err := r.Configure(); err != nil {
+		return err
+	}
+	r.Recorder.add(r.Name() + " run")
+	return r.RunErr
+}
+
+func (r *RunnableStub) Stop() <-chan bool {
+	r.Recorder.add(r.Name() + " stop")
+	c := make(chan bool, 1)
+	c <- true
+	return c
+}
@@ -0,0 +1,162 @@
+// Package sdktest runs services built on the SDK in tests: flags are isolated,
+// signals are ignored and the http server is served in memory.
+//
+//	ts := sdktest.NewTestService(t,
+//		sdktest.WithFlags(map[string]string{"gin-cors-enabled": "true"}),
+//		sdktest.WithComponent("mysql", db),
+//	)
+//	ts.HTTPServer().AddHandler(routes)
+//	w := ts.PerformRequest(http.MethodGet, "/users", nil, nil)
+package sdktest
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	goservice "github.com/taimaifika/go-sdk"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+const defaultName = "test"
+
+type options struct {
+	name        string
+	flags       map[string]string
+	serviceOpts []goservice.Option
+	doubles     []goservice.PrefixRunnable
+}
+
+type Option func(*options)
+
+// WithName names the service, test by default
+func WithName(name string) Option {
+	return func(o *options) { o.name = name }
+}
+
+// WithFlags sets flags like command line args. Ex: {"gin-request-timeout": "1s"}
+func WithFlags(values map[string]string) Option {
+	return func(o *options) {
+		for name, value := range values {
+			o.flags[name] = value
+		}
+	}
+}
+
+// WithServiceOptions passes options to goservice.New, e.g. the plugins of the service
+func WithServiceOptions(opts ...goservice.Option) Option {
+	return func(o *options) { o.serviceOpts = append(o.serviceOpts, opts...) }
+}
+
+// WithComponent makes Get and MustGet return value for prefix,
+// it replaces the plugin registered with the prefix. Ex: a mock of mysql.
+func WithComponent(prefix string, value interface{}) Option {
+	return func(o *options) {
+		o.doubles = append(o.doubles, &RunnableStub{ID: prefix, Prefix: prefix, Value: value})
+	}
+}
+
+// WithPlugin replaces the plugin registered with the prefix of r by r,
+// e.g. a RunnableStub recording the lifecycle
+func WithPlugin(r goservice.PrefixRunnable) Option {
+	return func(o *options) { o.doubles = append(o.doubles, r) }
+}
+
+// TestService is a service whose http server is served in memory.
+// It's stopped when the test ends.
+type TestService struct {
+	goservice.Service
+	t      testing.TB
+	logger *Logger
+
+	initOnce sync.Once
+	initErr  error
+	handler  http.Handler
+}
+
+// NewTestService creates a service with its own flags, ignoring signals
+// and logging to a Logger. The http server never listens, see PerformRequest.
+func NewTestService(t testing.TB, opts ...Option) *TestService {
+	t.Helper()
+
+	o := &options{name: defaultName, flags: map[string]string{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	rec := NewLogger()
+	previous := logger.GetCurrent()
+
+	serviceOpts := []goservice.Option{
+		goservice.WithName(o.name),
+		goservice.WithLogger(rec),
+		goservice.WithSignalsDisabled(),
+		goservice.WithArgs([]string{}),
+		// not the flags of flag.CommandLine, e.g. test.v
+		goservice.WithAppFlagSet(flag.NewFlagSet(o.name, flag.ContinueOnError)),
+	}
+	serviceOpts = append(serviceOpts, o.serviceOpts...)
+	// after the plugins they replace
+	for _, double := range o.doubles {
+		serviceOpts = append(serviceOpts, goservice.WithReplacedRunnable(double))
+	}
+	serviceOpts = append(serviceOpts, goservice.WithFlagValues(o.flags))
+
+	sv := goservice.New(serviceOpts...)
+
+	ts := &TestService{Service: sv, t: t, logger: rec}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := sv.Shutdown(ctx); err != nil {
+			t.Errorf("stop test service: %s", err.Error())
+		}
+		logger.SetCurrent(previous)
+	})
+	return ts
+}
+
+// Init runs Init of the service once, Handler and PerformRequest call it
+func (ts *TestService) Init() error {
+	ts.initOnce.Do(func() {
+		if ts.initErr = ts.Service.Init(); ts.initErr != nil {
+			return
+		}
+		ts.handler, ts.initErr = ts.HTTPServer().Handler()
+	})
+	return ts.initErr
+}
+
+// Handler returns the handler of the http server, the test fails if Init fails.
+// Add the http handlers before.
+func (ts *TestService) Handler() http.Handler {
+	ts.t.Helper()
+	if err := ts.Init(); err != nil {
+		ts.t.Fatalf("init test service: %s", err.Error())
+	}
+	return ts.handler
+}
+
+// PerformRequest serves a request in memory, body can be nil
+func (ts *TestService) PerformRequest(method, path string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+
+	req := httptest.NewRequest(method, path, body)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	w := httptest.NewRecorder()
+	ts.Handler().ServeHTTP(w, req)
+	return w
+}
+
+// Logs returns the logger recording the entries of the service
+func (ts *TestService) Logs() *Logger {
+	return ts.logger
+}
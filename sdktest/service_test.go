@@ -0,0 +1,81 @@
+package sdktest_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	goservice "github.com/taimaifika/go-sdk"
+	"github.com/taimaifika/go-sdk/sdktest"
+)
+
+type usersRepo interface {
+	Name(id string) string
+}
+
+type fakeRepo struct{}
+
+func (fakeRepo) Name(id string) string { return "user " + id }
+
+func TestPerformRequest(t *testing.T) {
+	ts := sdktest.NewTestService(t,
+		sdktest.WithServiceOptions(goservice.WithInitRunnable(&sdktest.RunnableStub{Prefix: "mysql", Value: "real db"})),
+		sdktest.WithComponent("mysql", fakeRepo{}),
+	)
+	ts.HTTPServer().AddHandler(func(engine *gin.Engine) {
+		engine.POST("/users/:id", func(c *gin.Context) {
+			repo := ts.MustGet("mysql").(usersRepo)
+			ts.Logger("users").Infof("get %s", c.Param("id"))
+			c.String(http.StatusOK, repo.Name(c.Param("id"))+" "+c.GetHeader("X-Tenant"))
+		})
+	})
+
+	w := ts.PerformRequest(http.MethodPost, "/users/7", strings.NewReader("{}"), map[string]string{"X-Tenant": "acme"})
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "user 7 acme", w.Body.String(), "the double should replace the plugin")
+
+	entries := ts.Logs().Find("info", "get 7")
+	if assert.Len(t, entries, 1, "should be recorded") {
+		assert.Equal(t, "users", entries[0].Prefix, "should be equal")
+	}
+}
+
+func TestFlags(t *testing.T) {
+	// two services in a test don't share their flags
+	first := sdktest.NewTestService(t, sdktest.WithFlags(map[string]string{"gin-port": "1234"}))
+	second := sdktest.NewTestService(t)
+	assert.Equal(t, 1234, first.HTTPServer().GetConfig().Port, "should be equal")
+	assert.NotEqual(t, 1234, second.HTTPServer().GetConfig().Port, "should be the default")
+
+	var source goservice.ConfigSource
+	for _, e := range first.EffectiveConfig() {
+		if e.Name == "gin-port" {
+			source = e.Source
+		}
+	}
+	assert.Equal(t, goservice.SourceCLI, source, "should be equal")
+
+	unknown := sdktest.NewTestService(t, sdktest.WithFlags(map[string]string{"no-such-flag": "1"}))
+	assert.NotNil(t, unknown.Init(), "should be an error")
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	rec := &sdktest.Recorder{}
+	ts := sdktest.NewTestService(t,
+		sdktest.WithServiceOptions(
+			goservice.WithInitRunnable(&sdktest.RunnableStub{Prefix: "db", Recorder: rec}),
+			goservice.WithInitRunnable(&sdktest.RunnableStub{Prefix: "cache", Recorder: rec, Dependencies: []string{"db"}}),
+		),
+	)
+
+	assert.Nil(t, ts.Init(), "must be nil")
+	ts.Stop()
+	calls := rec.Calls()
+	assert.Equal(t, []string{"db configure", "db run", "cache configure", "cache run", "cache stop", "db stop"}, calls, "should be equal")
+
+	failing := sdktest.NewTestService(t, sdktest.WithPlugin(&sdktest.RunnableStub{Prefix: "db", RunErr: errors.New("refused")}))
+	assert.NotNil(t, failing.Init(), "should be an error")
+}
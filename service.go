@@ -1,17 +1,23 @@
 package goservice
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/taimaifika/go-sdk/httpserver"
 	"github.com/taimaifika/go-sdk/logger"
+	"github.com/taimaifika/go-sdk/plugin/grpcserver"
+	"github.com/taimaifika/go-sdk/plugin/otel"
 )
 
 const (
@@ -21,44 +27,144 @@ const (
 	DefaultEnv = DevEnv
 )
 
+const defaultShutdownTimeout = 30 * time.Second
+
 type service struct {
 	name         string
 	version      string
+	commit       string
+	buildTime    string
+	startTime    time.Time
 	env          string
+	envPrefix    string
+	profile      string
+	configFile   string
+	configStrict bool
 	opts         []Option
 	subServices  []Runnable
 	initServices map[string]PrefixRunnable
+	initPrefixes []string
 	isRegister   bool
 	logger       logger.Logger
 	httpServer   HttpServer
+	httpDisabled bool
+	adminServer  AdminServer
+	grpcServer   GrpcServer
 	signalChan   chan os.Signal
 	cmdLine      *AppFlagSet
 	stopFunc     func()
+	initErr      error
+	flagSources  map[string]ConfigSource
+	flagOwners   map[string]string
+	// deprecated flag name => current name, see FlagAliaser
+	flagAliases map[string]string
+	// flag metadata of the service, see initFlagMeta
+	deprecatedFlags map[string]string
+	requiredFlags   map[string]bool
+	profileDefaults map[string]map[string]string
+	// flags of the application, flag.CommandLine unless WithAppFlagSet
+	appFlags *flag.FlagSet
+	// flags whose value was substituted by a FlagValueResolver
+	resolvedFlags map[string]bool
+	// values of WithFlagValues, applied like command line args
+	flagValues map[string]string
+	// Start doesn't stop on SIGINT and SIGTERM, set by WithSignalsDisabled
+	signalsDisabled bool
+	// parsed into cmdLine, os.Args[1:] unless WithArgs
+	args []string
+	// args of the flags not in cmdLine are dropped, see WithUnknownFlagsIgnored
+	unknownFlagsIgnored bool
+
+	// servers of WithHTTPServer by name, in the order of httpServerNames
+	httpServers     map[string]HttpServer
+	httpServerNames []string
+
+	// initPrefixes sorted by dependencies, set by Init
+	initOrder []string
+
+	waitForReady   string
+	startupTimeout time.Duration
+	readiness      readiness
+
+	onInit     []Hook
+	onStarted  []Hook
+	onStopping []Hook
+
+	printEffectiveConfig bool
+	// Init prints the startup info and exits
+	printConfig bool
+	// Init runs Check and exits, see the flag check
+	checkMode       bool
+	checkFormat     string
+	checkTimeout    time.Duration
+	shutdownTimeout time.Duration
+	shutdownOnce    sync.Once
+	shutdownErr     error
+	doneChan        chan struct{}
 }
 
 func New(opts ...Option) Service {
 	sv := &service{
-		opts:         opts,
-		signalChan:   make(chan os.Signal, 1),
-		subServices:  []Runnable{},
-		initServices: map[string]PrefixRunnable{},
+		opts:          opts,
+		signalChan:    make(chan os.Signal, 1),
+		doneChan:      make(chan struct{}),
+		subServices:   []Runnable{},
+		initServices:  map[string]PrefixRunnable{},
+		flagSources:   map[string]ConfigSource{},
+		flagOwners:    map[string]string{},
+		flagAliases:   map[string]string{},
+		resolvedFlags: map[string]bool{},
+		httpServers:   map[string]HttpServer{},
+		startTime:     time.Now(),
+	}
+	if len(os.Args) > 1 {
+		sv.args = os.Args[1:]
 	}
 
 	// init default logger
 	logger.InitServLogger(false)
 	sv.logger = logger.GetCurrent().GetLogger("service")
 
+	sv.initFlagMeta()
+
 	for _, opt := range opts {
 		opt(sv)
 	}
 
+	// telemetry is set up first and flushed last,
+	// unless the application registered its own otel component
+	if _, ok := sv.initServices[otelPrefix]; !ok {
+		sv.initServices[otelPrefix] = otel.NewOtelPlugin(otelPrefix, otelPrefix)
+		sv.initPrefixes = append([]string{otelPrefix}, sv.initPrefixes...)
+	}
+
+	// admin server is stopped after the http server,
+	// so probes see it draining
+	adminServer := httpserver.NewAdmin(sv.name)
+	sv.adminServer = adminServer
+
+	sv.subServices = append(sv.subServices, adminServer)
+
 	//// Http server
 	httpServer := httpserver.New(sv.name)
+	httpServer.SetAdminServer(adminServer)
+	if sv.httpDisabled {
+		httpServer.Disable()
+	}
 	sv.httpServer = httpServer
 
 	sv.subServices = append(sv.subServices, httpServer)
 
-	sv.initFlags()
+	for _, name := range sv.httpServerNames {
+		named := httpserver.NewNamed(sv.name, name)
+		sv.httpServers[name] = named
+		sv.subServices = append(sv.subServices, named)
+	}
+
+	grpcServer := grpcserver.New(sv.name)
+	sv.grpcServer = grpcServer
+
+	sv.subServices = append(sv.subServices, grpcServer)
 
 	if sv.name == "" {
 		if len(os.Args) >= 2 {
@@ -66,13 +172,30 @@ func New(opts ...Option) Service {
 		}
 	}
 
-	loggerRunnable := logger.GetCurrent().(Runnable)
-	loggerRunnable.InitFlags()
+	sv.resolveBuildInfo()
+	logger.SetServiceInfo(sv.name, sv.version)
+	otel.SetServiceInfo(sv.name, sv.version)
+
+	sv.cmdLine = newFlagSet(sv.name, flag.NewFlagSet(sv.name, flag.ContinueOnError))
+	sv.cmdLine.envPrefix = sv.envPrefix
+	sv.recordAppFlags()
+	sv.initFlags()
+
+	// the logger of WithLogger may have neither flags nor config
+	loggerRunnable, _ := logger.GetCurrent().(Runnable)
+	if loggerRunnable != nil {
+		sv.registerFlags(loggerRunnable)
+	}
 
-	sv.cmdLine = newFlagSet(sv.name, flag.CommandLine)
 	sv.parseFlags()
+	sv.recordCLIFlags()
+	sv.initErr = errors.Join(sv.initErr, sv.applyFlagValues(), sv.applyEnv(), sv.applyConfigFile(), sv.applyProfile())
 
-	_ = loggerRunnable.Configure()
+	if loggerRunnable != nil {
+		sv.initErr = errors.Join(sv.initErr, loggerRunnable.Configure())
+	}
+	// with the configured backend
+	sv.logger = logger.GetCurrent().GetLogger("service")
 
 	return sv
 }
@@ -86,13 +209,69 @@ func (s *service) Version() string {
 }
 
 func (s *service) Init() error {
-	for _, dbSv := range s.initServices {
-		if err := dbSv.Run(); err != nil {
+	if s.checkMode {
+		s.runCheck()
+	}
+
+	if s.initErr != nil {
+		return s.initErr
+	}
+
+	if err := s.resolveFlagValues(); err != nil {
+		return err
+	}
+
+	if err := s.checkFlagMeta(); err != nil {
+		return err
+	}
+
+	if s.profile != "" {
+		s.logger.Infof("active profile: %s", s.profile)
+	}
+
+	if s.printEffectiveConfig {
+		s.dumpEffectiveConfig(os.Stdout)
+	}
+
+	if s.printConfig {
+		if err := s.writeStartupInfo(os.Stdout); err != nil {
 			return err
 		}
+		os.Exit(0)
 	}
 
-	return nil
+	s.httpServer.AddOpsHandler(s.debugConfigHandler)
+	s.httpServer.AddOpsHandler(s.logLevelHandler)
+	s.httpServer.AddOpsHandler(s.reloadHandler)
+
+	for _, hs := range s.allHTTPServers() {
+		hs.SetBuildInfo(s.BuildInfo())
+		for _, r := range s.runnables() {
+			if hc, ok := r.(HealthChecker); ok {
+				hs.AddHealthCheck(r.Name(), hc.HealthCheck)
+			}
+		}
+	}
+
+	if err := validateWaitForReady(s.waitForReady); err != nil {
+		return err
+	}
+	s.initReadiness()
+
+	levels, err := s.initLevels()
+	if err != nil {
+		return err
+	}
+
+	for _, level := range levels {
+		s.initOrder = append(s.initOrder, level...)
+	}
+	s.logger.Debugf("init order: %s", formatLevels(levels))
+
+	if err := s.runInitLevels(levels); err != nil {
+		return err
+	}
+	return s.runHooks("on init", s.onInit)
 }
 
 func (s *service) IsRegistered() bool {
@@ -100,41 +279,97 @@ func (s *service) IsRegistered() bool {
 }
 
 func (s *service) Start() error {
-	signal.Notify(s.signalChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
-	c := s.run()
+	if err := s.checkHTTPAddrs(); err != nil {
+		return err
+	}
+	s.logStartupInfo()
+
+	if !s.signalsDisabled {
+		signal.Notify(s.signalChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		defer signal.Stop(s.signalChan)
+	}
+	var c <-chan error
+	hookErr := make(chan error, 1)
+	readyErr := make(chan error, 1)
+
+	if s.waitForReady != WaitForReadyNone {
+		go func() { readyErr <- s.waitReady() }()
+	}
+	// with the listen strategy, servers run once components are ready
+	if s.waitForReady != WaitForReadyListen {
+		c = s.run()
+		go s.waitStarted(hookErr)
+	}
 	//s.stopFunc = s.activeRegistry()
 
 	for {
 		select {
+		case err := <-readyErr:
+			if err != nil {
+				s.logger.Error(err.Error())
+				return errors.Join(err, s.shutdownWithTimeout())
+			}
+			if c == nil {
+				c = s.run()
+				go s.waitStarted(hookErr)
+			}
+
 		case err := <-c:
 			if err != nil {
 				s.logger.Error(err.Error())
-				s.Stop()
-				return err
+				return errors.Join(err, s.shutdownWithTimeout())
 			}
 
+		case err := <-hookErr:
+			s.logger.Error(err.Error())
+			return errors.Join(err, s.shutdownWithTimeout())
+
 		case sig := <-s.signalChan:
 			s.logger.Infoln(sig)
 			switch sig {
 			case syscall.SIGHUP:
-				return nil
+				// logrotate moved the log file
+				if reopener, ok := logger.GetCurrent().(interface{ Reopen() error }); ok {
+					if err := reopener.Reopen(); err != nil {
+						s.logger.Errorf("reopen log file: %s", err.Error())
+					}
+				}
+				if err := s.reloadHTTPServer(); err != nil {
+					s.logger.Errorf("reload http server: %s", err.Error())
+				}
+				continue
 			default:
-				s.Stop()
-				return nil
+				return s.shutdownWithTimeout()
 			}
+
+		case <-s.doneChan:
+			return s.shutdownErr
 		}
 	}
 }
 
 func (s *service) initFlags() {
-	flag.StringVar(&s.env, "app-env", DevEnv, "Env for service. Ex: dev | stg | prd")
+	s.recordFlagOwner(serviceFlagOwner, func() {
+		s.cmdLine.StringVar(&s.env, "app-env", DevEnv, "Env for service. Ex: dev | stg | prd")
+		s.cmdLine.StringVar(&s.configFile, "config", "", "YAML or JSON file of flag values, nested sections are joined with -. Ex: gin: {port: 8080} => gin-port")
+		s.cmdLine.BoolVar(&s.configStrict, "config-strict", false, "Fail on unknown keys in the config file instead of logging them")
+		s.cmdLine.StringVar(&s.profile, "profile", "", "Profile selecting flag defaults. Ex: dev | staging | prod (also $"+profileEnvName+")")
+		s.cmdLine.BoolVar(&s.printEffectiveConfig, "print-effective-config", false, "Print the effective config with the source of each value at startup")
+		s.cmdLine.BoolVar(&s.printConfig, "print-config", false, "Print the service info and the resolved config of every component as json, secrets redacted, and exit")
+		s.cmdLine.DurationVar(&s.shutdownTimeout, "shutdown-timeout", defaultShutdownTimeout, "Max time to wait for all components to stop")
+		s.cmdLine.StringVar(&s.waitForReady, "service-wait-for-ready", WaitForReadyReadiness, "Wait for components implementing Ready: readiness (readyz fails until ready) | listen (servers listen once ready) | none")
+		s.cmdLine.DurationVar(&s.startupTimeout, "service-startup-timeout", defaultStartupTimeout, "Max time to wait for components to be ready, the service fails after it")
+		s.cmdLine.BoolVar(&s.checkMode, "check", false, "Validate the config, configure the components and check their connectivity without running them, print the report and exit. Exits 1 if anything failed")
+		s.cmdLine.StringVar(&s.checkFormat, "check-format", CheckFormatText, "Format of the check report: text | json")
+		s.cmdLine.DurationVar(&s.checkTimeout, "check-timeout", defaultCheckTimeout, "Max time of the connectivity check of each component")
+	})
 
 	for _, subService := range s.subServices {
-		subService.InitFlags()
+		s.registerFlags(subService)
 	}
 
-	for _, dbService := range s.initServices {
-		dbService.InitFlags()
+	for _, prefix := range s.initPrefixes {
+		s.registerFlags(s.initServices[prefix])
 	}
 }
 
@@ -150,24 +385,93 @@ func (s *service) run() <-chan error {
 	return c
 }
 
-// Stop service and stop its components at the same time
+// Stop service and its components, waiting at most shutdown-timeout
 func (s *service) Stop() {
-	s.logger.Infoln("Stopping service...")
-	stopChan := make(chan bool)
-	for _, subService := range s.subServices {
-		go func(subSv Runnable) { stopChan <- <-subSv.Stop() }(subService)
+	if err := s.shutdownWithTimeout(); err != nil {
+		s.logger.Error(err.Error())
 	}
+}
 
-	for _, dbSv := range s.initServices {
-		go func(subSv Runnable) { stopChan <- <-subSv.Stop() }(dbSv)
+func (s *service) shutdownWithTimeout() error {
+	timeout := s.shutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
 	}
 
-	for i := 0; i < len(s.subServices)+len(s.initServices); i++ {
-		<-stopChan
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return s.Shutdown(ctx)
+}
+
+// Shutdown stops all components in reverse start order.
+// It returns an error naming the components which did not stop before ctx is done.
+// Calling it more than once returns the result of the first call.
+func (s *service) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		s.runStoppingHooks()
+		s.shutdownErr = s.stopRunnables(ctx)
+		close(s.doneChan)
+	})
+
+	return s.shutdownErr
+}
+
+// runnables returns all components in start order,
+// init components come first since they run before the others
+func (s *service) runnables() []Runnable {
+	result := make([]Runnable, 0, len(s.initPrefixes)+len(s.subServices))
+
+	order := s.initOrder
+	if order == nil {
+		order = s.initPrefixes
+	}
+
+	for _, prefix := range order {
+		result = append(result, s.initServices[prefix])
 	}
 
-	//s.stopFunc()
-	s.logger.Infoln("service stopped")
+	return append(result, s.subServices...)
+}
+
+// stopRunnables stops the components in reverse order. When ctx is done,
+// the remaining ones are still told to stop without waiting for them,
+// the logger is flushed in any case.
+func (s *service) stopRunnables(ctx context.Context) error {
+	s.logger.Infoln("Stopping service...")
+
+	var err error
+	components := s.runnables()
+	for i := len(components) - 1; i >= 0; i-- {
+		select {
+		case clean := <-components[i].Stop():
+			if !clean {
+				s.logger.Warnf("%s didn't stop cleanly", components[i].Name())
+			}
+			continue
+		case <-ctx.Done():
+		}
+
+		pending := make([]string, 0, i+1)
+		for _, r := range components[:i+1] {
+			pending = append(pending, r.Name())
+		}
+		err = fmt.Errorf("shutdown timeout, components not stopped: %s", strings.Join(pending, ", "))
+
+		for j := i - 1; j >= 0; j-- {
+			stopped := components[j].Stop()
+			go func() { <-stopped }()
+		}
+		break
+	}
+
+	if err == nil {
+		s.logger.Infoln("service stopped")
+	}
+	if flushErr := logger.Flush(); flushErr != nil {
+		err = errors.Join(err, fmt.Errorf("flush log: %w", flushErr))
+	}
+	return err
 }
 
 func (s *service) RunFunction(fn Function) error {
@@ -178,12 +482,32 @@ func (s *service) HTTPServer() HttpServer {
 	return s.httpServer
 }
 
+func (s *service) HTTPServerNamed(name string) HttpServer {
+	if name == httpserver.DefaultPrefix {
+		return s.httpServer
+	}
+	if hs, ok := s.httpServers[name]; ok {
+		return hs
+	}
+	return nil
+}
+
+func (s *service) AdminServer() AdminServer {
+	return s.adminServer
+}
+
+func (s *service) GRPCServer() GrpcServer {
+	return s.grpcServer
+}
+
 func (s *service) Logger(prefix string) logger.Logger {
 	return logger.GetCurrent().GetLogger(prefix)
 }
 
 func (s *service) OutEnv() {
-	s.cmdLine.GetSampleEnvs()
+	if err := s.ExportConfig(os.Stdout, ExportFormatEnv); err != nil {
+		s.logger.Error(err.Error())
+	}
 }
 
 func (s *service) parseFlags() {
@@ -202,7 +526,17 @@ func (s *service) parseFlags() {
 		s.logger.Fatalf("Loading env(%s): %s", envFile, err.Error())
 	}
 
-	s.cmdLine.Parse([]string{})
+	args := s.args
+	if s.unknownFlagsIgnored {
+		args = knownArgs(s.cmdLine.FlagSet, args)
+	}
+
+	if err := s.cmdLine.FlagSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		s.initErr = errors.Join(s.initErr, err)
+	}
 }
 
 // Service must have a name for service discovery and logging/monitoring
@@ -215,6 +549,12 @@ func WithVersion(version string) Option {
 	return func(s *service) { s.version = version }
 }
 
+// Env vars bound to flags will have this prefix.
+// Ex: WithEnvPrefix("MYAPP") => flag gin-port is read from MYAPP_GIN_PORT
+func WithEnvPrefix(prefix string) Option {
+	return func(s *service) { s.envPrefix = prefix }
+}
+
 // Service will write log data to file with this option
 func WithFileLogger() Option {
 	return func(s *service) {
@@ -222,21 +562,110 @@ func WithFileLogger() Option {
 	}
 }
 
+// WithLogger replaces the current logger by l, e.g. a logger recording
+// the entries in tests. It's configured by the service when it's a Runnable.
+func WithLogger(l logger.ServiceLogger) Option {
+	return func(s *service) {
+		logger.SetCurrent(l)
+		s.logger = l.GetLogger("service")
+	}
+}
+
+// WithAppFlagSet makes the service add the flags of fs instead of the ones
+// of flag.CommandLine, e.g. to isolate services created in one process
+func WithAppFlagSet(fs *flag.FlagSet) Option {
+	return func(s *service) { s.appFlags = fs }
+}
+
+// WithFlagValues sets flags like command line args, they override
+// the env, the config file and the profile. Ex: {"gin-port": "0"}
+func WithFlagValues(values map[string]string) Option {
+	return func(s *service) {
+		if s.flagValues == nil {
+			s.flagValues = map[string]string{}
+		}
+		for name, value := range values {
+			s.flagValues[name] = value
+		}
+	}
+}
+
+// WithArgs sets the command line args parsed into the flags, os.Args[1:] by default.
+// Ex: WithArgs([]string{}) in tests
+func WithArgs(args []string) Option {
+	return func(s *service) { s.args = args }
+}
+
+// WithUnknownFlagsIgnored makes the service parse only its flags in the args,
+// e.g. when it's run by a cobra command having its own flags and subcommands
+func WithUnknownFlagsIgnored() Option {
+	return func(s *service) { s.unknownFlagsIgnored = true }
+}
+
+// WithSignalsDisabled makes Start ignore SIGINT, SIGTERM and SIGHUP,
+// the service is only stopped by Stop or Shutdown, e.g. when embedded or in tests
+func WithSignalsDisabled() Option {
+	return func(s *service) { s.signalsDisabled = true }
+}
+
+// WithHTTPServerDisabled makes a headless service (workers, consumers...):
+// the http server never listens, HTTPServer() stays usable but ignores handlers.
+// Start still blocks until a stop signal. Set admin-port to serve health checks.
+func WithHTTPServerDisabled() Option {
+	return func(s *service) { s.httpDisabled = true }
+}
+
+// WithHTTPServer adds another http server, e.g. for partners on their own port.
+// Its flags start with name (partner-port, partner-cors-enabled...), it has its
+// own handlers and health endpoints and it's returned by HTTPServerNamed(name).
+func WithHTTPServer(name string) Option {
+	return func(s *service) {
+		if name == "" || name == httpserver.DefaultPrefix || strings.ContainsAny(name, " =") {
+			s.initErr = errors.Join(s.initErr, fmt.Errorf("invalid http server name %q", name))
+			return
+		}
+		for _, n := range s.httpServerNames {
+			if n == name {
+				s.initErr = errors.Join(s.initErr, fmt.Errorf("http server %s is duplicated", name))
+				return
+			}
+		}
+		s.httpServerNames = append(s.httpServerNames, name)
+	}
+}
+
 // Add Runnable component to SDK
 // These components will run parallel in when service run
 func WithRunnable(r Runnable) Option {
 	return func(s *service) { s.subServices = append(s.subServices, r) }
 }
 
+// WithReplacedRunnable replaces the init component registered with the same
+// prefix by r, keeping its init order, or adds r. Ex: a mock of mysql in tests.
+// It must come after the option registering the replaced component.
+func WithReplacedRunnable(r PrefixRunnable) Option {
+	return func(s *service) {
+		if _, ok := s.initServices[r.GetPrefix()]; !ok {
+			s.initPrefixes = append(s.initPrefixes, r.GetPrefix())
+		}
+		s.initServices[r.GetPrefix()] = r
+	}
+}
+
 // Add init component to SDK
-// These components will run sequentially before service run
+// These components run before service run, ordered by their dependencies (see Dependent):
+// a component runs once the ones it depends on succeeded, and the components
+// without dependency between them run concurrently, so they must not rely on
+// the side effects of one another without declaring it in DependsOn
 func WithInitRunnable(r PrefixRunnable) Option {
 	return func(s *service) {
-		if _, ok := s.initServices[r.GetPrefix()]; ok {
-			log.Fatal(fmt.Sprintf("prefix %s is duplicated", r.GetPrefix()))
+		if registered, ok := s.initServices[r.GetPrefix()]; ok {
+			s.initErr = errors.Join(s.initErr, fmt.Errorf("prefix %q of %s is already registered by %s", r.GetPrefix(), r.Name(), registered.Name()))
+			return
 		}
 
 		s.initServices[r.GetPrefix()] = r
+		s.initPrefixes = append(s.initPrefixes, r.GetPrefix())
 	}
 }
 
@@ -260,4 +689,12 @@ func (s *service) MustGet(prefix string) interface{} {
 	return db
 }
 
+func (s *service) ListPrefixes() []string {
+	prefixes := append([]string{}, s.initPrefixes...)
+	sort.Strings(prefixes)
+	return prefixes
+}
+
 func (s *service) Env() string { return s.env }
+
+func (s *service) Profile() string { return s.profile }
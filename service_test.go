@@ -0,0 +1,203 @@
+package goservice
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taimaifika/go-sdk/httpserver"
+	"github.com/taimaifika/go-sdk/logger"
+)
+
+type stopRecorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *stopRecorder) add(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, name)
+}
+
+type fakeRunnable struct {
+	name      string
+	prefix    string
+	stopDelay time.Duration
+	recorder  *stopRecorder
+}
+
+func (f *fakeRunnable) Name() string      { return f.name }
+func (f *fakeRunnable) GetPrefix() string { return f.prefix }
+func (f *fakeRunnable) Get() interface{}  { return f }
+func (f *fakeRunnable) InitFlags()        {}
+func (f *fakeRunnable) Configure() error  { return nil }
+func (f *fakeRunnable) Run() error        { return nil }
+func (f *fakeRunnable) Stop() <-chan bool {
+	c := make(chan bool, 1)
+	go func() {
+		time.Sleep(f.stopDelay)
+		f.recorder.add(f.name)
+		c <- true
+	}()
+	return c
+}
+
+func newTestService(opts ...Option) *service {
+	logger.InitServLogger(false)
+
+	s := &service{
+		logger:       logger.GetCurrent().GetLogger("service"),
+		signalChan:   make(chan os.Signal, 1),
+		doneChan:     make(chan struct{}),
+		initServices: map[string]PrefixRunnable{},
+		flagSources:  map[string]ConfigSource{},
+		flagOwners:   map[string]string{},
+		flagAliases:  map[string]string{},
+		cmdLine:      newFlagSet("test", flag.NewFlagSet("test", flag.ContinueOnError)),
+		waitForReady: WaitForReadyReadiness,
+	}
+	s.initFlagMeta()
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func TestShutdownReverseOrder(t *testing.T) {
+	rec := &stopRecorder{}
+	s := newTestService(
+		WithInitRunnable(&fakeRunnable{name: "db", prefix: "db", recorder: rec}),
+		WithInitRunnable(&fakeRunnable{name: "cache", prefix: "cache", recorder: rec}),
+		WithRunnable(&fakeRunnable{name: "consumer", recorder: rec}),
+		WithRunnable(&fakeRunnable{name: "http", recorder: rec}),
+	)
+
+	assert.Nil(t, s.Shutdown(context.Background()), "must be nil")
+	assert.Equal(t, []string{"http", "consumer", "cache", "db"}, rec.names, "should be equal")
+}
+
+// flushLogger counts the flushes of the current logger
+type flushLogger struct {
+	logger.ServiceLogger
+	flushes atomic.Int32
+}
+
+func (l *flushLogger) Flush() error {
+	l.flushes.Add(1)
+	return nil
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	rec := &stopRecorder{}
+	s := newTestService(
+		WithInitRunnable(&fakeRunnable{name: "db", prefix: "db", recorder: rec}),
+		WithRunnable(&fakeRunnable{name: "slow", stopDelay: time.Second, recorder: rec}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	previous := logger.GetCurrent()
+	flushed := &flushLogger{ServiceLogger: previous}
+	logger.SetCurrent(flushed)
+	defer logger.SetCurrent(previous)
+
+	err := s.Shutdown(ctx)
+	assert.NotNil(t, err, "should be an error")
+	assert.Contains(t, err.Error(), "components not stopped: db, slow")
+	assert.Equal(t, int32(1), flushed.flushes.Load(), "the logger should be flushed")
+
+	// db is still told to stop, without waiting for slow
+	assert.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.names) > 0 && rec.names[0] == "db"
+	}, 500*time.Millisecond, 10*time.Millisecond, "db should be stopped")
+}
+
+func TestShutdownStopsStart(t *testing.T) {
+	s := newTestService(WithRunnable(&fakeRunnable{name: "worker", recorder: &stopRecorder{}}))
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- s.Start() }()
+
+	assert.Nil(t, s.Shutdown(context.Background()), "must be nil")
+
+	select {
+	case err := <-errChan:
+		assert.Nil(t, err, "must be nil")
+	case <-time.After(time.Second):
+		t.Fatal("Start should return after Shutdown")
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	s := newTestService(WithName("orders"), WithVersion("1.2.3"), WithBuildInfo("abc123", "2024-01-01T00:00:00Z"))
+	s.resolveBuildInfo()
+
+	info := s.BuildInfo()
+	assert.Equal(t, "orders", info.Name, "should be equal")
+	assert.Equal(t, "1.2.3", info.Version, "should be equal")
+	assert.Equal(t, "abc123", info.Commit, "explicit build info must not be overridden")
+	assert.Equal(t, "2024-01-01T00:00:00Z", info.BuildTime, "should be equal")
+	assert.NotEmpty(t, info.GoVersion, "should not be empty")
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	s := newTestService()
+	engine := gin.New()
+	s.logLevelHandler(engine)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(`{"prefix":"gin","level":"debug"}`)))
+	assert.Equal(t, http.StatusOK, w.Code, "should be equal")
+	assert.Equal(t, "debug", logger.GetCurrent().Levels()["gin"], "should be equal")
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(`{"prefix":"gin","level":"loud"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "should be equal")
+}
+
+func TestStartListenError(t *testing.T) {
+	busy, err := net.Listen("tcp", "localhost:0")
+	assert.Nil(t, err, "must be nil")
+	defer busy.Close()
+
+	rec := &stopRecorder{}
+	s := newTestService(
+		WithInitRunnable(&fakeRunnable{name: "db", prefix: "db", recorder: rec}),
+		WithRunnable(&fakeRunnable{name: "worker", recorder: rec}),
+	)
+	gs := httpserver.New("test")
+	gs.Config.Port = busy.Addr().(*net.TCPAddr).Port
+	gs.AddHandler(func(*gin.Engine) {})
+	s.httpServer = gs
+	s.subServices = append(s.subServices, gs)
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- s.Start() }()
+
+	select {
+	case err := <-errChan:
+		assert.NotNil(t, err, "should be an error")
+		assert.True(t, errors.Is(err, syscall.EADDRINUSE), "should be address in use")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start should return the listen error")
+	}
+	assert.ElementsMatch(t, []string{"worker", "db"}, rec.names, "started components should be stopped")
+}